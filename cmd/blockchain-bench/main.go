@@ -0,0 +1,233 @@
+// Command blockchain-bench measures the throughput a node sustains.
+//
+//	blockchain-bench [--node URL | --in-process] [--workload transfer|contract] [--rate N] [--duration D] [--json]
+//	blockchain-bench --mining [--difficulties 4,5] [--blocks N] [--workers N] [--json]
+//
+// It fires signed transactions or contract calls from generated wallets at
+// a target rate, has the node mine them, and reports accepted and mined
+// TPS, submission latency percentiles, overflows and block intervals. With
+// --in-process it benchmarks a node of its own, called without sockets.
+// With --mining it instead times mining blocks on one goroutine against a
+// parallel miner.
+// It exits with 0 on success, 1 when the run fails, and 2 on usage errors.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/bench"
+	"github.com/anekazek/simple-blockchain/pkg/client"
+	"github.com/anekazek/simple-blockchain/pkg/config"
+	"github.com/anekazek/simple-blockchain/pkg/node"
+)
+
+// Exit codes
+const (
+	exitOK     = 0
+	exitFailed = 1
+	exitUsage  = 2
+)
+
+// defaultNode is the node benchmarked unless BLOCKCHAIN_NODE or --node names one
+const defaultNode = "http://localhost:8080"
+
+// Environment variables read by the benchmark, shared with blockchain-cli
+const (
+	envNode   = "BLOCKCHAIN_NODE"
+	envAPIKey = "BLOCKCHAIN_API_KEY"
+)
+
+// readyTimeout bounds the startup of an in-process node
+const readyTimeout = 30 * time.Second
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr, os.Getenv))
+}
+
+// options are the command-line settings
+type options struct {
+	bench      bench.Config
+	nodeURL    string
+	apiKey     string
+	inProcess  bool
+	difficulty int
+	poolSize   int
+	json       bool
+	mining     bool
+	mineBench  bench.MiningConfig
+}
+
+// textReport is a benchmark outcome that can be written as text
+type textReport interface {
+	WriteText(w io.Writer) error
+}
+
+// run benchmarks a node as the arguments say and returns the exit code
+func run(ctx context.Context, args []string, out, errOut io.Writer, getenv func(string) string) int {
+	opts := options{bench: bench.DefaultConfig(), mineBench: bench.DefaultMiningConfig(), nodeURL: getenv(envNode), apiKey: getenv(envAPIKey)}
+	if opts.nodeURL == "" {
+		opts.nodeURL = defaultNode
+	}
+	defaults := config.Default()
+	opts.difficulty, opts.poolSize = defaults.Consensus.Difficulty, defaults.Pool.Size
+
+	fs := flag.NewFlagSet("blockchain-bench", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	fs.StringVar(&opts.nodeURL, "node", opts.nodeURL, "node API URL")
+	fs.BoolVar(&opts.inProcess, "in-process", false, "benchmark an in-process node instead of --node")
+	fs.StringVar(&opts.bench.Workload, "workload", opts.bench.Workload, "transfer or contract")
+	fs.IntVar(&opts.bench.Wallets, "wallets", opts.bench.Wallets, "number of wallets signing the requests")
+	fs.Float64Var(&opts.bench.Rate, "rate", opts.bench.Rate, "target requests per second, 0 for as fast as possible")
+	fs.DurationVar(&opts.bench.Duration, "duration", opts.bench.Duration, "how long requests are sent")
+	fs.IntVar(&opts.bench.Concurrency, "concurrency", opts.bench.Concurrency, "maximum requests in flight")
+	fs.DurationVar(&opts.bench.MineInterval, "mine-interval", opts.bench.MineInterval, "how often to have the node mine pending transactions, 0 to leave it to the node's miner")
+	fs.DurationVar(&opts.bench.Drain, "drain", opts.bench.Drain, "how long to wait for pending transactions to be mined after the load")
+	fs.DurationVar(&opts.bench.PollInterval, "poll-interval", opts.bench.PollInterval, "how often the chain height is polled to time blocks")
+	fs.IntVar(&opts.difficulty, "difficulty", opts.difficulty, "mining difficulty of an in-process node")
+	fs.IntVar(&opts.poolSize, "pool-size", opts.poolSize, "transaction pool capacity of an in-process node")
+	fs.BoolVar(&opts.json, "json", false, "print the report as JSON")
+	fs.BoolVar(&opts.mining, "mining", false, "compare mining on one goroutine with a parallel miner instead of benchmarking a node")
+	fs.Func("difficulties", "comma-separated difficulties of the mining benchmark (default 4,5)", func(value string) error {
+		difficulties, err := parseDifficulties(value)
+		opts.mineBench.Difficulties = difficulties
+		return err
+	})
+	fs.IntVar(&opts.mineBench.Blocks, "blocks", opts.mineBench.Blocks, "blocks each miner mines per difficulty in the mining benchmark")
+	fs.IntVar(&opts.mineBench.Workers, "workers", opts.mineBench.Workers, "parallel miner workers in the mining benchmark, 0 for one per CPU")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(errOut, "unexpected argument %q\n", fs.Arg(0))
+		return exitUsage
+	}
+	if opts.difficulty <= 0 || opts.poolSize <= 0 {
+		fmt.Fprintln(errOut, "--difficulty and --pool-size must be positive")
+		return exitUsage
+	}
+	if opts.mineBench.Blocks <= 0 || opts.mineBench.Workers < 0 {
+		fmt.Fprintln(errOut, "--blocks must be positive and --workers not negative")
+		return exitUsage
+	}
+
+	var report textReport
+	var err error
+	if opts.mining {
+		report, err = bench.RunMining(ctx, opts.mineBench)
+	} else {
+		report, err = benchmark(ctx, opts)
+	}
+	if err != nil {
+		fmt.Fprintf(errOut, "error: %v\n", err)
+		return exitFailed
+	}
+	if opts.json {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = report.WriteText(out)
+	}
+	if err != nil {
+		fmt.Fprintf(errOut, "error: %v\n", err)
+		return exitFailed
+	}
+	return exitOK
+}
+
+// parseDifficulties parses a comma-separated list of positive difficulties
+func parseDifficulties(value string) ([]int, error) {
+	var difficulties []int
+	for _, field := range strings.Split(value, ",") {
+		difficulty, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || difficulty <= 0 {
+			return nil, fmt.Errorf("invalid difficulty %q", field)
+		}
+		difficulties = append(difficulties, difficulty)
+	}
+	return difficulties, nil
+}
+
+// benchmark runs the benchmark against the configured node
+func benchmark(ctx context.Context, opts options) (*bench.Report, error) {
+	if !opts.inProcess {
+		return bench.Run(ctx, opts.nodeURL, client.New(opts.nodeURL, opts.apiKey), opts.bench)
+	}
+
+	n, cleanup, err := startNode(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	return bench.Run(ctx, "in-process node", bench.InProcess(n), opts.bench)
+}
+
+// startNode starts a quiet in-process dev-mode node with memory storage
+// and waits until it is ready. The benchmark mines on it, so its miner is
+// off.
+func startNode(ctx context.Context, opts options) (*node.Node, func(), error) {
+	dataDir, err := os.MkdirTemp("", "blockchain-bench-")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg := config.Default()
+	cfg.Node.DataDir = dataDir
+	cfg.API.HTTPPort, cfg.API.WSPort = 0, 0
+	cfg.Metrics.OnAPI = true
+	cfg.Consensus.Difficulty = opts.difficulty
+	cfg.Pool.Size = opts.poolSize
+	// The faucet funds the wallets of the transfer workload
+	cfg.Dev.Enabled = true
+
+	// The node logs every request and block, which would mix with the
+	// report
+	log.SetOutput(io.Discard)
+	restore := func() {
+		log.SetOutput(os.Stderr)
+	}
+
+	n, err := node.New(&cfg)
+	if err != nil {
+		restore()
+		os.RemoveAll(dataDir)
+		return nil, nil, err
+	}
+	cleanup := func() {
+		stopCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		n.Stop(stopCtx)
+		restore()
+		os.RemoveAll(dataDir)
+	}
+	if err := n.Start(ctx); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	deadline := time.Now().Add(readyTimeout)
+	for !n.State().IsReady() {
+		if time.Now().After(deadline) {
+			cleanup()
+			return nil, nil, errors.New("in-process node did not become ready")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return n, cleanup, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/bench"
+)
+
+// runBench runs the command line, returning the exit code and output
+func runBench(args ...string) (int, string, string) {
+	var out, errOut bytes.Buffer
+	code := run(context.Background(), args, &out, &errOut, func(string) string { return "" })
+	return code, out.String(), errOut.String()
+}
+
+func TestInProcessBenchmark(t *testing.T) {
+	for _, workload := range []string{bench.WorkloadTransfer, bench.WorkloadContract} {
+		code, out, errOut := runBench("--in-process", "--json", "--workload", workload,
+			"--wallets", "2", "--rate", "50", "--duration", "300ms", "--concurrency", "4",
+			"--mine-interval", "50ms", "--drain", "2s", "--poll-interval", "20ms")
+		if code != exitOK {
+			t.Fatalf("%s: exited %d: %s", workload, code, errOut)
+		}
+
+		var report bench.Report
+		if err := json.Unmarshal([]byte(out), &report); err != nil {
+			t.Fatalf("%s: %v in %s", workload, err, out)
+		}
+		if report.Sent == 0 || report.Accepted == 0 {
+			t.Errorf("%s: sent %d, accepted %d: %v", workload, report.Sent, report.Accepted, report.Errors)
+		}
+		if report.Latency.Count != report.Sent {
+			t.Errorf("%s: %d latencies for %d requests", workload, report.Latency.Count, report.Sent)
+		}
+		if workload == bench.WorkloadTransfer && report.Mined == 0 {
+			t.Errorf("transfer: nothing mined of %d accepted", report.Accepted)
+		}
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"--difficulty", "0"},
+		{"--difficulties", "4,x", "--mining"},
+		{"stray"},
+	} {
+		if code, _, _ := runBench(args...); code != exitUsage {
+			t.Errorf("%v exited %d, want %d", args, code, exitUsage)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// blockGet shows a block by hash or height
+func blockGet(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("block get")
+	positional, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError("expected a block hash or height")
+	}
+
+	block, err := c.findBlock(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	return c.print(block, func() {
+		c.details(
+			field{"Height", block.Index},
+			field{"Hash", block.Hash},
+			field{"Previous", block.PrevHash},
+			field{"Timestamp", block.Time().Format(time.RFC3339)},
+			field{"Difficulty", block.Difficulty},
+			field{"Nonce", block.Nonce},
+			field{"Merkle root", block.MerkleRoot},
+			field{"Transactions", len(blockchain.BlockTransactions(*block))},
+		)
+	})
+}
+
+// findBlock fetches a block by hash, or by height when ref is a number
+func (c *cli) findBlock(ctx context.Context, ref string) (*blockchain.Block, error) {
+	height, err := strconv.Atoi(ref)
+	if err != nil {
+		return c.client().Block(ctx, ref)
+	}
+
+	if height < 0 {
+		return nil, fmt.Errorf("no block at height %d", height)
+	}
+	blocks, err := c.client().BlockRange(ctx, height, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("no block at height %d", height)
+	}
+	return &blocks[0], nil
+}
+
+// chainInfo shows the node's height, pool and version
+func chainInfo(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("chain info")
+	if positional, err := parse(fs, args); err != nil {
+		return err
+	} else if len(positional) > 0 {
+		return usageError("unexpected argument " + positional[0])
+	}
+
+	stats, err := c.client().Stats(ctx)
+	if err != nil {
+		return err
+	}
+	return c.print(stats, func() {
+		fields := []field{
+			{"Node", c.nodeURL},
+			{"Height", stats.BlockCount - 1},
+			{"Pending transactions", stats.TransactionCount},
+			{"Peers", stats.PeerCount},
+			{"Healthy", stats.NodeHealthy},
+		}
+		if stats.Version != nil {
+			fields = append(fields,
+				field{"Node ID", orDash(stats.Version.NodeID)},
+				field{"Version", stats.Version.Version + " (" + stats.Version.Commit + ")"},
+				field{"API version", stats.Version.APIVersion},
+				field{"Protocol version", stats.Version.ProtocolVersion},
+			)
+		}
+		c.details(fields...)
+	})
+}
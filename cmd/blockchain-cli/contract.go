@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"strconv"
+
+	"github.com/anekazek/simple-blockchain/pkg/client"
+	"github.com/anekazek/simple-blockchain/pkg/wallet"
+)
+
+// contractDeploy deploys a contract from a file, owned by the key file's
+// address when one is given
+func contractDeploy(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("contract deploy")
+	contractType := fs.String("type", "", "contract type: lua or wasm")
+	name := fs.String("name", "", "contract name")
+	file := fs.String("file", "", "Lua source or WASM module to deploy")
+	keyFile := fs.String("keyfile", "", "key file of the owner, who signs the deploy")
+	owner := fs.String("owner", "", "owner address, for deploys with the API key")
+	if positional, err := parse(fs, args); err != nil {
+		return err
+	} else if len(positional) > 0 {
+		return usageError("unexpected argument " + positional[0])
+	}
+	switch {
+	case *contractType != "lua" && *contractType != "wasm":
+		return usageError("--type must be lua or wasm")
+	case *file == "":
+		return usageError("--file is required")
+	}
+
+	code, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	deploy := client.DeployRequest{Type: *contractType, Name: *name, Code: string(code), Owner: *owner}
+	if *contractType == "wasm" {
+		deploy.Code = base64.StdEncoding.EncodeToString(code)
+	}
+	if *keyFile != "" {
+		w, err := wallet.Load(*keyFile)
+		if err != nil {
+			return err
+		}
+		deploy.Sign(w, c.now())
+	}
+
+	deployment, err := c.client().DeployContract(ctx, deploy)
+	if err != nil {
+		return err
+	}
+	return c.print(deployment, func() {
+		c.details(
+			field{"Contract", deployment.ID},
+			field{"Owner", orDash(deployment.Owner)},
+			field{"Status", deployment.Status},
+		)
+	})
+}
+
+// contractExecute calls a contract function, as the key file's address
+// when one is given
+func contractExecute(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("contract execute")
+	function := fs.String("function", "", "function to call")
+	params := fs.String("params", "", "JSON array of parameters")
+	gasLimit := fs.Uint64("gas", 0, "gas limit (default: the engine's)")
+	keyFile := fs.String("keyfile", "", "key file of the caller, who signs the call")
+	positional, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(positional) != 1:
+		return usageError("expected a contract ID")
+	case *function == "":
+		return usageError("--function is required")
+	}
+	var list []json.RawMessage
+	if *params != "" && json.Unmarshal([]byte(*params), &list) != nil {
+		return usageError("--params must be a JSON array")
+	}
+
+	id := positional[0]
+	call := client.ExecuteRequest{Function: *function, GasLimit: *gasLimit}
+	if *params != "" {
+		call.Params = json.RawMessage(*params)
+	}
+	if *keyFile != "" {
+		w, err := wallet.Load(*keyFile)
+		if err != nil {
+			return err
+		}
+		call.Sign(w, id, c.now())
+	}
+
+	execution, err := c.client().ExecuteContract(ctx, id, call)
+	if err != nil {
+		return err
+	}
+	return c.print(execution, func() {
+		result, _ := json.Marshal(execution.Result)
+		c.details(
+			field{"Result", string(result)},
+			field{"Gas used", execution.GasUsed},
+			field{"Events", len(execution.Events)},
+			field{"Receipt", execution.Receipt},
+		)
+	})
+}
+
+// contractList lists the deployed contracts
+func contractList(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("contract list")
+	if positional, err := parse(fs, args); err != nil {
+		return err
+	} else if len(positional) > 0 {
+		return usageError("unexpected argument " + positional[0])
+	}
+
+	contracts, err := c.client().Contracts(ctx)
+	if err != nil {
+		return err
+	}
+	return c.print(contracts, func() {
+		rows := make([][]string, len(contracts))
+		for i, contract := range contracts {
+			rows[i] = []string{contract.ID, orDash(contract.Name), contract.Type, orDash(shorten(contract.Owner)), contract.Status, strconv.Itoa(contract.Functions)}
+		}
+		c.table([]string{"ID", "NAME", "TYPE", "OWNER", "STATUS", "FUNCTIONS"}, rows)
+	})
+}
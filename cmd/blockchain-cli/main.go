@@ -0,0 +1,190 @@
+// Command blockchain-cli manages wallets and talks to a node's REST API.
+//
+//	blockchain-cli [--node URL] [--json] <group> <command> [arguments]
+//
+// The node URL defaults to $BLOCKCHAIN_NODE or http://localhost:8080, and
+// $BLOCKCHAIN_API_KEY is sent as the admin token. It exits with 0 on
+// success, 1 when a command fails, and 2 on usage errors.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/client"
+)
+
+// Exit codes
+const (
+	exitOK     = 0
+	exitFailed = 1
+	exitUsage  = 2
+)
+
+// defaultNode is the node URL used unless BLOCKCHAIN_NODE or --node names one
+const defaultNode = "http://localhost:8080"
+
+// Environment variables read by the CLI
+const (
+	envNode   = "BLOCKCHAIN_NODE"
+	envAPIKey = "BLOCKCHAIN_API_KEY"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr, os.Getenv))
+}
+
+// cli holds the global options and output of one invocation
+type cli struct {
+	out     io.Writer
+	errOut  io.Writer
+	nodeURL string
+	apiKey  string
+	json    bool
+	now     func() time.Time
+}
+
+// command is a subcommand, named by its group and verb
+type command struct {
+	name    string // "group verb"
+	args    string // Positional arguments, for usage
+	summary string
+	run     func(ctx context.Context, c *cli, args []string) error
+}
+
+// commands lists every subcommand
+var commands = []command{
+	{"wallet new", "[--out FILE]", "create a key pair and save it to a key file", walletNew},
+	{"wallet show", "--keyfile FILE", "show a key file's address and public key", walletShow},
+	{"tx send", "--from-keyfile FILE --to ADDRESS --value N [--fee N] [--data S]", "sign and submit a transaction", txSend},
+	{"tx status", "ID", "show whether a transaction is pending or mined", txStatus},
+	{"block get", "HASH|HEIGHT", "show a block", blockGet},
+	{"chain info", "", "show the node's height, pool and version", chainInfo},
+	{"contract deploy", "--type lua|wasm --name NAME --file FILE [--keyfile FILE]", "deploy a contract", contractDeploy},
+	{"contract execute", "ID --function NAME [--params JSON] [--keyfile FILE]", "call a contract function", contractExecute},
+	{"contract list", "", "list deployed contracts", contractList},
+	{"peers list", "", "list the node's peers", peersList},
+	{"peers add", "ADDRESS", "have the node connect to a peer (needs the API key)", peersAdd},
+}
+
+// usageError is a command line that can't be run
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// run executes the command line and returns the exit code
+func run(args []string, out, errOut io.Writer, getenv func(string) string) int {
+	c := &cli{out: out, errOut: errOut, nodeURL: getenv(envNode), apiKey: getenv(envAPIKey), now: time.Now}
+	if c.nodeURL == "" {
+		c.nodeURL = defaultNode
+	}
+
+	global := c.flagSet("blockchain-cli")
+	if err := global.Parse(args); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(errOut, "%v\n\n", err)
+		}
+		c.usage()
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	rest := global.Args()
+	if len(rest) < 2 {
+		c.usage()
+		return exitUsage
+	}
+
+	name := rest[0] + " " + rest[1]
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return c.execute(cmd, rest[2:])
+		}
+	}
+	fmt.Fprintf(errOut, "unknown command %q\n\n", name)
+	c.usage()
+	return exitUsage
+}
+
+// execute runs a command, reporting its error
+func (c *cli) execute(cmd command, args []string) int {
+	err := cmd.run(context.Background(), c, args)
+	var usage usageError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, flag.ErrHelp):
+		fmt.Fprintf(c.errOut, "usage: blockchain-cli %s %s\n%s\n", cmd.name, cmd.args, cmd.summary)
+		return exitOK
+	case errors.As(err, &usage):
+		fmt.Fprintf(c.errOut, "%v\nusage: blockchain-cli %s %s\n", err, cmd.name, cmd.args)
+		return exitUsage
+	default:
+		fmt.Fprintf(c.errOut, "error: %v\n", err)
+		return exitFailed
+	}
+}
+
+// usage lists the commands
+func (c *cli) usage() {
+	fmt.Fprintln(c.errOut, "usage: blockchain-cli [--node URL] [--json] <group> <command> [arguments]")
+	fmt.Fprintln(c.errOut, "\ncommands:")
+	names := make([]string, 0, len(commands))
+	byName := make(map[string]command, len(commands))
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
+		byName[cmd.name] = cmd
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(c.errOut, "  %-18s %s\n", name, byName[name].summary)
+	}
+	fmt.Fprintf(c.errOut, "\nThe node defaults to $%s or %s; $%s is sent as the admin token.\n", envNode, defaultNode, envAPIKey)
+}
+
+// flagSet creates a flag set with the global flags, which every command
+// accepts
+func (c *cli) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&c.nodeURL, "node", c.nodeURL, "node API URL")
+	fs.BoolVar(&c.json, "json", c.json, "print JSON for scripts")
+	return fs
+}
+
+// parse parses a command's flags, which may come before, between or after
+// its positional arguments, and returns the positional arguments. Invalid
+// flags are usage errors.
+func parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, usageError(err.Error())
+		}
+		remaining := fs.Args()
+		if len(remaining) == 0 {
+			return positional, nil
+		}
+		// Everything after "--" is positional
+		if consumed := len(args) - len(remaining); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, remaining...), nil
+		}
+		positional = append(positional, remaining[0])
+		args = remaining[1:]
+	}
+}
+
+// client returns an API client for the node
+func (c *cli) client() *client.Client {
+	return client.New(c.nodeURL, c.apiKey)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/anekazek/simple-blockchain/pkg/api"
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/metrics"
+)
+
+// testNode is an API server backed by a chain and pool the test can inspect
+type testNode struct {
+	url   string
+	chain *blockchain.Chain
+	pool  *blockchain.TransactionPool
+}
+
+// newTestNode serves the node API on a fresh chain. Its pool doesn't check
+// balances, so unfunded wallets can send.
+func newTestNode(t *testing.T) *testNode {
+	t.Helper()
+	chain := blockchain.NewBlockchain()
+	pool := blockchain.NewTransactionPool(100)
+	s := api.NewEnhancedBlockchainServer(chain, pool, 1, metrics.NewBlockchainMetricsWithRegistry(prometheus.NewRegistry()))
+	server := httptest.NewServer(s.Handler())
+	t.Cleanup(server.Close)
+	return &testNode{url: server.URL, chain: chain, pool: pool}
+}
+
+// runCLI runs the command line against nodeURL with apiKey in the
+// environment, returning the exit code and output
+func runCLI(nodeURL, apiKey string, args ...string) (int, string, string) {
+	env := map[string]string{envNode: nodeURL, envAPIKey: apiKey}
+	var out, errOut bytes.Buffer
+	code := run(args, &out, &errOut, func(name string) string { return env[name] })
+	return code, out.String(), errOut.String()
+}
+
+// newKeyFile creates a wallet key file through the CLI and returns its path
+// and address
+func newKeyFile(t *testing.T) (string, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	code, out, errOut := runCLI("", "", "--json", "wallet", "new", "--out", path)
+	if code != exitOK {
+		t.Fatalf("wallet new exited %d: %s", code, errOut)
+	}
+	var info walletInfo
+	if err := json.Unmarshal([]byte(out), &info); err != nil {
+		t.Fatal(err)
+	}
+	return path, info.Address
+}
+
+func TestWalletNewAndShow(t *testing.T) {
+	path, address := newKeyFile(t)
+	if address == "" {
+		t.Fatal("wallet new printed no address")
+	}
+
+	code, out, errOut := runCLI("", "", "wallet", "show", "--keyfile", path)
+	if code != exitOK {
+		t.Fatalf("wallet show exited %d: %s", code, errOut)
+	}
+	if !strings.Contains(out, "Address:") || !strings.Contains(out, address) {
+		t.Fatalf("wallet show printed %q", out)
+	}
+
+	if code, _, _ := runCLI("", "", "wallet", "show", "--keyfile", filepath.Join(t.TempDir(), "missing.json")); code != exitFailed {
+		t.Fatalf("showing a missing key file exited %d, want %d", code, exitFailed)
+	}
+}
+
+func TestSendTransactionAndCheckStatus(t *testing.T) {
+	node := newTestNode(t)
+	path, address := newKeyFile(t)
+
+	code, out, errOut := runCLI(node.url, "", "--json", "tx", "send", "--from-keyfile", path, "--to", "bob", "--value", "5", "--fee", "0.5")
+	if code != exitOK {
+		t.Fatalf("tx send exited %d: %s", code, errOut)
+	}
+	var sent map[string]string
+	if err := json.Unmarshal([]byte(out), &sent); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := node.pool.GetTransaction(sent["id"])
+	if err != nil {
+		t.Fatalf("sent transaction isn't pending: %v", err)
+	}
+	if tx.From != address || tx.To != "bob" || tx.Value != 5 || tx.Fee != 0.5 || tx.Signature == "" {
+		t.Fatalf("node received %+v", tx)
+	}
+
+	code, out, errOut = runCLI(node.url, "", "tx", "status", sent["id"])
+	if code != exitOK {
+		t.Fatalf("tx status exited %d: %s", code, errOut)
+	}
+	if !strings.Contains(out, "pending") {
+		t.Fatalf("tx status printed %q", out)
+	}
+}
+
+func TestBlockGetAndChainInfo(t *testing.T) {
+	node := newTestNode(t)
+	block, err := node.chain.AddBlock(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ref := range []string{"1", block.Hash} {
+		code, out, errOut := runCLI(node.url, "", "block", "get", ref, "--json")
+		if code != exitOK {
+			t.Fatalf("block get %s exited %d: %s", ref, code, errOut)
+		}
+		var got blockchain.Block
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Hash != block.Hash {
+			t.Fatalf("block get %s printed block %s", ref, got.Hash)
+		}
+	}
+	if code, _, _ := runCLI(node.url, "", "block", "get", "7"); code != exitFailed {
+		t.Fatalf("getting a missing block exited %d, want %d", code, exitFailed)
+	}
+
+	code, out, errOut := runCLI(node.url, "", "chain", "info")
+	if code != exitOK {
+		t.Fatalf("chain info exited %d: %s", code, errOut)
+	}
+	if !strings.Contains(out, "Height:") || !strings.Contains(out, node.url) {
+		t.Fatalf("chain info printed %q", out)
+	}
+}
+
+func TestPeersSendAPIKey(t *testing.T) {
+	var added []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/peers", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			json.NewEncoder(w).Encode(map[string]interface{}{"peers": []map[string]interface{}{{"address": "10.0.0.1:3000", "height": 7}}})
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "admin token required"})
+			return
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		added = append(added, body["address"])
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	code, out, _ := runCLI(server.URL, "", "peers", "list")
+	if code != exitOK || !strings.Contains(out, "10.0.0.1:3000") || !strings.HasPrefix(out, "ADDRESS") {
+		t.Fatalf("peers list exited %d and printed %q", code, out)
+	}
+
+	if code, _, errOut := runCLI(server.URL, "", "peers", "add", "10.0.0.2:3000"); code != exitFailed || !strings.Contains(errOut, "admin token required") {
+		t.Fatalf("peers add without the API key exited %d: %s", code, errOut)
+	}
+	if code, _, errOut := runCLI(server.URL, "secret", "peers", "add", "10.0.0.2:3000"); code != exitOK {
+		t.Fatalf("peers add exited %d: %s", code, errOut)
+	}
+	if len(added) != 1 || added[0] != "10.0.0.2:3000" {
+		t.Fatalf("node was asked to add %v", added)
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"--help"}, exitOK},
+		{[]string{"tx", "send", "--help"}, exitOK},
+		{[]string{}, exitUsage},
+		{[]string{"wallet"}, exitUsage},
+		{[]string{"wallet", "burn"}, exitUsage},
+		{[]string{"--bogus", "chain", "info"}, exitUsage},
+		{[]string{"tx", "send", "--to", "bob"}, exitUsage},
+		{[]string{"tx", "status"}, exitUsage},
+		{[]string{"block", "get", "1", "2"}, exitUsage},
+		// Nothing listens on port 1
+		{[]string{"--node", "http://127.0.0.1:1", "chain", "info"}, exitFailed},
+	}
+	for _, tt := range tests {
+		if code, _, errOut := runCLI("", "", tt.args...); code != tt.want {
+			t.Errorf("%v exited %d, want %d: %s", tt.args, code, tt.want, errOut)
+		}
+	}
+}
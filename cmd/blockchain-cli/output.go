@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// field is a labeled value printed by details
+type field struct {
+	label string
+	value interface{}
+}
+
+// print writes v as indented JSON with --json, and calls human otherwise
+func (c *cli) print(v interface{}, human func()) error {
+	if !c.json {
+		human()
+		return nil
+	}
+	encoder := json.NewEncoder(c.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// table writes rows under a header, in aligned columns
+func (c *cli) table(header []string, rows [][]string) {
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+}
+
+// details writes one labeled value per line, aligned
+func (c *cli) details(fields ...field) {
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	for _, f := range fields {
+		fmt.Fprintf(w, "%s:\t%v\n", f.label, f.value)
+	}
+	w.Flush()
+}
+
+// shorten abbreviates long hashes and addresses for tables
+func shorten(s string) string {
+	if len(s) <= 16 {
+		return s
+	}
+	return s[:8] + "…" + s[len(s)-6:]
+}
+
+// orDash returns s, or "-" when it is empty
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
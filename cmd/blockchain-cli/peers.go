@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// peersList lists the node's peers
+func peersList(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("peers list")
+	if positional, err := parse(fs, args); err != nil {
+		return err
+	} else if len(positional) > 0 {
+		return usageError("unexpected argument " + positional[0])
+	}
+
+	peers, err := c.client().Peers(ctx)
+	if err != nil {
+		return err
+	}
+	return c.print(peers, func() {
+		rows := make([][]string, len(peers))
+		for i, peer := range peers {
+			rows[i] = []string{
+				peer.Address,
+				orDash(shorten(peer.NodeID)),
+				orDash(peer.Role),
+				strconv.Itoa(peer.Height),
+				orDash(peer.Version),
+				fmt.Sprintf("%.0fms", peer.LatencyMs),
+				strconv.FormatBool(peer.Seed),
+			}
+		}
+		c.table([]string{"ADDRESS", "NODE ID", "ROLE", "HEIGHT", "VERSION", "LATENCY", "SEED"}, rows)
+	})
+}
+
+// peersAdd has the node connect to a peer
+func peersAdd(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("peers add")
+	positional, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError("expected a peer address")
+	}
+
+	address := positional[0]
+	if err := c.client().AddPeer(ctx, address); err != nil {
+		return err
+	}
+	result := map[string]string{"address": address, "status": "connected"}
+	return c.print(result, func() {
+		fmt.Fprintf(c.out, "Connected to %s\n", address)
+	})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/anekazek/simple-blockchain/pkg/client"
+	"github.com/anekazek/simple-blockchain/pkg/wallet"
+)
+
+// txSend signs a transaction with a key file and submits it
+func txSend(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("tx send")
+	keyFile := fs.String("from-keyfile", "", "key file of the sender, who signs the transaction")
+	to := fs.String("to", "", "recipient address")
+	value := fs.Float64("value", 0, "amount to send")
+	fee := fs.Float64("fee", 0, "fee offered to the miner")
+	data := fs.String("data", "", "data attached to the transaction")
+	if positional, err := parse(fs, args); err != nil {
+		return err
+	} else if len(positional) > 0 {
+		return usageError("unexpected argument " + positional[0])
+	}
+	switch {
+	case *keyFile == "":
+		return usageError("--from-keyfile is required")
+	case *to == "":
+		return usageError("--to is required")
+	case *value < 0 || *fee < 0:
+		return usageError("--value and --fee must not be negative")
+	}
+
+	w, err := wallet.Load(*keyFile)
+	if err != nil {
+		return err
+	}
+	tx := client.TransactionRequest{To: *to, Value: *value, Fee: *fee, Data: *data}
+	tx.Sign(w, c.now())
+
+	id, err := c.client().SendTransaction(ctx, tx)
+	if err != nil {
+		return err
+	}
+	result := map[string]string{"id": id, "status": client.StatusPending}
+	return c.print(result, func() {
+		c.details(
+			field{"Transaction", id},
+			field{"From", tx.From},
+			field{"Status", client.StatusPending},
+		)
+	})
+}
+
+// txStatus shows whether a transaction is pending or mined
+func txStatus(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("tx status")
+	positional, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError("expected a transaction ID")
+	}
+
+	tx, err := c.client().Transaction(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	return c.print(tx, func() {
+		block := "-"
+		if tx.BlockIndex != nil {
+			block = strconv.Itoa(*tx.BlockIndex) + " (" + tx.BlockHash + ")"
+		}
+		c.details(
+			field{"Transaction", tx.ID},
+			field{"Status", tx.Status},
+			field{"From", orDash(tx.From)},
+			field{"To", orDash(tx.To)},
+			field{"Value", tx.Value},
+			field{"Fee", tx.Fee},
+			field{"Signed", tx.Signature != ""},
+			field{"Block", block},
+		)
+	})
+}
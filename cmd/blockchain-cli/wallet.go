@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+
+	"github.com/anekazek/simple-blockchain/pkg/wallet"
+)
+
+// walletInfo is the JSON output of the wallet commands
+type walletInfo struct {
+	Address   string `json:"address"`
+	PublicKey string `json:"publicKey"`
+	KeyFile   string `json:"keyFile"`
+}
+
+// walletNew creates a key pair and saves it to a new key file
+func walletNew(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("wallet new")
+	out := fs.String("out", "wallet.json", "key file to create")
+	if positional, err := parse(fs, args); err != nil {
+		return err
+	} else if len(positional) > 0 {
+		return usageError("unexpected argument " + positional[0])
+	}
+
+	w, err := wallet.Generate()
+	if err != nil {
+		return err
+	}
+	if err := w.Save(*out); err != nil {
+		return err
+	}
+	return c.printWallet(w, *out)
+}
+
+// walletShow shows the address and public key of a key file
+func walletShow(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("wallet show")
+	keyFile := fs.String("keyfile", "", "key file to show")
+	positional, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if *keyFile == "" && len(positional) == 1 {
+		*keyFile = positional[0]
+	} else if len(positional) > 0 {
+		return usageError("unexpected argument " + positional[0])
+	}
+	if *keyFile == "" {
+		return usageError("--keyfile is required")
+	}
+
+	w, err := wallet.Load(*keyFile)
+	if err != nil {
+		return err
+	}
+	return c.printWallet(w, *keyFile)
+}
+
+// printWallet writes a wallet's public details
+func (c *cli) printWallet(w *wallet.Wallet, keyFile string) error {
+	info := walletInfo{Address: w.Address(), PublicKey: hex.EncodeToString(w.PublicKey), KeyFile: keyFile}
+	return c.print(info, func() {
+		c.details(
+			field{"Address", info.Address},
+			field{"Public key", info.PublicKey},
+			field{"Key file", info.KeyFile},
+		)
+	})
+}
@@ -1,76 +1,62 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
 	"os"
-	"strconv"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/anekazek/simple-blockchain/pkg/api"
-	"github.com/anekazek/simple-blockchain/pkg/blockchain"
-	"github.com/anekazek/simple-blockchain/pkg/metrics"
+	"github.com/anekazek/simple-blockchain/pkg/config"
+	"github.com/anekazek/simple-blockchain/pkg/node"
 )
 
+// shutdownTimeout bounds the graceful shutdown of the node
+const shutdownTimeout = 10 * time.Second
+
 func main() {
-	// Set mining difficulty (can be made configurable via flags/env)
-	difficulty := 1
-	if os.Getenv("BLOCKCHAIN_DIFFICULTY") != "" {
-		val, err := strconv.Atoi(os.Getenv("BLOCKCHAIN_DIFFICULTY"))
-		if err == nil && val > 0 {
-			difficulty = val
-		}
+	cfg, err := config.Load(os.Args[0], os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
 	}
-
-	// Initialize blockchain with genesis block
-	chain := blockchain.NewBlockchain()
-
-	// Initialize transaction pool
-	txPoolSize := 1000
-	if os.Getenv("TX_POOL_SIZE") != "" {
-		val, err := strconv.Atoi(os.Getenv("TX_POOL_SIZE"))
-		if err == nil && val > 0 {
-			txPoolSize = val
-		}
+	if err != nil {
+		log.Fatal(err)
 	}
-	txPool := blockchain.NewTransactionPool(txPoolSize)
 
-	// Initialize metrics
-	blockchainMetrics := metrics.NewBlockchainMetrics()
-	metricsPort := "9090"
-	if os.Getenv("METRICS_PORT") != "" {
-		metricsPort = os.Getenv("METRICS_PORT")
+	n, err := node.New(cfg)
+	if err != nil {
+		log.Fatal(err)
 	}
-	blockchainMetrics.StartServer(metricsPort)
-
-	// Set initial node health to healthy
-	blockchainMetrics.SetNodeHealth(true)
 
-	// Get API ports
-	httpPort := "8080"
-	if os.Getenv("HTTP_PORT") != "" {
-		httpPort = os.Getenv("HTTP_PORT")
+	exitCode := 0
+	if err := n.Start(context.Background()); err != nil {
+		log.Printf("Failed to start node: %v\n", err)
+		exitCode = 1
+	} else {
+		log.Printf("Web dashboard available at http://localhost:%d\n", cfg.API.HTTPPort)
+
+		// Run until stopped or a server fails
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		select {
+		case <-signals:
+		case err := <-n.Err():
+			log.Printf("Node failed: %v\n", err)
+			exitCode = 1
+		}
 	}
 
-	wsPort := "8081"
-	if os.Getenv("WS_PORT") != "" {
-		wsPort = os.Getenv("WS_PORT")
+	// Store the chain, push metrics a last time and flush traces
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := n.Stop(ctx); err != nil {
+		log.Printf("Shutdown error: %v\n", err)
 	}
-
-	// Create enhanced server with WebSocket support
-	server := api.NewEnhancedBlockchainServer(chain, txPool, difficulty, blockchainMetrics)
-
-	// Configure TLS if certificates are provided
-	certFile := os.Getenv("TLS_CERT_FILE")
-	keyFile := os.Getenv("TLS_KEY_FILE")
-	if certFile != "" && keyFile != "" {
-		server.ConfigureTLS(certFile, keyFile)
-		log.Println("TLS enabled for API and WebSocket servers")
+	if exitCode != 0 {
+		cancel()
+		os.Exit(exitCode)
 	}
-
-	log.Printf("Starting blockchain with difficulty: %d\n", difficulty)
-	log.Printf("Transaction pool initialized with capacity: %d\n", txPoolSize)
-	log.Printf("Metrics server available at http://localhost:%s/metrics\n", metricsPort)
-	log.Printf("Web dashboard available at http://localhost:%s\n", httpPort)
-
-	// Start the enhanced server
-	log.Fatal(server.Start(httpPort, wsPort))
 }
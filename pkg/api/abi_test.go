@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/contracts"
+)
+
+// addModule is a WASM module exporting add (i32, i32) -> i32
+var addModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	0x01, 0x07, 0x01, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f,
+	0x03, 0x02, 0x01, 0x00,
+	0x07, 0x07, 0x01, 0x03, 'a', 'd', 'd', 0x00, 0x00,
+	0x0a, 0x09, 0x01, 0x07, 0x00, 0x20, 0x00, 0x20, 0x01, 0x6a, 0x0b,
+}
+
+// tokenABIContract declares the types of its functions in an abi table
+const tokenABIContract = `
+abi = {
+	transfer = {"string", "number"},
+	balance = {params = {"string"}, returns = {"number"}, readonly = true},
+}
+
+function transfer(to, amount) end
+function balance(owner) return 0 end
+`
+
+// getABI fetches a contract's ABI, returning the response status
+func getABI(t *testing.T, s *EnhancedBlockchainServer, id string) (int, contracts.ContractABI) {
+	t.Helper()
+	w := serve(s, http.MethodGet, "/api/contracts/"+id+"/abi", "")
+	var abi contracts.ContractABI
+	if w.Code == http.StatusOK {
+		if err := json.NewDecoder(w.Body).Decode(&abi); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w.Code, abi
+}
+
+func TestContractABIDescribesWASMAndLuaFunctions(t *testing.T) {
+	s := newTestServer(t)
+	body, _ := json.Marshal(map[string]string{"type": contracts.TypeWASM, "name": "add", "code": base64.StdEncoding.EncodeToString(addModule)})
+	w := serve(s, http.MethodPost, "/api/contracts", string(body))
+	var deployed struct {
+		ID string `json:"id"`
+	}
+	if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&deployed) != nil {
+		t.Fatalf("deploy got status %d: %s", w.Code, w.Body)
+	}
+	wasmID := deployed.ID
+	luaID := deployLua(t, s, tokenABIContract)
+
+	code, abi := getABI(t, s, wasmID)
+	want := []contracts.FunctionABI{{Name: "add", Params: []string{"i32", "i32"}, Results: []string{"i32"}}}
+	if code != http.StatusOK || abi.Type != contracts.TypeWASM || !reflect.DeepEqual(abi.Functions, want) {
+		t.Fatalf("wasm abi %d %+v", code, abi)
+	}
+
+	code, abi = getABI(t, s, luaID)
+	want = []contracts.FunctionABI{
+		{Name: "balance", Params: []string{"string"}, Results: []string{"number"}, ReadOnly: true},
+		{Name: "transfer", Params: []string{"string", "number"}},
+	}
+	if code != http.StatusOK || abi.Type != contracts.TypeLua || !reflect.DeepEqual(abi.Functions, want) {
+		t.Fatalf("lua abi %d %+v", code, abi)
+	}
+
+	var listing struct {
+		Contracts []contractListing `json:"contracts"`
+	}
+	if err := json.NewDecoder(serve(s, http.MethodGet, "/api/contracts", "").Body).Decode(&listing); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	for _, contract := range listing.Contracts {
+		counts[contract.ID] = contract.Functions
+	}
+	if counts[wasmID] != 1 || counts[luaID] != 2 {
+		t.Fatalf("listing counted functions %v", counts)
+	}
+}
+
+func TestContractABIOfUnknownContract(t *testing.T) {
+	s := newTestServer(t)
+	if code, _ := getABI(t, s, "missing"); code != http.StatusNotFound {
+		t.Fatalf("unknown contract answered %d, want 404", code)
+	}
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// accountBalance is the balance endpoint's reply. Pending is the value of
+// the address's pending transactions, which Available leaves out.
+type accountBalance struct {
+	Address   string  `json:"address"`
+	Balance   float64 `json:"balance"`
+	Pending   float64 `json:"pending"`
+	Available float64 `json:"available"`
+}
+
+// handleGetBalance returns the balance of an address as of the tip
+func (s *EnhancedBlockchainServer) handleGetBalance(w http.ResponseWriter, r *http.Request) {
+	address := mux.Vars(r)["address"]
+	balance, pending := s.chain.Balance(address), s.txPool.PendingSpend(address)
+	negotiatedResponse(w, r, accountBalance{
+		Address:   address,
+		Balance:   balance,
+		Pending:   pending,
+		Available: balance - pending,
+	})
+}
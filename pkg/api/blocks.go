@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// handleGetBlocks returns the blocks from index from, up to limit of them
+// or to the tip. JSON responses are streamed a block at a time, so a long
+// chain is never encoded in one piece; other formats are encoded whole. The
+// handler stops when the client goes away, and since the status is already
+// sent, a stream cut short by a chain replacement is aborted rather than
+// ended as if complete.
+func (s *EnhancedBlockchainServer) handleGetBlocks(w http.ResponseWriter, r *http.Request) {
+	from, limit, err := blockRange(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c := responseCodec(r)
+	if _, ok := c.(jsonCodec); !ok {
+		if limit == 0 {
+			limit = math.MaxInt
+		}
+		negotiatedResponse(w, r, map[string]interface{}{"blocks": s.chain.BlocksFrom(from, limit)})
+		return
+	}
+
+	to := math.MaxInt
+	if limit > 0 && limit <= math.MaxInt-from {
+		to = from + limit - 1
+	}
+
+	w.Header().Set("Content-Type", c.ContentType())
+	w.Header().Add("Vary", "Accept")
+	io.WriteString(w, `{"blocks":[`)
+	enc := json.NewEncoder(w)
+	var sent int
+	var writeErr error
+	err = s.chain.Iterate(from, to, func(block blockchain.Block) bool {
+		if writeErr = r.Context().Err(); writeErr != nil {
+			return false
+		}
+		if sent > 0 {
+			io.WriteString(w, ",")
+		}
+		sent++
+		writeErr = enc.Encode(block)
+		return writeErr == nil
+	})
+	if err != nil || writeErr != nil {
+		panic(http.ErrAbortHandler)
+	}
+	io.WriteString(w, "]}\n")
+}
+
+// blockRange reads the optional from and limit query parameters of a block
+// listing. A limit of 0 means no limit.
+func blockRange(r *http.Request) (from, limit int, err error) {
+	query := r.URL.Query()
+	if param := query.Get("from"); param != "" {
+		if from, err = strconv.Atoi(param); err != nil || from < 0 {
+			return 0, 0, errors.New("invalid from")
+		}
+	}
+	if param := query.Get("limit"); param != "" {
+		if limit, err = strconv.Atoi(param); err != nil || limit < 0 {
+			return 0, 0, errors.New("invalid limit")
+		}
+	}
+	return from, limit, nil
+}
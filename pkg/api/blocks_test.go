@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// streamRecorder records a response and the number of writes that made it
+// up, calling onWrite after each one
+type streamRecorder struct {
+	*httptest.ResponseRecorder
+	writes  int
+	onWrite func(writes int)
+}
+
+func (w *streamRecorder) Write(p []byte) (int, error) {
+	n, err := w.ResponseRecorder.Write(p)
+	w.writes++
+	if w.onWrite != nil {
+		w.onWrite(w.writes)
+	}
+	return n, err
+}
+
+// getBlocks requests path from the server's handler, reporting whether the
+// handler aborted the response
+func getBlocks(s *EnhancedBlockchainServer, req *http.Request, w *streamRecorder) (aborted bool) {
+	defer func() {
+		if p := recover(); p != nil {
+			if p != http.ErrAbortHandler {
+				panic(p)
+			}
+			aborted = true
+		}
+	}()
+	s.Handler().ServeHTTP(w, req)
+	return false
+}
+
+// newLongServer returns a test server whose chain is longer than one
+// iteration batch
+func newLongServer(t *testing.T) *EnhancedBlockchainServer {
+	t.Helper()
+	s := newTestServer(t)
+	for i := 0; i < blockchain.IterateBatchSize+20; i++ {
+		if _, err := s.chain.AddBlock(nil, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestGetBlocksStreamsJSON(t *testing.T) {
+	s := newLongServer(t)
+	blocks := s.chain.GetBlocks()
+
+	for name, c := range map[string]struct {
+		query       string
+		first, want int
+	}{
+		"the whole chain": {"", 0, len(blocks)},
+		"a page":          {"?from=5&limit=3", 5, 3},
+		"up to the tip":   {"?from=250", 250, len(blocks) - 250},
+		"past the tip":    {"?from=100000", 0, 0},
+	} {
+		w := &streamRecorder{ResponseRecorder: httptest.NewRecorder()}
+		if getBlocks(s, httptest.NewRequest(http.MethodGet, "/api/blocks"+c.query, nil), w) {
+			t.Fatalf("%s: response aborted", name)
+		}
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got %d: %s", name, w.Code, w.Body)
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("%s: content type %q", name, ct)
+		}
+		// A write per block besides the opening and closing brackets
+		if c.want > 0 && w.writes < c.want+2 {
+			t.Errorf("%s: %d blocks in %d writes, want them streamed", name, c.want, w.writes)
+		}
+
+		var response struct {
+			Blocks []blockchain.Block `json:"blocks"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(response.Blocks) != c.want {
+			t.Fatalf("%s: got %d blocks, want %d", name, len(response.Blocks), c.want)
+		}
+		for i, block := range response.Blocks {
+			if block.Hash != blocks[c.first+i].Hash {
+				t.Fatalf("%s: block %d is %s, want %s", name, i, block.Hash, blocks[c.first+i].Hash)
+			}
+		}
+	}
+
+	for _, query := range []string{"from=-1", "limit=x"} {
+		if w := serve(s, http.MethodGet, "/api/blocks?"+query, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s got %d, want 400", query, w.Code)
+		}
+	}
+}
+
+func TestGetBlocksAbortsMidStream(t *testing.T) {
+	t.Run("chain rolled back", func(t *testing.T) {
+		s := newLongServer(t)
+		w := &streamRecorder{ResponseRecorder: httptest.NewRecorder()}
+		w.onWrite = func(writes int) {
+			if writes == 10 {
+				if _, err := s.chain.RollbackToHeight(5, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		if !getBlocks(s, httptest.NewRequest(http.MethodGet, "/api/blocks", nil), w) {
+			t.Fatal("stream over a replaced chain wasn't aborted")
+		}
+		if strings.HasSuffix(w.Body.String(), "]}\n") {
+			t.Error("aborted stream was ended as if complete")
+		}
+	})
+
+	t.Run("client gone", func(t *testing.T) {
+		s := newLongServer(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		w := &streamRecorder{ResponseRecorder: httptest.NewRecorder()}
+		w.onWrite = func(writes int) {
+			if writes == 10 {
+				cancel()
+			}
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/api/blocks", nil).WithContext(ctx)
+		if !getBlocks(s, req, w) {
+			t.Fatal("stream to a departed client wasn't aborted")
+		}
+		if w.writes > 12 {
+			t.Errorf("kept writing %d times after the client left", w.writes-10)
+		}
+	})
+}
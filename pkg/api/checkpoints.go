@@ -0,0 +1,9 @@
+package api
+
+import "net/http"
+
+// handleGetCheckpoints returns the block hashes pinned on the chain, lowest
+// height first
+func (s *EnhancedBlockchainServer) handleGetCheckpoints(w http.ResponseWriter, r *http.Request) {
+	negotiatedResponse(w, r, map[string]interface{}{"checkpoints": s.chain.Checkpoints()})
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+func TestGetCheckpoints(t *testing.T) {
+	s := newTestServer(t)
+	genesis := s.chain.Genesis()
+	if err := s.chain.AddCheckpoint(0, genesis.Hash); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(s, http.MethodGet, "/api/blockchain/checkpoints", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		Checkpoints []blockchain.Checkpoint `json:"checkpoints"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Checkpoints) != 1 || body.Checkpoints[0] != (blockchain.Checkpoint{Height: 0, Hash: genesis.Hash}) {
+		t.Fatalf("got %+v", body.Checkpoints)
+	}
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Supported media types for content negotiation
+const (
+	mediaTypeJSON    = "application/json"
+	mediaTypeMsgpack = "application/msgpack"
+	mediaTypeCBOR    = "application/cbor"
+)
+
+// codec encodes and decodes API payloads in a single wire format
+type codec interface {
+	// ContentType returns the media type written in responses
+	ContentType() string
+
+	// Encode writes v to w
+	Encode(w io.Writer, v interface{}) error
+
+	// Decode reads r into v
+	Decode(r io.Reader, v interface{}) error
+}
+
+// jsonCodec is the default codec
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string { return mediaTypeJSON }
+
+func (jsonCodec) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// msgpackCodec uses the json struct tags so field names match the JSON API
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string { return mediaTypeMsgpack }
+
+func (msgpackCodec) Encode(w io.Writer, v interface{}) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+func (msgpackCodec) Decode(r io.Reader, v interface{}) error {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// cborCodec falls back to json struct tags when no cbor tag is present
+type cborCodec struct{}
+
+func (cborCodec) ContentType() string { return mediaTypeCBOR }
+
+func (cborCodec) Encode(w io.Writer, v interface{}) error {
+	return cbor.NewEncoder(w).Encode(v)
+}
+
+func (cborCodec) Decode(r io.Reader, v interface{}) error {
+	return cbor.NewDecoder(r).Decode(v)
+}
+
+// codecs maps media types to their codec
+var codecs = map[string]codec{
+	mediaTypeJSON:           jsonCodec{},
+	mediaTypeMsgpack:        msgpackCodec{},
+	"application/x-msgpack": msgpackCodec{},
+	mediaTypeCBOR:           cborCodec{},
+}
+
+// responseCodec picks a codec from the Accept header, falling back to JSON
+func responseCodec(r *http.Request) codec {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if c, ok := codecs[mediaType]; ok {
+			return c
+		}
+	}
+	return jsonCodec{}
+}
+
+// requestCodec picks a codec from the Content-Type header, falling back to JSON
+func requestCodec(r *http.Request) codec {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return jsonCodec{}
+	}
+	if c, ok := codecs[mediaType]; ok {
+		return c
+	}
+	return jsonCodec{}
+}
+
+// negotiatedResponse encodes data with the codec the client asked for
+func negotiatedResponse(w http.ResponseWriter, r *http.Request, data interface{}) {
+	c := responseCodec(r)
+	w.Header().Set("Content-Type", c.ContentType())
+	w.Header().Add("Vary", "Accept")
+	c.Encode(w, data)
+}
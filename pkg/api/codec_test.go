@@ -0,0 +1,101 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// serveEncoded sends a request with the given Content-Type and Accept headers
+func serveEncoded(s *EnhancedBlockchainServer, method, path, contentType, accept string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", accept)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func TestCodecsRoundTripTransactionsAndBlocks(t *testing.T) {
+	for _, c := range []codec{jsonCodec{}, msgpackCodec{}, cborCodec{}} {
+		t.Run(c.ContentType(), func(t *testing.T) {
+			s := newTestServer(t)
+
+			var body bytes.Buffer
+			if err := c.Encode(&body, transactionRequest{To: "bob", Data: "hello"}); err != nil {
+				t.Fatal(err)
+			}
+			w := serveEncoded(s, http.MethodPost, "/api/transactions", c.ContentType(), c.ContentType(), body.Bytes())
+			if w.Code != http.StatusOK || w.Header().Get("Content-Type") != c.ContentType() {
+				t.Fatalf("submit answered %d as %q: %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+			}
+			var submitted map[string]string
+			if err := c.Decode(w.Body, &submitted); err != nil {
+				t.Fatal(err)
+			}
+			tx, err := s.txPool.GetTransaction(submitted["id"])
+			if err != nil || tx.To != "bob" || tx.Data != "hello" {
+				t.Fatalf("pool holds %+v (%v), want the submitted transaction", tx, err)
+			}
+
+			if _, err := s.MineBlock(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			w = serveEncoded(s, http.MethodGet, "/api/blockchain", "", c.ContentType(), nil)
+			if w.Header().Get("Content-Type") != c.ContentType() {
+				t.Fatalf("blocks sent as %q", w.Header().Get("Content-Type"))
+			}
+			var listing struct {
+				Blocks []blockchain.Block `json:"blocks"`
+			}
+			if err := c.Decode(w.Body, &listing); err != nil {
+				t.Fatal(err)
+			}
+			want := s.chain.GetBlocks()
+			if len(listing.Blocks) != len(want) {
+				t.Fatalf("got %d blocks, want %d", len(listing.Blocks), len(want))
+			}
+			for i, block := range listing.Blocks {
+				if block.Hash != want[i].Hash || blockchain.CalculateHash(block) != block.Hash {
+					t.Fatalf("block %d didn't round-trip: %+v", i, block)
+				}
+			}
+			mined := listing.Blocks[1].Transactions
+			if len(mined) != 1 || mined[0].ID != tx.ID || mined[0].Data != "hello" {
+				t.Fatalf("mined block holds %+v", mined)
+			}
+		})
+	}
+}
+
+func TestUnsupportedAcceptFallsBackToJSON(t *testing.T) {
+	s := newTestServer(t)
+	for _, accept := range []string{"", "text/html", "application/xml;q=0.9, */*", "not a media type"} {
+		w := serveEncoded(s, http.MethodGet, "/api/blockchain", "", accept, nil)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != mediaTypeJSON {
+			t.Errorf("Accept %q: got %d as %q", accept, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestResponseCodecHonorsFirstSupportedType(t *testing.T) {
+	tests := map[string]string{
+		"text/html, application/cbor":                mediaTypeCBOR,
+		"application/x-msgpack":                      mediaTypeMsgpack,
+		"application/msgpack; q=1, application/json": mediaTypeMsgpack,
+		"application/xml":                            mediaTypeJSON,
+	}
+	for accept, want := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", accept)
+		if got := responseCodec(req).ContentType(); got != want {
+			t.Errorf("Accept %q picked %s, want %s", accept, got, want)
+		}
+	}
+}
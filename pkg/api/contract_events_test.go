@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/contracts"
+)
+
+// eventsContract emits two events from transfer and one per call from spam
+const eventsContract = `
+function transfer(to, amount)
+	emit("Transfer", {to = to, amount = amount})
+	emit("Done", {})
+	return amount
+end
+
+function spam()
+	for i = 1, 100 do
+		emit("Spam", {i = i})
+	end
+end
+`
+
+// deployLua deploys a Lua contract through the API and returns its ID
+func deployLua(t *testing.T, s *EnhancedBlockchainServer, code string) string {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"type": contracts.TypeLua, "name": "events", "code": code})
+	w := serve(s, http.MethodPost, "/api/contracts", string(body))
+	var deployed struct {
+		ID string `json:"id"`
+	}
+	if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&deployed) != nil {
+		t.Fatalf("deploy got status %d: %s", w.Code, w.Body)
+	}
+	return deployed.ID
+}
+
+func TestContractEventsReachResponseReceiptAndSubscribers(t *testing.T) {
+	s := newTestServer(t)
+	id := deployLua(t, s, eventsContract)
+	subscribed, other := dialWebSocket(t, s), dialWebSocket(t, s)
+	request(t, subscribed, map[string]interface{}{"requestId": "sub", "action": actionSubscribe, "topic": contractTopic(id)})
+	request(t, other, map[string]interface{}{"requestId": "sub", "action": actionSubscribe, "topic": contractTopic("another")})
+
+	w := serve(s, http.MethodPost, "/api/contracts/"+id+"/execute", `{"function": "transfer", "params": ["bob", 5]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("execute got status %d: %s", w.Code, w.Body)
+	}
+	var response struct {
+		Events  []contracts.ContractEvent `json:"events"`
+		Receipt string                    `json:"receipt"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Events) != 2 || response.Events[0].Name != "Transfer" || response.Events[1].Name != "Done" {
+		t.Fatalf("response carried events %+v", response.Events)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(response.Events[0].Data, &payload); err != nil || payload["to"] != "bob" || payload["amount"] != 5.0 {
+		t.Fatalf("Transfer payload %s", response.Events[0].Data)
+	}
+
+	var receipt contracts.Receipt
+	w = serve(s, http.MethodGet, "/api/receipts/"+response.Receipt, "")
+	if err := json.NewDecoder(w.Body).Decode(&receipt); err != nil {
+		t.Fatal(err)
+	}
+	if len(receipt.Events) != 2 || receipt.Events[0].ContractID != id {
+		t.Fatalf("receipt holds events %+v", receipt.Events)
+	}
+
+	for _, name := range []string{"Transfer", "Done"} {
+		message := readMessage(t, subscribed, "contract_event")
+		event, _ := message["event"].(map[string]interface{})
+		if event["name"] != name || message["receipt"] != response.Receipt {
+			t.Fatalf("got %v, want the %s event", message, name)
+		}
+	}
+
+	// The other client only follows another contract
+	other.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	for {
+		var message map[string]interface{}
+		if err := other.ReadJSON(&message); err != nil {
+			break
+		}
+		if message["type"] == "contract_event" {
+			t.Fatalf("unsubscribed client got %v", message)
+		}
+	}
+}
+
+func TestContractEventsAreCappedPerExecution(t *testing.T) {
+	s := newTestServer(t)
+	id := deployLua(t, s, eventsContract)
+
+	w := serve(s, http.MethodPost, "/api/contracts/"+id+"/execute", `{"function": "spam"}`)
+	if w.Code == http.StatusOK {
+		t.Fatal("emitting 100 events succeeded")
+	}
+	if body := w.Body.String(); !strings.Contains(body, contracts.ErrTooManyEvents.Error()) {
+		t.Fatalf("error doesn't mention the event limit: %s", body)
+	}
+}
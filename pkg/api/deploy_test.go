@@ -0,0 +1,32 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/contracts"
+)
+
+func TestDeployRejectsOversizedAndInvalidCode(t *testing.T) {
+	s := newTestServer(t)
+	tests := []struct {
+		name, code string
+		status     int
+		errorCode  string
+	}{
+		{"oversized", strings.Repeat("-- padding\n", contracts.DefaultMaxLuaCodeBytes/10), http.StatusRequestEntityTooLarge, "code_too_large"},
+		{"forbidden global", "function now() return os.time() end", http.StatusUnprocessableEntity, "invalid_contract"},
+	}
+	for _, tt := range tests {
+		body, _ := json.Marshal(map[string]string{"type": contracts.TypeLua, "name": tt.name, "code": tt.code})
+		w := serve(s, http.MethodPost, "/api/contracts", string(body))
+		if w.Code != tt.status || decodeError(t, w).Code != tt.errorCode {
+			t.Errorf("%s: got status %d, want %d with %s", tt.name, w.Code, tt.status, tt.errorCode)
+		}
+	}
+	if n := len(s.registry.ListContracts()); n != 0 {
+		t.Fatalf("%d rejected contracts were deployed", n)
+	}
+}
@@ -1,19 +1,30 @@
 package api
 
 import (
-	"crypto/tls"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/anekazek/simple-blockchain/pkg/blockchain"
 	"github.com/anekazek/simple-blockchain/pkg/contracts"
 	"github.com/anekazek/simple-blockchain/pkg/metrics"
+	"github.com/anekazek/simple-blockchain/pkg/network"
+	"github.com/anekazek/simple-blockchain/pkg/node/lifecycle"
+	"github.com/anekazek/simple-blockchain/pkg/version"
+	"github.com/anekazek/simple-blockchain/pkg/wallet"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // EnhancedBlockchainServer provides a full-featured API with WebSocket support and TLS
@@ -21,36 +32,112 @@ type EnhancedBlockchainServer struct {
 	chain        *blockchain.Chain
 	txPool       *blockchain.TransactionPool
 	difficulty   int
-	wasmEngine   *contracts.WASMEngine
-	luaEngine    *contracts.LuaEngine
+	registry     *contracts.ContractRegistry
+	state        *contracts.MemoryStateStore // Storage shared by the contracts
+	receipts     *contracts.ReceiptStore
 	metrics      *metrics.BlockchainMetrics
-	clients      map[*websocket.Conn]bool
+	clients      map[*websocket.Conn]map[string]bool // Topics each client subscribed to
 	broadcast    chan interface{}
 	clientsMutex sync.Mutex
+	wsMetrics    WebSocketMetrics
+	httpMetrics  HTTPMetrics
+	mountMetrics bool // Serve /metrics and /healthz from the API router
 	upgrader     websocket.Upgrader
 	tlsCertFile  string
 	tlsKeyFile   string
 	enableTLS    bool
+	nodeState    *lifecycle.StateMachine
+	timeouts     TimeoutConfig
+	peers        PeerNetwork
+	adminToken   string // Bearer token allowed to manage every contract
+	nodeID       string
+	faucet       *faucet // Set in dev mode only
+	listening    *listeners
+	listenMutex  sync.Mutex
 }
 
+// PeerNetwork is the part of the P2P layer used by the API server
+type PeerNetwork interface {
+	// BroadcastBlock sends a locally produced block to all peers
+	BroadcastBlock(block blockchain.Block)
+
+	// BroadcastTransaction sends a locally submitted transaction to all peers
+	BroadcastTransaction(tx *blockchain.Transaction)
+
+	// PeerCount returns the number of known peers
+	PeerCount() int
+}
+
+// syncReporter is implemented by peer networks that track sync progress
+type syncReporter interface {
+	SyncStatus() network.SyncStatus
+	OnSyncProgress(fn func(network.SyncStatus))
+}
+
+// peerLister is implemented by peer networks that can describe their peers
+type peerLister interface {
+	Peers() []network.PeerInfo
+}
+
+// peerConnector is implemented by peer networks that can be told about a
+// peer to connect to
+type peerConnector interface {
+	ConnectPeer(address string) error
+}
+
+// transactionVerifier is implemented by peer networks that can prove a
+// transaction was mined
+type transactionVerifier interface {
+	VerifyTransaction(txID string) (network.TransactionProof, error)
+}
+
+// notReadyRetryAfter is the Retry-After hint, in seconds, sent while the node is not ready
+const notReadyRetryAfter = "5"
+
 // NewEnhancedBlockchainServer creates a new enhanced server
 func NewEnhancedBlockchainServer(chain *blockchain.Chain, txPool *blockchain.TransactionPool, difficulty int, metrics *metrics.BlockchainMetrics) *EnhancedBlockchainServer {
-	return &EnhancedBlockchainServer{
+	// Both engines share contract storage and can call each other's contracts
+	wasmEngine, luaEngine := contracts.NewWASMEngine(), contracts.NewLuaEngine()
+	state := contracts.NewMemoryStateStore()
+	wasmEngine.SetStateStore(state)
+	luaEngine.SetStateStore(state)
+	contracts.NewDispatcher(wasmEngine, luaEngine)
+	registry := contracts.NewContractRegistry(wasmEngine, luaEngine)
+	if metrics != nil {
+		registry.SetQueueObserver(metrics.ContractQueueDepth)
+		metrics.ObserveChain(chain, txPool)
+	}
+
+	s := &EnhancedBlockchainServer{
 		chain:      chain,
 		txPool:     txPool,
 		difficulty: difficulty,
-		wasmEngine: contracts.NewWASMEngine(),
-		luaEngine:  contracts.NewLuaEngine(),
+		registry:   registry,
+		state:      state,
+		receipts:   contracts.NewReceiptStore(contracts.DefaultReceiptCapacity),
 		metrics:    metrics,
-		clients:    make(map[*websocket.Conn]bool),
+		clients:    make(map[*websocket.Conn]map[string]bool),
 		broadcast:  make(chan interface{}, 100),
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true // Allow all origins for development
 			},
 		},
-		enableTLS: false,
+		enableTLS:   false,
+		timeouts:    DefaultTimeoutConfig(),
+		wsMetrics:   noWebSocketMetrics{},
+		httpMetrics: noHTTPMetrics{},
 	}
+	if metrics != nil {
+		s.wsMetrics, s.httpMetrics = metrics, metrics
+	}
+	chain.Subscribe(s.announceChainEvent)
+	return s
+}
+
+// SetNodeID sets the node ID reported by /api/version
+func (s *EnhancedBlockchainServer) SetNodeID(nodeID string) {
+	s.nodeID = nodeID
 }
 
 // ConfigureTLS sets up TLS for secure connections
@@ -60,79 +147,138 @@ func (s *EnhancedBlockchainServer) ConfigureTLS(certFile, keyFile string) {
 	s.enableTLS = true
 }
 
-// Start initializes the HTTP server with all routes
+// ConfigureTimeouts sets the handler deadlines for each route group
+func (s *EnhancedBlockchainServer) ConfigureTimeouts(timeouts TimeoutConfig) {
+	s.timeouts = timeouts
+}
+
+// SetPeerNetwork connects the server to the P2P layer so mined blocks are
+// broadcast to peers and the peer count is reported in stats
+func (s *EnhancedBlockchainServer) SetPeerNetwork(peers PeerNetwork) {
+	s.peers = peers
+	if reporter, ok := peers.(syncReporter); ok {
+		reporter.OnSyncProgress(s.broadcastSyncProgress)
+	}
+}
+
+// syncStatus returns the peer network's sync progress, if it reports one
+func (s *EnhancedBlockchainServer) syncStatus() (network.SyncStatus, bool) {
+	reporter, ok := s.peers.(syncReporter)
+	if !ok {
+		return network.SyncStatus{}, false
+	}
+	return reporter.SyncStatus(), true
+}
+
+// SetNodeState attaches the node state machine used to gate API traffic until the
+// node is ready. State transitions are pushed to WebSocket clients.
+func (s *EnhancedBlockchainServer) SetNodeState(state *lifecycle.StateMachine) {
+	s.nodeState = state
+	state.Subscribe(s.broadcastNodeState)
+}
+
+// LoadContracts reloads the contracts saved in store into both engines and
+// persists later deploys and removals to it
+func (s *EnhancedBlockchainServer) LoadContracts(store contracts.ContractStore) error {
+	return s.registry.LoadFrom(store)
+}
+
+// ContractState returns a contract's storage as of now, which must not be
+// modified
+func (s *EnhancedBlockchainServer) ContractState(id string) map[string][]byte {
+	return s.state.Snapshot(id)
+}
+
+// RestoreContractState writes values into a contract's storage, such as
+// the state of a contract installed from a snapshot
+func (s *EnhancedBlockchainServer) RestoreContractState(id string, values map[string][]byte) {
+	s.state.SetAll(id, values)
+}
+
+// Start serves the API and WebSocket servers on their ports, returning
+// when either fails or both are shut down
 func (s *EnhancedBlockchainServer) Start(httpPort, wsPort string) error {
-	// Start WebSocket server in a separate goroutine
-	go s.startWebSocketServer(wsPort)
+	if err := s.Listen(httpPort, wsPort); err != nil {
+		return err
+	}
+	return <-s.Err()
+}
 
-	// Start broadcasting service
-	go s.handleBroadcasts()
+// MountMetrics serves the metrics routes, /metrics and /healthz, from the
+// API router instead of a separate port. It must be called before Start.
+func (s *EnhancedBlockchainServer) MountMetrics() {
+	s.mountMetrics = true
+}
 
+// Handler returns the HTTP handler serving the API and the dashboard, with
+// every request recorded in the HTTP metrics
+func (s *EnhancedBlockchainServer) Handler() http.Handler {
 	// Create router with all API endpoints
 	r := mux.NewRouter()
 
-	// Blockchain endpoints
-	r.HandleFunc("/api/blockchain", s.handleGetBlockchain).Methods("GET")
-	r.HandleFunc("/api/blocks", s.handleGetBlocks).Methods("GET")
-	r.HandleFunc("/api/blocks/{hash}", s.handleGetBlock).Methods("GET")
+	// Readiness is always served, regardless of node state
+	r.HandleFunc("/api/ready", withTimeout(s.timeouts.Read, s.handleReady)).Methods("GET")
+	r.HandleFunc("/api/stats", withTimeout(s.timeouts.Read, s.handleGetStats)).Methods("GET")
+	r.HandleFunc("/api/version", withTimeout(s.timeouts.Read, s.handleGetVersion)).Methods("GET")
 
-	// Transaction endpoints
-	r.HandleFunc("/api/transactions", s.handleCreateTransaction).Methods("POST")
-	r.HandleFunc("/api/transactions", s.handleGetTransactions).Methods("GET")
-	r.HandleFunc("/api/transactions/{id}", s.handleGetTransaction).Methods("GET")
-	r.HandleFunc("/api/transactions/pending", s.handleGetPendingTransactions).Methods("GET")
+	api := r.PathPrefix("/api").Subrouter()
+	api.Use(s.readinessMiddleware)
 
-	// Smart contract endpoints
-	r.HandleFunc("/api/contracts", s.handleDeployContract).Methods("POST")
-	r.HandleFunc("/api/contracts", s.handleGetContracts).Methods("GET")
-	r.HandleFunc("/api/contracts/{id}", s.handleGetContract).Methods("GET")
-	r.HandleFunc("/api/contracts/{id}/execute", s.handleExecuteContract).Methods("POST")
+	// Blockchain endpoints
+	api.HandleFunc("/blockchain", withTimeout(s.timeouts.Read, s.handleGetBlockchain)).Methods("GET")
+	api.HandleFunc("/blockchain/validate", withTimeout(s.timeouts.Execute, s.handleValidateChain)).Methods("GET")
+	api.HandleFunc("/blockchain/checkpoints", withTimeout(s.timeouts.Read, s.handleGetCheckpoints)).Methods("GET")
+	api.HandleFunc("/blockchain/reorgs", withTimeout(s.timeouts.Read, s.handleGetReorgs)).Methods("GET")
+	// Streamed, so it isn't buffered by withTimeout
+	api.HandleFunc("/blocks", s.handleGetBlocks).Methods("GET")
+	api.HandleFunc("/blocks/{hash}", withTimeout(s.timeouts.Read, s.handleGetBlock)).Methods("GET")
+	api.HandleFunc("/headers", withTimeout(s.timeouts.Read, s.handleGetHeaders)).Methods("GET")
+	api.HandleFunc("/mine", withTimeout(s.timeouts.Execute, s.handleMineBlock)).Methods("POST")
+
+	// Peer endpoints
+	api.HandleFunc("/peers", withTimeout(s.timeouts.Read, s.handleGetPeers)).Methods("GET")
+	api.HandleFunc("/peers", withTimeout(s.timeouts.Write, s.handleAddPeer)).Methods("POST")
 
-	// Serve static files for the dashboard
-	r.PathPrefix("/").Handler(http.FileServer(http.Dir("./web")))
-
-	// Start HTTP server
-	log.Printf("API server listening on port %s\n", httpPort)
-
-	if s.enableTLS {
-		// Configure TLS
-		tlsConfig := &tls.Config{
-			MinVersion: tls.VersionTLS12,
-			CipherSuites: []uint16{
-				tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-				tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-				tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
-				tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
-			},
-		}
+	// Transaction endpoints
+	api.HandleFunc("/transactions", withTimeout(s.timeouts.Write, s.handleCreateTransaction)).Methods("POST")
+	api.HandleFunc("/transactions", withTimeout(s.timeouts.Read, s.handleGetTransactions)).Methods("GET")
+	api.HandleFunc("/transactions/{id}", withTimeout(s.timeouts.Read, s.handleGetTransaction)).Methods("GET")
+	api.HandleFunc("/transactions/pending", withTimeout(s.timeouts.Read, s.handleGetPendingTransactions)).Methods("GET")
+	api.HandleFunc("/transactions/{id}/proof", withTimeout(s.timeouts.Read, s.handleVerifyTransaction)).Methods("GET")
 
-		server := &http.Server{
-			Addr:      ":" + httpPort,
-			Handler:   r,
-			TLSConfig: tlsConfig,
-		}
+	// Address endpoints
+	api.HandleFunc("/addresses/{address}/balance", withTimeout(s.timeouts.Read, s.handleGetBalance)).Methods("GET")
 
-		return server.ListenAndServeTLS(s.tlsCertFile, s.tlsKeyFile)
-	} else {
-		return http.ListenAndServe(":"+httpPort, r)
+	// Smart contract endpoints
+	api.HandleFunc("/contracts", withTimeout(s.timeouts.Write, s.handleDeployContract)).Methods("POST")
+	api.HandleFunc("/contracts", withTimeout(s.timeouts.Read, s.handleGetContracts)).Methods("GET")
+	api.HandleFunc("/contracts/templates/token", withTimeout(s.timeouts.Write, s.handleDeployToken)).Methods("POST")
+	api.HandleFunc("/contracts/{id}", withTimeout(s.timeouts.Read, s.handleGetContract)).Methods("GET")
+	api.HandleFunc("/contracts/{id}", withTimeout(s.timeouts.Write, s.handleRemoveContract)).Methods("DELETE")
+	api.HandleFunc("/contracts/{id}/pause", withTimeout(s.timeouts.Write, s.handlePauseContract)).Methods("POST")
+	api.HandleFunc("/contracts/{id}/resume", withTimeout(s.timeouts.Write, s.handleResumeContract)).Methods("POST")
+	api.HandleFunc("/contracts/{id}/transfer-ownership", withTimeout(s.timeouts.Write, s.handleTransferOwnership)).Methods("POST")
+	api.HandleFunc("/contracts/{id}/abi", withTimeout(s.timeouts.Read, s.handleGetContractABI)).Methods("GET")
+	api.HandleFunc("/contracts/{id}/execute", withTimeout(s.timeouts.Execute, s.handleExecuteContract)).Methods("POST")
+	api.HandleFunc("/contracts/{id}/simulate", withTimeout(s.timeouts.Execute, s.handleSimulateContract)).Methods("POST")
+	api.HandleFunc("/receipts/{id}", withTimeout(s.timeouts.Read, s.handleGetReceipt)).Methods("GET")
+
+	// Admin endpoints
+	api.HandleFunc("/admin/rollback", withTimeout(s.timeouts.Write, s.handleRollback)).Methods("POST")
+
+	// Development faucet
+	if s.faucet != nil {
+		api.HandleFunc("/faucet", withTimeout(s.timeouts.Write, s.handleFaucet)).Methods("POST")
 	}
-}
 
-// startWebSocketServer initializes the WebSocket server
-func (s *EnhancedBlockchainServer) startWebSocketServer(port string) {
-	http.HandleFunc("/ws", s.handleWebSocketConnection)
+	if s.mountMetrics && s.metrics != nil {
+		s.metrics.MountOn(r)
+	}
 
-	log.Printf("WebSocket server listening on port %s\n", port)
+	// Serve static files for the dashboard
+	r.PathPrefix("/").Handler(http.FileServer(http.Dir("./web"))).Name(routeStatic)
 
-	if s.enableTLS {
-		if err := http.ListenAndServeTLS(":"+port, s.tlsCertFile, s.tlsKeyFile, nil); err != nil {
-			log.Printf("WebSocket server error: %v\n", err)
-		}
-	} else {
-		if err := http.ListenAndServe(":"+port, nil); err != nil {
-			log.Printf("WebSocket server error: %v\n", err)
-		}
-	}
+	return s.instrumentRouter(r)
 }
 
 // handleWebSocketConnection manages WebSocket client connections
@@ -145,7 +291,7 @@ func (s *EnhancedBlockchainServer) handleWebSocketConnection(w http.ResponseWrit
 
 	// Register new client
 	s.clientsMutex.Lock()
-	s.clients[conn] = true
+	s.addClient(conn)
 	s.clientsMutex.Unlock()
 
 	// Send initial stats
@@ -154,86 +300,304 @@ func (s *EnhancedBlockchainServer) handleWebSocketConnection(w http.ResponseWrit
 	// Handle client disconnection
 	defer func() {
 		s.clientsMutex.Lock()
-		delete(s.clients, conn)
+		s.removeClient(conn)
 		s.clientsMutex.Unlock()
 		conn.Close()
 	}()
 
-	// Listen for messages from client (not used currently but could be for commands)
+	// Listen for requests from the client
+	limiter := newMessageRateLimiter(wsMessagesPerSecond, time.Second)
 	for {
-		_, _, err := conn.ReadMessage()
+		_, payload, err := conn.ReadMessage()
 		if err != nil {
 			break
 		}
+		s.handleClientMessage(conn, limiter, payload)
 	}
 }
 
-// handleBroadcasts sends messages to all connected WebSocket clients
-func (s *EnhancedBlockchainServer) handleBroadcasts() {
-	for message := range s.broadcast {
+// handleBroadcasts sends messages to all connected WebSocket clients, or
+// for topic messages to the clients subscribed to the topic, until done is
+// closed
+func (s *EnhancedBlockchainServer) handleBroadcasts(done <-chan struct{}) {
+	for {
+		var message interface{}
+		select {
+		case <-done:
+			return
+		case message = <-s.broadcast:
+		}
+
+		topic := ""
+		if tm, ok := message.(topicMessage); ok {
+			topic, message = tm.topic, tm.message
+		}
+
 		s.clientsMutex.Lock()
-		for client := range s.clients {
-			err := client.WriteJSON(message)
-			if err != nil {
+		for client, topics := range s.clients {
+			if topic != "" && !topics[topic] {
+				continue
+			}
+			if err := s.send(client, message); err != nil {
 				client.Close()
-				delete(s.clients, client)
+				s.removeClient(client)
 			}
 		}
 		s.clientsMutex.Unlock()
 	}
 }
 
-// sendStats sends current blockchain stats to a specific client
-func (s *EnhancedBlockchainServer) sendStats(conn *websocket.Conn) {
+// currentStats builds a snapshot of the blockchain stats
+func (s *EnhancedBlockchainServer) currentStats() map[string]interface{} {
 	stats := map[string]interface{}{
 		"type":             "stats",
-		"blockCount":       len(s.chain.Blocks),
+		"blockCount":       s.chain.Height() + 1,
 		"transactionCount": s.txPool.Count(),
-		"peerCount":        0, // To be implemented with P2P
+		"peerCount":        0,
+		"websocketClients": s.clientCount(),
 		"nodeHealthy":      true,
+		"version":          s.versionInfo(),
+	}
+	if s.peers != nil {
+		stats["peerCount"] = s.peers.PeerCount()
+	}
+	if sync, ok := s.syncStatus(); ok {
+		stats["sync"] = sync
 	}
+	if s.nodeState != nil {
+		stats["nodeState"] = s.nodeState.Status()
+	}
+	return stats
+}
 
-	conn.WriteJSON(stats)
+// sendStats sends current blockchain stats to a specific client
+func (s *EnhancedBlockchainServer) sendStats(conn *websocket.Conn) {
+	s.writeToClient(conn, s.currentStats())
+}
+
+// writeToClient sends a message to a single client. Writes share the clients
+// lock with broadcasts since a connection supports only one concurrent writer.
+func (s *EnhancedBlockchainServer) writeToClient(conn *websocket.Conn, message interface{}) error {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+	return s.send(conn, message)
 }
 
 // broadcastNewBlock notifies all clients about a new block
 func (s *EnhancedBlockchainServer) broadcastNewBlock(block blockchain.Block) {
-	s.broadcast <- map[string]interface{}{
+	s.publish(map[string]interface{}{
 		"type":  "new_block",
 		"block": block,
+	})
+}
+
+// announceChainEvent notifies WebSocket clients about every change to the
+// chain, and peers about blocks mined here. Blocks received from peers have
+// already been propagated by the P2P layer.
+func (s *EnhancedBlockchainServer) announceChainEvent(event blockchain.ChainEvent) {
+	switch event.Type {
+	case blockchain.EventBlockAdded:
+		s.broadcastNewBlock(event.Block)
+		if event.Mined && s.peers != nil {
+			s.peers.BroadcastBlock(event.Block)
+		}
+	case blockchain.EventChainReplaced:
+		message := map[string]interface{}{
+			"type":   "chain_replaced",
+			"height": event.Block.Index,
+			"tip":    event.Block,
+		}
+		if event.Reorg != nil {
+			message["reorg"] = event.Reorg
+		}
+		s.publish(message)
 	}
 }
 
 // broadcastNewTransaction notifies all clients about a new transaction
 func (s *EnhancedBlockchainServer) broadcastNewTransaction(tx *blockchain.Transaction) {
-	s.broadcast <- map[string]interface{}{
+	s.publish(map[string]interface{}{
 		"type":        "new_transaction",
 		"transaction": tx,
-	}
+	})
 }
 
 // broadcastContractDeployed notifies all clients about a new contract
 func (s *EnhancedBlockchainServer) broadcastContractDeployed(contract interface{}) {
-	s.broadcast <- map[string]interface{}{
+	s.publish(map[string]interface{}{
 		"type":     "contract_deployed",
 		"contract": contract,
+	})
+}
+
+// broadcastContractEvents notifies clients subscribed to a contract's topic
+// about the events one of its executions emitted
+func (s *EnhancedBlockchainServer) broadcastContractEvents(receiptID string, events []contracts.ContractEvent) {
+	for _, event := range events {
+		s.publish(topicMessage{
+			topic: contractTopic(event.ContractID),
+			message: map[string]interface{}{
+				"type":    "contract_event",
+				"receipt": receiptID,
+				"event":   event,
+			},
+		})
 	}
 }
 
+// broadcastNodeState notifies all clients about a node state transition
+func (s *EnhancedBlockchainServer) broadcastNodeState(status lifecycle.Status) {
+	s.publish(map[string]interface{}{
+		"type":   "node_state",
+		"status": status,
+	})
+}
+
+// broadcastSyncProgress notifies all clients about block sync progress
+func (s *EnhancedBlockchainServer) broadcastSyncProgress(status network.SyncStatus) {
+	s.publish(map[string]interface{}{
+		"type": "sync_progress",
+		"sync": status,
+	})
+}
+
+// isReady reports whether the node is ready to serve API traffic
+func (s *EnhancedBlockchainServer) isReady() bool {
+	return s.nodeState == nil || s.nodeState.IsReady()
+}
+
+// readinessMiddleware rejects API requests with 503 until the node is ready
+func (s *EnhancedBlockchainServer) readinessMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.isReady() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		status := s.nodeState.Status()
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Retry-After", notReadyRetryAfter)
+			respondWithError(w, http.StatusServiceUnavailable, fmt.Sprintf("node is %s, retry later", status.State))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":  "node is not ready",
+			"status": http.StatusServiceUnavailable,
+			"node":   status,
+		})
+	})
+}
+
+// handleGetVersion reports the running build and the API and P2P protocol
+// versions it speaks
+func (s *EnhancedBlockchainServer) handleGetVersion(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, s.versionInfo())
+}
+
+// versionInfo returns the build details with the node's ID
+func (s *EnhancedBlockchainServer) versionInfo() version.Info {
+	info := version.Get()
+	info.NodeID = s.nodeID
+	return info
+}
+
+// handleReady reports whether the node is ready along with its loading progress
+func (s *EnhancedBlockchainServer) handleReady(w http.ResponseWriter, r *http.Request) {
+	status := lifecycle.Status{State: lifecycle.StateReady}
+	if s.nodeState != nil {
+		status = s.nodeState.Status()
+	}
+
+	response := struct {
+		lifecycle.Status
+		Sync *network.SyncStatus `json:"sync,omitempty"`
+	}{Status: status}
+	if sync, ok := s.syncStatus(); ok {
+		response.Sync = &sync
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if status.State != lifecycle.StateReady {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleGetStats returns node statistics, including sync progress
+func (s *EnhancedBlockchainServer) handleGetStats(w http.ResponseWriter, r *http.Request) {
+	stats := s.currentStats()
+	delete(stats, "type")
+	jsonResponse(w, stats)
+}
+
+// handleGetPeers lists the known peers and their broadcast delivery statistics
+func (s *EnhancedBlockchainServer) handleGetPeers(w http.ResponseWriter, r *http.Request) {
+	peers := []network.PeerInfo{}
+	if lister, ok := s.peers.(peerLister); ok {
+		peers = lister.Peers()
+	}
+	jsonResponse(w, map[string]interface{}{"peers": peers})
+}
+
+// handleAddPeer connects to a peer and stores it; admin only
+func (s *EnhancedBlockchainServer) handleAddPeer(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		respondWithErrorCode(w, http.StatusUnauthorized, "unauthenticated", "adding peers requires the admin token")
+		return
+	}
+	connector, ok := s.peers.(peerConnector)
+	if !ok {
+		respondWithError(w, http.StatusNotImplemented, "adding peers requires the HTTP P2P network")
+		return
+	}
+
+	var request struct {
+		Address string `json:"address"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Address == "" {
+		respondWithError(w, http.StatusBadRequest, "request must name the peer address")
+		return
+	}
+	if err := connector.ConnectPeer(request.Address); err != nil {
+		respondWithErrorCode(w, http.StatusBadGateway, "peer_unreachable", err.Error())
+		return
+	}
+	jsonResponse(w, map[string]interface{}{"address": request.Address, "status": "connected"})
+}
+
 // handleGetBlockchain returns the entire blockchain
 func (s *EnhancedBlockchainServer) handleGetBlockchain(w http.ResponseWriter, r *http.Request) {
 	response := map[string]interface{}{
-		"blocks":     s.chain.Blocks,
+		"blocks":     s.chain.GetBlocks(),
 		"difficulty": s.difficulty,
+		"limits":     s.chain.Limits(),
 	}
 
-	jsonResponse(w, response)
+	negotiatedResponse(w, r, response)
+}
+
+// chainValidation is the validate endpoint's reply. InvalidIndex and Error
+// are set when the chain is invalid.
+type chainValidation struct {
+	Valid        bool   `json:"valid"`
+	Height       int    `json:"height"`
+	InvalidIndex *int   `json:"invalidIndex,omitempty"`
+	Error        string `json:"error,omitempty"`
 }
 
-// handleGetBlocks returns all blocks or a subset with pagination
-func (s *EnhancedBlockchainServer) handleGetBlocks(w http.ResponseWriter, r *http.Request) {
-	// Could implement pagination here
-	jsonResponse(w, map[string]interface{}{"blocks": s.chain.Blocks})
+// handleValidateChain audits the whole chain and reports the first invalid
+// block, if any
+func (s *EnhancedBlockchainServer) handleValidateChain(w http.ResponseWriter, r *http.Request) {
+	result := chainValidation{Valid: true, Height: s.chain.Height()}
+	if index, err := s.chain.Validate(); err != nil {
+		result.Valid = false
+		result.InvalidIndex = &index
+		result.Error = err.Error()
+	}
+	negotiatedResponse(w, r, result)
 }
 
 // handleGetBlock returns a specific block by hash
@@ -241,60 +605,182 @@ func (s *EnhancedBlockchainServer) handleGetBlock(w http.ResponseWriter, r *http
 	vars := mux.Vars(r)
 	hash := vars["hash"]
 
-	for _, block := range s.chain.Blocks {
-		if block.Hash == hash {
-			jsonResponse(w, block)
-			return
-		}
+	if block, ok := s.findBlock(hash); ok {
+		negotiatedResponse(w, r, block)
+		return
 	}
 
 	http.Error(w, "Block not found", http.StatusNotFound)
 }
 
+// handleMineBlock mines a block from a batch of pending transactions
+func (s *EnhancedBlockchainServer) handleMineBlock(w http.ResponseWriter, r *http.Request) {
+	block, err := s.MineBlock(r.Context())
+	if errors.Is(err, blockchain.ErrInvalidBlock) {
+		respondWithError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	negotiatedResponse(w, r, block)
+}
+
+// MineBlock mines a block from a batch of pending transactions and removes
+// them from the pool. The chain event it raises announces the block to peers
+// and WebSocket clients.
+func (s *EnhancedBlockchainServer) MineBlock(ctx context.Context) (blockchain.Block, error) {
+	start := time.Now()
+
+	block, err := s.chain.AddBlockContext(ctx, s.txPool, s.difficulty)
+	if err != nil {
+		return blockchain.Block{}, err
+	}
+
+	size, _ := json.Marshal(block.Transactions)
+	s.metrics.BlockAdded(time.Since(start), len(size))
+	return block, nil
+}
+
+// findBlock looks up a block on the chain by hash
+func (s *EnhancedBlockchainServer) findBlock(hash string) (blockchain.Block, bool) {
+	return s.chain.GetBlockByHash(hash)
+}
+
 // handleCreateTransaction adds a new transaction to the pool
 func (s *EnhancedBlockchainServer) handleCreateTransaction(w http.ResponseWriter, r *http.Request) {
-	var txData struct {
-		From  string  `json:"from"`
-		To    string  `json:"to"`
-		Value float64 `json:"value"`
-		Data  string  `json:"data"`
+	var txData transactionRequest
+	if err := requestCodec(r).Decode(r.Body, &txData); err != nil {
+		http.Error(w, "Invalid transaction data", http.StatusBadRequest)
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&txData); err != nil {
-		http.Error(w, "Invalid transaction data", http.StatusBadRequest)
+	tx, err := s.submitTransaction(r.Context(), txData)
+	if errors.Is(err, errBadSignature) || errors.Is(err, errStaleSignature) || errors.Is(err, errWrongSigner) {
+		respondWithErrorCode(w, http.StatusUnauthorized, "unauthenticated", err.Error())
+		return
+	}
+	if errors.Is(err, blockchain.ErrPoolFull) {
+		respondWithErrorCode(w, http.StatusServiceUnavailable, "pool_full", err.Error())
+		return
+	}
+	if errors.Is(err, blockchain.ErrInsufficientFunds) {
+		respondWithErrorCode(w, http.StatusUnprocessableEntity, "insufficient_funds", err.Error())
+		return
+	}
+	if errors.Is(err, errTransactionTooLarge) {
+		respondWithErrorCode(w, http.StatusRequestEntityTooLarge, "transaction_too_large", err.Error())
 		return
 	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	negotiatedResponse(w, r, map[string]string{"id": tx.ID, "status": "pending"})
+}
+
+// transactionRequest is the client-supplied payload for a new transaction.
+// Signed transactions must be signed by their sender.
+type transactionRequest struct {
+	From  string  `json:"from"`
+	To    string  `json:"to"`
+	Value float64 `json:"value"`
+	Fee   float64 `json:"fee"`
+	Data  string  `json:"data"`
+	RequestSignature
+}
+
+// errWrongSigner is returned when a transaction is signed by someone other
+// than its sender
+var errWrongSigner = errors.New("transaction must be signed by its sender")
+
+// errTransactionTooLarge is returned for transactions that wouldn't fit in
+// a block under the chain's limits, so could never be mined
+var errTransactionTooLarge = errors.New("transaction too large for a block")
+
+// lastTransactionID is the latest ID handed out by nextTransactionID
+var lastTransactionID atomic.Int64
+
+// nextTransactionID returns a transaction ID: the current Unix time in
+// nanoseconds, bumped past the previous ID so that transactions submitted
+// in the same nanosecond don't collide
+func nextTransactionID() string {
+	for {
+		last := lastTransactionID.Load()
+		id := time.Now().UnixNano()
+		if id <= last {
+			id = last + 1
+		}
+		if lastTransactionID.CompareAndSwap(last, id) {
+			return strconv.FormatInt(id, 10)
+		}
+	}
+}
+
+// submitTransaction adds a transaction to the pool, records metrics, and
+// notifies WebSocket clients. It is shared by the REST and WebSocket APIs.
+func (s *EnhancedBlockchainServer) submitTransaction(ctx context.Context, txData transactionRequest) (*blockchain.Transaction, error) {
+	if txData.Fee < 0 {
+		return nil, errors.New("fee must not be negative")
+	}
+	if txData.From == blockchain.FaucetAddress {
+		return nil, errors.New("faucet transactions are only created by the faucet")
+	}
 
 	// Create a new transaction
 	tx := &blockchain.Transaction{
-		ID:        fmt.Sprintf("%d", time.Now().UnixNano()), // Simple ID generation
+		ID:        nextTransactionID(),
 		From:      txData.From,
 		To:        txData.To,
 		Data:      txData.Data,
 		Value:     txData.Value,
+		Fee:       txData.Fee,
 		Timestamp: time.Now(),
-		// Signature would be added in a real implementation
+	}
+	if txData.signed() {
+		signer, err := txData.verify(actionTransaction, "", wallet.TransactionHash(txData.From, txData.To, txData.Value, txData.Fee, txData.Data))
+		if err != nil {
+			return nil, err
+		}
+		if signer != txData.From {
+			return nil, errWrongSigner
+		}
+		tx.PublicKey, tx.Signature = txData.PublicKey, txData.Signature
+	}
+	if size, budget := blockchain.TransactionSize(*tx), s.chain.TransactionBudget(s.difficulty); size > budget {
+		return nil, fmt.Errorf("%w: %d bytes, over the %d a block has room for", errTransactionTooLarge, size, budget)
 	}
 
 	// Add to transaction pool
-	if err := s.txPool.AddTransaction(tx); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	_, span := tracer().Start(ctx, "txpool.add", trace.WithAttributes(attribute.String("tx.id", tx.ID)))
+	err := s.txPool.AddTransaction(tx)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	if err != nil {
+		return nil, err
 	}
 
 	// Record metrics
 	s.metrics.TransactionProcessed(time.Millisecond * 10) // Placeholder processing time
 
-	// Broadcast to WebSocket clients
+	// Broadcast to WebSocket clients and peers
 	s.broadcastNewTransaction(tx)
+	if s.peers != nil {
+		s.peers.BroadcastTransaction(tx)
+	}
 
-	jsonResponse(w, map[string]string{"id": tx.ID, "status": "pending"})
+	return tx, nil
 }
 
 // handleGetTransactions returns all transactions
 func (s *EnhancedBlockchainServer) handleGetTransactions(w http.ResponseWriter, r *http.Request) {
 	// In a real implementation, this would search transactions in blocks
-	jsonResponse(w, map[string]interface{}{"transactions": s.txPool.GetAllTransactions()})
+	negotiatedResponse(w, r, map[string]interface{}{"transactions": s.txPool.GetAllTransactions()})
 }
 
 // handleGetTransaction returns a specific transaction by ID
@@ -302,27 +788,75 @@ func (s *EnhancedBlockchainServer) handleGetTransaction(w http.ResponseWriter, r
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	tx, err := s.txPool.GetTransaction(id)
-	if err != nil {
-		// Could search in confirmed transactions here
-		http.Error(w, "Transaction not found", http.StatusNotFound)
+	if tx, err := s.txPool.GetTransaction(id); err == nil {
+		negotiatedResponse(w, r, transactionStatus{Transaction: tx, Status: statusPending})
+		return
+	}
+
+	// Look for it in the mined blocks, newest first
+	blocks := s.chain.GetBlocks()
+	for i := len(blocks) - 1; i >= 0; i-- {
+		for _, tx := range blockchain.BlockTransactions(blocks[i]) {
+			if tx.ID == id {
+				index := blocks[i].Index
+				negotiatedResponse(w, r, transactionStatus{
+					Transaction: &tx,
+					Status:      statusConfirmed,
+					BlockHash:   blocks[i].Hash,
+					BlockIndex:  &index,
+				})
+				return
+			}
+		}
+	}
+	http.Error(w, "Transaction not found", http.StatusNotFound)
+}
+
+// Transaction statuses
+const (
+	statusPending   = "pending"
+	statusConfirmed = "confirmed"
+)
+
+// transactionStatus is a transaction with whether it has been mined, and
+// where
+type transactionStatus struct {
+	*blockchain.Transaction
+	Status     string `json:"status"`
+	BlockHash  string `json:"blockHash,omitempty"`
+	BlockIndex *int   `json:"blockIndex,omitempty"`
+}
+
+// handleVerifyTransaction returns a merkle proof that a transaction was
+// mined, fetched from full peers when this is a light node
+func (s *EnhancedBlockchainServer) handleVerifyTransaction(w http.ResponseWriter, r *http.Request) {
+	verifier, ok := s.peers.(transactionVerifier)
+	if !ok {
+		respondWithError(w, http.StatusNotImplemented, "transaction proofs require the P2P network")
 		return
 	}
 
-	jsonResponse(w, tx)
+	proof, err := verifier.VerifyTransaction(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	negotiatedResponse(w, r, proof)
 }
 
 // handleGetPendingTransactions returns all pending transactions
 func (s *EnhancedBlockchainServer) handleGetPendingTransactions(w http.ResponseWriter, r *http.Request) {
-	jsonResponse(w, map[string]interface{}{"transactions": s.txPool.GetAllTransactions()})
+	negotiatedResponse(w, r, map[string]interface{}{"transactions": s.txPool.GetAllTransactions()})
 }
 
 // handleDeployContract deploys a new smart contract
 func (s *EnhancedBlockchainServer) handleDeployContract(w http.ResponseWriter, r *http.Request) {
 	var contractData struct {
-		Type string `json:"type"`
-		Name string `json:"name"`
-		Code string `json:"code"`
+		Type  string `json:"type"`
+		Name  string `json:"name"`
+		Code  string `json:"code"`
+		Owner string `json:"owner"` // Admin deploys only; signed deploys are owned by the signer
+		RequestSignature
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&contractData); err != nil {
@@ -330,143 +864,328 @@ func (s *EnhancedBlockchainServer) handleDeployContract(w http.ResponseWriter, r
 		return
 	}
 
-	contractID := fmt.Sprintf("contract-%d", time.Now().UnixNano())
-	var deployErr error
-	var contractInfo interface{}
-
-	switch contractData.Type {
-	case "wasm":
-		// In a real implementation, would save the WASM code to a file first
-		// For now, just return an error as we can't deploy from code string directly
-		http.Error(w, "WASM deployment from code string not supported", http.StatusNotImplemented)
-		return
-
-	case "lua":
-		deployErr = s.luaEngine.DeployContract(contractID, contractData.Name, contractData.Code)
-		if deployErr == nil {
-			contract, _ := s.luaEngine.GetContract(contractID)
-			contractInfo = map[string]interface{}{
-				"id":   contractID,
-				"name": contract.Name,
-				"type": "lua",
-			}
+	// Signed deploys are owned by the signer. Unsigned ones have no owner
+	// unless an admin names one, and only admins can manage them.
+	owner := ""
+	if contractData.signed() {
+		address, err := contractData.verify(actionDeploy, "", wallet.CodeHash(contractData.Code))
+		if err != nil {
+			respondWithErrorCode(w, http.StatusUnauthorized, "unauthenticated", err.Error())
+			return
+		}
+		owner = address
+	}
+	if contractData.Owner != "" {
+		if !s.isAdmin(r) {
+			respondWithErrorCode(w, http.StatusForbidden, "not_owner", "only admins may deploy contracts for another owner")
+			return
+		}
+		if !isAddress(contractData.Owner) {
+			respondWithError(w, http.StatusBadRequest, "owner must be a 40 character hex address")
+			return
 		}
+		owner = contractData.Owner
+	}
 
-	default:
+	// WASM binaries are sent base64-encoded, other contracts as source
+	code := []byte(contractData.Code)
+	if contractData.Type == contracts.TypeWASM {
+		var err error
+		if code, err = base64.StdEncoding.DecodeString(contractData.Code); err != nil {
+			http.Error(w, "WASM code must be base64-encoded", http.StatusBadRequest)
+			return
+		}
+	}
+
+	contractID := fmt.Sprintf("contract-%d", time.Now().UnixNano())
+	deployErr := s.registry.Deploy(contractData.Type, contractID, contractData.Name, owner, code)
+	if errors.Is(deployErr, contracts.ErrUnknownContractType) {
 		http.Error(w, "Unsupported contract type", http.StatusBadRequest)
 		return
 	}
-
+	if errors.Is(deployErr, contracts.ErrMemoryLimit) {
+		respondWithError(w, http.StatusUnprocessableEntity, deployErr.Error())
+		return
+	}
+	if errors.Is(deployErr, contracts.ErrCodeTooLarge) {
+		respondWithErrorCode(w, http.StatusRequestEntityTooLarge, "code_too_large", deployErr.Error())
+		return
+	}
+	if errors.Is(deployErr, contracts.ErrInvalidContract) {
+		respondWithErrorCode(w, http.StatusUnprocessableEntity, "invalid_contract", deployErr.Error())
+		return
+	}
 	if deployErr != nil {
 		http.Error(w, deployErr.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Broadcast to WebSocket clients
-	s.broadcastContractDeployed(contractInfo)
+	if info, err := s.registry.GetContract(contractID); err == nil {
+		s.broadcastContractDeployed(info)
+	}
 
-	jsonResponse(w, map[string]interface{}{"id": contractID, "status": "deployed"})
+	jsonResponse(w, map[string]interface{}{"id": contractID, "owner": owner, "status": "deployed"})
 }
 
-// handleGetContracts returns all deployed contracts
+// contractListing is a contract in the contract listing
+type contractListing struct {
+	contracts.ContractInfo
+	Functions int `json:"functions"` // How many functions the ABI lists
+}
+
+// handleGetContracts returns all deployed contracts, including stored
+// contracts that failed to reload with the reason
 func (s *EnhancedBlockchainServer) handleGetContracts(w http.ResponseWriter, r *http.Request) {
-	wasmContracts := s.wasmEngine.ListContracts()
-	luaContracts := s.luaEngine.ListContracts()
+	infos := s.registry.ListContracts()
+	list := make([]contractListing, 0, len(infos))
+	for _, info := range infos {
+		list = append(list, contractListing{
+			ContractInfo: info,
+			Functions:    functionCount(s.registry.ABI(info.ID)),
+		})
+	}
 
-	// Convert to a common format
-	contracts := make([]map[string]interface{}, 0)
+	jsonResponse(w, map[string]interface{}{"contracts": list})
+}
 
-	for _, c := range wasmContracts {
-		contracts = append(contracts, map[string]interface{}{
-			"id":   c.ID,
-			"name": c.Name,
-			"type": "wasm",
-		})
+// functionCount returns how many functions a contract's ABI lists, or 0 when
+// it has none
+func functionCount(abi *contracts.ContractABI, err error) int {
+	if err != nil {
+		return 0
 	}
+	return len(abi.Functions)
+}
 
-	for _, c := range luaContracts {
-		contracts = append(contracts, map[string]interface{}{
-			"id":   c.ID,
-			"name": c.Name,
-			"type": "lua",
-		})
+// handleGetContractABI returns the functions a contract can execute
+func (s *EnhancedBlockchainServer) handleGetContractABI(w http.ResponseWriter, r *http.Request) {
+	abi, err := s.registry.ABI(mux.Vars(r)["id"])
+	switch {
+	case errors.Is(err, contracts.ErrABIUnavailable):
+		respondWithErrorCode(w, http.StatusNotFound, "abi_unavailable", err.Error())
+	case err != nil:
+		respondWithError(w, http.StatusNotFound, "contract not found")
+	default:
+		negotiatedResponse(w, r, abi)
 	}
+}
+
+// memoryLimiter is implemented by engines that cap contract memory
+type memoryLimiter interface {
+	MemoryLimitPages() uint32
+}
 
-	jsonResponse(w, map[string]interface{}{"contracts": contracts})
+// contractDetails is a contract as returned by the contract endpoint
+type contractDetails struct {
+	contracts.ContractInfo
+	MemoryLimitPages uint32 `json:"memoryLimitPages,omitempty"`
 }
 
 // handleGetContract returns a specific contract
 func (s *EnhancedBlockchainServer) handleGetContract(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
-
-	// Try to find in WASM contracts
-	wasmContract, err1 := s.wasmEngine.GetContract(id)
-	if err1 == nil {
-		jsonResponse(w, map[string]interface{}{
-			"id":   wasmContract.ID,
-			"name": wasmContract.Name,
-			"type": "wasm",
-		})
+	info, err := s.registry.GetContract(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Contract not found", http.StatusNotFound)
 		return
 	}
 
-	// Try to find in Lua contracts
-	luaContract, err2 := s.luaEngine.GetContract(id)
-	if err2 == nil {
-		jsonResponse(w, map[string]interface{}{
-			"id":   luaContract.ID,
-			"name": luaContract.Name,
-			"type": "lua",
-		})
-		return
+	details := contractDetails{ContractInfo: info}
+	if engine, ok := s.registry.Engine(info.Type); ok {
+		if limiter, ok := engine.(memoryLimiter); ok {
+			details.MemoryLimitPages = limiter.MemoryLimitPages()
+		}
 	}
-
-	http.Error(w, "Contract not found", http.StatusNotFound)
+	jsonResponse(w, details)
 }
 
 // handleExecuteContract executes a function in a smart contract
 func (s *EnhancedBlockchainServer) handleExecuteContract(w http.ResponseWriter, r *http.Request) {
+	s.handleContractCall(w, r, false)
+}
+
+// handleSimulateContract previews an execution without committing its
+// state writes or recording a receipt
+func (s *EnhancedBlockchainServer) handleSimulateContract(w http.ResponseWriter, r *http.Request) {
+	s.handleContractCall(w, r, true)
+}
+
+// handleContractCall runs a function in a smart contract, as a dry run when
+// simulate is set
+func (s *EnhancedBlockchainServer) handleContractCall(w http.ResponseWriter, r *http.Request, simulate bool) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
 	var execData struct {
-		Function string        `json:"function"`
-		Params   []interface{} `json:"params"`
+		Function string          `json:"function"`
+		Params   json.RawMessage `json:"params"`
+		GasLimit uint64          `json:"gasLimit"` // 0 uses the engine default
+		Caller   string          `json:"caller"`   // Admin only; signed requests are made by the signer
+		Value    uint64          `json:"value"`    // Admin only, for calls made by a transaction
+		TxID     string          `json:"txId"`     // Admin only, the transaction making the call
+		Returns  string          `json:"returns"`  // WASM only; "string" or "bytes" for packed ptr/len results
+		RequestSignature
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&execData); err != nil {
 		http.Error(w, "Invalid execution data", http.StatusBadRequest)
 		return
 	}
+	var params []interface{}
+	if len(execData.Params) > 0 {
+		if err := json.Unmarshal(execData.Params, &params); err != nil {
+			http.Error(w, "Invalid execution data", http.StatusBadRequest)
+			return
+		}
+	}
 
-	// Try to execute WASM contract
-	_, err1 := s.wasmEngine.GetContract(id)
-	if err1 == nil {
-		result, err := s.wasmEngine.ExecuteContract(id, execData.Function, execData.Params...)
+	// Only admins, which apply transactions, may name the caller or describe
+	// a transaction; anyone else is the caller only if they sign
+	if (execData.Caller != "" || execData.Value != 0 || execData.TxID != "") && !s.isAdmin(r) {
+		respondWithErrorCode(w, http.StatusForbidden, "forbidden", "only admins may set caller, value or txId; sign the request instead")
+		return
+	}
+	caller := execData.Caller
+	if execData.signed() {
+		address, err := execData.verify(actionExecute, id, wallet.CallHash(execData.Function, execData.Params))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respondWithErrorCode(w, http.StatusUnauthorized, "unauthenticated", err.Error())
 			return
 		}
+		caller = address
+	}
 
-		jsonResponse(w, map[string]interface{}{"result": result})
-		return
+	// Bound the call, and stop it if the client disconnects
+	ctx := r.Context()
+	if s.timeouts.Contract > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeouts.Contract)
+		defer cancel()
 	}
 
-	// Try to execute Lua contract
-	_, err2 := s.luaEngine.GetContract(id)
-	if err2 == nil {
-		result, err := s.luaEngine.ExecuteContract(id, execData.Function, execData.Params...)
+	latest := s.chain.GetLatestBlock()
+	execCtx := contracts.ExecutionContext{
+		Caller:      caller,
+		Value:       execData.Value,
+		BlockHeight: int64(latest.Index),
+		BlockTime:   latest.Timestamp,
+		TxID:        execData.TxID,
+		GasLimit:    execData.GasLimit,
+		Returns:     execData.Returns,
+	}
+	// Contracts that failed to reload can't run, and paused ones refuse to
+	info, err := s.registry.GetContract(id)
+	if err != nil || info.Status == contracts.StatusFailed {
+		http.Error(w, "Contract not found", http.StatusNotFound)
+		return
+	}
+	if info.Pause != nil {
+		respondWithPaused(w, id, info.Pause)
+		return
+	}
+	if simulate {
+		result, err := s.registry.DryRun(ctx, execCtx, id, execData.Function, params...)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respondWithExecutionError(w, r, contracts.AsExecutionError(err), "")
 			return
 		}
+		jsonResponse(w, result)
+		return
+	}
+	result, err := s.registry.Execute(ctx, execCtx, id, execData.Function, params...)
+	if errors.Is(err, contracts.ErrQueueFull) {
+		respondWithErrorCode(w, http.StatusTooManyRequests, "queue_full", err.Error())
+		return
+	}
 
-		jsonResponse(w, map[string]interface{}{"result": result})
+	// Record the execution, successful or not
+	receipt := contracts.Receipt{
+		ID:         fmt.Sprintf("receipt-%d", time.Now().UnixNano()),
+		ContractID: id,
+		Function:   execData.Function,
+		Caller:     caller,
+		CreatedAt:  time.Now(),
+	}
+	if result != nil {
+		receipt.GasUsed = result.GasUsed
+	}
+	if err != nil {
+		failure := contracts.AsExecutionError(err)
+		receipt.Error, receipt.ErrorCode, receipt.Failure = failure.Message, failure.Code, failure
+		s.receipts.Add(receipt)
+		respondWithExecutionError(w, r, failure, receipt.ID)
 		return
 	}
 
-	http.Error(w, "Contract not found", http.StatusNotFound)
+	// Publish the events of a successful execution
+	receipt.Result = result.Value
+	receipt.Events = result.Events
+	s.receipts.Add(receipt)
+	s.broadcastContractEvents(receipt.ID, result.Events)
+
+	jsonResponse(w, executionResponse{ExecutionResult: result, Receipt: receipt.ID})
+}
+
+// executionResponse is the execute endpoint's reply
+type executionResponse struct {
+	*contracts.ExecutionResult
+	Receipt string `json:"receipt"`
+}
+
+// executionErrorResponse is the error envelope of the execute and simulate
+// endpoints, naming the engine that failed and the receipt recorded for a
+// failed execution
+type executionErrorResponse struct {
+	ErrorResponse
+	Engine      string `json:"engine,omitempty"`
+	Diagnostics string `json:"diagnostics,omitempty"` // Only for requests with debug=true
+	Receipt     string `json:"receipt,omitempty"`
+}
+
+// respondWithExecutionError writes the envelope of a failed execution,
+// with the engine's diagnostics when the request asks for them
+func respondWithExecutionError(w http.ResponseWriter, r *http.Request, failure *contracts.ExecutionError, receiptID string) {
+	status := executionErrorStatus(failure.Code)
+	response := executionErrorResponse{
+		ErrorResponse: ErrorResponse{Error: failure.Message, Status: status, Code: failure.Code},
+		Engine:        failure.Engine,
+		Receipt:       receiptID,
+	}
+	if debug, _ := strconv.ParseBool(r.URL.Query().Get("debug")); debug {
+		response.Diagnostics = failure.Diagnostics
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// executionErrorStatus maps an execution error code to its HTTP status
+func executionErrorStatus(code string) int {
+	switch code {
+	case contracts.CodeNotFound:
+		return http.StatusNotFound
+	case contracts.CodeBadParams, contracts.CodeParamTooLarge:
+		return http.StatusBadRequest
+	case contracts.CodeEngineError:
+		return http.StatusInternalServerError
+	case contracts.CodePaused:
+		return http.StatusLocked
+	case contracts.CodeStateConflict:
+		return http.StatusConflict
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
+
+// handleGetReceipt returns the receipt of a contract execution
+func (s *EnhancedBlockchainServer) handleGetReceipt(w http.ResponseWriter, r *http.Request) {
+	receipt, ok := s.receipts.Get(mux.Vars(r)["id"])
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "receipt not found")
+		return
+	}
+	negotiatedResponse(w, r, receipt)
 }
 
 // jsonResponse sends a JSON response with the given data
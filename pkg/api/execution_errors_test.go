@@ -0,0 +1,44 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/contracts"
+)
+
+func TestExecutionErrorsMapToStatusesWithDiagnosticsOnRequest(t *testing.T) {
+	s := newTestServer(t)
+	id := deployLua(t, s, "function revert() error('boom') end")
+
+	tests := []struct {
+		path, function string
+		status         int
+		code           string
+		diagnostics    bool
+	}{
+		{"/api/contracts/" + id + "/execute", "missing", http.StatusNotFound, contracts.CodeNotFound, false},
+		{"/api/contracts/" + id + "/execute", "revert", http.StatusUnprocessableEntity, contracts.CodeReverted, false},
+		{"/api/contracts/" + id + "/execute?debug=true", "revert", http.StatusUnprocessableEntity, contracts.CodeReverted, true},
+	}
+	for _, tt := range tests {
+		w := serve(s, http.MethodPost, tt.path, `{"function": "`+tt.function+`"}`)
+		var response executionErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != tt.status || response.Code != tt.code || response.Engine != contracts.TypeLua && tt.code == contracts.CodeReverted {
+			t.Errorf("%s %s: got %d %+v", tt.path, tt.function, w.Code, response)
+		}
+		if (response.Diagnostics != "") != tt.diagnostics {
+			t.Errorf("%s %s: diagnostics %q", tt.path, tt.function, response.Diagnostics)
+		}
+
+		var receipt contracts.Receipt
+		json.NewDecoder(serve(s, http.MethodGet, "/api/receipts/"+response.Receipt, "").Body).Decode(&receipt)
+		if receipt.Failure == nil || receipt.Failure.Code != tt.code {
+			t.Errorf("%s %s: receipt recorded %+v", tt.path, tt.function, receipt.Failure)
+		}
+	}
+}
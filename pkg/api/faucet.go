@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// FaucetConfig limits the development faucet
+type FaucetConfig struct {
+	// MaxAmount caps a single request
+	MaxAmount float64
+	// Interval is how long an address waits between requests
+	Interval time.Duration
+}
+
+// DefaultFaucetConfig returns the default faucet limits
+func DefaultFaucetConfig() FaucetConfig {
+	return FaucetConfig{MaxAmount: 100, Interval: time.Minute}
+}
+
+// faucet hands out funds on development chains, once per interval per
+// address
+type faucet struct {
+	config FaucetConfig
+	last   map[string]time.Time // Last drip per address
+	mutex  sync.Mutex
+}
+
+// reserve records a drip to address at now, or returns how long the
+// address still has to wait
+func (f *faucet) reserve(address string, now time.Time) time.Duration {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if last, ok := f.last[address]; ok {
+		if wait := last.Add(f.config.Interval).Sub(now); wait > 0 {
+			return wait
+		}
+	}
+	f.last[address] = now
+	return 0
+}
+
+// release forgets a drip that was never paid out
+func (f *faucet) release(address string, at time.Time) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.last[address].Equal(at) {
+		delete(f.last, address)
+	}
+}
+
+// EnableFaucet serves POST /api/faucet, which credits addresses through
+// faucet transactions. Only for dev mode, where the chain and pool accept
+// them; it must be called before Listen.
+func (s *EnhancedBlockchainServer) EnableFaucet(config FaucetConfig) {
+	s.faucet = &faucet{config: config, last: make(map[string]time.Time)}
+}
+
+// handleFaucet credits the requested amount to an address
+func (s *EnhancedBlockchainServer) handleFaucet(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Address string  `json:"address"`
+		Amount  float64 `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid faucet request")
+		return
+	}
+	if request.Address == "" {
+		respondWithError(w, http.StatusBadRequest, "address is required")
+		return
+	}
+	if request.Address == blockchain.FaucetAddress {
+		respondWithError(w, http.StatusBadRequest, "the faucet can't fund itself")
+		return
+	}
+	if !(request.Amount > 0) || request.Amount > s.faucet.config.MaxAmount || math.IsInf(request.Amount, 0) {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("amount must be above 0 and at most %g", s.faucet.config.MaxAmount))
+		return
+	}
+
+	now := time.Now()
+	if wait := s.faucet.reserve(request.Address, now); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		respondWithErrorCode(w, http.StatusTooManyRequests, "rate_limited", fmt.Sprintf("address may use the faucet again in %s", wait.Round(time.Second)))
+		return
+	}
+
+	tx := blockchain.NewFaucetTransaction(request.Address, request.Amount, now)
+	if err := s.txPool.AddTransaction(tx); err != nil {
+		s.faucet.release(request.Address, now)
+		if errors.Is(err, blockchain.ErrPoolFull) {
+			respondWithErrorCode(w, http.StatusServiceUnavailable, "pool_full", err.Error())
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.metrics.TransactionProcessed(time.Millisecond * 10)
+
+	// Broadcast to WebSocket clients and peers
+	s.broadcastNewTransaction(tx)
+	if s.peers != nil {
+		s.peers.BroadcastTransaction(tx)
+	}
+
+	jsonResponse(w, map[string]interface{}{
+		"id":      tx.ID,
+		"address": tx.To,
+		"amount":  tx.Value,
+		"status":  "pending",
+	})
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// newFaucetServer returns a test server with the faucet enabled, as in dev
+// mode
+func newFaucetServer(t *testing.T) *EnhancedBlockchainServer {
+	t.Helper()
+	s := newTestServer(t)
+	s.txPool.AllowFaucet(true)
+	s.EnableFaucet(FaucetConfig{MaxAmount: 100, Interval: time.Minute})
+	return s
+}
+
+func TestFaucetDrip(t *testing.T) {
+	s := newFaucetServer(t)
+
+	w := serve(s, http.MethodPost, "/api/faucet", `{"address":"carol","amount":25}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := s.txPool.GetTransaction(body.ID)
+	if err != nil {
+		t.Fatalf("drip isn't pending: %v", err)
+	}
+	if !blockchain.IsFaucetTransaction(*tx) || tx.To != "carol" || tx.Value != 25 {
+		t.Fatalf("pool holds %+v", tx)
+	}
+}
+
+func TestFaucetRateLimitsEachAddress(t *testing.T) {
+	s := newFaucetServer(t)
+
+	if w := serve(s, http.MethodPost, "/api/faucet", `{"address":"carol","amount":1}`); w.Code != http.StatusOK {
+		t.Fatalf("first drip: status %d", w.Code)
+	}
+	w := serve(s, http.MethodPost, "/api/faucet", `{"address":"carol","amount":1}`)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("second drip: status %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := serve(s, http.MethodPost, "/api/faucet", `{"address":"dave","amount":1}`); w.Code != http.StatusOK {
+		t.Fatalf("another address: status %d", w.Code)
+	}
+}
+
+func TestFaucetRejectsInvalidRequests(t *testing.T) {
+	s := newFaucetServer(t)
+	for _, body := range []string{
+		`{"address":"carol","amount":101}`,
+		`{"address":"carol","amount":0}`,
+		`{"amount":1}`,
+		`{"address":"faucet","amount":1}`,
+		`{`,
+	} {
+		if w := serve(s, http.MethodPost, "/api/faucet", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", body, w.Code)
+		}
+	}
+	// Rejected requests don't count against the rate limit
+	if w := serve(s, http.MethodPost, "/api/faucet", `{"address":"carol","amount":1}`); w.Code != http.StatusOK {
+		t.Fatalf("valid drip after rejected ones: status %d", w.Code)
+	}
+}
+
+func TestFaucetOnlyInDevMode(t *testing.T) {
+	s := newTestServer(t)
+	if w := serve(s, http.MethodPost, "/api/faucet", `{"address":"carol","amount":1}`); w.Code == http.StatusOK {
+		t.Fatal("faucet served outside dev mode")
+	}
+	if s.txPool.Count() != 0 {
+		t.Fatal("faucet transaction pending outside dev mode")
+	}
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// maxHeadersPage caps the headers of one GET /api/headers response
+const maxHeadersPage = 2000
+
+// handleGetHeaders returns the headers of the blocks from index from, up
+// to limit of them, without their transactions. A limit of 0 or over
+// maxHeadersPage returns maxHeadersPage headers; clients page on with from.
+func (s *EnhancedBlockchainServer) handleGetHeaders(w http.ResponseWriter, r *http.Request) {
+	from, limit, err := blockRange(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if limit == 0 || limit > maxHeadersPage {
+		limit = maxHeadersPage
+	}
+
+	headers := s.chain.GetHeaders(from, limit)
+	if headers == nil {
+		headers = []blockchain.BlockHeader{}
+	}
+	negotiatedResponse(w, r, map[string]interface{}{"headers": headers})
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+func TestGetHeaders(t *testing.T) {
+	s := newTestServer(t)
+	tx := blockchain.Transaction{ID: "tx", To: "bob"}
+	if _, err := s.chain.AddTransactionsContext(context.Background(), []blockchain.Transaction{tx}, 1); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := s.chain.AddBlock(nil, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := serve(s, http.MethodGet, "/api/headers?from=1&limit=2", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "transactions") {
+		t.Errorf("headers carry bodies: %s", w.Body)
+	}
+	var response struct {
+		Headers []blockchain.BlockHeader `json:"headers"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	blocks := s.chain.GetBlocks()
+	if len(response.Headers) != 2 || response.Headers[0] != blocks[1].Header() || response.Headers[1] != blocks[2].Header() {
+		t.Fatalf("got %+v, want the headers of blocks 1 and 2", response.Headers)
+	}
+
+	if w := serve(s, http.MethodGet, "/api/headers?from=10", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"headers":[]`) {
+		t.Errorf("past the tip got %d: %s", w.Code, w.Body)
+	}
+	for _, query := range []string{"from=-1", "limit=x"} {
+		if w := serve(s, http.MethodGet, "/api/headers?"+query, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s got %d, want 400", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Route labels for requests not matched by an API route
+const (
+	routeStatic    = "static"    // The dashboard's file server
+	routeUnmatched = "unmatched" // No route matched the path or method
+)
+
+// HTTPMetrics receives a record of each HTTP request the API server
+// handles. metrics.BlockchainMetrics implements it.
+type HTTPMetrics interface {
+	// HTTPRequest records a request to route, the matched route template
+	// or a catch-all label, with its status and response size
+	HTTPRequest(route, method string, status int, duration time.Duration, responseBytes int)
+}
+
+// noHTTPMetrics discards HTTP request records
+type noHTTPMetrics struct{}
+
+func (noHTTPMetrics) HTTPRequest(string, string, int, time.Duration, int) {}
+
+// SetHTTPMetrics sets where HTTP request records are reported
+func (s *EnhancedBlockchainServer) SetHTTPMetrics(metrics HTTPMetrics) {
+	if metrics == nil {
+		metrics = noHTTPMetrics{}
+	}
+	s.httpMetrics = metrics
+}
+
+// instrumentRouter records every request served by router, labeled with
+// the template of the route it matches so the label set stays bounded
+func (s *EnhancedBlockchainServer) instrumentRouter(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.instrument(routeLabel(router, r), router).ServeHTTP(w, r)
+	})
+}
+
+// routeLabel returns the template of the route matching a request, or a
+// catch-all label
+func routeLabel(router *mux.Router, r *http.Request) string {
+	var match mux.RouteMatch
+	if !router.Match(r, &match) || match.MatchErr != nil || match.Route == nil {
+		return routeUnmatched
+	}
+	if name := match.Route.GetName(); name != "" {
+		return name
+	}
+	template, err := match.Route.GetPathTemplate()
+	if err != nil {
+		return routeUnmatched
+	}
+	return template
+}
+
+// methodLabel returns a request method, or "other" for nonstandard ones so
+// clients can't inflate the label set
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "other"
+}
+
+// instrument records every request handler serves under route, and traces
+// it in a span continuing any trace the client sent
+func (s *EnhancedBlockchainServer) instrument(route string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestID := ensureRequestID(w, r)
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer().Start(ctx, methodLabel(r.Method)+" "+route, trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		handler.ServeHTTP(recorder, r.WithContext(ctx))
+
+		status := recorder.statusCode()
+		span.SetAttributes(
+			attribute.String("http.method", r.Method),
+			attribute.String("http.route", route),
+			attribute.Int("http.status_code", status),
+			attribute.String("request.id", requestID),
+		)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		s.httpMetrics.HTTPRequest(route, methodLabel(r.Method), status, time.Since(start), recorder.bytes)
+	})
+}
+
+// statusRecorder captures the status and size of a response. It passes
+// hijacking through so WebSocket upgrades still work.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+// WriteHeader records the status before sending it
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the bytes of the response body
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Hijack hands the connection over, recording the switch of protocols
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Flush sends buffered data to the client when the writer supports it
+func (w *statusRecorder) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// statusCode returns the response status, 200 if none was written
+func (w *statusRecorder) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
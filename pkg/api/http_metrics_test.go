@@ -0,0 +1,79 @@
+package api
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeHTTPMetrics counts requests by route, method and status
+type fakeHTTPMetrics struct {
+	requests map[string]int
+	mutex    sync.Mutex
+}
+
+func (m *fakeHTTPMetrics) HTTPRequest(route, method string, status int, duration time.Duration, responseBytes int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.requests[fmt.Sprintf("%s %s %d", method, route, status)]++
+}
+
+// count returns how many requests were recorded under a series
+func (m *fakeHTTPMetrics) count(series string) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.requests[series]
+}
+
+// series returns a copy of the recorded counts
+func (m *fakeHTTPMetrics) series() map[string]int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return maps.Clone(m.requests)
+}
+
+func TestHTTPRequestsAreRecordedByRouteTemplate(t *testing.T) {
+	s := newTestServer(t)
+	m := &fakeHTTPMetrics{requests: make(map[string]int)}
+	s.SetHTTPMetrics(m)
+
+	serve(s, http.MethodGet, "/api/blockchain", "")
+	serve(s, http.MethodGet, "/api/blockchain", "")
+	serve(s, http.MethodGet, "/api/contracts/first", "")
+	serve(s, http.MethodGet, "/api/contracts/second", "")
+	serve(s, http.MethodGet, "/no-such-file.html", "")
+	serve(s, http.MethodPut, "/api/blockchain", "")
+
+	want := map[string]int{
+		"GET /api/blockchain 200":     2,
+		"GET /api/contracts/{id} 404": 2,
+		"GET static 404":              1,
+		"PUT static 404":              1, // Other methods fall through to the file server too
+	}
+	for series, n := range want {
+		if got := m.count(series); got != n {
+			t.Errorf("%s recorded %d times, want %d", series, got, n)
+		}
+	}
+	if series := m.series(); len(series) != len(want) {
+		t.Errorf("recorded series %v", series)
+	}
+}
+
+func TestWebSocketUpgradeIsRecorded(t *testing.T) {
+	s := newTestServer(t)
+	m := &fakeHTTPMetrics{requests: make(map[string]int)}
+	s.SetHTTPMetrics(m)
+
+	dialWebSocket(t, s).Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for m.count("GET /ws 101") != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("upgrade not recorded, got %v", m.series())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
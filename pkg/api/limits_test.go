@@ -0,0 +1,27 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestBlockchainReportsLimits(t *testing.T) {
+	limits := blockchain.BlockLimits{MaxBytes: 4096, MaxTransactions: 7}
+	chain := blockchain.NewBlockchainWithGenesis(blockchain.CreateGenesisBlock(), limits)
+	s := NewEnhancedBlockchainServer(chain, blockchain.NewTransactionPool(10), 1, metrics.NewBlockchainMetricsWithRegistry(prometheus.NewRegistry()))
+
+	var response struct {
+		Limits blockchain.BlockLimits `json:"limits"`
+	}
+	if err := json.NewDecoder(serve(s, http.MethodGet, "/api/blockchain", "").Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Limits != limits {
+		t.Fatalf("reported %+v, want %+v", response.Limits, limits)
+	}
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// listeners are the API's running HTTP and WebSocket servers
+type listeners struct {
+	api       *http.Server
+	websocket *http.Server
+	apiAddr   net.Addr
+	wsAddr    net.Addr
+	errs      chan error
+	done      chan struct{} // Closed by Shutdown
+}
+
+// tlsConfig restricts the API server to TLS 1.2 and up with forward-secret
+// AEAD cipher suites
+func tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		},
+	}
+}
+
+// Listen serves the API and the WebSocket endpoint in the background. Both
+// ports are bound before it returns, so a port in use fails here; "0" picks
+// a free one.
+func (s *EnhancedBlockchainServer) Listen(httpPort, wsPort string) error {
+	s.listenMutex.Lock()
+	defer s.listenMutex.Unlock()
+	if s.listening != nil {
+		return errors.New("API server is already listening")
+	}
+
+	apiListener, err := net.Listen("tcp", ":"+httpPort)
+	if err != nil {
+		return fmt.Errorf("failed to listen for the API: %w", err)
+	}
+	wsListener, err := net.Listen("tcp", ":"+wsPort)
+	if err != nil {
+		apiListener.Close()
+		return fmt.Errorf("failed to listen for WebSockets: %w", err)
+	}
+
+	wsMux := http.NewServeMux()
+	wsMux.Handle("/ws", s.instrument("/ws", http.HandlerFunc(s.handleWebSocketConnection)))
+	l := &listeners{
+		api:       newHTTPServer("", s.Handler()),
+		websocket: newHTTPServer("", wsMux),
+		apiAddr:   apiListener.Addr(),
+		wsAddr:    wsListener.Addr(),
+		errs:      make(chan error, 2),
+		done:      make(chan struct{}),
+	}
+	if s.enableTLS {
+		l.api.TLSConfig = tlsConfig()
+	}
+	s.listening = l
+
+	// Start broadcasting service
+	go s.handleBroadcasts(l.done)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go s.serve(&wg, l.errs, "API", l.api, apiListener)
+	go s.serve(&wg, l.errs, "WebSocket", l.websocket, wsListener)
+	go func() {
+		wg.Wait()
+		close(l.errs)
+	}()
+	return nil
+}
+
+// serve runs server on listener until it is shut down, reporting any other
+// error on errs
+func (s *EnhancedBlockchainServer) serve(wg *sync.WaitGroup, errs chan<- error, name string, server *http.Server, listener net.Listener) {
+	defer wg.Done()
+	log.Printf("%s server listening on %s\n", name, listener.Addr())
+
+	var err error
+	if s.enableTLS {
+		err = server.ServeTLS(listener, s.tlsCertFile, s.tlsKeyFile)
+	} else {
+		err = server.Serve(listener)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errs <- fmt.Errorf("%s server failed: %w", name, err)
+	}
+}
+
+// Addr returns the address the API listens on, nil before Listen
+func (s *EnhancedBlockchainServer) Addr() net.Addr {
+	s.listenMutex.Lock()
+	defer s.listenMutex.Unlock()
+	if s.listening == nil {
+		return nil
+	}
+	return s.listening.apiAddr
+}
+
+// WebSocketAddr returns the address the WebSocket endpoint listens on, nil
+// before Listen
+func (s *EnhancedBlockchainServer) WebSocketAddr() net.Addr {
+	s.listenMutex.Lock()
+	defer s.listenMutex.Unlock()
+	if s.listening == nil {
+		return nil
+	}
+	return s.listening.wsAddr
+}
+
+// Err returns a channel receiving the error that stopped a server
+// unexpectedly. It is closed once both servers stop, and nil before Listen.
+func (s *EnhancedBlockchainServer) Err() <-chan error {
+	s.listenMutex.Lock()
+	defer s.listenMutex.Unlock()
+	if s.listening == nil {
+		return nil
+	}
+	return s.listening.errs
+}
+
+// Shutdown stops both servers gracefully, the broadcasts, and disconnects
+// WebSocket clients
+func (s *EnhancedBlockchainServer) Shutdown(ctx context.Context) error {
+	s.listenMutex.Lock()
+	l := s.listening
+	s.listenMutex.Unlock()
+	if l == nil {
+		return nil
+	}
+	select {
+	case <-l.done:
+	default:
+		close(l.done)
+	}
+
+	err := errors.Join(l.api.Shutdown(ctx), l.websocket.Shutdown(ctx))
+
+	// Hijacked WebSocket connections aren't closed by Shutdown
+	s.clientsMutex.Lock()
+	for client := range s.clients {
+		client.Close()
+	}
+	s.clientsMutex.Unlock()
+	return err
+}
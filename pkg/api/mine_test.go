@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMiningInvalidBlockIsUnprocessable(t *testing.T) {
+	chain := blockchain.NewBlockchainWithGenesis(blockchain.CreateDevGenesisBlock(map[string]float64{"alice": 100}), blockchain.DefaultBlockLimits())
+	chain.SetDevMode(true)
+	// The pool doesn't check balances, so the block it fills overspends
+	pool := blockchain.NewTransactionPool(10)
+	for _, id := range []string{"1", "2"} {
+		if err := pool.AddTransaction(&blockchain.Transaction{ID: id, From: "alice", To: "bob", Value: 60}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := NewEnhancedBlockchainServer(chain, pool, 1, metrics.NewBlockchainMetricsWithRegistry(prometheus.NewRegistry()))
+
+	w := serve(s, http.MethodPost, "/api/mine", "")
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("got %d, want 422: %s", w.Code, w.Body)
+	}
+	decodeError(t, w)
+	if chain.Height() != 0 || pool.Count() != 2 {
+		t.Errorf("chain at height %d with %d pending after a rejected block", chain.Height(), pool.Count())
+	}
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/contracts"
+	"github.com/anekazek/simple-blockchain/pkg/network"
+	"github.com/anekazek/simple-blockchain/pkg/wallet"
+	"github.com/gorilla/mux"
+)
+
+// maxSignatureAge bounds how far a signed request's timestamp may be from now
+const maxSignatureAge = 5 * time.Minute
+
+// Actions covered by request signatures
+const (
+	actionDeploy            = "deploy"
+	actionDeployToken       = "deploy-token"
+	actionExecute           = "execute"
+	actionPause             = "pause"
+	actionRemove            = "remove"
+	actionResume            = "resume"
+	actionTransaction       = "transaction"
+	actionTransferOwnership = "transfer-ownership"
+)
+
+// Errors returned when a management request can't be authenticated
+var (
+	errUnauthenticated = errors.New("request must be signed by the contract owner or use the admin token")
+	errBadSignature    = errors.New("invalid request signature")
+	errStaleSignature  = errors.New("request signature timestamp is too old or in the future")
+)
+
+// RequestSignature authenticates a contract request or a transaction. The signature is an
+// ed25519 signature, hex-encoded like the public key, over
+//
+//	action|contractID|subject|timestamp
+//
+// where subject is the hex SHA-256 of the code for deploys, of the name,
+// symbol and initial supply for token deploys, of the function and params
+// for executions, of the sender, recipient, value, fee and data for
+// transactions, the new owner for ownership transfers, the reason for
+// pauses, and empty otherwise. The signer's address is derived from the
+// public key the same way node IDs are.
+type RequestSignature struct {
+	PublicKey string `json:"publicKey,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"` // Unix seconds
+}
+
+// signed reports whether the request carries a signature
+func (sig RequestSignature) signed() bool {
+	return sig.PublicKey != "" || sig.Signature != ""
+}
+
+// signingBytes returns the canonical bytes covered by the signature
+func (sig RequestSignature) signingBytes(action, contractID, subject string) []byte {
+	return wallet.SigningBytes(action, contractID, subject, sig.Timestamp)
+}
+
+// verify checks the signature and returns the signer's address
+func (sig RequestSignature) verify(action, contractID, subject string) (string, error) {
+	age := time.Since(time.Unix(sig.Timestamp, 0))
+	if age > maxSignatureAge || age < -maxSignatureAge {
+		return "", errStaleSignature
+	}
+
+	publicKey, err := hex.DecodeString(sig.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return "", errBadSignature
+	}
+	signature, err := hex.DecodeString(sig.Signature)
+	if err != nil || !ed25519.Verify(publicKey, sig.signingBytes(action, contractID, subject), signature) {
+		return "", errBadSignature
+	}
+	return network.NodeIDFromPublicKey(publicKey), nil
+}
+
+// isAddress reports whether s has the form of an address: 20 hex-encoded bytes
+func isAddress(s string) bool {
+	decoded, err := hex.DecodeString(s)
+	return err == nil && len(decoded) == 20
+}
+
+// SetAdminToken sets the bearer token that lets a request manage every
+// contract. An empty token disables admin access.
+func (s *EnhancedBlockchainServer) SetAdminToken(token string) {
+	s.adminToken = token
+}
+
+// isAdmin reports whether the request carries the admin bearer token
+func (s *EnhancedBlockchainServer) isAdmin(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && s.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1
+}
+
+// manager identifies who requests a management operation, from the admin
+// token or the request signature
+func (s *EnhancedBlockchainServer) manager(r *http.Request, sig RequestSignature, action, contractID, subject string) (contracts.Manager, error) {
+	manager := contracts.Manager{Admin: s.isAdmin(r)}
+	if !sig.signed() {
+		if !manager.Admin {
+			return manager, errUnauthenticated
+		}
+		return manager, nil
+	}
+
+	address, err := sig.verify(action, contractID, subject)
+	if err != nil {
+		return manager, err
+	}
+	manager.Address = address
+	return manager, nil
+}
+
+// respondWithManagementError writes the response for a failed management operation
+func respondWithManagementError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errUnauthenticated), errors.Is(err, errBadSignature), errors.Is(err, errStaleSignature):
+		respondWithErrorCode(w, http.StatusUnauthorized, "unauthenticated", err.Error())
+	case errors.Is(err, contracts.ErrNotOwner):
+		respondWithErrorCode(w, http.StatusForbidden, "not_owner", err.Error())
+	case errors.Is(err, contracts.ErrContractNotFound):
+		respondWithError(w, http.StatusNotFound, "contract not found")
+	default:
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// handleRemoveContract removes a contract on behalf of its owner or an admin
+func (s *EnhancedBlockchainServer) handleRemoveContract(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	// Admins may send no body at all
+	var sig RequestSignature
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&sig); err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid removal request")
+			return
+		}
+	}
+
+	manager, err := s.manager(r, sig, actionRemove, id, "")
+	if err != nil {
+		respondWithManagementError(w, err)
+		return
+	}
+
+	if err := s.registry.RemoveContract(manager, id); err != nil {
+		respondWithManagementError(w, err)
+		return
+	}
+
+	jsonResponse(w, map[string]interface{}{"id": id, "status": "removed"})
+}
+
+// handleTransferOwnership hands a contract to a new owner
+func (s *EnhancedBlockchainServer) handleTransferOwnership(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var request struct {
+		NewOwner string `json:"newOwner"`
+		RequestSignature
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid ownership transfer request")
+		return
+	}
+	if !isAddress(request.NewOwner) {
+		respondWithError(w, http.StatusBadRequest, "newOwner must be a 40 character hex address")
+		return
+	}
+
+	manager, err := s.manager(r, request.RequestSignature, actionTransferOwnership, id, request.NewOwner)
+	if err != nil {
+		respondWithManagementError(w, err)
+		return
+	}
+
+	if err := s.registry.TransferOwnership(manager, id, request.NewOwner); err != nil {
+		respondWithManagementError(w, err)
+		return
+	}
+
+	jsonResponse(w, map[string]interface{}{"id": id, "owner": request.NewOwner})
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/contracts"
+	"github.com/anekazek/simple-blockchain/pkg/wallet"
+)
+
+// signedBody encodes a request body carrying fields and a signature
+func signedBody(fields map[string]interface{}, sig wallet.Signature) string {
+	body := map[string]interface{}{"publicKey": sig.PublicKey, "signature": sig.Signature, "timestamp": sig.Timestamp}
+	for key, value := range fields {
+		body[key] = value
+	}
+	encoded, _ := json.Marshal(body)
+	return string(encoded)
+}
+
+// deployOwned deploys a Lua contract signed by owner and returns its ID
+func deployOwned(t *testing.T, s *EnhancedBlockchainServer, owner *wallet.Wallet) string {
+	t.Helper()
+	code := "function get() return 1 end"
+	sig := owner.Sign(actionDeploy, "", wallet.CodeHash(code), time.Now())
+	w := serve(s, http.MethodPost, "/api/contracts", signedBody(map[string]interface{}{"type": contracts.TypeLua, "name": "owned", "code": code}, sig))
+	var deployed struct {
+		ID    string `json:"id"`
+		Owner string `json:"owner"`
+	}
+	if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&deployed) != nil || deployed.Owner != owner.Address() {
+		t.Fatalf("deploy got status %d: %s", w.Code, w.Body)
+	}
+	return deployed.ID
+}
+
+// removeAs asks to remove a contract with a request signed by signer
+func removeAs(s *EnhancedBlockchainServer, signer *wallet.Wallet, id string) int {
+	sig := signer.Sign(actionRemove, id, "", time.Now())
+	return serve(s, http.MethodDelete, "/api/contracts/"+id, signedBody(nil, sig)).Code
+}
+
+func TestOnlyOwnerOrAdminManagesContract(t *testing.T) {
+	s := newTestServer(t)
+	s.SetAdminToken("secret")
+	owner, stranger := wallet.FromSeed("owner", 0), wallet.FromSeed("stranger", 0)
+
+	id := deployOwned(t, s, owner)
+	if code := removeAs(s, stranger, id); code != http.StatusForbidden {
+		t.Fatalf("stranger's removal answered %d, want 403", code)
+	}
+	if code := serve(s, http.MethodDelete, "/api/contracts/"+id, "").Code; code != http.StatusUnauthorized {
+		t.Fatalf("unsigned removal answered %d, want 401", code)
+	}
+	if code := removeAs(s, owner, id); code != http.StatusOK {
+		t.Fatalf("owner's removal answered %d", code)
+	}
+
+	id = deployOwned(t, s, owner)
+	req := httptest.NewRequest(http.MethodDelete, "/api/contracts/"+id, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("admin removal answered %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestTransferredOwnershipLocksOutOldOwner(t *testing.T) {
+	s := newTestServer(t)
+	owner, buyer := wallet.FromSeed("owner", 0), wallet.FromSeed("buyer", 0)
+	id := deployOwned(t, s, owner)
+
+	sig := owner.Sign(actionTransferOwnership, id, buyer.Address(), time.Now())
+	w := serve(s, http.MethodPost, "/api/contracts/"+id+"/transfer-ownership", signedBody(map[string]interface{}{"newOwner": buyer.Address()}, sig))
+	if w.Code != http.StatusOK {
+		t.Fatalf("transfer answered %d: %s", w.Code, w.Body)
+	}
+
+	// A signature over another new owner doesn't authorize this one
+	forged := owner.Sign(actionTransferOwnership, id, owner.Address(), time.Now())
+	w = serve(s, http.MethodPost, "/api/contracts/"+id+"/transfer-ownership", signedBody(map[string]interface{}{"newOwner": strings.Repeat("0", 40)}, forged))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("mismatched signature answered %d, want 401", w.Code)
+	}
+
+	if code := removeAs(s, owner, id); code != http.StatusForbidden {
+		t.Fatalf("old owner's removal answered %d, want 403", code)
+	}
+	if code := removeAs(s, buyer, id); code != http.StatusOK {
+		t.Fatalf("new owner's removal answered %d", code)
+	}
+}
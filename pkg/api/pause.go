@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/contracts"
+	"github.com/gorilla/mux"
+)
+
+// pausedResponse is the error envelope for executions of a paused contract
+type pausedResponse struct {
+	ErrorResponse
+	Reason   string    `json:"reason,omitempty"`
+	PausedAt time.Time `json:"pausedAt"`
+}
+
+// respondWithPaused writes the 423 Locked response for a paused contract
+func respondWithPaused(w http.ResponseWriter, id string, pause *contracts.Pause) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusLocked)
+	json.NewEncoder(w).Encode(pausedResponse{
+		ErrorResponse: ErrorResponse{Error: "contract " + id + " is paused", Status: http.StatusLocked, Code: contracts.CodePaused},
+		Reason:        pause.Reason,
+		PausedAt:      pause.PausedAt,
+	})
+}
+
+// handlePauseContract suspends a contract's executions on behalf of its
+// owner or an admin
+func (s *EnhancedBlockchainServer) handlePauseContract(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	// Admins may send no body at all
+	var request struct {
+		Reason string `json:"reason"`
+		RequestSignature
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid pause request")
+			return
+		}
+	}
+
+	manager, err := s.manager(r, request.RequestSignature, actionPause, id, request.Reason)
+	if err != nil {
+		respondWithManagementError(w, err)
+		return
+	}
+
+	pause, err := s.registry.PauseContract(manager, id, request.Reason)
+	if err != nil {
+		respondWithManagementError(w, err)
+		return
+	}
+
+	s.broadcastContractLifecycle("contract_paused", id)
+	jsonResponse(w, map[string]interface{}{"id": id, "status": contracts.StatusPaused, "pause": pause})
+}
+
+// handleResumeContract lets a paused contract execute again on behalf of
+// its owner or an admin
+func (s *EnhancedBlockchainServer) handleResumeContract(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	// Admins may send no body at all
+	var sig RequestSignature
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&sig); err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid resume request")
+			return
+		}
+	}
+
+	manager, err := s.manager(r, sig, actionResume, id, "")
+	if err != nil {
+		respondWithManagementError(w, err)
+		return
+	}
+
+	if err := s.registry.ResumeContract(manager, id); err != nil {
+		respondWithManagementError(w, err)
+		return
+	}
+
+	s.broadcastContractLifecycle("contract_resumed", id)
+	jsonResponse(w, map[string]interface{}{"id": id, "status": contracts.StatusActive})
+}
+
+// broadcastContractLifecycle notifies all clients that a contract changed
+// status, with its updated description
+func (s *EnhancedBlockchainServer) broadcastContractLifecycle(eventType, id string) {
+	info, err := s.registry.GetContract(id)
+	if err != nil {
+		return
+	}
+	s.publish(map[string]interface{}{
+		"type":     eventType,
+		"contract": info,
+	})
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/contracts"
+	"github.com/anekazek/simple-blockchain/pkg/wallet"
+)
+
+func TestPausedContractRefusesCallsUntilResumed(t *testing.T) {
+	s := newTestServer(t)
+	owner, stranger := wallet.FromSeed("owner", 0), wallet.FromSeed("stranger", 0)
+	id := deployOwned(t, s, owner)
+	conn := dialWebSocket(t, s)
+
+	pause := func(signer *wallet.Wallet) int {
+		sig := signer.Sign(actionPause, id, "audit", time.Now())
+		return serve(s, http.MethodPost, "/api/contracts/"+id+"/pause", signedBody(map[string]interface{}{"reason": "audit"}, sig)).Code
+	}
+	if code := pause(stranger); code != http.StatusForbidden {
+		t.Fatalf("stranger's pause answered %d, want 403", code)
+	}
+	if code := pause(owner); code != http.StatusOK {
+		t.Fatalf("owner's pause answered %d", code)
+	}
+	if message := readMessage(t, conn, "contract_paused"); message["contract"].(map[string]interface{})["status"] != contracts.StatusPaused {
+		t.Fatalf("paused event %v", message)
+	}
+
+	for _, endpoint := range []string{"execute", "simulate"} {
+		w := serve(s, http.MethodPost, "/api/contracts/"+id+"/"+endpoint, `{"function": "get"}`)
+		var response pausedResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusLocked || response.Reason != "audit" || response.PausedAt.IsZero() {
+			t.Fatalf("%s on a paused contract answered %d %+v", endpoint, w.Code, response)
+		}
+	}
+	if code, _ := getABI(t, s, id); code != http.StatusOK {
+		t.Fatalf("abi of a paused contract answered %d", code)
+	}
+
+	sig := owner.Sign(actionResume, id, "", time.Now())
+	if w := serve(s, http.MethodPost, "/api/contracts/"+id+"/resume", signedBody(nil, sig)); w.Code != http.StatusOK {
+		t.Fatalf("resume answered %d: %s", w.Code, w.Body)
+	}
+	readMessage(t, conn, "contract_resumed")
+	if w := serve(s, http.MethodPost, "/api/contracts/"+id+"/execute", `{"function": "get"}`); w.Code != http.StatusOK {
+		t.Fatalf("execute after resuming answered %d: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/metrics"
+	"github.com/anekazek/simple-blockchain/pkg/node/lifecycle"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newTestServer returns an API server on a fresh chain, recording metrics
+// to a registry of its own
+func newTestServer(t *testing.T) *EnhancedBlockchainServer {
+	t.Helper()
+	chain := blockchain.NewBlockchain()
+	pool := blockchain.NewTransactionPool(100)
+	pool.SetBalances(chain)
+	return NewEnhancedBlockchainServer(chain, pool, 1, metrics.NewBlockchainMetricsWithRegistry(prometheus.NewRegistry()))
+}
+
+// serve sends a request to the server's API handler
+func serve(s *EnhancedBlockchainServer, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	return w
+}
+
+// dialWebSocket starts the server listening on free ports and connects a
+// WebSocket client, returning it once the initial stats arrived
+func dialWebSocket(t *testing.T, s *EnhancedBlockchainServer) *websocket.Conn {
+	t.Helper()
+	if s.Addr() == nil {
+		if err := s.Listen("0", "0"); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Shutdown(context.Background()) })
+	}
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+s.WebSocketAddr().String()+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	readMessage(t, conn, "stats")
+	return conn
+}
+
+// readMessage reads WebSocket messages until one of the given type arrives
+func readMessage(t *testing.T, conn *websocket.Conn, messageType string) map[string]interface{} {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var message map[string]interface{}
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("waiting for a %s message: %v", messageType, err)
+		}
+		if message["type"] == messageType {
+			return message
+		}
+	}
+}
+
+// blockingLoader reports progress and then waits to be released
+type blockingLoader struct {
+	loaded, target int
+	release        chan struct{}
+}
+
+func (l *blockingLoader) Load(progress lifecycle.ProgressFunc) error {
+	progress(l.loaded, l.target)
+	<-l.release
+	return nil
+}
+
+// readyStatus decodes the body of /api/ready
+func readyStatus(t *testing.T, s *EnhancedBlockchainServer) (int, lifecycle.Status) {
+	t.Helper()
+	w := serve(s, http.MethodGet, "/api/ready", "")
+	var status lifecycle.Status
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, status
+}
+
+func TestAPIRejectsTrafficUntilReady(t *testing.T) {
+	s := newTestServer(t)
+	state := lifecycle.NewStateMachine()
+	s.SetNodeState(state)
+
+	for _, next := range []lifecycle.State{lifecycle.StateLoading, lifecycle.StateSyncing} {
+		w := serve(s, http.MethodGet, "/api/blockchain", "")
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("%s: read got status %d", state.Status().State, w.Code)
+		}
+		var body struct {
+			Node lifecycle.Status `json:"node"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body.Node.State != state.Status().State {
+			t.Fatalf("%s: read body %q doesn't report the state", state.Status().State, w.Body)
+		}
+
+		w = serve(s, http.MethodPost, "/api/transactions", `{"to":"bob","value":0}`)
+		if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != notReadyRetryAfter {
+			t.Fatalf("%s: write got status %d, Retry-After %q", state.Status().State, w.Code, w.Header().Get("Retry-After"))
+		}
+
+		if code, _ := readyStatus(t, s); code != http.StatusServiceUnavailable {
+			t.Fatalf("%s: /api/ready answered %d", state.Status().State, code)
+		}
+		if w := serve(s, http.MethodGet, "/api/stats", ""); w.Code != http.StatusOK {
+			t.Fatalf("%s: /api/stats answered %d", state.Status().State, w.Code)
+		}
+
+		if err := state.Transition(next); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := state.Transition(lifecycle.StateReady); err != nil {
+		t.Fatal(err)
+	}
+	if code, status := readyStatus(t, s); code != http.StatusOK || status.State != lifecycle.StateReady {
+		t.Fatalf("/api/ready answered %d with %s", code, status.State)
+	}
+	if w := serve(s, http.MethodGet, "/api/blockchain", ""); w.Code != http.StatusOK {
+		t.Fatalf("read answered %d once ready", w.Code)
+	}
+}
+
+func TestReadyReportsLoadingProgress(t *testing.T) {
+	s := newTestServer(t)
+	state := lifecycle.NewStateMachine()
+	s.SetNodeState(state)
+
+	loader := &blockingLoader{loaded: 3, target: 10, release: make(chan struct{})}
+	done := make(chan error)
+	go func() { done <- state.Bootstrap(context.Background(), loader, nil) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		code, status := readyStatus(t, s)
+		if status.State == lifecycle.StateLoading && status.BlocksLoaded == 3 {
+			if code != http.StatusServiceUnavailable || status.TargetHeight != 10 {
+				t.Fatalf("got %d with %+v", code, status)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("progress never reported, last %+v", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(loader.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := readyStatus(t, s); code != http.StatusOK {
+		t.Fatalf("/api/ready answered %d after bootstrap", code)
+	}
+}
+
+func TestNodeStateTransitionsReachWebSocketClients(t *testing.T) {
+	s := newTestServer(t)
+	state := lifecycle.NewStateMachine()
+	s.SetNodeState(state)
+	conn := dialWebSocket(t, s)
+
+	for _, next := range []lifecycle.State{lifecycle.StateLoading, lifecycle.StateSyncing, lifecycle.StateReady} {
+		if err := state.Transition(next); err != nil {
+			t.Fatal(err)
+		}
+		message := readMessage(t, conn, "node_state")
+		status, _ := message["status"].(map[string]interface{})
+		if status["state"] != string(next) {
+			t.Fatalf("pushed %v, want state %s", message, next)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package api
+
+import "net/http"
+
+// handleGetReorgs returns the recent reorganizations of the chain, most
+// recent first, with the transactions each left off the chain
+func (s *EnhancedBlockchainServer) handleGetReorgs(w http.ResponseWriter, r *http.Request) {
+	negotiatedResponse(w, r, map[string]interface{}{"reorgs": s.chain.Forks().Reorgs()})
+}
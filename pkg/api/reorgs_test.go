@@ -0,0 +1,44 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+func TestGetReorgs(t *testing.T) {
+	s := newTestServer(t)
+	abandoned, err := s.chain.AddBlock(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A transaction makes the winning branch's first block differ from ours
+	winner, pool := blockchain.NewBlockchain(), blockchain.NewTransactionPool(1)
+	if err := pool.AddTransaction(&blockchain.Transaction{ID: "1", To: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := winner.AddBlock(pool, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !s.chain.ReplaceChain(winner.GetBlocks()) {
+		t.Fatal("winning chain wasn't accepted")
+	}
+
+	w := serve(s, http.MethodGet, "/api/blockchain/reorgs", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		Reorgs []blockchain.Reorg `json:"reorgs"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Reorgs) != 1 || body.Reorgs[0].OldTipHash != abandoned.Hash || body.Reorgs[0].NewTipHeight != 2 {
+		t.Fatalf("got %+v", body.Reorgs)
+	}
+}
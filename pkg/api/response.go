@@ -0,0 +1,27 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ErrorResponse is the JSON envelope returned by every API error path
+type ErrorResponse struct {
+	Error  string `json:"error"`
+	Status int    `json:"status"`
+	Code   string `json:"code,omitempty"` // Machine-readable reason, where one applies
+}
+
+// respondWithError writes an error envelope with the given status code
+func respondWithError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: message, Status: code})
+}
+
+// respondWithErrorCode writes an error envelope carrying a machine-readable code
+func respondWithErrorCode(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: message, Status: status, Code: code})
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// rollbackResult is the rollback endpoint's reply
+type rollbackResult struct {
+	Height  int                `json:"height"`
+	Removed []blockchain.Block `json:"removed"`
+}
+
+// handleRollback truncates the chain to the requested height and puts the
+// transactions of the removed blocks back in the pool; admin only
+func (s *EnhancedBlockchainServer) handleRollback(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		respondWithErrorCode(w, http.StatusUnauthorized, "unauthenticated", "rolling back requires the admin token")
+		return
+	}
+
+	var request struct {
+		Height *int `json:"height"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Height == nil {
+		respondWithError(w, http.StatusBadRequest, "request must name the height to roll back to")
+		return
+	}
+
+	removed, err := s.chain.RollbackToHeight(*request.Height, s.txPool)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if removed == nil {
+		removed = []blockchain.Block{}
+	}
+	jsonResponse(w, rollbackResult{Height: *request.Height, Removed: removed})
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// rollback posts a rollback request with the given token
+func rollback(s *EnhancedBlockchainServer, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/rollback", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func TestRollbackEndpoint(t *testing.T) {
+	s := newTestServer(t)
+	s.SetAdminToken("secret")
+	for i := 0; i < 3; i++ {
+		if _, err := s.chain.AddBlock(nil, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, token := range []string{"", "wrong"} {
+		if w := rollback(s, token, `{"height":1}`); w.Code != http.StatusUnauthorized {
+			t.Fatalf("token %q: status %d, want 401", token, w.Code)
+		}
+	}
+	if s.chain.Height() != 3 {
+		t.Fatal("unauthenticated rollback changed the chain")
+	}
+
+	w := rollback(s, "secret", `{"height":1}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var result rollbackResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Height != 1 || len(result.Removed) != 2 || s.chain.Height() != 1 {
+		t.Fatalf("rolled back to %d removing %d blocks; chain at %d", result.Height, len(result.Removed), s.chain.Height())
+	}
+
+	for _, body := range []string{`{"height":-1}`, `{}`, `{"height":5}`} {
+		if w := rollback(s, "secret", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", body, w.Code)
+		}
+	}
+}
+
+func TestRollbackNeedsConfiguredToken(t *testing.T) {
+	s := newTestServer(t)
+	if w := rollback(s, "anything", `{"height":0}`); w.Code != http.StatusUnauthorized {
+		t.Fatalf("status %d without an admin token configured, want 401", w.Code)
+	}
+}
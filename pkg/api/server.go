@@ -2,9 +2,13 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/anekazek/simple-blockchain/pkg/blockchain"
 )
@@ -26,11 +30,12 @@ func NewBlockchainServer(chain *blockchain.Chain, difficulty int) *BlockchainSer
 // Start initializes the HTTP server and routes
 func (s *BlockchainServer) Start(port string) error {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/", s.handleGetBlockchain)
-	mux.HandleFunc("/write", s.handleWriteBlock)
+	timeouts := DefaultTimeoutConfig()
+	mux.HandleFunc("/", withTimeout(timeouts.Read, s.handleGetBlockchain))
+	mux.HandleFunc("/write", withTimeout(timeouts.Execute, s.handleWriteBlock))
 
 	log.Printf("Server listening on port %s\n", port)
-	return http.ListenAndServe(":"+port, mux)
+	return newHTTPServer(":"+port, mux).ListenAndServe()
 }
 
 // handleGetBlockchain returns the entire blockchain
@@ -43,22 +48,48 @@ func (s *BlockchainServer) handleGetBlockchain(w http.ResponseWriter, r *http.Re
 	io.WriteString(w, string(bytes))
 }
 
+// maxWriteBodyBytes caps the size of a /write request body
+const maxWriteBodyBytes = 1 << 20
+
 // handleWriteBlock adds a new block to the blockchain
 func (s *BlockchainServer) handleWriteBlock(w http.ResponseWriter, r *http.Request) {
 	var data struct {
 		Data string `json:"data"`
 	}
 
+	r.Body = http.MaxBytesReader(w, r.Body, maxWriteBodyBytes)
+	defer r.Body.Close()
+
 	decoder := json.NewDecoder(r.Body)
 	if err := decoder.Decode(&data); err != nil {
-		respondWithJSON(w, r, http.StatusBadRequest, r.Body)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
 		return
 	}
-	defer r.Body.Close()
 
-	newBlock, err := s.chain.AddBlock(data.Data, s.difficulty)
+	if strings.TrimSpace(data.Data) == "" {
+		respondWithError(w, http.StatusUnprocessableEntity, "data must not be empty")
+		return
+	}
+
+	// The data is carried by a transaction of its own
+	now := time.Now()
+	tx := blockchain.Transaction{ID: fmt.Sprintf("data-%d", now.UnixNano()), Data: data.Data, Timestamp: now}
+	newBlock, err := s.chain.AddTransactionsContext(r.Context(), []blockchain.Transaction{tx}, s.difficulty)
+	if errors.Is(err, blockchain.ErrBlockTooLarge) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	if errors.Is(err, blockchain.ErrInvalidBlock) {
+		respondWithError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
 	if err != nil {
-		respondWithJSON(w, r, http.StatusInternalServerError, struct{ Error string }{Error: err.Error()})
+		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -69,10 +100,10 @@ func (s *BlockchainServer) handleWriteBlock(w http.ResponseWriter, r *http.Reque
 func respondWithJSON(w http.ResponseWriter, r *http.Request, code int, payload interface{}) {
 	response, err := json.MarshalIndent(payload, "", "  ")
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("HTTP 500: Internal Server Error"))
+		respondWithError(w, http.StatusInternalServerError, "failed to encode response")
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
 	w.Write(response)
 }
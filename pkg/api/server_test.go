@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// postWrite sends a /write request to server and returns the recorded response
+func postWrite(server *BlockchainServer, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/write", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	server.handleWriteBlock(w, req)
+	return w
+}
+
+// decodeError decodes an error envelope, failing the test if there is none
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) ErrorResponse {
+	t.Helper()
+	var envelope ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&envelope); err != nil || envelope.Error == "" {
+		t.Fatalf("response %q isn't an error envelope", w.Body.String())
+	}
+	if envelope.Status != w.Code {
+		t.Fatalf("envelope status %d, response status %d", envelope.Status, w.Code)
+	}
+	return envelope
+}
+
+func TestWriteBlockCreatesBlockOnTip(t *testing.T) {
+	chain := blockchain.NewBlockchain()
+	w := postWrite(NewBlockchainServer(chain, 1), `{"data":"hello"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+
+	var block blockchain.Block
+	if err := json.NewDecoder(w.Body).Decode(&block); err != nil {
+		t.Fatal(err)
+	}
+	if tip := chain.GetLatestBlock(); tip.Hash != block.Hash {
+		t.Fatalf("returned block %s, tip is %s", block.Hash, tip.Hash)
+	}
+}
+
+func TestWriteBlockRejectsRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits blockchain.BlockLimits
+		body   string
+		status int
+	}{
+		{"malformed JSON", blockchain.DefaultBlockLimits(), `{"data":`, http.StatusBadRequest},
+		{"wrong type", blockchain.DefaultBlockLimits(), `{"data":1}`, http.StatusBadRequest},
+		{"empty data", blockchain.DefaultBlockLimits(), `{"data":"  "}`, http.StatusUnprocessableEntity},
+		{"oversized body", blockchain.DefaultBlockLimits(), `{"data":"` + strings.Repeat("a", maxWriteBodyBytes) + `"}`, http.StatusRequestEntityTooLarge},
+		{"oversized block", blockchain.BlockLimits{MaxBytes: 1024}, `{"data":"` + strings.Repeat("a", 2048) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := blockchain.NewBlockchainWithGenesis(blockchain.CreateGenesisBlock(), tt.limits)
+			w := postWrite(NewBlockchainServer(chain, 0), tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			decodeError(t, w)
+			if height := chain.Height(); height != 0 {
+				t.Fatalf("chain grew to height %d", height)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/contracts"
+)
+
+// nameContract stores a name and announces the change
+const nameContract = `
+function set(name)
+	storage_set("name", name)
+	emit("Renamed", {name = name})
+	return name
+end
+
+function get()
+	return storage_get("name") or ""
+end
+`
+
+// callContract executes or simulates a function and decodes the result
+func callContract(t *testing.T, s *EnhancedBlockchainServer, id, endpoint, body string) contracts.ExecutionResult {
+	t.Helper()
+	w := serve(s, http.MethodPost, "/api/contracts/"+id+"/"+endpoint, body)
+	var result contracts.ExecutionResult
+	if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&result) != nil {
+		t.Fatalf("%s got status %d: %s", endpoint, w.Code, w.Body)
+	}
+	return result
+}
+
+func TestSimulateReportsChangesWithoutCommitting(t *testing.T) {
+	s := newTestServer(t)
+	id := deployLua(t, s, nameContract)
+	callContract(t, s, id, "execute", `{"function": "set", "params": ["alice"]}`)
+
+	set := `{"function": "set", "params": ["bob"]}`
+	preview := callContract(t, s, id, "simulate", set)
+	if preview.Value != "bob" || len(preview.Events) != 1 || preview.GasUsed == 0 {
+		t.Fatalf("simulation returned %+v", preview)
+	}
+	if diff := preview.StateDiff; len(diff) != 1 || diff[0].Key != "name" || diff[0].Old == nil || *diff[0].Old != "alice" || diff[0].New != "bob" {
+		t.Fatalf("simulation reported diff %+v", diff)
+	}
+	if got := callContract(t, s, id, "execute", `{"function": "get"}`).Value; got != "alice" {
+		t.Fatalf("after simulating, stored name is %v", got)
+	}
+
+	executed := callContract(t, s, id, "execute", set)
+	if executed.GasUsed != preview.GasUsed {
+		t.Errorf("execution used %d gas, simulation estimated %d", executed.GasUsed, preview.GasUsed)
+	}
+	if got := callContract(t, s, id, "execute", `{"function": "get"}`).Value; got != "bob" {
+		t.Fatalf("after executing, stored name is %v", got)
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/contracts"
+)
+
+// tokenHash returns the hex SHA-256 signed for a token deploy: of its name,
+// symbol and initial supply joined by "|"
+func tokenHash(config contracts.TokenConfig) string {
+	sum := sha256.Sum256([]byte(config.Name + "|" + config.Symbol + "|" + strconv.FormatUint(config.InitialSupply, 10)))
+	return hex.EncodeToString(sum[:])
+}
+
+// handleDeployToken deploys an instance of the standard token contract,
+// owned by the signer or, for admins, by the named owner, and mints its
+// initial supply to the owner
+func (s *EnhancedBlockchainServer) handleDeployToken(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		contracts.TokenConfig
+		Owner string `json:"owner"` // Admin deploys only; signed deploys are owned by the signer
+		RequestSignature
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid token request")
+		return
+	}
+
+	// A token needs an owner to mint, so the request must name one
+	owner := ""
+	switch {
+	case request.Owner != "":
+		if !s.isAdmin(r) {
+			respondWithErrorCode(w, http.StatusForbidden, "not_owner", "only admins may deploy tokens for another owner")
+			return
+		}
+		if !isAddress(request.Owner) {
+			respondWithError(w, http.StatusBadRequest, "owner must be a 40 character hex address")
+			return
+		}
+		owner = request.Owner
+	case request.signed():
+		address, err := request.verify(actionDeployToken, "", tokenHash(request.TokenConfig))
+		if err != nil {
+			respondWithErrorCode(w, http.StatusUnauthorized, "unauthenticated", err.Error())
+			return
+		}
+		owner = address
+	default:
+		respondWithErrorCode(w, http.StatusUnauthorized, "unauthenticated", "token deploys must be signed by the owner")
+		return
+	}
+
+	contractID := fmt.Sprintf("contract-%d", time.Now().UnixNano())
+	err := s.registry.DeployToken(r.Context(), contractID, owner, request.TokenConfig)
+	if errors.Is(err, contracts.ErrInvalidTokenConfig) {
+		respondWithErrorCode(w, http.StatusBadRequest, "invalid_token", err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if info, err := s.registry.GetContract(contractID); err == nil {
+		s.broadcastContractDeployed(info)
+	}
+
+	jsonResponse(w, map[string]interface{}{
+		"id":            contractID,
+		"owner":         owner,
+		"name":          request.Name,
+		"symbol":        request.Symbol,
+		"initialSupply": request.InitialSupply,
+		"status":        "deployed",
+	})
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Server hardening limits applied to every HTTP server
+const (
+	serverReadHeaderTimeout = 5 * time.Second
+	serverReadTimeout       = 15 * time.Second
+	serverWriteTimeout      = 2 * time.Minute
+	serverIdleTimeout       = 60 * time.Second
+	serverMaxHeaderBytes    = 1 << 20
+)
+
+// TimeoutConfig holds the handler deadlines for each route group
+type TimeoutConfig struct {
+	// Read bounds query endpoints
+	Read time.Duration
+	// Write bounds endpoints that submit or deploy
+	Write time.Duration
+	// Execute bounds long-running work such as mining and contract execution
+	Execute time.Duration
+	// Contract bounds a single contract call, which is cancelled once it passes
+	Contract time.Duration
+}
+
+// DefaultTimeoutConfig returns the default per-group handler deadlines
+func DefaultTimeoutConfig() TimeoutConfig {
+	return TimeoutConfig{
+		Read:     10 * time.Second,
+		Write:    15 * time.Second,
+		Execute:  60 * time.Second,
+		Contract: 30 * time.Second,
+	}
+}
+
+// newHTTPServer creates an http.Server with the hardening limits applied
+func newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: serverReadHeaderTimeout,
+		ReadTimeout:       serverReadTimeout,
+		WriteTimeout:      serverWriteTimeout,
+		IdleTimeout:       serverIdleTimeout,
+		MaxHeaderBytes:    serverMaxHeaderBytes,
+	}
+}
+
+// withTimeout runs a handler with a context deadline. If the deadline passes
+// first, the client receives a 503 error envelope and anything the handler
+// writes afterwards is discarded. The handler observes cancellation through
+// the request context.
+func withTimeout(timeout time.Duration, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if timeout <= 0 {
+			handler(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		tw := &timeoutWriter{ctx: ctx, header: make(http.Header)}
+		done := make(chan struct{})
+		panicChan := make(chan interface{}, 1)
+
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicChan <- p
+				}
+			}()
+			handler(tw, r.WithContext(ctx))
+			close(done)
+		}()
+
+		select {
+		case p := <-panicChan:
+			panic(p)
+		case <-done:
+			tw.mutex.Lock()
+			defer tw.mutex.Unlock()
+			if tw.expired() {
+				respondWithError(w, http.StatusServiceUnavailable, "request timed out")
+				return
+			}
+			dst := w.Header()
+			for k, v := range tw.header {
+				dst[k] = v
+			}
+			if tw.code == 0 {
+				tw.code = http.StatusOK
+			}
+			w.WriteHeader(tw.code)
+			w.Write(tw.buf.Bytes())
+		case <-ctx.Done():
+			tw.mutex.Lock()
+			defer tw.mutex.Unlock()
+			tw.timedOut = true
+			respondWithError(w, http.StatusServiceUnavailable, "request timed out")
+		}
+	}
+}
+
+// timeoutWriter buffers a handler's response until it completes in time
+type timeoutWriter struct {
+	ctx      context.Context
+	header   http.Header
+	buf      bytes.Buffer
+	code     int
+	timedOut bool
+	mutex    sync.Mutex
+}
+
+// expired reports whether the request's deadline passed, which a handler
+// may notice before withTimeout does. The caller holds the mutex.
+func (tw *timeoutWriter) expired() bool {
+	return tw.timedOut || tw.ctx.Err() != nil
+}
+
+// Header returns the buffered response headers
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+// Write buffers the response body, failing once the request has timed out
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if tw.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	return tw.buf.Write(p)
+}
+
+// WriteHeader records the response status code
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if tw.expired() || tw.code != 0 {
+		return
+	}
+	tw.code = code
+}
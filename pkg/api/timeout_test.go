@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/contracts"
+	"github.com/anekazek/simple-blockchain/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// slowHandler blocks until its request is cancelled, reporting the
+// cancellation and the result of writing afterwards
+type slowHandler struct {
+	cancelled chan error
+	written   chan error
+}
+
+func newSlowHandler() *slowHandler {
+	return &slowHandler{cancelled: make(chan error, 1), written: make(chan error, 1)}
+}
+
+func (h *slowHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	<-r.Context().Done()
+	h.cancelled <- r.Context().Err()
+	_, err := w.Write([]byte("too late"))
+	h.written <- err
+}
+
+// receive waits for a value from ch or fails the test
+func receive(t *testing.T, ch chan error, what string) error {
+	t.Helper()
+	select {
+	case err := <-ch:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatalf("handler never %s", what)
+		return nil
+	}
+}
+
+func TestWithTimeoutCancelsSlowHandler(t *testing.T) {
+	slow := newSlowHandler()
+	w := httptest.NewRecorder()
+	withTimeout(20*time.Millisecond, slow.ServeHTTP)(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("got status %d, want 503", w.Code)
+	}
+	if envelope := decodeError(t, w); envelope.Error != "request timed out" {
+		t.Fatalf("got error %q", envelope.Error)
+	}
+	if err := receive(t, slow.cancelled, "saw the deadline"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("handler context ended with %v, want DeadlineExceeded", err)
+	}
+	if err := receive(t, slow.written, "wrote"); !errors.Is(err, http.ErrHandlerTimeout) {
+		t.Fatalf("late write returned %v, want ErrHandlerTimeout", err)
+	}
+}
+
+func TestWithTimeoutPassesFastResponseThrough(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Error("handler runs without a deadline")
+		}
+		w.Header().Set("X-Test", "yes")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("done"))
+	}
+	w := httptest.NewRecorder()
+	withTimeout(time.Second, handler)(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusCreated || w.Body.String() != "done" || w.Header().Get("X-Test") != "yes" {
+		t.Fatalf("got %d %q with headers %v", w.Code, w.Body, w.Header())
+	}
+}
+
+func TestWithTimeoutZeroDisablesDeadline(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); ok {
+			t.Error("handler got a deadline")
+		}
+	}
+	withTimeout(0, handler)(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestWithTimeoutRepanics(t *testing.T) {
+	defer func() {
+		if p := recover(); p != "boom" {
+			t.Fatalf("recovered %v, want the handler's panic", p)
+		}
+	}()
+	withTimeout(time.Second, func(http.ResponseWriter, *http.Request) { panic("boom") })(
+		httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestMineTimesOutAndStopsMining(t *testing.T) {
+	chain := blockchain.NewBlockchain()
+	pool := blockchain.NewTransactionPool(100)
+	pool.SetBalances(chain)
+	// A difficulty no block will be mined at before the deadline
+	s := NewEnhancedBlockchainServer(chain, pool, 60, metrics.NewBlockchainMetricsWithRegistry(prometheus.NewRegistry()))
+	timeouts := DefaultTimeoutConfig()
+	timeouts.Execute = 50 * time.Millisecond
+	s.ConfigureTimeouts(timeouts)
+
+	start := time.Now()
+	w := serve(s, http.MethodPost, "/api/mine", "")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("mine answered %d, want 503", w.Code)
+	}
+	decodeError(t, w)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("timeout took %v", elapsed)
+	}
+	if chain.Height() != 0 {
+		t.Fatalf("chain grew to height %d", chain.Height())
+	}
+}
+
+func TestHTTPServersAreHardened(t *testing.T) {
+	server := newHTTPServer(":0", http.NotFoundHandler())
+	if server.ReadHeaderTimeout == 0 || server.ReadTimeout == 0 || server.WriteTimeout == 0 ||
+		server.IdleTimeout == 0 || server.MaxHeaderBytes == 0 {
+		t.Fatalf("server limits not all set: %+v", server)
+	}
+	if server.ReadHeaderTimeout > server.ReadTimeout {
+		t.Errorf("header timeout %v exceeds read timeout %v", server.ReadHeaderTimeout, server.ReadTimeout)
+	}
+}
+
+func TestContractTimeoutIsReportedAndRecorded(t *testing.T) {
+	s := newTestServer(t)
+	timeouts := DefaultTimeoutConfig()
+	timeouts.Contract = time.Millisecond
+	s.ConfigureTimeouts(timeouts)
+	id := deployLua(t, s, "function spin() while true do end end")
+
+	w := serve(s, http.MethodPost, "/api/contracts/"+id+"/execute", `{"function": "spin", "gasLimit": 10000000}`)
+	var response executionErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Code != contracts.CodeTimeout {
+		t.Fatalf("execute answered %d with code %q, want %q", w.Code, response.Code, contracts.CodeTimeout)
+	}
+
+	var receipt contracts.Receipt
+	w = serve(s, http.MethodGet, "/api/receipts/"+response.Receipt, "")
+	if err := json.NewDecoder(w.Body).Decode(&receipt); err != nil {
+		t.Fatal(err)
+	}
+	if receipt.ErrorCode != contracts.CodeTimeout {
+		t.Fatalf("receipt recorded code %q", receipt.ErrorCode)
+	}
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestIDHeader carries the ID correlating a request with its trace
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds request IDs accepted from clients
+const maxRequestIDLength = 128
+
+// tracer returns the tracer of API request spans. It is looked up when each
+// span starts so spans follow the tracer provider installed last.
+func tracer() trace.Tracer {
+	return otel.Tracer("github.com/anekazek/simple-blockchain/pkg/api")
+}
+
+// ensureRequestID returns the request's ID, generating one when the client
+// sent none, and echoes it in the response
+func ensureRequestID(w http.ResponseWriter, r *http.Request) string {
+	id := r.Header.Get(requestIDHeader)
+	if id == "" || len(id) > maxRequestIDLength {
+		buf := make([]byte, 8)
+		rand.Read(buf)
+		id = hex.EncodeToString(buf)
+	}
+	w.Header().Set(requestIDHeader, id)
+	return id
+}
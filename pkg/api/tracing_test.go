@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// spanNamed returns the ended span called name
+func spanNamed(t *testing.T, spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+	t.Fatalf("no %q span recorded", name)
+	return nil
+}
+
+// attributeOf returns the value of the span's attribute key
+func attributeOf(span sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestMineRequestSpanHierarchy(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracing.Install(provider)
+	defer provider.Shutdown(context.Background())
+
+	s := newTestServer(t)
+	req := httptest.NewRequest(http.MethodPost, "/api/transactions", strings.NewReader(`{"to":"bob","value":0}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(requestIDHeader, "submit-1")
+	s.Handler().ServeHTTP(httptest.NewRecorder(), req)
+	if w := serve(s, http.MethodPost, "/api/mine", ""); w.Code != http.StatusOK {
+		t.Fatalf("mine answered %d: %s", w.Code, w.Body)
+	}
+
+	spans := recorder.Ended()
+	submit := spanNamed(t, spans, "POST /api/transactions")
+	if id, _ := attributeOf(submit, "request.id"); id.AsString() != "submit-1" {
+		t.Errorf("submit span carries request ID %q, want submit-1", id.AsString())
+	}
+	admit := spanNamed(t, spans, "txpool.add")
+	if admit.Parent().SpanID() != submit.SpanContext().SpanID() {
+		t.Error("pool admission isn't nested under the submit request")
+	}
+
+	request := spanNamed(t, spans, "POST /api/mine")
+	mine := spanNamed(t, spans, "blockchain.mine")
+	if mine.Parent().SpanID() != request.SpanContext().SpanID() {
+		t.Error("mining isn't nested under the mine request")
+	}
+	if mine.SpanContext().TraceID() != request.SpanContext().TraceID() {
+		t.Error("mining span is in another trace")
+	}
+	if _, ok := attributeOf(request, "request.id"); !ok {
+		t.Error("mine request span has no request ID")
+	}
+	if difficulty, _ := attributeOf(mine, "block.difficulty"); difficulty.AsInt64() != 1 {
+		t.Errorf("mining span records difficulty %d, want 1", difficulty.AsInt64())
+	}
+	if attempts, _ := attributeOf(mine, "mining.attempts"); attempts.AsInt64() < 1 {
+		t.Errorf("mining span records %d attempts", attempts.AsInt64())
+	}
+}
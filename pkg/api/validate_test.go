@@ -0,0 +1,39 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestValidateEndpoint(t *testing.T) {
+	s := newTestServer(t)
+	for i := 0; i < 3; i++ {
+		if _, err := s.chain.AddBlock(nil, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	validate := func() chainValidation {
+		t.Helper()
+		w := serve(s, http.MethodGet, "/api/blockchain/validate", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("got %d: %s", w.Code, w.Body)
+		}
+		var result chainValidation
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	if result := validate(); !result.Valid || result.Height != 3 || result.InvalidIndex != nil {
+		t.Fatalf("intact chain reported %+v", result)
+	}
+
+	s.chain.Blocks[2].Nonce++
+	result := validate()
+	if result.Valid || result.InvalidIndex == nil || *result.InvalidIndex != 2 || result.Error == "" {
+		t.Fatalf("corrupted chain reported %+v, want block 2 invalid", result)
+	}
+}
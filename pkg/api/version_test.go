@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/version"
+)
+
+// setBuild injects build details for the duration of the test
+func setBuild(t *testing.T, v, commit, date string) {
+	t.Helper()
+	saved := [3]string{version.Version, version.Commit, version.BuildDate}
+	version.Version, version.Commit, version.BuildDate = v, commit, date
+	t.Cleanup(func() { version.Version, version.Commit, version.BuildDate = saved[0], saved[1], saved[2] })
+}
+
+func TestVersionEndpointReportsBuild(t *testing.T) {
+	setBuild(t, "v1.2.3", "abc123", "2026-01-02")
+	s := newTestServer(t)
+	s.SetNodeID("node-1")
+
+	w := serve(s, http.MethodGet, "/api/version", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body)
+	}
+	var info version.Info
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+	want := version.Info{
+		Version:         "v1.2.3",
+		Commit:          "abc123",
+		BuildDate:       "2026-01-02",
+		GoVersion:       runtime.Version(),
+		APIVersion:      version.APIVersion,
+		ProtocolVersion: version.ProtocolVersion,
+		NodeID:          "node-1",
+	}
+	if info != want {
+		t.Fatalf("got %+v, want %+v", info, want)
+	}
+
+	var stats struct {
+		Version version.Info `json:"version"`
+	}
+	if err := json.NewDecoder(serve(s, http.MethodGet, "/api/stats", "").Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Version != want {
+		t.Errorf("stats report version %+v, want %+v", stats.Version, want)
+	}
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsMessagesPerSecond is the number of inbound messages a client may send per second
+const wsMessagesPerSecond = 10
+
+// maxClientTopics bounds how many topics one client may subscribe to
+const maxClientTopics = 64
+
+// Inbound WebSocket actions
+const (
+	actionSubmitTx    = "submit_tx"
+	actionGetBlock    = "get_block"
+	actionGetStats    = "get_stats"
+	actionSubscribe   = "subscribe"
+	actionUnsubscribe = "unsubscribe"
+)
+
+// topicMessage is a broadcast delivered only to clients subscribed to its topic
+type topicMessage struct {
+	topic   string
+	message interface{}
+}
+
+// contractTopic is the topic carrying a contract's events
+func contractTopic(contractID string) string {
+	return "contract:" + contractID
+}
+
+// wsRequest is an inbound message from a WebSocket client. RequestID is an
+// optional correlation ID echoed back on the response.
+type wsRequest struct {
+	RequestID   string              `json:"requestId,omitempty"`
+	Action      string              `json:"action"`
+	Transaction *transactionRequest `json:"transaction,omitempty"`
+	Hash        string              `json:"hash,omitempty"`
+	Topic       string              `json:"topic,omitempty"`
+}
+
+// messageRateLimiter enforces a fixed-window message limit for one connection
+type messageRateLimiter struct {
+	limit       int
+	window      time.Duration
+	windowStart time.Time
+	count       int
+	mutex       sync.Mutex
+}
+
+// newMessageRateLimiter creates a limiter allowing limit messages per window
+func newMessageRateLimiter(limit int, window time.Duration) *messageRateLimiter {
+	return &messageRateLimiter{
+		limit:  limit,
+		window: window,
+	}
+}
+
+// Allow reports whether another message may be processed now
+func (l *messageRateLimiter) Allow() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.windowStart) >= l.window {
+		l.windowStart = now
+		l.count = 0
+	}
+	if l.count >= l.limit {
+		return false
+	}
+	l.count++
+	return true
+}
+
+// handleClientMessage dispatches an inbound message and replies only to the sender
+func (s *EnhancedBlockchainServer) handleClientMessage(conn *websocket.Conn, limiter *messageRateLimiter, payload []byte) {
+	var req wsRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		s.sendClientError(conn, "", "malformed message: "+err.Error())
+		return
+	}
+
+	if !limiter.Allow() {
+		s.sendClientError(conn, req.RequestID, "rate limit exceeded")
+		return
+	}
+
+	switch req.Action {
+	case actionSubmitTx:
+		if req.Transaction == nil {
+			s.sendClientError(conn, req.RequestID, "missing transaction")
+			return
+		}
+		if !s.isReady() {
+			s.sendClientError(conn, req.RequestID, "node is not ready")
+			return
+		}
+		tx, err := s.submitTransaction(context.Background(), *req.Transaction)
+		if err != nil {
+			s.writeToClient(conn, map[string]interface{}{
+				"type":      "tx_result",
+				"requestId": req.RequestID,
+				"status":    "rejected",
+				"error":     err.Error(),
+			})
+			return
+		}
+		s.writeToClient(conn, map[string]interface{}{
+			"type":      "tx_result",
+			"requestId": req.RequestID,
+			"id":        tx.ID,
+			"status":    "pending",
+		})
+
+	case actionGetBlock:
+		block, ok := s.findBlock(req.Hash)
+		if !ok {
+			s.sendClientError(conn, req.RequestID, "block not found")
+			return
+		}
+		s.writeToClient(conn, map[string]interface{}{
+			"type":      "block",
+			"requestId": req.RequestID,
+			"block":     block,
+		})
+
+	case actionGetStats:
+		stats := s.currentStats()
+		stats["requestId"] = req.RequestID
+		s.writeToClient(conn, stats)
+
+	case actionSubscribe, actionUnsubscribe:
+		if req.Topic == "" {
+			s.sendClientError(conn, req.RequestID, "missing topic")
+			return
+		}
+		s.clientsMutex.Lock()
+		topics := s.clients[conn]
+		full := req.Action == actionSubscribe && !topics[req.Topic] && len(topics) >= maxClientTopics
+		if !full && topics != nil {
+			if req.Action == actionSubscribe {
+				topics[req.Topic] = true
+			} else {
+				delete(topics, req.Topic)
+			}
+		}
+		s.clientsMutex.Unlock()
+		if full {
+			s.sendClientError(conn, req.RequestID, "too many subscriptions")
+			return
+		}
+		s.writeToClient(conn, map[string]interface{}{
+			"type":      req.Action + "d",
+			"requestId": req.RequestID,
+			"topic":     req.Topic,
+		})
+
+	default:
+		s.sendClientError(conn, req.RequestID, "unknown action: "+req.Action)
+	}
+}
+
+// sendClientError sends an error frame to a single client
+func (s *EnhancedBlockchainServer) sendClientError(conn *websocket.Conn, requestID, message string) {
+	s.writeToClient(conn, map[string]interface{}{
+		"type":      "error",
+		"requestId": requestID,
+		"error":     message,
+	})
+}
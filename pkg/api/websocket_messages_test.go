@@ -0,0 +1,163 @@
+package api
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// request sends a WebSocket action and returns the reply carrying its
+// request ID
+func request(t *testing.T, conn *websocket.Conn, req map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	if err := conn.WriteJSON(req); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var message map[string]interface{}
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("waiting for the reply to %v: %v", req, err)
+		}
+		if message["requestId"] == req["requestId"] {
+			return message
+		}
+	}
+}
+
+// expectNoReplies fails if any reply to a request reaches conn before the
+// read times out. Broadcasts carry no request ID and are skipped.
+func expectNoReplies(t *testing.T, conn *websocket.Conn) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	for {
+		var message map[string]interface{}
+		err := conn.ReadJSON(&message)
+		var timeout net.Error
+		if errors.As(err, &timeout) && timeout.Timeout() {
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id, _ := message["requestId"].(string); id != "" {
+			t.Fatalf("another client's reply arrived: %v", message)
+		}
+	}
+}
+
+func TestWebSocketSubmitTransaction(t *testing.T) {
+	s := newTestServer(t)
+	sender := dialWebSocket(t, s)
+	other := dialWebSocket(t, s)
+
+	reply := request(t, sender, map[string]interface{}{
+		"requestId":   "tx-1",
+		"action":      actionSubmitTx,
+		"transaction": map[string]interface{}{"to": "bob", "value": 0},
+	})
+	if reply["type"] != "tx_result" || reply["status"] != "pending" {
+		t.Fatalf("got %v, want a pending tx_result", reply)
+	}
+	id, _ := reply["id"].(string)
+	if _, err := s.txPool.GetTransaction(id); err != nil {
+		t.Fatalf("transaction %q isn't in the pool: %v", id, err)
+	}
+
+	reply = request(t, sender, map[string]interface{}{
+		"requestId":   "tx-2",
+		"action":      actionSubmitTx,
+		"transaction": map[string]interface{}{"to": "bob", "value": 1, "fee": -1},
+	})
+	if reply["type"] != "tx_result" || reply["status"] != "rejected" || reply["error"] == "" {
+		t.Fatalf("got %v, want a rejected tx_result", reply)
+	}
+
+	expectNoReplies(t, other)
+}
+
+func TestWebSocketQueries(t *testing.T) {
+	s := newTestServer(t)
+	sender := dialWebSocket(t, s)
+	other := dialWebSocket(t, s)
+	genesis, _ := s.chain.GetBlockByIndex(0)
+
+	reply := request(t, sender, map[string]interface{}{"requestId": "b", "action": actionGetBlock, "hash": genesis.Hash})
+	block, _ := reply["block"].(map[string]interface{})
+	if reply["type"] != "block" || block["hash"] != genesis.Hash {
+		t.Fatalf("got %v, want the genesis block", reply)
+	}
+
+	reply = request(t, sender, map[string]interface{}{"requestId": "missing", "action": actionGetBlock, "hash": "nope"})
+	if reply["type"] != "error" {
+		t.Fatalf("got %v for an unknown block, want an error", reply)
+	}
+
+	reply = request(t, sender, map[string]interface{}{"requestId": "s", "action": actionGetStats})
+	if reply["type"] != "stats" || reply["blockCount"] != float64(1) {
+		t.Fatalf("got %v, want stats for one block", reply)
+	}
+
+	expectNoReplies(t, other)
+}
+
+func TestWebSocketRejectsInvalidMessages(t *testing.T) {
+	s := newTestServer(t)
+	conn := dialWebSocket(t, s)
+
+	reply := request(t, conn, map[string]interface{}{"requestId": "x", "action": "mine"})
+	if reply["type"] != "error" || reply["error"] != "unknown action: mine" {
+		t.Fatalf("got %v, want an unknown action error", reply)
+	}
+
+	reply = request(t, conn, map[string]interface{}{"requestId": "t", "action": actionSubmitTx})
+	if reply["type"] != "error" || reply["error"] != "missing transaction" {
+		t.Fatalf("got %v, want a missing transaction error", reply)
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("{not json")); err != nil {
+		t.Fatal(err)
+	}
+	if message := readMessage(t, conn, "error"); message["requestId"] != "" {
+		t.Fatalf("got %v, want an error without a request ID", message)
+	}
+}
+
+func TestWebSocketRateLimitsEachConnection(t *testing.T) {
+	s := newTestServer(t)
+	conn := dialWebSocket(t, s)
+	other := dialWebSocket(t, s)
+
+	for i := 0; i < wsMessagesPerSecond; i++ {
+		if err := conn.WriteJSON(map[string]interface{}{"requestId": "ok", "action": actionGetStats}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reply := request(t, conn, map[string]interface{}{"requestId": "over", "action": actionGetStats})
+	if reply["type"] != "error" || reply["error"] != "rate limit exceeded" {
+		t.Fatalf("got %v, want the rate limit error", reply)
+	}
+
+	// The limit is per connection
+	reply = request(t, other, map[string]interface{}{"requestId": "fresh", "action": actionGetStats})
+	if reply["type"] != "stats" {
+		t.Fatalf("got %v on another connection, want stats", reply)
+	}
+}
+
+func TestMessageRateLimiterResetsEachWindow(t *testing.T) {
+	limiter := newMessageRateLimiter(2, 50*time.Millisecond)
+	if !limiter.Allow() || !limiter.Allow() {
+		t.Fatal("messages under the limit refused")
+	}
+	if limiter.Allow() {
+		t.Fatal("message over the limit allowed")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if !limiter.Allow() {
+		t.Fatal("limit didn't reset with the window")
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"github.com/gorilla/websocket"
+)
+
+// WebSocketMetrics receives the WebSocket server's client and delivery
+// statistics. metrics.BlockchainMetrics implements it.
+type WebSocketMetrics interface {
+	// WebSocketClients reports how many clients are connected
+	WebSocketClients(count int)
+
+	// WebSocketMessageSent records a message written to a client
+	WebSocketMessageSent(messageType string)
+
+	// WebSocketSendError records a message that couldn't be written to a client
+	WebSocketSendError(messageType string)
+
+	// BroadcastDropped records a broadcast discarded because the queue was full
+	BroadcastDropped()
+}
+
+// noWebSocketMetrics discards WebSocket statistics
+type noWebSocketMetrics struct{}
+
+func (noWebSocketMetrics) WebSocketClients(int)        {}
+func (noWebSocketMetrics) WebSocketMessageSent(string) {}
+func (noWebSocketMetrics) WebSocketSendError(string)   {}
+func (noWebSocketMetrics) BroadcastDropped()           {}
+
+// SetWebSocketMetrics sets where WebSocket statistics are reported
+func (s *EnhancedBlockchainServer) SetWebSocketMetrics(metrics WebSocketMetrics) {
+	if metrics == nil {
+		metrics = noWebSocketMetrics{}
+	}
+	s.wsMetrics = metrics
+}
+
+// messageType returns the type of a WebSocket message, for metrics
+func messageType(message interface{}) string {
+	if m, ok := message.(map[string]interface{}); ok {
+		if t, ok := m["type"].(string); ok {
+			return t
+		}
+	}
+	return "unknown"
+}
+
+// addClient registers a connected client. The caller must hold the clients lock.
+func (s *EnhancedBlockchainServer) addClient(conn *websocket.Conn) {
+	s.clients[conn] = make(map[string]bool)
+	s.wsMetrics.WebSocketClients(len(s.clients))
+}
+
+// removeClient forgets a client. The caller must hold the clients lock.
+func (s *EnhancedBlockchainServer) removeClient(conn *websocket.Conn) {
+	if _, ok := s.clients[conn]; !ok {
+		return
+	}
+	delete(s.clients, conn)
+	s.wsMetrics.WebSocketClients(len(s.clients))
+}
+
+// clientCount returns how many WebSocket clients are connected
+func (s *EnhancedBlockchainServer) clientCount() int {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+	return len(s.clients)
+}
+
+// send writes a message to a client and records the outcome. The caller
+// must hold the clients lock.
+func (s *EnhancedBlockchainServer) send(conn *websocket.Conn, message interface{}) error {
+	if err := conn.WriteJSON(message); err != nil {
+		s.wsMetrics.WebSocketSendError(messageType(message))
+		return err
+	}
+	s.wsMetrics.WebSocketMessageSent(messageType(message))
+	return nil
+}
+
+// publish queues a message for broadcast, dropping it when the queue is full
+// so a slow client can't stall the handlers producing messages
+func (s *EnhancedBlockchainServer) publish(message interface{}) {
+	select {
+	case s.broadcast <- message:
+	default:
+		s.wsMetrics.BroadcastDropped()
+	}
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeWebSocketMetrics records what the server reports
+type fakeWebSocketMetrics struct {
+	clients      int
+	sent, errors map[string]int
+	dropped      int
+	mutex        sync.Mutex
+}
+
+func newFakeWebSocketMetrics() *fakeWebSocketMetrics {
+	return &fakeWebSocketMetrics{sent: make(map[string]int), errors: make(map[string]int)}
+}
+
+func (m *fakeWebSocketMetrics) WebSocketClients(count int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.clients = count
+}
+
+func (m *fakeWebSocketMetrics) WebSocketMessageSent(messageType string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.sent[messageType]++
+}
+
+func (m *fakeWebSocketMetrics) WebSocketSendError(messageType string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.errors[messageType]++
+}
+
+func (m *fakeWebSocketMetrics) BroadcastDropped() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.dropped++
+}
+
+// clientCount returns the last reported client count
+func (m *fakeWebSocketMetrics) clientCount() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.clients
+}
+
+func TestWebSocketClientsAreCounted(t *testing.T) {
+	s := newTestServer(t)
+	m := newFakeWebSocketMetrics()
+	s.SetWebSocketMetrics(m)
+
+	first := dialWebSocket(t, s)
+	dialWebSocket(t, s)
+	if n := m.clientCount(); n != 2 || s.clientCount() != 2 {
+		t.Fatalf("reported %d clients, server counts %d, want 2", n, s.clientCount())
+	}
+	m.mutex.Lock()
+	stats := m.sent["stats"]
+	m.mutex.Unlock()
+	if stats < 2 {
+		t.Fatalf("counted %d stats messages sent, want one per client", stats)
+	}
+
+	first.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for m.clientCount() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("still reporting %d clients after one disconnected", m.clientCount())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebSocketSendErrorsAreCounted(t *testing.T) {
+	s := newTestServer(t)
+	m := newFakeWebSocketMetrics()
+	s.SetWebSocketMetrics(m)
+	conn := dialWebSocket(t, s)
+
+	// Take a server-side connection and close it under the server
+	s.clientsMutex.Lock()
+	var server *websocket.Conn
+	for client := range s.clients {
+		server = client
+	}
+	server.Close()
+	err := s.send(server, map[string]interface{}{"type": "new_block"})
+	s.clientsMutex.Unlock()
+	conn.Close()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if err == nil || m.errors["new_block"] != 1 {
+		t.Fatalf("send returned %v, counted errors %v", err, m.errors)
+	}
+}
+
+func TestDroppedBroadcastsAreCounted(t *testing.T) {
+	// Nothing drains the queue of a server that isn't listening
+	s := newTestServer(t)
+	m := newFakeWebSocketMetrics()
+	s.SetWebSocketMetrics(m)
+
+	for i := 0; i < cap(s.broadcast)+3; i++ {
+		s.publish(map[string]interface{}{"type": "new_transaction"})
+	}
+	if m.dropped != 3 {
+		t.Fatalf("counted %d dropped broadcasts, want 3", m.dropped)
+	}
+}
@@ -0,0 +1,436 @@
+// Package bench measures the throughput a node sustains. It submits signed
+// transactions or contract calls through the API client at a target rate,
+// mines them, and reports accepted and mined rates, submission latencies,
+// overflows and block intervals. RunMining compares mining on one goroutine
+// with a parallel blockchain.Miner.
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/client"
+	"github.com/anekazek/simple-blockchain/pkg/wallet"
+)
+
+// Workloads
+const (
+	// WorkloadTransfer sends signed transfers between the generated wallets
+	WorkloadTransfer = "transfer"
+	// WorkloadContract calls a counter contract deployed for the run
+	WorkloadContract = "contract"
+)
+
+// Error codes counted as overflows rather than rejections
+const (
+	codePoolFull  = "pool_full"
+	codeQueueFull = "queue_full"
+)
+
+// counterContract is the Lua contract the contract workload calls
+const counterContract = `
+function increment()
+  local count = (tonumber(storage_get("count")) or 0) + 1
+  storage_set("count", tostring(count))
+  return count
+end
+`
+
+// Config configures a benchmark run
+type Config struct {
+	Workload string
+	// Wallets is how many wallets sign the requests, in turn
+	Wallets int
+	// Rate is the target of requests per second, unlimited when 0
+	Rate float64
+	// Duration is how long requests are sent
+	Duration time.Duration
+	// Concurrency caps the requests in flight
+	Concurrency int
+	// MineInterval is how often the benchmark has the node mine while
+	// transactions are pending; 0 leaves mining to the node's own miner
+	MineInterval time.Duration
+	// Drain is how long to wait after the load for pending transactions to
+	// be mined
+	Drain time.Duration
+	// PollInterval is how often the chain height is polled to time blocks
+	PollInterval time.Duration
+}
+
+// DefaultConfig returns the default benchmark settings
+func DefaultConfig() Config {
+	return Config{
+		Workload:     WorkloadTransfer,
+		Wallets:      10,
+		Rate:         100,
+		Duration:     10 * time.Second,
+		Concurrency:  32,
+		MineInterval: time.Second,
+		Drain:        10 * time.Second,
+		PollInterval: 100 * time.Millisecond,
+	}
+}
+
+// operation sends the i-th request, returning the transaction ID of an
+// accepted transfer
+type operation func(ctx context.Context, i int) (string, error)
+
+// result is the outcome of one request
+type result struct {
+	due, done time.Time
+	txID      string
+	err       error
+}
+
+// Run benchmarks the node behind c, named target in the report
+func Run(ctx context.Context, target string, c *client.Client, cfg Config) (*Report, error) {
+	if cfg.Wallets <= 0 || cfg.Concurrency <= 0 || cfg.Duration <= 0 {
+		return nil, errors.New("wallets, concurrency and duration must be positive")
+	}
+	wallets := make([]*wallet.Wallet, cfg.Wallets)
+	for i := range wallets {
+		w, err := wallet.Generate()
+		if err != nil {
+			return nil, err
+		}
+		wallets[i] = w
+	}
+
+	var op operation
+	switch cfg.Workload {
+	case WorkloadTransfer:
+		if err := fund(ctx, c, wallets); err != nil {
+			return nil, err
+		}
+		op = transfers(c, wallets)
+	case WorkloadContract:
+		var err error
+		if op, err = contractCalls(ctx, c, wallets); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown workload %q", cfg.Workload)
+	}
+
+	stats, err := c.Stats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the node: %w", err)
+	}
+	firstBlock := stats.BlockCount
+
+	// Time the blocks and mine in the background until the pool drains
+	background, stop := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	watcher := &blockWatcher{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		watcher.run(background, c, cfg.PollInterval)
+	}()
+	if cfg.MineInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mine(background, c, cfg.MineInterval)
+		}()
+	}
+
+	start := time.Now()
+	results := load(ctx, cfg, op, start)
+	elapsed := time.Since(start)
+	if cfg.Workload == WorkloadTransfer {
+		drain(ctx, c, cfg.Drain, cfg.PollInterval)
+	}
+	stop()
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	watcher.poll(ctx, c) // Catch the blocks mined since the last poll
+
+	report := newReport(target, cfg, results, elapsed)
+	if cfg.Workload == WorkloadTransfer {
+		blocks, err := c.Blocks(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the mined blocks: %w", err)
+		}
+		report.countMined(results, blocks, firstBlock, watcher, start)
+	}
+	report.timeBlocks(watcher, firstBlock)
+	return report, nil
+}
+
+// walletFunding is what each wallet of the transfer workload is given, the
+// default most a faucet hands out at once
+const walletFunding = 100
+
+// fund credits the wallets through the faucet, which only dev-mode nodes
+// serve, and mines the faucet transactions, so the wallets can cover
+// their transfers
+func fund(ctx context.Context, c *client.Client, wallets []*wallet.Wallet) error {
+	for _, w := range wallets {
+		if _, err := c.Faucet(ctx, w.Address(), walletFunding); err != nil {
+			return fmt.Errorf("failed to fund the wallets, which needs a node in dev mode: %w", err)
+		}
+	}
+	if _, err := c.Mine(ctx); err != nil {
+		return fmt.Errorf("failed to mine the wallets' funding: %w", err)
+	}
+	return nil
+}
+
+// transfers returns the transfer workload: wallet i sends 1 to wallet i+1
+func transfers(c *client.Client, wallets []*wallet.Wallet) operation {
+	return func(ctx context.Context, i int) (string, error) {
+		from, to := wallets[i%len(wallets)], wallets[(i+1)%len(wallets)]
+		tx := client.TransactionRequest{To: to.Address(), Value: 1}
+		tx.Sign(from, time.Now())
+		return c.SendTransaction(ctx, tx)
+	}
+}
+
+// contractCalls deploys the counter contract and returns the workload
+// calling it, signed by each wallet in turn
+func contractCalls(ctx context.Context, c *client.Client, wallets []*wallet.Wallet) (operation, error) {
+	deploy := client.DeployRequest{Type: "lua", Name: "bench-counter", Code: counterContract}
+	deploy.Sign(wallets[0], time.Now())
+	deployment, err := c.DeployContract(ctx, deploy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deploy the benchmark contract: %w", err)
+	}
+
+	return func(ctx context.Context, i int) (string, error) {
+		call := client.ExecuteRequest{Function: "increment"}
+		call.Sign(wallets[i%len(wallets)], deployment.ID, time.Now())
+		_, err := c.ExecuteContract(ctx, deployment.ID, call)
+		return "", err
+	}, nil
+}
+
+// load sends requests at the configured rate until the duration has passed
+// and returns the outcome of each once all have completed
+func load(ctx context.Context, cfg Config, op operation, start time.Time) []result {
+	type job struct {
+		i   int
+		due time.Time
+	}
+	jobs := make(chan job)
+	var (
+		results []result
+		mutex   sync.Mutex
+		wg      sync.WaitGroup
+	)
+	for w := 0; w < cfg.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				id, err := op(ctx, j.i)
+				r := result{due: j.due, done: time.Now(), txID: id, err: err}
+				mutex.Lock()
+				results = append(results, r)
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	end := start.Add(cfg.Duration)
+	p := newPacer(cfg.Rate, start)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+dispatch:
+	for i := 0; ; i++ {
+		now := time.Now()
+		due := p.next(now)
+		if !due.Before(end) || !now.Before(end) {
+			break
+		}
+		if wait := due.Sub(now); wait > 0 {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+		// Blocks while every worker is busy; the lag counts as latency
+		select {
+		case jobs <- job{i: i, due: due}:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// mine has the node mine every interval while transactions are pending
+func mine(ctx context.Context, c *client.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if stats, err := c.Stats(ctx); err == nil && stats.TransactionCount > 0 {
+			c.Mine(ctx)
+		}
+	}
+}
+
+// drain waits up to timeout for the node's pool to empty
+func drain(ctx context.Context, c *client.Client, timeout, poll time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if stats, err := c.Stats(ctx); err == nil && stats.TransactionCount == 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(poll):
+		}
+	}
+}
+
+// blockWatcher records when each block was first seen by polling the
+// chain height, so block times are as precise as the poll interval
+type blockWatcher struct {
+	mutex sync.Mutex
+	seen  []time.Time // Time each block index was first seen
+}
+
+// run polls until ctx is done
+func (w *blockWatcher) run(ctx context.Context, c *client.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		w.poll(ctx, c)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll records the blocks added since the last poll
+func (w *blockWatcher) poll(ctx context.Context, c *client.Client) {
+	stats, err := c.Stats(ctx)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for len(w.seen) < stats.BlockCount {
+		w.seen = append(w.seen, now)
+	}
+}
+
+// seenAt returns when block index was first seen
+func (w *blockWatcher) seenAt(index int) (time.Time, bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if index < 0 || index >= len(w.seen) {
+		return time.Time{}, false
+	}
+	return w.seen[index], true
+}
+
+// newReport summarizes the requests of a run
+func newReport(target string, cfg Config, results []result, elapsed time.Duration) *Report {
+	report := &Report{
+		Workload:        cfg.Workload,
+		Target:          target,
+		TargetRate:      cfg.Rate,
+		DurationSeconds: elapsed.Seconds(),
+		Sent:            len(results),
+		Errors:          make(map[string]int),
+	}
+	latencies := make([]time.Duration, 0, len(results))
+	for _, r := range results {
+		latencies = append(latencies, r.done.Sub(r.due))
+		if r.err == nil {
+			report.Accepted++
+			continue
+		}
+		reason := r.err.Error()
+		var apiErr *client.Error
+		if errors.As(r.err, &apiErr) && apiErr.Code != "" {
+			reason = apiErr.Code
+		}
+		if reason == codePoolFull || reason == codeQueueFull {
+			report.Overflows++
+		} else {
+			report.Rejected++
+		}
+		report.Errors[reason]++
+	}
+	report.AcceptedTPS = perSecond(report.Accepted, report.DurationSeconds)
+	report.Latency = summarize(latencies)
+	return report
+}
+
+// countMined counts the accepted transactions in the blocks from
+// firstBlock, and their rate until the last of their blocks was seen
+func (r *Report) countMined(results []result, blocks []blockchain.Block, firstBlock int, watcher *blockWatcher, start time.Time) {
+	accepted := make(map[string]bool, len(results))
+	for _, res := range results {
+		if res.err == nil {
+			accepted[res.txID] = true
+		}
+	}
+
+	var last time.Time
+	for _, block := range blocks {
+		if block.Index < firstBlock {
+			continue
+		}
+		found := 0
+		for _, tx := range blockchain.BlockTransactions(block) {
+			if accepted[tx.ID] {
+				found++
+			}
+		}
+		if found == 0 {
+			continue
+		}
+		r.Mined += found
+		if seen, ok := watcher.seenAt(block.Index); ok && seen.After(last) {
+			last = seen
+		}
+	}
+	if !last.IsZero() {
+		r.MinedTPS = perSecond(r.Mined, last.Sub(start).Seconds())
+	}
+}
+
+// timeBlocks records the blocks added during the run and their intervals
+func (r *Report) timeBlocks(watcher *blockWatcher, firstBlock int) {
+	var intervals []time.Duration
+	for index := firstBlock; ; index++ {
+		seen, ok := watcher.seenAt(index)
+		if !ok {
+			break
+		}
+		r.Blocks++
+		if previous, ok := watcher.seenAt(index - 1); ok && index > firstBlock {
+			intervals = append(intervals, seen.Sub(previous))
+		}
+	}
+	r.BlockInterval = summarize(intervals)
+}
@@ -0,0 +1,111 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// MiningConfig configures a mining benchmark, which compares a single
+// mining goroutine with a blockchain.Miner of several
+type MiningConfig struct {
+	// Difficulties are the difficulties mined at, in turn
+	Difficulties []int
+	// Blocks is how many blocks each miner mines at each difficulty
+	Blocks int
+	// Workers is the parallel miner's worker count, one per CPU when 0
+	Workers int
+}
+
+// DefaultMiningConfig returns the default mining benchmark settings
+func DefaultMiningConfig() MiningConfig {
+	return MiningConfig{Difficulties: []int{4, 5}, Blocks: 5}
+}
+
+// MiningReport is the outcome of a mining benchmark. Durations are in
+// milliseconds.
+type MiningReport struct {
+	Workers int            `json:"workers"`
+	Results []MiningResult `json:"results"`
+}
+
+// MiningResult compares the miners at one difficulty. Hash rates are in
+// hashes per second.
+type MiningResult struct {
+	Difficulty       int          `json:"difficulty"`
+	Single           Distribution `json:"singleMs"`
+	SingleHashRate   float64      `json:"singleHashRate"`
+	Parallel         Distribution `json:"parallelMs"`
+	ParallelHashRate float64      `json:"parallelHashRate"`
+	// Speedup is the single miner's mean block time over the parallel one's
+	Speedup float64 `json:"speedup"`
+}
+
+// RunMining mines cfg.Blocks blocks at each difficulty, first on one
+// goroutine and then on cfg.Workers, and reports how long blocks took
+func RunMining(ctx context.Context, cfg MiningConfig) (*MiningReport, error) {
+	if cfg.Blocks <= 0 {
+		return nil, fmt.Errorf("blocks must be positive, got %d", cfg.Blocks)
+	}
+	single, parallel := blockchain.NewMiner(1), blockchain.NewMiner(cfg.Workers)
+	report := &MiningReport{Workers: parallel.Workers()}
+
+	for _, difficulty := range cfg.Difficulties {
+		result := MiningResult{Difficulty: difficulty}
+		var err error
+		if result.Single, result.SingleHashRate, err = timeMining(ctx, single, difficulty, cfg.Blocks); err != nil {
+			return nil, err
+		}
+		if result.Parallel, result.ParallelHashRate, err = timeMining(ctx, parallel, difficulty, cfg.Blocks); err != nil {
+			return nil, err
+		}
+		if result.Parallel.Mean > 0 {
+			result.Speedup = result.Single.Mean / result.Parallel.Mean
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+// timeMining mines a chain of count empty blocks at difficulty on miner,
+// summarizes how long each took, and returns the hash rate over all of them
+func timeMining(ctx context.Context, miner *blockchain.Miner, difficulty, count int) (Distribution, float64, error) {
+	tip := blockchain.CreateGenesisBlock()
+	durations := make([]time.Duration, 0, count)
+	var attempts uint64
+	var total time.Duration
+	for i := 0; i < count; i++ {
+		start := time.Now()
+		block, tries, err := miner.MineContext(ctx, tip, nil, difficulty)
+		if err != nil {
+			return Distribution{}, 0, err
+		}
+		elapsed := time.Since(start)
+		durations = append(durations, elapsed)
+		attempts += tries
+		total += elapsed
+		tip = block
+	}
+	return summarize(durations), float64(attempts) / total.Seconds(), nil
+}
+
+// WriteText writes the report for people to read
+func (r *MiningReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Difficulty\tSingle\tParallel (%d workers)\tSpeedup\n", r.Workers)
+	for _, result := range r.Results {
+		fmt.Fprintf(tw, "%d\t%s at %s\t%s at %s\t%.1f×\n", result.Difficulty,
+			ms(result.Single.Mean), hashRate(result.SingleHashRate),
+			ms(result.Parallel.Mean), hashRate(result.ParallelHashRate), result.Speedup)
+	}
+	return tw.Flush()
+}
+
+// hashRate formats hashes per second
+func hashRate(rate float64) string {
+	return fmt.Sprintf("%.2f MH/s", rate/1e6)
+}
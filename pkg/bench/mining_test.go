@@ -0,0 +1,25 @@
+package bench
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRunMiningComparesMiners(t *testing.T) {
+	report, err := RunMining(context.Background(), MiningConfig{Difficulties: []int{1, 2}, Blocks: 2, Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Workers != 2 || len(report.Results) != 2 {
+		t.Fatalf("got %+v", report)
+	}
+	for _, result := range report.Results {
+		if result.Single.Count != 2 || result.Parallel.Count != 2 {
+			t.Errorf("difficulty %d: timed %d and %d blocks, want 2 each", result.Difficulty, result.Single.Count, result.Parallel.Count)
+		}
+	}
+
+	if _, err := RunMining(context.Background(), MiningConfig{Difficulties: []int{1}}); err == nil {
+		t.Error("ran with no blocks")
+	}
+}
@@ -0,0 +1,29 @@
+package bench
+
+import "time"
+
+// pacer schedules operations at a fixed rate from a start time. Each
+// operation is due at start + n/rate whatever happened to the ones before,
+// so a slow node can't slow the load down, and latencies count from when
+// a request was due rather than when it could be sent.
+type pacer struct {
+	start time.Time
+	rate  float64 // Operations per second, unlimited when not positive
+	n     int64   // Operations scheduled so far
+}
+
+// newPacer creates a pacer starting at start
+func newPacer(rate float64, start time.Time) *pacer {
+	return &pacer{start: start, rate: rate}
+}
+
+// next returns when the next operation is due. Without a rate, every
+// operation is due as soon as it can be sent, now.
+func (p *pacer) next(now time.Time) time.Time {
+	if p.rate <= 0 {
+		return now
+	}
+	due := p.start.Add(time.Duration(float64(p.n) * float64(time.Second) / p.rate))
+	p.n++
+	return due
+}
@@ -0,0 +1,29 @@
+package bench
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPacerSchedulesFromStart(t *testing.T) {
+	start := time.Now()
+	p := newPacer(4, start)
+	// However late the caller asks, operations stay on the fixed schedule
+	for i, late := range []time.Duration{0, time.Second, 0, 3 * time.Second} {
+		due := p.next(start.Add(late))
+		if want := start.Add(time.Duration(i) * 250 * time.Millisecond); !due.Equal(want) {
+			t.Errorf("operation %d due at +%s, want +%s", i, due.Sub(start), want.Sub(start))
+		}
+	}
+}
+
+func TestUnlimitedPacerIsAlwaysDue(t *testing.T) {
+	start := time.Now()
+	p := newPacer(0, start)
+	for i := 0; i < 3; i++ {
+		now := start.Add(time.Duration(i) * time.Millisecond)
+		if due := p.next(now); !due.Equal(now) {
+			t.Errorf("operation %d due at %s, want now", i, due)
+		}
+	}
+}
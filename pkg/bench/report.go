@@ -0,0 +1,130 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// Report is the outcome of a benchmark run. Durations are in milliseconds
+// and rates per second.
+type Report struct {
+	Workload        string  `json:"workload"`
+	Target          string  `json:"target"`
+	TargetRate      float64 `json:"targetRate"`
+	DurationSeconds float64 `json:"durationSeconds"`
+
+	Sent      int `json:"sent"`
+	Accepted  int `json:"accepted"`
+	Overflows int `json:"overflows"` // Refused by a full pool or contract queue
+	Rejected  int `json:"rejected"`  // Failed for any other reason
+
+	AcceptedTPS float64 `json:"acceptedTps"`
+	// Mined counts accepted transactions found in blocks, over the time
+	// until the last of them was seen; contract executions aren't mined
+	Mined    int     `json:"mined"`
+	MinedTPS float64 `json:"minedTps"`
+
+	Latency       Distribution `json:"latencyMs"`
+	Blocks        int          `json:"blocks"`
+	BlockInterval Distribution `json:"blockIntervalMs"`
+
+	// Errors counts the failures by error code, or message without one
+	Errors map[string]int `json:"errors,omitempty"`
+}
+
+// Distribution summarizes a set of durations, in milliseconds
+type Distribution struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Mean  float64 `json:"mean"`
+	P50   float64 `json:"p50"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+// summarize computes the distribution of durations
+func summarize(durations []time.Duration) Distribution {
+	if len(durations) == 0 {
+		return Distribution{}
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	return Distribution{
+		Count: len(sorted),
+		Min:   milliseconds(sorted[0]),
+		Mean:  milliseconds(total / time.Duration(len(sorted))),
+		P50:   milliseconds(percentile(sorted, 50)),
+		P95:   milliseconds(percentile(sorted, 95)),
+		P99:   milliseconds(percentile(sorted, 99)),
+		Max:   milliseconds(sorted[len(sorted)-1]),
+	}
+}
+
+// percentile returns the nearest-rank percentile p of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// milliseconds converts d to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// perSecond returns count over seconds, 0 for an empty interval
+func perSecond(count int, seconds float64) float64 {
+	if seconds <= 0 {
+		return 0
+	}
+	return float64(count) / seconds
+}
+
+// WriteText writes the report for people to read
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Workload:\t%s against %s\n", r.Workload, r.Target)
+	rate := "unlimited"
+	if r.TargetRate > 0 {
+		rate = fmt.Sprintf("%g/s", r.TargetRate)
+	}
+	fmt.Fprintf(tw, "Duration:\t%.1fs at %s\n", r.DurationSeconds, rate)
+	fmt.Fprintf(tw, "Requests:\t%d sent, %d accepted, %d overflowed, %d rejected\n", r.Sent, r.Accepted, r.Overflows, r.Rejected)
+	fmt.Fprintf(tw, "Accepted TPS:\t%.1f\n", r.AcceptedTPS)
+	if r.Workload == WorkloadTransfer {
+		fmt.Fprintf(tw, "Mined TPS:\t%.1f (%d of %d mined)\n", r.MinedTPS, r.Mined, r.Accepted)
+	}
+	fmt.Fprintf(tw, "Latency:\tp50 %s, p95 %s, p99 %s, max %s\n", ms(r.Latency.P50), ms(r.Latency.P95), ms(r.Latency.P99), ms(r.Latency.Max))
+	if r.Blocks > 1 {
+		fmt.Fprintf(tw, "Blocks:\t%d, every %s on average (min %s, p50 %s, max %s)\n", r.Blocks, ms(r.BlockInterval.Mean), ms(r.BlockInterval.Min), ms(r.BlockInterval.P50), ms(r.BlockInterval.Max))
+	} else {
+		fmt.Fprintf(tw, "Blocks:\t%d\n", r.Blocks)
+	}
+
+	reasons := make([]string, 0, len(r.Errors))
+	for reason := range r.Errors {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(tw, "Error:\t%d × %s\n", r.Errors[reason], reason)
+	}
+	return tw.Flush()
+}
+
+// ms formats fractional milliseconds
+func ms(value float64) string {
+	return fmt.Sprintf("%.1fms", value)
+}
@@ -0,0 +1,109 @@
+package bench
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/client"
+)
+
+func TestSummarize(t *testing.T) {
+	var durations []time.Duration
+	// 100ms down to 1ms, out of order
+	for i := 100; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+	got := summarize(durations)
+	want := Distribution{Count: 100, Min: 1, Mean: 50.5, P50: 50, P95: 95, P99: 99, Max: 100}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if durations[0] != 100*time.Millisecond {
+		t.Error("summarize sorted its input")
+	}
+
+	if got := summarize(nil); got != (Distribution{}) {
+		t.Errorf("empty set summarized as %+v", got)
+	}
+	if got := summarize([]time.Duration{time.Millisecond}); got.P50 != 1 || got.P99 != 1 {
+		t.Errorf("single duration summarized as %+v", got)
+	}
+}
+
+func TestNewReportCountsOutcomes(t *testing.T) {
+	start := time.Now()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	results := []result{
+		{due: at(0), done: at(10), txID: "a"},
+		{due: at(0), done: at(20), txID: "b"},
+		{due: at(0), done: at(30), err: &client.Error{Code: codePoolFull}},
+		{due: at(0), done: at(40), err: &client.Error{Code: codeQueueFull}},
+		{due: at(0), done: at(50), err: errors.New("connection refused")},
+	}
+	cfg := Config{Workload: WorkloadTransfer, Rate: 10}
+	report := newReport("node", cfg, results, 2*time.Second)
+
+	if report.Sent != 5 || report.Accepted != 2 || report.Overflows != 2 || report.Rejected != 1 {
+		t.Errorf("sent %d, accepted %d, overflowed %d, rejected %d", report.Sent, report.Accepted, report.Overflows, report.Rejected)
+	}
+	if report.AcceptedTPS != 1 {
+		t.Errorf("accepted TPS %g, want 1", report.AcceptedTPS)
+	}
+	if report.Latency.P50 != 30 || report.Latency.Max != 50 {
+		t.Errorf("latency %+v", report.Latency)
+	}
+	want := map[string]int{codePoolFull: 1, codeQueueFull: 1, "connection refused": 1}
+	for reason, n := range want {
+		if report.Errors[reason] != n {
+			t.Errorf("%d × %s, want %d", report.Errors[reason], reason, n)
+		}
+	}
+}
+
+func TestTimeBlocks(t *testing.T) {
+	start := time.Now()
+	watcher := &blockWatcher{seen: []time.Time{
+		start.Add(-time.Hour), // Before the run
+		start,
+		start.Add(100 * time.Millisecond),
+		start.Add(300 * time.Millisecond),
+	}}
+	report := &Report{}
+	report.timeBlocks(watcher, 1)
+
+	if report.Blocks != 3 {
+		t.Errorf("%d blocks, want 3", report.Blocks)
+	}
+	want := Distribution{Count: 2, Min: 100, Mean: 150, P50: 100, P95: 200, P99: 200, Max: 200}
+	if report.BlockInterval != want {
+		t.Errorf("intervals %+v, want %+v", report.BlockInterval, want)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	report := &Report{
+		Workload: WorkloadTransfer, Target: "node", TargetRate: 50, DurationSeconds: 2,
+		Sent: 100, Accepted: 90, Overflows: 6, Rejected: 4, AcceptedTPS: 45,
+		Mined: 90, MinedTPS: 30, Blocks: 3,
+		Errors: map[string]int{codePoolFull: 6, "timeout": 4},
+	}
+	var out bytes.Buffer
+	if err := report.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"transfer against node",
+		"2.0s at 50/s",
+		"100 sent, 90 accepted, 6 overflowed, 4 rejected",
+		"30.0 (90 of 90 mined)",
+		"6 × pool_full",
+		"4 × timeout",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("report lacks %q:\n%s", line, out.String())
+		}
+	}
+}
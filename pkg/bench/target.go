@@ -0,0 +1,33 @@
+package bench
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/anekazek/simple-blockchain/pkg/client"
+	"github.com/anekazek/simple-blockchain/pkg/node"
+)
+
+// inProcessURL is the base URL of clients of an in-process node
+const inProcessURL = "http://in-process"
+
+// InProcess returns a client calling the API handler of an in-process node
+// directly, without sockets, so that a benchmark measures the node rather
+// than the network stack
+func InProcess(n *node.Node) *client.Client {
+	c := client.New(inProcessURL, "")
+	c.SetHTTPClient(&http.Client{Transport: handlerTransport{n.Server().Handler()}})
+	return c
+}
+
+// handlerTransport serves requests with an http.Handler
+type handlerTransport struct {
+	handler http.Handler
+}
+
+// RoundTrip implements http.RoundTripper
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorder := httptest.NewRecorder()
+	t.handler.ServeHTTP(recorder, req)
+	return recorder.Result(), nil
+}
@@ -0,0 +1,61 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAddBlockFailsWhenTipChangesWhileMining(t *testing.T) {
+	// The rival block must land while the block is mined, after its parent
+	// was read, so retry the few times mining wins the race
+	for attempt := 0; attempt < 10; attempt++ {
+		chain := NewBlockchain()
+		chain.SetMiner(NewMiner(1))
+		rival := mineBlock(t, chain.GetLatestBlock(), 1)
+
+		done := make(chan error, 1)
+		var mined Block
+		go func() {
+			var err error
+			mined, err = chain.AddBlock(nil, 5)
+			done <- err
+		}()
+		time.Sleep(5 * time.Millisecond)
+		if err := chain.AddExistingBlock(rival); err != nil {
+			<-done
+			continue
+		}
+
+		err := <-done
+		if !errors.Is(err, ErrInvalidBlock) {
+			t.Fatalf("got %v, want ErrInvalidBlock", err)
+		}
+		if mined.Hash != "" {
+			t.Errorf("returned block %s though it wasn't added", mined.Hash)
+		}
+		if tip := chain.GetLatestBlock(); tip.Hash != rival.Hash || chain.Height() != 1 {
+			t.Errorf("chain at height %d with tip %s, want the rival", chain.Height(), tip.Hash)
+		}
+		return
+	}
+	t.Fatal("mining always finished before the tip changed")
+}
+
+func TestAddBlockRejectsOverspending(t *testing.T) {
+	chain := newFundedChain(t)
+	pool := NewTransactionPool(10)
+	for _, tx := range []Transaction{transfer("1", "alice", "bob", 60), transfer("2", "alice", "carol", 60)} {
+		if err := pool.AddTransaction(&tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := chain.AddBlock(pool, 0)
+	if !errors.Is(err, ErrInvalidBlock) || !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("got %v, want ErrInvalidBlock and ErrInsufficientFunds", err)
+	}
+	if chain.Height() != 0 || pool.Count() != 2 {
+		t.Errorf("chain at height %d with %d pending, want nothing added or removed", chain.Height(), pool.Count())
+	}
+}
@@ -0,0 +1,73 @@
+package blockchain
+
+import "fmt"
+
+// State is the balance of every address as of a block, folded from the
+// transactions of the blocks up to it. Senders are debited the value, not
+// yet the fee, and faucet transactions credit without a debit. A State is
+// not safe for concurrent use; a chain guards its own with its mutex.
+type State struct {
+	balances map[string]float64
+}
+
+// NewState returns the state whose balances start from a copy of balances,
+// which may be nil for the state before the genesis block
+func NewState(balances map[string]float64) *State {
+	s := &State{balances: make(map[string]float64, len(balances))}
+	for address, balance := range balances {
+		s.balances[address] = balance
+	}
+	return s
+}
+
+// Check returns an error wrapping ErrInvalidBlock if a transaction of block
+// has a value checkValue refuses, or spends more than its sender owns once
+// the block's earlier transactions are applied. Faucet transactions create
+// their funds. The state is left unchanged.
+func (s *State) Check(block Block) error {
+	changes := make(map[string]float64)
+	for _, tx := range BlockTransactions(block) {
+		if err := checkValue(tx); err != nil {
+			return fmt.Errorf("%w: block %d: %w", ErrInvalidBlock, block.Index, err)
+		}
+		if !IsFaucetTransaction(tx) {
+			if available := s.balances[tx.From] + changes[tx.From]; tx.Value > available {
+				return fmt.Errorf("%w: %w: block %d: %s can spend %g, not %g", ErrInvalidBlock, ErrInsufficientFunds, block.Index, tx.From, max(available, 0), tx.Value)
+			}
+			changes[tx.From] -= tx.Value
+		}
+		changes[tx.To] += tx.Value
+	}
+	return nil
+}
+
+// Apply folds the transactions of a block into the balances
+func (s *State) Apply(block Block) {
+	for _, tx := range BlockTransactions(block) {
+		if !IsFaucetTransaction(tx) {
+			s.balances[tx.From] -= tx.Value
+		}
+		s.balances[tx.To] += tx.Value
+	}
+}
+
+// Balance returns the balance of an address, 0 when no transaction has
+// touched it
+func (s *State) Balance(address string) float64 {
+	return s.balances[address]
+}
+
+// Balances returns a copy of the balance of every address
+func (s *State) Balances() map[string]float64 {
+	return NewState(s.balances).balances
+}
+
+// Balances folds the transactions of blocks into the balance of every
+// address they touch, as a State does
+func Balances(blocks []Block) map[string]float64 {
+	state := NewState(nil)
+	for _, block := range blocks {
+		state.Apply(block)
+	}
+	return state.balances
+}
@@ -0,0 +1,121 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+// newFundedChain returns a dev chain whose genesis block funds alice with 100
+func newFundedChain(t *testing.T) *Chain {
+	t.Helper()
+	chain := NewBlockchainWithGenesis(CreateDevGenesisBlock(map[string]float64{"alice": 100}), DefaultBlockLimits())
+	chain.SetDevMode(true)
+	return chain
+}
+
+// transfer returns a transaction moving value between addresses
+func transfer(id, from, to string, value float64) Transaction {
+	return Transaction{ID: id, From: from, To: to, Value: value}
+}
+
+func TestChainTracksBalances(t *testing.T) {
+	chain := newFundedChain(t)
+	if _, err := chain.AddTransactionsContext(context.Background(), []Transaction{transfer("1", "alice", "bob", 30)}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := chain.Balance("alice"); got != 70 {
+		t.Errorf("alice has %g, want 70", got)
+	}
+	if got := chain.Balance("bob"); got != 30 {
+		t.Errorf("bob has %g, want 30", got)
+	}
+}
+
+func TestChainRejectsOverspendingBlock(t *testing.T) {
+	chain := newFundedChain(t)
+	block := mineBlock(t, chain.GetLatestBlock(), 0,
+		transfer("1", "alice", "bob", 60),
+		transfer("2", "alice", "carol", 60))
+
+	err := chain.AddExistingBlock(block)
+	if !errors.Is(err, ErrInvalidBlock) || !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("got %v, want ErrInvalidBlock and ErrInsufficientFunds", err)
+	}
+	if got := chain.Balance("alice"); got != 100 {
+		t.Fatalf("alice has %g after a rejected block", got)
+	}
+}
+
+func TestChainRejectsNegativeValueInBlock(t *testing.T) {
+	chain := newFundedChain(t)
+	block := mineBlock(t, chain.GetLatestBlock(), 0, transfer("1", "bob", "alice", -50))
+
+	if err := chain.AddExistingBlock(block); !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("got %v, want ErrInvalidValue", err)
+	}
+	if got := chain.Balance("bob"); got != 0 {
+		t.Fatalf("bob has %g after a rejected block", got)
+	}
+}
+
+func TestReplaceChainRejectsOverspendingChain(t *testing.T) {
+	chain := newFundedChain(t)
+	genesis := chain.GetLatestBlock()
+	spend := mineBlock(t, genesis, 2, transfer("1", "bob", "carol", 10))
+
+	if chain.ReplaceChain([]Block{genesis, spend}) {
+		t.Fatal("chain spending funds bob doesn't have accepted")
+	}
+}
+
+func TestBlockMaySpendFundsReceivedEarlierInIt(t *testing.T) {
+	chain := newFundedChain(t)
+	block := mineBlock(t, chain.GetLatestBlock(), 0,
+		transfer("1", "alice", "bob", 40),
+		transfer("2", "bob", "carol", 40))
+
+	if err := chain.AddExistingBlock(block); err != nil {
+		t.Fatalf("valid block rejected: %v", err)
+	}
+	if got := chain.Balance("carol"); got != 40 {
+		t.Fatalf("carol has %g, want 40", got)
+	}
+}
+
+func TestPoolRejectsOverspendAcrossPendingTransactions(t *testing.T) {
+	chain := newFundedChain(t)
+	pool := NewTransactionPool(10)
+	pool.SetBalances(chain)
+
+	for _, tx := range []Transaction{transfer("1", "alice", "bob", 40), transfer("2", "alice", "bob", 40)} {
+		if err := pool.AddTransaction(&tx); err != nil {
+			t.Fatalf("transaction %s refused: %v", tx.ID, err)
+		}
+	}
+	tx := transfer("3", "alice", "bob", 40)
+	if err := pool.AddTransaction(&tx); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("got %v, want ErrInsufficientFunds", err)
+	}
+	if got := pool.PendingSpend("alice"); got != 80 {
+		t.Fatalf("alice has %g pending, want 80", got)
+	}
+}
+
+func TestPoolRejectsInvalidValues(t *testing.T) {
+	pool := NewTransactionPool(10)
+	for _, value := range []float64{-1, math.NaN(), math.Inf(1)} {
+		tx := transfer("1", "alice", "bob", value)
+		if err := pool.AddTransaction(&tx); !errors.Is(err, ErrInvalidValue) {
+			t.Errorf("value %g: got %v, want ErrInvalidValue", value, err)
+		}
+	}
+
+	if added := pool.Requeue([]Transaction{transfer("2", "alice", "bob", -5)}); added != 0 {
+		t.Fatal("requeued a transaction of a negative value")
+	}
+	if pool.Count() != 0 {
+		t.Fatalf("pool holds %d transactions", pool.Count())
+	}
+}
@@ -1,97 +1,272 @@
 package blockchain
 
 import (
+	"context"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
-	"strings"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// Block represents each 'item' in the blockchain
+// tracer returns the tracer of mining spans, from whichever provider is
+// installed when mining starts
+func tracer() trace.Tracer {
+	return otel.Tracer("github.com/anekazek/simple-blockchain/pkg/blockchain")
+}
+
+// BlockVersion is the format version of the blocks made now. Version 1
+// added the difficulty and version to the hash, version 2 timestamps
+// blocks in Unix seconds, and version 3 hashes a binary encoding of the
+// block with a numeric nonce, version 4 hashes the merkle root of the
+// transactions in place of the transactions, and version 5 hashes the
+// hash of the data in place of the data, so the hash covers the header
+// only; blocks without a version are hashed by the rule before them.
+const BlockVersion = 5
+
+// Block represents each 'item' in the blockchain: a header, which its hash
+// covers, and a body, which the header commits to. It is encoded as a
+// single flat object, as before the two were split.
 type Block struct {
-	Index      int    `json:"index"`
-	Timestamp  string `json:"timestamp"`
-	Data       string `json:"data"`
-	Hash       string `json:"hash"`
-	PrevHash   string `json:"prevHash"`
-	Difficulty int    `json:"difficulty"`
-	Nonce      string `json:"nonce"`
+	BlockHeader
+	BlockBody
+	// Pruned blocks were installed from a snapshot with only their header
+	// known, so their transactions are gone and their hash can't be
+	// recomputed
+	Pruned bool `json:"pruned,omitempty"`
+
+	// legacyTimestamp is the time.Time.String timestamp of a block made
+	// before version 2, which its hash covers. See timestamp.go.
+	legacyTimestamp string
 }
 
-// CalculateHash is a simple SHA256 hashing function
+// CalculateHash hashes the block fields with the block's hash algorithm,
+// SHA256 unless it records another. Blocks from version 5 hash their
+// header only, as HeaderHash does, and blocks of version 3 and 4 the
+// encoding of hashRecord, which includes their data; older blocks hash
+// their fields as strings with SHA256, as they did when they were made. A
+// block recording an unknown algorithm has no hash, so it matches none.
 func CalculateHash(block Block) string {
-	record := strconv.Itoa(block.Index) + block.Timestamp + block.Data + block.PrevHash + block.Nonce
+	hasher, err := HasherFor(block.HashAlgo)
+	if err != nil {
+		return ""
+	}
+	if block.Version < 3 {
+		return legacyHash(block)
+	}
+	sum := newNonceHasher(block, hasher).sum(block.Nonce)
+	return hex.EncodeToString(sum[:])
+}
+
+// HeaderHash returns the hash of a header from version 5, which covers
+// only the header, so light nodes can check it without the body. Headers
+// of older blocks can't be hashed without their data or transactions, and
+// headers recording an unknown algorithm can't be hashed at all; for those
+// it returns false.
+func HeaderHash(header BlockHeader) (string, bool) {
+	if header.Version < 5 {
+		return "", false
+	}
+	hash := CalculateHash(Block{BlockHeader: header})
+	return hash, hash != ""
+}
+
+// legacyHash hashes a block made before version 3. Blocks without
+// transactions hash as they did before blocks carried them, and blocks
+// without a version leave out their difficulty, as they did before it.
+// Nonces were hex strings then, and genesis blocks had none.
+func legacyHash(block Block) string {
+	var nonce string
+	if block.Index > 0 {
+		nonce = strconv.FormatUint(block.Nonce, 16)
+	}
+	record := strconv.Itoa(block.Index) + block.hashedTimestamp() + block.Data + encodeTransactions(block.Transactions) + block.PrevHash + nonce
+	if block.Version >= 1 {
+		record += "v" + strconv.Itoa(block.Version) + "d" + strconv.Itoa(block.Difficulty)
+	}
 	h := sha256.New()
 	h.Write([]byte(record))
 	hashed := h.Sum(nil)
 	return hex.EncodeToString(hashed)
 }
 
-// GenerateBlock creates a new block using previous block's hash
-func GenerateBlock(oldBlock Block, data string, difficulty int) (Block, error) {
-	var newBlock Block
+// hashRecord returns the canonical encoding of a block that its hash
+// covers from version 3: the version, index, timestamp and difficulty as
+// big-endian 64-bit integers, the previous hash, data and transaction
+// encoding each prefixed with its length, and the nonce last, so mining
+// rewrites only the last 8 bytes. From version 4 the merkle root stands in
+// for the transaction encoding, and from version 5 the data hash for the
+// data, so a header commits to the body.
+func hashRecord(block Block) []byte {
+	data, txs := block.Data, block.MerkleRoot
+	if block.Version < 4 {
+		txs = encodeTransactions(block.Transactions)
+	}
+	if block.Version >= 5 {
+		data = block.DataHash
+	}
+	record := make([]byte, 0, 4*8+3*8+len(block.PrevHash)+len(data)+len(txs)+8)
+	record = binary.BigEndian.AppendUint64(record, uint64(block.Version))
+	record = binary.BigEndian.AppendUint64(record, uint64(block.Index))
+	record = binary.BigEndian.AppendUint64(record, uint64(block.Timestamp))
+	record = binary.BigEndian.AppendUint64(record, uint64(block.Difficulty))
+	for _, field := range []string{block.PrevHash, data, txs} {
+		record = binary.BigEndian.AppendUint64(record, uint64(len(field)))
+		record = append(record, field...)
+	}
+	return binary.BigEndian.AppendUint64(record, block.Nonce)
+}
+
+// nonceHasher hashes a block with different nonces, encoding the rest of
+// the block once
+type nonceHasher struct {
+	record []byte
+	hasher Hasher
+}
 
-	t := time.Now()
+// newNonceHasher returns a hasher for a block from version 3
+func newNonceHasher(block Block, hasher Hasher) *nonceHasher {
+	return &nonceHasher{record: hashRecord(block), hasher: hasher}
+}
 
-	newBlock.Index = oldBlock.Index + 1
-	newBlock.Timestamp = t.String()
-	newBlock.Data = data
-	newBlock.PrevHash = oldBlock.Hash
-	newBlock.Difficulty = difficulty
+// sum returns the hash of the block with the given nonce
+func (h *nonceHasher) sum(nonce uint64) [32]byte {
+	binary.BigEndian.PutUint64(h.record[len(h.record)-8:], nonce)
+	return h.hasher.Sum(h.record)
+}
 
-	for i := 0; ; i++ {
-		hex := fmt.Sprintf("%x", i)
-		newBlock.Nonce = hex
-		newBlockHash := CalculateHash(newBlock)
-		if !IsHashValid(newBlockHash, newBlock.Difficulty) {
-			fmt.Printf("\r%s", newBlockHash)
-			continue
-		}
-		fmt.Println()
-		newBlock.Hash = newBlockHash
-		break
+// encodeTransactions returns the encoding of transactions hashed into a
+// block, empty when there are none. encoding/json writes struct fields in
+// order and formats times and numbers one way, so it is deterministic.
+func encodeTransactions(txs []Transaction) string {
+	if len(txs) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(txs)
+	if err != nil {
+		panic(fmt.Sprintf("encoding transactions: %v", err))
 	}
+	return string(data)
+}
+
+// GenerateBlock creates a new block of transactions using previous block's
+// hash. It returns the block and how many hashes were computed to find it;
+// WithProgress reports on the search as it goes.
+func GenerateBlock(oldBlock Block, txs []Transaction, difficulty int, opts ...MineOption) (Block, uint64, error) {
+	return GenerateBlockContext(context.Background(), oldBlock, txs, difficulty, opts...)
+}
 
-	return newBlock, nil
+// GenerateBlockContext creates a new block like GenerateBlock, but stops mining
+// and returns the context error once ctx is done. It searches for the nonce
+// on a single goroutine; a Miner searches on several.
+func GenerateBlockContext(ctx context.Context, oldBlock Block, txs []Transaction, difficulty int, opts ...MineOption) (Block, uint64, error) {
+	return NewMiner(1).MineContext(ctx, oldBlock, txs, difficulty, opts...)
 }
 
+// ErrInvalidBlock is wrapped by the errors of blocks that don't extend
+// their parent validly
+var ErrInvalidBlock = errors.New("invalid block")
+
 // IsBlockValid makes sure block is valid by checking index
-// and comparing the hash of the previous block
+// and comparing the hash of the previous block. The hash must meet the
+// block's difficulty, and the format version may not go back, so blocks
+// can't be passed off as unversioned to leave their difficulty unhashed.
+// The timestamp must pass CheckTimestamp, the merkle root must match the
+// transactions, and the hash algorithm must be known.
 func IsBlockValid(newBlock, oldBlock Block) bool {
+	return ValidateBlock(newBlock, oldBlock) == nil
+}
+
+// ValidateBlock applies the checks of IsBlockValid, returning an error
+// wrapping ErrInvalidBlock that says which one failed
+func ValidateBlock(newBlock, oldBlock Block) error {
+	if newBlock.Pruned {
+		return fmt.Errorf("%w: block %d is pruned", ErrInvalidBlock, newBlock.Index)
+	}
+
+	if newBlock.Version < oldBlock.Version || newBlock.Version > BlockVersion {
+		return fmt.Errorf("%w: block %d has version %d after version %d", ErrInvalidBlock, newBlock.Index, newBlock.Version, oldBlock.Version)
+	}
+
+	if newBlock.Version >= 2 && newBlock.legacyTimestamp != "" {
+		return fmt.Errorf("%w: block %d has a string timestamp", ErrInvalidBlock, newBlock.Index)
+	}
+
+	if err := CheckTimestamp(newBlock.Timestamp, &oldBlock.Timestamp, time.Now()); err != nil {
+		return fmt.Errorf("%w: block %d: %w", ErrInvalidBlock, newBlock.Index, err)
+	}
+
 	if oldBlock.Index+1 != newBlock.Index {
-		return false
+		return fmt.Errorf("%w: block %d doesn't follow block %d", ErrInvalidBlock, newBlock.Index, oldBlock.Index)
 	}
 
 	if oldBlock.Hash != newBlock.PrevHash {
-		return false
+		return fmt.Errorf("%w: block %d doesn't link to the hash of block %d", ErrInvalidBlock, newBlock.Index, oldBlock.Index)
+	}
+
+	if err := checkBody(newBlock); err != nil {
+		return err
+	}
+
+	if err := checkHashAlgo(newBlock); err != nil {
+		return err
+	}
+
+	if newBlock.Difficulty < 0 || newBlock.Difficulty > len(newBlock.Hash) {
+		return fmt.Errorf("%w: block %d has difficulty %d out of range", ErrInvalidBlock, newBlock.Index, newBlock.Difficulty)
 	}
 
 	if CalculateHash(newBlock) != newBlock.Hash {
-		return false
+		return fmt.Errorf("%w: block %d has a bad hash", ErrInvalidBlock, newBlock.Index)
 	}
 
-	return true
+	if !IsHashValid(newBlock.Hash, newBlock.Difficulty) {
+		return fmt.Errorf("%w: block %d doesn't meet its difficulty %d", ErrInvalidBlock, newBlock.Index, newBlock.Difficulty)
+	}
+
+	return nil
+}
+
+// HashMatches reports whether a block hashes to its hash and its header
+// commits to its body, so neither was altered after mining
+func HashMatches(block Block) bool {
+	return checkBody(block) == nil && CalculateHash(block) == block.Hash
 }
 
-// IsHashValid checks if hash meets difficulty requirement
+// IsHashValid checks if hash meets difficulty requirement: its first
+// difficulty characters are zeros. Difficulties below zero or longer than
+// the hash are never met, as they come from peers unchecked.
 func IsHashValid(hash string, difficulty int) bool {
-	prefix := strings.Repeat("0", difficulty)
-	return strings.HasPrefix(hash, prefix)
-}
-
-// CreateGenesisBlock creates the first block in the blockchain
-func CreateGenesisBlock() Block {
-	t := time.Now()
-	genesisBlock := Block{
-		Index:      0,
-		Timestamp:  t.String(),
-		Data:       "Genesis Block",
-		Difficulty: 1,
-		Nonce:      "",
-		PrevHash:   "",
-	}
-	genesisBlock.Hash = CalculateHash(genesisBlock)
-	return genesisBlock
+	if difficulty < 0 || difficulty > len(hash) {
+		return false
+	}
+	for i := 0; i < difficulty; i++ {
+		if hash[i] != '0' {
+			return false
+		}
+	}
+	return true
+}
+
+// sumMeetsDifficulty is IsHashValid for a hash before its hex encoding:
+// the first difficulty hex digits, 4 bits each, must be zero
+func sumMeetsDifficulty(sum [32]byte, difficulty int) bool {
+	if difficulty <= 0 {
+		return true
+	}
+	if difficulty > 2*len(sum) {
+		return false
+	}
+	for i := 0; i < difficulty/2; i++ {
+		if sum[i] != 0 {
+			return false
+		}
+	}
+	return difficulty%2 == 0 || sum[difficulty/2]>>4 == 0
 }
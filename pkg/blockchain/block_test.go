@@ -0,0 +1,190 @@
+package blockchain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+// mineBlock mines a block on parent at the given difficulty or fails the test
+func mineBlock(t testing.TB, parent Block, difficulty int, txs ...Transaction) Block {
+	t.Helper()
+	block, _, err := GenerateBlock(parent, txs, difficulty)
+	if err != nil {
+		t.Fatalf("mining block on %d: %v", parent.Index, err)
+	}
+	return block
+}
+
+// rehash recomputes a block's hash after a test altered its header
+func rehash(block Block) Block {
+	block.Hash = CalculateHash(block)
+	return block
+}
+
+func TestIsBlockValidAcceptsMinedBlock(t *testing.T) {
+	genesis := CreateGenesisBlock()
+	block := mineBlock(t, genesis, 2)
+	if err := ValidateBlock(block, genesis); err != nil {
+		t.Fatalf("mined block rejected: %v", err)
+	}
+}
+
+func TestLoweringDifficultyBreaksHash(t *testing.T) {
+	genesis := CreateGenesisBlock()
+	block := mineBlock(t, genesis, 2)
+
+	block.Difficulty = 0
+	if IsBlockValid(block, genesis) {
+		t.Fatal("block with lowered difficulty still valid")
+	}
+	if CalculateHash(block) == block.Hash {
+		t.Fatal("difficulty isn't covered by the hash")
+	}
+}
+
+func TestUnversionedBlocksHashWithoutDifficulty(t *testing.T) {
+	block := Block{BlockHeader: BlockHeader{Index: 1, Timestamp: 1, PrevHash: "parent", Difficulty: 1}}
+	hash := CalculateHash(block)
+	block.Difficulty = 3
+	if CalculateHash(block) != hash {
+		t.Fatal("unversioned blocks must keep hashing by the old rule")
+	}
+
+	block.Version = 1
+	if CalculateHash(block) == hash {
+		t.Fatal("versioned blocks must hash their difficulty")
+	}
+}
+
+func TestValidateBlockRejectsDifficultyOutOfRange(t *testing.T) {
+	genesis := CreateGenesisBlock()
+	mined := mineBlock(t, genesis, 1)
+
+	for _, difficulty := range []int{-1, math.MinInt, 65, math.MaxInt} {
+		block := mined
+		block.Difficulty = difficulty
+		block = rehash(block)
+
+		err := ValidateBlock(block, genesis)
+		if !errors.Is(err, ErrInvalidBlock) {
+			t.Errorf("difficulty %d: got %v, want ErrInvalidBlock", difficulty, err)
+		}
+	}
+}
+
+func TestIsHashValid(t *testing.T) {
+	tests := []struct {
+		hash       string
+		difficulty int
+		want       bool
+	}{
+		{"00ab", 0, true},
+		{"00ab", 2, true},
+		{"00ab", 3, false},
+		{"0000", 4, true},
+		{"0000", 5, false},
+		{"00ab", -1, false},
+		{"00ab", math.MaxInt, false},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		if got := IsHashValid(tt.hash, tt.difficulty); got != tt.want {
+			t.Errorf("IsHashValid(%q, %d) = %v, want %v", tt.hash, tt.difficulty, got, tt.want)
+		}
+	}
+}
+
+func TestIsHashValidDoesNotAllocate(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		IsHashValid("0000abcd", 1<<30)
+		IsHashValid("0000abcd", 4)
+	})
+	if allocs != 0 {
+		t.Fatalf("IsHashValid allocated %v times", allocs)
+	}
+}
+
+func TestTamperedTransactionInvalidatesBlock(t *testing.T) {
+	genesis := CreateGenesisBlock()
+	block := mineBlock(t, genesis, 1, transfer("1", "", "bob", 5), transfer("2", "", "carol", 0))
+	if err := ValidateBlock(block, genesis); err != nil {
+		t.Fatalf("mined block rejected: %v", err)
+	}
+
+	tampered := block
+	tampered.Transactions = append([]Transaction(nil), block.Transactions...)
+	tampered.Transactions[0].To = "mallory"
+	if err := ValidateBlock(tampered, genesis); !errors.Is(err, ErrInvalidBlock) {
+		t.Fatalf("tampered block: got %v, want ErrInvalidBlock", err)
+	}
+
+	// Blocks before version 4 hash their transactions directly
+	legacy := block
+	legacy.Version, legacy.MerkleRoot, legacy.DataHash = 3, "", ""
+	legacy = rehash(legacy)
+	legacy.Transactions = append([]Transaction(nil), block.Transactions...)
+	legacy.Transactions[1].Value = 1
+	if CalculateHash(legacy) == legacy.Hash {
+		t.Fatal("a version 3 block's hash doesn't cover its transactions")
+	}
+}
+
+func TestDataOnlyBlockDecodesAndValidates(t *testing.T) {
+	// Blocks made before versions follow only unversioned blocks
+	genesis := Block{BlockHeader: BlockHeader{Timestamp: time.Now().Add(-time.Minute).Unix(), Hash: "0a"}}
+	timestamp := time.Now().String()
+	sum := sha256.Sum256([]byte("1" + timestamp + "hello" + genesis.Hash + "1f"))
+	data := fmt.Sprintf(`{"index":1,"timestamp":%q,"data":"hello","hash":%q,"prevHash":%q,"difficulty":0,"nonce":"1f"}`,
+		timestamp, hex.EncodeToString(sum[:]), genesis.Hash)
+
+	var block Block
+	if err := json.Unmarshal([]byte(data), &block); err != nil {
+		t.Fatal(err)
+	}
+	if block.Data != "hello" || len(block.Transactions) != 0 || block.Nonce != 0x1f {
+		t.Fatalf("decoded %+v", block)
+	}
+	if err := ValidateBlock(block, genesis); err != nil {
+		t.Fatalf("data-only block rejected: %v", err)
+	}
+
+	block.Data = "goodbye"
+	if err := ValidateBlock(block, genesis); err == nil {
+		t.Fatal("block with altered data validated")
+	}
+}
+
+func TestAddBlockRemovesTransactionsOnlyOnceAdded(t *testing.T) {
+	chain := NewBlockchain()
+	pool := NewTransactionPool(10)
+	if err := pool.AddTransaction(&Transaction{ID: "1", To: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := chain.AddBlockContext(ctx, pool, 40); err == nil {
+		t.Fatal("mining with a cancelled context succeeded")
+	}
+	if pool.Count() != 1 {
+		t.Fatal("transaction left the pool though no block was added")
+	}
+
+	block, err := chain.AddBlock(pool, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(block.Transactions) != 1 || block.Transactions[0].ID != "1" {
+		t.Fatalf("mined %+v", block.Transactions)
+	}
+	if pool.Count() != 0 {
+		t.Fatal("mined transaction still pending")
+	}
+}
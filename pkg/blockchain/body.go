@@ -0,0 +1,47 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// BlockBody is the payload of a block, which its header commits to through
+// the merkle root and the data hash
+type BlockBody struct {
+	Transactions []Transaction `json:"transactions,omitempty"`
+	// Data is the free-form payload of blocks made before blocks carried
+	// transactions, which held them JSON-encoded here, and the label of
+	// genesis blocks. It is still hashed, so those blocks keep validating.
+	Data string `json:"data,omitempty"`
+}
+
+// ComputeDataHash returns the SHA256 of a block's data, empty for blocks
+// without any
+func ComputeDataHash(data string) string {
+	if data == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkBody checks that a block's header commits to its body: its merkle
+// root must be that of its transactions from version 4, and its data hash
+// that of its data from version 5. Older blocks have neither.
+func checkBody(block Block) error {
+	root, dataHash := "", ""
+	if block.Version >= 4 {
+		root = ComputeMerkleRoot(block.Transactions)
+	}
+	if block.Version >= 5 {
+		dataHash = ComputeDataHash(block.Data)
+	}
+	if block.MerkleRoot != root {
+		return fmt.Errorf("%w: block %d has a bad merkle root", ErrInvalidBlock, block.Index)
+	}
+	if block.DataHash != dataHash {
+		return fmt.Errorf("%w: block %d has a bad data hash", ErrInvalidBlock, block.Index)
+	}
+	return nil
+}
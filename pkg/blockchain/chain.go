@@ -1,71 +1,411 @@
 package blockchain
 
 import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
 	"sync"
+	"time"
 )
 
-// Chain represents the blockchain and provides methods to interact with it
+// MaxReorgDepth is how many blocks behind the tip a fork may branch off.
+// Blocks deeper than this are considered final.
+const MaxReorgDepth = 100
+
+// Chain represents the blockchain and provides methods to interact with it.
+// Blocks should be read through GetBlocks and the other accessors, which
+// hold the chain's lock.
 type Chain struct {
-	Blocks []Block
-	mutex  *sync.Mutex
+	Blocks      []Block
+	hashIndex   map[string]int // Index of each block by hash
+	state       *State         // Balances as of the tip
+	mutex       *sync.RWMutex
+	mining      sync.Mutex         // Lets one block be mined at a time
+	devMode     bool               // Accept faucet transactions
+	base        *SnapshotBase      // Set when installed from a snapshot
+	miner       *Miner             // Mines the blocks added by AddBlock
+	hasher      Hasher             // Hashes the blocks added by AddBlock
+	signer      ed25519.PrivateKey // Signs the blocks added by AddBlock
+	limits      BlockLimits
+	checkpoints map[int]string // Pinned block hash by height
+	orphans     *orphanPool    // Blocks waiting for their parent
+	forks       *ForkManager   // Abandoned branches and reorganizations
+	events      chainEvents
+	pending     []ChainEvent // Events to deliver once the mutex is released
 }
 
-// NewBlockchain creates a new blockchain with a genesis block
+// NewBlockchain creates a new blockchain starting at the genesis block of
+// the default network, with the default block limits
 func NewBlockchain() *Chain {
-	genesisBlock := CreateGenesisBlock()
-	return &Chain{
-		Blocks: []Block{genesisBlock},
-		mutex:  &sync.Mutex{},
+	return NewBlockchainWithGenesis(CreateGenesisBlock(), DefaultBlockLimits())
+}
+
+// NewBlockchainWithGenesis creates a blockchain starting at genesisBlock,
+// such as a development genesis from CreateDevGenesisBlock. Blocks over
+// limits are neither mined nor accepted; limits that aren't positive take
+// their default.
+func NewBlockchainWithGenesis(genesisBlock Block, limits BlockLimits) *Chain {
+	bc := &Chain{
+		mutex:   &sync.RWMutex{},
+		miner:   NewMiner(0),
+		hasher:  hashers[DefaultHashAlgo],
+		limits:  limits.withDefaults(),
+		orphans: newOrphanPool(DefaultOrphanLimits()),
+		forks:   NewForkManager(),
 	}
+	bc.setBlocks([]Block{genesisBlock})
+	return bc
+}
+
+// setBlocks makes blocks the chain and rebuilds the hash index and the
+// state, from the snapshot base if there is one, so the base must be set
+// first. The caller must hold the mutex.
+func (bc *Chain) setBlocks(blocks []Block) {
+	bc.Blocks = blocks
+	bc.hashIndex = make(map[string]int, len(blocks))
+	for i, block := range blocks {
+		bc.hashIndex[block.Hash] = i
+	}
+	bc.resetState()
+}
+
+// resetState recomputes the state from the blocks and the snapshot base.
+// The caller must hold the mutex.
+func (bc *Chain) resetState() {
+	balances, _ := bc.balancesAt(len(bc.Blocks) - 1)
+	bc.state = NewState(balances)
+}
+
+// appendBlock adds a block to the tip, the hash index and the state. The
+// caller must hold the mutex.
+func (bc *Chain) appendBlock(block Block) {
+	bc.Blocks = append(bc.Blocks, block)
+	bc.hashIndex[block.Hash] = len(bc.Blocks) - 1
+	bc.state.Apply(block)
+	bc.forks.prune(block.Index)
+}
+
+// SetMiner sets the miner of the blocks added by AddBlock and
+// AddTransactionsContext. Chains start with one worker per CPU.
+func (bc *Chain) SetMiner(miner *Miner) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	bc.miner = miner
 }
 
-// AddBlock adds a new block to the blockchain if it's valid
-func (bc *Chain) AddBlock(data string, difficulty int) (Block, error) {
+// SetHasher sets the hash algorithm of the blocks added by AddBlock and
+// AddTransactionsContext. Chains start with DefaultHashAlgo. Blocks record
+// their algorithm, so blocks hashed with any known algorithm are accepted
+// whatever the chain mines with.
+func (bc *Chain) SetHasher(hasher Hasher) {
 	bc.mutex.Lock()
 	defer bc.mutex.Unlock()
+	bc.hasher = hasher
+}
+
+// Hasher returns the hash algorithm of the blocks the chain mines
+func (bc *Chain) Hasher() Hasher {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+	return bc.hasher
+}
 
-	newBlock, err := GenerateBlock(bc.Blocks[len(bc.Blocks)-1], data, difficulty)
+// SetDevMode sets whether blocks may carry faucet transactions. Outside dev
+// mode, blocks with them are rejected.
+func (bc *Chain) SetDevMode(enabled bool) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	bc.devMode = enabled
+}
+
+// DevMode reports whether the chain accepts faucet transactions
+func (bc *Chain) DevMode() bool {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+	return bc.devMode
+}
+
+// Limits returns the limits blocks on the chain are held to
+func (bc *Chain) Limits() BlockLimits {
+	return bc.limits
+}
+
+// validateBlock applies ValidateBlock, the chain's checkpoints, block
+// limits and faucet rule, and the balance rule of State.Check against
+// state, the balances as of oldBlock. Balances are unknown after blocks
+// pruned by a snapshot whose base isn't known, and a nil state skips them.
+func (bc *Chain) validateBlock(newBlock, oldBlock Block, state *State) error {
+	if err := ValidateBlock(newBlock, oldBlock); err != nil {
+		return err
+	}
+	if err := bc.checkCheckpoint(newBlock); err != nil {
+		return err
+	}
+	if err := bc.limits.Check(newBlock); err != nil {
+		return err
+	}
+	if !bc.devMode && hasFaucetTransactions(newBlock) {
+		return fmt.Errorf("%w: block %d carries faucet transactions outside dev mode", ErrInvalidBlock, newBlock.Index)
+	}
+	if state != nil {
+		return state.Check(newBlock)
+	}
+	return nil
+}
+
+// AddBlock mines a block of transactions taken from pool and adds it to
+// the blockchain. A block over the chain's limits isn't mined, and a block
+// that fails validation, for example because the tip changed while it was
+// mined, isn't added, and an error wrapping ErrInvalidBlock says why.
+func (bc *Chain) AddBlock(pool *TransactionPool, difficulty int) (Block, error) {
+	return bc.AddBlockContext(context.Background(), pool, difficulty)
+}
+
+// AddBlockContext adds a new block like AddBlock, abandoning mining once ctx
+// is done. As many pending transactions are mined as fit the chain's
+// limits, and they are removed from the pool only once the block is on the
+// chain. A nil pool mines an empty block.
+func (bc *Chain) AddBlockContext(ctx context.Context, pool *TransactionPool, difficulty int) (Block, error) {
+	var batch []*Transaction
+	if pool != nil {
+		batch = pool.GetBatch(bc.limits.MaxTransactions, bc.TransactionBudget(difficulty))
+	}
+	txs := make([]Transaction, len(batch))
+	ids := make([]string, len(batch))
+	for i, tx := range batch {
+		txs[i], ids[i] = *tx, tx.ID
+	}
+
+	newBlock, err := bc.addTransactions(ctx, txs, difficulty)
 	if err != nil {
 		return Block{}, err
 	}
+	if pool != nil {
+		pool.RemoveBatch(ids)
+	}
+	return newBlock, nil
+}
+
+// TransactionBudget returns how many bytes of transactions the next block
+// of the given difficulty may carry under the chain's limits
+func (bc *Chain) TransactionBudget(difficulty int) int {
+	bc.mutex.RLock()
+	tip, hasher, signer := bc.Blocks[len(bc.Blocks)-1], bc.hasher, bc.signer
+	bc.mutex.RUnlock()
+	return bc.limits.TransactionBytes(withSignatureSpace(Block{BlockHeader: BlockHeader{
+		Version:    BlockVersion,
+		Index:      tip.Index + 1,
+		Timestamp:  time.Now().Unix(),
+		PrevHash:   tip.Hash,
+		Difficulty: difficulty,
+		HashAlgo:   recordedAlgo(hasher),
+	}}, signer))
+}
+
+// AddTransactionsContext mines a block of the given transactions and adds
+// it to the blockchain, failing like AddBlock if it's invalid or over the
+// chain's limits
+func (bc *Chain) AddTransactionsContext(ctx context.Context, txs []Transaction, difficulty int) (Block, error) {
+	return bc.addTransactions(ctx, txs, difficulty)
+}
+
+// addTransactions mines a block of txs on the tip and appends it if valid.
+// The chain stays readable while the block is mined; if a block from
+// elsewhere changes the tip meanwhile, the mined one no longer validates.
+func (bc *Chain) addTransactions(ctx context.Context, txs []Transaction, difficulty int) (Block, error) {
+	bc.mining.Lock()
+	defer bc.mining.Unlock()
 
-	if IsBlockValid(newBlock, bc.Blocks[len(bc.Blocks)-1]) {
-		bc.Blocks = append(bc.Blocks, newBlock)
+	bc.mutex.RLock()
+	tip, miner, hasher, signer := bc.Blocks[len(bc.Blocks)-1], bc.miner, bc.hasher, bc.signer
+	bc.mutex.RUnlock()
+
+	newBlock, _, err := miner.MineContext(ctx, tip, txs, difficulty, WithLimits(bc.limits), WithHasher(hasher), WithSigner(signer))
+	if err != nil {
+		return Block{}, err
 	}
 
+	bc.mutex.Lock()
+	defer bc.unlock()
+	if err := bc.validateBlock(newBlock, bc.Blocks[len(bc.Blocks)-1], bc.state); err != nil {
+		return Block{}, err
+	}
+	bc.appendBlock(newBlock)
+	bc.notify(EventBlockAdded, newBlock, true)
+	bc.connectOrphans()
 	return newBlock, nil
 }
 
+// AddExistingBlock appends a block produced elsewhere, such as by a peer,
+// if it extends the current tip. The error of one that doesn't wraps
+// ErrInvalidBlock.
+func (bc *Chain) AddExistingBlock(block Block) error {
+	bc.mutex.Lock()
+	defer bc.unlock()
+
+	if err := bc.validateBlock(block, bc.Blocks[len(bc.Blocks)-1], bc.state); err != nil {
+		return fmt.Errorf("block does not extend the current chain: %w", err)
+	}
+
+	bc.appendBlock(block)
+	bc.notify(EventBlockAdded, block, false)
+	bc.connectOrphans()
+	return nil
+}
+
 // GetLatestBlock returns the most recent block in the chain
 func (bc *Chain) GetLatestBlock() Block {
-	bc.mutex.Lock()
-	defer bc.mutex.Unlock()
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
 	return bc.Blocks[len(bc.Blocks)-1]
 }
 
-// ReplaceChain replaces our chain with a new one if it's longer and valid
+// Height returns the index of the latest block, 0 when only the genesis
+// block exists
+func (bc *Chain) Height() int {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+	return len(bc.Blocks) - 1
+}
+
+// GetBlockByHash returns the block with the given hash if it is on the
+// chain, looking it up in the chain's hash index
+func (bc *Chain) GetBlockByHash(hash string) (Block, bool) {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	i, ok := bc.hashIndex[hash]
+	if !ok {
+		return Block{}, false
+	}
+	return bc.Blocks[i], true
+}
+
+// ReplaceChain replaces our chain with a new one if it starts from our
+// genesis block, is valid, matches the chain's checkpoints, and has more
+// total work. Blocks of ours the new chain doesn't share are kept as a side
+// branch by the chain's ForkManager, which records the switch.
 func (bc *Chain) ReplaceChain(newChain []Block) bool {
 	bc.mutex.Lock()
-	defer bc.mutex.Unlock()
+	defer bc.unlock()
 
-	if len(newChain) <= len(bc.Blocks) {
+	if len(newChain) == 0 || newChain[0].Pruned || newChain[0].Hash != bc.Blocks[0].Hash {
+		return false
+	}
+	if TotalWork(newChain).Cmp(TotalWork(bc.Blocks)) <= 0 {
 		return false
 	}
 
-	// Validate the new chain
+	// Validate the new chain, folding its balances as it goes
+	if ValidateGenesis(newChain[0]) != nil {
+		return false
+	}
+	state := NewState(nil)
+	state.Apply(newChain[0])
 	for i := 1; i < len(newChain); i++ {
-		if !IsBlockValid(newChain[i], newChain[i-1]) {
+		if bc.validateBlock(newChain[i], newChain[i-1], state) != nil {
 			return false
 		}
+		state.Apply(newChain[i])
 	}
 
-	bc.Blocks = newChain
+	// The new chain is whole, so its balances need no snapshot
+	bc.base = nil
+	bc.switchBranch(append([]Block(nil), newChain...))
+	bc.connectOrphans()
 	return true
 }
 
-// GetBlocks returns all blocks in the chain
-func (bc *Chain) GetBlocks() []Block {
+// Restore replaces the chain with blocks read back from storage, whatever
+// their length, after checking that each links to the one before it. A
+// chain installed from a snapshot needs its SetSnapshotBase again.
+func (bc *Chain) Restore(blocks []Block) error {
 	bc.mutex.Lock()
-	defer bc.mutex.Unlock()
-	return bc.Blocks
+	defer bc.unlock()
+
+	if err := bc.validateStored(blocks, nil); err != nil {
+		return err
+	}
+	bc.base = nil
+	bc.setBlocks(append([]Block{}, blocks...))
+	bc.notify(EventChainReplaced, bc.Blocks[len(bc.Blocks)-1], false)
+	return nil
+}
+
+// Reorganize switches to a competing branch. The branch must start with a
+// block whose parent is on our chain no deeper than MaxReorgDepth, and the
+// branch must have more total work than the blocks it replaces, which the
+// chain's ForkManager keeps as a side branch.
+func (bc *Chain) Reorganize(branch []Block) error {
+	bc.mutex.Lock()
+	defer bc.unlock()
+
+	if len(branch) == 0 {
+		return errors.New("empty branch")
+	}
+
+	// Find the common ancestor
+	ancestor, ok := bc.hashIndex[branch[0].PrevHash]
+	if !ok {
+		return errors.New("branch does not connect to the chain")
+	}
+
+	if bc.base != nil && ancestor < bc.base.Height {
+		return errors.New("branch forks below the snapshot the chain was installed from")
+	}
+
+	tip := len(bc.Blocks) - 1
+	if tip-ancestor > MaxReorgDepth {
+		return fmt.Errorf("reorg depth %d exceeds maximum of %d", tip-ancestor, MaxReorgDepth)
+	}
+
+	if TotalWork(branch).Cmp(TotalWork(bc.Blocks[ancestor+1:])) <= 0 {
+		return errors.New("branch doesn't have more work than the current chain")
+	}
+
+	balances, err := bc.balancesAt(ancestor)
+	if err != nil {
+		return err
+	}
+	state := NewState(balances)
+	prev := bc.Blocks[ancestor]
+	for _, block := range branch {
+		if err := bc.validateBlock(block, prev, state); err != nil {
+			return fmt.Errorf("invalid block %d in branch: %w", block.Index, err)
+		}
+		state.Apply(block)
+		prev = block
+	}
+
+	newBlocks := make([]Block, 0, ancestor+1+len(branch))
+	newBlocks = append(newBlocks, bc.Blocks[:ancestor+1]...)
+	bc.switchBranch(append(newBlocks, branch...))
+	bc.connectOrphans()
+	return nil
+}
+
+// GetBlocks returns a copy of all blocks in the chain, which later changes
+// to the chain leave alone
+func (bc *Chain) GetBlocks() []Block {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+	return append([]Block(nil), bc.Blocks...)
+}
+
+// Genesis returns the chain's first block
+func (bc *Chain) Genesis() Block {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+	return bc.Blocks[0]
+}
+
+// GetBlockByIndex returns the block at index i if the chain has one
+func (bc *Chain) GetBlockByIndex(i int) (Block, bool) {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+	if i < 0 || i >= len(bc.Blocks) {
+		return Block{}, false
+	}
+	return bc.Blocks[i], true
 }
@@ -0,0 +1,89 @@
+package blockchain
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestGetBlocksReturnsCopy(t *testing.T) {
+	chain := NewBlockchain()
+	if _, err := chain.AddBlock(nil, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := chain.GetBlocks()
+	blocks[1].Hash = "changed"
+	_ = append(blocks[:1], Block{})
+	if got, _ := chain.GetBlockByIndex(1); got.Hash == "changed" || got.Index != 1 {
+		t.Fatalf("changing the returned slice changed the chain: %+v", got)
+	}
+}
+
+func TestBlockAccessors(t *testing.T) {
+	chain := NewBlockchain()
+	block, err := chain.AddBlock(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chain.Height() != 1 {
+		t.Fatalf("height %d, want 1", chain.Height())
+	}
+	if got, ok := chain.GetBlockByIndex(1); !ok || got.Hash != block.Hash {
+		t.Fatalf("GetBlockByIndex(1) = %s, %v", got.Hash, ok)
+	}
+	for _, i := range []int{-1, 2} {
+		if _, ok := chain.GetBlockByIndex(i); ok {
+			t.Errorf("GetBlockByIndex(%d) found a block", i)
+		}
+	}
+}
+
+// TestConcurrentChainAccess adds blocks, replaces the chain and reads it
+// from many goroutines at once; run it with -race
+func TestConcurrentChainAccess(t *testing.T) {
+	chain := NewBlockchain()
+	longer := NewBlockchain()
+	for i := 0; i < 50; i++ {
+		if _, err := longer.AddBlock(nil, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				// Another writer may change the tip while this block is mined
+				chain.AddBlock(nil, 0)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				blocks := chain.GetBlocks()
+				for j, block := range blocks {
+					if block.Index != j {
+						t.Errorf("block %d at position %d", block.Index, j)
+						return
+					}
+				}
+				if height := chain.Height(); height < 0 {
+					t.Errorf("height %d", height)
+				}
+				chain.GetBlockByIndex(len(blocks) - 1)
+				chain.GetLatestBlock()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			chain.ReplaceChain(longer.GetBlocks())
+		}()
+	}
+	wg.Wait()
+
+	if _, err := chain.Validate(); err != nil {
+		t.Fatalf("chain invalid after concurrent use: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrCheckpointMismatch is wrapped by the errors of blocks at a
+// checkpointed height whose hash isn't the pinned one
+var ErrCheckpointMismatch = errors.New("block doesn't match the checkpoint")
+
+// Checkpoint is a block known to be on the canonical chain
+type Checkpoint struct {
+	Height int    `json:"height"`
+	Hash   string `json:"hash"`
+}
+
+// ParseCheckpoint parses a checkpoint written as height:hash
+func ParseCheckpoint(s string) (Checkpoint, error) {
+	height, hash, ok := strings.Cut(s, ":")
+	if !ok || hash == "" {
+		return Checkpoint{}, fmt.Errorf("checkpoint %q is not height:hash", s)
+	}
+	n, err := strconv.Atoi(height)
+	if err != nil || n < 0 {
+		return Checkpoint{}, fmt.Errorf("checkpoint %q has an invalid height", s)
+	}
+	return Checkpoint{Height: n, Hash: hash}, nil
+}
+
+// String formats the checkpoint as height:hash
+func (c Checkpoint) String() string {
+	return strconv.Itoa(c.Height) + ":" + c.Hash
+}
+
+// AddCheckpoint pins the hash of the block at height. From then on no
+// block with another hash is accepted at that height, whether it extends
+// the tip, comes in a reorganization, or is part of a chain that would
+// replace ours, so history up to the checkpoint can't be rewritten however
+// much work a competing chain has. Blocks already on the chain aren't
+// checked; a stored chain that contradicts a checkpoint fails to Restore.
+// Pinning a second hash at a height is an error.
+func (bc *Chain) AddCheckpoint(height int, hash string) error {
+	if height < 0 || hash == "" {
+		return fmt.Errorf("invalid checkpoint %d:%s", height, hash)
+	}
+
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	if pinned, ok := bc.checkpoints[height]; ok && pinned != hash {
+		return fmt.Errorf("height %d is already pinned to %s", height, pinned)
+	}
+	if bc.checkpoints == nil {
+		bc.checkpoints = make(map[int]string)
+	}
+	bc.checkpoints[height] = hash
+	return nil
+}
+
+// Checkpoints returns the chain's checkpoints, lowest first
+func (bc *Chain) Checkpoints() []Checkpoint {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	checkpoints := make([]Checkpoint, 0, len(bc.checkpoints))
+	for height, hash := range bc.checkpoints {
+		checkpoints = append(checkpoints, Checkpoint{Height: height, Hash: hash})
+	}
+	sort.Slice(checkpoints, func(i, j int) bool { return checkpoints[i].Height < checkpoints[j].Height })
+	return checkpoints
+}
+
+// checkCheckpoint returns an error wrapping ErrInvalidBlock and
+// ErrCheckpointMismatch if a checkpoint pins another hash at the block's
+// height. The caller must hold the mutex.
+func (bc *Chain) checkCheckpoint(block Block) error {
+	if pinned, ok := bc.checkpoints[block.Index]; ok && block.Hash != pinned {
+		return fmt.Errorf("%w: %w: block %d is %s, not %s", ErrInvalidBlock, ErrCheckpointMismatch, block.Index, block.Hash, pinned)
+	}
+	return nil
+}
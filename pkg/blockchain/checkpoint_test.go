@@ -0,0 +1,115 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+)
+
+// forkAt returns a chain sharing chain's blocks up to height and then
+// mining length blocks of its own, the first carrying a transaction so it
+// differs from chain's block at that height
+func forkAt(t *testing.T, chain *Chain, height, length int) *Chain {
+	t.Helper()
+	fork := NewBlockchain()
+	for _, block := range chain.GetBlocks()[1 : height+1] {
+		if err := fork.AddExistingBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pool := NewTransactionPool(1)
+	if err := pool.AddTransaction(&Transaction{ID: "fork", To: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < length; i++ {
+		if _, err := fork.AddBlock(pool, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return fork
+}
+
+// newCheckpointedChain returns a chain of 4 blocks with block 2 pinned
+func newCheckpointedChain(t *testing.T) *Chain {
+	t.Helper()
+	chain := NewBlockchain()
+	for i := 0; i < 4; i++ {
+		if _, err := chain.AddBlock(nil, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pinned, _ := chain.GetBlockByIndex(2)
+	if err := chain.AddCheckpoint(2, pinned.Hash); err != nil {
+		t.Fatal(err)
+	}
+	return chain
+}
+
+func TestReplaceChainCannotCrossCheckpoint(t *testing.T) {
+	chain := newCheckpointedChain(t)
+	tip := chain.GetLatestBlock()
+
+	// Forking below the checkpoint rewrites the pinned block
+	fork := forkAt(t, chain, 1, 6)
+	if chain.ReplaceChain(fork.GetBlocks()) {
+		t.Fatal("replaced the chain with one crossing the checkpoint")
+	}
+	if chain.GetLatestBlock().Hash != tip.Hash {
+		t.Fatal("rejected replacement changed the chain")
+	}
+	if err := chain.Reorganize(fork.GetBlocks()[2:]); !errors.Is(err, ErrCheckpointMismatch) {
+		t.Fatalf("reorganizing across the checkpoint: got %v, want ErrCheckpointMismatch", err)
+	}
+}
+
+func TestReplaceChainAboveCheckpoint(t *testing.T) {
+	chain := newCheckpointedChain(t)
+
+	fork := forkAt(t, chain, 2, 4)
+	if !chain.ReplaceChain(fork.GetBlocks()) {
+		t.Fatal("rejected a longer chain keeping the checkpointed block")
+	}
+	if chain.GetLatestBlock().Hash != fork.GetLatestBlock().Hash {
+		t.Fatal("chain didn't switch to the fork")
+	}
+}
+
+func TestAddCheckpoint(t *testing.T) {
+	chain := NewBlockchain()
+	if err := chain.AddCheckpoint(5, "aa"); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.AddCheckpoint(5, "aa"); err != nil {
+		t.Fatalf("pinning the same hash again: %v", err)
+	}
+	if err := chain.AddCheckpoint(5, "bb"); err == nil {
+		t.Fatal("pinned a second hash at height 5")
+	}
+	for _, c := range []Checkpoint{{-1, "aa"}, {1, ""}} {
+		if err := chain.AddCheckpoint(c.Height, c.Hash); err == nil {
+			t.Errorf("accepted checkpoint %s", c)
+		}
+	}
+	if err := chain.AddCheckpoint(1, "cc"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := chain.Checkpoints()
+	if len(got) != 2 || got[0] != (Checkpoint{1, "cc"}) || got[1] != (Checkpoint{5, "aa"}) {
+		t.Fatalf("Checkpoints() = %v", got)
+	}
+}
+
+func TestParseCheckpoint(t *testing.T) {
+	got, err := ParseCheckpoint("12:abc")
+	if err != nil || got != (Checkpoint{12, "abc"}) {
+		t.Fatalf("got %v, %v", got, err)
+	}
+	if got.String() != "12:abc" {
+		t.Fatalf("String() = %q", got.String())
+	}
+	for _, s := range []string{"", "12", "12:", "x:abc", "-1:abc"} {
+		if _, err := ParseCheckpoint(s); err == nil {
+			t.Errorf("parsed %q", s)
+		}
+	}
+}
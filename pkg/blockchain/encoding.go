@@ -0,0 +1,115 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// flatBlock is the JSON encoding of a block: the fields of its header and
+// body side by side, as they were before Block was split into the two.
+// plainHeader keeps the header's UnmarshalJSON from taking over.
+type flatBlock struct {
+	plainHeader
+	BlockBody
+	Pruned bool `json:"pruned,omitempty"`
+}
+
+// MarshalJSON encodes the block as a flat object, giving blocks made
+// before version 2 their original string timestamp so their hash can still
+// be checked
+func (b Block) MarshalJSON() ([]byte, error) {
+	flat := flatBlock{plainHeader(b.BlockHeader), b.BlockBody, b.Pruned}
+	if b.legacyTimestamp == "" {
+		return json.Marshal(flat)
+	}
+	return json.Marshal(struct {
+		flatBlock
+		Timestamp string `json:"timestamp"`
+	}{flat, b.legacyTimestamp})
+}
+
+// UnmarshalJSON decodes a flat block, migrating the string timestamp of a
+// block made before version 2 to Unix seconds and a hex string nonce to a
+// number
+func (b *Block) UnmarshalJSON(data []byte) error {
+	var v struct {
+		flatBlock
+		Timestamp json.RawMessage `json:"timestamp"`
+		Nonce     json.RawMessage `json:"nonce"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*b = Block{BlockHeader: BlockHeader(v.plainHeader), BlockBody: v.BlockBody, Pruned: v.Pruned}
+
+	var err error
+	if b.Nonce, err = decodeNonce(v.Nonce); err != nil {
+		return fmt.Errorf("block %d: %w", b.Index, err)
+	}
+
+	if len(v.Timestamp) == 0 || v.Timestamp[0] != '"' {
+		if len(v.Timestamp) == 0 {
+			return nil
+		}
+		return json.Unmarshal(v.Timestamp, &b.Timestamp)
+	}
+
+	var legacy string
+	if err := json.Unmarshal(v.Timestamp, &legacy); err != nil {
+		return err
+	}
+	minted, err := ParseTimestamp(legacy)
+	if err != nil {
+		return fmt.Errorf("block %d has an unreadable timestamp %q", b.Index, legacy)
+	}
+	b.Timestamp, b.legacyTimestamp = minted.Unix(), legacy
+	return nil
+}
+
+// plainHeader has the fields of BlockHeader without its JSON methods
+type plainHeader BlockHeader
+
+// UnmarshalJSON decodes a header, accepting a hex string nonce
+func (h *BlockHeader) UnmarshalJSON(data []byte) error {
+	v := struct {
+		*plainHeader
+		Nonce json.RawMessage `json:"nonce"`
+	}{plainHeader: (*plainHeader)(h)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	var err error
+	if h.Nonce, err = decodeNonce(v.Nonce); err != nil {
+		return fmt.Errorf("header %d: %w", h.Index, err)
+	}
+	return nil
+}
+
+// decodeNonce reads a nonce encoded as a number, or as the hex string that
+// nodes sent before blocks had numeric nonces. Genesis blocks sent an empty
+// string. The strings are accepted for one release.
+func decodeNonce(raw json.RawMessage) (uint64, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return 0, nil
+	}
+	if raw[0] != '"' {
+		var nonce uint64
+		err := json.Unmarshal(raw, &nonce)
+		return nonce, err
+	}
+
+	var legacy string
+	if err := json.Unmarshal(raw, &legacy); err != nil {
+		return 0, err
+	}
+	if legacy == "" {
+		return 0, nil
+	}
+	nonce, err := strconv.ParseUint(legacy, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unreadable nonce %q", legacy)
+	}
+	return nonce, nil
+}
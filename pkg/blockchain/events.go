@@ -0,0 +1,113 @@
+package blockchain
+
+import "sync"
+
+// ChainEventType says how the chain changed
+type ChainEventType string
+
+// Chain event types
+const (
+	// EventBlockAdded follows a block appended to the tip, whether mined
+	// by AddBlock or received through AddExistingBlock
+	EventBlockAdded ChainEventType = "block_added"
+	// EventChainReplaced follows a change to blocks below the tip: a
+	// ReplaceChain, Reorganize, Restore or snapshot install
+	EventChainReplaced ChainEventType = "chain_replaced"
+)
+
+// ChainEvent describes a change to the chain
+type ChainEvent struct {
+	Type ChainEventType
+	// Block is the block added, or the new tip of a replaced chain
+	Block Block
+	// Mined is set for blocks mined by this chain's AddBlock, which the
+	// node still has to announce to peers
+	Mined bool
+	// Reorg is set for replacements by ReplaceChain or Reorganize that
+	// abandoned blocks
+	Reorg *Reorg
+}
+
+// chainEvents holds the subscribers of a chain. Events are delivered in
+// order, one at a time, after the chain's mutex is released, so subscribers
+// may read the chain. They must not modify it.
+type chainEvents struct {
+	mutex       sync.Mutex // Held while an event is delivered
+	subscribers map[int]func(ChainEvent)
+	next        int
+}
+
+// Subscribe has fn called with every later change to the chain, and
+// returns a function that ends the subscription
+func (bc *Chain) Subscribe(fn func(ChainEvent)) (unsubscribe func()) {
+	bc.events.mutex.Lock()
+	defer bc.events.mutex.Unlock()
+	if bc.events.subscribers == nil {
+		bc.events.subscribers = make(map[int]func(ChainEvent))
+	}
+	id := bc.events.next
+	bc.events.next++
+	bc.events.subscribers[id] = fn
+
+	return func() {
+		bc.events.mutex.Lock()
+		defer bc.events.mutex.Unlock()
+		delete(bc.events.subscribers, id)
+	}
+}
+
+// OnBlockAdded has fn called with every block later added to the tip
+func (bc *Chain) OnBlockAdded(fn func(block Block)) (unsubscribe func()) {
+	return bc.Subscribe(func(event ChainEvent) {
+		if event.Type == EventBlockAdded {
+			fn(event.Block)
+		}
+	})
+}
+
+// OnChainReplaced has fn called with the new tip whenever the chain is
+// later replaced
+func (bc *Chain) OnChainReplaced(fn func(tip Block)) (unsubscribe func()) {
+	return bc.Subscribe(func(event ChainEvent) {
+		if event.Type == EventChainReplaced {
+			fn(event.Block)
+		}
+	})
+}
+
+// OnReorg has fn called whenever ReplaceChain or Reorganize later abandons
+// blocks, with the record of the switch
+func (bc *Chain) OnReorg(fn func(reorg Reorg)) (unsubscribe func()) {
+	return bc.Subscribe(func(event ChainEvent) {
+		if event.Reorg != nil {
+			fn(*event.Reorg)
+		}
+	})
+}
+
+// notify records an event for unlock to deliver. The caller must hold the
+// mutex.
+func (bc *Chain) notify(eventType ChainEventType, block Block, mined bool) {
+	bc.pending = append(bc.pending, ChainEvent{Type: eventType, Block: block, Mined: mined})
+}
+
+// unlock releases the chain's write lock and then delivers the events
+// recorded while it was held. The event lock is taken before the chain's
+// is released, so events reach subscribers in the order of the changes.
+func (bc *Chain) unlock() {
+	events := bc.pending
+	bc.pending = nil
+	if len(events) == 0 {
+		bc.mutex.Unlock()
+		return
+	}
+	bc.events.mutex.Lock()
+	defer bc.events.mutex.Unlock()
+	bc.mutex.Unlock()
+
+	for _, event := range events {
+		for _, fn := range bc.events.subscribers {
+			fn(event)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package blockchain
+
+import "testing"
+
+func TestEverySubscriberSeesEveryEvent(t *testing.T) {
+	chain := NewBlockchain()
+	var first, second []ChainEvent
+	chain.Subscribe(func(event ChainEvent) { first = append(first, event) })
+	chain.Subscribe(func(event ChainEvent) { second = append(second, event) })
+
+	block, err := chain.AddBlock(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	longer := NewBlockchain()
+	for i := 0; i < 3; i++ {
+		if _, err := longer.AddBlock(nil, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !chain.ReplaceChain(longer.GetBlocks()) {
+		t.Fatal("longer chain wasn't accepted")
+	}
+
+	for name, events := range map[string][]ChainEvent{"first": first, "second": second} {
+		if len(events) != 2 {
+			t.Fatalf("%s subscriber got %d events, want 2", name, len(events))
+		}
+		if events[0].Type != EventBlockAdded || events[0].Block.Hash != block.Hash || !events[0].Mined {
+			t.Errorf("%s subscriber's first event is %+v, want the mined block", name, events[0])
+		}
+		if events[1].Type != EventChainReplaced || events[1].Block.Hash != longer.GetLatestBlock().Hash {
+			t.Errorf("%s subscriber's second event is %+v, want the replaced chain's tip", name, events[1])
+		}
+	}
+}
+
+func TestSubscribersMayReadTheChain(t *testing.T) {
+	chain := NewBlockchain()
+	var heights []int
+	chain.OnBlockAdded(func(block Block) {
+		// Would deadlock if events were delivered under the chain mutex
+		heights = append(heights, chain.Height())
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := chain.AddBlock(nil, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(heights) != 2 || heights[0] != 1 || heights[1] != 2 {
+		t.Fatalf("subscriber saw heights %v, want [1 2]", heights)
+	}
+}
+
+func TestTypedSubscriptions(t *testing.T) {
+	chain := NewBlockchain()
+	var added, replaced int
+	chain.OnBlockAdded(func(Block) { added++ })
+	chain.OnChainReplaced(func(Block) { replaced++ })
+
+	if _, err := chain.AddBlock(nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	longer := NewBlockchain()
+	for i := 0; i < 2; i++ {
+		if _, err := longer.AddBlock(nil, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chain.ReplaceChain(longer.GetBlocks())
+
+	if added != 1 || replaced != 1 {
+		t.Fatalf("got %d added and %d replaced events, want 1 of each", added, replaced)
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	chain := NewBlockchain()
+	var kept, dropped int
+	chain.Subscribe(func(ChainEvent) { kept++ })
+	unsubscribe := chain.Subscribe(func(ChainEvent) { dropped++ })
+
+	if _, err := chain.AddBlock(nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	unsubscribe()
+	if _, err := chain.AddBlock(nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if kept != 2 || dropped != 1 {
+		t.Fatalf("kept subscriber got %d events and dropped one %d, want 2 and 1", kept, dropped)
+	}
+}
+
+func TestRejectedChangesSendNoEvents(t *testing.T) {
+	chain := NewBlockchain()
+	if _, err := chain.AddBlock(nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	var events int
+	chain.Subscribe(func(ChainEvent) { events++ })
+
+	if chain.ReplaceChain(NewBlockchain().GetBlocks()) {
+		t.Fatal("replaced the chain with a shorter one")
+	}
+	if events != 0 {
+		t.Fatalf("got %d events for a rejected replacement", events)
+	}
+}
@@ -0,0 +1,76 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// FaucetAddress is the sender of faucet transactions, which create funds
+// out of nothing and are only valid on development chains
+const FaucetAddress = "faucet"
+
+// ErrFaucetDisabled is returned for faucet transactions outside dev mode
+var ErrFaucetDisabled = errors.New("faucet transactions are only valid in dev mode")
+
+// IsFaucetTransaction reports whether tx is a faucet transaction
+func IsFaucetTransaction(tx Transaction) bool {
+	return tx.From == FaucetAddress
+}
+
+// NewFaucetTransaction creates a faucet transaction crediting amount to an
+// address
+func NewFaucetTransaction(to string, amount float64, now time.Time) *Transaction {
+	return &Transaction{
+		ID:        fmt.Sprintf("faucet-%d", now.UnixNano()),
+		From:      FaucetAddress,
+		To:        to,
+		Value:     amount,
+		Timestamp: now,
+	}
+}
+
+// hasFaucetTransactions reports whether a block carries faucet transactions
+func hasFaucetTransactions(block Block) bool {
+	for _, tx := range BlockTransactions(block) {
+		if IsFaucetTransaction(tx) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateDevGenesisBlock creates the genesis block of a development chain,
+// funding each address with its allocation through faucet transactions.
+// The block depends only on the allocations.
+func CreateDevGenesisBlock(allocations map[string]float64) Block {
+	addresses := make([]string, 0, len(allocations))
+	for address := range allocations {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	txs := make([]Transaction, len(addresses))
+	for i, address := range addresses {
+		txs[i] = Transaction{
+			ID:        fmt.Sprintf("genesis-%d", i),
+			From:      FaucetAddress,
+			To:        address,
+			Value:     allocations[address],
+			Timestamp: genesisTime,
+		}
+	}
+	genesisBlock := Block{
+		BlockHeader: BlockHeader{
+			Version:    BlockVersion,
+			Index:      0,
+			Timestamp:  genesisTime.Unix(),
+			MerkleRoot: ComputeMerkleRoot(txs),
+			Difficulty: 1,
+		},
+		BlockBody: BlockBody{Transactions: txs},
+	}
+	genesisBlock.Hash = CalculateHash(genesisBlock)
+	return genesisBlock
+}
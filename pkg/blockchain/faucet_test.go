@@ -0,0 +1,68 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newDevChain returns a dev mode chain whose genesis funds alice and bob
+func newDevChain() *Chain {
+	chain := NewBlockchainWithGenesis(CreateDevGenesisBlock(map[string]float64{"alice": 100, "bob": 50}), DefaultBlockLimits())
+	chain.SetDevMode(true)
+	return chain
+}
+
+func TestDevGenesisFundsAccounts(t *testing.T) {
+	chain := newDevChain()
+	if chain.Balance("alice") != 100 || chain.Balance("bob") != 50 {
+		t.Fatalf("balances %v", chain.Balances())
+	}
+	if _, err := chain.Validate(); err != nil {
+		t.Fatalf("dev chain invalid: %v", err)
+	}
+
+	again := CreateDevGenesisBlock(map[string]float64{"bob": 50, "alice": 100})
+	if again.Hash != chain.Genesis().Hash {
+		t.Fatal("dev genesis depends on more than its allocations")
+	}
+	if CreateDevGenesisBlock(map[string]float64{"alice": 1}).Hash == again.Hash {
+		t.Fatal("different allocations gave the same genesis")
+	}
+}
+
+func TestFaucetTransactionsOnlyInDevMode(t *testing.T) {
+	drip := *NewFaucetTransaction("carol", 10, time.Now())
+
+	chain := newDevChain()
+	block := mineBlock(t, chain.GetLatestBlock(), 0, drip)
+	if err := chain.AddExistingBlock(block); err != nil {
+		t.Fatalf("dev chain rejected a faucet block: %v", err)
+	}
+	if chain.Balance("carol") != 10 {
+		t.Fatalf("carol has %g after the drip, want 10", chain.Balance("carol"))
+	}
+
+	chain = NewBlockchain()
+	block = mineBlock(t, chain.GetLatestBlock(), 0, drip)
+	if err := chain.AddExistingBlock(block); !errors.Is(err, ErrInvalidBlock) {
+		t.Fatalf("faucet block outside dev mode: got %v, want ErrInvalidBlock", err)
+	}
+
+	// A dev genesis isn't valid on a chain outside dev mode either
+	chain = NewBlockchainWithGenesis(newDevChain().Genesis(), DefaultBlockLimits())
+	if _, err := chain.Validate(); err == nil {
+		t.Fatal("dev genesis validated outside dev mode")
+	}
+}
+
+func TestPoolAdmitsFaucetTransactionsWhenAllowed(t *testing.T) {
+	pool := NewTransactionPool(10)
+	if err := pool.AddTransaction(NewFaucetTransaction("carol", 10, time.Now())); !errors.Is(err, ErrFaucetDisabled) {
+		t.Fatalf("got %v, want ErrFaucetDisabled", err)
+	}
+	pool.AllowFaucet(true)
+	if err := pool.AddTransaction(NewFaucetTransaction("carol", 10, time.Now())); err != nil {
+		t.Fatal(err)
+	}
+}
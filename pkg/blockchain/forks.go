@@ -0,0 +1,176 @@
+package blockchain
+
+import (
+	"sync"
+	"time"
+)
+
+// Bounds of what a ForkManager keeps
+const (
+	// MaxSideBranchDepth is how far below the tip a side branch may fork
+	// off and still be kept, and how many of its blocks are kept
+	MaxSideBranchDepth = 50
+	// MaxReorgRecords is how many reorganizations are remembered
+	MaxReorgRecords = 100
+)
+
+// SideBranch is a run of blocks the chain held until a reorganization or
+// replacement abandoned them for a branch with more work
+type SideBranch struct {
+	// AncestorHeight and AncestorHash identify the last block the branch
+	// shares with the chain
+	AncestorHeight int    `json:"ancestorHeight"`
+	AncestorHash   string `json:"ancestorHash"`
+	// Blocks are the abandoned blocks, oldest first, at most
+	// MaxSideBranchDepth of them
+	Blocks []Block `json:"blocks"`
+}
+
+// Reorg records a switch from one branch of the chain to another
+type Reorg struct {
+	Time           time.Time `json:"time"`
+	AncestorHeight int       `json:"ancestorHeight"`
+	AncestorHash   string    `json:"ancestorHash"`
+	OldTipHeight   int       `json:"oldTipHeight"`
+	OldTipHash     string    `json:"oldTipHash"`
+	NewTipHeight   int       `json:"newTipHeight"`
+	NewTipHash     string    `json:"newTipHash"`
+	// AbandonedBlocks is how many blocks the switch abandoned
+	AbandonedBlocks int `json:"abandonedBlocks"`
+	// Transactions were mined in the abandoned blocks but not in the
+	// branch that replaced them, so they are no longer on the chain
+	Transactions []Transaction `json:"transactions"`
+}
+
+// ForkManager keeps the side branches recent reorganizations abandoned and
+// a record of the reorganizations. Each chain has one, which records every
+// ReplaceChain and Reorganize that abandons blocks.
+type ForkManager struct {
+	mutex    sync.Mutex
+	branches []SideBranch
+	reorgs   []Reorg
+}
+
+// NewForkManager creates a fork manager with nothing recorded
+func NewForkManager() *ForkManager {
+	return &ForkManager{}
+}
+
+// SideBranches returns the side branches forking off no more than
+// MaxSideBranchDepth blocks below the tip, most recently abandoned first
+func (f *ForkManager) SideBranches() []SideBranch {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	branches := make([]SideBranch, len(f.branches))
+	for i, branch := range f.branches {
+		branches[len(branches)-1-i] = branch
+	}
+	return branches
+}
+
+// Reorgs returns the recorded reorganizations, most recent first
+func (f *ForkManager) Reorgs() []Reorg {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	reorgs := make([]Reorg, len(f.reorgs))
+	for i, reorg := range f.reorgs {
+		reorgs[len(reorgs)-1-i] = reorg
+	}
+	return reorgs
+}
+
+// record keeps the blocks above ancestor that winning replaces, and
+// returns the record of the switch, with the transactions of the
+// abandoned blocks that winning doesn't include
+func (f *ForkManager) record(ancestor Block, abandoned, winning []Block) Reorg {
+	included := make(map[string]bool)
+	for _, block := range winning {
+		for _, tx := range BlockTransactions(block) {
+			included[tx.ID] = true
+		}
+	}
+	var lost []Transaction
+	for _, block := range abandoned {
+		for _, tx := range BlockTransactions(block) {
+			if !included[tx.ID] {
+				lost = append(lost, tx)
+			}
+		}
+	}
+
+	oldTip, newTip := abandoned[len(abandoned)-1], ancestor
+	if len(winning) > 0 {
+		newTip = winning[len(winning)-1]
+	}
+	reorg := Reorg{
+		Time:            time.Now(),
+		AncestorHeight:  ancestor.Index,
+		AncestorHash:    ancestor.Hash,
+		OldTipHeight:    oldTip.Index,
+		OldTipHash:      oldTip.Hash,
+		NewTipHeight:    newTip.Index,
+		NewTipHash:      newTip.Hash,
+		AbandonedBlocks: len(abandoned),
+		Transactions:    lost,
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.branches = append(f.branches, SideBranch{
+		AncestorHeight: ancestor.Index,
+		AncestorHash:   ancestor.Hash,
+		Blocks:         append([]Block(nil), abandoned[:min(len(abandoned), MaxSideBranchDepth)]...),
+	})
+	f.pruneLocked(newTip.Index)
+	f.reorgs = append(f.reorgs, reorg)
+	if len(f.reorgs) > MaxReorgRecords {
+		f.reorgs = append([]Reorg(nil), f.reorgs[len(f.reorgs)-MaxReorgRecords:]...)
+	}
+	return reorg
+}
+
+// prune drops the side branches forking off more than MaxSideBranchDepth
+// blocks below height
+func (f *ForkManager) prune(height int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.pruneLocked(height)
+}
+
+// pruneLocked is prune for callers holding the mutex
+func (f *ForkManager) pruneLocked(height int) {
+	kept := f.branches[:0]
+	for _, branch := range f.branches {
+		if height-branch.AncestorHeight <= MaxSideBranchDepth {
+			kept = append(kept, branch)
+		}
+	}
+	clear(f.branches[len(kept):])
+	f.branches = kept
+}
+
+// Forks returns the chain's fork manager
+func (bc *Chain) Forks() *ForkManager {
+	return bc.forks
+}
+
+// switchBranch makes blocks the chain, recording a reorganization when
+// blocks above their last common block with the current chain are
+// abandoned, and announces the change. The caller must hold the mutex and
+// have set the snapshot base for blocks.
+func (bc *Chain) switchBranch(blocks []Block) {
+	var reorg *Reorg
+	common := 0
+	for common+1 < min(len(blocks), len(bc.Blocks)) && blocks[common+1].Hash == bc.Blocks[common+1].Hash {
+		common++
+	}
+	if abandoned := bc.Blocks[common+1:]; len(abandoned) > 0 {
+		record := bc.forks.record(bc.Blocks[common], abandoned, blocks[common+1:])
+		reorg = &record
+	}
+
+	bc.setBlocks(blocks)
+	bc.pending = append(bc.pending, ChainEvent{Type: EventChainReplaced, Block: bc.Blocks[len(bc.Blocks)-1], Reorg: reorg})
+}
@@ -0,0 +1,107 @@
+package blockchain
+
+import "testing"
+
+// mineTransactions adds one block per transaction to chain
+func mineTransactions(t *testing.T, chain *Chain, txs ...Transaction) {
+	t.Helper()
+	pool := NewTransactionPool(len(txs))
+	for i := range txs {
+		if err := pool.AddTransaction(&txs[i]); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := chain.AddBlock(pool, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReorgRequeuesAbandonedTransactions(t *testing.T) {
+	chain := NewBlockchain()
+	pool := NewTransactionPool(10)
+	chain.OnReorg(func(reorg Reorg) { pool.Requeue(reorg.Transactions) })
+	mineTransactions(t, chain, Transaction{ID: "a", To: "bob"}, Transaction{ID: "b", To: "bob"}, Transaction{ID: "c", To: "bob"})
+	abandoned := chain.GetBlocks()[1:]
+
+	// The winning branch mines b again, and more blocks than ours
+	winner := NewBlockchain()
+	mineTransactions(t, winner, Transaction{ID: "b", To: "bob"}, Transaction{ID: "d", To: "carol"})
+	for i := 0; i < 2; i++ {
+		if _, err := winner.AddBlock(nil, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !chain.ReplaceChain(winner.GetBlocks()) {
+		t.Fatal("winning branch wasn't accepted")
+	}
+
+	reorgs := chain.Forks().Reorgs()
+	if len(reorgs) != 1 {
+		t.Fatalf("recorded %d reorgs, want 1", len(reorgs))
+	}
+	reorg := reorgs[0]
+	if reorg.AncestorHeight != 0 || reorg.AbandonedBlocks != 3 || reorg.OldTipHash != abandoned[2].Hash || reorg.NewTipHeight != 4 {
+		t.Fatalf("recorded %+v", reorg)
+	}
+	if len(reorg.Transactions) != 2 || reorg.Transactions[0].ID != "a" || reorg.Transactions[1].ID != "c" {
+		t.Fatalf("lost transactions %+v, want a and c", reorg.Transactions)
+	}
+
+	if pool.Count() != 2 {
+		t.Fatalf("pool holds %d transactions, want a and c", pool.Count())
+	}
+	for _, id := range []string{"a", "c"} {
+		if _, err := pool.GetTransaction(id); err != nil {
+			t.Errorf("transaction %s wasn't requeued: %v", id, err)
+		}
+	}
+
+	branches := chain.Forks().SideBranches()
+	if len(branches) != 1 || len(branches[0].Blocks) != 3 || branches[0].Blocks[0].Hash != abandoned[0].Hash {
+		t.Fatalf("side branches %+v, want the abandoned blocks", branches)
+	}
+}
+
+func TestExtendingTheChainIsNoReorg(t *testing.T) {
+	chain := NewBlockchain()
+	if _, err := chain.AddBlock(nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	longer := NewBlockchain()
+	for _, block := range chain.GetBlocks()[1:] {
+		if err := longer.AddExistingBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := longer.AddBlock(nil, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if !chain.ReplaceChain(longer.GetBlocks()) {
+		t.Fatal("longer chain wasn't accepted")
+	}
+	if n := len(chain.Forks().Reorgs()); n != 0 {
+		t.Fatalf("recorded %d reorgs for a chain that only grew", n)
+	}
+}
+
+func TestSideBranchesArePruned(t *testing.T) {
+	f := NewForkManager()
+	ancestor := Block{BlockHeader: BlockHeader{Index: 10, Hash: "a"}}
+	f.record(ancestor, []Block{{BlockHeader: BlockHeader{Index: 11, Hash: "old"}}}, nil)
+	if len(f.SideBranches()) != 1 {
+		t.Fatal("side branch wasn't kept")
+	}
+
+	f.prune(10 + MaxSideBranchDepth)
+	if len(f.SideBranches()) != 1 {
+		t.Fatal("dropped a side branch within the depth")
+	}
+	f.prune(11 + MaxSideBranchDepth)
+	if len(f.SideBranches()) != 0 {
+		t.Fatal("kept a side branch beyond the depth")
+	}
+	if len(f.Reorgs()) != 1 {
+		t.Fatal("pruning dropped the reorg record")
+	}
+}
@@ -0,0 +1,47 @@
+package blockchain
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultNetworkID names the network whose genesis block CreateGenesisBlock
+// makes
+const DefaultNetworkID = "simple-blockchain"
+
+// ErrGenesisMismatch is returned for chains that start from another genesis
+// block than ours, which belong to another network
+var ErrGenesisMismatch = errors.New("chain starts from a different genesis block")
+
+// genesisTime is the timestamp of every genesis block, so nodes started
+// independently share their genesis
+var genesisTime = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// CreateGenesisBlock creates the first block in the blockchain of the
+// default network
+func CreateGenesisBlock() Block {
+	return CreateNetworkGenesisBlock(DefaultNetworkID)
+}
+
+// CreateNetworkGenesisBlock creates the first block in the blockchain of a
+// network. It depends only on the network ID, so every node on the network
+// starts from the same block, and test networks start from their own.
+func CreateNetworkGenesisBlock(networkID string) Block {
+	data := "Genesis Block"
+	if networkID != DefaultNetworkID {
+		data += " of " + networkID
+	}
+	genesisBlock := Block{
+		BlockHeader: BlockHeader{
+			Version:    BlockVersion,
+			Index:      0,
+			Timestamp:  genesisTime.Unix(),
+			Difficulty: 1,
+			PrevHash:   "",
+			DataHash:   ComputeDataHash(data),
+		},
+		BlockBody: BlockBody{Data: data},
+	}
+	genesisBlock.Hash = CalculateHash(genesisBlock)
+	return genesisBlock
+}
@@ -0,0 +1,48 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFreshChainsShareGenesis(t *testing.T) {
+	a := NewBlockchain()
+	time.Sleep(1100 * time.Millisecond)
+	b := NewBlockchain()
+	if a.Genesis().Hash != b.Genesis().Hash {
+		t.Fatalf("genesis hashes differ: %s and %s", a.Genesis().Hash, b.Genesis().Hash)
+	}
+	if err := ValidateGenesis(a.Genesis()); err != nil {
+		t.Fatalf("genesis invalid: %v", err)
+	}
+}
+
+func TestNetworksHaveTheirOwnGenesis(t *testing.T) {
+	if CreateNetworkGenesisBlock(DefaultNetworkID).Hash != CreateGenesisBlock().Hash {
+		t.Fatal("the default network's genesis isn't CreateGenesisBlock's")
+	}
+	testnet := CreateNetworkGenesisBlock("testnet")
+	if testnet.Hash == CreateGenesisBlock().Hash {
+		t.Fatal("testnet shares mainnet's genesis")
+	}
+	if testnet.Hash != CreateNetworkGenesisBlock("testnet").Hash {
+		t.Fatal("testnet genesis isn't deterministic")
+	}
+}
+
+func TestReplaceChainRejectsOtherGenesis(t *testing.T) {
+	chain := NewBlockchain()
+	other := NewBlockchainWithGenesis(CreateNetworkGenesisBlock("testnet"), DefaultBlockLimits())
+	for i := 0; i < 3; i++ {
+		if _, err := other.AddBlock(nil, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if chain.ReplaceChain(other.GetBlocks()) {
+		t.Fatal("replaced the chain with one from another network")
+	}
+	if chain.Height() != 0 || chain.Genesis().Hash != CreateGenesisBlock().Hash {
+		t.Fatal("rejected replacement changed the chain")
+	}
+}
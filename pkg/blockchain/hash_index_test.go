@@ -0,0 +1,90 @@
+package blockchain
+
+import (
+	"fmt"
+	"testing"
+)
+
+// checkIndexed fails the test unless every block is found by its hash at
+// its place and none of gone is found
+func checkIndexed(t *testing.T, chain *Chain, blocks, gone []Block) {
+	t.Helper()
+	for _, block := range blocks {
+		got, ok := chain.GetBlockByHash(block.Hash)
+		if !ok || got.Index != block.Index {
+			t.Errorf("block %d: found %t at %d", block.Index, ok, got.Index)
+		}
+	}
+	for _, block := range gone {
+		if _, ok := chain.GetBlockByHash(block.Hash); ok {
+			t.Errorf("removed block %d still found", block.Index)
+		}
+	}
+}
+
+func TestHashIndexFollowsTheChain(t *testing.T) {
+	chain := NewBlockchain()
+	for i := 0; i < 2; i++ {
+		if _, err := chain.AddBlock(nil, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ours := chain.GetBlocks()
+	checkIndexed(t, chain, ours, nil)
+	if _, ok := chain.GetBlockByHash("missing"); ok {
+		t.Error("found a block by an unknown hash")
+	}
+
+	// A heavier branch from genesis replaces both blocks
+	theirs := append(ours[:1:1], mineChain(t, ours[0], 2, 2, 2)...)
+	if !chain.ReplaceChain(theirs) {
+		t.Fatal("heavier chain not accepted")
+	}
+	checkIndexed(t, chain, theirs, ours[1:])
+
+	removed, err := chain.RollbackToHeight(1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIndexed(t, chain, theirs[:2], removed)
+}
+
+// linearFind looks a block up the way handleGetBlock did before the hash
+// index, for comparison
+func linearFind(bc *Chain, hash string) (Block, bool) {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+	for _, block := range bc.Blocks {
+		if block.Hash == hash {
+			return block, true
+		}
+	}
+	return Block{}, false
+}
+
+// BenchmarkGetBlockByHash compares the hash index with a scan on a chain
+// of 100k blocks, looking up one near the tip
+func BenchmarkGetBlockByHash(b *testing.B) {
+	blocks := make([]Block, 100000)
+	for i := range blocks {
+		blocks[i].Index, blocks[i].Hash = i, fmt.Sprintf("%064x", i)
+	}
+	chain := NewBlockchain()
+	chain.mutex.Lock()
+	chain.setBlocks(blocks)
+	chain.mutex.Unlock()
+	hash := blocks[len(blocks)-10].Hash
+
+	for name, find := range map[string]func(*Chain, string) (Block, bool){
+		"index": (*Chain).GetBlockByHash,
+		"scan":  linearFind,
+	} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, ok := find(chain, hash); !ok {
+					b.Fatal("block not found")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+// Names of the hash algorithms blocks may be hashed with
+const (
+	HashSHA256  = "sha256"
+	HashSHA3    = "sha3-256"
+	HashBLAKE2b = "blake2b-256"
+)
+
+// DefaultHashAlgo is the hash algorithm of blocks that don't record one
+const DefaultHashAlgo = HashSHA256
+
+// ErrUnknownHashAlgo is returned for hash algorithms this node doesn't
+// implement
+var ErrUnknownHashAlgo = errors.New("unknown hash algorithm")
+
+// Hasher is a hash function blocks can be hashed with. Every algorithm
+// makes 32-byte hashes, so difficulties mean the same under each.
+type Hasher interface {
+	// Name returns the name blocks record the algorithm by
+	Name() string
+
+	// Sum returns the hash of data
+	Sum(data []byte) [32]byte
+}
+
+// sha256Hasher hashes with SHA-256
+type sha256Hasher struct{}
+
+func (sha256Hasher) Name() string { return HashSHA256 }
+
+func (sha256Hasher) Sum(data []byte) [32]byte { return sha256.Sum256(data) }
+
+// sha3Hasher hashes with SHA3-256
+type sha3Hasher struct{}
+
+func (sha3Hasher) Name() string { return HashSHA3 }
+
+func (sha3Hasher) Sum(data []byte) [32]byte { return sha3.Sum256(data) }
+
+// blake2bHasher hashes with BLAKE2b-256
+type blake2bHasher struct{}
+
+func (blake2bHasher) Name() string { return HashBLAKE2b }
+
+func (blake2bHasher) Sum(data []byte) [32]byte { return blake2b.Sum256(data) }
+
+// hashers maps algorithm names to their hasher
+var hashers = map[string]Hasher{
+	HashSHA256:  sha256Hasher{},
+	HashSHA3:    sha3Hasher{},
+	HashBLAKE2b: blake2bHasher{},
+}
+
+// HasherFor returns the hasher of the named algorithm; an empty name is
+// DefaultHashAlgo
+func HasherFor(name string) (Hasher, error) {
+	if name == "" {
+		name = DefaultHashAlgo
+	}
+	hasher, ok := hashers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownHashAlgo, name)
+	}
+	return hasher, nil
+}
+
+// HashAlgos returns the names of the hash algorithms, sorted
+func HashAlgos() []string {
+	names := make([]string, 0, len(hashers))
+	for name := range hashers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// recordedAlgo returns the HashAlgo a block hashed by hasher records: none
+// for the default algorithm, so blocks hashed with it stay as they were
+func recordedAlgo(hasher Hasher) string {
+	if hasher == nil || hasher.Name() == DefaultHashAlgo {
+		return ""
+	}
+	return hasher.Name()
+}
+
+// checkHashAlgo checks that a block records a known hash algorithm, and
+// the default one by leaving it out. Blocks before version 4 predate the
+// choice.
+func checkHashAlgo(block Block) error {
+	if block.HashAlgo == "" {
+		return nil
+	}
+	if block.Version < 4 || block.HashAlgo == DefaultHashAlgo {
+		return fmt.Errorf("%w: block %d records hash algorithm %q", ErrInvalidBlock, block.Index, block.HashAlgo)
+	}
+	if _, err := HasherFor(block.HashAlgo); err != nil {
+		return fmt.Errorf("%w: block %d: %w", ErrInvalidBlock, block.Index, err)
+	}
+	return nil
+}
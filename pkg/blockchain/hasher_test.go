@@ -0,0 +1,98 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+)
+
+// mustHasher returns the named hasher or fails the test
+func mustHasher(t testing.TB, name string) Hasher {
+	t.Helper()
+	hasher, err := HasherFor(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hasher
+}
+
+func TestBlocksValidateWithTheirOwnAlgorithm(t *testing.T) {
+	genesis := CreateGenesisBlock()
+	for _, name := range HashAlgos() {
+		t.Run(name, func(t *testing.T) {
+			block, _, err := GenerateBlock(genesis, []Transaction{transfer("1", "", "bob", 1)}, 2, WithHasher(mustHasher(t, name)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := recordedAlgo(mustHasher(t, name)); block.HashAlgo != want {
+				t.Fatalf("block records %q, want %q", block.HashAlgo, want)
+			}
+			if err := ValidateBlock(block, genesis); err != nil {
+				t.Fatalf("block rejected: %v", err)
+			}
+
+			// The same header claiming another algorithm doesn't hash the same
+			for _, other := range HashAlgos() {
+				if other == name {
+					continue
+				}
+				relabelled := block
+				relabelled.HashAlgo = recordedAlgo(mustHasher(t, other))
+				if CalculateHash(relabelled) == block.Hash {
+					t.Errorf("%s and %s give the same hash", name, other)
+				}
+			}
+		})
+	}
+}
+
+func TestChainMixesAlgorithms(t *testing.T) {
+	chain := NewBlockchain()
+	for _, name := range []string{HashSHA3, HashBLAKE2b, HashSHA256} {
+		chain.SetHasher(mustHasher(t, name))
+		if _, err := chain.AddBlock(nil, 2); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	if _, err := chain.Validate(); err != nil {
+		t.Fatalf("chain with mixed algorithms invalid: %v", err)
+	}
+	blocks := chain.GetBlocks()
+	if blocks[1].HashAlgo != HashSHA3 || blocks[2].HashAlgo != HashBLAKE2b || blocks[3].HashAlgo != "" {
+		t.Fatalf("blocks record %q, %q and %q", blocks[1].HashAlgo, blocks[2].HashAlgo, blocks[3].HashAlgo)
+	}
+}
+
+func TestUnknownHashAlgo(t *testing.T) {
+	if _, err := HasherFor("md5"); !errors.Is(err, ErrUnknownHashAlgo) {
+		t.Fatalf("got %v, want ErrUnknownHashAlgo", err)
+	}
+
+	genesis := CreateGenesisBlock()
+	for _, algo := range []string{"md5", DefaultHashAlgo} {
+		block := mineBlock(t, genesis, 0)
+		block.HashAlgo = algo
+		block = rehash(block)
+		if err := ValidateBlock(block, genesis); !errors.Is(err, ErrInvalidBlock) {
+			t.Errorf("block recording %q: got %v, want ErrInvalidBlock", algo, err)
+		}
+	}
+}
+
+// BenchmarkMining compares how fast each algorithm mines a block
+func BenchmarkMining(b *testing.B) {
+	genesis := CreateGenesisBlock()
+	for _, name := range HashAlgos() {
+		hasher := mustHasher(b, name)
+		b.Run(name, func(b *testing.B) {
+			var hashes uint64
+			for i := 0; i < b.N; i++ {
+				_, tried, err := GenerateBlock(genesis, nil, 3, WithHasher(hasher))
+				if err != nil {
+					b.Fatal(err)
+				}
+				hashes += tried
+			}
+			b.ReportMetric(float64(hashes)/b.Elapsed().Seconds(), "hashes/s")
+		})
+	}
+}
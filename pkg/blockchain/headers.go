@@ -0,0 +1,123 @@
+package blockchain
+
+import (
+	"errors"
+	"sync"
+)
+
+// BlockHeader is a block without its body. MerkleRoot summarizes the
+// block's transactions so that light nodes can check inclusion proofs, and
+// DataHash its data. Hash covers the merkle root from block version 4 and
+// only the header from version 5; for older blocks the merkle root is
+// computed by the full node serving the header.
+type BlockHeader struct {
+	Version    int    `json:"version,omitempty"`
+	Index      int    `json:"index"`
+	Timestamp  int64  `json:"timestamp"` // Unix seconds
+	Hash       string `json:"hash"`
+	PrevHash   string `json:"prevHash"`
+	Difficulty int    `json:"difficulty"`
+	Nonce      uint64 `json:"nonce"`
+	// MerkleRoot is the ComputeMerkleRoot of the transactions of blocks
+	// from version 4, empty for blocks without any
+	MerkleRoot string `json:"merkleRoot,omitempty"`
+	// DataHash is the ComputeDataHash of the data of blocks from version
+	// 5, empty for blocks without any
+	DataHash string `json:"dataHash,omitempty"`
+	// HashAlgo names the algorithm the block is hashed with from version 4,
+	// empty for DefaultHashAlgo
+	HashAlgo string `json:"hashAlgo,omitempty"`
+	// Validator is the hex ed25519 public key that signed the block and
+	// Signature its hex signature of the hash, both empty for unsigned
+	// blocks. Proof of stake requires them; see SignBlock.
+	Validator string `json:"validator,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// Header returns the header of a block
+func (b Block) Header() BlockHeader {
+	header := b.BlockHeader
+	// The transactions of a pruned block are gone, and the root of one
+	// before version 4 with them
+	if b.Version < 4 && !b.Pruned {
+		header.MerkleRoot = ComputeMerkleRoot(BlockTransactions(b))
+	}
+	return header
+}
+
+// HeaderChain stores the header chain tracked by a light node. It starts
+// empty and adopts the first header it is given at index 0 as genesis.
+type HeaderChain struct {
+	headers []BlockHeader
+	byHash  map[string]int
+	mutex   sync.Mutex
+}
+
+// NewHeaderChain creates an empty header chain
+func NewHeaderChain() *HeaderChain {
+	return &HeaderChain{byHash: make(map[string]int)}
+}
+
+// AddHeader appends a header that extends the tip, or a genesis header to an
+// empty chain
+func (hc *HeaderChain) AddHeader(header BlockHeader) error {
+	hc.mutex.Lock()
+	defer hc.mutex.Unlock()
+
+	if len(hc.headers) == 0 {
+		if header.Index != 0 {
+			return errors.New("header chain must start at the genesis block")
+		}
+	} else {
+		tip := hc.headers[len(hc.headers)-1]
+		if header.Index != tip.Index+1 || header.PrevHash != tip.Hash {
+			return errors.New("header does not extend the current chain")
+		}
+	}
+
+	hc.byHash[header.Hash] = len(hc.headers)
+	hc.headers = append(hc.headers, header)
+	return nil
+}
+
+// Latest returns the tip of the header chain, and false while it is empty
+func (hc *HeaderChain) Latest() (BlockHeader, bool) {
+	hc.mutex.Lock()
+	defer hc.mutex.Unlock()
+
+	if len(hc.headers) == 0 {
+		return BlockHeader{}, false
+	}
+	return hc.headers[len(hc.headers)-1], true
+}
+
+// GetHeader returns the header with the given hash
+func (hc *HeaderChain) GetHeader(hash string) (BlockHeader, bool) {
+	hc.mutex.Lock()
+	defer hc.mutex.Unlock()
+
+	i, ok := hc.byHash[hash]
+	if !ok {
+		return BlockHeader{}, false
+	}
+	return hc.headers[i], true
+}
+
+// HeadersAfter returns up to count headers following the given index
+func (hc *HeaderChain) HeadersAfter(from, count int) []BlockHeader {
+	hc.mutex.Lock()
+	defer hc.mutex.Unlock()
+
+	start := from + 1
+	if start < 0 {
+		start = 0
+	}
+	if start > len(hc.headers) {
+		start = len(hc.headers)
+	}
+	end := start + count
+	if end > len(hc.headers) {
+		end = len(hc.headers)
+	}
+	return append([]BlockHeader(nil), hc.headers[start:end]...)
+}
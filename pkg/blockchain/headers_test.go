@@ -0,0 +1,99 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetHeadersPages(t *testing.T) {
+	chain := newGrownChain(t, 4)
+	blocks := chain.GetBlocks()
+
+	headers := chain.GetHeaders(1, 2)
+	if len(headers) != 2 || headers[0] != blocks[1].Header() || headers[1] != blocks[2].Header() {
+		t.Fatalf("got %+v, want the headers of blocks 1 and 2", headers)
+	}
+	for name, c := range map[string]struct{ from, limit, want int }{
+		"past the tip":      {5, 10, 0},
+		"up to the tip":     {3, 10, 2},
+		"negative from":     {-3, 2, 2},
+		"no limit":          {0, 0, 0},
+		"the whole chain":   {0, 100, 5},
+		"the last one only": {4, 1, 1},
+	} {
+		if got := len(chain.GetHeaders(c.from, c.limit)); got != c.want {
+			t.Errorf("%s: %d headers, want %d", name, got, c.want)
+		}
+	}
+}
+
+func TestHeaderHashCoversOnlyTheHeader(t *testing.T) {
+	tx := Transaction{ID: "tx", To: "bob", Value: 1, Timestamp: time.Now()}
+	block := mineBlock(t, CreateGenesisBlock(), 1, tx)
+	hash, ok := HeaderHash(block.Header())
+	if !ok || hash != block.Hash {
+		t.Fatalf("header hashes to %q, block to %q", hash, block.Hash)
+	}
+
+	// The header commits to the body through its merkle root
+	altered := block
+	altered.Transactions = []Transaction{{ID: "other", To: "mallory", Value: 100}}
+	if CalculateHash(altered) != block.Hash {
+		t.Fatal("the hash covers more than the header")
+	}
+	if err := ValidateBlock(altered, CreateGenesisBlock()); err == nil {
+		t.Fatal("block with a body its header doesn't commit to validated")
+	}
+
+	legacy := block.Header()
+	legacy.Version = 4
+	if _, ok := HeaderHash(legacy); ok {
+		t.Error("hashed a header of a block hashed with its body")
+	}
+}
+
+func TestOldBlockHeaderComputesMerkleRoot(t *testing.T) {
+	txs := []Transaction{{ID: "a", To: "bob"}, {ID: "b", To: "carol"}}
+	block := Block{BlockHeader: BlockHeader{Version: 3, Index: 1}, BlockBody: BlockBody{Transactions: txs}}
+	if got, want := block.Header().MerkleRoot, ComputeMerkleRoot(txs); got != want {
+		t.Fatalf("header of a version 3 block has root %q, want %q", got, want)
+	}
+}
+
+func TestBlockEncodesFlat(t *testing.T) {
+	tx := Transaction{ID: "tx", To: "bob", Value: 1, Timestamp: time.Now()}
+	block := mineBlock(t, CreateGenesisBlock(), 1, tx)
+	encoded, err := json.Marshal(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"index", "timestamp", "hash", "prevHash", "nonce", "merkleRoot", "transactions"} {
+		if _, ok := fields[key]; !ok {
+			t.Errorf("encoding lacks top-level %q: %s", key, encoded)
+		}
+	}
+	for key := range fields {
+		if strings.HasPrefix(key, "Block") {
+			t.Errorf("encoding nests %q: %s", key, encoded)
+		}
+	}
+
+	var decoded Block
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	reencoded, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(reencoded) != string(encoded) || CalculateHash(decoded) != block.Hash {
+		t.Fatalf("round trip changed the block:\n%s\n%s", encoded, reencoded)
+	}
+}
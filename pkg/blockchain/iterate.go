@@ -0,0 +1,102 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// IterateBatchSize is how many blocks Iterate copies per read lock
+const IterateBatchSize = 256
+
+// ErrChainChanged is returned by Iterate when the blocks it already walked
+// were replaced or rolled back before it finished
+var ErrChainChanged = errors.New("chain changed during iteration")
+
+// Iterate calls fn with the blocks from index from up to index to, both
+// included, oldest first, until fn returns false. A to past the tip walks
+// to the tip, including blocks added meanwhile. Blocks are copied under the
+// read lock IterateBatchSize at a time and fn runs without it, so memory
+// stays bounded however long the chain is and fn may take its time; if the
+// chain is replaced between batches, Iterate stops with ErrChainChanged.
+func (bc *Chain) Iterate(from, to int, fn func(Block) bool) error {
+	if from < 0 || to < from {
+		return fmt.Errorf("invalid block range %d to %d", from, to)
+	}
+
+	batch := make([]Block, 0, IterateBatchSize)
+	var last string
+	for next := from; next <= to; {
+		var err error
+		if batch, err = bc.copyBatch(batch[:0], next, to, last); err != nil || len(batch) == 0 {
+			return err
+		}
+		for _, block := range batch {
+			if !fn(block) {
+				return nil
+			}
+		}
+		last = batch[len(batch)-1].Hash
+		next += len(batch)
+	}
+	return nil
+}
+
+// copyBatch appends up to IterateBatchSize blocks from index from, up to
+// index to, to batch. When last is set, the block before from must still
+// have that hash.
+func (bc *Chain) copyBatch(batch []Block, from, to int, last string) ([]Block, error) {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	if last != "" && (from > len(bc.Blocks) || bc.Blocks[from-1].Hash != last) {
+		return nil, ErrChainChanged
+	}
+	end := min(from+IterateBatchSize, len(bc.Blocks))
+	if to < end-1 {
+		end = to + 1
+	}
+	if from >= end {
+		return batch, nil
+	}
+	return append(batch, bc.Blocks[from:end]...), nil
+}
+
+// BlocksFrom returns a copy of up to limit blocks starting at index from,
+// empty when from is past the tip, for paging through the chain without
+// copying all of it
+func (bc *Chain) BlocksFrom(from, limit int) []Block {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	from = max(from, 0)
+	if from >= len(bc.Blocks) || limit <= 0 {
+		return nil
+	}
+	end := len(bc.Blocks)
+	if limit < end-from {
+		end = from + limit
+	}
+	return append([]Block(nil), bc.Blocks[from:end]...)
+}
+
+// GetHeaders returns the headers of up to limit blocks starting at index
+// from, empty when from is past the tip, for light clients and header sync
+// that don't need the block bodies
+func (bc *Chain) GetHeaders(from, limit int) []BlockHeader {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	from = max(from, 0)
+	if from >= len(bc.Blocks) || limit <= 0 {
+		return nil
+	}
+	end := len(bc.Blocks)
+	if limit < end-from {
+		end = from + limit
+	}
+	headers := make([]BlockHeader, 0, end-from)
+	for _, block := range bc.Blocks[from:end] {
+		headers = append(headers, block.Header())
+	}
+	return headers
+}
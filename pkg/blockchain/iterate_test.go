@@ -0,0 +1,188 @@
+package blockchain
+
+import (
+	"errors"
+	"math"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+// iterated returns the indexes of the blocks Iterate walks from from to to
+func iterated(t *testing.T, chain *Chain, from, to int) []int {
+	t.Helper()
+	var indexes []int
+	if err := chain.Iterate(from, to, func(block Block) bool {
+		indexes = append(indexes, block.Index)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return indexes
+}
+
+func TestIterateWalksRangeAcrossBatches(t *testing.T) {
+	height := IterateBatchSize + 10
+	chain := newGrownChain(t, height)
+
+	for name, c := range map[string]struct{ from, to, first, count int }{
+		"the whole chain":        {0, math.MaxInt, 0, height + 1},
+		"within a batch":         {5, 7, 5, 3},
+		"across a batch":         {IterateBatchSize - 2, IterateBatchSize + 1, IterateBatchSize - 2, 4},
+		"up to the tip":          {height - 3, height + 100, height - 3, 4},
+		"the last one only":      {height, height, height, 1},
+		"past the tip":           {height + 1, math.MaxInt, 0, 0},
+		"exactly the first page": {0, IterateBatchSize - 1, 0, IterateBatchSize},
+	} {
+		indexes := iterated(t, chain, c.from, c.to)
+		if len(indexes) != c.count {
+			t.Errorf("%s: walked %d blocks, want %d", name, len(indexes), c.count)
+			continue
+		}
+		for i, index := range indexes {
+			if index != c.first+i {
+				t.Errorf("%s: block %d has index %d, want %d", name, i, index, c.first+i)
+				break
+			}
+		}
+	}
+
+	for _, r := range [][2]int{{-1, 5}, {5, 4}} {
+		if err := chain.Iterate(r[0], r[1], func(Block) bool { return true }); err == nil {
+			t.Errorf("range %d to %d accepted", r[0], r[1])
+		}
+	}
+}
+
+func TestIterateStopsWhenFnReturnsFalse(t *testing.T) {
+	chain := newGrownChain(t, IterateBatchSize+10)
+
+	var seen int
+	err := chain.Iterate(0, math.MaxInt, func(block Block) bool {
+		seen++
+		return block.Index < 3
+	})
+	if err != nil || seen != 4 {
+		t.Fatalf("got %v after %d blocks, want to stop cleanly after 4", err, seen)
+	}
+}
+
+func TestIterateReportsChainChanged(t *testing.T) {
+	t.Run("reorg", func(t *testing.T) {
+		// Blocks of difficulty 0, so the longer fork has more work
+		chain := NewBlockchain()
+		for i := 0; i < IterateBatchSize+10; i++ {
+			if _, err := chain.AddBlock(nil, 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		fork := forkAt(t, chain, 100, IterateBatchSize)
+
+		var seen int
+		err := chain.Iterate(0, math.MaxInt, func(Block) bool {
+			if seen == 0 && !chain.ReplaceChain(fork.GetBlocks()) {
+				t.Fatal("fork not adopted")
+			}
+			seen++
+			return true
+		})
+		if !errors.Is(err, ErrChainChanged) {
+			t.Fatalf("got %v, want ErrChainChanged", err)
+		}
+		if seen != IterateBatchSize {
+			t.Errorf("walked %d blocks, want only the first batch", seen)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		chain := newGrownChain(t, IterateBatchSize+10)
+
+		var seen int
+		err := chain.Iterate(0, math.MaxInt, func(Block) bool {
+			if seen == 0 {
+				if _, err := chain.RollbackToHeight(100, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			seen++
+			return true
+		})
+		if !errors.Is(err, ErrChainChanged) {
+			t.Fatalf("got %v, want ErrChainChanged", err)
+		}
+	})
+
+	t.Run("growth", func(t *testing.T) {
+		chain := newGrownChain(t, IterateBatchSize+10)
+
+		var seen int
+		err := chain.Iterate(0, math.MaxInt, func(Block) bool {
+			if seen == 0 {
+				if _, err := chain.AddBlock(nil, 1); err != nil {
+					t.Fatal(err)
+				}
+			}
+			seen++
+			return true
+		})
+		if err != nil || seen != IterateBatchSize+12 {
+			t.Fatalf("got %v after %d blocks, want the new tip walked too", err, seen)
+		}
+	})
+}
+
+func TestBlocksFromPages(t *testing.T) {
+	chain := newGrownChain(t, 4)
+	blocks := chain.GetBlocks()
+
+	page := chain.BlocksFrom(1, 2)
+	if len(page) != 2 || page[0].Hash != blocks[1].Hash || page[1].Hash != blocks[2].Hash {
+		t.Fatalf("got %d blocks, want blocks 1 and 2", len(page))
+	}
+	for name, c := range map[string]struct{ from, limit, want int }{
+		"past the tip":      {5, 10, 0},
+		"up to the tip":     {3, 10, 2},
+		"negative from":     {-3, 2, 2},
+		"no limit":          {0, 0, 0},
+		"the whole chain":   {0, 100, 5},
+		"the last one only": {4, 1, 1},
+	} {
+		if got := len(chain.BlocksFrom(c.from, c.limit)); got != c.want {
+			t.Errorf("%s: %d blocks, want %d", name, got, c.want)
+		}
+	}
+
+	// The page is a copy
+	page[0].Hash = "changed"
+	if got, _ := chain.GetBlockByIndex(1); got.Hash != blocks[1].Hash {
+		t.Fatal("changing a page changed the chain")
+	}
+}
+
+// BenchmarkIterate walks synthetic chains of growing length, reporting the
+// allocations per block alongside those per walk. Neither should grow with
+// the chain, which Iterate never copies whole.
+func BenchmarkIterate(b *testing.B) {
+	for _, height := range []int{1_000, 10_000, 100_000} {
+		chain := NewBlockchain()
+		for i := 1; i <= height; i++ {
+			chain.Blocks = append(chain.Blocks, Block{BlockHeader: BlockHeader{Index: i, Hash: "h", PrevHash: "h"}})
+		}
+
+		b.Run(strconv.Itoa(height), func(b *testing.B) {
+			b.ReportAllocs()
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			for i := 0; i < b.N; i++ {
+				if err := chain.Iterate(0, math.MaxInt, func(Block) bool { return true }); err != nil {
+					b.Fatal(err)
+				}
+			}
+			runtime.ReadMemStats(&after)
+
+			blocks := float64(b.N) * float64(height+1)
+			b.ReportMetric(float64(after.Mallocs-before.Mallocs)/blocks, "allocs/block")
+			b.ReportMetric(float64(after.TotalAlloc-before.TotalAlloc)/blocks, "B/block")
+		})
+	}
+}
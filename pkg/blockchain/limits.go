@@ -0,0 +1,100 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Default block limits
+const (
+	// DefaultMaxBlockBytes caps the JSON encoding of a block, which is how
+	// blocks travel between peers
+	DefaultMaxBlockBytes = 1 << 20
+	// DefaultMaxBlockTransactions caps how many transactions one block
+	// carries
+	DefaultMaxBlockTransactions = 100
+)
+
+// ErrBlockTooLarge is wrapped, along with ErrInvalidBlock, by the errors of
+// blocks over the chain's limits
+var ErrBlockTooLarge = errors.New("block too large")
+
+// BlockLimits caps the size of blocks, so that no block is too large to
+// broadcast to peers
+type BlockLimits struct {
+	// MaxBytes caps the size of a block's JSON encoding
+	MaxBytes int `json:"maxBytes"`
+	// MaxTransactions caps how many transactions a block carries
+	MaxTransactions int `json:"maxTransactions"`
+}
+
+// DefaultBlockLimits returns the limits of chains made by NewBlockchain
+func DefaultBlockLimits() BlockLimits {
+	return BlockLimits{
+		MaxBytes:        DefaultMaxBlockBytes,
+		MaxTransactions: DefaultMaxBlockTransactions,
+	}
+}
+
+// withDefaults replaces the limits that aren't positive by the defaults
+func (l BlockLimits) withDefaults() BlockLimits {
+	defaults := DefaultBlockLimits()
+	if l.MaxBytes <= 0 {
+		l.MaxBytes = defaults.MaxBytes
+	}
+	if l.MaxTransactions <= 0 {
+		l.MaxTransactions = defaults.MaxTransactions
+	}
+	return l
+}
+
+// Check returns an error wrapping ErrInvalidBlock and ErrBlockTooLarge if
+// block is over the limits
+func (l BlockLimits) Check(block Block) error {
+	if count := len(block.Transactions); count > l.MaxTransactions {
+		return fmt.Errorf("%w: %w: block %d has %d transactions, over the limit of %d", ErrInvalidBlock, ErrBlockTooLarge, block.Index, count, l.MaxTransactions)
+	}
+	if size := BlockSize(block); size > l.MaxBytes {
+		return fmt.Errorf("%w: %w: block %d is %d bytes, over the limit of %d", ErrInvalidBlock, ErrBlockTooLarge, block.Index, size, l.MaxBytes)
+	}
+	return nil
+}
+
+// TransactionBytes returns how many bytes of a block under the limits its
+// transactions may take, given the block without them
+func (l BlockLimits) TransactionBytes(block Block) int {
+	block.Transactions = nil
+	// The transactions field, which an empty block leaves out
+	overhead := BlockSize(asMined(block)) + len(`,"transactions":[]`)
+	return max(l.MaxBytes-overhead, 0)
+}
+
+// BlockSize returns the size of a block's JSON encoding
+func BlockSize(block Block) int {
+	data, err := json.Marshal(block)
+	if err != nil {
+		panic(fmt.Sprintf("encoding block: %v", err))
+	}
+	return len(data)
+}
+
+// TransactionSize returns the size of a transaction's JSON encoding, as a
+// block carries it
+func TransactionSize(tx Transaction) int {
+	return len(encodeTransactions([]Transaction{tx})) - len("[]")
+}
+
+// asMined returns a block yet to be mined with the longest hash, nonce and
+// merkle root it may get, so it is as large as the block once mined can be
+func asMined(block Block) Block {
+	block.Hash = strings.Repeat("0", 2*sha256.Size)
+	block.Nonce = math.MaxUint64
+	if block.Version >= 4 {
+		block.MerkleRoot = block.Hash
+	}
+	return block
+}
@@ -0,0 +1,97 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// padded returns a transaction carrying n bytes of data
+func padded(id string, n int) Transaction {
+	return Transaction{ID: id, To: "bob", Data: strings.Repeat("x", n)}
+}
+
+func TestLimitsTakeDefaults(t *testing.T) {
+	got := NewBlockchainWithGenesis(CreateGenesisBlock(), BlockLimits{MaxTransactions: 5}).Limits()
+	if got.MaxTransactions != 5 || got.MaxBytes != DefaultMaxBlockBytes {
+		t.Fatalf("got %+v, want 5 transactions and the default size", got)
+	}
+}
+
+func TestChainRefusesBlocksOverItsLimits(t *testing.T) {
+	limits := BlockLimits{MaxBytes: 4096, MaxTransactions: 2}
+	for name, txs := range map[string][]Transaction{
+		"too many transactions": {padded("1", 1), padded("2", 1), padded("3", 1)},
+		"too many bytes":        {padded("1", 8192)},
+	} {
+		chain := NewBlockchainWithGenesis(CreateGenesisBlock(), limits)
+		if _, err := chain.AddTransactionsContext(context.Background(), txs, 1); !errors.Is(err, ErrBlockTooLarge) || !errors.Is(err, ErrInvalidBlock) {
+			t.Errorf("%s: mining got %v, want ErrBlockTooLarge", name, err)
+		}
+
+		// Nor is one mined elsewhere accepted
+		block := mineBlock(t, chain.GetLatestBlock(), 1, txs...)
+		if err := chain.AddExistingBlock(block); !errors.Is(err, ErrBlockTooLarge) {
+			t.Errorf("%s: adding got %v, want ErrBlockTooLarge", name, err)
+		}
+		if chain.Height() != 0 {
+			t.Errorf("%s: chain grew to height %d", name, chain.Height())
+		}
+	}
+}
+
+func TestGetBatchRespectsCountAndBytes(t *testing.T) {
+	pool := NewTransactionPool(10)
+	for _, tx := range []Transaction{padded("big", 1000), padded("small-1", 10), padded("small-2", 10), padded("small-3", 10)} {
+		if err := pool.AddTransaction(&tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	small := TransactionSize(padded("small-1", 10))
+
+	ids := func(batch []*Transaction) string {
+		var ids []string
+		for _, tx := range batch {
+			ids = append(ids, tx.ID)
+		}
+		return strings.Join(ids, ",")
+	}
+	// The large transaction doesn't fit, so the smaller ones after it go
+	if got := ids(pool.GetBatch(10, 3*small+2)); got != "small-1,small-2,small-3" {
+		t.Errorf("byte budget took %s", got)
+	}
+	// The separating commas count against the budget
+	if got := ids(pool.GetBatch(10, 3*small+1)); got != "small-1,small-2" {
+		t.Errorf("byte budget short of a comma took %s", got)
+	}
+	if got := ids(pool.GetBatch(2, 1<<20)); got != "big,small-1" {
+		t.Errorf("count limit took %s", got)
+	}
+}
+
+func TestAddBlockFillsBlocksUpToTheLimits(t *testing.T) {
+	limits := BlockLimits{MaxBytes: 8192, MaxTransactions: 5}
+	chain := NewBlockchainWithGenesis(CreateGenesisBlock(), limits)
+	pool := NewTransactionPool(100)
+	for i := 0; i < 20; i++ {
+		tx := padded(fmt.Sprint(i), 1000)
+		if err := pool.AddTransaction(&tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for pool.Count() > 0 {
+		block, err := chain.AddBlock(pool, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(block.Transactions) == 0 || len(block.Transactions) > limits.MaxTransactions {
+			t.Fatalf("block %d carries %d transactions", block.Index, len(block.Transactions))
+		}
+		if size := BlockSize(block); size > limits.MaxBytes {
+			t.Fatalf("block %d is %d bytes, over %d", block.Index, size, limits.MaxBytes)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// MerkleStep is one sibling hash on the path from a leaf to the merkle root
+type MerkleStep struct {
+	Hash  string `json:"hash"`
+	Right bool   `json:"right"` // Sibling is on the right of the running hash
+}
+
+// TransactionHash returns the merkle leaf of a transaction, the SHA256 of
+// its JSON encoding
+func TransactionHash(tx Transaction) string {
+	data, _ := json.Marshal(tx)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// BlockTransactions returns the transactions mined into a block. Blocks
+// made before blocks carried transactions held them JSON-encoded in Data;
+// those whose data isn't a transaction list, such as the genesis block,
+// have none.
+func BlockTransactions(block Block) []Transaction {
+	if len(block.Transactions) > 0 || block.Data == "" {
+		return block.Transactions
+	}
+	var txs []Transaction
+	if err := json.Unmarshal([]byte(block.Data), &txs); err != nil {
+		return nil
+	}
+	return txs
+}
+
+// hashPair combines two merkle nodes
+func hashPair(left, right string) string {
+	sum := sha256.Sum256([]byte(left + right))
+	return hex.EncodeToString(sum[:])
+}
+
+// merkleLevels builds every level of the tree from the leaves up. An odd
+// node at the end of a level is paired with itself.
+func merkleLevels(leaves []string) [][]string {
+	levels := [][]string{leaves}
+	for level := leaves; len(level) > 1; {
+		next := make([]string, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			right := level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			next = append(next, hashPair(level[i], right))
+		}
+		levels = append(levels, next)
+		level = next
+	}
+	return levels
+}
+
+// ComputeMerkleRoot returns the merkle root of a block's transactions, or
+// an empty string for a block without transactions
+func ComputeMerkleRoot(txs []Transaction) string {
+	if len(txs) == 0 {
+		return ""
+	}
+	levels := merkleLevels(transactionLeaves(txs))
+	return levels[len(levels)-1][0]
+}
+
+// MerkleProof returns the path proving that the transaction at index is part
+// of the tree built from txs
+func MerkleProof(txs []Transaction, index int) ([]MerkleStep, error) {
+	if index < 0 || index >= len(txs) {
+		return nil, errors.New("transaction index out of range")
+	}
+
+	levels := merkleLevels(transactionLeaves(txs))
+	proof := make([]MerkleStep, 0, len(levels)-1)
+	for _, level := range levels[:len(levels)-1] {
+		sibling := index ^ 1
+		if sibling >= len(level) {
+			sibling = index
+		}
+		proof = append(proof, MerkleStep{Hash: level[sibling], Right: sibling >= index})
+		index /= 2
+	}
+	return proof, nil
+}
+
+// ErrTransactionNotInBlock is returned for proofs of transactions a block
+// doesn't carry
+var ErrTransactionNotInBlock = errors.New("transaction not in block")
+
+// GenerateMerkleProof returns the path proving that the transaction with
+// the given ID is part of a block, for VerifyMerkleProof against the
+// block's merkle root
+func GenerateMerkleProof(block Block, txID string) ([]MerkleStep, error) {
+	txs := BlockTransactions(block)
+	for i, tx := range txs {
+		if tx.ID == txID {
+			return MerkleProof(txs, i)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrTransactionNotInBlock, txID)
+}
+
+// VerifyMerkleProof reports whether proof links the transaction to root
+func VerifyMerkleProof(tx Transaction, proof []MerkleStep, root string) bool {
+	hash := TransactionHash(tx)
+	for _, step := range proof {
+		if step.Right {
+			hash = hashPair(hash, step.Hash)
+		} else {
+			hash = hashPair(step.Hash, hash)
+		}
+	}
+	return root != "" && hash == root
+}
+
+// transactionLeaves hashes transactions into merkle leaves
+func transactionLeaves(txs []Transaction) []string {
+	leaves := make([]string, len(txs))
+	for i, tx := range txs {
+		leaves[i] = TransactionHash(tx)
+	}
+	return leaves
+}
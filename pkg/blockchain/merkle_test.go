@@ -0,0 +1,83 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// transfers returns n distinct transactions
+func transfers(n int) []Transaction {
+	txs := make([]Transaction, n)
+	for i := range txs {
+		txs[i] = transfer(fmt.Sprint(i), "", "bob", float64(i))
+	}
+	return txs
+}
+
+func TestMerkleRoot(t *testing.T) {
+	txs := transfers(3)
+	a, b, c := TransactionHash(txs[0]), TransactionHash(txs[1]), TransactionHash(txs[2])
+
+	tests := []struct {
+		name string
+		txs  []Transaction
+		want string
+	}{
+		{"none", nil, ""},
+		{"one", txs[:1], a},
+		{"two", txs[:2], hashPair(a, b)},
+		{"three duplicates the last leaf", txs, hashPair(hashPair(a, b), hashPair(c, c))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ComputeMerkleRoot(tt.txs); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerkleProofsVerify(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 5, 8} {
+		txs := transfers(n)
+		block := mineBlock(t, CreateGenesisBlock(), 0, txs...)
+		if block.MerkleRoot != ComputeMerkleRoot(txs) {
+			t.Fatalf("%d transactions: block root %q isn't theirs", n, block.MerkleRoot)
+		}
+
+		for _, tx := range txs {
+			proof, err := GenerateMerkleProof(block, tx.ID)
+			if err != nil {
+				t.Fatalf("%d transactions: proof of %s: %v", n, tx.ID, err)
+			}
+			if !VerifyMerkleProof(tx, proof, block.MerkleRoot) {
+				t.Errorf("%d transactions: proof of %s doesn't verify", n, tx.ID)
+			}
+
+			altered := tx
+			altered.Value += 1
+			if VerifyMerkleProof(altered, proof, block.MerkleRoot) {
+				t.Errorf("%d transactions: proof of %s verifies an altered transaction", n, tx.ID)
+			}
+		}
+	}
+}
+
+func TestMerkleProofOfMissingTransaction(t *testing.T) {
+	block := mineBlock(t, CreateGenesisBlock(), 0, transfers(2)...)
+	if _, err := GenerateMerkleProof(block, "missing"); !errors.Is(err, ErrTransactionNotInBlock) {
+		t.Fatalf("got %v, want ErrTransactionNotInBlock", err)
+	}
+}
+
+func TestBlockWithWrongMerkleRootIsInvalid(t *testing.T) {
+	genesis := CreateGenesisBlock()
+	block := mineBlock(t, genesis, 0, transfers(3)...)
+
+	block.MerkleRoot = ComputeMerkleRoot(transfers(2))
+	block = rehash(block)
+	if err := ValidateBlock(block, genesis); !errors.Is(err, ErrInvalidBlock) {
+		t.Fatalf("got %v, want ErrInvalidBlock", err)
+	}
+}
@@ -0,0 +1,225 @@
+package blockchain
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// DefaultProgressInterval is how many attempts pass between progress
+// reports unless WithProgressInterval sets another interval
+const DefaultProgressInterval = 1 << 20
+
+// attemptBatch is how many nonces a worker tries between checking for
+// cancellation and adding to the shared attempt count
+const attemptBatch = 1024
+
+// ProgressFunc reports mining progress: the attempts made so far across
+// all workers, and the hash of the latest of them
+type ProgressFunc func(attempts uint64, currentHash string)
+
+// MineOption configures a single mining run
+type MineOption func(*mineOptions)
+
+// mineOptions are the settings of a mining run
+type mineOptions struct {
+	progress ProgressFunc
+	interval uint64
+	limits   *BlockLimits
+	hasher   Hasher
+	signer   ed25519.PrivateKey
+}
+
+// WithProgress has fn called as mining goes on, at most once every
+// progress interval. Calls don't overlap. Mining is silent without it.
+func WithProgress(fn ProgressFunc) MineOption {
+	return func(o *mineOptions) {
+		o.progress = fn
+	}
+}
+
+// WithProgressInterval sets how many attempts pass between progress
+// reports. It is rounded up to a multiple of 1024, the attempts a worker
+// makes between reports of its count.
+func WithProgressInterval(attempts uint64) MineOption {
+	return func(o *mineOptions) {
+		o.interval = attempts
+	}
+}
+
+// WithLimits has blocks over limits rejected before they are mined, with
+// the error of BlockLimits.Check
+func WithLimits(limits BlockLimits) MineOption {
+	return func(o *mineOptions) {
+		o.limits = &limits
+	}
+}
+
+// WithHasher has the block hashed with hasher instead of DefaultHashAlgo,
+// and records its algorithm in the block. A nil hasher leaves the default.
+func WithHasher(hasher Hasher) MineOption {
+	return func(o *mineOptions) {
+		if hasher != nil {
+			o.hasher = hasher
+		}
+	}
+}
+
+// Miner searches for block nonces on a pool of goroutines. Worker w of n
+// tries the nonces w, w+n, w+2n, ..., so the workers never repeat each
+// other's work, and all of them stop once one finds a hash that meets the
+// difficulty.
+type Miner struct {
+	workers int
+}
+
+// NewMiner creates a miner with the given number of workers, or one per
+// CPU when workers isn't positive
+func NewMiner(workers int) *Miner {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	return &Miner{workers: workers}
+}
+
+// Workers returns how many goroutines the miner searches on
+func (m *Miner) Workers() int {
+	return m.workers
+}
+
+// Mine creates a new block of transactions on oldBlock, like GenerateBlock,
+// searching for its nonce on all workers. It returns the block and how
+// many hashes were computed to find it.
+func (m *Miner) Mine(oldBlock Block, txs []Transaction, difficulty int, opts ...MineOption) (Block, uint64, error) {
+	return m.MineContext(context.Background(), oldBlock, txs, difficulty, opts...)
+}
+
+// MineContext creates a new block like Mine, but stops all workers and
+// returns the context error once ctx is done
+func (m *Miner) MineContext(ctx context.Context, oldBlock Block, txs []Transaction, difficulty int, opts ...MineOption) (Block, uint64, error) {
+	ctx, span := tracer().Start(ctx, "blockchain.mine")
+	defer span.End()
+
+	options := mineOptions{interval: DefaultProgressInterval, hasher: hashers[DefaultHashAlgo]}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var newBlock Block
+
+	newBlock.Version = BlockVersion
+	newBlock.Index = oldBlock.Index + 1
+	newBlock.Timestamp = time.Now().Unix()
+	newBlock.Transactions = txs
+	newBlock.MerkleRoot = ComputeMerkleRoot(txs)
+	newBlock.HashAlgo = recordedAlgo(options.hasher)
+	newBlock.PrevHash = oldBlock.Hash
+	newBlock.Difficulty = difficulty
+	span.SetAttributes(
+		attribute.Int("block.index", newBlock.Index),
+		attribute.Int("block.difficulty", difficulty),
+		attribute.Int("mining.workers", m.workers),
+	)
+	if options.limits != nil {
+		if err := options.limits.Check(withSignatureSpace(asMined(newBlock), options.signer)); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return Block{}, 0, err
+		}
+	}
+
+	searchCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	s := &search{options: options, workers: uint64(m.workers), done: searchCtx.Done()}
+	var (
+		once  sync.Once
+		found Block
+		wg    sync.WaitGroup
+	)
+	for w := 0; w < m.workers; w++ {
+		wg.Add(1)
+		go func(start uint64) {
+			defer wg.Done()
+			if block, ok := s.run(newBlock, start); ok {
+				once.Do(func() {
+					found = block
+					stop()
+				})
+			}
+		}(uint64(w))
+	}
+	wg.Wait()
+
+	attempts := s.attempts.Load()
+	span.SetAttributes(attribute.Int64("mining.attempts", int64(attempts)))
+	if found.Hash == "" {
+		err := ctx.Err()
+		span.SetStatus(codes.Error, err.Error())
+		return Block{}, attempts, err
+	}
+	if options.signer != nil {
+		SignBlock(&found, options.signer)
+	}
+	return found, attempts, nil
+}
+
+// search is the state the workers of a mining run share
+type search struct {
+	options  mineOptions
+	workers  uint64
+	done     <-chan struct{}
+	attempts atomic.Uint64
+	progress sync.Mutex // Keeps progress calls from overlapping
+}
+
+// run tries the nonces start, start+workers, ... on a copy of block until
+// one meets its difficulty or the search is stopped
+func (s *search) run(block Block, start uint64) (Block, bool) {
+	var (
+		tried uint64
+		sum   [32]byte
+	)
+	defer func() { s.count(tried, "") }()
+
+	hasher := newNonceHasher(block, s.options.hasher)
+	for nonce := start; ; nonce += s.workers {
+		if tried == attemptBatch {
+			select {
+			case <-s.done:
+				return Block{}, false
+			default:
+			}
+			s.count(tried, hex.EncodeToString(sum[:]))
+			tried = 0
+		}
+		tried++
+		sum = hasher.sum(nonce)
+		if sumMeetsDifficulty(sum, block.Difficulty) {
+			block.Nonce = nonce
+			block.Hash = hex.EncodeToString(sum[:])
+			return block, true
+		}
+	}
+}
+
+// count adds tried attempts to the total, reporting progress when the
+// total passes a multiple of the progress interval
+func (s *search) count(tried uint64, hash string) {
+	if tried == 0 {
+		return
+	}
+	total := s.attempts.Add(tried)
+	if s.options.progress == nil || hash == "" || s.options.interval == 0 || total/s.options.interval == (total-tried)/s.options.interval {
+		return
+	}
+	s.progress.Lock()
+	defer s.progress.Unlock()
+	s.options.progress(total, hash)
+}
@@ -0,0 +1,70 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestNewMinerDefaultsToOneWorkerPerCPU(t *testing.T) {
+	if n := NewMiner(0).Workers(); n != runtime.NumCPU() {
+		t.Errorf("default miner has %d workers, want %d", n, runtime.NumCPU())
+	}
+	if n := NewMiner(3).Workers(); n != 3 {
+		t.Errorf("miner has %d workers, want 3", n)
+	}
+}
+
+func TestParallelMinerFindsValidBlocks(t *testing.T) {
+	miner := NewMiner(4)
+	parent := CreateGenesisBlock()
+	tx := Transaction{ID: "tx", To: "bob", Timestamp: time.Now()}
+	for i := 0; i < 5; i++ {
+		block, attempts, err := miner.Mine(parent, []Transaction{tx}, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ValidateBlock(block, parent); err != nil {
+			t.Fatalf("block %d: %v", block.Index, err)
+		}
+		if attempts == 0 {
+			t.Errorf("block %d reported no attempts", block.Index)
+		}
+		parent = block
+	}
+}
+
+func TestCanceledMiningStopsEveryWorker(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	before := runtime.NumGoroutine()
+
+	// No hash meets a difficulty this high
+	_, _, err := NewMiner(4).MineContext(ctx, CreateGenesisBlock(), nil, 64)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the deadline", err)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines left running", after-before)
+	}
+}
+
+// BenchmarkParallelMining compares mining on one goroutine with a miner of
+// one worker per CPU
+func BenchmarkParallelMining(b *testing.B) {
+	genesis := CreateGenesisBlock()
+	for _, difficulty := range []int{4, 5} {
+		for name, miner := range map[string]*Miner{"single": NewMiner(1), "parallel": NewMiner(0)} {
+			b.Run(fmt.Sprintf("difficulty=%d/%s", difficulty, name), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, _, err := miner.Mine(genesis, nil, difficulty); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
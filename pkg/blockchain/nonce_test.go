@@ -0,0 +1,93 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNonceDecodesFromNumberOrLegacyString(t *testing.T) {
+	for raw, want := range map[string]uint64{
+		`1234`:                 1234,
+		`18446744073709551615`: 1<<64 - 1,
+		`"1f"`:                 0x1f,
+		`""`:                   0,
+		`null`:                 0,
+	} {
+		var header BlockHeader
+		if err := json.Unmarshal([]byte(`{"index":1,"nonce":`+raw+`}`), &header); err != nil {
+			t.Errorf("%s: %v", raw, err)
+			continue
+		}
+		if header.Nonce != want {
+			t.Errorf("%s decoded as %d, want %d", raw, header.Nonce, want)
+		}
+	}
+
+	for _, raw := range []string{`"xyz"`, `-1`, `true`} {
+		var block Block
+		if err := json.Unmarshal([]byte(`{"index":1,"nonce":`+raw+`}`), &block); err == nil {
+			t.Errorf("%s decoded", raw)
+		}
+	}
+}
+
+func TestNonceEncodesAsNumber(t *testing.T) {
+	block := mineBlock(t, CreateGenesisBlock(), 1)
+	block.Nonce = 0xabcdef
+	encoded, err := json.Marshal(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(encoded), `"nonce":11259375`) {
+		t.Fatalf("nonce not encoded as a number: %s", encoded)
+	}
+}
+
+func TestHashCoversNonce(t *testing.T) {
+	block := mineBlock(t, CreateGenesisBlock(), 1)
+	if CalculateHash(block) != block.Hash {
+		t.Fatal("mined block doesn't hash to its hash")
+	}
+	block.Nonce++
+	if CalculateHash(block) == block.Hash {
+		t.Fatal("changing the nonce kept the hash")
+	}
+}
+
+func TestNonceHashingDoesNotAllocate(t *testing.T) {
+	block := mineBlock(t, CreateGenesisBlock(), 1)
+	h := newNonceHasher(block, hashers[DefaultHashAlgo])
+	var nonce uint64
+	allocs := testing.AllocsPerRun(100, func() {
+		nonce++
+		h.sum(nonce)
+	})
+	if allocs != 0 {
+		t.Fatalf("hashing a nonce allocated %v times", allocs)
+	}
+}
+
+// BenchmarkNonceHashing compares hashing a nonce in the canonical encoding
+// with the string concatenation blocks before version 3 hash
+func BenchmarkNonceHashing(b *testing.B) {
+	block := CreateGenesisBlock()
+	block.Data = strings.Repeat("x", 256)
+
+	b.Run("canonical", func(b *testing.B) {
+		b.ReportAllocs()
+		h := newNonceHasher(block, hashers[DefaultHashAlgo])
+		for i := 0; i < b.N; i++ {
+			h.sum(uint64(i))
+		}
+	})
+	b.Run("legacy", func(b *testing.B) {
+		b.ReportAllocs()
+		legacy := block
+		legacy.Version = 0
+		for i := 0; i < b.N; i++ {
+			legacy.Nonce = uint64(i)
+			legacyHash(legacy)
+		}
+	})
+}
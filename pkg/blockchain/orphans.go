@@ -0,0 +1,208 @@
+package blockchain
+
+import (
+	"time"
+)
+
+// Defaults of OrphanLimits
+const (
+	DefaultMaxOrphans = 128
+	DefaultOrphanTTL  = 5 * time.Minute
+)
+
+// OrphanLimits bound the blocks a chain holds while their parent is missing
+type OrphanLimits struct {
+	// MaxBlocks caps how many orphans are held; the oldest are dropped
+	// first
+	MaxBlocks int
+	// TTL is how long an orphan is held before it is dropped
+	TTL time.Duration
+}
+
+// DefaultOrphanLimits returns the orphan limits chains start with
+func DefaultOrphanLimits() OrphanLimits {
+	return OrphanLimits{MaxBlocks: DefaultMaxOrphans, TTL: DefaultOrphanTTL}
+}
+
+// withDefaults replaces the limits that aren't positive by the defaults
+func (l OrphanLimits) withDefaults() OrphanLimits {
+	defaults := DefaultOrphanLimits()
+	if l.MaxBlocks <= 0 {
+		l.MaxBlocks = defaults.MaxBlocks
+	}
+	if l.TTL <= 0 {
+		l.TTL = defaults.TTL
+	}
+	return l
+}
+
+// orphan is a held block and when it expires
+type orphan struct {
+	block   Block
+	expires time.Time
+}
+
+// orphanPool holds blocks whose parent hasn't arrived, by the hash of the
+// parent. It is guarded by the chain's mutex.
+type orphanPool struct {
+	limits   OrphanLimits
+	byParent map[string][]orphan
+	hashes   map[string]bool
+	now      func() time.Time
+}
+
+// newOrphanPool creates an empty pool
+func newOrphanPool(limits OrphanLimits) *orphanPool {
+	return &orphanPool{
+		limits:   limits.withDefaults(),
+		byParent: make(map[string][]orphan),
+		hashes:   make(map[string]bool),
+		now:      time.Now,
+	}
+}
+
+// add holds a block, dropping the oldest orphan when the pool is full, and
+// reports whether it wasn't held already
+func (o *orphanPool) add(block Block) bool {
+	o.expire()
+	if o.hashes[block.Hash] {
+		return false
+	}
+	for len(o.hashes) >= o.limits.MaxBlocks {
+		o.dropOldest()
+	}
+	o.byParent[block.PrevHash] = append(o.byParent[block.PrevHash], orphan{block: block, expires: o.now().Add(o.limits.TTL)})
+	o.hashes[block.Hash] = true
+	return true
+}
+
+// take removes and returns the unexpired orphans whose parent has the
+// given hash
+func (o *orphanPool) take(parent string) []Block {
+	o.expire()
+	held := o.byParent[parent]
+	delete(o.byParent, parent)
+	blocks := make([]Block, len(held))
+	for i, orphan := range held {
+		delete(o.hashes, orphan.block.Hash)
+		blocks[i] = orphan.block
+	}
+	return blocks
+}
+
+// expire drops the orphans past their TTL
+func (o *orphanPool) expire() {
+	now := o.now()
+	o.drop(func(held orphan) bool { return !now.Before(held.expires) })
+}
+
+// drop removes the orphans for which discard returns true
+func (o *orphanPool) drop(discard func(orphan) bool) {
+	for parent, held := range o.byParent {
+		kept := held[:0]
+		for _, orphan := range held {
+			if discard(orphan) {
+				delete(o.hashes, orphan.block.Hash)
+			} else {
+				kept = append(kept, orphan)
+			}
+		}
+		if len(kept) == 0 {
+			delete(o.byParent, parent)
+		} else {
+			o.byParent[parent] = kept
+		}
+	}
+}
+
+// dropOldest drops the orphan that expires first
+func (o *orphanPool) dropOldest() {
+	var (
+		oldestParent string
+		oldestIndex  = -1
+		oldest       time.Time
+	)
+	for parent, held := range o.byParent {
+		for i, orphan := range held {
+			if oldestIndex < 0 || orphan.expires.Before(oldest) {
+				oldestParent, oldestIndex, oldest = parent, i, orphan.expires
+			}
+		}
+	}
+	if oldestIndex < 0 {
+		return
+	}
+	held := o.byParent[oldestParent]
+	delete(o.hashes, held[oldestIndex].block.Hash)
+	held = append(held[:oldestIndex], held[oldestIndex+1:]...)
+	if len(held) == 0 {
+		delete(o.byParent, oldestParent)
+	} else {
+		o.byParent[oldestParent] = held
+	}
+}
+
+// SetOrphanLimits bounds the orphans the chain holds. Chains start with
+// DefaultOrphanLimits; limits that aren't positive take their default.
+// Orphans already held are kept until they expire.
+func (bc *Chain) SetOrphanLimits(limits OrphanLimits) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	bc.orphans.limits = limits.withDefaults()
+}
+
+// AddOrphan holds a block whose parent isn't on the chain yet, such as one
+// a peer delivered ahead of its parent. Once a block it chains off is
+// added, whether by AddExistingBlock, mining, or a reorganization or
+// replacement, the orphan is validated and added after it, and in turn the
+// orphans chaining off it. Orphans expire after the chain's orphan TTL. It
+// reports whether the block was held: blocks already held, on the chain,
+// or whose parent is on the chain aren't.
+func (bc *Chain) AddOrphan(block Block) bool {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	if _, onChain := bc.hashIndex[block.Hash]; onChain {
+		return false
+	}
+	if _, parentOnChain := bc.hashIndex[block.PrevHash]; parentOnChain {
+		return false
+	}
+	return bc.orphans.add(block)
+}
+
+// OrphanCount returns how many orphans the chain holds
+func (bc *Chain) OrphanCount() int {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	bc.orphans.expire()
+	return len(bc.orphans.hashes)
+}
+
+// connectOrphans adds the orphans that chain off the tip, and then those
+// that chain off them. Of competing orphans with the same parent the first
+// valid one is added and the others dropped, as are invalid ones and those
+// that reached the chain some other way, such as in a reorganization. Each
+// added orphan is announced like a block from AddExistingBlock. The caller
+// must hold the mutex.
+func (bc *Chain) connectOrphans() {
+	bc.orphans.drop(func(held orphan) bool {
+		_, onChain := bc.hashIndex[held.block.Hash]
+		return onChain
+	})
+	for {
+		tip := bc.Blocks[len(bc.Blocks)-1]
+		var connected bool
+		for _, block := range bc.orphans.take(tip.Hash) {
+			if connected || bc.validateBlock(block, tip, bc.state) != nil {
+				continue
+			}
+			bc.appendBlock(block)
+			bc.notify(EventBlockAdded, block, false)
+			connected = true
+		}
+		if !connected {
+			return
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+)
+
+// minedBlocks returns n blocks mined in order on a chain of their own
+func minedBlocks(t *testing.T, n int) []Block {
+	t.Helper()
+	source := NewBlockchain()
+	for i := 0; i < n; i++ {
+		if _, err := source.AddBlock(nil, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return source.GetBlocks()[1:]
+}
+
+func TestOutOfOrderBlocksConnect(t *testing.T) {
+	blocks := minedBlocks(t, 3)
+	chain := NewBlockchain()
+
+	for _, block := range []Block{blocks[2], blocks[1]} {
+		if !chain.AddOrphan(block) {
+			t.Fatalf("block %d wasn't held", block.Index)
+		}
+	}
+	if chain.OrphanCount() != 2 || chain.Height() != 0 {
+		t.Fatalf("holding %d orphans at height %d", chain.OrphanCount(), chain.Height())
+	}
+
+	if err := chain.AddExistingBlock(blocks[0]); err != nil {
+		t.Fatal(err)
+	}
+	if chain.Height() != 3 || chain.GetLatestBlock().Hash != blocks[2].Hash {
+		t.Fatalf("chain at height %d after its missing block arrived, want 3", chain.Height())
+	}
+	if chain.OrphanCount() != 0 {
+		t.Fatalf("%d orphans left after connecting", chain.OrphanCount())
+	}
+}
+
+func TestAddOrphanRefusesConnectedBlocks(t *testing.T) {
+	blocks := minedBlocks(t, 2)
+	chain := NewBlockchain()
+
+	if chain.AddOrphan(blocks[0]) {
+		t.Fatal("held a block whose parent is on the chain")
+	}
+	if !chain.AddOrphan(blocks[1]) || chain.AddOrphan(blocks[1]) {
+		t.Fatal("held the same orphan twice")
+	}
+}
+
+func TestOrphansExpire(t *testing.T) {
+	blocks := minedBlocks(t, 3)
+	chain := NewBlockchain()
+	now := time.Now()
+	chain.orphans.now = func() time.Time { return now }
+	chain.SetOrphanLimits(OrphanLimits{TTL: time.Minute})
+
+	chain.AddOrphan(blocks[2])
+	now = now.Add(30 * time.Second)
+	chain.AddOrphan(blocks[1])
+	now = now.Add(45 * time.Second)
+	if chain.OrphanCount() != 1 {
+		t.Fatalf("holding %d orphans, want only the younger one", chain.OrphanCount())
+	}
+
+	if err := chain.AddExistingBlock(blocks[0]); err != nil {
+		t.Fatal(err)
+	}
+	if chain.Height() != 2 {
+		t.Fatalf("height %d, want 2: the expired block 3 must not connect", chain.Height())
+	}
+}
+
+func TestOrphanPoolIsBounded(t *testing.T) {
+	blocks := minedBlocks(t, 4)
+	chain := NewBlockchain()
+	now := time.Now()
+	chain.orphans.now = func() time.Time { return now }
+	chain.SetOrphanLimits(OrphanLimits{MaxBlocks: 2})
+
+	for _, block := range blocks[1:] {
+		chain.AddOrphan(block)
+		now = now.Add(time.Second)
+	}
+	if chain.OrphanCount() != 2 {
+		t.Fatalf("holding %d orphans, want 2", chain.OrphanCount())
+	}
+
+	// Block 2, the oldest, was dropped, so nothing connects past block 1
+	if err := chain.AddExistingBlock(blocks[0]); err != nil {
+		t.Fatal(err)
+	}
+	if chain.Height() != 1 {
+		t.Fatalf("height %d, want 1", chain.Height())
+	}
+}
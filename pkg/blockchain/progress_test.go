@@ -0,0 +1,65 @@
+package blockchain
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+func TestMiningIsSilentByDefault(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	_, _, err = GenerateBlock(CreateGenesisBlock(), nil, 3)
+	os.Stdout = stdout
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	printed, _ := io.ReadAll(r)
+	if len(printed) > 0 {
+		t.Fatalf("mining printed %q", printed)
+	}
+}
+
+func TestProgressIsReportedEveryInterval(t *testing.T) {
+	var reports []uint64
+	progress := func(attempts uint64, currentHash string) {
+		if len(currentHash) != 64 {
+			t.Errorf("progress reported hash %q", currentHash)
+		}
+		reports = append(reports, attempts)
+	}
+	// Difficulty 4 takes tens of thousands of attempts on average
+	_, attempts, err := GenerateBlock(CreateGenesisBlock(), nil, 4, WithProgress(progress), WithProgressInterval(attemptBatch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts == 0 {
+		t.Fatal("no attempts counted")
+	}
+	// The batch finding the block isn't reported
+	if uint64(len(reports)) != (attempts-1)/attemptBatch {
+		t.Errorf("%d reports over %d attempts, want one every %d", len(reports), attempts, attemptBatch)
+	}
+	for i, reported := range reports {
+		if reported != uint64(i+1)*attemptBatch {
+			t.Errorf("report %d at %d attempts, want %d", i, reported, uint64(i+1)*attemptBatch)
+		}
+	}
+}
+
+func TestDefaultProgressIntervalThrottlesReports(t *testing.T) {
+	var reports int
+	_, attempts, err := GenerateBlock(CreateGenesisBlock(), nil, 3, WithProgress(func(uint64, string) { reports++ }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (attempts - 1) / DefaultProgressInterval; uint64(reports) != want {
+		t.Errorf("%d reports over %d attempts, want %d", reports, attempts, want)
+	}
+}
@@ -0,0 +1,53 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrRollbackGenesis is returned for rollbacks that would remove the
+// genesis block
+var ErrRollbackGenesis = errors.New("can't roll back the genesis block")
+
+// RollbackToHeight truncates the chain to height, for undoing bad blocks on
+// test networks, and returns the removed blocks, oldest first. Their
+// transactions are put back in pool, unless it is nil; those the pool
+// refuses are dropped. Subscribers see the rollback as a replaced chain.
+// The genesis block and the blocks of the snapshot the chain was installed
+// from can't be rolled back.
+func (bc *Chain) RollbackToHeight(height int, pool *TransactionPool) ([]Block, error) {
+	removed, err := bc.truncate(height)
+	if err != nil || pool == nil {
+		return removed, err
+	}
+
+	// The pool checks balances against the chain, so this waits until the
+	// chain is unlocked
+	for _, block := range removed {
+		pool.Requeue(BlockTransactions(block))
+	}
+	return removed, nil
+}
+
+// truncate removes the blocks above height and returns them
+func (bc *Chain) truncate(height int) ([]Block, error) {
+	bc.mutex.Lock()
+	defer bc.unlock()
+
+	tip := len(bc.Blocks) - 1
+	switch {
+	case height < 0:
+		return nil, ErrRollbackGenesis
+	case height > tip:
+		return nil, fmt.Errorf("height %d is above the tip at %d", height, tip)
+	case bc.base != nil && height < bc.base.Height:
+		return nil, fmt.Errorf("blocks up to height %d were installed from a snapshot", bc.base.Height)
+	case height == tip:
+		return nil, nil
+	}
+
+	removed := append([]Block(nil), bc.Blocks[height+1:]...)
+	bc.setBlocks(append([]Block(nil), bc.Blocks[:height+1]...))
+	bc.notify(EventChainReplaced, bc.Blocks[height], false)
+	return removed, nil
+}
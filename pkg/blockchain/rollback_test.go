@@ -0,0 +1,68 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRollbackRequeuesRemovedTransactions(t *testing.T) {
+	chain := NewBlockchain()
+	mineTransactions(t, chain, Transaction{ID: "a", To: "bob"}, Transaction{ID: "b", To: "bob"}, Transaction{ID: "c", To: "bob"})
+	keep, _ := chain.GetBlockByIndex(1)
+	var events []ChainEvent
+	chain.Subscribe(func(event ChainEvent) { events = append(events, event) })
+
+	pool := NewTransactionPool(10)
+	removed, err := chain.RollbackToHeight(1, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 || removed[0].Index != 2 || removed[1].Index != 3 {
+		t.Fatalf("removed %d blocks, want blocks 2 and 3", len(removed))
+	}
+	if chain.Height() != 1 || chain.GetLatestBlock().Hash != keep.Hash {
+		t.Fatalf("chain at height %d after rolling back to 1", chain.Height())
+	}
+	for _, id := range []string{"b", "c"} {
+		if _, err := pool.GetTransaction(id); err != nil {
+			t.Errorf("transaction %s wasn't requeued: %v", id, err)
+		}
+	}
+	if pool.Count() != 2 {
+		t.Fatalf("pool holds %d transactions, want b and c", pool.Count())
+	}
+	if len(events) != 1 || events[0].Type != EventChainReplaced || events[0].Block.Hash != keep.Hash {
+		t.Fatalf("subscribers got %+v, want one replacement ending at block 1", events)
+	}
+
+	// The chain grows again from the new tip
+	if _, err := chain.AddBlock(pool, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chain.Validate(); err != nil {
+		t.Fatalf("chain invalid after rolling back and mining: %v", err)
+	}
+}
+
+func TestRollbackLimits(t *testing.T) {
+	chain := NewBlockchain()
+	if _, err := chain.AddBlock(nil, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := chain.RollbackToHeight(-1, nil); !errors.Is(err, ErrRollbackGenesis) {
+		t.Fatalf("rolling back the genesis block: got %v, want ErrRollbackGenesis", err)
+	}
+	if _, err := chain.RollbackToHeight(2, nil); err == nil {
+		t.Fatal("rolled back to a height above the tip")
+	}
+	if removed, err := chain.RollbackToHeight(1, nil); err != nil || len(removed) != 0 {
+		t.Fatalf("rolling back to the tip removed %d blocks: %v", len(removed), err)
+	}
+	if removed, err := chain.RollbackToHeight(0, nil); err != nil || len(removed) != 1 {
+		t.Fatalf("rolling back to genesis removed %d blocks: %v", len(removed), err)
+	}
+	if chain.Height() != 0 {
+		t.Fatalf("height %d, want only the genesis block", chain.Height())
+	}
+}
@@ -0,0 +1,65 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrBadSignature is wrapped by the errors of blocks whose validator
+// signature is missing or doesn't verify
+var ErrBadSignature = errors.New("bad block signature")
+
+// SignBlock signs a mined block's hash with key, recording the key as the
+// block's validator. The signature fields aren't hashed, so signing doesn't
+// change the hash; the signature covers everything the hash does.
+func SignBlock(block *Block, key ed25519.PrivateKey) {
+	block.Validator = hex.EncodeToString(key.Public().(ed25519.PublicKey))
+	block.Signature = hex.EncodeToString(ed25519.Sign(key, []byte(block.Hash)))
+}
+
+// VerifyBlockSignature checks that a block is signed by the validator it
+// names and returns the validator's key
+func VerifyBlockSignature(block Block) (ed25519.PublicKey, error) {
+	if block.Validator == "" || block.Signature == "" {
+		return nil, fmt.Errorf("%w: block %d is unsigned", ErrBadSignature, block.Index)
+	}
+	key, err := hex.DecodeString(block.Validator)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: block %d names an invalid validator key", ErrBadSignature, block.Index)
+	}
+	signature, err := hex.DecodeString(block.Signature)
+	if err != nil || !ed25519.Verify(key, []byte(block.Hash), signature) {
+		return nil, fmt.Errorf("%w: block %d isn't signed by its validator", ErrBadSignature, block.Index)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// WithSigner has the mined block signed with key. A nil key leaves the
+// block unsigned.
+func WithSigner(key ed25519.PrivateKey) MineOption {
+	return func(o *mineOptions) {
+		o.signer = key
+	}
+}
+
+// SetSigner sets the key that signs the blocks added by AddBlock and
+// AddTransactionsContext, as proof-of-stake validators sign theirs. Chains
+// start without one and leave blocks unsigned.
+func (bc *Chain) SetSigner(key ed25519.PrivateKey) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	bc.signer = key
+}
+
+// withSignatureSpace fills in a placeholder validator signature when key
+// will sign the block, so a block yet to be mined is as large as it can be
+func withSignatureSpace(block Block, key ed25519.PrivateKey) Block {
+	if key != nil {
+		block.Validator = strings.Repeat("0", 2*ed25519.PublicKeySize)
+		block.Signature = strings.Repeat("0", 2*ed25519.SignatureSize)
+	}
+	return block
+}
@@ -0,0 +1,70 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"testing"
+)
+
+func TestSignedBlockVerifies(t *testing.T) {
+	public, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis := CreateGenesisBlock()
+	block, _, err := GenerateBlock(genesis, nil, 1, WithSigner(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validator, err := VerifyBlockSignature(block)
+	if err != nil {
+		t.Fatalf("signed block doesn't verify: %v", err)
+	}
+	if !validator.Equal(public) {
+		t.Fatal("wrong validator key")
+	}
+	if err := ValidateBlock(block, genesis); err != nil {
+		t.Fatalf("signing broke the block: %v", err)
+	}
+}
+
+func TestUnsignedOrAlteredBlockFailsVerification(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis := CreateGenesisBlock()
+	unsigned := mineBlock(t, genesis, 0)
+	if _, err := VerifyBlockSignature(unsigned); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("unsigned block: got %v, want ErrBadSignature", err)
+	}
+
+	signed := unsigned
+	SignBlock(&signed, key)
+	signed.Hash = genesis.Hash
+	if _, err := VerifyBlockSignature(signed); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("altered block: got %v, want ErrBadSignature", err)
+	}
+}
+
+func TestChainSignsMinedBlocksWithinLimits(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := NewBlockchain()
+	unsignedBudget := chain.TransactionBudget(1)
+	chain.SetSigner(key)
+	if budget := chain.TransactionBudget(1); budget >= unsignedBudget {
+		t.Fatalf("budget %d doesn't leave room for the signature (unsigned %d)", budget, unsignedBudget)
+	}
+
+	block, err := chain.AddBlock(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyBlockSignature(block); err != nil {
+		t.Fatalf("mined block not signed: %v", err)
+	}
+}
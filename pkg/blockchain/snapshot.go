@@ -0,0 +1,127 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SnapshotBase is the state a chain installed from a snapshot starts from:
+// the balances as of the snapshot tip, whose earlier blocks are pruned
+type SnapshotBase struct {
+	Height   int                `json:"height"`
+	Hash     string             `json:"hash"`
+	Balances map[string]float64 `json:"balances"`
+}
+
+// PrunedBlock returns the stand-in for a block of which only the header is
+// known
+func PrunedBlock(header BlockHeader) Block {
+	return Block{BlockHeader: header, Pruned: true}
+}
+
+// InstallSnapshot replaces the chain with blocks from a snapshot, pruned up
+// to some height and whole after it, and base, the state at the snapshot
+// tip. The blocks must start at the genesis block and reach base.
+func (bc *Chain) InstallSnapshot(blocks []Block, base SnapshotBase) error {
+	bc.mutex.Lock()
+	defer bc.unlock()
+
+	if err := bc.validateStored(blocks, &base); err != nil {
+		return err
+	}
+	if base.Height >= len(blocks) || blocks[base.Height].Hash != base.Hash {
+		return fmt.Errorf("snapshot tip %d is not on the installed chain", base.Height)
+	}
+	bc.base = &base
+	bc.setBlocks(append([]Block{}, blocks...))
+	bc.notify(EventChainReplaced, bc.Blocks[len(bc.Blocks)-1], false)
+	return nil
+}
+
+// SetSnapshotBase restores the state a chain installed from a snapshot
+// starts from, after its blocks have been restored from storage
+func (bc *Chain) SetSnapshotBase(base SnapshotBase) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	if base.Height >= len(bc.Blocks) || bc.Blocks[base.Height].Hash != base.Hash {
+		return fmt.Errorf("snapshot tip %d is not on the chain", base.Height)
+	}
+	bc.base = &base
+	bc.resetState()
+	return nil
+}
+
+// SnapshotBase returns the snapshot the chain was installed from, if any
+func (bc *Chain) SnapshotBase() (SnapshotBase, bool) {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	if bc.base == nil {
+		return SnapshotBase{}, false
+	}
+	return *bc.base, true
+}
+
+// Balances returns the balance of every address: the snapshot balances, if
+// the chain was installed from one, plus the blocks after it
+func (bc *Chain) Balances() map[string]float64 {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+	return bc.state.Balances()
+}
+
+// Balance returns the balance of an address as of the tip. The chain
+// keeps the balances up to date as blocks are added, and recomputes them
+// when the chain is replaced.
+func (bc *Chain) Balance(address string) float64 {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+	return bc.state.Balance(address)
+}
+
+// SnapshotState returns the blocks of the chain up to height, the tip when
+// negative, and the balances as of that height
+func (bc *Chain) SnapshotState(height int) ([]Block, map[string]float64, error) {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	if height < 0 {
+		height = len(bc.Blocks) - 1
+	}
+	if height >= len(bc.Blocks) {
+		return nil, nil, fmt.Errorf("no block at height %d", height)
+	}
+	balances, err := bc.balancesAt(height)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append([]Block{}, bc.Blocks[:height+1]...), balances, nil
+}
+
+// balancesAt computes the balances as of a height, which can't be below
+// the snapshot the chain was installed from. The caller must hold the
+// mutex.
+func (bc *Chain) balancesAt(height int) (map[string]float64, error) {
+	if bc.base == nil {
+		return Balances(bc.Blocks[:height+1]), nil
+	}
+	if height < bc.base.Height {
+		return nil, fmt.Errorf("balances at height %d are pruned", height)
+	}
+	balances := Balances(bc.Blocks[bc.base.Height+1 : height+1])
+	for address, balance := range bc.base.Balances {
+		balances[address] += balance
+	}
+	return balances, nil
+}
+
+// validateStored checks blocks read back from storage or a snapshot like
+// Validate, base being the snapshot they start from if known
+func (bc *Chain) validateStored(blocks []Block, base *SnapshotBase) error {
+	if len(blocks) == 0 {
+		return errors.New("no blocks to restore")
+	}
+	_, err := bc.validateBlocks(blocks, base)
+	return err
+}
@@ -0,0 +1,64 @@
+package blockchain
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timestamp rules
+const (
+	// MaxBlockTimeDrift is how far ahead of the local clock a block may be
+	// timestamped
+	MaxBlockTimeDrift = 2 * time.Minute
+	// BlockTimeTolerance is how far before its parent a block may be
+	// timestamped, for miners whose clock is slightly behind
+	BlockTimeTolerance = 5 * time.Second
+)
+
+// Timestamp rule violations
+var (
+	ErrBlockInFuture   = errors.New("block is timestamped in the future")
+	ErrBlockBeforePrev = errors.New("block is timestamped before its parent")
+)
+
+// timestampLayout is the format time.Time.String gave block timestamps
+// before version 2
+const timestampLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// ParseTimestamp parses a timestamp of a block made before version 2,
+// ignoring the monotonic clock reading time.Time.String appends
+func ParseTimestamp(timestamp string) (time.Time, error) {
+	if i := strings.Index(timestamp, " m="); i >= 0 {
+		timestamp = timestamp[:i]
+	}
+	return time.Parse(timestampLayout, timestamp)
+}
+
+// CheckTimestamp applies the timestamp rules to a block minted at
+// timestamp: it may be at most MaxBlockTimeDrift ahead of now, and, when
+// the parent's timestamp is known, at most BlockTimeTolerance before it
+func CheckTimestamp(timestamp int64, parent *int64, now time.Time) error {
+	minted := time.Unix(timestamp, 0)
+	if minted.After(now.Add(MaxBlockTimeDrift)) {
+		return ErrBlockInFuture
+	}
+	if parent != nil && minted.Before(time.Unix(*parent, 0).Add(-BlockTimeTolerance)) {
+		return ErrBlockBeforePrev
+	}
+	return nil
+}
+
+// Time returns the time the block was minted
+func (b Block) Time() time.Time {
+	return time.Unix(b.Timestamp, 0)
+}
+
+// hashedTimestamp returns the timestamp as the block's hash covers it
+func (b Block) hashedTimestamp() string {
+	if b.legacyTimestamp != "" {
+		return b.legacyTimestamp
+	}
+	return strconv.FormatInt(b.Timestamp, 10)
+}
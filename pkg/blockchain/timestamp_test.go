@@ -0,0 +1,110 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckTimestamp(t *testing.T) {
+	now := time.Now()
+	parent := now.Add(-time.Minute).Unix()
+	for name, c := range map[string]struct {
+		timestamp int64
+		parent    *int64
+		want      error
+	}{
+		"now":                     {now.Unix(), &parent, nil},
+		"within the drift":        {now.Add(MaxBlockTimeDrift - time.Second).Unix(), &parent, nil},
+		"past the drift":          {now.Add(MaxBlockTimeDrift + time.Minute).Unix(), &parent, ErrBlockInFuture},
+		"within the tolerance":    {parent - 1, &parent, nil},
+		"before the tolerance":    {time.Unix(parent, 0).Add(-BlockTimeTolerance - time.Second).Unix(), &parent, ErrBlockBeforePrev},
+		"old without a parent":    {0, nil, nil},
+		"future without a parent": {now.Add(time.Hour).Unix(), nil, ErrBlockInFuture},
+	} {
+		if err := CheckTimestamp(c.timestamp, c.parent, now); !errors.Is(err, c.want) {
+			t.Errorf("%s: got %v, want %v", name, err, c.want)
+		}
+	}
+}
+
+func TestValidateBlockAppliesTimestampRules(t *testing.T) {
+	parent := mineBlock(t, CreateGenesisBlock(), 1)
+	for name, c := range map[string]struct {
+		timestamp time.Time
+		want      error
+	}{
+		"in the future":     {time.Now().Add(time.Hour), ErrBlockInFuture},
+		"before its parent": {parent.Time().Add(-time.Minute), ErrBlockBeforePrev},
+	} {
+		block := mineBlock(t, parent, 1)
+		block.Timestamp = c.timestamp.Unix()
+		block = rehash(block)
+		err := ValidateBlock(block, parent)
+		if !errors.Is(err, ErrInvalidBlock) || !errors.Is(err, c.want) {
+			t.Errorf("%s: got %v, want %v", name, err, c.want)
+		}
+	}
+}
+
+func TestParseTimestampIgnoresMonotonicClock(t *testing.T) {
+	now := time.Now()
+	parsed, err := ParseTimestamp(now.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.Equal(now.Round(0)) {
+		t.Fatalf("parsed %s, want %s", parsed, now)
+	}
+	if _, err := ParseTimestamp("yesterday"); err == nil {
+		t.Error("parsed a malformed timestamp")
+	}
+}
+
+func TestLegacyTimestampSurvivesReencoding(t *testing.T) {
+	legacy := time.Now().Add(-time.Hour).String()
+	data := `{"index":1,"timestamp":` + strconv.Quote(legacy) + `,"data":"hello","hash":"0a","prevHash":"00","difficulty":0,"nonce":0}`
+
+	var block Block
+	if err := json.Unmarshal([]byte(data), &block); err != nil {
+		t.Fatal(err)
+	}
+	minted, _ := ParseTimestamp(legacy)
+	if block.Timestamp != minted.Unix() {
+		t.Errorf("migrated timestamp %d, want %d", block.Timestamp, minted.Unix())
+	}
+
+	// The original string is kept, since the block's hash covers it
+	encoded, err := json.Marshal(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again Block
+	if err := json.Unmarshal(encoded, &again); err != nil {
+		t.Fatal(err)
+	}
+	if again.hashedTimestamp() != legacy || CalculateHash(again) != CalculateHash(block) {
+		t.Errorf("reencoded block hashes timestamp %q, want %q", again.hashedTimestamp(), legacy)
+	}
+}
+
+func TestVersionedBlockRejectsStringTimestamp(t *testing.T) {
+	parent := mineBlock(t, CreateGenesisBlock(), 1)
+	block := mineBlock(t, parent, 1)
+	encoded, err := json.Marshal(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unix := `"timestamp":` + strconv.FormatInt(block.Timestamp, 10)
+	legacy := `"timestamp":` + strconv.Quote(block.Time().String())
+	var decoded Block
+	if err := json.Unmarshal([]byte(strings.Replace(string(encoded), unix, legacy, 1)), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateBlock(decoded, parent); !errors.Is(err, ErrInvalidBlock) || !strings.Contains(err.Error(), "string timestamp") {
+		t.Fatalf("got %v, want the string timestamp rejected", err)
+	}
+}
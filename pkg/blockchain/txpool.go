@@ -2,6 +2,9 @@ package blockchain
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"sort"
 	"sync"
 	"time"
 )
@@ -13,15 +16,46 @@ type Transaction struct {
 	To        string    `json:"to"`
 	Data      string    `json:"data"`
 	Value     float64   `json:"value"`
+	Fee       float64   `json:"fee,omitempty"` // Recorded, not yet charged
 	Timestamp time.Time `json:"timestamp"`
+	PublicKey string    `json:"publicKey,omitempty"` // Sender's key, for signed transactions
 	Signature string    `json:"signature"`
 }
 
+// ErrPoolFull is returned when the pool holds as many transactions as it may
+var ErrPoolFull = errors.New("transaction pool is full")
+
+// ErrInsufficientFunds is returned for transactions whose sender's balance
+// can't cover their value on top of the sender's pending transactions
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// ErrInvalidValue is returned for transactions whose value is negative or
+// not a finite number, which would credit their sender
+var ErrInvalidValue = errors.New("transaction value must be a non-negative number")
+
+// checkValue refuses transactions of a negative or non-finite value
+func checkValue(tx Transaction) error {
+	if tx.Value < 0 || math.IsNaN(tx.Value) || math.IsInf(tx.Value, 0) {
+		return fmt.Errorf("%w, got %g", ErrInvalidValue, tx.Value)
+	}
+	return nil
+}
+
+// BalanceSource tells the pool what addresses own. Chain implements it.
+type BalanceSource interface {
+	Balance(address string) float64
+}
+
 // TransactionPool manages pending transactions
 type TransactionPool struct {
 	pendingTransactions map[string]*Transaction
+	pendingSpends       map[string]float64 // Value of the pending transactions of each sender
+	arrivals            map[string]uint64  // Order each pending transaction arrived in
+	arrived             uint64             // Transactions admitted so far
 	mutex               sync.RWMutex
 	maxPoolSize         int
+	allowFaucet         bool
+	balances            BalanceSource
 }
 
 // NewTransactionPool creates a new transaction pool
@@ -32,18 +66,47 @@ func NewTransactionPool(maxPoolSize int) *TransactionPool {
 
 	return &TransactionPool{
 		pendingTransactions: make(map[string]*Transaction),
+		pendingSpends:       make(map[string]float64),
+		arrivals:            make(map[string]uint64),
 		maxPoolSize:         maxPoolSize,
 	}
 }
 
-// AddTransaction adds a transaction to the pool
+// SetBalances has the pool refuse transactions whose sender's balance in
+// source doesn't cover their value plus the value of the sender's pending
+// transactions. Without a source, balances aren't checked.
+func (tp *TransactionPool) SetBalances(source BalanceSource) {
+	tp.mutex.Lock()
+	defer tp.mutex.Unlock()
+	tp.balances = source
+}
+
+// AllowFaucet sets whether faucet transactions are admitted, which only
+// dev mode allows
+func (tp *TransactionPool) AllowFaucet(allowed bool) {
+	tp.mutex.Lock()
+	defer tp.mutex.Unlock()
+	tp.allowFaucet = allowed
+}
+
+// AddTransaction adds a transaction to the pool. Transactions of a negative
+// value are refused wherever they come from: the API, peers, or blocks
+// abandoned in a reorganization.
 func (tp *TransactionPool) AddTransaction(tx *Transaction) error {
+	if err := checkValue(*tx); err != nil {
+		return err
+	}
+
 	tp.mutex.Lock()
 	defer tp.mutex.Unlock()
 
+	if IsFaucetTransaction(*tx) && !tp.allowFaucet {
+		return ErrFaucetDisabled
+	}
+
 	// Check if pool is full
 	if len(tp.pendingTransactions) >= tp.maxPoolSize {
-		return errors.New("transaction pool is full")
+		return ErrPoolFull
 	}
 
 	// Check if transaction already exists
@@ -51,11 +114,67 @@ func (tp *TransactionPool) AddTransaction(tx *Transaction) error {
 		return errors.New("transaction already exists in pool")
 	}
 
+	// Faucet transactions create their funds
+	if tp.balances != nil && !IsFaucetTransaction(*tx) {
+		available := tp.balances.Balance(tx.From) - tp.pendingSpends[tx.From]
+		if tx.Value > available {
+			return fmt.Errorf("%w: %s can spend %g, not %g", ErrInsufficientFunds, tx.From, max(available, 0), tx.Value)
+		}
+	}
+
 	// Add transaction to pool
-	tp.pendingTransactions[tx.ID] = tx
+	tp.add(tx)
 	return nil
 }
 
+// add puts a transaction in the pool. The caller must hold the mutex.
+func (tp *TransactionPool) add(tx *Transaction) {
+	tp.pendingTransactions[tx.ID] = tx
+	tp.arrivals[tx.ID] = tp.arrived
+	tp.arrived++
+	if !IsFaucetTransaction(*tx) {
+		tp.pendingSpends[tx.From] += tx.Value
+	}
+}
+
+// remove takes a transaction out of the pool, reporting whether it was
+// there. The caller must hold the mutex.
+func (tp *TransactionPool) remove(txID string) bool {
+	tx, exists := tp.pendingTransactions[txID]
+	if !exists {
+		return false
+	}
+	delete(tp.pendingTransactions, txID)
+	delete(tp.arrivals, txID)
+	if !IsFaucetTransaction(*tx) {
+		tp.pendingSpends[tx.From] -= tx.Value
+		if tp.pendingSpends[tx.From] <= 0 {
+			delete(tp.pendingSpends, tx.From)
+		}
+	}
+	return true
+}
+
+// oldestFirst returns the pending transactions in the order they arrived.
+// The caller must hold the mutex.
+func (tp *TransactionPool) oldestFirst() []*Transaction {
+	transactions := make([]*Transaction, 0, len(tp.pendingTransactions))
+	for _, tx := range tp.pendingTransactions {
+		transactions = append(transactions, tx)
+	}
+	sort.Slice(transactions, func(i, j int) bool {
+		return tp.arrivals[transactions[i].ID] < tp.arrivals[transactions[j].ID]
+	})
+	return transactions
+}
+
+// PendingSpend returns the value of an address's pending transactions
+func (tp *TransactionPool) PendingSpend(address string) float64 {
+	tp.mutex.RLock()
+	defer tp.mutex.RUnlock()
+	return tp.pendingSpends[address]
+}
+
 // GetTransaction retrieves a transaction from the pool
 func (tp *TransactionPool) GetTransaction(txID string) (*Transaction, error) {
 	tp.mutex.RLock()
@@ -69,17 +188,12 @@ func (tp *TransactionPool) GetTransaction(txID string) (*Transaction, error) {
 	return tx, nil
 }
 
-// GetAllTransactions returns all transactions in the pool
+// GetAllTransactions returns all transactions in the pool, oldest first
 func (tp *TransactionPool) GetAllTransactions() []*Transaction {
 	tp.mutex.RLock()
 	defer tp.mutex.RUnlock()
 
-	transactions := make([]*Transaction, 0, len(tp.pendingTransactions))
-	for _, tx := range tp.pendingTransactions {
-		transactions = append(transactions, tx)
-	}
-
-	return transactions
+	return tp.oldestFirst()
 }
 
 // RemoveTransaction removes a transaction from the pool
@@ -87,26 +201,36 @@ func (tp *TransactionPool) RemoveTransaction(txID string) error {
 	tp.mutex.Lock()
 	defer tp.mutex.Unlock()
 
-	if _, exists := tp.pendingTransactions[txID]; !exists {
+	if !tp.remove(txID) {
 		return errors.New("transaction not found in pool")
 	}
-
-	delete(tp.pendingTransactions, txID)
 	return nil
 }
 
-// GetBatch retrieves a batch of transactions for block creation
-func (tp *TransactionPool) GetBatch(maxCount int) []*Transaction {
+// GetBatch retrieves a batch of transactions for block creation: up to
+// maxCount of them, whose encodings in a block take at most maxBytes.
+// Transactions are taken in the order they arrived, and those too large
+// for what is left of the budget are skipped.
+func (tp *TransactionPool) GetBatch(maxCount, maxBytes int) []*Transaction {
 	tp.mutex.RLock()
 	defer tp.mutex.RUnlock()
 
 	count := 0
-	transactions := make([]*Transaction, 0, maxCount)
+	transactions := make([]*Transaction, 0, min(maxCount, len(tp.pendingTransactions)))
 
-	for _, tx := range tp.pendingTransactions {
+	for _, tx := range tp.oldestFirst() {
 		if count >= maxCount {
 			break
 		}
+		// Transactions after the first are preceded by a comma
+		size := TransactionSize(*tx)
+		if count > 0 {
+			size++
+		}
+		if size > maxBytes {
+			continue
+		}
+		maxBytes -= size
 		transactions = append(transactions, tx)
 		count++
 	}
@@ -120,16 +244,33 @@ func (tp *TransactionPool) RemoveBatch(txIDs []string) {
 	defer tp.mutex.Unlock()
 
 	for _, id := range txIDs {
-		delete(tp.pendingTransactions, id)
+		tp.remove(id)
 	}
 }
 
+// Requeue returns transactions to the pool, such as those of blocks a
+// reorganization abandoned, and reports how many it took back. They go
+// through the checks of AddTransaction, so ones the new branch made
+// unaffordable, or that don't fit, are left out.
+func (tp *TransactionPool) Requeue(txs []Transaction) int {
+	var added int
+	for i := range txs {
+		tx := txs[i]
+		if tp.AddTransaction(&tx) == nil {
+			added++
+		}
+	}
+	return added
+}
+
 // Clear empties the transaction pool
 func (tp *TransactionPool) Clear() {
 	tp.mutex.Lock()
 	defer tp.mutex.Unlock()
 
 	tp.pendingTransactions = make(map[string]*Transaction)
+	tp.pendingSpends = make(map[string]float64)
+	tp.arrivals = make(map[string]uint64)
 }
 
 // Count returns the number of transactions in the pool
@@ -139,3 +280,16 @@ func (tp *TransactionPool) Count() int {
 
 	return len(tp.pendingTransactions)
 }
+
+// Capacity returns how many more transactions the pool can accept
+func (tp *TransactionPool) Capacity() int {
+	tp.mutex.RLock()
+	defer tp.mutex.RUnlock()
+
+	return tp.maxPoolSize - len(tp.pendingTransactions)
+}
+
+// MaxSize returns how many transactions the pool holds at most
+func (tp *TransactionPool) MaxSize() int {
+	return tp.maxPoolSize
+}
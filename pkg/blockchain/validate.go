@@ -0,0 +1,100 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ValidateGenesis checks that a block is a genesis block as
+// CreateGenesisBlock makes them: index 0, no parent, a known format
+// version, a merkle root of its transactions, and a hash of its contents.
+// A pruned genesis block, installed from a snapshot, can't be hashed, so
+// only its place is checked.
+func ValidateGenesis(block Block) error {
+	if block.Index != 0 {
+		return fmt.Errorf("%w: genesis block has index %d", ErrInvalidBlock, block.Index)
+	}
+	if block.PrevHash != "" {
+		return fmt.Errorf("%w: genesis block has a parent %s", ErrInvalidBlock, block.PrevHash)
+	}
+	if block.Pruned {
+		return nil
+	}
+	if block.Version < 0 || block.Version > BlockVersion {
+		return fmt.Errorf("%w: genesis block has version %d", ErrInvalidBlock, block.Version)
+	}
+	if block.Version >= 2 && block.legacyTimestamp != "" {
+		return fmt.Errorf("%w: genesis block has a string timestamp", ErrInvalidBlock)
+	}
+	if err := checkBody(block); err != nil {
+		return err
+	}
+	if err := checkHashAlgo(block); err != nil {
+		return err
+	}
+	if CalculateHash(block) != block.Hash {
+		return fmt.Errorf("%w: genesis block has a bad hash", ErrInvalidBlock)
+	}
+	return nil
+}
+
+// Validate audits the whole chain, for example after it was restored from
+// storage: the genesis block must pass ValidateGenesis and every block
+// after it must extend its parent by ValidateBlock and the chain's
+// checkpoints, limits, faucet and balance rules. It returns the index of
+// the first invalid block and why it is, or -1 and nil when the chain is
+// valid.
+func (bc *Chain) Validate() (int, error) {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+	return bc.validateBlocks(bc.Blocks, bc.base)
+}
+
+// validateBlocks checks a chain of blocks like Validate. Pruned blocks may
+// only precede whole ones, and are checked by their links alone. Balances
+// are checked from the genesis block, or from base when the chain starts
+// pruned; without a base, they aren't checked after pruned blocks.
+func (bc *Chain) validateBlocks(blocks []Block, base *SnapshotBase) (int, error) {
+	if len(blocks) == 0 {
+		return 0, errors.New("no blocks")
+	}
+	if err := ValidateGenesis(blocks[0]); err != nil {
+		return 0, err
+	}
+	if err := bc.checkCheckpoint(blocks[0]); err != nil {
+		return 0, err
+	}
+	if !bc.devMode && hasFaucetTransactions(blocks[0]) {
+		return 0, fmt.Errorf("%w: genesis block: %w", ErrInvalidBlock, ErrFaucetDisabled)
+	}
+	var state *State
+	if !blocks[0].Pruned {
+		state = NewState(nil)
+		state.Apply(blocks[0])
+	}
+	for i := 1; i < len(blocks); i++ {
+		if base != nil && i-1 == base.Height {
+			state = NewState(base.Balances)
+		}
+		block, prev := blocks[i], blocks[i-1]
+		if block.Pruned {
+			if !prev.Pruned && i > 1 {
+				return i, fmt.Errorf("%w: pruned block %d follows a whole block", ErrInvalidBlock, block.Index)
+			}
+			if block.Index != prev.Index+1 || block.PrevHash != prev.Hash {
+				return i, fmt.Errorf("%w: pruned block %d doesn't link to block %d", ErrInvalidBlock, block.Index, prev.Index)
+			}
+			if err := bc.checkCheckpoint(block); err != nil {
+				return i, err
+			}
+			continue
+		}
+		if err := bc.validateBlock(block, prev, state); err != nil {
+			return i, err
+		}
+		if state != nil {
+			state.Apply(block)
+		}
+	}
+	return -1, nil
+}
@@ -0,0 +1,60 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+)
+
+// newGrownChain returns a chain of height empty blocks above genesis
+func newGrownChain(t *testing.T, height int) *Chain {
+	t.Helper()
+	chain := NewBlockchain()
+	for i := 0; i < height; i++ {
+		if _, err := chain.AddBlock(nil, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return chain
+}
+
+func TestValidateAcceptsMinedChain(t *testing.T) {
+	chain := newGrownChain(t, 3)
+	if index, err := chain.Validate(); index != -1 || err != nil {
+		t.Fatalf("got %d, %v, want a valid chain", index, err)
+	}
+}
+
+func TestValidateReportsCorruptedMiddleBlock(t *testing.T) {
+	chain := newGrownChain(t, 4)
+	chain.Blocks[2].Nonce++
+
+	index, err := chain.Validate()
+	if index != 2 || !errors.Is(err, ErrInvalidBlock) {
+		t.Fatalf("got %d, %v, want block 2 invalid", index, err)
+	}
+}
+
+func TestValidateReportsBrokenLink(t *testing.T) {
+	chain := newGrownChain(t, 3)
+	// A validly mined block on another parent
+	chain.Blocks[3] = mineBlock(t, chain.Blocks[1], 1)
+	chain.Blocks[3].Index = 3
+
+	if index, err := chain.Validate(); index != 3 || !errors.Is(err, ErrInvalidBlock) {
+		t.Fatalf("got %d, %v, want block 3 invalid", index, err)
+	}
+}
+
+func TestValidateReportsBadGenesis(t *testing.T) {
+	for name, corrupt := range map[string]func(*Block){
+		"altered contents": func(b *Block) { b.Data = "rewritten" },
+		"has a parent":     func(b *Block) { b.PrevHash = "00" },
+		"not at index 0":   func(b *Block) { b.Index = 1 },
+	} {
+		chain := newGrownChain(t, 1)
+		corrupt(&chain.Blocks[0])
+		if index, err := chain.Validate(); index != 0 || !errors.Is(err, ErrInvalidBlock) {
+			t.Errorf("%s: got %d, %v, want the genesis block invalid", name, index, err)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package blockchain
+
+import "math/big"
+
+// MaxDifficulty is the highest difficulty a block can meet: every hex digit
+// of a 32-byte hash zero
+const MaxDifficulty = 64
+
+// BlockWork returns the work a block of the given difficulty proves: the
+// expected number of hashes it takes to mine, 16^difficulty, as the
+// difficulty counts leading hex zeros. Work is computed for chains before
+// their blocks are validated, so the difficulty is clamped to 0 through
+// MaxDifficulty; blocks outside that range fail validation anyway.
+func BlockWork(difficulty int) *big.Int {
+	difficulty = min(max(difficulty, 0), MaxDifficulty)
+	return new(big.Int).Lsh(big.NewInt(1), uint(4*difficulty))
+}
+
+// TotalWork sums the work of blocks. Chains are compared by it rather than
+// by length, so a long chain of easy blocks can't displace a shorter chain
+// that took more hashing to mine.
+func TotalWork(blocks []Block) *big.Int {
+	total := new(big.Int)
+	for _, block := range blocks {
+		total.Add(total, BlockWork(block.Difficulty))
+	}
+	return total
+}
+
+// TotalWork returns the total work of the chain
+func (bc *Chain) TotalWork() *big.Int {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+	return TotalWork(bc.Blocks)
+}
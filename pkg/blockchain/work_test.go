@@ -0,0 +1,65 @@
+package blockchain
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+// mineChain mines blocks on parent at the given difficulties
+func mineChain(t testing.TB, parent Block, difficulties ...int) []Block {
+	t.Helper()
+	blocks := make([]Block, 0, len(difficulties))
+	for _, difficulty := range difficulties {
+		parent = mineBlock(t, parent, difficulty)
+		blocks = append(blocks, parent)
+	}
+	return blocks
+}
+
+func TestBlockWork(t *testing.T) {
+	tests := []struct {
+		difficulty int
+		want       *big.Int
+	}{
+		{0, big.NewInt(1)},
+		{1, big.NewInt(16)},
+		{3, big.NewInt(4096)},
+		{-1, big.NewInt(1)},
+		{math.MinInt, big.NewInt(1)},
+		{math.MaxInt, new(big.Int).Lsh(big.NewInt(1), 4*MaxDifficulty)},
+	}
+	for _, tt := range tests {
+		if got := BlockWork(tt.difficulty); got.Cmp(tt.want) != 0 {
+			t.Errorf("BlockWork(%d) = %v, want %v", tt.difficulty, got, tt.want)
+		}
+	}
+}
+
+func TestLongerEasierChainLosesToHeavierChain(t *testing.T) {
+	genesis := CreateGenesisBlock()
+	heavy := append([]Block{genesis}, mineChain(t, genesis, 3, 3)...)
+	light := append([]Block{genesis}, mineChain(t, genesis, 1, 1, 1, 1, 1)...)
+
+	chain := NewBlockchain()
+	if !chain.ReplaceChain(heavy) {
+		t.Fatal("heavier chain rejected")
+	}
+	if chain.ReplaceChain(light) {
+		t.Fatal("longer chain with less work replaced the heavier one")
+	}
+	if chain.GetLatestBlock().Hash != heavy[len(heavy)-1].Hash {
+		t.Fatal("tip moved off the heavier chain")
+	}
+}
+
+func TestReplaceChainRejectsForgedDifficulty(t *testing.T) {
+	genesis := CreateGenesisBlock()
+	forged := append([]Block{genesis}, mineChain(t, genesis, 1)...)
+	forged[1].Difficulty = math.MaxInt
+
+	chain := NewBlockchain()
+	if chain.ReplaceChain(forged) {
+		t.Fatal("chain with a forged difficulty accepted")
+	}
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/wallet"
+)
+
+// Contract is a deployed contract
+type Contract struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Owner     string    `json:"owner"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Functions int       `json:"functions"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// DeployRequest is a contract to deploy. WASM code is base64-encoded, Lua
+// code is the source.
+type DeployRequest struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Code  string `json:"code"`
+	Owner string `json:"owner,omitempty"` // With the API key only
+	wallet.Signature
+}
+
+// Sign signs the deploy, making the wallet's address the owner
+func (d *DeployRequest) Sign(w *wallet.Wallet, now time.Time) {
+	d.Signature = w.Sign(actionDeploy, "", wallet.CodeHash(d.Code), now)
+}
+
+// Deployment is the outcome of a deploy
+type Deployment struct {
+	ID     string `json:"id"`
+	Owner  string `json:"owner"`
+	Status string `json:"status"`
+}
+
+// ExecuteRequest is a call of a contract function
+type ExecuteRequest struct {
+	Function string          `json:"function"`
+	Params   json.RawMessage `json:"params,omitempty"` // JSON array
+	GasLimit uint64          `json:"gasLimit,omitempty"`
+	wallet.Signature
+}
+
+// Sign signs the call of a contract, making the wallet's address the caller
+func (e *ExecuteRequest) Sign(w *wallet.Wallet, contractID string, now time.Time) {
+	e.Signature = w.Sign(actionExecute, contractID, wallet.CallHash(e.Function, e.Params), now)
+}
+
+// Event is an event emitted by a contract
+type Event struct {
+	ContractID string          `json:"contractId"`
+	Name       string          `json:"name,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+// Execution is the outcome of a successful call
+type Execution struct {
+	Result  interface{} `json:"result"`
+	GasUsed uint64      `json:"gasUsed,omitempty"`
+	Events  []Event     `json:"events,omitempty"`
+	Receipt string      `json:"receipt"`
+}
+
+// Contracts lists the deployed contracts
+func (c *Client) Contracts(ctx context.Context) ([]Contract, error) {
+	var response struct {
+		Contracts []Contract `json:"contracts"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/contracts", nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Contracts, nil
+}
+
+// DeployContract deploys a contract
+func (c *Client) DeployContract(ctx context.Context, deploy DeployRequest) (*Deployment, error) {
+	var deployment Deployment
+	if err := c.do(ctx, http.MethodPost, "/api/contracts", deploy, &deployment); err != nil {
+		return nil, err
+	}
+	return &deployment, nil
+}
+
+// ExecuteContract calls a contract function
+func (c *Client) ExecuteContract(ctx context.Context, contractID string, call ExecuteRequest) (*Execution, error) {
+	var execution Execution
+	if err := c.do(ctx, http.MethodPost, "/api/contracts/"+escape(contractID)+"/execute", call, &execution); err != nil {
+		return nil, err
+	}
+	return &execution, nil
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/version"
+)
+
+// Stats are a node's counters, from /api/stats
+type Stats struct {
+	BlockCount       int           `json:"blockCount"`
+	TransactionCount int           `json:"transactionCount"`
+	PeerCount        int           `json:"peerCount"`
+	WebSocketClients int           `json:"websocketClients"`
+	NodeHealthy      bool          `json:"nodeHealthy"`
+	Version          *version.Info `json:"version,omitempty"`
+}
+
+// Peer is a peer known to a node
+type Peer struct {
+	Address         string    `json:"address"`
+	NodeID          string    `json:"nodeId,omitempty"`
+	LastSeen        time.Time `json:"lastSeen"`
+	Seed            bool      `json:"seed"`
+	Role            string    `json:"role,omitempty"`
+	Version         string    `json:"version,omitempty"`
+	ProtocolVersion int       `json:"protocolVersion,omitempty"`
+	Height          int       `json:"height"`
+	DeliveryRatio   float64   `json:"deliveryRatio"`
+	LatencyMs       float64   `json:"latencyMs"`
+}
+
+// Stats returns the node's counters
+func (c *Client) Stats(ctx context.Context) (*Stats, error) {
+	var stats Stats
+	if err := c.do(ctx, http.MethodGet, "/api/stats", nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// Version returns the node's build and protocol versions
+func (c *Client) Version(ctx context.Context) (*version.Info, error) {
+	var info version.Info
+	if err := c.do(ctx, http.MethodGet, "/api/version", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Blocks returns the node's whole chain
+func (c *Client) Blocks(ctx context.Context) ([]blockchain.Block, error) {
+	var response struct {
+		Blocks []blockchain.Block `json:"blocks"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/blocks", nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Blocks, nil
+}
+
+// BlockRange returns up to limit blocks starting at height from, fewer
+// when the chain ends first
+func (c *Client) BlockRange(ctx context.Context, from, limit int) ([]blockchain.Block, error) {
+	var response struct {
+		Blocks []blockchain.Block `json:"blocks"`
+	}
+	path := fmt.Sprintf("/api/blocks?from=%d&limit=%d", from, limit)
+	if err := c.do(ctx, http.MethodGet, path, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Blocks, nil
+}
+
+// Block returns the block with a hash
+func (c *Client) Block(ctx context.Context, hash string) (*blockchain.Block, error) {
+	var block blockchain.Block
+	if err := c.do(ctx, http.MethodGet, "/api/blocks/"+escape(hash), nil, &block); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+// Peers returns the peers the node knows
+func (c *Client) Peers(ctx context.Context) ([]Peer, error) {
+	var response struct {
+		Peers []Peer `json:"peers"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/peers", nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Peers, nil
+}
+
+// AddPeer has the node connect to a peer. It requires the API key.
+func (c *Client) AddPeer(ctx context.Context, address string) error {
+	return c.do(ctx, http.MethodPost, "/api/peers", map[string]string{"address": address}, nil)
+}
+
+// Mine has the node mine a block from its pending transactions
+func (c *Client) Mine(ctx context.Context) (*blockchain.Block, error) {
+	var block blockchain.Block
+	if err := c.do(ctx, http.MethodPost, "/api/mine", nil, &block); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/wallet"
+)
+
+// Actions covered by request signatures, matching the API's
+const (
+	actionDeploy      = "deploy"
+	actionExecute     = "execute"
+	actionTransaction = "transaction"
+)
+
+// Transaction statuses
+const (
+	StatusPending   = "pending"
+	StatusConfirmed = "confirmed"
+)
+
+// TransactionRequest is a transaction to submit
+type TransactionRequest struct {
+	From  string  `json:"from"`
+	To    string  `json:"to"`
+	Value float64 `json:"value"`
+	Fee   float64 `json:"fee,omitempty"`
+	Data  string  `json:"data,omitempty"`
+	wallet.Signature
+}
+
+// Sign sets the sender to the wallet's address and signs the transaction
+func (tx *TransactionRequest) Sign(w *wallet.Wallet, now time.Time) {
+	tx.From = w.Address()
+	tx.Signature = w.Sign(actionTransaction, "", wallet.TransactionHash(tx.From, tx.To, tx.Value, tx.Fee, tx.Data), now)
+}
+
+// TransactionStatus is a transaction with whether it has been mined, and
+// where
+type TransactionStatus struct {
+	blockchain.Transaction
+	Status     string `json:"status"`
+	BlockHash  string `json:"blockHash,omitempty"`
+	BlockIndex *int   `json:"blockIndex,omitempty"`
+}
+
+// SendTransaction submits a transaction to the pool and returns its ID
+func (c *Client) SendTransaction(ctx context.Context, tx TransactionRequest) (string, error) {
+	var response struct {
+		ID string `json:"id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/transactions", tx, &response); err != nil {
+		return "", err
+	}
+	return response.ID, nil
+}
+
+// Balance is the balance of an address. Pending is the value of the
+// address's pending transactions, which Available leaves out.
+type Balance struct {
+	Address   string  `json:"address"`
+	Balance   float64 `json:"balance"`
+	Pending   float64 `json:"pending"`
+	Available float64 `json:"available"`
+}
+
+// Balance returns the balance of an address as of the node's tip
+func (c *Client) Balance(ctx context.Context, address string) (*Balance, error) {
+	var balance Balance
+	if err := c.do(ctx, http.MethodGet, "/api/addresses/"+escape(address)+"/balance", nil, &balance); err != nil {
+		return nil, err
+	}
+	return &balance, nil
+}
+
+// Faucet has a dev-mode node credit amount to an address and returns the
+// ID of the faucet transaction
+func (c *Client) Faucet(ctx context.Context, address string, amount float64) (string, error) {
+	var response struct {
+		ID string `json:"id"`
+	}
+	request := map[string]interface{}{"address": address, "amount": amount}
+	if err := c.do(ctx, http.MethodPost, "/api/faucet", request, &response); err != nil {
+		return "", err
+	}
+	return response.ID, nil
+}
+
+// Transaction returns a pending or mined transaction
+func (c *Client) Transaction(ctx context.Context, id string) (*TransactionStatus, error) {
+	var status TransactionStatus
+	if err := c.do(ctx, http.MethodGet, "/api/transactions/"+escape(id), nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
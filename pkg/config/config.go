@@ -0,0 +1,370 @@
+// Package config loads the node configuration from an optional YAML or JSON
+// file, environment variables, and command-line flags, in increasing order
+// of precedence, and validates it.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/consensus"
+	"github.com/anekazek/simple-blockchain/pkg/metrics"
+	"github.com/anekazek/simple-blockchain/pkg/network"
+	"github.com/anekazek/simple-blockchain/pkg/snapshot"
+	"github.com/anekazek/simple-blockchain/pkg/tracing"
+)
+
+// Consensus algorithms a node can run
+const (
+	ConsensusPoW = consensus.PoW
+	ConsensusPoS = consensus.PoS
+)
+
+// Storage backends for blocks and deployed contracts
+const (
+	BackendMemory  = "memory"
+	BackendLevelDB = "leveldb"
+)
+
+// P2P transports
+const (
+	TransportHTTP   = "http"
+	TransportLibp2p = "libp2p"
+)
+
+// DefaultP2PPort is the P2P port used when peers or seeds are configured
+// without one
+const DefaultP2PPort = 3000
+
+// Config is the whole node configuration. Keys in files, error messages and
+// the documentation are the yaml tags joined by dots, such as p2p.maxPeers.
+type Config struct {
+	Node      NodeConfig      `yaml:"node"`
+	Consensus ConsensusConfig `yaml:"consensus"`
+	Pool      PoolConfig      `yaml:"pool"`
+	API       APIConfig       `yaml:"api"`
+	Storage   StorageConfig   `yaml:"storage"`
+	Metrics   MetricsConfig   `yaml:"metrics"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	P2P       P2PConfig       `yaml:"p2p"`
+	Miner     MinerConfig     `yaml:"miner"`
+	Dev       DevConfig       `yaml:"dev"`
+}
+
+// NodeConfig locates the node's own data
+type NodeConfig struct {
+	// DataDir holds the node's identity key file
+	DataDir string `yaml:"dataDir"`
+	// IdentityPassphrase encrypts the identity key file when set
+	IdentityPassphrase string `yaml:"identityPassphrase"`
+}
+
+// ConsensusConfig selects the consensus algorithm
+type ConsensusConfig struct {
+	// Type is ConsensusPoW or ConsensusPoS
+	Type string `yaml:"type"`
+	// Difficulty is the number of leading zeros mined block hashes need
+	Difficulty int `yaml:"difficulty"`
+	// MaxBlockBytes caps the JSON encoding of a block
+	MaxBlockBytes int `yaml:"maxBlockBytes"`
+	// MaxBlockTransactions caps how many transactions a block carries
+	MaxBlockTransactions int `yaml:"maxBlockTransactions"`
+	// HashAlgo is the hash algorithm of mined blocks, one of
+	// blockchain.HashAlgos
+	HashAlgo string `yaml:"hashAlgo"`
+	// Checkpoints pin block hashes the chain must keep, as height:hash
+	Checkpoints []string `yaml:"checkpoints"`
+	// CheckpointsFile is a JSON array of more checkpoints, each with a
+	// height and a hash
+	CheckpointsFile string `yaml:"checkpointsFile"`
+}
+
+// LoadCheckpoints returns the checkpoints of Checkpoints and those read
+// from CheckpointsFile
+func (c ConsensusConfig) LoadCheckpoints() ([]blockchain.Checkpoint, error) {
+	checkpoints := make([]blockchain.Checkpoint, 0, len(c.Checkpoints))
+	for _, s := range c.Checkpoints {
+		checkpoint, err := blockchain.ParseCheckpoint(s)
+		if err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	if c.CheckpointsFile == "" {
+		return checkpoints, nil
+	}
+
+	data, err := os.ReadFile(c.CheckpointsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoints: %w", err)
+	}
+	var listed []blockchain.Checkpoint
+	if err := json.Unmarshal(data, &listed); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoints file %s: %w", c.CheckpointsFile, err)
+	}
+	return append(checkpoints, listed...), nil
+}
+
+// PoolConfig sizes the transaction pool
+type PoolConfig struct {
+	Size int `yaml:"size"`
+}
+
+// APIConfig configures the REST and WebSocket servers
+type APIConfig struct {
+	HTTPPort int `yaml:"httpPort"`
+	WSPort   int `yaml:"wsPort"`
+	// AdminToken is the bearer token that may manage every contract; empty
+	// disables admin access
+	AdminToken string    `yaml:"adminToken"`
+	TLS        TLSConfig `yaml:"tls"`
+}
+
+// TLSConfig names a certificate and its key. Both are set or neither is.
+type TLSConfig struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+// Enabled reports whether a certificate is configured
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+// StorageConfig selects where blocks and deployed contracts are kept
+type StorageConfig struct {
+	// Backend is BackendMemory or BackendLevelDB
+	Backend string `yaml:"backend"`
+	// Path is the LevelDB directory
+	Path string `yaml:"path"`
+}
+
+// MetricsConfig configures the Prometheus endpoints
+type MetricsConfig struct {
+	Port int `yaml:"port"`
+	// OnAPI serves the metrics from the API port instead of Port
+	OnAPI              bool       `yaml:"onAPI"`
+	Pprof              bool       `yaml:"pprof"`
+	PprofMutexFraction int        `yaml:"pprofMutexFraction"`
+	PprofBlockRate     int        `yaml:"pprofBlockRate"`
+	Push               PushConfig `yaml:"push"`
+}
+
+// PushConfig configures pushing metrics to a Pushgateway
+type PushConfig struct {
+	// URL enables pushing when set
+	URL      string        `yaml:"url"`
+	Job      string        `yaml:"job"`
+	Instance string        `yaml:"instance"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// TracingConfig configures OpenTelemetry tracing
+type TracingConfig struct {
+	Enabled     bool    `yaml:"enabled"`
+	ServiceName string  `yaml:"serviceName"`
+	SampleRatio float64 `yaml:"sampleRatio"`
+}
+
+// P2PConfig configures peer-to-peer networking
+type P2PConfig struct {
+	// Port enables P2P networking when set; 0 leaves it off unless peers
+	// or seeds are configured
+	Port          int      `yaml:"port"`
+	Transport     string   `yaml:"transport"`
+	Peers         []string `yaml:"peers"`
+	Seeds         []string `yaml:"seeds"`
+	AdvertiseAddr string   `yaml:"advertiseAddr"`
+	NetworkID     string   `yaml:"networkID"`
+	MaxPeers      int      `yaml:"maxPeers"`
+	// Role is network.RoleFull or network.RoleLight
+	Role              string        `yaml:"role"`
+	Auth              bool          `yaml:"auth"`
+	WebSocket         bool          `yaml:"websocket"`
+	MDNS              bool          `yaml:"mdns"`
+	DiscoveryInterval time.Duration `yaml:"discoveryInterval"`
+	SyncInterval      time.Duration `yaml:"syncInterval"`
+	ConnectTimeout    time.Duration `yaml:"connectTimeout"`
+	RequestTimeout    time.Duration `yaml:"requestTimeout"`
+	TLS               P2PTLSConfig  `yaml:"tls"`
+	// FastSync installs a snapshot from a peer when the store is empty,
+	// trusting those signed by SnapshotSigners or below SnapshotCheckpoint
+	FastSync           bool     `yaml:"fastSync"`
+	SnapshotSigners    []string `yaml:"snapshotSigners"`
+	SnapshotCheckpoint string   `yaml:"snapshotCheckpoint"` // height:hash
+	// SnapshotBlocks is how many recent blocks the served snapshots hold
+	SnapshotBlocks int `yaml:"snapshotBlocks"`
+	// MaxOrphans and OrphanTTL bound the blocks held until their parent
+	// arrives
+	MaxOrphans int           `yaml:"maxOrphans"`
+	OrphanTTL  time.Duration `yaml:"orphanTTL"`
+}
+
+// P2PTLSConfig configures TLS for peer traffic
+type P2PTLSConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// CertFile and KeyFile default to the API certificate
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+	CAFile   string `yaml:"caFile"`
+	// Pins are SHA-256 fingerprints of accepted peer certificates
+	Pins []string `yaml:"pins"`
+}
+
+// MinerConfig configures mining pending transactions in the background
+type MinerConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Interval is how often the pool is checked for transactions to mine
+	Interval time.Duration `yaml:"interval"`
+	// Address is credited with mined blocks, the node ID when empty
+	Address string `yaml:"address"`
+	// Workers is how many goroutines search for nonces, one per CPU when 0
+	Workers int `yaml:"workers"`
+}
+
+// DefaultDevSeed derives the dev accounts when dev.seed is not set
+const DefaultDevSeed = "simple-blockchain dev"
+
+// DevConfig configures development mode: a chain whose genesis funds
+// accounts derived from a public seed, and a faucet handing out more
+type DevConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Accounts is how many accounts the genesis funds
+	Accounts int `yaml:"accounts"`
+	// Balance is the genesis balance of each account
+	Balance float64 `yaml:"balance"`
+	// Seed derives the account keys
+	Seed string `yaml:"seed"`
+	// FaucetMaxAmount caps a single faucet request
+	FaucetMaxAmount float64 `yaml:"faucetMaxAmount"`
+	// FaucetInterval is how long an address waits between faucet requests
+	FaucetInterval time.Duration `yaml:"faucetInterval"`
+}
+
+// Default returns the configuration of a node started with no file,
+// environment variables or flags
+func Default() Config {
+	push := metrics.DefaultPushConfig()
+	trace := tracing.DefaultConfig()
+	intervals := network.DefaultIntervalConfig()
+	client := network.DefaultClientConfig()
+	limits := blockchain.DefaultBlockLimits()
+	orphans := blockchain.DefaultOrphanLimits()
+
+	return Config{
+		Node:      NodeConfig{DataDir: "data"},
+		Consensus: ConsensusConfig{Type: ConsensusPoW, Difficulty: 1, MaxBlockBytes: limits.MaxBytes, MaxBlockTransactions: limits.MaxTransactions, HashAlgo: blockchain.DefaultHashAlgo},
+		Pool:      PoolConfig{Size: 1000},
+		API:       APIConfig{HTTPPort: 8080, WSPort: 8081},
+		Storage:   StorageConfig{Backend: BackendMemory},
+		Metrics: MetricsConfig{
+			Port: 9090,
+			Push: PushConfig{Job: push.Job, Interval: push.Interval},
+		},
+		Tracing: TracingConfig{ServiceName: trace.ServiceName, SampleRatio: trace.SampleRatio},
+		P2P: P2PConfig{
+			Transport:         TransportHTTP,
+			NetworkID:         network.DefaultNetworkID,
+			MaxPeers:          network.DefaultMaxPeers,
+			Role:              network.RoleFull,
+			DiscoveryInterval: intervals.Discovery,
+			SyncInterval:      intervals.Sync,
+			ConnectTimeout:    client.ConnectTimeout,
+			RequestTimeout:    client.RequestTimeout,
+			SnapshotBlocks:    64,
+			MaxOrphans:        orphans.MaxBlocks,
+			OrphanTTL:         orphans.TTL,
+		},
+		Miner: MinerConfig{Interval: 10 * time.Second},
+		Dev: DevConfig{
+			Accounts:        10,
+			Balance:         1000,
+			Seed:            DefaultDevSeed,
+			FaucetMaxAmount: 100,
+			FaucetInterval:  time.Minute,
+		},
+	}
+}
+
+// Enabled reports whether P2P networking is configured
+func (c P2PConfig) Enabled() bool {
+	return c.Port != 0 || len(c.Peers) > 0 || len(c.Seeds) > 0
+}
+
+// ListenPort returns the P2P port, DefaultP2PPort when none is set
+func (c P2PConfig) ListenPort() string {
+	if c.Port == 0 {
+		return strconv.Itoa(DefaultP2PPort)
+	}
+	return strconv.Itoa(c.Port)
+}
+
+// Intervals returns the P2P background loop periods
+func (c P2PConfig) Intervals() network.IntervalConfig {
+	return network.IntervalConfig{Discovery: c.DiscoveryInterval, Sync: c.SyncInterval}
+}
+
+// ClientConfig returns the peer client limits
+func (c P2PConfig) ClientConfig() network.ClientConfig {
+	client := network.DefaultClientConfig()
+	client.ConnectTimeout = c.ConnectTimeout
+	client.RequestTimeout = c.RequestTimeout
+	return client
+}
+
+// SnapshotTrust returns the snapshots fast sync trusts
+func (c P2PConfig) SnapshotTrust() (snapshot.Trust, error) {
+	trust := snapshot.Trust{Signers: c.SnapshotSigners}
+	if c.SnapshotCheckpoint != "" {
+		checkpoint, err := snapshot.ParseCheckpoint(c.SnapshotCheckpoint)
+		if err != nil {
+			return snapshot.Trust{}, err
+		}
+		trust.Checkpoint = &checkpoint
+	}
+	return trust, nil
+}
+
+// PeerTLSConfig returns the P2P TLS settings, falling back to the API
+// certificate when no dedicated P2P certificate is configured
+func (c *Config) PeerTLSConfig() network.PeerTLSConfig {
+	tls := network.PeerTLSConfig{
+		CertFile:           c.P2P.TLS.CertFile,
+		KeyFile:            c.P2P.TLS.KeyFile,
+		CAFile:             c.P2P.TLS.CAFile,
+		PinnedFingerprints: c.P2P.TLS.Pins,
+	}
+	if tls.CertFile == "" && tls.KeyFile == "" {
+		tls.CertFile = c.API.TLS.CertFile
+		tls.KeyFile = c.API.TLS.KeyFile
+	}
+	return tls
+}
+
+// ProfilingConfig returns the pprof settings
+func (c MetricsConfig) ProfilingConfig() metrics.ProfilingConfig {
+	return metrics.ProfilingConfig{
+		Enabled:              c.Pprof,
+		MutexProfileFraction: c.PprofMutexFraction,
+		BlockProfileRate:     c.PprofBlockRate,
+	}
+}
+
+// PushConfig returns the Pushgateway settings
+func (c MetricsConfig) PushConfig() metrics.PushConfig {
+	push := metrics.DefaultPushConfig()
+	push.URL = c.Push.URL
+	push.Job = c.Push.Job
+	push.Instance = c.Push.Instance
+	push.Interval = c.Push.Interval
+	return push
+}
+
+// Config returns the tracing settings
+func (c TracingConfig) Config() tracing.Config {
+	return tracing.Config{Enabled: c.Enabled, ServiceName: c.ServiceName, SampleRatio: c.SampleRatio}
+}
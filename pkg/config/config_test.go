@@ -0,0 +1,43 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+func TestLoadCheckpointsFromListAndFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "checkpoints.json")
+	if err := os.WriteFile(file, []byte(`[{"height": 10, "hash": "bb"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := ConsensusConfig{Checkpoints: []string{"5:aa"}, CheckpointsFile: file}
+	got, err := c.LoadCheckpoints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []blockchain.Checkpoint{{Height: 5, Hash: "aa"}, {Height: 10, Hash: "bb"}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestLoadCheckpointsErrors(t *testing.T) {
+	bad := filepath.Join(t.TempDir(), "bad.json")
+	if err := os.WriteFile(bad, []byte(`{`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, c := range map[string]ConsensusConfig{
+		"malformed entry": {Checkpoints: []string{"five:aa"}},
+		"missing file":    {CheckpointsFile: filepath.Join(t.TempDir(), "missing.json")},
+		"malformed file":  {CheckpointsFile: bad},
+	} {
+		if _, err := c.LoadCheckpoints(); err == nil {
+			t.Errorf("%s: loaded", name)
+		}
+	}
+}
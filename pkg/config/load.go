@@ -0,0 +1,288 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidConfig is returned when a setting has an invalid value
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// FileEnv names the environment variable holding the configuration file
+// path, which the -config flag overrides
+const FileEnv = "CONFIG_FILE"
+
+// setting binds a configuration key to its environment variable and flag
+type setting struct {
+	key   string // Dotted key, as in files
+	env   string
+	flag  string
+	usage string
+	value flag.Value // Reads and writes the field in the Config
+}
+
+// settings lists every setting of c, bound to its fields
+func (c *Config) settings() []setting {
+	return []setting{
+		{"node.dataDir", "DATA_DIR", "data-dir", "directory of the node's identity key file", (*stringValue)(&c.Node.DataDir)},
+		{"node.identityPassphrase", "IDENTITY_PASSPHRASE", "identity-passphrase", "passphrase encrypting the identity key file", (*stringValue)(&c.Node.IdentityPassphrase)},
+
+		{"consensus.type", "CONSENSUS", "consensus", "consensus algorithm: pow or pos", (*stringValue)(&c.Consensus.Type)},
+		{"consensus.difficulty", "BLOCKCHAIN_DIFFICULTY", "difficulty", "mining difficulty", (*intValue)(&c.Consensus.Difficulty)},
+		{"consensus.maxBlockBytes", "MAX_BLOCK_BYTES", "max-block-bytes", "largest block, in bytes of its JSON encoding", (*intValue)(&c.Consensus.MaxBlockBytes)},
+		{"consensus.maxBlockTransactions", "MAX_BLOCK_TRANSACTIONS", "max-block-transactions", "most transactions a block carries", (*intValue)(&c.Consensus.MaxBlockTransactions)},
+		{"consensus.hashAlgo", "HASH_ALGO", "hash-algo", "hash algorithm of mined blocks: sha256, sha3-256 or blake2b-256", (*stringValue)(&c.Consensus.HashAlgo)},
+		{"consensus.checkpoints", "CHECKPOINTS", "checkpoints", "comma-separated height:hash of blocks the chain must keep", (*listValue)(&c.Consensus.Checkpoints)},
+		{"consensus.checkpointsFile", "CHECKPOINTS_FILE", "checkpoints-file", "JSON file of checkpoints, each with a height and a hash", (*stringValue)(&c.Consensus.CheckpointsFile)},
+		{"pool.size", "TX_POOL_SIZE", "pool-size", "transaction pool capacity", (*intValue)(&c.Pool.Size)},
+
+		{"api.httpPort", "HTTP_PORT", "http-port", "HTTP API port", (*intValue)(&c.API.HTTPPort)},
+		{"api.wsPort", "WS_PORT", "ws-port", "WebSocket server port", (*intValue)(&c.API.WSPort)},
+		{"api.adminToken", "API_ADMIN_TOKEN", "admin-token", "bearer token that may manage every contract", (*stringValue)(&c.API.AdminToken)},
+		{"api.tls.certFile", "TLS_CERT_FILE", "tls-cert", "API TLS certificate file", (*stringValue)(&c.API.TLS.CertFile)},
+		{"api.tls.keyFile", "TLS_KEY_FILE", "tls-key", "API TLS key file", (*stringValue)(&c.API.TLS.KeyFile)},
+
+		{"storage.backend", "STORAGE_BACKEND", "storage-backend", "block and contract storage: memory or leveldb", (*stringValue)(&c.Storage.Backend)},
+		{"storage.path", "STORAGE_PATH", "storage-path", "LevelDB directory of the leveldb backend", (*stringValue)(&c.Storage.Path)},
+
+		{"metrics.port", "METRICS_PORT", "metrics-port", "Prometheus metrics port", (*intValue)(&c.Metrics.Port)},
+		{"metrics.onAPI", "METRICS_ON_API", "metrics-on-api", "serve metrics from the API port", (*boolValue)(&c.Metrics.OnAPI)},
+		{"metrics.pprof", "METRICS_PPROF", "pprof", "serve /debug/pprof/ on the metrics port", (*boolValue)(&c.Metrics.Pprof)},
+		{"metrics.pprofMutexFraction", "PPROF_MUTEX_FRACTION", "pprof-mutex-fraction", "sample 1 in n mutex contention events", (*intValue)(&c.Metrics.PprofMutexFraction)},
+		{"metrics.pprofBlockRate", "PPROF_BLOCK_RATE", "pprof-block-rate", "sample one blocking event per n nanoseconds blocked", (*intValue)(&c.Metrics.PprofBlockRate)},
+		{"metrics.push.url", "METRICS_PUSH_URL", "metrics-push-url", "Pushgateway URL to push metrics to", (*stringValue)(&c.Metrics.Push.URL)},
+		{"metrics.push.job", "METRICS_PUSH_JOB", "metrics-push-job", "job name of pushed metrics", (*stringValue)(&c.Metrics.Push.Job)},
+		{"metrics.push.instance", "METRICS_PUSH_INSTANCE", "metrics-push-instance", "instance label of pushed metrics", (*stringValue)(&c.Metrics.Push.Instance)},
+		{"metrics.push.interval", "METRICS_PUSH_INTERVAL", "metrics-push-interval", "how often metrics are pushed", (*durationValue)(&c.Metrics.Push.Interval)},
+
+		{"tracing.enabled", "TRACING_ENABLED", "tracing", "export OpenTelemetry traces over OTLP/HTTP", (*boolValue)(&c.Tracing.Enabled)},
+		{"tracing.serviceName", "OTEL_SERVICE_NAME", "tracing-service-name", "service name of exported traces", (*stringValue)(&c.Tracing.ServiceName)},
+		{"tracing.sampleRatio", "TRACING_SAMPLE_RATIO", "tracing-sample-ratio", "share of new traces recorded, from 0 to 1", (*floatValue)(&c.Tracing.SampleRatio)},
+
+		{"p2p.port", "P2P_PORT", "p2p-port", "P2P port; enables P2P networking", (*intValue)(&c.P2P.Port)},
+		{"p2p.transport", "P2P_TRANSPORT", "p2p-transport", "P2P backend: http or libp2p", (*stringValue)(&c.P2P.Transport)},
+		{"p2p.peers", "PEERS", "peers", "comma-separated peers to connect to", (*listValue)(&c.P2P.Peers)},
+		{"p2p.seeds", "SEED_PEERS", "seeds", "comma-separated bootstrap peers", (*listValue)(&c.P2P.Seeds)},
+		{"p2p.advertiseAddr", "P2P_ADVERTISE_ADDR", "p2p-advertise-addr", "host:port announced to peers", (*stringValue)(&c.P2P.AdvertiseAddr)},
+		{"p2p.networkID", "P2P_NETWORK_ID", "p2p-network-id", "network name peers must share", (*stringValue)(&c.P2P.NetworkID)},
+		{"p2p.maxPeers", "P2P_MAX_PEERS", "p2p-max-peers", "maximum number of stored peers", (*intValue)(&c.P2P.MaxPeers)},
+		{"p2p.role", "P2P_ROLE", "p2p-role", "node role: full or light", (*stringValue)(&c.P2P.Role)},
+		{"p2p.auth", "P2P_AUTH", "p2p-auth", "require signed gossip from peers", (*boolValue)(&c.P2P.Auth)},
+		{"p2p.websocket", "P2P_WEBSOCKET", "p2p-websocket", "keep WebSocket sessions with peers", (*boolValue)(&c.P2P.WebSocket)},
+		{"p2p.mdns", "P2P_MDNS", "p2p-mdns", "discover peers on the local network", (*boolValue)(&c.P2P.MDNS)},
+		{"p2p.discoveryInterval", "P2P_DISCOVERY_INTERVAL", "p2p-discovery-interval", "how often peers are asked for their peers", (*durationValue)(&c.P2P.DiscoveryInterval)},
+		{"p2p.syncInterval", "P2P_SYNC_INTERVAL", "p2p-sync-interval", "how often the chain is synced with peers", (*durationValue)(&c.P2P.SyncInterval)},
+		{"p2p.connectTimeout", "P2P_CONNECT_TIMEOUT", "p2p-connect-timeout", "timeout for dialing a peer", (*durationValue)(&c.P2P.ConnectTimeout)},
+		{"p2p.requestTimeout", "P2P_REQUEST_TIMEOUT", "p2p-request-timeout", "timeout for a whole peer request", (*durationValue)(&c.P2P.RequestTimeout)},
+		{"p2p.tls.enabled", "P2P_TLS", "p2p-tls", "serve and reach peers over HTTPS", (*boolValue)(&c.P2P.TLS.Enabled)},
+		{"p2p.tls.certFile", "P2P_TLS_CERT_FILE", "p2p-tls-cert", "P2P TLS certificate file", (*stringValue)(&c.P2P.TLS.CertFile)},
+		{"p2p.tls.keyFile", "P2P_TLS_KEY_FILE", "p2p-tls-key", "P2P TLS key file", (*stringValue)(&c.P2P.TLS.KeyFile)},
+		{"p2p.tls.caFile", "P2P_TLS_CA_FILE", "p2p-tls-ca", "PEM roots trusted for peer certificates", (*stringValue)(&c.P2P.TLS.CAFile)},
+		{"p2p.tls.pins", "P2P_TLS_PINS", "p2p-tls-pins", "comma-separated SHA-256 fingerprints of accepted peer certificates", (*listValue)(&c.P2P.TLS.Pins)},
+		{"p2p.fastSync", "FAST_SYNC", "fast-sync", "install a trusted snapshot from a peer when the store is empty", (*boolValue)(&c.P2P.FastSync)},
+		{"p2p.snapshotSigners", "SNAPSHOT_SIGNERS", "snapshot-signers", "comma-separated node IDs trusted to sign snapshots", (*listValue)(&c.P2P.SnapshotSigners)},
+		{"p2p.snapshotCheckpoint", "SNAPSHOT_CHECKPOINT", "snapshot-checkpoint", "height:hash of a trusted block; snapshots at or below it are trusted", (*stringValue)(&c.P2P.SnapshotCheckpoint)},
+		{"p2p.maxOrphans", "MAX_ORPHANS", "max-orphans", "maximum number of blocks held until their parent arrives", (*intValue)(&c.P2P.MaxOrphans)},
+		{"p2p.orphanTTL", "ORPHAN_TTL", "orphan-ttl", "how long a block is held waiting for its parent", (*durationValue)(&c.P2P.OrphanTTL)},
+		{"p2p.snapshotBlocks", "SNAPSHOT_BLOCKS", "snapshot-blocks", "recent blocks held by the snapshots served to peers", (*intValue)(&c.P2P.SnapshotBlocks)},
+
+		{"miner.enabled", "MINER_ENABLED", "mine", "mine pending transactions in the background", (*boolValue)(&c.Miner.Enabled)},
+		{"miner.interval", "MINER_INTERVAL", "mine-interval", "how often the pool is checked for transactions to mine", (*durationValue)(&c.Miner.Interval)},
+		{"miner.address", "MINER_ADDRESS", "miner-address", "address credited with mined blocks; defaults to the node ID", (*stringValue)(&c.Miner.Address)},
+		{"miner.workers", "MINER_WORKERS", "miner-workers", "goroutines searching for block nonces; 0 for one per CPU", (*intValue)(&c.Miner.Workers)},
+
+		{"dev.enabled", "DEV", "dev", "run a development chain with funded accounts and a faucet; never on a real network", (*boolValue)(&c.Dev.Enabled)},
+		{"dev.accounts", "DEV_ACCOUNTS", "dev-accounts", "number of accounts funded by the dev genesis", (*intValue)(&c.Dev.Accounts)},
+		{"dev.balance", "DEV_BALANCE", "dev-balance", "genesis balance of each dev account", (*floatValue)(&c.Dev.Balance)},
+		{"dev.seed", "DEV_SEED", "dev-seed", "seed the dev account keys are derived from", (*stringValue)(&c.Dev.Seed)},
+		{"dev.faucetMaxAmount", "FAUCET_MAX_AMOUNT", "faucet-max-amount", "largest amount a single faucet request may ask for", (*floatValue)(&c.Dev.FaucetMaxAmount)},
+		{"dev.faucetInterval", "FAUCET_INTERVAL", "faucet-interval", "how long an address waits between faucet requests", (*durationValue)(&c.Dev.FaucetInterval)},
+	}
+}
+
+// Load builds the configuration from the defaults, the file named by the
+// -config flag or CONFIG_FILE, the environment, and the command-line
+// arguments, each overriding the last, and validates it. It returns
+// flag.ErrHelp when the arguments ask for usage.
+func Load(name string, args []string) (*Config, error) {
+	return load(name, args, os.LookupEnv, os.Stderr)
+}
+
+// load is Load with the environment and usage output supplied
+func load(name string, args []string, lookupEnv func(string) (string, bool), output io.Writer) (*Config, error) {
+	// Parse the flags once up front to find the file and reject bad flags
+	// before anything is read
+	parsed := Default()
+	fs, file := parsed.flagSet(name)
+	fs.SetOutput(output)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	cfg := Default()
+	path := *file
+	if path == "" {
+		path, _ = lookupEnv(FileEnv)
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.loadEnv(lookupEnv); err != nil {
+		return nil, err
+	}
+
+	// Flags given on the command line override everything else
+	settings := cfg.settings()
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name && err == nil {
+				err = s.value.Set(f.Value.String())
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// flagSet declares a flag for every setting, bound to c, and the -config
+// flag naming the file
+func (c *Config) flagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	file := fs.String("config", "", "YAML or JSON configuration file (env "+FileEnv+")")
+	for _, s := range c.settings() {
+		fs.Var(s.value, s.flag, fmt.Sprintf("%s (env %s, key %s)", s.usage, s.env, s.key))
+	}
+	return fs, file
+}
+
+// loadFile reads a YAML or JSON file over c. Keys the configuration doesn't
+// have are rejected, so typos don't go unnoticed.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidConfig, path, err)
+	}
+	return nil
+}
+
+// loadEnv applies the environment variables that are set over c
+func (c *Config) loadEnv(lookupEnv func(string) (string, bool)) error {
+	for _, s := range c.settings() {
+		value, ok := lookupEnv(s.env)
+		if !ok || value == "" {
+			continue
+		}
+		if err := s.value.Set(value); err != nil {
+			return fmt.Errorf("%w: %s: %s=%q: %v", ErrInvalidConfig, s.key, s.env, value, err)
+		}
+	}
+	return nil
+}
+
+// stringValue is a flag.Value writing a string field
+type stringValue string
+
+func (v *stringValue) Set(s string) error { *v = stringValue(s); return nil }
+func (v *stringValue) String() string     { return string(*v) }
+
+// intValue is a flag.Value writing an int field
+type intValue int
+
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return errors.New("not an integer")
+	}
+	*v = intValue(n)
+	return nil
+}
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+// floatValue is a flag.Value writing a float64 field
+type floatValue float64
+
+func (v *floatValue) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return errors.New("not a number")
+	}
+	*v = floatValue(f)
+	return nil
+}
+
+func (v *floatValue) String() string { return strconv.FormatFloat(float64(*v), 'g', -1, 64) }
+
+// boolValue is a flag.Value writing a bool field. As a flag it may be
+// given without a value.
+type boolValue bool
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return errors.New("not true or false")
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+func (v *boolValue) String() string   { return strconv.FormatBool(bool(*v)) }
+func (v *boolValue) IsBoolFlag() bool { return true }
+
+// durationValue is a flag.Value writing a time.Duration field, written
+// like "30s"
+type durationValue time.Duration
+
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return errors.New("not a duration such as 30s")
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+
+// listValue is a flag.Value writing a []string field from a
+// comma-separated list, dropping empty entries
+type listValue []string
+
+func (v *listValue) Set(s string) error {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*v = items
+	return nil
+}
+
+func (v *listValue) String() string { return strings.Join(*v, ",") }
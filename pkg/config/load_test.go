@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// env is a fake environment
+type env map[string]string
+
+func (e env) lookup(key string) (string, bool) {
+	value, ok := e[key]
+	return value, ok
+}
+
+// writeFile writes a configuration file into a temporary directory
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "node.yaml", `
+consensus:
+  difficulty: 2
+pool:
+  size: 50
+api:
+  httpPort: 7000
+p2p:
+  seeds: [a:3000, b:3000]
+`)
+	environment := env{FileEnv: file, "TX_POOL_SIZE": "60", "HTTP_PORT": "7100", "MINER_INTERVAL": "5s"}
+	cfg, err := load("node", []string{"-http-port", "7200"}, environment.lookup, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Consensus.Difficulty != 2 {
+		t.Errorf("difficulty %d, want the file's 2", cfg.Consensus.Difficulty)
+	}
+	if cfg.Pool.Size != 60 {
+		t.Errorf("pool size %d, want the environment's 60", cfg.Pool.Size)
+	}
+	if cfg.API.HTTPPort != 7200 {
+		t.Errorf("HTTP port %d, want the flag's 7200", cfg.API.HTTPPort)
+	}
+	if cfg.Miner.Interval != 5*time.Second {
+		t.Errorf("miner interval %s, want the environment's 5s", cfg.Miner.Interval)
+	}
+	if strings.Join(cfg.P2P.Seeds, ",") != "a:3000,b:3000" {
+		t.Errorf("seeds %v, want the file's", cfg.P2P.Seeds)
+	}
+	if cfg.API.WSPort != Default().API.WSPort {
+		t.Errorf("WebSocket port %d, want the default", cfg.API.WSPort)
+	}
+}
+
+func TestLoadConfigFlagOverridesFileEnv(t *testing.T) {
+	envFile := writeFile(t, "env.json", `{"pool": {"size": 10}}`)
+	flagFile := writeFile(t, "flag.json", `{"pool": {"size": 20}}`)
+	cfg, err := load("node", []string{"-config", flagFile}, env{FileEnv: envFile}.lookup, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Pool.Size != 20 {
+		t.Fatalf("pool size %d, want the -config file's 20", cfg.Pool.Size)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	typo := writeFile(t, "typo.yaml", "consensus:\n  dificulty: 3\n")
+	for name, c := range map[string]struct {
+		args []string
+		env  env
+		key  string
+	}{
+		"non-numeric env":   {env: env{"BLOCKCHAIN_DIFFICULTY": "hard"}, key: "consensus.difficulty"},
+		"unknown file key":  {env: env{FileEnv: typo}, key: "dificulty"},
+		"invalid flag":      {args: []string{"-difficulty", "0"}, key: "consensus.difficulty"},
+		"invalid duration":  {env: env{"P2P_SYNC_INTERVAL": "often"}, key: "p2p.syncInterval"},
+		"bad boolean value": {env: env{"MINER_ENABLED": "sometimes"}, key: "miner.enabled"},
+	} {
+		_, err := load("node", c.args, c.env.lookup, io.Discard)
+		if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), c.key) {
+			t.Errorf("%s: got %v, want an invalid configuration naming %s", name, err, c.key)
+		}
+	}
+
+	if _, err := load("node", []string{"-no-such-flag"}, env{}.lookup, io.Discard); err == nil {
+		t.Error("unknown flag accepted")
+	}
+	if _, err := load("node", []string{"extra"}, env{}.lookup, io.Discard); err == nil {
+		t.Error("stray argument accepted")
+	}
+	if _, err := load("node", []string{"-h"}, env{}.lookup, io.Discard); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("-h returned %v, want flag.ErrHelp", err)
+	}
+}
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/network"
+	"github.com/anekazek/simple-blockchain/pkg/snapshot"
+)
+
+// Validate checks every setting and reports each invalid one by key
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	if c.Node.DataDir == "" {
+		v.fail("node.dataDir", "must be set")
+	}
+	v.oneOf("consensus.type", c.Consensus.Type, ConsensusPoW, ConsensusPoS)
+	v.positive("consensus.difficulty", c.Consensus.Difficulty)
+	v.positive("consensus.maxBlockBytes", c.Consensus.MaxBlockBytes)
+	v.positive("consensus.maxBlockTransactions", c.Consensus.MaxBlockTransactions)
+	v.oneOf("consensus.hashAlgo", c.Consensus.HashAlgo, blockchain.HashAlgos()...)
+	for _, checkpoint := range c.Consensus.Checkpoints {
+		if _, err := blockchain.ParseCheckpoint(checkpoint); err != nil {
+			v.fail("consensus.checkpoints", "must be a list of height:hash")
+			break
+		}
+	}
+	v.positive("pool.size", c.Pool.Size)
+
+	v.port("api.httpPort", c.API.HTTPPort)
+	v.port("api.wsPort", c.API.WSPort)
+	if c.API.WSPort == c.API.HTTPPort {
+		v.fail("api.wsPort", "must differ from api.httpPort")
+	}
+	v.pair("api.tls", c.API.TLS.CertFile, c.API.TLS.KeyFile)
+
+	v.oneOf("storage.backend", c.Storage.Backend, BackendMemory, BackendLevelDB)
+	switch {
+	case c.Storage.Backend == BackendLevelDB && c.Storage.Path == "":
+		v.fail("storage.path", "is required by the leveldb backend")
+	case c.Storage.Backend == BackendMemory && c.Storage.Path != "":
+		v.fail("storage.path", "is only used by the leveldb backend")
+	}
+
+	if !c.Metrics.OnAPI {
+		v.port("metrics.port", c.Metrics.Port)
+		if c.Metrics.Port == c.API.HTTPPort || c.Metrics.Port == c.API.WSPort {
+			v.fail("metrics.port", "must differ from the API ports; set metrics.onAPI to share the HTTP port")
+		}
+	} else if c.Metrics.Pprof {
+		v.fail("metrics.pprof", "needs the private metrics port; it is never served from the API port, so unset metrics.onAPI")
+	}
+	v.nonNegative("metrics.pprofMutexFraction", c.Metrics.PprofMutexFraction)
+	v.nonNegative("metrics.pprofBlockRate", c.Metrics.PprofBlockRate)
+	if c.Metrics.Push.URL != "" {
+		if u, err := url.Parse(c.Metrics.Push.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.fail("metrics.push.url", "must be an http or https URL")
+		}
+		if c.Metrics.Push.Job == "" {
+			v.fail("metrics.push.job", "must not be empty")
+		}
+		v.positiveDuration("metrics.push.interval", c.Metrics.Push.Interval)
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		v.fail("tracing.sampleRatio", "must be between 0 and 1")
+	}
+
+	if c.P2P.Port != 0 {
+		v.port("p2p.port", c.P2P.Port)
+	}
+	v.oneOf("p2p.transport", c.P2P.Transport, TransportHTTP, TransportLibp2p)
+	v.oneOf("p2p.role", c.P2P.Role, network.RoleFull, network.RoleLight)
+	v.positive("p2p.maxPeers", c.P2P.MaxPeers)
+	if c.P2P.NetworkID == "" {
+		v.fail("p2p.networkID", "must not be empty")
+	}
+	v.positiveDuration("p2p.discoveryInterval", c.P2P.DiscoveryInterval)
+	v.positiveDuration("p2p.syncInterval", c.P2P.SyncInterval)
+	v.positiveDuration("p2p.connectTimeout", c.P2P.ConnectTimeout)
+	v.positiveDuration("p2p.requestTimeout", c.P2P.RequestTimeout)
+	v.pair("p2p.tls", c.P2P.TLS.CertFile, c.P2P.TLS.KeyFile)
+	if c.P2P.TLS.Enabled && !c.API.TLS.Enabled() && c.P2P.TLS.CertFile == "" {
+		v.fail("p2p.tls.enabled", "requires p2p.tls.certFile and p2p.tls.keyFile, or an API certificate")
+	}
+	v.positive("p2p.snapshotBlocks", c.P2P.SnapshotBlocks)
+	v.positive("p2p.maxOrphans", c.P2P.MaxOrphans)
+	v.positiveDuration("p2p.orphanTTL", c.P2P.OrphanTTL)
+	if c.P2P.SnapshotCheckpoint != "" {
+		if _, err := snapshot.ParseCheckpoint(c.P2P.SnapshotCheckpoint); err != nil {
+			v.fail("p2p.snapshotCheckpoint", "must be height:hash")
+		}
+	}
+	if c.P2P.FastSync {
+		if len(c.P2P.SnapshotSigners) == 0 && c.P2P.SnapshotCheckpoint == "" {
+			v.fail("p2p.fastSync", "requires p2p.snapshotSigners or p2p.snapshotCheckpoint")
+		}
+		if c.P2P.Transport != TransportHTTP || c.P2P.Role != network.RoleFull {
+			v.fail("p2p.fastSync", "requires the http transport and the full role")
+		}
+	}
+
+	v.nonNegative("miner.workers", c.Miner.Workers)
+	if c.Miner.Enabled {
+		v.positiveDuration("miner.interval", c.Miner.Interval)
+	}
+
+	if c.Dev.Enabled {
+		v.positive("dev.accounts", c.Dev.Accounts)
+		if c.Dev.Balance <= 0 {
+			v.fail("dev.balance", fmt.Sprintf("must be positive, got %g", c.Dev.Balance))
+		}
+		if c.Dev.Seed == "" {
+			v.fail("dev.seed", "must not be empty")
+		}
+		if c.Dev.FaucetMaxAmount <= 0 {
+			v.fail("dev.faucetMaxAmount", fmt.Sprintf("must be positive, got %g", c.Dev.FaucetMaxAmount))
+		}
+		v.positiveDuration("dev.faucetInterval", c.Dev.FaucetInterval)
+	}
+
+	return errors.Join(v.errs...)
+}
+
+// validator collects the invalid settings
+type validator struct {
+	errs []error
+}
+
+// fail records an invalid setting
+func (v *validator) fail(key, reason string) {
+	v.errs = append(v.errs, fmt.Errorf("%w: %s %s", ErrInvalidConfig, key, reason))
+}
+
+// positive requires n > 0
+func (v *validator) positive(key string, n int) {
+	if n <= 0 {
+		v.fail(key, fmt.Sprintf("must be positive, got %d", n))
+	}
+}
+
+// nonNegative requires n >= 0
+func (v *validator) nonNegative(key string, n int) {
+	if n < 0 {
+		v.fail(key, fmt.Sprintf("must not be negative, got %d", n))
+	}
+}
+
+// positiveDuration requires d > 0
+func (v *validator) positiveDuration(key string, d time.Duration) {
+	if d <= 0 {
+		v.fail(key, fmt.Sprintf("must be a positive duration, got %s", d))
+	}
+}
+
+// port requires a TCP port number
+func (v *validator) port(key string, port int) {
+	if port < 1 || port > 65535 {
+		v.fail(key, fmt.Sprintf("must be a port between 1 and 65535, got %d", port))
+	}
+}
+
+// oneOf requires value to be one of allowed
+func (v *validator) oneOf(key, value string, allowed ...string) {
+	if !slices.Contains(allowed, value) {
+		v.fail(key, fmt.Sprintf("must be one of %q, got %q", allowed, value))
+	}
+}
+
+// pair requires a certificate and key to be set together
+func (v *validator) pair(prefix, certFile, keyFile string) {
+	switch {
+	case certFile != "" && keyFile == "":
+		v.fail(prefix+".keyFile", "is required with "+prefix+".certFile")
+	case certFile == "" && keyFile != "":
+		v.fail(prefix+".certFile", "is required with "+prefix+".keyFile")
+	}
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDefaultIsValid(t *testing.T) {
+	cfg := Default()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateNamesOffendingKey(t *testing.T) {
+	for key, modify := range map[string]func(*Config){
+		"node.dataDir":                   func(c *Config) { c.Node.DataDir = "" },
+		"consensus.type":                 func(c *Config) { c.Consensus.Type = "pow2" },
+		"consensus.difficulty":           func(c *Config) { c.Consensus.Difficulty = 0 },
+		"consensus.maxBlockBytes":        func(c *Config) { c.Consensus.MaxBlockBytes = -1 },
+		"consensus.maxBlockTransactions": func(c *Config) { c.Consensus.MaxBlockTransactions = 0 },
+		"consensus.hashAlgo":             func(c *Config) { c.Consensus.HashAlgo = "md5" },
+		"consensus.checkpoints":          func(c *Config) { c.Consensus.Checkpoints = []string{"ten"} },
+		"pool.size":                      func(c *Config) { c.Pool.Size = 0 },
+		"api.httpPort":                   func(c *Config) { c.API.HTTPPort = 70000 },
+		"api.wsPort":                     func(c *Config) { c.API.WSPort = c.API.HTTPPort },
+		"api.tls.keyFile":                func(c *Config) { c.API.TLS.CertFile = "cert.pem" },
+		"api.tls.certFile":               func(c *Config) { c.API.TLS.KeyFile = "key.pem" },
+		"storage.backend":                func(c *Config) { c.Storage.Backend = "bolt" },
+		"storage.path":                   func(c *Config) { c.Storage.Backend = BackendLevelDB },
+		"metrics.port":                   func(c *Config) { c.Metrics.Port = c.API.HTTPPort },
+		"metrics.pprof":                  func(c *Config) { c.Metrics.OnAPI, c.Metrics.Pprof = true, true },
+		"metrics.pprofBlockRate":         func(c *Config) { c.Metrics.PprofBlockRate = -1 },
+		"metrics.push.url":               func(c *Config) { c.Metrics.Push.URL = "gateway:9091" },
+		"metrics.push.interval":          func(c *Config) { c.Metrics.Push.URL, c.Metrics.Push.Interval = "http://gateway", 0 },
+		"tracing.sampleRatio":            func(c *Config) { c.Tracing.SampleRatio = 2 },
+		"p2p.port":                       func(c *Config) { c.P2P.Port = -3 },
+		"p2p.transport":                  func(c *Config) { c.P2P.Transport = "udp" },
+		"p2p.role":                       func(c *Config) { c.P2P.Role = "archive" },
+		"p2p.maxPeers":                   func(c *Config) { c.P2P.MaxPeers = 0 },
+		"p2p.networkID":                  func(c *Config) { c.P2P.NetworkID = "" },
+		"p2p.syncInterval":               func(c *Config) { c.P2P.SyncInterval = 0 },
+		"p2p.tls.enabled":                func(c *Config) { c.P2P.TLS.Enabled = true },
+		"p2p.snapshotCheckpoint":         func(c *Config) { c.P2P.SnapshotCheckpoint = "tip" },
+		"p2p.fastSync":                   func(c *Config) { c.P2P.FastSync = true },
+		"p2p.orphanTTL":                  func(c *Config) { c.P2P.OrphanTTL = -1 },
+		"miner.workers":                  func(c *Config) { c.Miner.Workers = -1 },
+		"miner.interval":                 func(c *Config) { c.Miner.Enabled, c.Miner.Interval = true, 0 },
+		"dev.balance":                    func(c *Config) { c.Dev.Enabled, c.Dev.Balance = true, 0 },
+		"dev.seed":                       func(c *Config) { c.Dev.Enabled, c.Dev.Seed = true, "" },
+	} {
+		cfg := Default()
+		modify(&cfg)
+		err := cfg.Validate()
+		if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), key+" ") {
+			t.Errorf("%s: got %v", key, err)
+		}
+	}
+}
+
+func TestValidateReportsEveryFailure(t *testing.T) {
+	cfg := Default()
+	cfg.Pool.Size = 0
+	cfg.API.HTTPPort = 0
+	err := cfg.Validate()
+	for _, key := range []string{"pool.size", "api.httpPort"} {
+		if err == nil || !strings.Contains(err.Error(), key) {
+			t.Errorf("error %v doesn't name %s", err, key)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package consensus
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Names of the consensus algorithms New can create
+const (
+	PoW = "pow"
+	PoS = "pos"
+)
+
+// ErrUnknownAlgorithm is returned by New for a name it doesn't know
+var ErrUnknownAlgorithm = errors.New("unknown consensus algorithm")
+
+// New creates the consensus algorithm with the given name and difficulty
+func New(name string, difficulty int) (Algorithm, error) {
+	switch name {
+	case PoW:
+		return NewProofOfWork(difficulty), nil
+	case PoS:
+		return NewProofOfStake(difficulty), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, name)
+	}
+}
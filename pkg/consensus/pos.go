@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/wallet"
 )
 
 // ProofOfStake implements a basic Proof of Stake consensus algorithm
@@ -54,17 +55,22 @@ func (pos *ProofOfStake) SelectValidator() string {
 	return ""
 }
 
-// ValidateBlock checks if a block is valid according to PoS rules
-// In a real implementation, this would verify the validator's signature
+// ValidateBlock checks if a block is valid according to PoS rules: it must
+// be signed by the validator it names, and once stakers are added the
+// validator's address must hold stake. Genesis blocks are exempt. Whether
+// the validator was selected for the block's slot isn't checked.
 func (pos *ProofOfStake) ValidateBlock(block blockchain.Block) bool {
-	// In a real implementation, we would verify:
-	// 1. The block is signed by a valid validator
-	// 2. The validator was selected for this time slot
-	// 3. The validator has sufficient stake
-
-	// For this simple implementation, we'll just return true
-	// as if the block was properly validated
-	return true
+	if block.Index == 0 {
+		return true
+	}
+	key, err := blockchain.VerifyBlockSignature(block)
+	if err != nil {
+		return false
+	}
+	if len(pos.Stakers) == 0 {
+		return true
+	}
+	return pos.Stakers[wallet.AddressFromPublicKey(key)] > 0
 }
 
 // SetDifficulty changes the consensus parameter (not directly used in PoS)
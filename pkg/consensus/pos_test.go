@@ -0,0 +1,86 @@
+package consensus
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/wallet"
+)
+
+// mineSigned mines a block on the genesis block, signed with key unless it
+// is nil
+func mineSigned(t *testing.T, key ed25519.PrivateKey) blockchain.Block {
+	t.Helper()
+	block, _, err := blockchain.GenerateBlock(blockchain.CreateGenesisBlock(), nil, 0, blockchain.WithSigner(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+func TestProofOfStakeRejectsUnsignedBlock(t *testing.T) {
+	pos := NewProofOfStake(0)
+	if pos.ValidateBlock(mineSigned(t, nil)) {
+		t.Fatal("unsigned block accepted")
+	}
+}
+
+func TestProofOfStakeAcceptsSignedBlock(t *testing.T) {
+	validator, err := wallet.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos := NewProofOfStake(0)
+	if !pos.ValidateBlock(mineSigned(t, validator.PrivateKey)) {
+		t.Fatal("signed block rejected")
+	}
+}
+
+func TestProofOfStakeRejectsForgedSignature(t *testing.T) {
+	validator, err := wallet.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := mineSigned(t, validator.PrivateKey)
+	other, err := wallet.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Claim the block for another validator, keeping the signature
+	block.Validator = hex.EncodeToString(other.PublicKey)
+
+	if NewProofOfStake(0).ValidateBlock(block) {
+		t.Fatal("block claimed by another validator accepted")
+	}
+}
+
+func TestProofOfStakeRequiresStakeOnceStakersAreAdded(t *testing.T) {
+	staker, err := wallet.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	outsider, err := wallet.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos := NewProofOfStake(0)
+	pos.AddStaker(staker.Address(), 10)
+
+	if !pos.ValidateBlock(mineSigned(t, staker.PrivateKey)) {
+		t.Fatal("staker's block rejected")
+	}
+	if pos.ValidateBlock(mineSigned(t, outsider.PrivateKey)) {
+		t.Fatal("block of a validator without stake accepted")
+	}
+}
+
+func TestProofOfWorkBoundsDifficulty(t *testing.T) {
+	block := mineSigned(t, nil)
+	for _, difficulty := range []int{-1, 1 << 40} {
+		if NewProofOfWork(difficulty).ValidateBlock(block) {
+			t.Errorf("difficulty %d met", difficulty)
+		}
+	}
+}
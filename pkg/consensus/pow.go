@@ -1,8 +1,6 @@
 package consensus
 
 import (
-	"strings"
-
 	"github.com/anekazek/simple-blockchain/pkg/blockchain"
 )
 
@@ -20,8 +18,7 @@ func NewProofOfWork(difficulty int) *ProofOfWork {
 
 // ValidateBlock checks if a block's hash meets the difficulty requirement
 func (pow *ProofOfWork) ValidateBlock(block blockchain.Block) bool {
-	prefix := strings.Repeat("0", pow.Difficulty)
-	return strings.HasPrefix(block.Hash, prefix)
+	return blockchain.IsHashValid(block.Hash, pow.Difficulty)
 }
 
 // SetDifficulty changes the mining difficulty
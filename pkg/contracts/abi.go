@@ -0,0 +1,214 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// Names of the allocator functions a contract exports to exchange strings
+// and bytes with the engine
+const (
+	abiAlloc = "alloc" // alloc(size i32) -> ptr i32
+	abiFree  = "free"  // free(ptr i32, size i32), optional
+)
+
+// Result encodings a caller may request for a WASM function's return value
+const (
+	// ReturnRaw returns the value as the Go type matching its WASM type
+	ReturnRaw = ""
+	// ReturnString reads a packed (ptr << 32 | len) i64 result out of memory
+	// as a string
+	ReturnString = "string"
+	// ReturnBytes reads a packed (ptr << 32 | len) i64 result out of memory
+	// as bytes
+	ReturnBytes = "bytes"
+)
+
+// Errors returned when exchanging strings and bytes with a contract
+var (
+	ErrParamTooLarge = errors.New("parameter exceeds the size limit")
+	errNoAllocator   = errors.New("contract does not export alloc")
+	errBadAllocation = errors.New("alloc returned memory out of bounds")
+	errBadResult     = errors.New("result refers to memory out of bounds")
+)
+
+// encodeParams converts Go parameters to WASM values of the function's
+// parameter types. Strings and byte slices are copied into memory obtained
+// from the contract's alloc and passed as (ptr, len) pairs. memory is nil
+// when the module has none.
+func encodeParams(ctx context.Context, mod api.Module, memory api.Memory, types []api.ValueType, params []interface{}, maxBytes int) ([]uint64, error) {
+	wasmParams := make([]uint64, 0, len(types))
+	for _, param := range params {
+		switch v := param.(type) {
+		case string:
+			ptr, err := writeBytes(ctx, mod, memory, []byte(v), maxBytes)
+			if err != nil {
+				return nil, err
+			}
+			wasmParams = append(wasmParams, uint64(ptr), uint64(len(v)))
+		case []byte:
+			ptr, err := writeBytes(ctx, mod, memory, v, maxBytes)
+			if err != nil {
+				return nil, err
+			}
+			wasmParams = append(wasmParams, uint64(ptr), uint64(len(v)))
+		default:
+			if len(wasmParams) >= len(types) {
+				return nil, fmt.Errorf("%w: too many parameters, function takes %d values", ErrInvalidParams, len(types))
+			}
+			value, err := encodeNumber(param, types[len(wasmParams)])
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidParams, err)
+			}
+			wasmParams = append(wasmParams, value)
+		}
+	}
+	if len(wasmParams) != len(types) {
+		return nil, fmt.Errorf("%w: function takes %d values, got %d", ErrInvalidParams, len(types), len(wasmParams))
+	}
+	return wasmParams, nil
+}
+
+// encodeNumber converts a numeric parameter to a value of the given type.
+// Floats are passed by their IEEE-754 bits; a float passed to an integer
+// parameter must be integral.
+func encodeNumber(param interface{}, valueType api.ValueType) (uint64, error) {
+	var i int64
+	var f float64
+	isFloat := false
+	switch v := param.(type) {
+	case int:
+		i = int64(v)
+	case int32:
+		i = int64(v)
+	case int64:
+		i = v
+	case uint:
+		i = int64(v)
+	case uint32:
+		i = int64(v)
+	case uint64:
+		i = int64(v)
+	case float32:
+		f, isFloat = float64(v), true
+	case float64:
+		f, isFloat = v, true
+	default:
+		return 0, fmt.Errorf("unsupported parameter type: %T", param)
+	}
+
+	switch valueType {
+	case api.ValueTypeF32, api.ValueTypeF64:
+		if !isFloat {
+			f = float64(i)
+		}
+		if valueType == api.ValueTypeF32 {
+			return api.EncodeF32(float32(f)), nil
+		}
+		return api.EncodeF64(f), nil
+	case api.ValueTypeI32, api.ValueTypeI64:
+		if isFloat {
+			if f != math.Trunc(f) {
+				return 0, fmt.Errorf("parameter %v is not an integer", f)
+			}
+			i = int64(f)
+		}
+		if valueType == api.ValueTypeI32 {
+			return api.EncodeI32(int32(i)), nil
+		}
+		return api.EncodeI64(i), nil
+	default:
+		return 0, fmt.Errorf("unsupported parameter type: %s", api.ValueTypeName(valueType))
+	}
+}
+
+// decodeValue converts a WASM value to the Go type matching its type
+func decodeValue(value uint64, valueType api.ValueType) interface{} {
+	switch valueType {
+	case api.ValueTypeI32:
+		return api.DecodeI32(value)
+	case api.ValueTypeI64:
+		return int64(value)
+	case api.ValueTypeF32:
+		return api.DecodeF32(value)
+	case api.ValueTypeF64:
+		return api.DecodeF64(value)
+	default:
+		return value
+	}
+}
+
+// writeBytes copies data into a buffer allocated by the contract and returns
+// the buffer's address
+func writeBytes(ctx context.Context, mod api.Module, memory api.Memory, data []byte, maxBytes int) (uint32, error) {
+	if maxBytes > 0 && len(data) > maxBytes {
+		return 0, fmt.Errorf("%w: %d bytes, limit is %d", ErrParamTooLarge, len(data), maxBytes)
+	}
+
+	alloc := mod.ExportedFunction(abiAlloc)
+	if alloc == nil {
+		return 0, errNoAllocator
+	}
+	results, err := alloc.Call(ctx, uint64(len(data)))
+	if err != nil {
+		return 0, fmt.Errorf("alloc failed: %w", err)
+	}
+	if len(results) == 0 {
+		return 0, errNoAllocator
+	}
+
+	ptr := uint32(results[0])
+	if memory == nil || !memory.Write(ptr, data) {
+		return 0, errBadAllocation
+	}
+	return ptr, nil
+}
+
+// decodeResult converts a function's results according to the requested
+// encoding. ReturnRaw decodes each result by its declared type, returning a
+// single value, or a []interface{} for functions with several results.
+// Strings and bytes are read out of memory from the first result and
+// released with the contract's free when it exports one.
+func decodeResult(ctx context.Context, mod api.Module, memory api.Memory, types []api.ValueType, results []uint64, encoding string) (interface{}, error) {
+	if len(results) == 0 {
+		return nil, nil
+	}
+	if encoding == ReturnRaw {
+		if len(results) == 1 {
+			return decodeValue(results[0], types[0]), nil
+		}
+		values := make([]interface{}, len(results))
+		for i, result := range results {
+			values[i] = decodeValue(result, types[i])
+		}
+		return values, nil
+	}
+	if encoding != ReturnString && encoding != ReturnBytes {
+		return nil, fmt.Errorf("unsupported return encoding: %s", encoding)
+	}
+
+	ptr, length := uint32(results[0]>>32), uint32(results[0])
+	if memory == nil {
+		return nil, errBadResult
+	}
+	data, ok := memory.Read(ptr, length)
+	if !ok {
+		return nil, fmt.Errorf("%w: %d bytes at %d", errBadResult, length, ptr)
+	}
+	data = append([]byte(nil), data...)
+
+	if free := mod.ExportedFunction(abiFree); free != nil {
+		if _, err := free.Call(ctx, uint64(ptr), uint64(length)); err != nil {
+			return nil, fmt.Errorf("free failed: %w", err)
+		}
+	}
+
+	if encoding == ReturnString {
+		return string(data), nil
+	}
+	return data, nil
+}
@@ -0,0 +1,202 @@
+package contracts
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// arithmeticModule exports addf (f64, f64) -> f64, addf32 (f32, f32) -> f32,
+// add (i32, i32) -> i32 and add64 (i64, i64) -> i64
+func arithmeticModule() []byte {
+	return wasmBinary(
+		wasmSection{wasmSectionType, wasmVec(
+			[]byte{0x60, 0x02, 0x7c, 0x7c, 0x01, 0x7c},
+			[]byte{0x60, 0x02, 0x7d, 0x7d, 0x01, 0x7d},
+			[]byte{0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f},
+			[]byte{0x60, 0x02, 0x7e, 0x7e, 0x01, 0x7e},
+		)},
+		wasmSection{wasmSectionFunction, wasmVec([]byte{0}, []byte{1}, []byte{2}, []byte{3})},
+		wasmSection{7, wasmVec(wasmExport("addf", 0), wasmExport("addf32", 1), wasmExport("add", 2), wasmExport("add64", 3))},
+		wasmSection{wasmSectionCode, wasmVec(
+			wasmBody(0x20, 0x00, 0x20, 0x01, 0xa0, 0x0b),
+			wasmBody(0x20, 0x00, 0x20, 0x01, 0x92, 0x0b),
+			addBody,
+			wasmBody(0x20, 0x00, 0x20, 0x01, 0x7c, 0x0b),
+		)},
+	)
+}
+
+func TestWASMMarshalsNumbersByDeclaredType(t *testing.T) {
+	engine := NewWASMEngine()
+	if err := engine.DeployContractFromBytes("math", "math", arithmeticModule()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		function string
+		params   []interface{}
+		want     interface{}
+	}{
+		{"addf", []interface{}{1.5, 2.25}, 3.75},
+		{"addf", []interface{}{3.7, 0}, 3.7},
+		{"addf", []interface{}{-0.5, math.Inf(1)}, math.Inf(1)},
+		{"addf32", []interface{}{float32(1.5), 2.25}, float32(3.75)},
+		{"add", []interface{}{2, 3}, int32(5)},
+		{"add", []interface{}{-7, 2.0}, int32(-5)},
+		{"add64", []interface{}{int64(1) << 40, 1}, int64(1)<<40 + 1},
+	}
+	for _, tt := range tests {
+		got, err := engine.ExecuteContract("math", tt.function, tt.params...)
+		if err != nil {
+			t.Errorf("%s%v: %v", tt.function, tt.params, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s%v = %v (%T), want %v (%T)", tt.function, tt.params, got, got, tt.want, tt.want)
+		}
+	}
+}
+
+func TestWASMRejectsFractionForIntegerParameter(t *testing.T) {
+	engine := NewWASMEngine()
+	if err := engine.DeployContractFromBytes("math", "math", arithmeticModule()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.ExecuteContract("math", "add", 3.7, 1); !errors.Is(err, ErrInvalidParams) {
+		t.Fatalf("got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestEncodeNumberPassesFloatBits(t *testing.T) {
+	value, err := encodeNumber(3.7, api.ValueTypeF64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != math.Float64bits(3.7) {
+		t.Fatalf("encoded 3.7 as %#x, want its IEEE-754 bits", value)
+	}
+	value, err = encodeNumber(3.7, api.ValueTypeF32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != uint64(math.Float32bits(3.7)) {
+		t.Fatalf("encoded 3.7 as f32 %#x, want its IEEE-754 bits", value)
+	}
+}
+
+// greetModule exports a bump allocator alloc, a no-op free and
+// greet(name) -> string, which returns "hello, " followed by name
+func greetModule() []byte {
+	return wasmBinary(
+		wasmSection{wasmSectionType, wasmVec(
+			[]byte{0x60, 0x01, 0x7f, 0x01, 0x7f},
+			[]byte{0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e},
+			[]byte{0x60, 0x02, 0x7f, 0x7f, 0x00},
+		)},
+		wasmSection{wasmSectionFunction, wasmVec([]byte{0}, []byte{1}, []byte{2})},
+		wasmSection{wasmSectionMemory, wasmVec([]byte{0x00, 0x01})},
+		// The allocator's next free address, starting at 1024
+		wasmSection{6, wasmVec([]byte{0x7f, 0x01, 0x41, 0x80, 0x08, 0x0b})},
+		wasmSection{7, wasmVec(wasmExport("alloc", 0), wasmExport("greet", 1), wasmExport("free", 2))},
+		wasmSection{wasmSectionCode, wasmVec(
+			wasmBody(0x23, 0x00, 0x23, 0x00, 0x20, 0x00, 0x6a, 0x24, 0x00, 0x0b),
+			// Copy name after the greeting at 0 and return (0 << 32 | len+7)
+			wasmBody(0x41, 0x07, 0x20, 0x00, 0x20, 0x01, 0xfc, 0x0a, 0x00, 0x00, 0x20, 0x01, 0x41, 0x07, 0x6a, 0xad, 0x0b),
+			wasmBody(0x0b),
+		)},
+		wasmSection{11, wasmVec(wasmData(0, "hello, "))},
+	)
+}
+
+func TestWASMPassesAndReturnsStrings(t *testing.T) {
+	config := DefaultWASMConfig()
+	config.MaxParamBytes = 64
+	engine := NewWASMEngineWithConfig(config)
+	if err := engine.DeployContractFromBytes("greeter", "greeter", greetModule()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"bob", "Zoë", "世界", ""} {
+		result, err := engine.ExecuteContractWithContext(ExecutionContext{Returns: ReturnString}, "greeter", "greet", name)
+		if err != nil {
+			t.Fatalf("greet(%q): %v", name, err)
+		}
+		if want := "hello, " + name; result.Value != want {
+			t.Errorf("greet(%q) = %q, want %q", name, result.Value, want)
+		}
+	}
+
+	result, err := engine.ExecuteContractWithContext(ExecutionContext{Returns: ReturnBytes}, "greeter", "greet", []byte{0xff, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := result.Value.([]byte); !ok || string(got) != "hello, \xff\x00" {
+		t.Fatalf("greet(bytes) = %#v", result.Value)
+	}
+
+	_, err = engine.ExecuteContractWithContext(ExecutionContext{Returns: ReturnString}, "greeter", "greet", strings.Repeat("x", 65))
+	if !errors.Is(err, ErrParamTooLarge) {
+		t.Fatalf("oversized name: got %v, want ErrParamTooLarge", err)
+	}
+}
+
+func TestWASMStringParameterNeedsAllocator(t *testing.T) {
+	engine := NewWASMEngine()
+	if err := engine.DeployContractFromBytes("math", "math", arithmeticModule()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.ExecuteContract("math", "add", "two"); !errors.Is(err, errNoAllocator) {
+		t.Fatalf("got %v, want errNoAllocator", err)
+	}
+}
+
+// resultsModule exports divmod (i32, i32) -> (i32, i32), pair () -> (i64,
+// f64) and noop () -> ()
+func resultsModule() []byte {
+	return wasmBinary(
+		wasmSection{wasmSectionType, wasmVec(
+			[]byte{0x60, 0x02, 0x7f, 0x7f, 0x02, 0x7f, 0x7f},
+			[]byte{0x60, 0x00, 0x02, 0x7e, 0x7c},
+			[]byte{0x60, 0x00, 0x00},
+		)},
+		wasmSection{wasmSectionFunction, wasmVec([]byte{0}, []byte{1}, []byte{2})},
+		wasmSection{7, wasmVec(wasmExport("divmod", 0), wasmExport("pair", 1), wasmExport("noop", 2))},
+		wasmSection{wasmSectionCode, wasmVec(
+			wasmBody(0x20, 0x00, 0x20, 0x01, 0x6d, 0x20, 0x00, 0x20, 0x01, 0x6f, 0x0b),
+			wasmBody(0x42, 0x07, 0x44, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xe0, 0x3f, 0x0b),
+			wasmBody(0x0b),
+		)},
+	)
+}
+
+func TestWASMReturnsEveryResultByType(t *testing.T) {
+	engine := NewWASMEngine()
+	if err := engine.DeployContractFromBytes("results", "results", resultsModule()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		function string
+		params   []interface{}
+		want     interface{}
+	}{
+		{"divmod", []interface{}{17, 5}, []interface{}{int32(3), int32(2)}},
+		{"pair", nil, []interface{}{int64(7), 0.5}},
+		{"noop", nil, nil},
+	}
+	for _, tt := range tests {
+		got, err := engine.ExecuteContract("results", tt.function, tt.params...)
+		if err != nil {
+			t.Errorf("%s%v: %v", tt.function, tt.params, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s%v = %#v, want %#v", tt.function, tt.params, got, tt.want)
+		}
+	}
+}
@@ -1,25 +1,97 @@
 package contracts
 
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Contract types, naming the engine that runs a contract
+const (
+	TypeWASM = "wasm"
+	TypeLua  = "lua"
+)
+
+// ErrContractNotFound is returned for contract IDs no engine holds
+var ErrContractNotFound = errors.New("contract not found")
+
 // ContractEngine defines the interface for smart contract execution engines
 type ContractEngine interface {
-	// DeployContract deploys a new contract
-	// For WASM engine, code is a file path
-	// For Lua engine, code is the actual Lua code
-	DeployContract(id string, name string, code string) error
+	// Type names the contracts the engine runs, such as TypeWASM
+	Type() string
+
+	// Deploy compiles and registers a contract owned by owner, who may be
+	// empty. code is the engine's source or binary format.
+	Deploy(id, name, owner string, code []byte) error
+
+	// ExecuteContractCtx runs a function in the given chain context, stopping
+	// when ctx is cancelled or its deadline passes
+	ExecuteContractCtx(ctx context.Context, execCtx ExecutionContext, contractID string, functionName string, params ...interface{}) (*ExecutionResult, error)
+
+	// DryRun runs a function like ExecuteContractCtx but never commits its
+	// state writes, reporting them as the result's StateDiff instead
+	DryRun(ctx context.Context, execCtx ExecutionContext, contractID string, functionName string, params ...interface{}) (*ExecutionResult, error)
+
+	// ContractInfo describes a contract, including one that failed to reload
+	ContractInfo(id string) (ContractInfo, error)
+
+	// ListContractInfo describes every contract, including those that
+	// failed to reload
+	ListContractInfo() []ContractInfo
 
-	// ExecuteContract runs a function in a contract with the given parameters
-	ExecuteContract(contractID string, functionName string, params ...interface{}) (interface{}, error)
+	// ABI describes the functions a contract can execute
+	ABI(id string) (*ContractABI, error)
 
-	// GetContract retrieves contract information by ID
-	GetContract(id string) (interface{}, error)
+	// RemoveContractAs deletes a contract on behalf of its owner or an admin
+	RemoveContractAs(manager Manager, id string) error
 
-	// RemoveContract deletes a contract
-	RemoveContract(id string) error
+	// TransferOwnership hands a contract to a new owner on behalf of its
+	// current owner or an admin
+	TransferOwnership(manager Manager, id, newOwner string) error
+
+	// SetPause suspends a contract's executions with pause, or resumes them
+	// when pause is nil, on behalf of its owner or an admin
+	SetPause(manager Manager, id string, pause *Pause) error
+
+	// LoadFrom reloads the engine's contracts from store and persists later
+	// changes to it
+	LoadFrom(store ContractStore) error
 }
 
 // ContractInfo contains common contract metadata
 type ContractInfo struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-	Type string `json:"type"` // "wasm" or "lua"
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"` // "wasm" or "lua"
+	Owner     string    `json:"owner"`
+	Status    string    `json:"status"`          // StatusActive, StatusPaused or StatusFailed
+	Pause     *Pause    `json:"pause,omitempty"` // Why and when a paused contract was paused
+	Error     string    `json:"error,omitempty"` // Why a failed contract didn't reload
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ExecutionResult is the outcome of a contract execution
+type ExecutionResult struct {
+	Value   interface{}     `json:"result"`
+	GasUsed uint64          `json:"gasUsed,omitempty"`
+	Events  []ContractEvent `json:"events,omitempty"`
+
+	// StateDiff lists the storage a dry run would have changed
+	StateDiff []StateChange `json:"stateDiff,omitempty"`
+}
+
+// ErrExecutionCancelled is returned when the caller abandons an execution,
+// such as when an HTTP client disconnects
+var ErrExecutionCancelled = errors.New("execution cancelled")
+
+// contextError reports why ctx stopped an execution, or nil if it hasn't
+func contextError(ctx context.Context) error {
+	switch ctx.Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return ErrExecutionTimeout
+	default:
+		return ErrExecutionCancelled
+	}
 }
@@ -0,0 +1,244 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DefaultMaxCallDepth bounds how many nested contract calls an execution may make
+const DefaultMaxCallDepth = 8
+
+// Errors returned by inter-contract calls
+var (
+	ErrCallDepthExceeded = errors.New("contract call depth limit exceeded")
+	errNoDispatcher      = errors.New("inter-contract calls are not enabled")
+)
+
+// Dispatcher routes calls from one contract to another, across engines.
+// Every call in a tree shares the root execution's gas and time budget, and
+// their state writes are committed together when the root succeeds.
+type Dispatcher struct {
+	wasm     *WASMEngine
+	lua      *LuaEngine
+	maxDepth int
+}
+
+// NewDispatcher connects the engines so their contracts can call each other
+func NewDispatcher(wasm *WASMEngine, lua *LuaEngine) *Dispatcher {
+	d := &Dispatcher{
+		wasm:     wasm,
+		lua:      lua,
+		maxDepth: DefaultMaxCallDepth,
+	}
+	wasm.dispatcher.Store(d)
+	lua.dispatcher.Store(d)
+	return d
+}
+
+// SetMaxDepth sets how many nested calls an execution may make. It must be
+// called before executions start.
+func (d *Dispatcher) SetMaxDepth(depth int) {
+	d.maxDepth = depth
+}
+
+// call runs a function of another contract on behalf of the executing
+// caller. The callee sees the calling contract as its caller, receives no
+// value, and keeps the tree's origin. A failed call fails the whole tree, so none of its state
+// writes are committed even if the caller recovers from the error.
+func (d *Dispatcher) call(ctx context.Context, caller *execution, contractID, functionName string, params []interface{}) (interface{}, error) {
+	value, err := d.dispatch(ctx, caller, contractID, functionName, params)
+	if err != nil {
+		caller.tree.fail(err)
+	}
+	return value, err
+}
+
+// dispatch routes a call to the engine holding the contract
+func (d *Dispatcher) dispatch(ctx context.Context, caller *execution, contractID, functionName string, params []interface{}) (interface{}, error) {
+	depth := caller.depth + 1
+	if depth > d.maxDepth {
+		return nil, fmt.Errorf("%w: %s calling %s.%s at depth %d, limit is %d",
+			ErrCallDepthExceeded, caller.contractID, contractID, functionName, depth, d.maxDepth)
+	}
+
+	execCtx := caller.context
+	execCtx.Caller = caller.contractID
+	execCtx.Value = 0
+	execCtx.Returns = ReturnRaw
+
+	value, err := d.wasm.execute(ctx, caller.tree, depth, execCtx, contractID, functionName, params)
+	if errors.Is(err, ErrContractNotFound) {
+		value, err = d.lua.execute(ctx, caller.tree, depth, execCtx, contractID, functionName, params)
+	}
+	if errors.Is(err, ErrContractNotFound) {
+		return nil, fmt.Errorf("%w: %s", err, contractID)
+	}
+	return value, err
+}
+
+// callTree is shared by a root execution and every contract it calls
+type callTree struct {
+	dispatcher *Dispatcher        // nil when the engine isn't connected to one
+	cancel     context.CancelFunc // stops every execution in the tree
+	meter      *gasMeter          // created by the first execution
+	events     *eventLog
+	states     map[string]*stateTx
+	locked     map[*sync.RWMutex]bool
+	failure    error // the first failure that fails the root
+	mutex      sync.Mutex
+}
+
+// newCallTree starts the call tree of a root execution
+func newCallTree(dispatcher *Dispatcher, cancel context.CancelFunc) *callTree {
+	return &callTree{
+		dispatcher: dispatcher,
+		cancel:     cancel,
+		events:     newEventLog(),
+		states:     make(map[string]*stateTx),
+		locked:     make(map[*sync.RWMutex]bool),
+	}
+}
+
+// state returns the transaction buffering a contract's writes in this tree
+func (t *callTree) state(store StateStore, contractID string) *stateTx {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	tx, ok := t.states[contractID]
+	if !ok {
+		tx = newStateTx(store, contractID)
+		t.states[contractID] = tx
+	}
+	return tx
+}
+
+// gasMeter returns the tree's gas meter, creating it with the given budget
+// on first use. Exhausting it stops the whole tree.
+func (t *callTree) gasMeter(limit uint64) *gasMeter {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.meter == nil {
+		t.meter = &gasMeter{limit: limit, cancel: t.cancel}
+	}
+	return t.meter
+}
+
+// gasUsed returns the gas the tree's executions consumed
+func (t *callTree) gasUsed() uint64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.meter == nil {
+		return 0
+	}
+	return t.meter.gasUsed()
+}
+
+// rlock read-locks an engine for the tree, returning the unlock function.
+// Nested calls back into an engine the tree already holds don't lock again,
+// which could deadlock behind a waiting writer.
+func (t *callTree) rlock(mutex *sync.RWMutex) func() {
+	t.mutex.Lock()
+	held := t.locked[mutex]
+	t.locked[mutex] = true
+	t.mutex.Unlock()
+	if held {
+		return func() {}
+	}
+
+	mutex.RLock()
+	return func() {
+		t.mutex.Lock()
+		delete(t.locked, mutex)
+		t.mutex.Unlock()
+		mutex.RUnlock()
+	}
+}
+
+// fail records a failed nested call, or another failure that must fail the
+// root execution even if the contract recovers from it
+func (t *callTree) fail(err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.failure == nil {
+		t.failure = err
+	}
+}
+
+// failed returns the first failure recorded by fail, which fails the root
+// execution even when the contract recovered from it
+func (t *callTree) failed() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.failure
+}
+
+// executionError reports why an execution in the tree stopped: gas
+// exhaustion, then the first failed nested call, then the deadline or
+// cancellation of ctx, and otherwise err itself
+func (t *callTree) executionError(ctx context.Context, err error) error {
+	t.mutex.Lock()
+	meter, failure := t.meter, t.failure
+	t.mutex.Unlock()
+
+	if meter != nil && meter.exhausted.Load() {
+		return fmt.Errorf("%w: limit %d", ErrOutOfGas, meter.limit)
+	}
+	if failure != nil {
+		return failure
+	}
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// diff returns the state changes of every contract in the tree, by
+// contract and key
+func (t *callTree) diff() []StateChange {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	ids := make([]string, 0, len(t.states))
+	for id := range t.states {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var changes []StateChange
+	for _, id := range ids {
+		changes = append(changes, t.states[id].diff()...)
+	}
+	return changes
+}
+
+// commitMutex makes checking and applying a tree's writes one step, so no
+// other tree commits in between
+var commitMutex sync.Mutex
+
+// commit applies the state writes of every contract in the tree, unless
+// another execution changed a value the tree read, in which case nothing
+// is applied and an error wrapping ErrStateConflict is returned. Only the
+// root contract's calls are queued, so this is what keeps concurrent trees
+// calling the same contract from losing each other's writes.
+func (t *callTree) commit() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	commitMutex.Lock()
+	defer commitMutex.Unlock()
+
+	for id, tx := range t.states {
+		if tx.conflicts() {
+			return fmt.Errorf("%w: %s", ErrStateConflict, id)
+		}
+	}
+	for _, tx := range t.states {
+		tx.commit()
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package contracts
+
+import (
+	"errors"
+	"testing"
+)
+
+const callerContract = `
+function run(v)
+	storage_set("a", v)
+	return "a got " .. call("callee", "put", v)
+end
+
+function run_failing(v)
+	storage_set("a", v)
+	pcall(call, "callee", "boom", v)
+	return "recovered"
+end
+
+function whoami()
+	return call("callee", "who")
+end
+
+function loop()
+	return call("callee", "back")
+end
+`
+
+const calleeContract = `
+function put(v)
+	storage_set("b", v)
+	return "stored " .. v
+end
+
+function boom(v)
+	storage_set("b", v)
+	error("boom")
+end
+
+function who()
+	return msg.sender .. "|" .. msg.origin
+end
+
+function back()
+	return call("caller", "loop")
+end
+`
+
+// newDispatchedEngines deploys callerContract and calleeContract on a Lua
+// engine connected to a dispatcher, returning the engine and its state
+func newDispatchedEngines(t *testing.T) (*LuaEngine, StateStore) {
+	t.Helper()
+	engine := NewLuaEngine()
+	state := NewMemoryStateStore()
+	engine.SetStateStore(state)
+	NewDispatcher(NewWASMEngine(), engine)
+	if err := engine.DeployContract("caller", "caller", callerContract); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.DeployContract("callee", "callee", calleeContract); err != nil {
+		t.Fatal(err)
+	}
+	return engine, state
+}
+
+// stored returns the value a contract stored under key, or "" if none
+func stored(state StateStore, contractID, key string) string {
+	value, _ := state.Get(contractID, key)
+	return string(value)
+}
+
+func TestContractCallReturnsValueAndCommitsBothWrites(t *testing.T) {
+	engine, state := newDispatchedEngines(t)
+
+	result, err := engine.ExecuteContractWithContext(ExecutionContext{Caller: "alice"}, "caller", "run", "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Value != "a got stored x" {
+		t.Fatalf("got %v", result.Value)
+	}
+	if stored(state, "caller", "a") != "x" || stored(state, "callee", "b") != "x" {
+		t.Fatalf("writes not committed: a=%q b=%q", stored(state, "caller", "a"), stored(state, "callee", "b"))
+	}
+
+	alone, err := engine.ExecuteContractWithContext(ExecutionContext{Caller: "alice"}, "callee", "put", "y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.GasUsed <= alone.GasUsed {
+		t.Errorf("the call tree used %d gas, no more than the callee alone (%d)", result.GasUsed, alone.GasUsed)
+	}
+}
+
+func TestFailedContractCallRollsBackTheTree(t *testing.T) {
+	engine, state := newDispatchedEngines(t)
+
+	if _, err := engine.ExecuteContractWithContext(ExecutionContext{Caller: "alice"}, "caller", "run_failing", "x"); err == nil {
+		t.Fatal("the caller recovering from a failed call hid the failure")
+	}
+	if a, b := stored(state, "caller", "a"), stored(state, "callee", "b"); a != "" || b != "" {
+		t.Fatalf("writes committed after a failed call: a=%q b=%q", a, b)
+	}
+}
+
+func TestContractCallSeesCallerAndOrigin(t *testing.T) {
+	engine, _ := newDispatchedEngines(t)
+
+	result, err := engine.ExecuteContractWithContext(ExecutionContext{Caller: "alice"}, "caller", "whoami")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Value != "caller|alice" {
+		t.Fatalf("callee saw sender|origin %v, want caller|alice", result.Value)
+	}
+}
+
+func TestRecursiveContractCallsHitDepthLimit(t *testing.T) {
+	engine, _ := newDispatchedEngines(t)
+
+	_, err := engine.ExecuteContractWithContext(ExecutionContext{Caller: "alice"}, "caller", "loop")
+	if !errors.Is(err, ErrCallDepthExceeded) {
+		t.Fatalf("got %v, want ErrCallDepthExceeded", err)
+	}
+}
+
+func TestContractCallsNeedDispatcher(t *testing.T) {
+	engine := NewLuaEngine()
+	if err := engine.DeployContract("caller", "caller", callerContract); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.ExecuteContract("caller", "run", "x"); err == nil {
+		t.Fatal("call succeeded without a dispatcher")
+	}
+}
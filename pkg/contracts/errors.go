@@ -0,0 +1,166 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Errors returned when a call doesn't match the contract
+var (
+	ErrFunctionNotFound = errors.New("function not found")
+	ErrInvalidParams    = errors.New("invalid parameters")
+)
+
+// Codes classifying why an execution failed
+const (
+	CodeNotFound          = "not_found"           // No such contract or function
+	CodeBadParams         = "bad_params"          // Parameters don't fit the function
+	CodeParamTooLarge     = "param_too_large"     // A string or bytes parameter is over the limit
+	CodeReverted          = "reverted"            // The contract raised an error or trapped
+	CodeGasExhausted      = "gas_exhausted"       // The gas limit ran out
+	CodeTimeout           = "timeout"             // The time limit passed
+	CodeCancelled         = "cancelled"           // The caller gave up
+	CodeMemoryLimit       = "memory_limit"        // WASM memory grew to the cap
+	CodeEventLimit        = "event_limit"         // Too many or too large events
+	CodeCallDepthExceeded = "call_depth_exceeded" // Calls between contracts nested too deep
+	CodeReadOnlyWrite     = "read_only_write"     // A read-only function wrote storage
+	CodePaused            = "paused"              // The contract is paused
+	CodeStateConflict     = "state_conflict"      // Another execution changed the state read
+	CodeEngineError       = "engine_error"        // Anything else
+)
+
+// wasmStackTrace separates a wazero error from the stack trace it appends
+const wasmStackTrace = "\nwasm stack trace:\n"
+
+// ExecutionError describes a failed execution. Errors returned by the
+// engines' executions are ExecutionErrors, which unwrap to the sentinel
+// errors of this package that caused them.
+type ExecutionError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Engine names the engine that raised the error, such as TypeLua
+	Engine string `json:"engine,omitempty"`
+	// Diagnostics holds the engine's view of where the error happened: a
+	// Lua traceback or a WASM stack trace
+	Diagnostics string `json:"diagnostics,omitempty"`
+
+	err error
+}
+
+// Error returns the message
+func (e *ExecutionError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the error that caused the failure
+func (e *ExecutionError) Unwrap() error {
+	return e.err
+}
+
+// revertError is an error raised by contract code. Its message leaves out
+// the engine's diagnostics, which are kept apart.
+type revertError struct {
+	engine      string
+	err         error
+	message     string
+	diagnostics string
+}
+
+// Error returns the error without diagnostics
+func (e *revertError) Error() string {
+	return e.message
+}
+
+// Unwrap returns the engine's error
+func (e *revertError) Unwrap() error {
+	return e.err
+}
+
+// luaRevert marks an error raised by running Lua code, keeping its
+// traceback as diagnostics
+func luaRevert(context string, err error) error {
+	revert := &revertError{engine: TypeLua, err: err, message: context + ": " + err.Error()}
+	var apiErr *lua.ApiError
+	if errors.As(err, &apiErr) && apiErr.StackTrace != "" {
+		revert.message = context + ": " + apiErr.Object.String()
+		revert.diagnostics = apiErr.StackTrace
+	}
+	return revert
+}
+
+// wasmRevert marks an error raised by running WASM code, keeping the
+// stack trace wazero appends as diagnostics
+func wasmRevert(context string, err error) error {
+	message, trace, _ := strings.Cut(err.Error(), wasmStackTrace)
+	return &revertError{
+		engine:      TypeWASM,
+		err:         err,
+		message:     context + ": " + message,
+		diagnostics: strings.TrimSpace(trace),
+	}
+}
+
+// AsExecutionError describes why an execution failed. Errors that aren't
+// already ExecutionErrors are classified by the errors they wrap.
+func AsExecutionError(err error) *ExecutionError {
+	return executionFailure("", err)
+}
+
+// executionFailure describes why an execution running on engine failed
+func executionFailure(engine string, err error) *ExecutionError {
+	var failure *ExecutionError
+	if errors.As(err, &failure) {
+		return failure
+	}
+
+	failure = &ExecutionError{Code: errorCode(err), Message: err.Error(), Engine: engine, err: err}
+	var revert *revertError
+	if errors.As(err, &revert) {
+		failure.Engine, failure.Diagnostics = revert.engine, revert.diagnostics
+	}
+	return failure
+}
+
+// errorCode classifies an execution error. Limits are checked before
+// reverts, since a contract may raise the error a limit caused.
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrOutOfGas):
+		return CodeGasExhausted
+	case errors.Is(err, ErrExecutionTimeout):
+		return CodeTimeout
+	case errors.Is(err, ErrExecutionCancelled):
+		return CodeCancelled
+	case errors.Is(err, ErrMemoryLimit):
+		return CodeMemoryLimit
+	case errors.Is(err, ErrTooManyEvents), errors.Is(err, ErrEventTooLarge):
+		return CodeEventLimit
+	case errors.Is(err, ErrCallDepthExceeded):
+		return CodeCallDepthExceeded
+	case errors.Is(err, ErrReadOnlyWrites):
+		return CodeReadOnlyWrite
+	case errors.Is(err, ErrContractPaused):
+		return CodePaused
+	case errors.Is(err, ErrStateConflict):
+		return CodeStateConflict
+	case errors.Is(err, ErrContractNotFound), errors.Is(err, ErrFunctionNotFound):
+		return CodeNotFound
+	case errors.Is(err, ErrParamTooLarge):
+		return CodeParamTooLarge
+	case errors.Is(err, ErrInvalidParams):
+		return CodeBadParams
+	}
+	var revert *revertError
+	if errors.As(err, &revert) {
+		return CodeReverted
+	}
+	return CodeEngineError
+}
+
+// notFound reports a function missing from a contract
+func notFound(contractID, functionName string) error {
+	return fmt.Errorf("%w: %s in %s", ErrFunctionNotFound, functionName, contractID)
+}
@@ -0,0 +1,111 @@
+package contracts
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// failingContract fails in every way a Lua contract can
+const failingContract = `
+function revert()
+	error("boom")
+end
+
+function spin()
+	while true do end
+end
+
+function add(a, b)
+	return a + b
+end
+`
+
+// trapModule exports trap, () -> (), which hits unreachable, and spin and
+// add from loopModule
+func trapModule() []byte {
+	return wasmBinary(
+		wasmSection{wasmSectionType, wasmVec([]byte{0x60, 0x00, 0x00}, []byte{0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f})},
+		wasmSection{wasmSectionFunction, wasmVec([]byte{0}, []byte{0}, []byte{1})},
+		wasmSection{7, wasmVec(wasmExport("trap", 0), wasmExport("spin", 1), wasmExport("add", 2))},
+		wasmSection{wasmSectionCode, wasmVec(wasmBody(0x00, 0x0b), spinBody, addBody)},
+	)
+}
+
+// failingCall is a call expected to fail with code
+type failingCall struct {
+	name     string
+	execCtx  ExecutionContext
+	timeout  time.Duration
+	function string
+	params   []interface{}
+	code     string
+}
+
+// checkFailures runs each call against engine's contract id and checks the
+// code of its error, and that reverts carry diagnostics
+func checkFailures(t *testing.T, engine ContractEngine, id string, calls []failingCall) {
+	t.Helper()
+	for _, call := range calls {
+		ctx, cancel := context.WithTimeout(context.Background(), call.timeout)
+		_, err := engine.ExecuteContractCtx(ctx, call.execCtx, id, call.function, call.params...)
+		cancel()
+		failure := AsExecutionError(err)
+		if err == nil || failure.Code != call.code {
+			t.Errorf("%s %s: got %v (%s), want %s", engine.Type(), call.name, err, failure.Code, call.code)
+			continue
+		}
+		if failure.Engine != engine.Type() && call.code != CodeNotFound {
+			t.Errorf("%s %s: raised by engine %q", engine.Type(), call.name, failure.Engine)
+		}
+		if call.code == CodeReverted && failure.Diagnostics == "" {
+			t.Errorf("%s %s: revert has no diagnostics", engine.Type(), call.name)
+		}
+	}
+}
+
+func TestLuaExecutionErrorCodes(t *testing.T) {
+	config := DefaultLuaConfig()
+	engine := NewLuaEngineWithConfig(config)
+	if err := engine.DeployContract("failing", "failing", failingContract); err != nil {
+		t.Fatal(err)
+	}
+	config.InstructionGas = 0
+	free := NewLuaEngineWithConfig(config)
+	if err := free.DeployContract("failing", "failing", failingContract); err != nil {
+		t.Fatal(err)
+	}
+
+	checkFailures(t, engine, "failing", []failingCall{
+		{name: "missing function", timeout: time.Minute, function: "missing", code: CodeNotFound},
+		{name: "unsupported param", timeout: time.Minute, function: "add", params: []interface{}{struct{}{}, 1}, code: CodeBadParams},
+		{name: "error", timeout: time.Minute, function: "revert", code: CodeReverted},
+		{name: "loop", execCtx: ExecutionContext{GasLimit: 1000}, timeout: time.Minute, function: "spin", code: CodeGasExhausted},
+	})
+	checkFailures(t, free, "failing", []failingCall{
+		{name: "deadline", timeout: 20 * time.Millisecond, function: "spin", code: CodeTimeout},
+	})
+}
+
+func TestWASMExecutionErrorCodes(t *testing.T) {
+	config := DefaultWASMConfig()
+	engine := NewWASMEngineWithConfig(config)
+	if err := engine.DeployContractFromBytes("failing", "failing", trapModule()); err != nil {
+		t.Fatal(err)
+	}
+	config.CallGas = 0
+	free := NewWASMEngineWithConfig(config)
+	if err := free.DeployContractFromBytes("failing", "failing", trapModule()); err != nil {
+		t.Fatal(err)
+	}
+
+	checkFailures(t, engine, "failing", []failingCall{
+		{name: "missing function", timeout: time.Minute, function: "missing", code: CodeNotFound},
+		{name: "missing param", timeout: time.Minute, function: "add", params: []interface{}{1}, code: CodeBadParams},
+		{name: "trap", timeout: time.Minute, function: "trap", code: CodeReverted},
+		{name: "loop", execCtx: ExecutionContext{GasLimit: 1000}, timeout: time.Minute, function: "spin", code: CodeGasExhausted},
+	})
+	checkFailures(t, free, "failing", []failingCall{
+		{name: "deadline", timeout: 20 * time.Millisecond, function: "spin", code: CodeTimeout},
+	})
+}
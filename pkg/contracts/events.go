@@ -0,0 +1,73 @@
+package contracts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Limits on the events one execution may emit
+const (
+	maxEventsPerExecution = 32
+	maxEventDataBytes     = 4096
+)
+
+// Errors returned when a contract emits too much
+var (
+	ErrTooManyEvents = errors.New("too many events emitted")
+	ErrEventTooLarge = errors.New("event payload too large")
+)
+
+// ContractEvent is an event emitted by a contract during an execution. Data
+// is the JSON payload: the table passed to a Lua contract's emit, or the
+// bytes passed to a WASM contract's emit_event, kept as-is when they are
+// valid JSON and base64-encoded otherwise.
+type ContractEvent struct {
+	ContractID string          `json:"contractId"`
+	Name       string          `json:"name,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+// eventLog collects the events of one execution, including those of the
+// contracts it calls
+type eventLog struct {
+	events []ContractEvent
+	mutex  sync.Mutex
+}
+
+// newEventLog creates an empty event log for an execution
+func newEventLog() *eventLog {
+	return &eventLog{}
+}
+
+// emit records an event of a contract, enforcing the per-execution limits
+func (l *eventLog) emit(contractID, name string, data json.RawMessage) error {
+	if len(name)+len(data) > maxEventDataBytes {
+		return fmt.Errorf("%w: %d bytes, limit is %d", ErrEventTooLarge, len(name)+len(data), maxEventDataBytes)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if len(l.events) >= maxEventsPerExecution {
+		return fmt.Errorf("%w: limit is %d", ErrTooManyEvents, maxEventsPerExecution)
+	}
+	l.events = append(l.events, ContractEvent{ContractID: contractID, Name: name, Data: data})
+	return nil
+}
+
+// list returns the recorded events
+func (l *eventLog) list() []ContractEvent {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return append([]ContractEvent(nil), l.events...)
+}
+
+// wasmEventData encodes the bytes a WASM contract emitted as JSON
+func wasmEventData(data []byte) json.RawMessage {
+	if json.Valid(data) {
+		return data
+	}
+	encoded, _ := json.Marshal(data)
+	return encoded
+}
@@ -0,0 +1,78 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// Errors returned when an execution exceeds its resource limits
+var (
+	ErrOutOfGas         = errors.New("gas exhausted")
+	ErrExecutionTimeout = errors.New("execution time limit exceeded")
+)
+
+// gasMeter tracks the gas of one execution and aborts it once the limit is
+// reached by cancelling the execution context
+type gasMeter struct {
+	limit     uint64
+	used      atomic.Uint64
+	exhausted atomic.Bool
+	cancel    context.CancelFunc
+}
+
+// callGas is what the gas listener charges a WASM execution per function
+// call and loop iteration
+type callGas struct {
+	meter  *gasMeter
+	amount uint64
+}
+
+// callGasKey is the context key under which a WASM execution's callGas is stored
+type callGasKey struct{}
+
+// charge consumes gas, aborting the execution when the limit is passed
+func (m *gasMeter) charge(amount uint64) {
+	if m.used.Add(amount) > m.limit && !m.exhausted.Swap(true) {
+		m.cancel()
+	}
+}
+
+// gasUsed returns the gas consumed, never more than the limit
+func (m *gasMeter) gasUsed() uint64 {
+	return min(m.used.Load(), m.limit)
+}
+
+// gasListener charges the meter of the calling execution on every function
+// call, including the call meterLoops adds to the top of each loop
+type gasListener struct{}
+
+// NewFunctionListener returns the shared gas listener for every function
+func (gasListener) NewFunctionListener(api.FunctionDefinition) experimental.FunctionListener {
+	return experimental.FunctionListenerFunc(func(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ []uint64, _ experimental.StackIterator) {
+		if gas, ok := ctx.Value(callGasKey{}).(callGas); ok {
+			gas.meter.charge(gas.amount)
+		}
+	})
+}
+
+// gasLimit returns the budget for a requested limit: the default when none is
+// requested, capped at the configured maximum
+func (c WASMConfig) gasLimit(requested uint64) uint64 {
+	return capGasLimit(requested, c.DefaultGasLimit, c.MaxGasLimit)
+}
+
+// capGasLimit returns defaultLimit when no limit is requested, and caps the
+// requested one at maxLimit when that is set
+func capGasLimit(requested, defaultLimit, maxLimit uint64) uint64 {
+	if requested == 0 {
+		requested = defaultLimit
+	}
+	if maxLimit > 0 && requested > maxLimit {
+		return maxLimit
+	}
+	return requested
+}
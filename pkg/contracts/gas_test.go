@@ -0,0 +1,163 @@
+package contracts
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+// wasmVec encodes a vector: its length followed by its entries
+func wasmVec(entries ...[]byte) []byte {
+	out := binary.AppendUvarint(nil, uint64(len(entries)))
+	for _, entry := range entries {
+		out = append(out, entry...)
+	}
+	return out
+}
+
+// wasmBinary assembles a module from section IDs and their entries
+func wasmBinary(sections ...wasmSection) []byte {
+	out := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	for _, section := range sections {
+		out = append(out, section.id)
+		out = binary.AppendUvarint(out, uint64(len(section.payload)))
+		out = append(out, section.payload...)
+	}
+	return out
+}
+
+// wasmExport encodes an export of function index
+func wasmExport(name string, index byte) []byte {
+	return append(append([]byte{byte(len(name))}, name...), 0x00, index)
+}
+
+// wasmBody encodes a function body without locals
+func wasmBody(instructions ...byte) []byte {
+	body := append([]byte{0x00}, instructions...)
+	return append(binary.AppendUvarint(nil, uint64(len(body))), body...)
+}
+
+var (
+	// addBody is (i32, i32) -> i32 returning the sum of its parameters
+	addBody = wasmBody(0x20, 0x00, 0x20, 0x01, 0x6a, 0x0b)
+	// spinBody is () -> () looping forever without calling anything
+	spinBody = wasmBody(0x03, 0x40, 0x0c, 0x00, 0x0b, 0x0b)
+	// countBody is (i32) -> i32 looping n times down to 0
+	countBody = wasmBody(0x03, 0x40, 0x20, 0x00, 0x41, 0x01, 0x6b, 0x22, 0x00, 0x0d, 0x00, 0x0b, 0x20, 0x00, 0x0b)
+)
+
+// loopModule exports add, spin and count, importing get_block_height first
+// when imports is set, which shifts the index of every defined function
+func loopModule(imports bool) []byte {
+	types := wasmSection{wasmSectionType, wasmVec(
+		[]byte{0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f},
+		[]byte{0x60, 0x00, 0x00},
+		[]byte{0x60, 0x01, 0x7f, 0x01, 0x7f},
+		[]byte{0x60, 0x00, 0x01, 0x7e},
+	)}
+	functions := wasmSection{wasmSectionFunction, wasmVec([]byte{0}, []byte{1}, []byte{2})}
+	code := wasmSection{wasmSectionCode, wasmVec(addBody, spinBody, countBody)}
+
+	first := byte(0)
+	sections := []wasmSection{types}
+	if imports {
+		first = 1
+		name := append([]byte{3}, hostModuleName...)
+		name = append(append(name, byte(len("get_block_height"))), "get_block_height"...)
+		sections = append(sections, wasmSection{wasmSectionImport, wasmVec(append(name, wasmImportFunc, 3))})
+	}
+	exports := wasmSection{7, wasmVec(wasmExport("add", first), wasmExport("spin", first+1), wasmExport("count", first+2))}
+	return wasmBinary(append(sections, functions, exports, code)...)
+}
+
+// newMeteredEngine returns a WASM engine running loopModule as "loops",
+// with a time limit long enough that only gas stops an execution
+func newMeteredEngine(t *testing.T, imports bool) *WASMEngine {
+	t.Helper()
+	config := DefaultWASMConfig()
+	config.Timeout = time.Minute
+	engine := NewWASMEngineWithConfig(config)
+	if err := engine.DeployContractFromBytes("loops", "loops", loopModule(imports)); err != nil {
+		t.Fatalf("deploying: %v", err)
+	}
+	return engine
+}
+
+func TestMeterLoopsLeavesLoopFreeCodeUnchanged(t *testing.T) {
+	code := wasmBinary(
+		wasmSection{wasmSectionType, wasmVec([]byte{0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f})},
+		wasmSection{wasmSectionFunction, wasmVec([]byte{0})},
+		wasmSection{7, wasmVec(wasmExport("add", 0))},
+		wasmSection{wasmSectionCode, wasmVec(addBody)},
+	)
+	metered, err := meterLoops(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(metered, code) {
+		t.Fatal("code without loops was rewritten")
+	}
+}
+
+func TestMeterLoopsRejectsUnknownOpcodes(t *testing.T) {
+	code := wasmBinary(
+		wasmSection{wasmSectionType, wasmVec([]byte{0x60, 0x00, 0x00})},
+		wasmSection{wasmSectionFunction, wasmVec([]byte{0})},
+		wasmSection{wasmSectionCode, wasmVec(wasmBody(0x03, 0x40, 0xfe, 0x0b, 0x0b))},
+	)
+	if _, err := meterLoops(code); !errors.Is(err, errMalformedModule) {
+		t.Fatalf("got %v, want errMalformedModule", err)
+	}
+}
+
+func TestWASMLoopWithoutCallsRunsOutOfGas(t *testing.T) {
+	engine := newMeteredEngine(t, false)
+
+	start := time.Now()
+	result, err := engine.ExecuteContractWithGas("loops", "spin", 10_000)
+	if code := AsExecutionError(err).Code; code != CodeGasExhausted {
+		t.Fatalf("got %v (%s), want %s", err, code, CodeGasExhausted)
+	}
+	if result.GasUsed != 10_000 {
+		t.Errorf("used %d gas, want the whole budget of 10000", result.GasUsed)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("took %v to run out of gas", elapsed)
+	}
+}
+
+func TestWASMLoopIterationsCostGas(t *testing.T) {
+	for _, imports := range []bool{false, true} {
+		engine := newMeteredEngine(t, imports)
+		for _, n := range []int32{1, 10, 1000} {
+			for run := 0; run < 2; run++ {
+				result, err := engine.ExecuteContractWithGas("loops", "count", 0, n)
+				if err != nil {
+					t.Fatalf("imports %v, count(%d): %v", imports, n, err)
+				}
+				// The entry call and one charge per iteration
+				if want := uint64(n) + 1; result.GasUsed != want {
+					t.Errorf("imports %v, count(%d) used %d gas, want %d", imports, n, result.GasUsed, want)
+				}
+			}
+		}
+	}
+}
+
+func TestWASMAddUsesStableGas(t *testing.T) {
+	engine := newMeteredEngine(t, false)
+	for run := 0; run < 3; run++ {
+		result, err := engine.ExecuteContractWithGas("loops", "add", 0, 2, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Value != int32(5) {
+			t.Errorf("add(2, 3) = %v", result.Value)
+		}
+		if result.GasUsed != 1 {
+			t.Errorf("add used %d gas, want 1", result.GasUsed)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package contracts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// hostModuleName is the import module of the functions the node provides
+const hostModuleName = "env"
+
+// errMemoryAccess aborts a host call whose pointer and length fall outside
+// the calling module's memory
+var errMemoryAccess = errors.New("host call accessed memory out of bounds")
+
+// ExecutionContext describes the chain context a contract executes in
+type ExecutionContext struct {
+	// Caller identifies the account invoking the contract, or the calling
+	// contract's ID for calls made by another contract
+	Caller string
+	// Origin is the account that started the execution, kept across calls
+	// between contracts. It defaults to Caller.
+	Origin string
+	// Value is the amount the caller sends with the call. Calls between
+	// contracts send none.
+	Value uint64
+	// BlockHeight is the index of the latest block
+	BlockHeight int64
+	// BlockTime is the latest block's time in Unix seconds
+	BlockTime int64
+	// TxID is the transaction that triggered the execution, if any
+	TxID string
+	// GasLimit is the execution's gas budget, 0 meaning the engine default
+	GasLimit uint64
+	// Returns selects how the function's result is decoded: ReturnRaw,
+	// ReturnString, or ReturnBytes
+	Returns string
+}
+
+// execution is the state of one running contract call that host functions
+// reach through the call's context
+type execution struct {
+	contractID string
+	owner      string // Address of the contract's owner
+	context    ExecutionContext
+	hasMemory  bool
+	state      *stateTx
+	events     *eventLog
+	tree       *callTree
+	depth      int // Number of contract calls above this one
+}
+
+// executionKey is the context key under which the running execution is stored
+type executionKey struct{}
+
+// currentExecution returns the execution a host function was called from
+func currentExecution(ctx context.Context) *execution {
+	exec, _ := ctx.Value(executionKey{}).(*execution)
+	return exec
+}
+
+// moduleMemory returns the calling module's memory, aborting the call when
+// the module has none
+func moduleMemory(ctx context.Context, mod api.Module) api.Memory {
+	if !currentExecution(ctx).hasMemory {
+		panic(errMemoryAccess)
+	}
+	return mod.Memory()
+}
+
+// readMemory copies a range of the module's memory, aborting the call when
+// the range is out of bounds
+func readMemory(ctx context.Context, mod api.Module, ptr, length uint32) []byte {
+	data, ok := moduleMemory(ctx, mod).Read(ptr, length)
+	if !ok {
+		panic(errMemoryAccess)
+	}
+	return append([]byte(nil), data...)
+}
+
+// writeOutput copies value into the module's buffer at ptr when it fits in
+// length bytes, and returns the value's full length so the caller can retry
+// with a larger buffer
+func writeOutput(ctx context.Context, mod api.Module, ptr, length uint32, value []byte) int32 {
+	memory := moduleMemory(ctx, mod)
+	if uint32(len(value)) <= length {
+		if !memory.Write(ptr, value) {
+			panic(errMemoryAccess)
+		}
+	} else if _, ok := memory.Read(ptr, length); !ok {
+		panic(errMemoryAccess)
+	}
+	return int32(len(value))
+}
+
+// instantiateHostModule registers the host functions contracts import from "env":
+//
+//   - get_block_height() i64 returns the latest block index
+//   - get_caller(ptr, len) i32 writes the caller and returns its length
+//   - storage_get(kptr, klen, vptr, vlen) i32 writes the value stored under a
+//     key and returns its length, or -1 if the key is not set
+//   - storage_set(kptr, klen, vptr, vlen) stores a value under a key
+//   - emit_event(ptr, len) emits an event with the given data
+//   - get_origin(ptr, len) i32 writes the account that started the execution
+//     and returns its length
+//   - get_value() i64 returns the value sent with the call
+//   - get_block_time() i64 returns the latest block's time in Unix seconds
+//   - get_tx_id(ptr, len) i32 writes the triggering transaction's ID and
+//     returns its length, 0 when there is none
+//   - get_owner(ptr, len) i32 writes the contract owner's address and returns
+//     its length, 0 when it has none
+//   - call(idptr, idlen, fnptr, fnlen, argsptr, argslen) i64 calls another
+//     contract's function with a JSON array of arguments and returns its
+//     JSON-encoded result as a packed (ptr << 32 | len) buffer obtained from
+//     the caller's alloc. A failed call aborts the caller.
+//
+// Output buffers too small for the value are left untouched; the returned
+// length tells the contract how much space is needed.
+func instantiateHostModule(ctx context.Context, runtime wazero.Runtime) error {
+	_, err := runtime.NewHostModuleBuilder(hostModuleName).
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context) int64 {
+			return currentExecution(ctx).context.BlockHeight
+		}).
+		Export("get_block_height").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, ptr, length uint32) int32 {
+			return writeOutput(ctx, mod, ptr, length, []byte(currentExecution(ctx).context.Caller))
+		}).
+		Export("get_caller").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, keyPtr, keyLen, valuePtr, valueLen uint32) int32 {
+			key := readMemory(ctx, mod, keyPtr, keyLen)
+			value, ok := currentExecution(ctx).state.get(string(key))
+			if !ok {
+				return -1
+			}
+			return writeOutput(ctx, mod, valuePtr, valueLen, value)
+		}).
+		Export("storage_get").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, keyPtr, keyLen, valuePtr, valueLen uint32) {
+			key := readMemory(ctx, mod, keyPtr, keyLen)
+			value := readMemory(ctx, mod, valuePtr, valueLen)
+			currentExecution(ctx).state.set(string(key), value)
+		}).
+		Export("storage_set").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, ptr, length uint32) {
+			exec := currentExecution(ctx)
+			if err := exec.events.emit(exec.contractID, "", wasmEventData(readMemory(ctx, mod, ptr, length))); err != nil {
+				panic(err)
+			}
+		}).
+		Export("emit_event").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, ptr, length uint32) int32 {
+			return writeOutput(ctx, mod, ptr, length, []byte(currentExecution(ctx).context.Origin))
+		}).
+		Export("get_origin").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context) int64 {
+			return int64(currentExecution(ctx).context.Value)
+		}).
+		Export("get_value").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context) int64 {
+			return currentExecution(ctx).context.BlockTime
+		}).
+		Export("get_block_time").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, ptr, length uint32) int32 {
+			return writeOutput(ctx, mod, ptr, length, []byte(currentExecution(ctx).context.TxID))
+		}).
+		Export("get_tx_id").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, ptr, length uint32) int32 {
+			return writeOutput(ctx, mod, ptr, length, []byte(currentExecution(ctx).owner))
+		}).
+		Export("get_owner").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, idPtr, idLen, fnPtr, fnLen, argsPtr, argsLen uint32) uint64 {
+			contractID := string(readMemory(ctx, mod, idPtr, idLen))
+			functionName := string(readMemory(ctx, mod, fnPtr, fnLen))
+			var params []interface{}
+			if argsLen > 0 {
+				if err := json.Unmarshal(readMemory(ctx, mod, argsPtr, argsLen), &params); err != nil {
+					panic(fmt.Errorf("call arguments must be a JSON array: %w", err))
+				}
+			}
+
+			value, err := callContract(ctx, currentExecution(ctx), contractID, functionName, params)
+			if err != nil {
+				panic(err)
+			}
+			result, err := json.Marshal(value)
+			if err != nil {
+				panic(err)
+			}
+			ptr, err := writeBytes(ctx, mod, moduleMemory(ctx, mod), result, 0)
+			if err != nil {
+				panic(err)
+			}
+			return uint64(ptr)<<32 | uint64(len(result))
+		}).
+		Export("call").
+		Instantiate(ctx)
+	return err
+}
+
+// callContract runs another contract's function for the executing contract
+func callContract(ctx context.Context, caller *execution, contractID, functionName string, params []interface{}) (interface{}, error) {
+	if caller.tree.dispatcher == nil {
+		return nil, errNoDispatcher
+	}
+	return caller.tree.dispatcher.call(ctx, caller, contractID, functionName, params)
+}
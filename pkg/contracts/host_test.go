@@ -0,0 +1,115 @@
+package contracts
+
+import (
+	"errors"
+	"testing"
+)
+
+// wasmImport encodes an import of a host function with the given type index
+func wasmImport(name string, typeIndex byte) []byte {
+	entry := append([]byte{byte(len(hostModuleName))}, hostModuleName...)
+	entry = append(append(entry, byte(len(name))), name...)
+	return append(entry, wasmImportFunc, typeIndex)
+}
+
+// wasmData encodes an active data segment placing data at a small offset
+func wasmData(offset byte, data string) []byte {
+	return append([]byte{0x00, 0x41, offset, 0x0b, byte(len(data))}, data...)
+}
+
+// hostModule imports host functions and exports a function calling each.
+// Its memory holds the key "key" at 0 and the value {"n":1} at 8.
+func hostModule() []byte {
+	return wasmBinary(
+		wasmSection{wasmSectionType, wasmVec(
+			[]byte{0x60, 0x00, 0x01, 0x7e},
+			[]byte{0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f},
+			[]byte{0x60, 0x04, 0x7f, 0x7f, 0x7f, 0x7f, 0x00},
+			[]byte{0x60, 0x04, 0x7f, 0x7f, 0x7f, 0x7f, 0x01, 0x7f},
+			[]byte{0x60, 0x02, 0x7f, 0x7f, 0x00},
+			[]byte{0x60, 0x00, 0x01, 0x7f},
+			[]byte{0x60, 0x00, 0x00},
+		)},
+		wasmSection{wasmSectionImport, wasmVec(
+			wasmImport("get_block_height", 0),
+			wasmImport("get_caller", 1),
+			wasmImport("storage_set", 2),
+			wasmImport("storage_get", 3),
+			wasmImport("emit_event", 4),
+		)},
+		wasmSection{wasmSectionFunction, wasmVec([]byte{0}, []byte{5}, []byte{6}, []byte{5}, []byte{6}, []byte{6})},
+		wasmSection{wasmSectionMemory, wasmVec([]byte{0x00, 0x01})},
+		wasmSection{7, wasmVec(
+			wasmExport("height", 5),
+			wasmExport("caller_len", 6),
+			wasmExport("put", 7),
+			wasmExport("get_len", 8),
+			wasmExport("emit", 9),
+			wasmExport("out_of_bounds", 10),
+		)},
+		wasmSection{wasmSectionCode, wasmVec(
+			wasmBody(0x10, 0x00, 0x0b),
+			wasmBody(0x41, 0xe4, 0x00, 0x41, 0xc0, 0x00, 0x10, 0x01, 0x0b),
+			wasmBody(0x41, 0x00, 0x41, 0x03, 0x41, 0x08, 0x41, 0x07, 0x10, 0x02, 0x0b),
+			wasmBody(0x41, 0x00, 0x41, 0x03, 0x41, 0xc8, 0x01, 0x41, 0x10, 0x10, 0x03, 0x0b),
+			wasmBody(0x41, 0x08, 0x41, 0x07, 0x10, 0x04, 0x0b),
+			wasmBody(0x41, 0x00, 0x41, 0x03, 0x41, 0xf0, 0xa2, 0x04, 0x41, 0x07, 0x10, 0x02, 0x0b),
+		)},
+		wasmSection{11, wasmVec(wasmData(0, "key"), wasmData(8, `{"n":1}`))},
+	)
+}
+
+func TestWASMHostFunctions(t *testing.T) {
+	engine := NewWASMEngine()
+	state := NewMemoryStateStore()
+	engine.SetStateStore(state)
+	if err := engine.DeployContractFromBytes("host", "host", hostModule()); err != nil {
+		t.Fatal(err)
+	}
+	execCtx := ExecutionContext{Caller: "alice", BlockHeight: 42}
+
+	if result, err := engine.ExecuteContractWithContext(execCtx, "host", "height"); err != nil || result.Value != int64(42) {
+		t.Fatalf("height returned %+v, %v", result, err)
+	}
+	if result, err := engine.ExecuteContractWithContext(execCtx, "host", "caller_len"); err != nil || result.Value != int32(len("alice")) {
+		t.Fatalf("caller_len returned %+v, %v", result, err)
+	}
+
+	if result, err := engine.ExecuteContractWithContext(execCtx, "host", "get_len"); err != nil || result.Value != int32(-1) {
+		t.Fatalf("reading an unset key returned %+v, %v", result, err)
+	}
+	if _, err := engine.ExecuteContractWithContext(execCtx, "host", "put"); err != nil {
+		t.Fatal(err)
+	}
+	if got := stored(state, "host", "key"); got != `{"n":1}` {
+		t.Fatalf("stored %q", got)
+	}
+	if result, err := engine.ExecuteContractWithContext(execCtx, "host", "get_len"); err != nil || result.Value != int32(7) {
+		t.Fatalf("reading the stored key returned %+v, %v", result, err)
+	}
+
+	result, err := engine.ExecuteContractWithContext(execCtx, "host", "emit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Events) != 1 || result.Events[0].ContractID != "host" || string(result.Events[0].Data) != `{"n":1}` {
+		t.Fatalf("emitted %+v", result.Events)
+	}
+}
+
+func TestWASMHostFunctionChecksBounds(t *testing.T) {
+	engine := NewWASMEngine()
+	state := NewMemoryStateStore()
+	engine.SetStateStore(state)
+	if err := engine.DeployContractFromBytes("host", "host", hostModule()); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := engine.ExecuteContractWithContext(ExecutionContext{Caller: "alice"}, "host", "out_of_bounds")
+	if !errors.Is(err, errMemoryAccess) {
+		t.Fatalf("got %v, want errMemoryAccess", err)
+	}
+	if got := stored(state, "host", "key"); got != "" {
+		t.Fatalf("out of bounds write stored %q", got)
+	}
+}
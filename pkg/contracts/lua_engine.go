@@ -1,9 +1,12 @@
 package contracts
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	lua "github.com/yuin/gopher-lua"
@@ -12,122 +15,699 @@ import (
 // LuaEngine provides Lua-based smart contract execution
 type LuaEngine struct {
 	contracts map[string]*LuaContract
+	store     ContractStore             // Written through on deploy and removal when set
+	failed    map[string]FailedContract // Stored contracts that failed to reload
+	state     StateStore
+	pool      *luaStatePool
+	config    LuaConfig
 	mutex     sync.RWMutex
+
+	// dispatcher routes calls to other contracts, when connected
+	dispatcher atomic.Pointer[Dispatcher]
 }
 
 // LuaContract represents a Lua smart contract
 type LuaContract struct {
 	ID        string
 	Name      string
+	Owner     string // Address allowed to manage the contract, empty if none
+	Pause     *Pause // Set while executions are suspended
 	Code      string
 	CreatedAt time.Time
 	UpdatedAt time.Time
+
+	// proto is the compiled code, loaded into a pooled state per execution
+	proto *lua.FunctionProto
+	abi   []FunctionABI
 }
 
-// NewLuaEngine creates a new Lua smart contract engine
+// NewLuaEngine creates a new Lua smart contract engine with the default
+// gas costs and limits
 func NewLuaEngine() *LuaEngine {
+	return NewLuaEngineWithConfig(DefaultLuaConfig())
+}
+
+// NewLuaEngineWithConfig creates a new Lua smart contract engine with the
+// given gas costs and limits
+func NewLuaEngineWithConfig(config LuaConfig) *LuaEngine {
 	return &LuaEngine{
 		contracts: make(map[string]*LuaContract),
+		failed:    make(map[string]FailedContract),
+		state:     NewMemoryStateStore(),
+		pool:      newLuaStatePool(DefaultLuaPoolSize),
+		config:    config,
 	}
 }
 
-// DeployContract loads and registers a Lua contract
-func (e *LuaEngine) DeployContract(id, name, code string) error {
+// SetConfig replaces the gas costs and limits
+func (e *LuaEngine) SetConfig(config LuaConfig) {
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
+	e.config = config
+}
+
+// SetStateStore replaces the store holding contract storage
+func (e *LuaEngine) SetStateStore(store StateStore) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.state = store
+}
+
+// DeployContract loads and registers a Lua contract. The contract has no
+// owner, so only admins can manage it.
+func (e *LuaEngine) DeployContract(id, name, code string) error {
+	return e.DeployContractWithOwner(id, name, "", code)
+}
 
-	// Validate the Lua code by attempting to load it
-	L := lua.NewState()
-	defer L.Close()
+// DeployContractWithOwner loads and registers a Lua contract owned by the
+// given address
+func (e *LuaEngine) DeployContractWithOwner(id, name, owner, code string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
 
-	err := L.DoString(code)
+	if err := checkCodeSize([]byte(code), e.config.MaxCodeBytes); err != nil {
+		return err
+	}
+	proto, functions, err := e.prepare(id, code)
 	if err != nil {
-		return fmt.Errorf("invalid Lua code: %w", err)
+		return err
+	}
+	if err := validateLua(proto, functions); err != nil {
+		return err
 	}
 
-	// Store the contract
-	e.contracts[id] = &LuaContract{
+	contract := &LuaContract{
 		ID:        id,
 		Name:      name,
+		Owner:     owner,
 		Code:      code,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
+		proto:     proto,
+		abi:       functions,
+	}
+
+	// Persist first so the store never misses a deployed contract
+	if e.store != nil {
+		if err := e.store.SaveContract(contract.stored()); err != nil {
+			return fmt.Errorf("failed to store contract: %w", err)
+		}
+	}
+
+	// Store the contract
+	e.contracts[id] = contract
+	delete(e.failed, id)
+
+	return nil
+}
+
+// stored returns the persisted form of the contract
+func (c *LuaContract) stored() StoredContract {
+	return StoredContract{ID: c.ID, Name: c.Name, Type: TypeLua, Owner: c.Owner, Code: []byte(c.Code), Pause: c.Pause, CreatedAt: c.CreatedAt}
+}
+
+// prepare compiles contract code, checks that it loads in the sandbox
+// within the default gas limit, and describes the functions it defines. The
+// caller must hold the lock.
+func (e *LuaEngine) prepare(id, code string) (*lua.FunctionProto, []FunctionABI, error) {
+	proto, err := compileLua(id, code)
+	if err != nil {
+		return nil, nil, invalidContract("invalid Lua code: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	meter := &gasMeter{limit: e.config.DefaultGasLimit, cancel: cancel}
+
+	L := e.pool.get()
+	L.SetContext(&luaGasContext{Context: ctx, meter: meter, cost: e.config.InstructionGas})
+	env := sandboxEnv(L)
+	err = loadLua(L, proto, env)
+	e.pool.put(L, err == nil)
+	if meter.exhausted.Load() {
+		return nil, nil, invalidContract("loading the contract exhausted its gas limit of %d", meter.limit)
+	}
+	if err != nil {
+		return nil, nil, invalidContract("invalid Lua code: %v", err)
+	}
+
+	functions, err := luaABI(env)
+	if err != nil {
+		return nil, nil, invalidContract("invalid Lua code: %v", err)
+	}
+	return proto, functions, nil
+}
+
+// Type names the contracts the engine runs
+func (e *LuaEngine) Type() string {
+	return TypeLua
+}
+
+// Deploy loads and registers a Lua contract for owner
+func (e *LuaEngine) Deploy(id, name, owner string, code []byte) error {
+	return e.DeployContractWithOwner(id, name, owner, string(code))
+}
+
+// ContractInfo describes a contract, including one that failed to reload
+func (e *LuaEngine) ContractInfo(id string) (ContractInfo, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if contract, exists := e.contracts[id]; exists {
+		return contract.info(), nil
+	}
+	if failed, exists := e.failed[id]; exists {
+		return failed.info(), nil
+	}
+	return ContractInfo{}, ErrContractNotFound
+}
+
+// ListContractInfo describes every contract, including those that failed
+// to reload
+func (e *LuaEngine) ListContractInfo() []ContractInfo {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	infos := make([]ContractInfo, 0, len(e.contracts)+len(e.failed))
+	for _, contract := range e.contracts {
+		infos = append(infos, contract.info())
+	}
+	for _, failed := range e.failed {
+		infos = append(infos, failed.info())
+	}
+	return infos
+}
+
+// info describes the contract
+func (c *LuaContract) info() ContractInfo {
+	return ContractInfo{ID: c.ID, Name: c.Name, Type: TypeLua, Owner: c.Owner, Status: pauseStatus(c.Pause), Pause: c.Pause, CreatedAt: c.CreatedAt}
+}
+
+// ABI describes the functions a contract defines
+func (e *LuaEngine) ABI(id string) (*ContractABI, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	contract, exists := e.contracts[id]
+	if !exists {
+		return nil, ErrContractNotFound
 	}
+	if len(contract.abi) == 0 {
+		return nil, ErrABIUnavailable
+	}
+	return &ContractABI{ContractID: id, Type: TypeLua, Functions: contract.abi}, nil
+}
+
+// LoadFrom re-validates the Lua contracts saved in store and keeps the
+// store up to date with later deploys and removals. Contracts that fail to
+// load are skipped and reported by FailedContracts.
+func (e *LuaEngine) LoadFrom(store ContractStore) error {
+	stored, err := store.ListContracts()
+	if err != nil {
+		return fmt.Errorf("failed to list stored contracts: %w", err)
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, c := range stored {
+		if c.Type != TypeLua {
+			continue
+		}
+		code := string(c.Code)
+		proto, functions, err := e.prepare(c.ID, code)
+		if err != nil {
+			e.failed[c.ID] = failedContract(c, err)
+			continue
+		}
+		e.contracts[c.ID] = &LuaContract{
+			ID:        c.ID,
+			Name:      c.Name,
+			Owner:     c.Owner,
+			Pause:     c.Pause,
+			Code:      code,
+			CreatedAt: c.CreatedAt,
+			UpdatedAt: time.Now(),
+			proto:     proto,
+			abi:       functions,
+		}
+		delete(e.failed, c.ID)
+	}
+	e.store = store
 
 	return nil
 }
 
-// ExecuteContract runs a function in the specified Lua contract
+// FailedContracts returns the stored contracts that failed to reload
+func (e *LuaEngine) FailedContracts() []FailedContract {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	failed := make([]FailedContract, 0, len(e.failed))
+	for _, contract := range e.failed {
+		failed = append(failed, contract)
+	}
+	return failed
+}
+
+// ExecuteContract runs a function in the specified Lua contract. Its
+// results are converted as described by luaToGo; a function returning
+// several values gives a slice of them.
 func (e *LuaEngine) ExecuteContract(contractID, functionName string, params ...interface{}) (interface{}, error) {
+	result, err := e.ExecuteContractWithContext(ExecutionContext{}, contractID, functionName, params...)
+	if err != nil {
+		return nil, err
+	}
+	return result.Value, nil
+}
+
+// ExecuteContractWithContext runs a function in the specified Lua contract
+// and returns its result along with the events it emitted
+func (e *LuaEngine) ExecuteContractWithContext(execCtx ExecutionContext, contractID, functionName string, params ...interface{}) (*ExecutionResult, error) {
+	return e.ExecuteContractCtx(context.Background(), execCtx, contractID, functionName, params...)
+}
+
+// ExecuteContractCtx runs a function in the specified Lua contract, stopping
+// when parent is cancelled or its deadline passes, or when the gas limit,
+// capped at the configured maximum and shared with the contracts the
+// function calls, runs out. Storage writes, including those of called
+// contracts, are committed only if the whole execution succeeds. When the
+// execution starts, the result reports the gas used even if an error is
+// returned.
+func (e *LuaEngine) ExecuteContractCtx(parent context.Context, execCtx ExecutionContext, contractID, functionName string, params ...interface{}) (*ExecutionResult, error) {
+	return e.run(parent, execCtx, contractID, functionName, params, true)
+}
+
+// DryRun runs a function like ExecuteContractCtx, within the same limits,
+// but never commits its state writes. The result reports the storage the
+// call would change as StateDiff, and its gas use as an estimate.
+func (e *LuaEngine) DryRun(parent context.Context, execCtx ExecutionContext, contractID, functionName string, params ...interface{}) (*ExecutionResult, error) {
+	return e.run(parent, execCtx, contractID, functionName, params, false)
+}
+
+// run executes a call tree from its root, committing its state writes when
+// commit is set and it succeeds
+func (e *LuaEngine) run(parent context.Context, execCtx ExecutionContext, contractID, functionName string, params []interface{}, commit bool) (*ExecutionResult, error) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	tree := newCallTree(e.dispatcher.Load(), cancel)
+	if execCtx.Origin == "" {
+		execCtx.Origin = execCtx.Caller
+	}
+
+	value, err := e.execute(ctx, tree, 0, execCtx, contractID, functionName, params)
+	if errors.Is(err, ErrContractNotFound) {
+		return nil, executionFailure(TypeLua, err)
+	}
+	if err == nil {
+		err = tree.failed()
+	}
+	result := &ExecutionResult{GasUsed: tree.gasUsed()}
+	if err != nil {
+		return result, executionFailure(TypeLua, err)
+	}
+
+	result.Value = value
+	result.Events = tree.events.list()
+	if !commit {
+		result.StateDiff = tree.diff()
+		return result, nil
+	}
+	if err := tree.commit(); err != nil {
+		return result, executionFailure(TypeLua, err)
+	}
+	return result, nil
+}
+
+// execute runs one call of a call tree, at the given nesting depth, without
+// committing its state writes
+func (e *LuaEngine) execute(ctx context.Context, tree *callTree, depth int, execCtx ExecutionContext, contractID, functionName string, params []interface{}) (interface{}, error) {
 	e.mutex.RLock()
 	contract, exists := e.contracts[contractID]
+	store, config := e.state, e.config
+	e.mutex.RUnlock()
 	if !exists {
-		e.mutex.RUnlock()
-		return nil, errors.New("contract not found")
+		return nil, ErrContractNotFound
 	}
-	code := contract.Code
-	e.mutex.RUnlock()
+	if contract.Pause != nil {
+		return nil, pausedError(contractID, contract.Pause)
+	}
+
+	// Borrow a pooled state, reusing it only if the execution succeeds, and
+	// meter every instruction it runs against the tree's budget
+	L := e.pool.get()
+	reusable := false
+	defer func() { e.pool.put(L, reusable) }()
+	meter := tree.gasMeter(config.gasLimit(execCtx.GasLimit))
+	L.SetContext(&luaGasContext{Context: ctx, meter: meter, cost: config.InstructionGas})
 
-	// Create a new Lua state for execution
-	L := lua.NewState()
-	defer L.Close()
+	// Run in a fresh environment so nothing leaks between executions, and
+	// give the contract its host functions
+	env := sandboxEnv(L)
+	exec := &execution{
+		contractID: contractID,
+		owner:      contract.Owner,
+		context:    execCtx,
+		state:      tree.state(store, contractID),
+		events:     tree.events,
+		tree:       tree,
+		depth:      depth,
+	}
+	registerLuaHost(ctx, L, env, exec, meter, config)
 
-	// Load the contract code
-	err := L.DoString(code)
+	// Load the compiled contract code
+	err := loadLua(L, contract.proto, env)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load contract: %w", err)
+		return nil, tree.executionError(ctx, luaRevert("failed to load contract", err))
 	}
 
 	// Get the function
-	luaFunc := L.GetGlobal(functionName)
+	luaFunc := env.RawGetString(functionName)
 	if luaFunc.Type() != lua.LTFunction {
-		return nil, fmt.Errorf("function '%s' not found in contract", functionName)
+		return nil, notFound(contractID, functionName)
 	}
 
 	// Convert Go params to Lua values
 	luaParams := make([]lua.LValue, len(params))
 	for i, param := range params {
-		switch v := param.(type) {
-		case string:
-			luaParams[i] = lua.LString(v)
-		case int:
-			luaParams[i] = lua.LNumber(v)
-		case float64:
-			luaParams[i] = lua.LNumber(v)
-		case bool:
-			luaParams[i] = lua.LBool(v)
-		default:
-			return nil, fmt.Errorf("unsupported parameter type: %T", param)
+		if luaParams[i], err = goToLua(L, param); err != nil {
+			return nil, fmt.Errorf("%w: unsupported parameter type: %T", ErrInvalidParams, param)
 		}
 	}
 
-	// Call the function
+	// Call the function, keeping every value it returns
+	base := L.GetTop()
 	err = L.CallByParam(lua.P{
 		Fn:      luaFunc,
-		NRet:    1,
+		NRet:    lua.MultRet,
 		Protect: true,
 	}, luaParams...)
 
 	if err != nil {
-		return nil, fmt.Errorf("execution error: %w", err)
+		return nil, tree.executionError(ctx, luaRevert("execution error", err))
+	}
+
+	// Convert the results to Go values: nothing is nil, one value is itself,
+	// and several become a slice
+	results := make([]interface{}, 0, L.GetTop()-base)
+	for i := base + 1; i <= L.GetTop(); i++ {
+		result, err := luaToGo(L.Get(i), config.MaxValueDepth)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported return value: %w", err)
+		}
+		results = append(results, result)
+	}
+	L.SetTop(base)
+
+	var value interface{}
+	switch len(results) {
+	case 0:
+	case 1:
+		value = results[0]
+	default:
+		value = results
 	}
 
-	// Get the result
-	result := L.Get(-1)
-	L.Pop(1)
+	reusable = true
+	return value, nil
+}
+
+// registerLuaHost gives a Lua execution its host functions:
+//
+//   - emit(name, payload) emits an event with the payload table as JSON
+//   - storage_get(key) returns the value stored under key, or nil
+//   - storage_set(key, value) stores a string value under key
+//   - call(contractID, function, ...) calls another contract's function and
+//     returns its result; a failed call raises an error and fails the whole
+//     execution even if caught
+//
+// and read-only tables describing the call:
+//
+//   - msg.sender, msg.origin, msg.value and msg.tx_id: the caller, the
+//     account that started the execution, the value sent, and the triggering
+//     transaction
+//   - block.height and block.timestamp: the latest block
+//   - contract.id and contract.owner: the executing contract
+//
+// Storage access and events are charged their fixed costs from config to
+// meter, and nested calls run under ctx.
+func registerLuaHost(ctx context.Context, L *lua.LState, env *lua.LTable, exec *execution, meter *gasMeter, config LuaConfig) {
+	execCtx := exec.context
+	env.RawSetString("msg", luaReadOnly(L, map[string]lua.LValue{
+		"sender": lua.LString(execCtx.Caller),
+		"origin": lua.LString(execCtx.Origin),
+		"value":  lua.LNumber(execCtx.Value),
+		"tx_id":  lua.LString(execCtx.TxID),
+	}))
+	env.RawSetString("block", luaReadOnly(L, map[string]lua.LValue{
+		"height":    lua.LNumber(execCtx.BlockHeight),
+		"timestamp": lua.LNumber(execCtx.BlockTime),
+	}))
+	env.RawSetString("contract", luaReadOnly(L, map[string]lua.LValue{
+		"id":    lua.LString(exec.contractID),
+		"owner": lua.LString(exec.owner),
+	}))
+
+	emit := luaEmit(exec.tree, exec.contractID, config.MaxValueDepth)
+	env.RawSetString("emit", L.NewFunction(func(L *lua.LState) int {
+		chargeLua(L, meter, config.EventGas)
+		return emit(L)
+	}))
+	env.RawSetString("storage_get", L.NewFunction(func(L *lua.LState) int {
+		chargeLua(L, meter, config.StateReadGas)
+		value, ok := exec.state.get(L.CheckString(1))
+		if !ok {
+			L.Push(lua.LNil)
+			return 1
+		}
+		L.Push(lua.LString(value))
+		return 1
+	}))
+	env.RawSetString("storage_set", L.NewFunction(func(L *lua.LState) int {
+		chargeLua(L, meter, config.StateWriteGas)
+		exec.state.set(L.CheckString(1), []byte(L.CheckString(2)))
+		return 0
+	}))
+	env.RawSetString("call", L.NewFunction(func(L *lua.LState) int {
+		contractID, functionName := L.CheckString(1), L.CheckString(2)
+		params := make([]interface{}, 0, L.GetTop()-2)
+		for i := 3; i <= L.GetTop(); i++ {
+			param, err := luaToGo(L.Get(i), config.MaxValueDepth)
+			if err != nil {
+				L.ArgError(i, err.Error())
+			}
+			params = append(params, param)
+		}
+
+		value, err := callContract(ctx, exec, contractID, functionName, params)
+		if err != nil {
+			L.RaiseError("%s", err.Error())
+		}
+		result, err := goToLua(L, value)
+		if err != nil {
+			L.RaiseError("%s", err.Error())
+		}
+		L.Push(result)
+		return 1
+	}))
+}
 
-	// Convert Lua value to Go value
-	switch result.Type() {
-	case lua.LTNil:
+// luaReadOnly returns a table exposing fields that contracts can read but
+// not change
+func luaReadOnly(L *lua.LState, fields map[string]lua.LValue) *lua.LTable {
+	data := L.NewTable()
+	for name, value := range fields {
+		data.RawSetString(name, value)
+	}
+	meta := L.NewTable()
+	meta.RawSetString("__index", data)
+	meta.RawSetString("__newindex", L.NewFunction(func(L *lua.LState) int {
+		L.RaiseError("attempt to modify read-only field %s", L.CheckAny(2).String())
+		return 0
+	}))
+	meta.RawSetString("__metatable", lua.LFalse)
+
+	table := L.NewTable()
+	L.SetMetatable(table, meta)
+	return table
+}
+
+// luaEmit returns the emit(name, payload) function exposed to Lua contracts,
+// which records an event in the tree with the payload table encoded as
+// JSON. Exceeding the event limits fails the tree.
+func luaEmit(tree *callTree, contractID string, maxDepth int) lua.LGFunction {
+	return func(L *lua.LState) int {
+		name := L.CheckString(1)
+		var data json.RawMessage
+		if payload := L.Get(2); payload != lua.LNil {
+			value, err := luaToGo(payload, maxDepth)
+			if err != nil {
+				L.ArgError(2, err.Error())
+			}
+			if data, err = json.Marshal(value); err != nil {
+				L.ArgError(2, err.Error())
+			}
+		}
+		if err := tree.events.emit(contractID, name, data); err != nil {
+			tree.fail(err)
+			L.RaiseError("%s", err.Error())
+		}
+		return 0
+	}
+}
+
+// maxLuaValueItems bounds how many values converting one Lua value may
+// produce, so tables shared many times over can't make it run unbounded
+const maxLuaValueItems = 1 << 16
+
+// luaToGo converts a Lua value to plain Go values, with tables nested at
+// most maxDepth deep. A table whose keys are exactly the integers 1..n
+// becomes a slice. Any other table becomes a map: string keys are kept,
+// number keys are written as Lua prints them, so {[1] = "a", x = "b"}
+// becomes {"1": "a", "x": "b"}, and keys of other types are rejected. An
+// empty table becomes an empty map. Tables that contain themselves are
+// rejected, as are functions and other values with no plain equivalent.
+func luaToGo(value lua.LValue, maxDepth int) (interface{}, error) {
+	converter := &luaConverter{maxDepth: maxDepth, path: make(map[*lua.LTable]bool)}
+	return converter.convert(value, 0)
+}
+
+// luaConverter holds the state of one luaToGo conversion
+type luaConverter struct {
+	maxDepth int
+	path     map[*lua.LTable]bool // Tables being converted, to detect cycles
+	items    int                  // Values converted so far
+}
+
+// convert converts a value nested depth tables deep
+func (c *luaConverter) convert(value lua.LValue, depth int) (interface{}, error) {
+	if c.items++; c.items > maxLuaValueItems {
+		return nil, fmt.Errorf("value has more than %d items", maxLuaValueItems)
+	}
+	switch v := value.(type) {
+	case *lua.LNilType:
 		return nil, nil
-	case lua.LTBool:
-		return lua.LVAsBool(result), nil
-	case lua.LTNumber:
-		return float64(result.(lua.LNumber)), nil
-	case lua.LTString:
-		return string(result.(lua.LString)), nil
+	case lua.LBool:
+		return bool(v), nil
+	case lua.LNumber:
+		return float64(v), nil
+	case lua.LString:
+		return string(v), nil
+	case *lua.LTable:
+		if depth >= c.maxDepth {
+			return nil, fmt.Errorf("value nested deeper than %d tables", c.maxDepth)
+		}
+		if c.path[v] {
+			return nil, errors.New("table contains itself")
+		}
+		c.path[v] = true
+		defer delete(c.path, v)
+		if luaIsArray(v) {
+			return c.convertArray(v, depth)
+		}
+		return c.convertMap(v, depth)
 	default:
-		return nil, fmt.Errorf("unsupported return type: %s", result.Type().String())
+		return nil, fmt.Errorf("unsupported value type: %s", value.Type().String())
+	}
+}
+
+// convertArray converts a table with keys 1..n to a slice
+func (c *luaConverter) convertArray(table *lua.LTable, depth int) (interface{}, error) {
+	n := table.Len()
+	items := make([]interface{}, 0, n)
+	for i := 1; i <= n; i++ {
+		item, err := c.convert(table.RawGetInt(i), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// convertMap converts a table to a map keyed by string
+func (c *luaConverter) convertMap(table *lua.LTable, depth int) (interface{}, error) {
+	fields := make(map[string]interface{})
+	var err error
+	table.ForEach(func(key, item lua.LValue) {
+		if err != nil {
+			return
+		}
+		if key.Type() != lua.LTString && key.Type() != lua.LTNumber {
+			err = fmt.Errorf("unsupported table key type: %s", key.Type().String())
+			return
+		}
+		var converted interface{}
+		if converted, err = c.convert(item, depth+1); err == nil {
+			fields[key.String()] = converted
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// luaIsArray reports whether a table's keys are exactly the integers 1..n
+// for some n > 0
+func luaIsArray(table *lua.LTable) bool {
+	count, largest, isArray := 0, 0, true
+	table.ForEach(func(key, _ lua.LValue) {
+		n, ok := key.(lua.LNumber)
+		if !ok || n != lua.LNumber(int(n)) || n < 1 {
+			isArray = false
+			return
+		}
+		count, largest = count+1, max(largest, int(n))
+	})
+	// As many distinct positive integers as the largest of them are 1..n
+	return isArray && count > 0 && largest == count
+}
+
+// goToLua converts plain Go values, as produced by luaToGo, JSON decoding,
+// or WASM results, to Lua values
+func goToLua(L *lua.LState, value interface{}) (lua.LValue, error) {
+	switch v := value.(type) {
+	case nil:
+		return lua.LNil, nil
+	case bool:
+		return lua.LBool(v), nil
+	case string:
+		return lua.LString(v), nil
+	case []byte:
+		return lua.LString(v), nil
+	case int:
+		return lua.LNumber(v), nil
+	case int32:
+		return lua.LNumber(v), nil
+	case int64:
+		return lua.LNumber(v), nil
+	case float32:
+		return lua.LNumber(v), nil
+	case float64:
+		return lua.LNumber(v), nil
+	case []interface{}:
+		table := L.NewTable()
+		for _, item := range v {
+			converted, err := goToLua(L, item)
+			if err != nil {
+				return nil, err
+			}
+			table.Append(converted)
+		}
+		return table, nil
+	case map[string]interface{}:
+		table := L.NewTable()
+		for key, item := range v {
+			converted, err := goToLua(L, item)
+			if err != nil {
+				return nil, err
+			}
+			table.RawSetString(key, converted)
+		}
+		return table, nil
+	default:
+		return nil, fmt.Errorf("unsupported type: %T", value)
 	}
 }
 
@@ -138,7 +718,7 @@ func (e *LuaEngine) GetContract(id string) (*LuaContract, error) {
 
 	contract, exists := e.contracts[id]
 	if !exists {
-		return nil, errors.New("contract not found")
+		return nil, ErrContractNotFound
 	}
 
 	return contract, nil
@@ -157,18 +737,95 @@ func (e *LuaEngine) ListContracts() []*LuaContract {
 	return contracts
 }
 
-// RemoveContract deletes a contract by ID
+// RemoveContract deletes a contract by ID without checking who asks for it
 func (e *LuaEngine) RemoveContract(id string) error {
+	return e.RemoveContractAs(Manager{Admin: true}, id)
+}
+
+// RemoveContractAs deletes a contract on behalf of its owner or an admin
+func (e *LuaEngine) RemoveContractAs(manager Manager, id string) error {
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
 
-	_, exists := e.contracts[id]
-	if !exists {
-		return errors.New("contract not found")
+	contract, exists := e.contracts[id]
+	failedContract, failed := e.failed[id]
+	if !exists && !failed {
+		return ErrContractNotFound
+	}
+	owner := failedContract.Owner
+	if exists {
+		owner = contract.Owner
+	}
+	if err := manager.authorize(id, owner); err != nil {
+		return err
+	}
+
+	// Remove from the store first so the contract can't come back on restart
+	if e.store != nil {
+		if err := e.store.DeleteContract(id); err != nil {
+			return fmt.Errorf("failed to delete stored contract: %w", err)
+		}
 	}
 
 	// Remove the contract from the map
 	delete(e.contracts, id)
+	delete(e.failed, id)
+
+	return nil
+}
+
+// TransferOwnership hands a contract to a new owner on behalf of its current
+// owner or an admin
+func (e *LuaEngine) TransferOwnership(manager Manager, id, newOwner string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	contract, exists := e.contracts[id]
+	if !exists {
+		return ErrContractNotFound
+	}
+	if err := manager.authorize(id, contract.Owner); err != nil {
+		return err
+	}
+
+	// Contracts are read without the lock once fetched, so replace it rather
+	// than changing it in place
+	updated := *contract
+	updated.Owner = newOwner
+	updated.UpdatedAt = time.Now()
+	if e.store != nil {
+		if err := e.store.SaveContract(updated.stored()); err != nil {
+			return fmt.Errorf("failed to store contract: %w", err)
+		}
+	}
+	e.contracts[id] = &updated
+
+	return nil
+}
+
+// SetPause suspends a contract's executions with pause, or resumes them
+// when pause is nil, on behalf of its owner or an admin
+func (e *LuaEngine) SetPause(manager Manager, id string, pause *Pause) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	contract, exists := e.contracts[id]
+	if !exists {
+		return ErrContractNotFound
+	}
+	if err := manager.authorize(id, contract.Owner); err != nil {
+		return err
+	}
+
+	updated := *contract
+	updated.Pause = pause
+	updated.UpdatedAt = time.Now()
+	if e.store != nil {
+		if err := e.store.SaveContract(updated.stored()); err != nil {
+			return fmt.Errorf("failed to store contract: %w", err)
+		}
+	}
+	e.contracts[id] = &updated
 
 	return nil
 }
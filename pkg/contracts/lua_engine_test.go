@@ -0,0 +1,184 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// spinContract never returns from spin, leaving it to the caller to stop
+const spinContract = `
+function spin()
+	while true do end
+end
+
+function add(a, b)
+	return a + b
+end
+`
+
+// newSpinEngine returns a Lua engine running spinContract as "spin" with
+// free instructions, so only its context can stop spin
+func newSpinEngine(t *testing.T) *LuaEngine {
+	t.Helper()
+	config := DefaultLuaConfig()
+	config.InstructionGas = 0
+	engine := NewLuaEngineWithConfig(config)
+	if err := engine.DeployContract("spin", "spin", spinContract); err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+func TestLuaExecutionStopsAtDeadline(t *testing.T) {
+	engine := newSpinEngine(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := engine.ExecuteContractCtx(ctx, ExecutionContext{}, "spin", "spin")
+	if !errors.Is(err, ErrExecutionTimeout) || AsExecutionError(err).Code != CodeTimeout {
+		t.Fatalf("got %v, want a timeout", err)
+	}
+	if value, err := engine.ExecuteContract("spin", "add", 2, 3); err != nil || value != 5.0 {
+		t.Fatalf("next call returned %v, %v", value, err)
+	}
+}
+
+func TestLuaExecutionStopsWhenCallerLeaves(t *testing.T) {
+	engine := newSpinEngine(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err := engine.ExecuteContractCtx(ctx, ExecutionContext{}, "spin", "spin")
+	if !errors.Is(err, ErrExecutionCancelled) || AsExecutionError(err).Code != CodeCancelled {
+		t.Fatalf("got %v, want a cancellation", err)
+	}
+	if value, err := engine.ExecuteContract("spin", "add", 2, 3); err != nil || value != 5.0 {
+		t.Fatalf("next call returned %v, %v", value, err)
+	}
+}
+
+// ownerOnlyContract lets only its owner withdraw, and reports what it
+// knows about the call
+const ownerOnlyContract = `
+function withdraw()
+	if msg.sender ~= contract.owner then
+		error("only the owner may withdraw")
+	end
+	return msg.value
+end
+
+function describe()
+	return msg.origin .. "|" .. msg.tx_id .. "|" .. block.height .. "|" .. block.timestamp
+end
+
+function impersonate()
+	msg.sender = contract.owner
+end
+`
+
+func TestLuaContractSeesItsCaller(t *testing.T) {
+	engine := NewLuaEngine()
+	if err := engine.Deploy("vault", "vault", "alice", []byte(ownerOnlyContract)); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := engine.ExecuteContractWithContext(ExecutionContext{Caller: "alice", Value: 7}, "vault", "withdraw")
+	if err != nil || result.Value != 7.0 {
+		t.Fatalf("owner's withdrawal returned %+v, %v", result, err)
+	}
+	if _, err := engine.ExecuteContractWithContext(ExecutionContext{Caller: "mallory"}, "vault", "withdraw"); err == nil || !strings.Contains(err.Error(), "only the owner") {
+		t.Fatalf("stranger's withdrawal got %v", err)
+	}
+	if _, err := engine.ExecuteContractWithContext(ExecutionContext{Caller: "mallory"}, "vault", "impersonate"); err == nil {
+		t.Fatal("contract rewrote msg.sender")
+	}
+
+	execCtx := ExecutionContext{Caller: "alice", TxID: "tx-1", BlockHeight: 12, BlockTime: 1700000000}
+	result, err = engine.ExecuteContractWithContext(execCtx, "vault", "describe")
+	if err != nil || result.Value != "alice|tx-1|12|1700000000" {
+		t.Fatalf("describe returned %+v, %v", result, err)
+	}
+}
+
+// valuesContract returns tables and several values
+const valuesContract = `
+function holders()
+	return {"alice", "bob"}
+end
+
+function record()
+	return {name = "gold", supply = 100, tags = {"rare"}, meta = {owner = {id = 1}}}
+end
+
+function mixed()
+	return {[1] = "a", x = "b", [2.5] = true}
+end
+
+function empty()
+	return {}
+end
+
+function several()
+	return 1, "two", {3}
+end
+
+function shared()
+	local t = {1}
+	return {a = t, b = t}
+end
+
+function cyclic()
+	local t = {}
+	t.self = t
+	return t
+end
+
+function deep()
+	return {{{{1}}}}
+end
+
+function callback()
+	return function() end
+end
+`
+
+func TestLuaReturnsStructuredValues(t *testing.T) {
+	config := DefaultLuaConfig()
+	config.MaxValueDepth = 3
+	engine := NewLuaEngineWithConfig(config)
+	if err := engine.DeployContract("values", "values", valuesContract); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]interface{}{
+		"holders": []interface{}{"alice", "bob"},
+		"record": map[string]interface{}{
+			"name": "gold", "supply": 100.0, "tags": []interface{}{"rare"},
+			"meta": map[string]interface{}{"owner": map[string]interface{}{"id": 1.0}},
+		},
+		"mixed":   map[string]interface{}{"1": "a", "x": "b", "2.5": true},
+		"empty":   map[string]interface{}{},
+		"several": []interface{}{1.0, "two", []interface{}{3.0}},
+		"shared":  map[string]interface{}{"a": []interface{}{1.0}, "b": []interface{}{1.0}},
+	}
+	for function, want := range tests {
+		got, err := engine.ExecuteContract("values", function)
+		if err != nil {
+			t.Errorf("%s: %v", function, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s returned %#v, want %#v", function, got, want)
+		}
+	}
+
+	for function, reason := range map[string]string{"cyclic": "contains itself", "deep": "deeper than 3", "callback": "unsupported value type"} {
+		if _, err := engine.ExecuteContract("values", function); err == nil || !strings.Contains(err.Error(), reason) {
+			t.Errorf("%s: got %v, want an error mentioning %q", function, err, reason)
+		}
+	}
+}
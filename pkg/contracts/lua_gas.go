@@ -0,0 +1,75 @@
+package contracts
+
+import (
+	"context"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// LuaConfig bounds the code size of Lua contracts, the gas a single
+// execution may use, and the values it may pass back
+type LuaConfig struct {
+	// MaxCodeBytes caps the size of deployed code, 0 meaning no limit
+	MaxCodeBytes int
+	// DefaultGasLimit is the budget of calls that don't set one
+	DefaultGasLimit uint64
+	// MaxGasLimit caps the budget a caller may request
+	MaxGasLimit uint64
+	// InstructionGas is charged for every VM instruction the contract runs
+	InstructionGas uint64
+	// StateReadGas is charged for every storage_get
+	StateReadGas uint64
+	// StateWriteGas is charged for every storage_set
+	StateWriteGas uint64
+	// EventGas is charged for every emitted event
+	EventGas uint64
+	// MaxValueDepth caps how deeply tables may nest in results, event
+	// payloads and call arguments
+	MaxValueDepth int
+}
+
+// DefaultLuaConfig returns the default Lua code size, gas costs and limits
+func DefaultLuaConfig() LuaConfig {
+	return LuaConfig{
+		MaxCodeBytes:    DefaultMaxLuaCodeBytes,
+		DefaultGasLimit: 1_000_000,
+		MaxGasLimit:     10_000_000,
+		InstructionGas:  1,
+		StateReadGas:    100,
+		StateWriteGas:   500,
+		EventGas:        200,
+		MaxValueDepth:   32,
+	}
+}
+
+// gasLimit returns the budget for a requested limit: the default when none is
+// requested, capped at the configured maximum
+func (c LuaConfig) gasLimit(requested uint64) uint64 {
+	return capGasLimit(requested, c.DefaultGasLimit, c.MaxGasLimit)
+}
+
+// luaGasContext charges gas for every instruction a Lua state runs. A state
+// given a context checks its Done channel before each instruction, so every
+// check is charged as one instruction; once the meter is exhausted it
+// cancels the tree and the state stops at that same instruction.
+type luaGasContext struct {
+	context.Context
+	meter *gasMeter
+	cost  uint64
+}
+
+// Done charges one instruction and returns the channel closed when the
+// execution must stop
+func (c *luaGasContext) Done() <-chan struct{} {
+	c.meter.charge(c.cost)
+	return c.Context.Done()
+}
+
+// chargeLua charges a host function's fixed cost, stopping the contract
+// right away when it exhausts the budget
+func chargeLua(L *lua.LState, meter *gasMeter, amount uint64) {
+	meter.charge(amount)
+	if meter.exhausted.Load() {
+		L.RaiseError("%s", ErrOutOfGas.Error())
+	}
+}
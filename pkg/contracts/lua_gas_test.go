@@ -0,0 +1,74 @@
+package contracts
+
+import (
+	"errors"
+	"testing"
+)
+
+// meteredContract loops forever in spin and writes and emits in store
+const meteredContract = `
+function spin()
+	while true do end
+end
+
+function store(value)
+	storage_set("value", value)
+	emit("Stored", {value = value})
+	return value
+end
+`
+
+// newMeteredLuaEngine returns a Lua engine running meteredContract as "metered"
+func newMeteredLuaEngine(t *testing.T, config LuaConfig) *LuaEngine {
+	t.Helper()
+	engine := NewLuaEngineWithConfig(config)
+	if err := engine.DeployContract("metered", "metered", meteredContract); err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+func TestLuaLoopExhaustsGasDeterministically(t *testing.T) {
+	engine := newMeteredLuaEngine(t, DefaultLuaConfig())
+
+	for i := 0; i < 3; i++ {
+		result, err := engine.ExecuteContractWithContext(ExecutionContext{GasLimit: 10_000}, "metered", "spin")
+		if !errors.Is(err, ErrOutOfGas) || AsExecutionError(err).Code != CodeGasExhausted {
+			t.Fatalf("run %d: got %v, want ErrOutOfGas", i, err)
+		}
+		if result.GasUsed != 10_000 {
+			t.Fatalf("run %d used %d gas, want the whole budget", i, result.GasUsed)
+		}
+	}
+}
+
+func TestLuaCallReportsStableGas(t *testing.T) {
+	config := DefaultLuaConfig()
+	engine := newMeteredLuaEngine(t, config)
+
+	var used uint64
+	for i := 0; i < 3; i++ {
+		result, err := engine.ExecuteContractWithContext(ExecutionContext{}, "metered", "store", "x")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i > 0 && result.GasUsed != used {
+			t.Fatalf("run %d used %d gas, earlier runs %d", i, result.GasUsed, used)
+		}
+		used = result.GasUsed
+	}
+	if fixed := config.StateWriteGas + config.EventGas; used <= fixed {
+		t.Fatalf("store used %d gas, less than its host calls' %d", used, fixed)
+	}
+}
+
+func TestLuaGasLimitIsCapped(t *testing.T) {
+	config := DefaultLuaConfig()
+	config.MaxGasLimit = 5_000
+	engine := newMeteredLuaEngine(t, config)
+
+	result, err := engine.ExecuteContractWithContext(ExecutionContext{GasLimit: 1_000_000}, "metered", "spin")
+	if !errors.Is(err, ErrOutOfGas) || result.GasUsed != config.MaxGasLimit {
+		t.Fatalf("got %v after %d gas, want to run out at the cap", err, result.GasUsed)
+	}
+}
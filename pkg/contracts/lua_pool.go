@@ -0,0 +1,156 @@
+package contracts
+
+import (
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// DefaultLuaPoolSize is how many idle Lua states an engine keeps for reuse
+const DefaultLuaPoolSize = 16
+
+// luaLibs are the libraries available to Lua contracts
+var luaLibs = []struct {
+	name string
+	open lua.LGFunction
+}{
+	{lua.BaseLibName, lua.OpenBase},
+	{lua.TabLibName, lua.OpenTable},
+	{lua.StringLibName, lua.OpenString},
+	{lua.MathLibName, lua.OpenMath},
+}
+
+// luaBlockedGlobals are base library functions removed from the sandbox
+// because they reach files or the shared global environment, run code
+// deploy-time checks never saw, or bypass the metatables that protect
+// shared tables
+var luaBlockedGlobals = []string{"dofile", "loadfile", "require", "module", "getfenv", "setfenv", "load", "loadstring", "rawset", "rawget"}
+
+// luaPristineKey is the registry key of the globals every execution's
+// environment is copied from
+const luaPristineKey = "simple-blockchain.pristine"
+
+// compileLua parses and compiles contract code once so executions only have
+// to load the compiled function
+func compileLua(name, code string) (*lua.FunctionProto, error) {
+	chunk, err := parse.Parse(strings.NewReader(code), name)
+	if err != nil {
+		return nil, err
+	}
+	return lua.Compile(chunk, name)
+}
+
+// newSandboxState creates a Lua state with only the base, table, string and
+// math libraries and no file access. The libraries are moved out of the
+// state's real globals into a registry table that sandboxEnv copies, so
+// the real globals stay empty and nothing a contract reaches can change
+// what later executions start from.
+func newSandboxState() *lua.LState {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range luaLibs {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range luaBlockedGlobals {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	// Hide the string metatable so contracts can't change it for later calls
+	if mt, ok := L.GetMetatable(lua.LString("")).(*lua.LTable); ok {
+		mt.RawSetString("__metatable", lua.LFalse)
+	}
+
+	pristine := L.NewTable()
+	L.G.Global.ForEach(func(key, value lua.LValue) {
+		if value != L.G.Global {
+			pristine.RawSet(key, value)
+		}
+	})
+	L.G.Registry.RawSetString(luaPristineKey, pristine)
+	L.G.Global = L.NewTable()
+	L.Env = L.G.Global
+	return L
+}
+
+// sandboxEnv returns a fresh global environment for one execution, copied
+// from the state's pristine globals. Library tables are copied deeply so
+// changes a contract makes to them, like any globals it sets, are gone
+// once the execution ends.
+func sandboxEnv(L *lua.LState) *lua.LTable {
+	pristine := L.G.Registry.RawGetString(luaPristineKey).(*lua.LTable)
+	env := copyLuaTable(L, pristine, make(map[*lua.LTable]*lua.LTable))
+	env.RawSetString("_G", env)
+	return env
+}
+
+// copyLuaTable copies a table and the tables nested in it, copying each
+// table once so tables that refer to themselves or each other keep doing so
+func copyLuaTable(L *lua.LState, table *lua.LTable, copies map[*lua.LTable]*lua.LTable) *lua.LTable {
+	if copied, ok := copies[table]; ok {
+		return copied
+	}
+	copied := L.NewTable()
+	copies[table] = copied
+	table.ForEach(func(key, value lua.LValue) {
+		if nested, ok := value.(*lua.LTable); ok {
+			value = copyLuaTable(L, nested, copies)
+		}
+		copied.RawSet(key, value)
+	})
+	return copied
+}
+
+// globalsWritten reports whether anything was stored in the state's real
+// globals, which executions never use, so the state can't be trusted
+func globalsWritten(L *lua.LState) bool {
+	written := false
+	L.G.Global.ForEach(func(lua.LValue, lua.LValue) { written = true })
+	return written
+}
+
+// loadLua runs a compiled contract chunk in env, defining its functions
+func loadLua(L *lua.LState, proto *lua.FunctionProto, env *lua.LTable) error {
+	fn := L.NewFunctionFromProto(proto)
+	fn.Env = env
+	L.Push(fn)
+	return L.PCall(0, 0, nil)
+}
+
+// luaStatePool keeps a bounded number of idle sandboxed states so executions
+// don't pay for creating a state and opening its libraries
+type luaStatePool struct {
+	states chan *lua.LState
+}
+
+// newLuaStatePool creates a pool keeping up to size idle states
+func newLuaStatePool(size int) *luaStatePool {
+	return &luaStatePool{states: make(chan *lua.LState, size)}
+}
+
+// get returns an idle state, or a new one when none is available
+func (p *luaStatePool) get() *lua.LState {
+	select {
+	case L := <-p.states:
+		return L
+	default:
+		return newSandboxState()
+	}
+}
+
+// put returns a state to the pool once it's done with. States from failed
+// executions, which may have been interrupted mid-call, and states whose
+// real globals were written are closed instead.
+func (p *luaStatePool) put(L *lua.LState, reusable bool) {
+	if reusable && !globalsWritten(L) {
+		L.RemoveContext()
+		L.SetTop(0)
+		select {
+		case p.states <- L:
+			return
+		default:
+		}
+	}
+	L.Close()
+}
@@ -0,0 +1,141 @@
+package contracts
+
+import (
+	"sync"
+	"testing"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// leakContract tries to leave globals behind for the next execution
+const leakContract = `
+function set()
+	counter = 1
+	_G.other = 2
+	string.leaked = "yes"
+	math.floor = nil
+end
+
+function get()
+	return tostring(counter) .. "," .. tostring(other) .. "," .. tostring(string.leaked) .. "," .. type(math.floor)
+end
+
+function fail()
+	error("failed")
+end
+
+function add(a, b)
+	return a + b
+end
+`
+
+// newPooledEngine returns a Lua engine running leakContract as "leak",
+// keeping at most one idle state so every execution reuses the same one
+func newPooledEngine(t testing.TB) *LuaEngine {
+	t.Helper()
+	engine := NewLuaEngine()
+	engine.pool = newLuaStatePool(1)
+	if err := engine.DeployContract("leak", "leak", leakContract); err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+// idleState returns the pool's idle state without taking it out
+func idleState(t *testing.T, pool *luaStatePool) *lua.LState {
+	t.Helper()
+	select {
+	case L := <-pool.states:
+		pool.states <- L
+		return L
+	default:
+		t.Fatal("no idle state in the pool")
+		return nil
+	}
+}
+
+func TestPooledLuaExecutionsDoNotShareGlobals(t *testing.T) {
+	engine := newPooledEngine(t)
+	if _, err := engine.ExecuteContract("leak", "set"); err != nil {
+		t.Fatal(err)
+	}
+	state := idleState(t, engine.pool)
+
+	got, err := engine.ExecuteContract("leak", "get")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idleState(t, engine.pool) != state {
+		t.Fatal("the second execution didn't reuse the pooled state")
+	}
+	if got != "nil,nil,nil,function" {
+		t.Fatalf("second execution saw %q left by the first", got)
+	}
+}
+
+func TestFailedLuaExecutionDiscardsState(t *testing.T) {
+	engine := newPooledEngine(t)
+	if _, err := engine.ExecuteContract("leak", "add", 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	idleState(t, engine.pool)
+
+	if _, err := engine.ExecuteContract("leak", "fail"); err == nil {
+		t.Fatal("fail didn't fail")
+	}
+	if n := len(engine.pool.states); n != 0 {
+		t.Fatalf("pool kept %d states after a failed execution", n)
+	}
+}
+
+func TestConcurrentPooledLuaExecutions(t *testing.T) {
+	engine := newPooledEngine(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got, err := engine.ExecuteContract("leak", "add", i, 1)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got != float64(i+1) {
+				t.Errorf("add(%d, 1) = %v", i, got)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if n := len(engine.pool.states); n > 1 {
+		t.Fatalf("pool holds %d idle states, over its bound of 1", n)
+	}
+}
+
+// BenchmarkLuaExecute measures a call to a precompiled contract on a pooled
+// state
+func BenchmarkLuaExecute(b *testing.B) {
+	engine := newPooledEngine(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := engine.ExecuteContract("leak", "add", 1, 2); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkLuaExecuteFromSource measures the same call on a new state that
+// parses the contract source first, as every execution used to
+func BenchmarkLuaExecuteFromSource(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		L := newSandboxState()
+		L.Env = sandboxEnv(L)
+		if err := L.DoString(leakContract); err != nil {
+			b.Fatal(err)
+		}
+		if err := L.CallByParam(lua.P{Fn: L.Env.RawGetString("add"), NRet: 1, Protect: true}, lua.LNumber(1), lua.LNumber(2)); err != nil {
+			b.Fatal(err)
+		}
+		L.Close()
+	}
+}
@@ -0,0 +1,183 @@
+package contracts
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// wasmPageSize is the size of one page of WASM linear memory
+const wasmPageSize = 65536
+
+// WASM binary section and import kind IDs read by declaredMemories
+const (
+	wasmSectionImport = 2
+	wasmSectionMemory = 5
+	wasmImportFunc    = 0
+	wasmImportTable   = 1
+	wasmImportMemory  = 2
+	wasmImportGlobal  = 3
+)
+
+// ErrMemoryLimit is returned when a contract needs more memory than allowed
+var ErrMemoryLimit = errors.New("memory limit exceeded")
+
+// errMalformedModule is returned when the memory declarations can't be read
+var errMalformedModule = errors.New("malformed WASM module")
+
+// memoryLimits are the page limits of a declared or imported memory
+type memoryLimits struct {
+	Min    uint32
+	Max    uint32
+	HasMax bool
+}
+
+// checkMemoryLimits rejects modules whose memories can't fit in limitPages.
+// A declared maximum must fit as well, since the runtime can't cap it.
+// It reports whether the module has a memory; modules that can't be read
+// are left to the compiler to reject.
+func checkMemoryLimits(code []byte, limitPages uint32) (bool, error) {
+	memories, err := declaredMemories(code)
+	if err != nil {
+		return false, nil
+	}
+	for _, memory := range memories {
+		if memory.Min > limitPages {
+			return true, fmt.Errorf("%w: module requires %d pages, limit is %d", ErrMemoryLimit, memory.Min, limitPages)
+		}
+		if memory.HasMax && memory.Max > limitPages {
+			return true, fmt.Errorf("%w: module allows %d pages, limit is %d", ErrMemoryLimit, memory.Max, limitPages)
+		}
+	}
+	return len(memories) > 0, nil
+}
+
+// atMemoryLimit reports whether an instance's memory has grown to the limit,
+// which is why a trapped execution failed to allocate
+func atMemoryLimit(memory api.Memory, limitPages uint32) bool {
+	return memory.Size() >= limitPages*wasmPageSize
+}
+
+// declaredMemories reads the limits of the memories a module defines or
+// imports, without compiling it
+func declaredMemories(code []byte) ([]memoryLimits, error) {
+	if len(code) < 8 {
+		return nil, errMalformedModule
+	}
+
+	var memories []memoryLimits
+	rest := code[8:]
+	for len(rest) > 0 {
+		id := rest[0]
+		size, n := binary.Uvarint(rest[1:])
+		if n <= 0 || size > uint64(len(rest)-1-n) {
+			return nil, errMalformedModule
+		}
+		section := bytes.NewReader(rest[1+n : 1+n+int(size)])
+		rest = rest[1+n+int(size):]
+
+		var found []memoryLimits
+		var err error
+		switch id {
+		case wasmSectionImport:
+			found, err = importedMemories(section)
+		case wasmSectionMemory:
+			found, err = definedMemories(section)
+		}
+		if err != nil {
+			return nil, errMalformedModule
+		}
+		memories = append(memories, found...)
+	}
+	return memories, nil
+}
+
+// definedMemories reads the entries of a memory section
+func definedMemories(r *bytes.Reader) ([]memoryLimits, error) {
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	var memories []memoryLimits
+	for i := uint64(0); i < count; i++ {
+		limits, err := readLimits(r)
+		if err != nil {
+			return nil, err
+		}
+		memories = append(memories, limits)
+	}
+	return memories, nil
+}
+
+// importedMemories reads the memory entries of an import section
+func importedMemories(r *bytes.Reader) ([]memoryLimits, error) {
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	var memories []memoryLimits
+	for i := uint64(0); i < count; i++ {
+		// Module and field names
+		for j := 0; j < 2; j++ {
+			length, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := io.CopyN(io.Discard, r, int64(length)); err != nil {
+				return nil, err
+			}
+		}
+
+		kind, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch kind {
+		case wasmImportFunc:
+			_, err = binary.ReadUvarint(r)
+		case wasmImportTable:
+			if _, err = r.ReadByte(); err == nil {
+				_, err = readLimits(r)
+			}
+		case wasmImportMemory:
+			var limits memoryLimits
+			if limits, err = readLimits(r); err == nil {
+				memories = append(memories, limits)
+			}
+		case wasmImportGlobal:
+			_, err = io.CopyN(io.Discard, r, 2)
+		default:
+			err = errMalformedModule
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return memories, nil
+}
+
+// readLimits reads a limits entry: a flag byte, the minimum, and the maximum
+// when the flag's low bit is set
+func readLimits(r *bytes.Reader) (memoryLimits, error) {
+	flag, err := r.ReadByte()
+	if err != nil {
+		return memoryLimits{}, err
+	}
+	min, err := binary.ReadUvarint(r)
+	if err != nil {
+		return memoryLimits{}, err
+	}
+	limits := memoryLimits{Min: uint32(min)}
+	if flag&1 == 1 {
+		max, err := binary.ReadUvarint(r)
+		if err != nil {
+			return memoryLimits{}, err
+		}
+		limits.Max, limits.HasMax = uint32(max), true
+	}
+	return limits, nil
+}
@@ -0,0 +1,56 @@
+package contracts
+
+import (
+	"errors"
+	"testing"
+)
+
+// memoryModule declares a memory of min pages and exports grow, which adds
+// a page at a time until memory.grow fails and then traps
+func memoryModule(min byte) []byte {
+	return wasmBinary(
+		wasmSection{wasmSectionType, wasmVec([]byte{0x60, 0x00, 0x00})},
+		wasmSection{wasmSectionFunction, wasmVec([]byte{0})},
+		wasmSection{wasmSectionMemory, wasmVec([]byte{0x00, min})},
+		wasmSection{7, wasmVec(wasmExport("grow", 0))},
+		wasmSection{wasmSectionCode, wasmVec(wasmBody(
+			0x03, 0x40,
+			0x41, 0x01, 0x40, 0x00,
+			0x41, 0x7f, 0x46,
+			0x04, 0x40, 0x00, 0x0b,
+			0x0c, 0x00,
+			0x0b,
+			0x0b,
+		))},
+	)
+}
+
+func TestModuleDeclaringTooMuchMemoryIsRejected(t *testing.T) {
+	config := DefaultWASMConfig()
+	config.MemoryLimitPages = 4
+	engine := NewWASMEngineWithConfig(config)
+
+	err := engine.DeployContractFromBytes("big", "big", memoryModule(5))
+	if !errors.Is(err, ErrMemoryLimit) {
+		t.Fatalf("got %v, want ErrMemoryLimit", err)
+	}
+	if err := engine.DeployContractFromBytes("small", "small", memoryModule(4)); err != nil {
+		t.Fatalf("module within the limit: %v", err)
+	}
+}
+
+func TestGrowingPastMemoryLimitFailsClearly(t *testing.T) {
+	config := DefaultWASMConfig()
+	config.MemoryLimitPages = 32
+	engine := NewWASMEngineWithConfig(config)
+	if err := engine.DeployContractFromBytes("grow", "grow", memoryModule(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := engine.ExecuteContract("grow", "grow"); !errors.Is(err, ErrMemoryLimit) {
+		t.Fatalf("got %v, want ErrMemoryLimit", err)
+	}
+	if limit := engine.MemoryLimitPages(); limit != 32 {
+		t.Fatalf("engine reports a limit of %d pages, want 32", limit)
+	}
+}
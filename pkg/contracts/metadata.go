@@ -0,0 +1,166 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/tetratelabs/wazero/api"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// ErrABIUnavailable is returned for contracts whose functions can't be discovered
+var ErrABIUnavailable = errors.New("abi unavailable")
+
+// luaABIGlobal is the global a Lua contract may set to describe its functions
+const luaABIGlobal = "abi"
+
+// FunctionABI describes a function clients can execute. Params and Results
+// hold WASM value types ("i32", "i64", "f32", "f64") or the types a Lua
+// contract declares; they are empty when a Lua contract declares none.
+type FunctionABI struct {
+	Name     string   `json:"name"`
+	Params   []string `json:"params"`
+	Results  []string `json:"results,omitempty"`
+	ReadOnly bool     `json:"readOnly,omitempty"` // Declared not to write storage
+}
+
+// ContractABI lists the functions of a contract
+type ContractABI struct {
+	ContractID string        `json:"contractId"`
+	Type       string        `json:"type"`
+	Functions  []FunctionABI `json:"functions"`
+}
+
+// wasmABI lists a compiled module's exported functions, leaving out the
+// alloc and free helpers used to pass strings and bytes
+func wasmABI(definitions map[string]api.FunctionDefinition) []FunctionABI {
+	functions := make([]FunctionABI, 0, len(definitions))
+	for name, definition := range definitions {
+		if name == abiAlloc || name == abiFree {
+			continue
+		}
+		functions = append(functions, FunctionABI{
+			Name:    name,
+			Params:  valueTypeNames(definition.ParamTypes()),
+			Results: valueTypeNames(definition.ResultTypes()),
+		})
+	}
+	sortFunctions(functions)
+	return functions
+}
+
+// valueTypeNames names WASM value types
+func valueTypeNames(types []api.ValueType) []string {
+	names := make([]string, len(types))
+	for i, valueType := range types {
+		names[i] = api.ValueTypeName(valueType)
+	}
+	return names
+}
+
+// luaABI describes the functions a loaded Lua contract defines. A contract
+// may declare them in an abi table mapping each function name to a list of
+// parameter types, or to a table with params and returns lists and a
+// readonly flag:
+//
+//	abi = {
+//	  transfer = {"string", "number"},
+//	  balance = {params = {"string"}, returns = {"number"}, readonly = true},
+//	}
+//
+// Read-only functions may not write storage, and run concurrently with
+// other calls instead of queueing behind them. Without an abi table, every
+// global function the contract defines is listed with no types.
+func luaABI(env *lua.LTable) ([]FunctionABI, error) {
+	declared, ok := env.RawGetString(luaABIGlobal).(*lua.LTable)
+	if !ok {
+		var functions []FunctionABI
+		env.ForEach(func(key, value lua.LValue) {
+			if fn, ok := value.(*lua.LFunction); ok && !fn.IsG && key.Type() == lua.LTString {
+				functions = append(functions, FunctionABI{Name: key.String(), Params: []string{}})
+			}
+		})
+		sortFunctions(functions)
+		return functions, nil
+	}
+
+	var functions []FunctionABI
+	var err error
+	declared.ForEach(func(key, value lua.LValue) {
+		if err != nil {
+			return
+		}
+		name, isName := key.(lua.LString)
+		if !isName {
+			err = fmt.Errorf("abi: function names must be strings, got %s", key.Type().String())
+			return
+		}
+		if fn, isFunc := env.RawGetString(string(name)).(*lua.LFunction); !isFunc || fn.IsG {
+			err = fmt.Errorf("abi: %s is not a function of the contract", name)
+			return
+		}
+		var function FunctionABI
+		function, err = luaFunctionABI(string(name), value)
+		functions = append(functions, function)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortFunctions(functions)
+	return functions, nil
+}
+
+// luaFunctionABI reads the parameter and result types declared for a
+// function, and whether it is declared read-only
+func luaFunctionABI(name string, value lua.LValue) (FunctionABI, error) {
+	function := FunctionABI{Name: name, Params: []string{}}
+	spec, ok := value.(*lua.LTable)
+	if !ok {
+		return function, fmt.Errorf("abi: %s must be a table", name)
+	}
+	var err error
+	if spec.Len() > 0 {
+		function.Params, err = luaStrings(name, spec)
+		return function, err
+	}
+
+	if list, ok := spec.RawGetString("params").(*lua.LTable); ok {
+		if function.Params, err = luaStrings(name, list); err != nil {
+			return function, err
+		}
+	}
+	if list, ok := spec.RawGetString("returns").(*lua.LTable); ok {
+		if function.Results, err = luaStrings(name, list); err != nil {
+			return function, err
+		}
+	}
+	switch readOnly := spec.RawGetString("readonly").(type) {
+	case *lua.LNilType:
+	case lua.LBool:
+		function.ReadOnly = bool(readOnly)
+	default:
+		return function, fmt.Errorf("abi: readonly of %s must be a boolean", name)
+	}
+	return function, nil
+}
+
+// luaStrings reads a list of type names
+func luaStrings(name string, list *lua.LTable) ([]string, error) {
+	values := make([]string, 0, list.Len())
+	for i := 1; i <= list.Len(); i++ {
+		value, ok := list.RawGetInt(i).(lua.LString)
+		if !ok {
+			return nil, fmt.Errorf("abi: types of %s must be strings", name)
+		}
+		values = append(values, string(value))
+	}
+	return values, nil
+}
+
+// sortFunctions orders functions by name
+func sortFunctions(functions []FunctionABI) {
+	sort.Slice(functions, func(i, j int) bool {
+		return functions[i].Name < functions[j].Name
+	})
+}
@@ -0,0 +1,27 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotOwner is returned when a management operation is requested by
+// someone other than the contract's owner or an admin
+var ErrNotOwner = errors.New("not the contract owner")
+
+// Manager identifies who requests a management operation on a contract
+type Manager struct {
+	// Address is the requester's address, empty when they aren't known
+	Address string
+	// Admin lets the requester manage every contract
+	Admin bool
+}
+
+// authorize checks that the manager may manage a contract owned by owner.
+// Contracts deployed without an owner can only be managed by admins.
+func (m Manager) authorize(contractID, owner string) error {
+	if m.Admin || (owner != "" && m.Address == owner) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrNotOwner, contractID)
+}
@@ -0,0 +1,55 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrContractPaused is returned when executing a paused contract
+var ErrContractPaused = errors.New("contract paused")
+
+// Pause records why and when a contract's executions were suspended
+type Pause struct {
+	Reason   string    `json:"reason,omitempty"`
+	PausedBy string    `json:"pausedBy,omitempty"` // The owner's address, empty when an admin paused it
+	PausedAt time.Time `json:"pausedAt"`
+}
+
+// pauseStatus returns the status of a contract that may be paused
+func pauseStatus(pause *Pause) string {
+	if pause != nil {
+		return StatusPaused
+	}
+	return StatusActive
+}
+
+// pausedError reports an execution refused because its contract is paused
+func pausedError(id string, pause *Pause) error {
+	return fmt.Errorf("%w: %s since %s: %s", ErrContractPaused, id, pause.PausedAt.Format(time.RFC3339), pause.Reason)
+}
+
+// PauseContract suspends a contract's executions, including calls from
+// other contracts, on behalf of its owner or an admin. Its code, state and
+// ABI stay readable. Pausing a paused contract replaces the reason.
+func (r *ContractRegistry) PauseContract(manager Manager, id, reason string) (*Pause, error) {
+	engine, err := r.engineFor(id)
+	if err != nil {
+		return nil, err
+	}
+	pause := &Pause{Reason: reason, PausedBy: manager.Address, PausedAt: time.Now().UTC()}
+	if err := engine.SetPause(manager, id, pause); err != nil {
+		return nil, err
+	}
+	return pause, nil
+}
+
+// ResumeContract lets a paused contract execute again, on behalf of its
+// owner or an admin
+func (r *ContractRegistry) ResumeContract(manager Manager, id string) error {
+	engine, err := r.engineFor(id)
+	if err != nil {
+		return err
+	}
+	return engine.SetPause(manager, id, nil)
+}
@@ -0,0 +1,153 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// DefaultMaxQueueDepth bounds how many mutating calls of one contract may
+// run or wait at once
+const DefaultMaxQueueDepth = 64
+
+// Errors returned when the registry coordinates executions
+var (
+	ErrQueueFull      = errors.New("contract execution queue full")
+	ErrReadOnlyWrites = errors.New("read-only function wrote contract storage")
+)
+
+// executionQueue runs the mutating calls of one contract one at a time
+type executionQueue struct {
+	slot    chan struct{}
+	depth   atomic.Int64    // Calls running or waiting
+	observe func(depth int) // Told the depth when it changes, may be nil
+}
+
+// newExecutionQueue creates an idle queue
+func newExecutionQueue(observe func(depth int)) *executionQueue {
+	return &executionQueue{slot: make(chan struct{}, 1), observe: observe}
+}
+
+// add changes the depth by delta and tells the observer
+func (q *executionQueue) add(delta int64) {
+	depth := q.depth.Add(delta)
+	if q.observe != nil {
+		q.observe(int(depth))
+	}
+}
+
+// enter waits for the queue's turn, failing when maxDepth calls are already
+// running or waiting, or when ctx ends first
+func (q *executionQueue) enter(ctx context.Context, maxDepth int) error {
+	for {
+		depth := q.depth.Load()
+		if maxDepth > 0 && depth >= int64(maxDepth) {
+			return ErrQueueFull
+		}
+		if q.depth.CompareAndSwap(depth, depth+1) {
+			break
+		}
+	}
+	q.add(0) // Report the new depth
+
+	select {
+	case q.slot <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		q.add(-1)
+		return contextError(ctx)
+	}
+}
+
+// leave hands the turn to the next call
+func (q *executionQueue) leave() {
+	<-q.slot
+	q.add(-1)
+}
+
+// SetMaxQueueDepth sets how many mutating calls of one contract may run or
+// wait at once; further calls fail with ErrQueueFull. 0 means no limit.
+func (r *ContractRegistry) SetMaxQueueDepth(depth int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.maxQueueDepth = depth
+}
+
+// SetQueueObserver sets a function told each contract's queue depth
+// whenever it changes, such as a metrics gauge. It must be set before
+// contracts execute.
+func (r *ContractRegistry) SetQueueObserver(observer func(contractID string, depth int)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.observer = observer
+}
+
+// queue returns a contract's execution queue, creating it on first use,
+// along with the depth limit
+func (r *ContractRegistry) queue(id string) (*executionQueue, int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	queue, ok := r.queues[id]
+	if !ok {
+		var observe func(int)
+		if observer := r.observer; observer != nil {
+			observe = func(depth int) { observer(id, depth) }
+		}
+		queue = newExecutionQueue(observe)
+		r.queues[id] = queue
+	}
+	return queue, r.maxQueueDepth
+}
+
+// serialize runs a mutating call once every earlier one of the same
+// contract has finished. Calls into other contracts aren't queued, so a
+// call whose commit finds that another call changed what it read, in any
+// contract, runs again until it commits or ctx ends; each conflict means
+// another call committed, so the calls as a whole make progress.
+func (r *ContractRegistry) serialize(ctx context.Context, id string, run func() (*ExecutionResult, error)) (*ExecutionResult, error) {
+	queue, maxDepth := r.queue(id)
+	if err := queue.enter(ctx, maxDepth); err != nil {
+		if errors.Is(err, ErrQueueFull) {
+			err = fmt.Errorf("%w: %s has %d calls pending", err, id, maxDepth)
+		}
+		return nil, err
+	}
+	defer queue.leave()
+	for {
+		result, err := run()
+		if !errors.Is(err, ErrStateConflict) || ctx.Err() != nil {
+			return result, err
+		}
+	}
+}
+
+// readOnly reports whether a contract declares a function read-only
+func readOnly(engine ContractEngine, id, functionName string) bool {
+	abi, err := engine.ABI(id)
+	if err != nil {
+		return false
+	}
+	for _, function := range abi.Functions {
+		if function.Name == functionName {
+			return function.ReadOnly
+		}
+	}
+	return false
+}
+
+// executeReadOnly runs a read-only function without committing anything,
+// failing it if it writes storage
+func executeReadOnly(ctx context.Context, engine ContractEngine, execCtx ExecutionContext, id, functionName string, params []interface{}) (*ExecutionResult, error) {
+	result, err := engine.DryRun(ctx, execCtx, id, functionName, params...)
+	if err != nil {
+		return result, err
+	}
+	if len(result.StateDiff) > 0 {
+		err := fmt.Errorf("%w: %s.%s", ErrReadOnlyWrites, id, functionName)
+		return &ExecutionResult{GasUsed: result.GasUsed}, executionFailure(engine.Type(), err)
+	}
+	result.StateDiff = nil
+	return result, nil
+}
@@ -0,0 +1,181 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// counterContract counts calls of increment in storage, spinning for the
+// given number of iterations between reading and writing the count so
+// concurrent calls overlap
+const counterContract = `
+spin = 0
+
+abi = {
+  increment = {returns = {"number"}},
+  get = {returns = {"number"}, readonly = true},
+}
+
+function increment()
+  local n = tonumber(storage_get("n") or "0") + 1
+  for i = 1, spin do end
+  storage_set("n", tostring(n))
+  return n
+end
+
+function get()
+  return tonumber(storage_get("n") or "0")
+end
+`
+
+// forwarderContract increments the counter contract through a call
+const forwarderContract = `
+function increment()
+  return call("counter", "increment")
+end
+`
+
+// newTestRegistry creates a registry over connected Lua and WASM engines
+// with the given Lua contracts deployed, by ID
+func newTestRegistry(t *testing.T, contracts map[string]string) *ContractRegistry {
+	t.Helper()
+	lua, wasm := NewLuaEngine(), NewWASMEngine()
+	NewDispatcher(wasm, lua)
+	registry := NewContractRegistry(lua, wasm)
+	for id, code := range contracts {
+		if err := registry.Deploy(TypeLua, id, id, "owner", []byte(code)); err != nil {
+			t.Fatalf("deploying %s: %v", id, err)
+		}
+	}
+	return registry
+}
+
+// counterValue reads the counter contract's count
+func counterValue(t *testing.T, registry *ContractRegistry) float64 {
+	t.Helper()
+	result, err := registry.Execute(context.Background(), ExecutionContext{}, "counter", "get")
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, _ := result.Value.(float64)
+	return count
+}
+
+func TestConcurrentIncrementsAreSerialized(t *testing.T) {
+	registry := newTestRegistry(t, map[string]string{"counter": counterContract})
+	registry.SetMaxQueueDepth(0)
+
+	const calls = 100
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			started := time.Now()
+			_, err := registry.Execute(ctx, ExecutionContext{}, "counter", "get")
+			cancel()
+			if err != nil {
+				t.Errorf("read-only call failed: %v", err)
+				return
+			}
+			if elapsed := time.Since(started); elapsed > time.Second {
+				t.Errorf("read-only call blocked for %v", elapsed)
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := registry.Execute(context.Background(), ExecutionContext{}, "counter", "increment"); err != nil {
+				t.Errorf("increment failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	readers.Wait()
+
+	if count := counterValue(t, registry); count != calls {
+		t.Fatalf("count is %v after %d increments", count, calls)
+	}
+}
+
+func TestConcurrentCallsThroughDifferentRootsDontLoseWrites(t *testing.T) {
+	registry := newTestRegistry(t, map[string]string{
+		"counter": strings.Replace(counterContract, "spin = 0", "spin = 100000", 1),
+		"a":       forwarderContract,
+		"b":       forwarderContract,
+	})
+	registry.SetMaxQueueDepth(0)
+
+	const calls = 20
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		for _, root := range []string{"a", "b", "counter"} {
+			wg.Add(1)
+			go func(root string) {
+				defer wg.Done()
+				if _, err := registry.Execute(context.Background(), ExecutionContext{}, root, "increment"); err != nil {
+					t.Errorf("increment through %s failed: %v", root, err)
+				}
+			}(root)
+		}
+	}
+	wg.Wait()
+
+	if count := counterValue(t, registry); count != 3*calls {
+		t.Fatalf("count is %v after %d increments", count, 3*calls)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	registry := newTestRegistry(t, map[string]string{"counter": counterContract})
+	registry.SetMaxQueueDepth(1)
+
+	queue, maxDepth := registry.queue("counter")
+	if err := queue.enter(context.Background(), maxDepth); err != nil {
+		t.Fatal(err)
+	}
+	defer queue.leave()
+
+	_, err := registry.Execute(context.Background(), ExecutionContext{}, "counter", "increment")
+	if !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("got %v, want ErrQueueFull", err)
+	}
+}
+
+func TestCommitDetectsConflictingWrite(t *testing.T) {
+	store := NewMemoryStateStore()
+	store.Set("counter", "n", []byte("1"))
+
+	tree := newCallTree(nil, func() {})
+	tx := tree.state(store, "counter")
+	if value, _ := tx.get("n"); string(value) != "1" {
+		t.Fatalf("read %q", value)
+	}
+	tx.set("n", []byte("2"))
+
+	// Another execution commits first
+	store.Set("counter", "n", []byte("5"))
+
+	if err := tree.commit(); !errors.Is(err, ErrStateConflict) {
+		t.Fatalf("got %v, want ErrStateConflict", err)
+	}
+	if value, _ := store.Get("counter", "n"); string(value) != "5" {
+		t.Fatalf("conflicting commit overwrote the value with %q", value)
+	}
+}
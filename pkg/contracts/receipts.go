@@ -0,0 +1,68 @@
+package contracts
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultReceiptCapacity is how many receipts a ReceiptStore keeps by default
+const DefaultReceiptCapacity = 1000
+
+// Receipt records the outcome of a contract execution. Failed executions
+// carry the structured Failure, whose message and code Error and ErrorCode
+// repeat, instead of a result and events.
+type Receipt struct {
+	ID         string          `json:"id"`
+	ContractID string          `json:"contractId"`
+	Function   string          `json:"function"`
+	Caller     string          `json:"caller,omitempty"`
+	Result     interface{}     `json:"result"`
+	GasUsed    uint64          `json:"gasUsed,omitempty"`
+	Events     []ContractEvent `json:"events,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	ErrorCode  string          `json:"errorCode,omitempty"`
+	Failure    *ExecutionError `json:"failure,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+// ReceiptStore keeps the most recent execution receipts in memory, dropping
+// the oldest once full
+type ReceiptStore struct {
+	receipts map[string]Receipt
+	order    []string
+	capacity int
+	mutex    sync.RWMutex
+}
+
+// NewReceiptStore creates a store holding up to capacity receipts
+func NewReceiptStore(capacity int) *ReceiptStore {
+	return &ReceiptStore{
+		receipts: make(map[string]Receipt),
+		capacity: capacity,
+	}
+}
+
+// Add stores a receipt, evicting the oldest when the store is full
+func (s *ReceiptStore) Add(receipt Receipt) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.receipts[receipt.ID]; !exists {
+		s.order = append(s.order, receipt.ID)
+	}
+	s.receipts[receipt.ID] = receipt
+
+	for len(s.order) > s.capacity {
+		delete(s.receipts, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// Get returns a receipt by ID
+func (s *ReceiptStore) Get(id string) (Receipt, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	receipt, ok := s.receipts[id]
+	return receipt, ok
+}
@@ -0,0 +1,220 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Both engines implement the common engine interface
+var (
+	_ ContractEngine = (*WASMEngine)(nil)
+	_ ContractEngine = (*LuaEngine)(nil)
+)
+
+// Errors returned by the contract registry
+var (
+	ErrUnknownContractType = errors.New("unsupported contract type")
+	ErrContractExists      = errors.New("contract already exists")
+)
+
+// ContractRegistry routes contract operations to the engine that holds each
+// contract. Contracts must be deployed and removed through the registry for
+// it to know them, and engines must have distinct types.
+//
+// Executions through the registry that may write storage run one at a time
+// per contract, so concurrent calls can't interleave their reads and
+// writes. Functions a contract declares read-only, and dry runs, run
+// concurrently against a snapshot of the contract's storage.
+type ContractRegistry struct {
+	engines       map[string]ContractEngine  // Engines by contract type
+	owners        map[string]ContractEngine  // The engine holding each contract ID
+	queues        map[string]*executionQueue // Mutating calls of each contract
+	maxQueueDepth int
+	observer      func(contractID string, depth int)
+	mutex         sync.RWMutex
+}
+
+// NewContractRegistry creates a registry routing to the given engines
+func NewContractRegistry(engines ...ContractEngine) *ContractRegistry {
+	r := &ContractRegistry{
+		engines:       make(map[string]ContractEngine),
+		owners:        make(map[string]ContractEngine),
+		queues:        make(map[string]*executionQueue),
+		maxQueueDepth: DefaultMaxQueueDepth,
+	}
+	for _, engine := range engines {
+		r.Register(engine)
+	}
+	return r
+}
+
+// Register adds an engine, replacing any of the same type, and indexes the
+// contracts it already holds
+func (r *ContractRegistry) Register(engine ContractEngine) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.engines[engine.Type()] = engine
+	r.index(engine)
+}
+
+// index records which contracts an engine holds. The caller must hold the
+// write lock.
+func (r *ContractRegistry) index(engine ContractEngine) {
+	for _, info := range engine.ListContractInfo() {
+		r.owners[info.ID] = engine
+	}
+}
+
+// Engine returns the engine running contracts of a type
+func (r *ContractRegistry) Engine(contractType string) (ContractEngine, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	engine, ok := r.engines[contractType]
+	return engine, ok
+}
+
+// engineFor returns the engine holding a contract
+func (r *ContractRegistry) engineFor(id string) (ContractEngine, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	engine, ok := r.owners[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrContractNotFound, id)
+	}
+	return engine, nil
+}
+
+// LoadFrom reloads every engine's contracts from store and persists later
+// changes to it
+func (r *ContractRegistry) LoadFrom(store ContractStore) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, engine := range r.engines {
+		if err := engine.LoadFrom(store); err != nil {
+			return err
+		}
+		r.index(engine)
+	}
+	return nil
+}
+
+// Deploy deploys a contract to the engine for its type
+func (r *ContractRegistry) Deploy(contractType, id, name, owner string, code []byte) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	engine, ok := r.engines[contractType]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownContractType, contractType)
+	}
+	if _, exists := r.owners[id]; exists {
+		return fmt.Errorf("%w: %s", ErrContractExists, id)
+	}
+	if err := engine.Deploy(id, name, owner, code); err != nil {
+		return err
+	}
+	r.owners[id] = engine
+	return nil
+}
+
+// Execute runs a function of a contract in the given chain context. Calls
+// of functions the contract doesn't declare read-only wait for the
+// contract's earlier ones, and fail with ErrQueueFull when too many are
+// waiting.
+func (r *ContractRegistry) Execute(ctx context.Context, execCtx ExecutionContext, id, functionName string, params ...interface{}) (result *ExecutionResult, err error) {
+	engine, err := r.engineFor(id)
+	if err != nil {
+		return nil, err
+	}
+	ctx, span := startExecutionSpan(ctx, "contracts.execute", engine, id, functionName)
+	defer func() { endExecutionSpan(span, result, err) }()
+
+	if readOnly(engine, id, functionName) {
+		return executeReadOnly(ctx, engine, execCtx, id, functionName, params)
+	}
+	return r.serialize(ctx, id, func() (*ExecutionResult, error) {
+		return engine.ExecuteContractCtx(ctx, execCtx, id, functionName, params...)
+	})
+}
+
+// DryRun runs a function of a contract without committing its state writes
+func (r *ContractRegistry) DryRun(ctx context.Context, execCtx ExecutionContext, id, functionName string, params ...interface{}) (result *ExecutionResult, err error) {
+	engine, err := r.engineFor(id)
+	if err != nil {
+		return nil, err
+	}
+	ctx, span := startExecutionSpan(ctx, "contracts.dry_run", engine, id, functionName)
+	defer func() { endExecutionSpan(span, result, err) }()
+
+	return engine.DryRun(ctx, execCtx, id, functionName, params...)
+}
+
+// GetContract describes a contract
+func (r *ContractRegistry) GetContract(id string) (ContractInfo, error) {
+	engine, err := r.engineFor(id)
+	if err != nil {
+		return ContractInfo{}, err
+	}
+	return engine.ContractInfo(id)
+}
+
+// ListContracts describes the contracts of every engine, oldest first
+func (r *ContractRegistry) ListContracts() []ContractInfo {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var infos []ContractInfo
+	for _, engine := range r.engines {
+		infos = append(infos, engine.ListContractInfo()...)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if !infos[i].CreatedAt.Equal(infos[j].CreatedAt) {
+			return infos[i].CreatedAt.Before(infos[j].CreatedAt)
+		}
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
+
+// ABI describes the functions a contract can execute
+func (r *ContractRegistry) ABI(id string) (*ContractABI, error) {
+	engine, err := r.engineFor(id)
+	if err != nil {
+		return nil, err
+	}
+	return engine.ABI(id)
+}
+
+// RemoveContract deletes a contract on behalf of its owner or an admin
+func (r *ContractRegistry) RemoveContract(manager Manager, id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	engine, ok := r.owners[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrContractNotFound, id)
+	}
+	if err := engine.RemoveContractAs(manager, id); err != nil {
+		return err
+	}
+	delete(r.owners, id)
+	delete(r.queues, id)
+	return nil
+}
+
+// TransferOwnership hands a contract to a new owner on behalf of its
+// current owner or an admin
+func (r *ContractRegistry) TransferOwnership(manager Manager, id, newOwner string) error {
+	engine, err := r.engineFor(id)
+	if err != nil {
+		return err
+	}
+	return engine.TransferOwnership(manager, id, newOwner)
+}
@@ -0,0 +1,117 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// echoEngine is a third engine type whose contracts return their own code
+type echoEngine struct {
+	ContractEngine // Operations the tests don't reach
+	contracts      map[string]ContractInfo
+	code           map[string]string
+}
+
+func newEchoEngine() *echoEngine {
+	return &echoEngine{contracts: make(map[string]ContractInfo), code: make(map[string]string)}
+}
+
+func (e *echoEngine) Type() string { return "echo" }
+
+func (e *echoEngine) Deploy(id, name, owner string, code []byte) error {
+	e.contracts[id] = ContractInfo{ID: id, Name: name, Type: e.Type(), Owner: owner, Status: StatusActive, CreatedAt: time.Now()}
+	e.code[id] = string(code)
+	return nil
+}
+
+func (e *echoEngine) ExecuteContractCtx(ctx context.Context, execCtx ExecutionContext, contractID, functionName string, params ...interface{}) (*ExecutionResult, error) {
+	return &ExecutionResult{Value: e.code[contractID]}, nil
+}
+
+func (e *echoEngine) ContractInfo(id string) (ContractInfo, error) { return e.contracts[id], nil }
+
+func (e *echoEngine) ListContractInfo() []ContractInfo {
+	infos := make([]ContractInfo, 0, len(e.contracts))
+	for _, info := range e.contracts {
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func (e *echoEngine) ABI(id string) (*ContractABI, error) { return nil, ErrABIUnavailable }
+
+func TestRegistryRoutesByContractType(t *testing.T) {
+	registry := NewContractRegistry(NewLuaEngine(), NewWASMEngine(), newEchoEngine())
+	deploys := []struct {
+		contractType, id string
+		code             []byte
+	}{
+		{TypeLua, "lua", []byte("function get() return 'lua' end")},
+		{TypeWASM, "wasm", counterModule()},
+		{"echo", "echo", []byte("echoed")},
+	}
+	for _, d := range deploys {
+		if err := registry.Deploy(d.contractType, d.id, d.id, "", d.code); err != nil {
+			t.Fatalf("deploying %s: %v", d.id, err)
+		}
+		time.Sleep(time.Millisecond) // Distinct creation times order the listing
+	}
+
+	want := map[string]interface{}{"lua": "lua", "wasm": int32(1), "echo": "echoed"}
+	functions := map[string]string{"lua": "get", "wasm": "bump", "echo": "anything"}
+	for id, value := range want {
+		result, err := registry.Execute(context.Background(), ExecutionContext{}, id, functions[id])
+		if err != nil || result.Value != value {
+			t.Errorf("%s returned %+v, %v, want %v", id, result, err, value)
+		}
+	}
+
+	infos := registry.ListContracts()
+	if len(infos) != 3 {
+		t.Fatalf("listed %d contracts, want 3", len(infos))
+	}
+	for i, d := range deploys {
+		if infos[i].ID != d.id || infos[i].Type != d.contractType {
+			t.Errorf("listing[%d] = %s (%s), want %s (%s)", i, infos[i].ID, infos[i].Type, d.id, d.contractType)
+		}
+	}
+}
+
+func TestRegistryRejectsUnknownTypesAndDuplicateIDs(t *testing.T) {
+	registry := NewContractRegistry(NewLuaEngine(), NewWASMEngine())
+	if err := registry.Deploy("python", "py", "py", "", []byte("pass")); !errors.Is(err, ErrUnknownContractType) {
+		t.Fatalf("unknown type: got %v", err)
+	}
+	if err := registry.Deploy(TypeLua, "taken", "taken", "", []byte("function get() end")); err != nil {
+		t.Fatal(err)
+	}
+	// IDs are unique across engines
+	if err := registry.Deploy(TypeWASM, "taken", "taken", "", counterModule()); !errors.Is(err, ErrContractExists) {
+		t.Fatalf("duplicate ID: got %v", err)
+	}
+}
+
+func TestRegistryUnknownContract(t *testing.T) {
+	registry := NewContractRegistry(NewLuaEngine(), NewWASMEngine())
+	admin := Manager{Admin: true}
+
+	_, executeErr := registry.Execute(context.Background(), ExecutionContext{}, "missing", "get")
+	_, dryRunErr := registry.DryRun(context.Background(), ExecutionContext{}, "missing", "get")
+	_, infoErr := registry.GetContract("missing")
+	_, abiErr := registry.ABI("missing")
+	errs := map[string]error{
+		"Execute":           executeErr,
+		"DryRun":            dryRunErr,
+		"GetContract":       infoErr,
+		"ABI":               abiErr,
+		"RemoveContract":    registry.RemoveContract(admin, "missing"),
+		"TransferOwnership": registry.TransferOwnership(admin, "missing", "new"),
+	}
+	for operation, err := range errs {
+		if !errors.Is(err, ErrContractNotFound) {
+			t.Errorf("%s: got %v, want ErrContractNotFound", operation, err)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package contracts
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrStateConflict is returned when storage an execution read was changed
+// by another execution before its writes were committed, so none were.
+// ContractRegistry.Execute runs such executions again.
+var ErrStateConflict = errors.New("contract state changed during execution")
+
+// StateStore holds the persistent key-value storage of each contract
+type StateStore interface {
+	// Get returns the value stored under key by a contract
+	Get(contractID, key string) ([]byte, bool)
+
+	// Set stores a value under key for a contract
+	Set(contractID, key string, value []byte)
+}
+
+// SnapshotStateStore is a StateStore that applies a contract's writes at
+// once and captures a contract's storage as of a moment, so an execution
+// never sees part of another's commit
+type SnapshotStateStore interface {
+	StateStore
+
+	// SetAll stores several values for a contract at once
+	SetAll(contractID string, values map[string][]byte)
+
+	// Snapshot returns a contract's storage as of now, which later writes
+	// don't change. It must not be modified.
+	Snapshot(contractID string) map[string][]byte
+}
+
+// MemoryStateStore is an in-memory SnapshotStateStore. Writes replace a
+// contract's storage with an updated copy, so snapshots are free.
+type MemoryStateStore struct {
+	values map[string]map[string][]byte
+	mutex  sync.RWMutex
+}
+
+// NewMemoryStateStore creates an empty in-memory state store
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{values: make(map[string]map[string][]byte)}
+}
+
+// Get returns the value stored under key by a contract
+func (s *MemoryStateStore) Get(contractID, key string) ([]byte, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	value, ok := s.values[contractID][key]
+	return value, ok
+}
+
+// Set stores a value under key for a contract
+func (s *MemoryStateStore) Set(contractID, key string, value []byte) {
+	s.SetAll(contractID, map[string][]byte{key: value})
+}
+
+// SetAll stores several values for a contract at once
+func (s *MemoryStateStore) SetAll(contractID string, values map[string][]byte) {
+	if len(values) == 0 {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	current := s.values[contractID]
+	updated := make(map[string][]byte, len(current)+len(values))
+	for key, value := range current {
+		updated[key] = value
+	}
+	for key, value := range values {
+		updated[key] = append([]byte(nil), value...)
+	}
+	s.values[contractID] = updated
+}
+
+// Snapshot returns a contract's storage as of now
+func (s *MemoryStateStore) Snapshot(contractID string) map[string][]byte {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.values[contractID]
+}
+
+// stateTx buffers one execution's writes to a contract's state so they are
+// applied only if the execution succeeds. Reads see the buffered writes,
+// and otherwise the storage as of the transaction's start when the store
+// takes snapshots. The stored values it reads are recorded, so a commit can
+// tell whether another execution changed them meanwhile.
+type stateTx struct {
+	store      StateStore
+	snapshot   map[string][]byte // nil unless the store takes snapshots
+	contractID string
+	writes     map[string][]byte
+	reads      map[string]storedValue
+	mutex      sync.Mutex
+}
+
+// storedValue is a value as a transaction read it from storage
+type storedValue struct {
+	value []byte
+	ok    bool
+}
+
+// newStateTx starts a state transaction over a contract's storage
+func newStateTx(store StateStore, contractID string) *stateTx {
+	tx := &stateTx{store: store, contractID: contractID, writes: make(map[string][]byte), reads: make(map[string]storedValue)}
+	if snapshots, ok := store.(SnapshotStateStore); ok {
+		tx.snapshot = snapshots.Snapshot(contractID)
+		if tx.snapshot == nil {
+			tx.snapshot = map[string][]byte{}
+		}
+	}
+	return tx
+}
+
+// get returns a value, preferring this transaction's own writes, and
+// records values read from storage
+func (tx *stateTx) get(key string) ([]byte, bool) {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	if value, ok := tx.writes[key]; ok {
+		return value, true
+	}
+	value, ok := tx.stored(key)
+	if _, read := tx.reads[key]; !read {
+		tx.reads[key] = storedValue{value: value, ok: ok}
+	}
+	return value, ok
+}
+
+// stored returns a value as the transaction found it
+func (tx *stateTx) stored(key string) ([]byte, bool) {
+	if tx.snapshot != nil {
+		value, ok := tx.snapshot[key]
+		return value, ok
+	}
+	return tx.store.Get(tx.contractID, key)
+}
+
+// set buffers a write
+func (tx *stateTx) set(key string, value []byte) {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	tx.writes[key] = append([]byte(nil), value...)
+}
+
+// conflicts reports whether a value the transaction read has changed in the
+// store since
+func (tx *stateTx) conflicts() bool {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	for key, read := range tx.reads {
+		value, ok := tx.store.Get(tx.contractID, key)
+		if ok != read.ok || !bytes.Equal(value, read.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// commit applies the buffered writes to the store, at once when it
+// supports that
+func (tx *stateTx) commit() {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	if snapshots, ok := tx.store.(SnapshotStateStore); ok {
+		snapshots.SetAll(tx.contractID, tx.writes)
+	} else {
+		for key, value := range tx.writes {
+			tx.store.Set(tx.contractID, key, value)
+		}
+	}
+	tx.writes = make(map[string][]byte)
+}
+
+// StateChange is a storage key an execution changed. Values are shown as
+// strings; Old is nil when the key was unset.
+type StateChange struct {
+	ContractID string  `json:"contractId"`
+	Key        string  `json:"key"`
+	Old        *string `json:"old"`
+	New        string  `json:"new"`
+}
+
+// diff returns the buffered writes that change the stored values, by key
+func (tx *stateTx) diff() []StateChange {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+
+	changes := make([]StateChange, 0, len(tx.writes))
+	for key, value := range tx.writes {
+		change := StateChange{ContractID: tx.contractID, Key: key, New: string(value)}
+		if old, ok := tx.stored(key); ok {
+			if bytes.Equal(old, value) {
+				continue
+			}
+			oldValue := string(old)
+			change.Old = &oldValue
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
@@ -0,0 +1,107 @@
+package contracts
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Contract statuses reported by the contract listings
+const (
+	StatusActive = "active"
+	StatusPaused = "paused"
+	StatusFailed = "failed"
+)
+
+// StoredContract is the persisted form of a deployed contract
+type StoredContract struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"` // "wasm" or "lua"
+	Owner     string    `json:"owner,omitempty"`
+	Code      []byte    `json:"code"`
+	Pause     *Pause    `json:"pause,omitempty"` // Set while executions are suspended
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ContractStore persists deployed contracts so engines can reload them
+type ContractStore interface {
+	// SaveContract stores a contract, replacing any with the same ID
+	SaveContract(contract StoredContract) error
+
+	// DeleteContract removes a contract
+	DeleteContract(id string) error
+
+	// ListContracts returns every stored contract
+	ListContracts() ([]StoredContract, error)
+}
+
+// FailedContract is a stored contract that could not be reloaded
+type FailedContract struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Owner     string    `json:"owner,omitempty"`
+	Error     string    `json:"error"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// failedContract records why a stored contract could not be reloaded
+func failedContract(c StoredContract, err error) FailedContract {
+	return FailedContract{ID: c.ID, Name: c.Name, Type: c.Type, Owner: c.Owner, Error: err.Error(), CreatedAt: c.CreatedAt}
+}
+
+// info describes the failed contract
+func (f FailedContract) info() ContractInfo {
+	return ContractInfo{ID: f.ID, Name: f.Name, Type: f.Type, Owner: f.Owner, Status: StatusFailed, Error: f.Error, CreatedAt: f.CreatedAt}
+}
+
+// MemoryContractStore is an in-memory ContractStore
+type MemoryContractStore struct {
+	contracts map[string]StoredContract
+	mutex     sync.RWMutex
+}
+
+// NewMemoryContractStore creates an empty in-memory contract store
+func NewMemoryContractStore() *MemoryContractStore {
+	return &MemoryContractStore{contracts: make(map[string]StoredContract)}
+}
+
+// SaveContract stores a contract, replacing any with the same ID
+func (s *MemoryContractStore) SaveContract(contract StoredContract) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	contract.Code = append([]byte(nil), contract.Code...)
+	s.contracts[contract.ID] = contract
+	return nil
+}
+
+// DeleteContract removes a contract
+func (s *MemoryContractStore) DeleteContract(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.contracts[id]; !exists {
+		return errors.New("contract not found")
+	}
+	delete(s.contracts, id)
+	return nil
+}
+
+// ListContracts returns every stored contract, oldest first
+func (s *MemoryContractStore) ListContracts() ([]StoredContract, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	contracts := make([]StoredContract, 0, len(s.contracts))
+	for _, contract := range s.contracts {
+		contract.Code = append([]byte(nil), contract.Code...)
+		contracts = append(contracts, contract)
+	}
+	sort.Slice(contracts, func(i, j int) bool {
+		return contracts[i].CreatedAt.Before(contracts[j].CreatedAt)
+	})
+	return contracts, nil
+}
@@ -0,0 +1,126 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// newStoredRegistry returns a registry with fresh engines loaded from store
+func newStoredRegistry(t *testing.T, store ContractStore) *ContractRegistry {
+	t.Helper()
+	registry := NewContractRegistry(NewWASMEngine(), NewLuaEngine())
+	if err := registry.LoadFrom(store); err != nil {
+		t.Fatal(err)
+	}
+	return registry
+}
+
+func TestContractsReloadFromStore(t *testing.T) {
+	store := NewMemoryContractStore()
+	registry := newStoredRegistry(t, store)
+	if err := registry.Deploy(TypeWASM, "wasm", "wasm", "alice", arithmeticModule()); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Deploy(TypeLua, "lua", "lua", "alice", []byte(leakContract)); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := newStoredRegistry(t, store)
+	wasm, err := reloaded.Execute(context.Background(), ExecutionContext{}, "wasm", "add", 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lua, err := reloaded.Execute(context.Background(), ExecutionContext{}, "lua", "add", 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wasm.Value != int32(5) || lua.Value != float64(5) {
+		t.Fatalf("reloaded contracts returned %v and %v", wasm.Value, lua.Value)
+	}
+	if info, err := reloaded.GetContract("wasm"); err != nil || info.Owner != "alice" || info.Status != StatusActive {
+		t.Fatalf("reloaded contract %+v, %v", info, err)
+	}
+}
+
+func TestRemovedContractsStayRemoved(t *testing.T) {
+	store := NewMemoryContractStore()
+	registry := newStoredRegistry(t, store)
+	if err := registry.Deploy(TypeLua, "lua", "lua", "alice", []byte(leakContract)); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.RemoveContract(Manager{Address: "alice"}, "lua"); err != nil {
+		t.Fatal(err)
+	}
+
+	if infos := newStoredRegistry(t, store).ListContracts(); len(infos) != 0 {
+		t.Fatalf("removed contract came back: %+v", infos)
+	}
+}
+
+func TestCorruptStoredContractIsListedAsFailed(t *testing.T) {
+	store := NewMemoryContractStore()
+	registry := newStoredRegistry(t, store)
+	if err := registry.Deploy(TypeLua, "lua", "lua", "alice", []byte(leakContract)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveContract(StoredContract{ID: "broken", Name: "broken", Type: TypeWASM, Code: []byte("not wasm")}); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := newStoredRegistry(t, store)
+	infos := reloaded.ListContracts()
+	if len(infos) != 2 {
+		t.Fatalf("listed %d contracts, want the good and the failed one", len(infos))
+	}
+	for _, info := range infos {
+		switch info.ID {
+		case "broken":
+			if info.Status != StatusFailed || info.Error == "" {
+				t.Errorf("corrupt contract listed as %+v", info)
+			}
+		case "lua":
+			if info.Status != StatusActive {
+				t.Errorf("good contract listed as %+v", info)
+			}
+		}
+	}
+	if _, err := reloaded.Execute(context.Background(), ExecutionContext{}, "lua", "add", 1, 1); err != nil {
+		t.Fatalf("good contract didn't run after a failed reload: %v", err)
+	}
+}
+
+func TestPauseSurvivesReload(t *testing.T) {
+	store := NewMemoryContractStore()
+	registry := newStoredRegistry(t, store)
+	for _, contractType := range []string{TypeWASM, TypeLua} {
+		code := arithmeticModule()
+		if contractType == TypeLua {
+			code = []byte(leakContract)
+		}
+		if err := registry.Deploy(contractType, contractType, contractType, "alice", code); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := registry.PauseContract(Manager{Address: "alice"}, contractType, "audit"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reloaded := newStoredRegistry(t, store)
+	for _, id := range []string{TypeWASM, TypeLua} {
+		info, err := reloaded.GetContract(id)
+		if err != nil || info.Status != StatusPaused || info.Pause == nil || info.Pause.Reason != "audit" {
+			t.Fatalf("reloaded %s as %+v, %v", id, info, err)
+		}
+		_, err = reloaded.Execute(context.Background(), ExecutionContext{}, id, "add", 2, 3)
+		if !errors.Is(err, ErrContractPaused) {
+			t.Fatalf("executing reloaded %s: got %v, want ErrContractPaused", id, err)
+		}
+		if err := reloaded.ResumeContract(Manager{Address: "alice"}, id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := reloaded.Execute(context.Background(), ExecutionContext{}, id, "add", 2, 3); err != nil {
+			t.Fatalf("executing resumed %s: %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+-- Standard fungible token. TokenSource prepends the configuration:
+--
+--   local TOKEN_NAME, TOKEN_SYMBOL, TOKEN_INITIAL_SUPPLY = ...
+--
+-- Balances and allowances live in contract storage as decimal strings.
+-- Amounts are whole numbers no larger than 2^53, the largest integer a Lua
+-- number holds exactly.
+
+local MAX_AMOUNT = 9007199254740992
+
+abi = {
+  init = {params = {}, returns = {"boolean"}},
+  name = {params = {}, returns = {"string"}, readonly = true},
+  symbol = {params = {}, returns = {"string"}, readonly = true},
+  totalSupply = {params = {}, returns = {"number"}, readonly = true},
+  balanceOf = {params = {"string"}, returns = {"number"}, readonly = true},
+  allowance = {params = {"string", "string"}, returns = {"number"}, readonly = true},
+  mint = {params = {"string", "number"}, returns = {"boolean"}},
+  transfer = {params = {"string", "number"}, returns = {"boolean"}},
+  approve = {params = {"string", "number"}, returns = {"boolean"}},
+  transferFrom = {params = {"string", "string", "number"}, returns = {"boolean"}},
+}
+
+local function read(key)
+  return tonumber(storage_get(key) or "0")
+end
+
+local function write(key, amount)
+  storage_set(key, string.format("%.0f", amount))
+end
+
+local function balanceKey(account)
+  return "balance:" .. account
+end
+
+local function allowanceKey(owner, spender)
+  return "allowance:" .. owner .. ":" .. spender
+end
+
+local function checkAccount(account, what)
+  if type(account) ~= "string" or account == "" then
+    error(what .. " must be an address")
+  end
+end
+
+local function checkAmount(amount)
+  if type(amount) ~= "number" or amount < 0 or amount ~= math.floor(amount) or amount > MAX_AMOUNT then
+    error("amount must be a whole number between 0 and " .. string.format("%.0f", MAX_AMOUNT))
+  end
+end
+
+local function sender()
+  if msg.sender == "" then
+    error("the call must be signed")
+  end
+  return msg.sender
+end
+
+local function move(from, to, amount)
+  checkAccount(to, "recipient")
+  checkAmount(amount)
+  local balance = read(balanceKey(from))
+  if balance < amount then
+    error("insufficient balance")
+  end
+  write(balanceKey(from), balance - amount)
+  write(balanceKey(to), read(balanceKey(to)) + amount)
+  emit("Transfer", {from = from, to = to, value = amount})
+end
+
+local function issue(to, amount)
+  checkAccount(to, "recipient")
+  checkAmount(amount)
+  local supply = read("totalSupply")
+  if supply + amount > MAX_AMOUNT then
+    error("total supply would exceed " .. string.format("%.0f", MAX_AMOUNT))
+  end
+  write("totalSupply", supply + amount)
+  write(balanceKey(to), read(balanceKey(to)) + amount)
+  emit("Transfer", {from = "", to = to, value = amount})
+end
+
+-- init mints the initial supply to the owner. It runs once, right after
+-- the token is deployed.
+function init()
+  if sender() ~= contract.owner then
+    error("only the owner may initialize the token")
+  end
+  if storage_get("initialized") then
+    error("token already initialized")
+  end
+  storage_set("initialized", "true")
+  if TOKEN_INITIAL_SUPPLY > 0 then
+    issue(contract.owner, TOKEN_INITIAL_SUPPLY)
+  end
+  return true
+end
+
+function name()
+  return TOKEN_NAME
+end
+
+function symbol()
+  return TOKEN_SYMBOL
+end
+
+function totalSupply()
+  return read("totalSupply")
+end
+
+function balanceOf(account)
+  checkAccount(account, "account")
+  return read(balanceKey(account))
+end
+
+function allowance(owner, spender)
+  checkAccount(owner, "owner")
+  checkAccount(spender, "spender")
+  return read(allowanceKey(owner, spender))
+end
+
+function mint(to, amount)
+  if sender() ~= contract.owner then
+    error("only the owner may mint")
+  end
+  issue(to, amount)
+  return true
+end
+
+function transfer(to, amount)
+  move(sender(), to, amount)
+  return true
+end
+
+function approve(spender, amount)
+  local owner = sender()
+  checkAccount(spender, "spender")
+  checkAmount(amount)
+  write(allowanceKey(owner, spender), amount)
+  emit("Approval", {owner = owner, spender = spender, value = amount})
+  return true
+end
+
+function transferFrom(from, to, amount)
+  local spender = sender()
+  checkAccount(from, "owner")
+  checkAmount(amount)
+  local allowed = read(allowanceKey(from, spender))
+  if allowed < amount then
+    error("insufficient allowance")
+  end
+  move(from, to, amount)
+  write(allowanceKey(from, spender), allowed - amount)
+  return true
+end
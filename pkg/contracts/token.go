@@ -0,0 +1,109 @@
+package contracts
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// tokenTemplate is the Lua source of the standard token contract
+//
+//go:embed templates/token.lua
+var tokenTemplate string
+
+// Limits on token template configurations
+const (
+	maxTokenNameLength   = 64
+	maxTokenSymbolLength = 16
+	// MaxTokenSupply is the largest supply a token may reach, the largest
+	// integer a Lua number holds exactly
+	MaxTokenSupply = 1 << 53
+)
+
+// ErrInvalidTokenConfig is returned for token configurations that can't be deployed
+var ErrInvalidTokenConfig = errors.New("invalid token configuration")
+
+// TokenConfig configures an instance of the standard token contract
+type TokenConfig struct {
+	Name          string `json:"name"`
+	Symbol        string `json:"symbol"`
+	InitialSupply uint64 `json:"initialSupply"` // Minted to the owner at deploy
+}
+
+// validate checks that a token configuration can be deployed
+func (c TokenConfig) validate() error {
+	switch {
+	case c.Name == "" || len(c.Name) > maxTokenNameLength:
+		return fmt.Errorf("%w: name must be 1 to %d bytes", ErrInvalidTokenConfig, maxTokenNameLength)
+	case c.Symbol == "" || len(c.Symbol) > maxTokenSymbolLength:
+		return fmt.Errorf("%w: symbol must be 1 to %d bytes", ErrInvalidTokenConfig, maxTokenSymbolLength)
+	case c.InitialSupply > MaxTokenSupply:
+		return fmt.Errorf("%w: initial supply exceeds %d", ErrInvalidTokenConfig, uint64(MaxTokenSupply))
+	}
+	return nil
+}
+
+// TokenSource returns the Lua source of a token configured by config. The
+// token has these functions, taking addresses as strings and amounts as
+// whole numbers:
+//
+//   - name(), symbol(), totalSupply(), balanceOf(account) and
+//     allowance(owner, spender) read the token
+//   - mint(to, amount) creates tokens; owner only
+//   - transfer(to, amount) moves the caller's tokens
+//   - approve(spender, amount) lets spender move up to amount of them
+//   - transferFrom(from, to, amount) moves tokens from an account that
+//     approved the caller
+//
+// Moving and minting emit Transfer events, with an empty from when minting,
+// and approving emits Approval. The initial supply is minted by init, which
+// the owner calls once after deploying; DeployToken does so.
+func TokenSource(config TokenConfig) (string, error) {
+	if err := config.validate(); err != nil {
+		return "", err
+	}
+	header := fmt.Sprintf("local TOKEN_NAME, TOKEN_SYMBOL, TOKEN_INITIAL_SUPPLY = %s, %s, %d\n",
+		luaQuote(config.Name), luaQuote(config.Symbol), config.InitialSupply)
+	return header + tokenTemplate, nil
+}
+
+// luaQuote returns s as a Lua string literal, escaping quotes, backslashes
+// and control characters as decimal escapes
+func luaQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c == 0x7f || c == '"' || c == '\\' {
+			fmt.Fprintf(&b, "\\%03d", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// DeployToken deploys a standard token owned by owner and mints its initial
+// supply to them. The token is removed again if minting fails.
+func (r *ContractRegistry) DeployToken(ctx context.Context, id, owner string, config TokenConfig) error {
+	if owner == "" {
+		return fmt.Errorf("%w: a token needs an owner", ErrInvalidTokenConfig)
+	}
+	source, err := TokenSource(config)
+	if err != nil {
+		return err
+	}
+	if err := r.Deploy(TypeLua, id, config.Name, owner, []byte(source)); err != nil {
+		return err
+	}
+	if _, err := r.Execute(ctx, ExecutionContext{Caller: owner}, id, "init"); err != nil {
+		if removeErr := r.RemoveContract(Manager{Admin: true}, id); removeErr != nil {
+			return fmt.Errorf("failed to initialize token: %w (and to remove it: %v)", err, removeErr)
+		}
+		return fmt.Errorf("failed to initialize token: %w", err)
+	}
+	return nil
+}
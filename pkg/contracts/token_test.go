@@ -0,0 +1,131 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newTestToken deploys a token owned by "owner" with the given supply
+func newTestToken(t *testing.T, registry *ContractRegistry, id string, supply uint64) {
+	t.Helper()
+	if err := registry.DeployToken(context.Background(), id, "owner", TokenConfig{Name: id, Symbol: "TK", InitialSupply: supply}); err != nil {
+		t.Fatalf("deploying %s: %v", id, err)
+	}
+}
+
+// tokenCall calls a token function as caller
+func tokenCall(registry *ContractRegistry, id, caller, function string, params ...interface{}) (*ExecutionResult, error) {
+	return registry.Execute(context.Background(), ExecutionContext{Caller: caller}, id, function, params...)
+}
+
+// balance reads an account's token balance
+func balance(t *testing.T, registry *ContractRegistry, id, account string) float64 {
+	t.Helper()
+	result, err := tokenCall(registry, id, "", "balanceOf", account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result.Value.(float64)
+}
+
+func TestTokenMintIsOwnerOnly(t *testing.T) {
+	registry := newTestRegistry(t, nil)
+	newTestToken(t, registry, "token", 100)
+
+	result, err := tokenCall(registry, "token", "owner", "mint", "bob", 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Events) != 1 || result.Events[0].Name != "Transfer" {
+		t.Fatalf("mint emitted %+v", result.Events)
+	}
+	if _, err := tokenCall(registry, "token", "bob", "mint", "bob", 50); err == nil || !strings.Contains(err.Error(), "only the owner") {
+		t.Fatalf("bob minted: %v", err)
+	}
+
+	supply, err := tokenCall(registry, "token", "", "totalSupply")
+	if err != nil || supply.Value != 150.0 {
+		t.Fatalf("total supply %+v, %v", supply, err)
+	}
+	if got := balance(t, registry, "token", "owner"); got != 100 {
+		t.Fatalf("owner holds %v, want the initial supply", got)
+	}
+}
+
+func TestTokenTransferNeedsBalance(t *testing.T) {
+	registry := newTestRegistry(t, nil)
+	newTestToken(t, registry, "token", 100)
+
+	if _, err := tokenCall(registry, "token", "owner", "transfer", "bob", 30); err != nil {
+		t.Fatal(err)
+	}
+	_, err := tokenCall(registry, "token", "bob", "transfer", "carol", 31)
+	if AsExecutionError(err).Code != CodeReverted || !strings.Contains(err.Error(), "insufficient balance") {
+		t.Fatalf("overdraft got %v", err)
+	}
+	if owner, bob, carol := balance(t, registry, "token", "owner"), balance(t, registry, "token", "bob"), balance(t, registry, "token", "carol"); owner != 70 || bob != 30 || carol != 0 {
+		t.Fatalf("balances owner=%v bob=%v carol=%v", owner, bob, carol)
+	}
+}
+
+func TestTokenAllowance(t *testing.T) {
+	registry := newTestRegistry(t, nil)
+	newTestToken(t, registry, "token", 100)
+
+	result, err := tokenCall(registry, "token", "owner", "approve", "spender", 40)
+	if err != nil || len(result.Events) != 1 || result.Events[0].Name != "Approval" {
+		t.Fatalf("approve returned %+v, %v", result, err)
+	}
+	if _, err := tokenCall(registry, "token", "spender", "transferFrom", "owner", "bob", 25); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tokenCall(registry, "token", "spender", "transferFrom", "owner", "bob", 20); err == nil {
+		t.Fatal("spender moved more than the remaining allowance")
+	}
+	if _, err := tokenCall(registry, "token", "bob", "transferFrom", "owner", "bob", 1); err == nil {
+		t.Fatal("an account without allowance moved tokens")
+	}
+
+	remaining, err := tokenCall(registry, "token", "", "allowance", "owner", "spender")
+	if err != nil || remaining.Value != 15.0 {
+		t.Fatalf("remaining allowance %+v, %v", remaining, err)
+	}
+	if got := balance(t, registry, "token", "bob"); got != 25 {
+		t.Fatalf("bob holds %v, want 25", got)
+	}
+}
+
+func TestTokenInstancesAreIsolated(t *testing.T) {
+	registry := newTestRegistry(t, nil)
+	newTestToken(t, registry, "gold", 100)
+	newTestToken(t, registry, "silver", 5)
+
+	if _, err := tokenCall(registry, "gold", "owner", "transfer", "bob", 60); err != nil {
+		t.Fatal(err)
+	}
+	if got := balance(t, registry, "silver", "bob"); got != 0 {
+		t.Fatalf("bob holds %v silver after receiving gold", got)
+	}
+	if got := balance(t, registry, "silver", "owner"); got != 5 {
+		t.Fatalf("owner holds %v silver, want 5", got)
+	}
+}
+
+func TestTokenConfigIsValidated(t *testing.T) {
+	registry := newTestRegistry(t, nil)
+	configs := []TokenConfig{
+		{Symbol: "TK"},
+		{Name: "token", Symbol: strings.Repeat("T", maxTokenSymbolLength+1)},
+		{Name: "token", Symbol: "TK", InitialSupply: MaxTokenSupply + 1},
+	}
+	for _, config := range configs {
+		if err := registry.DeployToken(context.Background(), "token", "owner", config); !errors.Is(err, ErrInvalidTokenConfig) {
+			t.Errorf("%+v: got %v, want ErrInvalidTokenConfig", config, err)
+		}
+	}
+	if err := registry.DeployToken(context.Background(), "token", "", TokenConfig{Name: "token", Symbol: "TK"}); !errors.Is(err, ErrInvalidTokenConfig) {
+		t.Errorf("ownerless token: got %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package contracts
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer returns the tracer of contract execution spans from the current
+// global provider
+func tracer() trace.Tracer {
+	return otel.Tracer("github.com/anekazek/simple-blockchain/pkg/contracts")
+}
+
+// startExecutionSpan starts the span of an execution through the registry
+func startExecutionSpan(ctx context.Context, name string, engine ContractEngine, contractID, functionName string) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithAttributes(
+		attribute.String("contract.engine", engine.Type()),
+		attribute.String("contract.id", contractID),
+		attribute.String("contract.function", functionName),
+	))
+}
+
+// endExecutionSpan records an execution's gas and outcome and ends its span
+func endExecutionSpan(span trace.Span, result *ExecutionResult, err error) {
+	if result != nil {
+		span.SetAttributes(attribute.Int64("contract.gas_used", int64(result.GasUsed)))
+	}
+	if err != nil {
+		span.SetAttributes(attribute.String("contract.error_code", AsExecutionError(err).Code))
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,131 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Errors returned when contract code is rejected at deploy
+var (
+	ErrCodeTooLarge    = errors.New("contract code too large")
+	ErrInvalidContract = errors.New("invalid contract")
+)
+
+// Default caps on the size of deployed contract code
+const (
+	DefaultMaxWASMCodeBytes = 2 * 1024 * 1024
+	DefaultMaxLuaCodeBytes  = 256 * 1024
+)
+
+// luaForbiddenGlobals are globals Lua contracts may not reference: the
+// functions removed from the sandbox and the libraries it doesn't open
+var luaForbiddenGlobals = append([]string{"io", "os", "debug", "package"}, luaBlockedGlobals...)
+
+// invalidContract reports why contract code was rejected
+func invalidContract(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidContract, fmt.Sprintf(format, args...))
+}
+
+// checkCodeSize rejects code larger than limit bytes, 0 meaning no limit
+func checkCodeSize(code []byte, limit int) error {
+	if limit > 0 && len(code) > limit {
+		return fmt.Errorf("%w: %d bytes, limit is %d", ErrCodeTooLarge, len(code), limit)
+	}
+	return nil
+}
+
+// validateWASM checks that a compiled module only imports functions the
+// host provides, with matching signatures, and exports functions to call
+// along with a well-formed alloc and free when it has them
+func validateWASM(module wazero.CompiledModule, host api.Module) error {
+	if memories := module.ImportedMemories(); len(memories) > 0 {
+		moduleName, name, _ := memories[0].Import()
+		return invalidContract("imports memory %s.%s, which the host doesn't provide", moduleName, name)
+	}
+
+	hostFunctions := host.ExportedFunctionDefinitions()
+	importsCall := false
+	for _, imported := range module.ImportedFunctions() {
+		moduleName, name, _ := imported.Import()
+		if moduleName != hostModuleName {
+			return invalidContract("imports %s.%s from a module other than %q", moduleName, name, hostModuleName)
+		}
+		provided, ok := hostFunctions[name]
+		if !ok {
+			return invalidContract("imports %s.%s, which the host doesn't provide", moduleName, name)
+		}
+		if !sameSignature(imported, provided) {
+			return invalidContract("imports %s.%s with the wrong signature", moduleName, name)
+		}
+		importsCall = importsCall || name == "call"
+	}
+
+	exports := module.ExportedFunctions()
+	if len(wasmABI(exports)) == 0 {
+		return invalidContract("exports no functions")
+	}
+	alloc, hasAlloc := exports[abiAlloc]
+	if hasAlloc && !hasTypes(alloc, []api.ValueType{api.ValueTypeI32}, []api.ValueType{api.ValueTypeI32}) {
+		return invalidContract("%s must take and return an i32", abiAlloc)
+	}
+	if free, ok := exports[abiFree]; ok && !hasTypes(free, []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}, nil) {
+		return invalidContract("%s must take two i32 values and return nothing", abiFree)
+	}
+	if importsCall && !hasAlloc {
+		return invalidContract("imports call but doesn't export %s for its results", abiAlloc)
+	}
+	return nil
+}
+
+// sameSignature reports whether two functions have the same types
+func sameSignature(a, b api.FunctionDefinition) bool {
+	return hasTypes(a, b.ParamTypes(), b.ResultTypes())
+}
+
+// hasTypes reports whether a function has the given parameter and result types
+func hasTypes(fn api.FunctionDefinition, params, results []api.ValueType) bool {
+	return slices.Equal(fn.ParamTypes(), params) && slices.Equal(fn.ResultTypes(), results)
+}
+
+// validateLua checks that compiled Lua code defines functions to call and
+// never references a forbidden global
+func validateLua(proto *lua.FunctionProto, functions []FunctionABI) error {
+	if err := checkLuaGlobals(proto); err != nil {
+		return err
+	}
+	if len(functions) == 0 {
+		return invalidContract("defines no functions")
+	}
+	return nil
+}
+
+// checkLuaGlobals walks a compiled function and those nested in it for
+// reads or writes of forbidden globals
+func checkLuaGlobals(proto *lua.FunctionProto) error {
+	for pc, inst := range proto.Code {
+		op := int(inst >> 26)
+		if op != lua.OP_GETGLOBAL && op != lua.OP_SETGLOBAL {
+			continue
+		}
+		name, ok := proto.Constants[inst&0x3ffff].(lua.LString)
+		if !ok || !slices.Contains(luaForbiddenGlobals, string(name)) {
+			continue
+		}
+		line := 0
+		if pc < len(proto.DbgSourcePositions) {
+			line = proto.DbgSourcePositions[pc]
+		}
+		return invalidContract("line %d references %s, which contracts may not use", line, name)
+	}
+	for _, nested := range proto.FunctionPrototypes {
+		if err := checkLuaGlobals(nested); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package contracts
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// importingModule imports field from module with the given function type
+// and exports add
+func importingModule(module, field string, importType []byte) []byte {
+	entry := append([]byte{byte(len(module))}, module...)
+	entry = append(append(entry, byte(len(field))), field...)
+	return wasmBinary(
+		wasmSection{wasmSectionType, wasmVec([]byte{0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f}, importType)},
+		wasmSection{wasmSectionImport, wasmVec(append(entry, wasmImportFunc, 1))},
+		wasmSection{wasmSectionFunction, wasmVec([]byte{0})},
+		wasmSection{7, wasmVec(wasmExport("add", 1))},
+		wasmSection{wasmSectionCode, wasmVec(addBody)},
+	)
+}
+
+func TestWASMDeployValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		code []byte
+		want error
+	}{
+		{"valid", arithmeticModule(), nil},
+		{"valid host import", importingModule(hostModuleName, "get_block_height", []byte{0x60, 0x00, 0x01, 0x7e}), nil},
+		{"too large", append(arithmeticModule(), make([]byte, 1024)...), ErrCodeTooLarge},
+		{"not wasm", []byte("not wasm"), ErrInvalidContract},
+		{"no exports", wasmBinary(wasmSection{wasmSectionType, wasmVec([]byte{0x60, 0x00, 0x00})}), ErrInvalidContract},
+		{"foreign module", importingModule("wasi_snapshot_preview1", "fd_write", []byte{0x60, 0x00, 0x00}), ErrInvalidContract},
+		{"unknown host function", importingModule(hostModuleName, "launch", []byte{0x60, 0x00, 0x00}), ErrInvalidContract},
+		{"wrong host signature", importingModule(hostModuleName, "get_block_height", []byte{0x60, 0x00, 0x01, 0x7f}), ErrInvalidContract},
+		{"malformed alloc", wasmBinary(
+			wasmSection{wasmSectionType, wasmVec([]byte{0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f})},
+			wasmSection{wasmSectionFunction, wasmVec([]byte{0})},
+			wasmSection{7, wasmVec(wasmExport("alloc", 0))},
+			wasmSection{wasmSectionCode, wasmVec(addBody)},
+		), ErrInvalidContract},
+	}
+
+	config := DefaultWASMConfig()
+	config.MaxCodeBytes = 512
+	for _, tt := range tests {
+		engine := NewWASMEngineWithConfig(config)
+		err := engine.DeployContractFromBytes("contract", "contract", tt.code)
+		if tt.want == nil && err != nil || !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+		if _, getErr := engine.GetContract("contract"); tt.want != nil && getErr == nil {
+			t.Errorf("%s: rejected contract was kept", tt.name)
+		}
+	}
+}
+
+func TestLuaDeployValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want error
+	}{
+		{"valid", "function get() return 1 end", nil},
+		{"too large", "function get() return 1 end\n" + strings.Repeat("-- padding\n", 100), ErrCodeTooLarge},
+		{"syntax error", "function get( return 1 end", ErrInvalidContract},
+		{"no functions", "supply = 100", ErrInvalidContract},
+		{"forbidden library", "function now() return os.time() end", ErrInvalidContract},
+		{"forbidden function", "function run(code) return loadstring(code)() end", ErrInvalidContract},
+		{"error at load", "error('broken')\nfunction get() end", ErrInvalidContract},
+	}
+
+	config := DefaultLuaConfig()
+	config.MaxCodeBytes = 512
+	for _, tt := range tests {
+		engine := NewLuaEngineWithConfig(config)
+		err := engine.DeployContract("contract", "contract", tt.code)
+		if tt.want == nil && err != nil || !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -6,132 +6,440 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 )
 
+// WASMConfig bounds the resources a single WASM contract execution may use
+type WASMConfig struct {
+	// MemoryLimitPages caps each module's linear memory in 64KiB pages. It is
+	// fixed when the engine is created.
+	MemoryLimitPages uint32
+	// MaxParamBytes caps each string or byte parameter copied into memory
+	MaxParamBytes int
+	// MaxCodeBytes caps the size of deployed modules, 0 meaning no limit
+	MaxCodeBytes int
+	// DefaultGasLimit is the budget of calls that don't set one
+	DefaultGasLimit uint64
+	// MaxGasLimit caps the budget a caller may request
+	MaxGasLimit uint64
+	// CallGas is charged for every function call, including the entry call,
+	// and for every loop iteration
+	CallGas uint64
+	// Timeout is the wall-clock limit of one execution
+	Timeout time.Duration
+}
+
+// DefaultWASMConfig returns the default WASM execution limits
+func DefaultWASMConfig() WASMConfig {
+	return WASMConfig{
+		MemoryLimitPages: 64, // 4MiB
+		MaxParamBytes:    64 * 1024,
+		MaxCodeBytes:     DefaultMaxWASMCodeBytes,
+		DefaultGasLimit:  1_000_000,
+		MaxGasLimit:      10_000_000,
+		CallGas:          1,
+		Timeout:          5 * time.Second,
+	}
+}
+
 // WASMEngine provides WebAssembly-based smart contract execution
 type WASMEngine struct {
 	contracts map[string]*Contract
 	runtime   wazero.Runtime
 	mutex     sync.RWMutex
 	ctx       context.Context
+	config    WASMConfig
+	state     StateStore
+	store     ContractStore             // Written through on deploy and removal when set
+	failed    map[string]FailedContract // Stored contracts that failed to reload
+
+	// instances numbers module instances so each execution gets a unique name
+	instances atomic.Uint64
+
+	// dispatcher routes calls to other contracts, when connected
+	dispatcher atomic.Pointer[Dispatcher]
 }
 
-// Contract represents a compiled WASM smart contract
+// Contract represents a compiled WASM smart contract. Each execution runs in
+// a fresh instance of the compiled module, so memory and globals never carry
+// over from one call to the next.
 type Contract struct {
 	ID        string
 	Name      string
+	Owner     string // Address allowed to manage the contract, empty if none
+	Pause     *Pause // Set while executions are suspended
 	Code      []byte
-	Module    api.Module
+	Module    wazero.CompiledModule
 	CreatedAt time.Time
 	UpdatedAt time.Time
+
+	hasMemory bool // Whether the module defines or imports a memory
 }
 
-// NewWASMEngine creates a new WebAssembly smart contract engine
+// NewWASMEngine creates a new WebAssembly smart contract engine with the
+// default limits
 func NewWASMEngine() *WASMEngine {
-	ctx := context.Background()
-	// Create a new WebAssembly Runtime
-	runtime := wazero.NewRuntime(ctx)
+	return NewWASMEngineWithConfig(DefaultWASMConfig())
+}
+
+// NewWASMEngineWithConfig creates a new WebAssembly smart contract engine
+// with the given limits
+func NewWASMEngineWithConfig(wasmConfig WASMConfig) *WASMEngine {
+	// Modules compiled under this context report every function call to the
+	// gas listener
+	ctx := context.WithValue(context.Background(), experimental.FunctionListenerFactoryKey{}, gasListener{})
+
+	// Create a new WebAssembly Runtime whose executions stop when their
+	// context is cancelled
+	config := wazero.NewRuntimeConfigInterpreter().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(wasmConfig.MemoryLimitPages)
+	runtime := wazero.NewRuntimeWithConfig(ctx, config)
+	if err := instantiateHostModule(ctx, runtime); err != nil {
+		panic(err)
+	}
 
 	return &WASMEngine{
 		contracts: make(map[string]*Contract),
+		failed:    make(map[string]FailedContract),
 		runtime:   runtime,
 		ctx:       ctx,
+		config:    wasmConfig,
+		state:     NewMemoryStateStore(),
 	}
 }
 
-// DeployContract loads and compiles a WASM contract from a file
-func (e *WASMEngine) DeployContract(id, name, filePath string) error {
+// SetStateStore replaces the store holding contract storage
+func (e *WASMEngine) SetStateStore(store StateStore) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.state = store
+}
+
+// SetConfig replaces the execution limits. The memory limit can't change
+// once the engine is created and is kept.
+func (e *WASMEngine) SetConfig(config WASMConfig) {
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
+	config.MemoryLimitPages = e.config.MemoryLimitPages
+	e.config = config
+}
+
+// MemoryLimitPages returns the cap on each module's linear memory in 64KiB pages
+func (e *WASMEngine) MemoryLimitPages() uint32 {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.config.MemoryLimitPages
+}
 
+// DeployContract loads and compiles a WASM contract from a file
+func (e *WASMEngine) DeployContract(id, name, filePath string) error {
 	// Read the WASM file
 	wasmBytes, err := os.ReadFile(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to read WASM file: %w", err)
 	}
 
-	// Compile the WebAssembly module
-	module, err := e.runtime.CompileModule(e.ctx, wasmBytes)
+	return e.DeployContractFromBytes(id, name, wasmBytes)
+}
+
+// DeployContractFromBytes compiles a WASM contract from its binary code.
+// The contract has no owner, so only admins can manage it.
+func (e *WASMEngine) DeployContractFromBytes(id, name string, code []byte) error {
+	return e.DeployContractWithOwner(id, name, "", code)
+}
+
+// DeployContractWithOwner compiles a WASM contract owned by the given address
+func (e *WASMEngine) DeployContractWithOwner(id, name, owner string, code []byte) error {
+	e.mutex.RLock()
+	limitPages, maxCode := e.config.MemoryLimitPages, e.config.MaxCodeBytes
+	e.mutex.RUnlock()
+	if err := checkCodeSize(code, maxCode); err != nil {
+		return err
+	}
+
+	contract, err := e.compile(id, name, code, time.Now(), limitPages)
+	if err != nil {
+		return err
+	}
+	contract.Owner = owner
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// Persist first so the store never misses a deployed contract
+	if e.store != nil {
+		if err := e.store.SaveContract(contract.stored()); err != nil {
+			contract.Module.Close(e.ctx)
+			return fmt.Errorf("failed to store contract: %w", err)
+		}
+	}
+
+	e.install(contract)
+	return nil
+}
+
+// compile checks a contract's memory against limitPages, compiles it with
+// its loops metered, and validates its imports and exports. The contract
+// keeps the code as deployed.
+func (e *WASMEngine) compile(id, name string, code []byte, createdAt time.Time, limitPages uint32) (*Contract, error) {
+	hasMemory, err := checkMemoryLimits(code, limitPages)
 	if err != nil {
-		return fmt.Errorf("failed to compile WASM module: %w", err)
+		return nil, err
 	}
 
-	// Instantiate the WebAssembly module
-	instance, err := e.runtime.InstantiateModule(e.ctx, module, wazero.NewModuleConfig())
+	// Charge every loop iteration, so loops that call no functions still
+	// run out of gas
+	metered, err := meterLoops(code)
 	if err != nil {
-		return fmt.Errorf("failed to instantiate WASM module: %w", err)
+		return nil, invalidContract("failed to compile WASM module: %v", err)
 	}
 
-	// Store the contract
-	e.contracts[id] = &Contract{
+	// Compile the WebAssembly module
+	module, err := e.runtime.CompileModule(e.ctx, metered)
+	if err != nil {
+		return nil, invalidContract("failed to compile WASM module: %v", err)
+	}
+	if err := validateWASM(module, e.runtime.Module(hostModuleName)); err != nil {
+		module.Close(e.ctx)
+		return nil, err
+	}
+
+	return &Contract{
 		ID:        id,
 		Name:      name,
-		Code:      wasmBytes,
-		Module:    instance,
-		CreatedAt: time.Now(),
+		Code:      code,
+		Module:    module,
+		CreatedAt: createdAt,
 		UpdatedAt: time.Now(),
+		hasMemory: hasMemory,
+	}, nil
+}
+
+// stored returns the persisted form of the contract
+func (c *Contract) stored() StoredContract {
+	return StoredContract{ID: c.ID, Name: c.Name, Type: TypeWASM, Owner: c.Owner, Code: c.Code, Pause: c.Pause, CreatedAt: c.CreatedAt}
+}
+
+// install adds a compiled contract, releasing the module it replaces.
+// The caller must hold the write lock.
+func (e *WASMEngine) install(contract *Contract) {
+	if old, exists := e.contracts[contract.ID]; exists {
+		old.Module.Close(e.ctx)
+	}
+	e.contracts[contract.ID] = contract
+	delete(e.failed, contract.ID)
+}
+
+// LoadFrom recompiles the WASM contracts saved in store and keeps the store
+// up to date with later deploys and removals. Contracts that fail to
+// compile are skipped and reported by FailedContracts.
+func (e *WASMEngine) LoadFrom(store ContractStore) error {
+	stored, err := store.ListContracts()
+	if err != nil {
+		return fmt.Errorf("failed to list stored contracts: %w", err)
 	}
 
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, c := range stored {
+		if c.Type != TypeWASM {
+			continue
+		}
+		contract, err := e.compile(c.ID, c.Name, c.Code, c.CreatedAt, e.config.MemoryLimitPages)
+		if err != nil {
+			e.failed[c.ID] = failedContract(c, err)
+			continue
+		}
+		contract.Owner, contract.Pause = c.Owner, c.Pause
+		e.install(contract)
+	}
+	e.store = store
+
 	return nil
 }
 
-// ExecuteContract runs a function in the specified contract
+// FailedContracts returns the stored contracts that failed to reload
+func (e *WASMEngine) FailedContracts() []FailedContract {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	failed := make([]FailedContract, 0, len(e.failed))
+	for _, contract := range e.failed {
+		failed = append(failed, contract)
+	}
+	return failed
+}
+
+// ExecuteContract runs a function in the specified contract with the
+// default gas limit
 func (e *WASMEngine) ExecuteContract(contractID, functionName string, params ...interface{}) (interface{}, error) {
+	result, err := e.ExecuteContractWithGas(contractID, functionName, 0, params...)
+	if err != nil {
+		return nil, err
+	}
+	return result.Value, nil
+}
+
+// ExecuteContractWithGas runs a function in the specified contract with a gas
+// limit, 0 meaning the default
+func (e *WASMEngine) ExecuteContractWithGas(contractID, functionName string, gasLimit uint64, params ...interface{}) (*ExecutionResult, error) {
+	return e.ExecuteContractWithContext(ExecutionContext{GasLimit: gasLimit}, contractID, functionName, params...)
+}
+
+// ExecuteContractWithContext runs a function in the specified contract in
+// the given chain context
+func (e *WASMEngine) ExecuteContractWithContext(execCtx ExecutionContext, contractID, functionName string, params ...interface{}) (*ExecutionResult, error) {
+	return e.ExecuteContractCtx(context.Background(), execCtx, contractID, functionName, params...)
+}
+
+// ExecuteContractCtx runs a function in the specified contract in the given
+// chain context, stopping early when parent is cancelled or its deadline
+// passes. The gas limit is capped at the configured maximum and is shared
+// with any contracts the function calls. Storage writes are committed only
+// if the whole execution succeeds. When the execution starts, the result
+// reports the gas used even if an error is returned.
+func (e *WASMEngine) ExecuteContractCtx(parent context.Context, execCtx ExecutionContext, contractID, functionName string, params ...interface{}) (*ExecutionResult, error) {
+	return e.run(parent, execCtx, contractID, functionName, params, true)
+}
+
+// DryRun runs a function like ExecuteContractCtx, within the same limits,
+// but never commits its state writes. The result reports the storage the
+// call would change as StateDiff, and its gas use as an estimate.
+func (e *WASMEngine) DryRun(parent context.Context, execCtx ExecutionContext, contractID, functionName string, params ...interface{}) (*ExecutionResult, error) {
+	return e.run(parent, execCtx, contractID, functionName, params, false)
+}
+
+// run executes a call tree from its root, committing its state writes when
+// commit is set and it succeeds
+func (e *WASMEngine) run(parent context.Context, execCtx ExecutionContext, contractID, functionName string, params []interface{}, commit bool) (*ExecutionResult, error) {
+	// Bound the running time of the execution and every call it makes
+	ctx, cancel := context.WithTimeout(parent, e.timeout())
+	defer cancel()
+	tree := newCallTree(e.dispatcher.Load(), cancel)
+	if execCtx.Origin == "" {
+		execCtx.Origin = execCtx.Caller
+	}
+
+	value, err := e.execute(ctx, tree, 0, execCtx, contractID, functionName, params)
+	if errors.Is(err, ErrContractNotFound) {
+		return nil, executionFailure(TypeWASM, err)
+	}
+	if err == nil {
+		err = tree.failed()
+	}
+	result := &ExecutionResult{GasUsed: tree.gasUsed()}
+	if err != nil {
+		return result, executionFailure(TypeWASM, err)
+	}
+
+	result.Value = value
+	result.Events = tree.events.list()
+	if !commit {
+		result.StateDiff = tree.diff()
+		return result, nil
+	}
+	if err := tree.commit(); err != nil {
+		return result, executionFailure(TypeWASM, err)
+	}
+	return result, nil
+}
+
+// timeout returns the wall-clock limit of one execution
+func (e *WASMEngine) timeout() time.Duration {
 	e.mutex.RLock()
 	defer e.mutex.RUnlock()
+	return e.config.Timeout
+}
+
+// execute runs one call of a call tree, at the given nesting depth, without
+// committing its state writes
+func (e *WASMEngine) execute(parent context.Context, tree *callTree, depth int, execCtx ExecutionContext, contractID, functionName string, params []interface{}) (interface{}, error) {
+	unlock := tree.rlock(&e.mutex)
+	defer unlock()
 
 	// Get the contract
 	contract, exists := e.contracts[contractID]
 	if !exists {
-		return nil, errors.New("contract not found")
+		return nil, ErrContractNotFound
+	}
+	if contract.Pause != nil {
+		return nil, pausedError(contractID, contract.Pause)
+	}
+
+	// Calls from other engines are bounded by the time limit if the caller
+	// set none
+	ctx := parent
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.config.Timeout)
+		defer cancel()
 	}
 
+	// Meter the execution against the tree's budget
+	meter := tree.gasMeter(e.config.gasLimit(execCtx.GasLimit))
+	ctx = context.WithValue(ctx, callGasKey{}, callGas{meter: meter, amount: e.config.CallGas})
+
+	// Give host functions the chain context and a view of the contract's storage
+	exec := &execution{
+		contractID: contractID,
+		owner:      contract.Owner,
+		context:    execCtx,
+		hasMemory:  contract.hasMemory,
+		state:      tree.state(e.state, contractID),
+		events:     tree.events,
+		tree:       tree,
+		depth:      depth,
+	}
+	ctx = context.WithValue(ctx, executionKey{}, exec)
+
+	// Instantiate a fresh copy of the module for this execution
+	config := wazero.NewModuleConfig().
+		WithName(fmt.Sprintf("%s-%d", contractID, e.instances.Add(1)))
+	instance, err := e.runtime.InstantiateModule(ctx, contract.Module, config)
+	if err != nil {
+		return nil, tree.executionError(ctx, fmt.Errorf("failed to instantiate WASM module: %w", err))
+	}
+	defer instance.Close(e.ctx)
+
 	// Get the function from the module
-	fn := contract.Module.ExportedFunction(functionName)
+	fn := instance.ExportedFunction(functionName)
 	if fn == nil {
-		return nil, fmt.Errorf("function not found: %s", functionName)
+		return nil, notFound(contractID, functionName)
+	}
+
+	// Only modules that have a memory expose one
+	var memory api.Memory
+	if contract.hasMemory {
+		memory = instance.Memory()
 	}
 
 	// Convert params to wazero format
-	wasmParams := make([]uint64, 0, len(params))
-	for _, param := range params {
-		switch v := param.(type) {
-		case int:
-			wasmParams = append(wasmParams, uint64(v))
-		case int32:
-			wasmParams = append(wasmParams, uint64(v))
-		case int64:
-			wasmParams = append(wasmParams, uint64(v))
-		case uint:
-			wasmParams = append(wasmParams, uint64(v))
-		case uint32:
-			wasmParams = append(wasmParams, uint64(v))
-		case uint64:
-			wasmParams = append(wasmParams, v)
-		case float32:
-			wasmParams = append(wasmParams, uint64(v))
-		case float64:
-			wasmParams = append(wasmParams, uint64(v))
-		default:
-			return nil, fmt.Errorf("unsupported parameter type: %T", param)
-		}
+	wasmParams, err := encodeParams(ctx, instance, memory, fn.Definition().ParamTypes(), params, e.config.MaxParamBytes)
+	if err != nil {
+		return nil, tree.executionError(ctx, err)
 	}
 
 	// Execute the function
-	results, err := fn.Call(e.ctx, wasmParams...)
+	results, err := fn.Call(ctx, wasmParams...)
 	if err != nil {
-		return nil, fmt.Errorf("execution error: %w", err)
+		if memory != nil && atMemoryLimit(memory, e.config.MemoryLimitPages) {
+			return nil, fmt.Errorf("%w: %d pages", ErrMemoryLimit, e.config.MemoryLimitPages)
+		}
+		return nil, tree.executionError(ctx, wasmRevert("execution error", err))
 	}
 
-	if len(results) == 0 {
-		return nil, nil
+	value, err := decodeResult(ctx, instance, memory, fn.Definition().ResultTypes(), results, execCtx.Returns)
+	if err != nil {
+		return nil, tree.executionError(ctx, err)
 	}
-
-	return results[0], nil
+	return value, nil
 }
 
 // GetContract returns a contract by ID
@@ -141,12 +449,73 @@ func (e *WASMEngine) GetContract(id string) (*Contract, error) {
 
 	contract, exists := e.contracts[id]
 	if !exists {
-		return nil, errors.New("contract not found")
+		return nil, ErrContractNotFound
 	}
 
 	return contract, nil
 }
 
+// Type names the contracts the engine runs
+func (e *WASMEngine) Type() string {
+	return TypeWASM
+}
+
+// Deploy compiles a WASM contract from its binary code for owner
+func (e *WASMEngine) Deploy(id, name, owner string, code []byte) error {
+	return e.DeployContractWithOwner(id, name, owner, code)
+}
+
+// ContractInfo describes a contract, including one that failed to reload
+func (e *WASMEngine) ContractInfo(id string) (ContractInfo, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if contract, exists := e.contracts[id]; exists {
+		return contract.info(), nil
+	}
+	if failed, exists := e.failed[id]; exists {
+		return failed.info(), nil
+	}
+	return ContractInfo{}, ErrContractNotFound
+}
+
+// ListContractInfo describes every contract, including those that failed
+// to reload
+func (e *WASMEngine) ListContractInfo() []ContractInfo {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	infos := make([]ContractInfo, 0, len(e.contracts)+len(e.failed))
+	for _, contract := range e.contracts {
+		infos = append(infos, contract.info())
+	}
+	for _, failed := range e.failed {
+		infos = append(infos, failed.info())
+	}
+	return infos
+}
+
+// info describes the contract
+func (c *Contract) info() ContractInfo {
+	return ContractInfo{ID: c.ID, Name: c.Name, Type: TypeWASM, Owner: c.Owner, Status: pauseStatus(c.Pause), Pause: c.Pause, CreatedAt: c.CreatedAt}
+}
+
+// ABI describes the functions a contract exports
+func (e *WASMEngine) ABI(id string) (*ContractABI, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	contract, exists := e.contracts[id]
+	if !exists {
+		return nil, ErrContractNotFound
+	}
+	functions := wasmABI(contract.Module.ExportedFunctions())
+	if len(functions) == 0 {
+		return nil, ErrABIUnavailable
+	}
+	return &ContractABI{ContractID: id, Type: TypeWASM, Functions: functions}, nil
+}
+
 // ListContracts returns all deployed contracts
 func (e *WASMEngine) ListContracts() []*Contract {
 	e.mutex.RLock()
@@ -160,17 +529,41 @@ func (e *WASMEngine) ListContracts() []*Contract {
 	return contracts
 }
 
-// RemoveContract deletes a contract by ID
+// RemoveContract deletes a contract by ID without checking who asks for it
 func (e *WASMEngine) RemoveContract(id string) error {
+	return e.RemoveContractAs(Manager{Admin: true}, id)
+}
+
+// RemoveContractAs deletes a contract on behalf of its owner or an admin
+func (e *WASMEngine) RemoveContractAs(manager Manager, id string) error {
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
 
 	contract, exists := e.contracts[id]
+	failedContract, failed := e.failed[id]
+	if !exists && !failed {
+		return ErrContractNotFound
+	}
+	owner := failedContract.Owner
+	if exists {
+		owner = contract.Owner
+	}
+	if err := manager.authorize(id, owner); err != nil {
+		return err
+	}
+
+	// Remove from the store first so the contract can't come back on restart
+	if e.store != nil {
+		if err := e.store.DeleteContract(id); err != nil {
+			return fmt.Errorf("failed to delete stored contract: %w", err)
+		}
+	}
+	delete(e.failed, id)
 	if !exists {
-		return errors.New("contract not found")
+		return nil
 	}
 
-	// Close the WebAssembly module
+	// Release the compiled module
 	err := contract.Module.Close(e.ctx)
 	if err != nil {
 		return fmt.Errorf("failed to close module: %w", err)
@@ -181,3 +574,59 @@ func (e *WASMEngine) RemoveContract(id string) error {
 
 	return nil
 }
+
+// TransferOwnership hands a contract to a new owner on behalf of its current
+// owner or an admin
+func (e *WASMEngine) TransferOwnership(manager Manager, id, newOwner string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	contract, exists := e.contracts[id]
+	if !exists {
+		return ErrContractNotFound
+	}
+	if err := manager.authorize(id, contract.Owner); err != nil {
+		return err
+	}
+
+	// Contracts are read without the lock once fetched, so replace it rather
+	// than changing it in place
+	updated := *contract
+	updated.Owner = newOwner
+	updated.UpdatedAt = time.Now()
+	if e.store != nil {
+		if err := e.store.SaveContract(updated.stored()); err != nil {
+			return fmt.Errorf("failed to store contract: %w", err)
+		}
+	}
+	e.contracts[id] = &updated
+
+	return nil
+}
+
+// SetPause suspends a contract's executions with pause, or resumes them
+// when pause is nil, on behalf of its owner or an admin
+func (e *WASMEngine) SetPause(manager Manager, id string, pause *Pause) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	contract, exists := e.contracts[id]
+	if !exists {
+		return ErrContractNotFound
+	}
+	if err := manager.authorize(id, contract.Owner); err != nil {
+		return err
+	}
+
+	updated := *contract
+	updated.Pause = pause
+	updated.UpdatedAt = time.Now()
+	if e.store != nil {
+		if err := e.store.SaveContract(updated.stored()); err != nil {
+			return fmt.Errorf("failed to store contract: %w", err)
+		}
+	}
+	e.contracts[id] = &updated
+
+	return nil
+}
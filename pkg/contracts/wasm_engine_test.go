@@ -0,0 +1,77 @@
+package contracts
+
+import (
+	"sync"
+	"testing"
+)
+
+// counterModule exports bump, () -> i32, which increments a mutable global
+// and returns its new value
+func counterModule() []byte {
+	return wasmBinary(
+		wasmSection{wasmSectionType, wasmVec([]byte{0x60, 0x00, 0x01, 0x7f})},
+		wasmSection{wasmSectionFunction, wasmVec([]byte{0})},
+		// A mutable i32 global starting at 0
+		wasmSection{6, wasmVec([]byte{0x7f, 0x01, 0x41, 0x00, 0x0b})},
+		wasmSection{7, wasmVec(wasmExport("bump", 0))},
+		wasmSection{wasmSectionCode, wasmVec(wasmBody(0x23, 0x00, 0x41, 0x01, 0x6a, 0x24, 0x00, 0x23, 0x00, 0x0b))},
+	)
+}
+
+// newCounterEngine returns a WASM engine running counterModule as "counter"
+func newCounterEngine(t *testing.T) *WASMEngine {
+	t.Helper()
+	engine := NewWASMEngine()
+	if err := engine.DeployContractFromBytes("counter", "counter", counterModule()); err != nil {
+		t.Fatalf("deploying: %v", err)
+	}
+	return engine
+}
+
+func TestWASMExecutionsDoNotShareGlobals(t *testing.T) {
+	engine := newCounterEngine(t)
+	for call := 0; call < 3; call++ {
+		value, err := engine.ExecuteContract("counter", "bump")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != int32(1) {
+			t.Fatalf("call %d returned %v, want 1 from a fresh instance", call, value)
+		}
+	}
+}
+
+func TestWASMConcurrentExecutionsDoNotInterfere(t *testing.T) {
+	engine := newCounterEngine(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 32)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := engine.ExecuteContract("counter", "bump")
+			if err == nil && value != int32(1) {
+				t.Errorf("concurrent call returned %v, want 1", value)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDeployContractFromBytesRejectsInvalidCode(t *testing.T) {
+	engine := NewWASMEngine()
+	if err := engine.DeployContractFromBytes("bad", "bad", []byte("not wasm")); err == nil {
+		t.Fatal("invalid code deployed")
+	}
+	if _, err := engine.GetContract("bad"); err == nil {
+		t.Fatal("failed deployment left a contract behind")
+	}
+}
@@ -0,0 +1,389 @@
+package contracts
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// WASM binary section IDs and opcodes read by meterLoops
+const (
+	wasmSectionType     = 1
+	wasmSectionFunction = 3
+	wasmSectionCode     = 10
+
+	wasmOpLoop = 0x03
+	wasmOpCall = 0x10
+)
+
+// meterFunctionType and meterFunctionBody declare the empty function of
+// type [] -> [] that metered loops call
+var (
+	meterFunctionType = []byte{0x60, 0x00, 0x00}
+	meterFunctionBody = []byte{0x02, 0x00, 0x0b}
+)
+
+// wasmSection is one section of a WASM binary
+type wasmSection struct {
+	id      byte
+	payload []byte
+}
+
+// meterLoops returns code with an empty function appended and a call to it
+// added at the top of every loop, so the gas listener charges each loop
+// iteration as a function call. Appending the function leaves the indices of
+// the others unchanged. Code without loops is returned as it is.
+func meterLoops(code []byte) ([]byte, error) {
+	sections, err := readSections(code)
+	if err != nil {
+		return nil, err
+	}
+
+	var imported, defined uint64
+	types, functions, bodies := -1, -1, -1
+	for i, section := range sections {
+		switch section.id {
+		case wasmSectionType:
+			types = i
+		case wasmSectionImport:
+			if imported, err = importedFunctions(bytes.NewReader(section.payload)); err != nil {
+				return nil, err
+			}
+		case wasmSectionFunction:
+			functions = i
+			if defined, _, err = readCount(section.payload); err != nil {
+				return nil, err
+			}
+		case wasmSectionCode:
+			bodies = i
+		}
+	}
+	if bodies < 0 {
+		return code, nil
+	}
+	if types < 0 || functions < 0 {
+		return nil, errMalformedModule
+	}
+
+	typeCount, _, err := readCount(sections[types].payload)
+	if err != nil {
+		return nil, err
+	}
+	meter := uint32(imported + defined)
+	metered, loops, err := meterBodies(sections[bodies].payload, meter)
+	if err != nil {
+		return nil, err
+	}
+	if loops == 0 {
+		return code, nil
+	}
+
+	sections[types].payload, err = appendEntry(sections[types].payload, meterFunctionType)
+	if err != nil {
+		return nil, err
+	}
+	sections[functions].payload, err = appendEntry(sections[functions].payload, binary.AppendUvarint(nil, typeCount))
+	if err != nil {
+		return nil, err
+	}
+	sections[bodies].payload, err = appendEntry(metered, meterFunctionBody)
+	if err != nil {
+		return nil, err
+	}
+
+	out := append([]byte(nil), code[:8]...)
+	for _, section := range sections {
+		out = append(out, section.id)
+		out = binary.AppendUvarint(out, uint64(len(section.payload)))
+		out = append(out, section.payload...)
+	}
+	return out, nil
+}
+
+// readSections splits a WASM binary into its sections
+func readSections(code []byte) ([]wasmSection, error) {
+	if len(code) < 8 {
+		return nil, errMalformedModule
+	}
+
+	var sections []wasmSection
+	rest := code[8:]
+	for len(rest) > 0 {
+		size, n := binary.Uvarint(rest[1:])
+		if n <= 0 || size > uint64(len(rest)-1-n) {
+			return nil, errMalformedModule
+		}
+		sections = append(sections, wasmSection{id: rest[0], payload: rest[1+n : 1+n+int(size)]})
+		rest = rest[1+n+int(size):]
+	}
+	return sections, nil
+}
+
+// readCount reads the entry count at the start of a section, returning it
+// with the entries that follow
+func readCount(payload []byte) (uint64, []byte, error) {
+	count, n := binary.Uvarint(payload)
+	if n <= 0 {
+		return 0, nil, errMalformedModule
+	}
+	return count, payload[n:], nil
+}
+
+// appendEntry adds an entry to the end of a section's payload
+func appendEntry(payload, entry []byte) ([]byte, error) {
+	count, entries, err := readCount(payload)
+	if err != nil {
+		return nil, err
+	}
+	out := binary.AppendUvarint(nil, count+1)
+	out = append(out, entries...)
+	return append(out, entry...), nil
+}
+
+// importedFunctions counts the function entries of an import section
+func importedFunctions(r *bytes.Reader) (uint64, error) {
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, errMalformedModule
+	}
+	var functions uint64
+	for i := uint64(0); i < count; i++ {
+		for j := 0; j < 2; j++ {
+			length, err := binary.ReadUvarint(r)
+			if err != nil {
+				return 0, errMalformedModule
+			}
+			if _, err := io.CopyN(io.Discard, r, int64(length)); err != nil {
+				return 0, errMalformedModule
+			}
+		}
+
+		kind, err := r.ReadByte()
+		if err != nil {
+			return 0, errMalformedModule
+		}
+		switch kind {
+		case wasmImportFunc:
+			functions++
+			_, err = binary.ReadUvarint(r)
+		case wasmImportTable:
+			if _, err = r.ReadByte(); err == nil {
+				_, err = readLimits(r)
+			}
+		case wasmImportMemory:
+			_, err = readLimits(r)
+		case wasmImportGlobal:
+			_, err = io.CopyN(io.Discard, r, 2)
+		default:
+			err = errMalformedModule
+		}
+		if err != nil {
+			return 0, errMalformedModule
+		}
+	}
+	return functions, nil
+}
+
+// meterBodies adds a call to function meter at the top of every loop in a
+// code section's function bodies, returning the new payload and the number
+// of loops metered
+func meterBodies(payload []byte, meter uint32) ([]byte, int, error) {
+	count, rest, err := readCount(payload)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	out := binary.AppendUvarint(nil, count)
+	loops := 0
+	for i := uint64(0); i < count; i++ {
+		size, n := binary.Uvarint(rest)
+		if n <= 0 || size > uint64(len(rest)-n) {
+			return nil, 0, errMalformedModule
+		}
+		body, found, err := meterBody(rest[n:n+int(size)], meter)
+		if err != nil {
+			return nil, 0, err
+		}
+		rest = rest[n+int(size):]
+		loops += found
+
+		out = binary.AppendUvarint(out, uint64(len(body)))
+		out = append(out, body...)
+	}
+	if len(rest) > 0 {
+		return nil, 0, errMalformedModule
+	}
+	return out, loops, nil
+}
+
+// meterBody adds a call to function meter at the top of every loop in one
+// function body, returning the new body and the number of loops metered
+func meterBody(body []byte, meter uint32) ([]byte, int, error) {
+	r := bytes.NewReader(body)
+	offset := func() int { return len(body) - r.Len() }
+
+	// Local declarations: a count of runs, each a count and a type
+	runs, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, 0, errMalformedModule
+	}
+	for i := uint64(0); i < runs; i++ {
+		if _, err := binary.ReadUvarint(r); err != nil {
+			return nil, 0, errMalformedModule
+		}
+		if _, err := r.ReadByte(); err != nil {
+			return nil, 0, errMalformedModule
+		}
+	}
+
+	call := binary.AppendUvarint([]byte{wasmOpCall}, uint64(meter))
+	out := make([]byte, 0, len(body))
+	out = append(out, body[:offset()]...)
+	loops := 0
+	for r.Len() > 0 {
+		start := offset()
+		op, _ := r.ReadByte()
+		if err := skipImmediates(op, r); err != nil {
+			return nil, 0, fmt.Errorf("%w: %v at offset %d", errMalformedModule, err, start)
+		}
+		out = append(out, body[start:offset()]...)
+		if op == wasmOpLoop {
+			out = append(out, call...)
+			loops++
+		}
+	}
+	return out, loops, nil
+}
+
+// skipImmediates reads past the immediate operands of the instruction with
+// the given opcode. Opcodes the runtime doesn't support are rejected.
+func skipImmediates(op byte, r *bytes.Reader) error {
+	switch {
+	case op == 0x02 || op == 0x03 || op == 0x04: // block, loop, if: block type
+		return skipLEB(r, 1)
+	case op == 0x0c || op == 0x0d: // br, br_if: label
+		return skipLEB(r, 1)
+	case op == 0x0e: // br_table: labels and a default
+		labels, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		return skipLEB(r, labels+1)
+	case op == 0x10: // call: function
+		return skipLEB(r, 1)
+	case op == 0x11: // call_indirect: type and table
+		return skipLEB(r, 2)
+	case op == 0x1c: // typed select: value types
+		types, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		return skipBytes(r, types)
+	case op >= 0x20 && op <= 0x26: // local, global and table access: index
+		return skipLEB(r, 1)
+	case op >= 0x28 && op <= 0x3e: // loads and stores: alignment and offset
+		return skipLEB(r, 2)
+	case op == 0x3f || op == 0x40: // memory.size, memory.grow: memory
+		return skipLEB(r, 1)
+	case op == 0x41 || op == 0x42: // i32.const, i64.const
+		return skipLEB(r, 1)
+	case op == 0x43: // f32.const
+		return skipBytes(r, 4)
+	case op == 0x44: // f64.const
+		return skipBytes(r, 8)
+	case op == 0xd0: // ref.null: reference type
+		return skipBytes(r, 1)
+	case op == 0xd2: // ref.func: function
+		return skipLEB(r, 1)
+	case op == 0xfc:
+		return skipMiscImmediates(r)
+	case op == 0xfd:
+		return skipVectorImmediates(r)
+	case op <= 0x01, op == 0x05, op == 0x0b, op == 0x0f, op == 0x1a, op == 0x1b,
+		op >= 0x45 && op <= 0xc4, op == 0xd1:
+		return nil
+	}
+	return fmt.Errorf("unsupported opcode 0x%02x", op)
+}
+
+// skipMiscImmediates reads past a 0xfc-prefixed instruction: the saturating
+// truncations and the bulk memory and table operations
+func skipMiscImmediates(r *bytes.Reader) error {
+	op, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	switch {
+	case op <= 7: // trunc_sat
+		return nil
+	case op == 8: // memory.init: data segment and memory
+		if err := skipLEB(r, 1); err != nil {
+			return err
+		}
+		return skipBytes(r, 1)
+	case op == 9: // data.drop
+		return skipLEB(r, 1)
+	case op == 10: // memory.copy: two memories
+		return skipBytes(r, 2)
+	case op == 11: // memory.fill: memory
+		return skipBytes(r, 1)
+	case op == 12 || op == 14: // table.init, table.copy
+		return skipLEB(r, 2)
+	case op == 13 || (op >= 15 && op <= 17): // elem.drop, table.grow, table.size, table.fill
+		return skipLEB(r, 1)
+	}
+	return fmt.Errorf("unsupported opcode 0xfc %d", op)
+}
+
+// skipVectorImmediates reads past a 0xfd-prefixed SIMD instruction
+func skipVectorImmediates(r *bytes.Reader) error {
+	op, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	switch {
+	case op <= 11 || op == 92 || op == 93: // loads and stores: memory argument
+		return skipLEB(r, 2)
+	case op == 12 || op == 13: // v128.const, i8x16.shuffle
+		return skipBytes(r, 16)
+	case op >= 21 && op <= 34: // lane extracts and replacements
+		return skipBytes(r, 1)
+	case op >= 84 && op <= 91: // lane loads and stores: memory argument and lane
+		if err := skipLEB(r, 2); err != nil {
+			return err
+		}
+		return skipBytes(r, 1)
+	case op <= 255:
+		return nil
+	}
+	return fmt.Errorf("unsupported opcode 0xfd %d", op)
+}
+
+// skipLEB reads past count LEB128 numbers, signed or not
+func skipLEB(r *bytes.Reader, count uint64) error {
+	for i := uint64(0); i < count; i++ {
+		for n := 0; ; n++ {
+			b, err := r.ReadByte()
+			if err != nil {
+				return err
+			}
+			if b < 0x80 {
+				break
+			}
+			if n == 9 {
+				return errMalformedModule
+			}
+		}
+	}
+	return nil
+}
+
+// skipBytes reads past count bytes
+func skipBytes(r *bytes.Reader, count uint64) error {
+	if count > uint64(r.Len()) {
+		return io.ErrUnexpectedEOF
+	}
+	_, err := r.Seek(int64(count), io.SeekCurrent)
+	return err
+}
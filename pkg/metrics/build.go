@@ -0,0 +1,17 @@
+package metrics
+
+import (
+	"github.com/anekazek/simple-blockchain/pkg/version"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// registerBuildInfo registers the build info gauge, labeled with the
+// running build's details
+func (m *BlockchainMetrics) registerBuildInfo(factory promauto.Factory) {
+	info := version.Get()
+	factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "blockchain_build_info",
+		Help: "Always 1, labeled with the build's version, commit and Go version",
+	}, []string{"version", "commit", "go_version"}).WithLabelValues(info.Version, info.Commit, info.GoVersion).Set(1)
+}
@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/version"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestBuildInfoMetric(t *testing.T) {
+	saved := [2]string{version.Version, version.Commit}
+	version.Version, version.Commit = "v1.2.3", "abc123"
+	defer func() { version.Version, version.Commit = saved[0], saved[1] }()
+
+	m := NewBlockchainMetricsWithRegistry(prometheus.NewRegistry())
+	want := fmt.Sprintf(`blockchain_build_info{commit="abc123",go_version=%q,version="v1.2.3"} 1`, runtime.Version())
+	if scraped := scrape(t, m); !strings.Contains(scraped, want) {
+		t.Errorf("missing %s", want)
+	}
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ChainSource reports the height of a chain
+type ChainSource interface {
+	Height() int
+}
+
+// PoolSource reports the size of a transaction pool
+type PoolSource interface {
+	Count() int
+	MaxSize() int
+}
+
+// chainSources are the chain and pool the gauges read at scrape time
+type chainSources struct {
+	chain ChainSource
+	pool  PoolSource
+	mutex sync.RWMutex
+}
+
+// registerChainGauges registers the gauges reading the observed chain and
+// pool, which report 0 until ObserveChain is called
+func (m *BlockchainMetrics) registerChainGauges(factory promauto.Factory) {
+	factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "blockchain_height",
+		Help: "Index of the latest block on the chain",
+	}, func() float64 {
+		if chain, _ := m.observed(); chain != nil {
+			return float64(chain.Height())
+		}
+		return 0
+	})
+	factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "blockchain_txpool_pending",
+		Help: "Transactions waiting in the pool",
+	}, func() float64 {
+		if _, pool := m.observed(); pool != nil {
+			return float64(pool.Count())
+		}
+		return 0
+	})
+	factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "blockchain_txpool_capacity",
+		Help: "Maximum number of transactions the pool holds",
+	}, func() float64 {
+		if _, pool := m.observed(); pool != nil {
+			return float64(pool.MaxSize())
+		}
+		return 0
+	})
+}
+
+// ObserveChain makes the height and pool gauges report the given chain and
+// pool, read at every scrape so they can't drift. Either may be nil.
+func (m *BlockchainMetrics) ObserveChain(chain ChainSource, pool PoolSource) {
+	m.sources.mutex.Lock()
+	defer m.sources.mutex.Unlock()
+	m.sources.chain, m.sources.pool = chain, pool
+}
+
+// observed returns the chain and pool the gauges report
+func (m *BlockchainMetrics) observed() (ChainSource, PoolSource) {
+	m.sources.mutex.RLock()
+	defer m.sources.mutex.RUnlock()
+	return m.sources.chain, m.sources.pool
+}
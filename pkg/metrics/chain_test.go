@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// expectGauge fails unless the scrape reports the gauge at value
+func expectGauge(t *testing.T, scraped, name, value string) {
+	t.Helper()
+	if !strings.Contains(scraped, "\n"+name+" "+value+"\n") {
+		t.Errorf("want %s %s in:\n%s", name, value, scraped)
+	}
+}
+
+func TestChainGaugesFollowChainAndPool(t *testing.T) {
+	m := NewBlockchainMetricsWithRegistry(prometheus.NewRegistry())
+	expectGauge(t, scrape(t, m), "blockchain_height", "0")
+
+	chain := blockchain.NewBlockchain()
+	pool := blockchain.NewTransactionPool(10)
+	m.ObserveChain(chain, pool)
+	scraped := scrape(t, m)
+	expectGauge(t, scraped, "blockchain_height", "0")
+	expectGauge(t, scraped, "blockchain_txpool_pending", "0")
+	expectGauge(t, scraped, "blockchain_txpool_capacity", "10")
+
+	if err := pool.AddTransaction(&blockchain.Transaction{ID: "tx", To: "bob", Timestamp: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	expectGauge(t, scrape(t, m), "blockchain_txpool_pending", "1")
+
+	if _, err := chain.AddBlock(pool, 0); err != nil {
+		t.Fatal(err)
+	}
+	scraped = scrape(t, m)
+	expectGauge(t, scraped, "blockchain_height", "1")
+	expectGauge(t, scraped, "blockchain_txpool_pending", "0")
+}
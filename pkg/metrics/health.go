@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// healthStatus is the body of the /healthz endpoint
+type healthStatus struct {
+	Healthy       bool    `json:"healthy"`
+	UptimeSeconds float64 `json:"uptimeSeconds"`
+}
+
+// registerNodeMetrics registers the uptime and node info gauges
+func (m *BlockchainMetrics) registerNodeMetrics(factory promauto.Factory) {
+	factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "blockchain_uptime_seconds",
+		Help: "Seconds since the node started",
+	}, m.GetUptime)
+	m.nodeInfo = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "blockchain_node_info",
+		Help: "Always 1, labeled with the node's version, commit and consensus algorithm",
+	}, []string{"version", "commit", "consensus"})
+}
+
+// SetNodeInfo labels the node info gauge, replacing earlier labels
+func (m *BlockchainMetrics) SetNodeInfo(version, commit, consensus string) {
+	m.nodeInfo.Reset()
+	m.nodeInfo.WithLabelValues(version, commit, consensus).Set(1)
+}
+
+// Healthy reports the health last set with SetNodeHealth
+func (m *BlockchainMetrics) Healthy() bool {
+	return m.healthy.Load()
+}
+
+// HealthHandler returns a liveness handler answering 200 with the uptime
+// while the node is healthy, and 503 otherwise
+func (m *BlockchainMetrics) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := healthStatus{Healthy: m.Healthy(), UptimeSeconds: m.GetUptime()}
+		w.Header().Set("Content-Type", "application/json")
+		if !status.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(status)
+	})
+}
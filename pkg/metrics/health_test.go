@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// get serves a request from the metrics server's routes
+func get(m *BlockchainMetrics, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	m.serveMux().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestHealthzFollowsNodeHealth(t *testing.T) {
+	m := NewBlockchainMetricsWithRegistry(prometheus.NewRegistry())
+
+	w := get(m, "/healthz")
+	var status healthStatus
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || !status.Healthy || status.UptimeSeconds <= 0 {
+		t.Fatalf("healthy node answered %d %+v", w.Code, status)
+	}
+
+	m.SetNodeHealth(false)
+	if w := get(m, "/healthz"); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unhealthy node answered %d, want 503", w.Code)
+	}
+	m.SetNodeHealth(true)
+	if w := get(m, "/healthz"); w.Code != http.StatusOK {
+		t.Fatalf("recovered node answered %d", w.Code)
+	}
+}
+
+func TestUptimeAndNodeInfoAreScraped(t *testing.T) {
+	m := NewBlockchainMetricsWithRegistry(prometheus.NewRegistry())
+	m.SetNodeInfo("1.0.0", "abc123", "pow")
+	m.SetNodeInfo("1.1.0", "def456", "pos")
+
+	scraped := get(m, "/metrics").Body.String()
+	if !strings.Contains(scraped, "\nblockchain_uptime_seconds ") {
+		t.Error("uptime gauge missing")
+	}
+	if !strings.Contains(scraped, `blockchain_node_info{commit="def456",consensus="pos",version="1.1.0"} 1`) {
+		t.Errorf("node info missing:\n%s", scraped)
+	}
+	if strings.Contains(scraped, `version="1.0.0"`) {
+		t.Error("replaced node info still scraped")
+	}
+}
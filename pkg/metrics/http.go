@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// httpCollectors track the API server's HTTP traffic
+type httpCollectors struct {
+	requests     *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	responseSize *prometheus.HistogramVec
+}
+
+// registerHTTPMetrics registers the HTTP collectors
+func (m *BlockchainMetrics) registerHTTPMetrics(factory promauto.Factory) {
+	m.http = httpCollectors{
+		requests: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "api_http_requests_total",
+			Help: "HTTP requests served, by route template, method and status",
+		}, []string{"route", "method", "status"}),
+		duration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "api_http_request_duration_seconds",
+			Help:    "Time taken to serve HTTP requests, by route template and method",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		responseSize: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "api_http_response_size_bytes",
+			Help:    "Size of HTTP response bodies, by route template and method",
+			Buckets: prometheus.ExponentialBuckets(100, 4, 8),
+		}, []string{"route", "method"}),
+	}
+}
+
+// HTTPRequest records a request served by the API server
+func (m *BlockchainMetrics) HTTPRequest(route, method string, status int, duration time.Duration, responseBytes int) {
+	m.http.requests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	m.http.duration.WithLabelValues(route, method).Observe(duration.Seconds())
+	m.http.responseSize.WithLabelValues(route, method).Observe(float64(responseBytes))
+}
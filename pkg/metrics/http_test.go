@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestHTTPRequestSeriesAreLabeled(t *testing.T) {
+	m := NewBlockchainMetricsWithRegistry(prometheus.NewRegistry())
+	m.HTTPRequest("/api/blockchain", "GET", 200, time.Millisecond, 512)
+	m.HTTPRequest("/api/blockchain", "GET", 200, time.Millisecond, 512)
+	m.HTTPRequest("/api/contracts/{id}", "GET", 404, time.Millisecond, 20)
+
+	scraped := scrape(t, m)
+	for _, series := range []string{
+		`api_http_requests_total{method="GET",route="/api/blockchain",status="200"} 2`,
+		`api_http_requests_total{method="GET",route="/api/contracts/{id}",status="404"} 1`,
+		`api_http_request_duration_seconds_count{method="GET",route="/api/blockchain"} 2`,
+		`api_http_response_size_bytes_sum{method="GET",route="/api/blockchain"} 1024`,
+	} {
+		if !strings.Contains(scraped, series) {
+			t.Errorf("missing %s", series)
+		}
+	}
+}
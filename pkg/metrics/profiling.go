@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+)
+
+// ProfilingConfig controls the pprof endpoints of the metrics server
+type ProfilingConfig struct {
+	// Enabled serves /debug/pprof/ on the metrics server. The profiles
+	// expose the node's internals and profiling costs CPU, so the metrics
+	// port must not be reachable from the public internet when enabled.
+	Enabled bool
+	// MutexProfileFraction samples 1 in n mutex contention events, 0
+	// leaving mutex profiling off
+	MutexProfileFraction int
+	// BlockProfileRate samples one blocking event per n nanoseconds spent
+	// blocked, 0 leaving block profiling off
+	BlockProfileRate int
+}
+
+// DefaultProfilingConfig returns the default profiling settings: disabled
+func DefaultProfilingConfig() ProfilingConfig {
+	return ProfilingConfig{}
+}
+
+// SetProfiling configures the pprof endpoints. It must be called before
+// StartServer, and applies the sampling rates to the whole process.
+func (m *BlockchainMetrics) SetProfiling(config ProfilingConfig) {
+	m.profiling = config
+	if !config.Enabled {
+		return
+	}
+	runtime.SetMutexProfileFraction(config.MutexProfileFraction)
+	runtime.SetBlockProfileRate(config.BlockProfileRate)
+}
+
+// registerProfiling adds the pprof handlers to mux when profiling is enabled
+func (m *BlockchainMetrics) registerProfiling(mux *http.ServeMux) {
+	if !m.profiling.Enabled {
+		return
+	}
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestPprofServedWhenEnabled(t *testing.T) {
+	m := NewBlockchainMetricsWithRegistry(prometheus.NewRegistry())
+	m.SetProfiling(ProfilingConfig{Enabled: true, MutexProfileFraction: 5})
+	defer runtime.SetMutexProfileFraction(0)
+
+	w := get(m, "/debug/pprof/goroutine?debug=1")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine profile:") {
+		t.Fatalf("goroutine profile answered %d: %.200s", w.Code, w.Body)
+	}
+	if got := runtime.SetMutexProfileFraction(-1); got != 5 {
+		t.Fatalf("mutex profile fraction is %d, want 5", got)
+	}
+}
+
+func TestPprofNotServedByDefault(t *testing.T) {
+	m := NewBlockchainMetricsWithRegistry(prometheus.NewRegistry())
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/goroutine?debug=1", "/debug/pprof/cmdline"} {
+		if w := get(m, path); w.Code != http.StatusNotFound {
+			t.Errorf("%s answered %d, want 404", path, w.Code)
+		}
+	}
+}
@@ -1,17 +1,26 @@
 package metrics
 
 import (
-	"log"
+	"context"
+	"errors"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-// BlockchainMetrics collects and exposes blockchain metrics
+// BlockchainMetrics collects and exposes blockchain metrics. Each instance
+// registers its collectors on its own registry, so several can live in one
+// process.
 type BlockchainMetrics struct {
+	registry *prometheus.Registry
+	server   *MetricsServer // Set once StartServer runs
+
 	// Metrics collectors
 	blockCounter       prometheus.Counter
 	blockTime          prometheus.Histogram
@@ -21,71 +30,151 @@ type BlockchainMetrics struct {
 	nodeHealth         prometheus.Gauge
 	blockSize          prometheus.Histogram
 	consensusRoundTime prometheus.Histogram
+	p2pRawBytes        prometheus.Counter
+	p2pCompressedBytes prometheus.Counter
+	p2pMessages        *prometheus.CounterVec
+	p2pInvalidMessages prometheus.Counter
+	p2pBytes           *prometheus.CounterVec
+	syncDuration       prometheus.Histogram
+	peerDeliveryRatio  *prometheus.GaugeVec
+	contractQueueDepth *prometheus.GaugeVec
+	sources            chainSources // What the height and pool gauges read
+	websocket          websocketCollectors
+	http               httpCollectors
+	nodeInfo           *prometheus.GaugeVec
+	healthy            atomic.Bool // Mirrors nodeHealth for /healthz
+	profiling          ProfilingConfig
+	storage            storageCollectors
+	pushFailures       prometheus.Counter
+	push               *pushLoop // Set while push mode runs
+	pushMutex          sync.Mutex
 
 	// Start time for calculating uptime
 	startTime time.Time
 }
 
-// NewBlockchainMetrics creates and registers blockchain metrics
+// NewBlockchainMetrics creates blockchain metrics on a private registry that
+// also reports the Go runtime and process metrics
 func NewBlockchainMetrics() *BlockchainMetrics {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return NewBlockchainMetricsWithRegistry(registry)
+}
+
+// NewBlockchainMetricsWithRegistry creates blockchain metrics registered on
+// the given registry, which the metrics server then exposes. It panics if
+// the registry already holds blockchain metrics.
+func NewBlockchainMetricsWithRegistry(registry *prometheus.Registry) *BlockchainMetrics {
+	factory := promauto.With(registry)
 	m := &BlockchainMetrics{
+		registry:  registry,
 		startTime: time.Now(),
-		blockCounter: promauto.NewCounter(prometheus.CounterOpts{
+		blockCounter: factory.NewCounter(prometheus.CounterOpts{
 			Name: "blockchain_blocks_total",
 			Help: "The total number of blocks in the blockchain",
 		}),
-		blockTime: promauto.NewHistogram(prometheus.HistogramOpts{
+		blockTime: factory.NewHistogram(prometheus.HistogramOpts{
 			Name:    "blockchain_block_processing_time_seconds",
 			Help:    "Time taken to process and add a new block",
 			Buckets: prometheus.LinearBuckets(0.1, 0.1, 10),
 		}),
-		transactionCounter: promauto.NewCounter(prometheus.CounterOpts{
+		transactionCounter: factory.NewCounter(prometheus.CounterOpts{
 			Name: "blockchain_transactions_total",
 			Help: "The total number of transactions processed",
 		}),
-		transactionTime: promauto.NewHistogram(prometheus.HistogramOpts{
+		transactionTime: factory.NewHistogram(prometheus.HistogramOpts{
 			Name:    "blockchain_transaction_processing_time_seconds",
 			Help:    "Time taken to process a transaction",
 			Buckets: prometheus.LinearBuckets(0.01, 0.01, 10),
 		}),
-		peerCount: promauto.NewGauge(prometheus.GaugeOpts{
+		peerCount: factory.NewGauge(prometheus.GaugeOpts{
 			Name: "blockchain_peer_count",
 			Help: "The current number of connected peers",
 		}),
-		nodeHealth: promauto.NewGauge(prometheus.GaugeOpts{
+		nodeHealth: factory.NewGauge(prometheus.GaugeOpts{
 			Name: "blockchain_node_health",
 			Help: "Node health status (1 = healthy, 0 = unhealthy)",
 		}),
-		blockSize: promauto.NewHistogram(prometheus.HistogramOpts{
+		blockSize: factory.NewHistogram(prometheus.HistogramOpts{
 			Name:    "blockchain_block_size_bytes",
 			Help:    "Size of blocks in bytes",
 			Buckets: prometheus.ExponentialBuckets(100, 2, 10),
 		}),
-		consensusRoundTime: promauto.NewHistogram(prometheus.HistogramOpts{
+		consensusRoundTime: factory.NewHistogram(prometheus.HistogramOpts{
 			Name:    "blockchain_consensus_round_time_seconds",
 			Help:    "Time taken to complete a consensus round",
 			Buckets: prometheus.LinearBuckets(0.5, 0.5, 10),
 		}),
+		p2pRawBytes: factory.NewCounter(prometheus.CounterOpts{
+			Name: "blockchain_p2p_raw_bytes_total",
+			Help: "Uncompressed size of P2P payloads sent or received with gzip",
+		}),
+		p2pCompressedBytes: factory.NewCounter(prometheus.CounterOpts{
+			Name: "blockchain_p2p_compressed_bytes_total",
+			Help: "On-the-wire size of P2P payloads sent or received with gzip",
+		}),
+		p2pMessages: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "blockchain_p2p_messages_total",
+			Help: "Gossip messages exchanged with peers, by direction and message type",
+		}, []string{"direction", "type"}),
+		p2pInvalidMessages: factory.NewCounter(prometheus.CounterOpts{
+			Name: "blockchain_p2p_invalid_messages_total",
+			Help: "Peer messages rejected as malformed, oversized, unauthenticated, or invalid",
+		}),
+		p2pBytes: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "blockchain_p2p_bytes_total",
+			Help: "Bytes exchanged with peers on the wire, by direction",
+		}, []string{"direction"}),
+		syncDuration: factory.NewHistogram(prometheus.HistogramOpts{
+			Name:    "blockchain_sync_duration_seconds",
+			Help:    "Time taken to catch up with peers during block sync",
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 12),
+		}),
+		peerDeliveryRatio: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "blockchain_peer_delivery_ratio",
+			Help: "Share of broadcasts delivered to each peer, after retries",
+		}, []string{"peer"}),
+		contractQueueDepth: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "blockchain_contract_queue_depth",
+			Help: "State-mutating calls of each contract running or waiting to run",
+		}, []string{"contract"}),
 	}
 
+	m.registerChainGauges(factory)
+	m.registerWebSocketMetrics(factory)
+	m.registerHTTPMetrics(factory)
+	m.registerNodeMetrics(factory)
+	m.registerStorageMetrics(factory)
+	m.registerPushMetrics(factory)
+	m.registerBuildInfo(factory)
+
 	// Set initial health to healthy
-	m.nodeHealth.Set(1)
+	m.SetNodeHealth(true)
 
 	return m
 }
 
-// StartServer starts the metrics HTTP server
-func (m *BlockchainMetrics) StartServer(port string) {
-	// Register the metrics handler
-	http.Handle("/metrics", promhttp.Handler())
+// Registry returns the registry the metrics are registered on
+func (m *BlockchainMetrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler returns an HTTP handler serving the registry's metrics
+func (m *BlockchainMetrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
 
-	// Start the HTTP server in a goroutine
-	go func() {
-		log.Printf("Metrics server listening on :%s/metrics\n", port)
-		if err := http.ListenAndServe(":"+port, nil); err != nil {
-			log.Printf("Metrics server error: %v\n", err)
-		}
-	}()
+// Shutdown stops push mode after a final push and the metrics HTTP
+// server, whichever are running
+func (m *BlockchainMetrics) Shutdown(ctx context.Context) error {
+	pushErr := m.StopPush(ctx)
+	if m.server == nil {
+		return pushErr
+	}
+	return errors.Join(pushErr, m.server.Stop(ctx))
 }
 
 // BlockAdded records metrics when a new block is added
@@ -108,6 +197,7 @@ func (m *BlockchainMetrics) UpdatePeerCount(count int) {
 
 // SetNodeHealth updates the node health status
 func (m *BlockchainMetrics) SetNodeHealth(healthy bool) {
+	m.healthy.Store(healthy)
 	if healthy {
 		m.nodeHealth.Set(1)
 	} else {
@@ -120,6 +210,58 @@ func (m *BlockchainMetrics) RecordConsensusRound(duration time.Duration) {
 	m.consensusRoundTime.Observe(duration.Seconds())
 }
 
+// RecordTransferBytes records the raw and compressed size of a gzip P2P payload
+func (m *BlockchainMetrics) RecordTransferBytes(raw, compressed int64) {
+	m.p2pRawBytes.Add(float64(raw))
+	m.p2pCompressedBytes.Add(float64(compressed))
+}
+
+// PeerMessageSent records a gossip message sent to a peer
+func (m *BlockchainMetrics) PeerMessageSent(msgType string) {
+	m.p2pMessages.WithLabelValues("out", msgType).Inc()
+}
+
+// PeerMessageReceived records a gossip message received from a peer
+func (m *BlockchainMetrics) PeerMessageReceived(msgType string) {
+	m.p2pMessages.WithLabelValues("in", msgType).Inc()
+}
+
+// InvalidPeerMessage records a rejected peer message
+func (m *BlockchainMetrics) InvalidPeerMessage() {
+	m.p2pInvalidMessages.Inc()
+}
+
+// PeerBytesSent records bytes written to peers
+func (m *BlockchainMetrics) PeerBytesSent(n int64) {
+	m.p2pBytes.WithLabelValues("out").Add(float64(n))
+}
+
+// PeerBytesReceived records bytes read from peers
+func (m *BlockchainMetrics) PeerBytesReceived(n int64) {
+	m.p2pBytes.WithLabelValues("in").Add(float64(n))
+}
+
+// RecordSync records the time taken by a block sync
+func (m *BlockchainMetrics) RecordSync(duration time.Duration) {
+	m.syncDuration.Observe(duration.Seconds())
+}
+
+// PeerDeliveryRatio records the share of broadcasts delivered to a peer
+func (m *BlockchainMetrics) PeerDeliveryRatio(peer string, ratio float64) {
+	m.peerDeliveryRatio.WithLabelValues(peer).Set(ratio)
+}
+
+// PeerRemoved drops the per-peer series of a forgotten peer
+func (m *BlockchainMetrics) PeerRemoved(peer string) {
+	m.peerDeliveryRatio.DeleteLabelValues(peer)
+}
+
+// ContractQueueDepth records how many mutating calls of a contract are
+// running or waiting
+func (m *BlockchainMetrics) ContractQueueDepth(contractID string, depth int) {
+	m.contractQueueDepth.WithLabelValues(contractID).Set(float64(depth))
+}
+
 // GetUptime returns the node uptime in seconds
 func (m *BlockchainMetrics) GetUptime() float64 {
 	return time.Since(m.startTime).Seconds()
@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// Errors returned when configuring push mode
+var (
+	ErrInvalidPushConfig = errors.New("invalid push configuration")
+	ErrPushRunning       = errors.New("metrics push already running")
+)
+
+// pushLogInterval is how often push failures are logged at most
+const pushLogInterval = time.Minute
+
+// PushConfig configures pushing metrics to a Prometheus Pushgateway, for
+// nodes Prometheus can't scrape
+type PushConfig struct {
+	// URL is the Pushgateway's base URL, such as http://gateway:9091
+	URL string
+	// Job names the pushed metrics group
+	Job string
+	// Instance, when set, adds an instance label to the group so several
+	// nodes can push under one job
+	Instance string
+	// Interval is how often metrics are pushed
+	Interval time.Duration
+	// Timeout bounds each push
+	Timeout time.Duration
+}
+
+// DefaultPushConfig returns the default push settings, with no gateway
+func DefaultPushConfig() PushConfig {
+	return PushConfig{
+		Job:      "simple-blockchain",
+		Interval: 15 * time.Second,
+		Timeout:  10 * time.Second,
+	}
+}
+
+// pushLoop pushes the registry until stopped
+type pushLoop struct {
+	pusher  *push.Pusher
+	config  PushConfig
+	stop    chan struct{}
+	done    chan struct{}
+	lastLog time.Time
+}
+
+// registerPushMetrics registers the push failure counter
+func (m *BlockchainMetrics) registerPushMetrics(factory promauto.Factory) {
+	m.pushFailures = factory.NewCounter(prometheus.CounterOpts{
+		Name: "metrics_push_failures_total",
+		Help: "Failed pushes to the Pushgateway",
+	})
+}
+
+// StartPush pushes the metrics to a Pushgateway every interval until
+// StopPush or Shutdown, which push a last time. Scraping keeps working.
+func (m *BlockchainMetrics) StartPush(config PushConfig) error {
+	if config.URL == "" || config.Job == "" || config.Interval <= 0 {
+		return ErrInvalidPushConfig
+	}
+
+	m.pushMutex.Lock()
+	defer m.pushMutex.Unlock()
+	if m.push != nil {
+		return ErrPushRunning
+	}
+
+	pusher := push.New(config.URL, config.Job).Gatherer(m.registry)
+	if config.Instance != "" {
+		pusher = pusher.Grouping("instance", config.Instance)
+	}
+	loop := &pushLoop{pusher: pusher, config: config, stop: make(chan struct{}), done: make(chan struct{})}
+	m.push = loop
+	go m.runPush(loop)
+	return nil
+}
+
+// runPush pushes on every tick until the loop is stopped
+func (m *BlockchainMetrics) runPush(loop *pushLoop) {
+	defer close(loop.done)
+
+	ticker := time.NewTicker(loop.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.pushOnce(context.Background(), loop)
+		case <-loop.stop:
+			return
+		}
+	}
+}
+
+// pushOnce pushes the registry, counting and occasionally logging failures
+func (m *BlockchainMetrics) pushOnce(ctx context.Context, loop *pushLoop) error {
+	if loop.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, loop.config.Timeout)
+		defer cancel()
+	}
+
+	err := loop.pusher.PushContext(ctx)
+	if err != nil {
+		m.pushFailures.Inc()
+		if time.Since(loop.lastLog) >= pushLogInterval {
+			loop.lastLog = time.Now()
+			log.Printf("Metrics push to %s failed: %v\n", loop.config.URL, err)
+		}
+	}
+	return err
+}
+
+// StopPush stops pushing after a final push, returning its error. It does
+// nothing when push mode isn't running.
+func (m *BlockchainMetrics) StopPush(ctx context.Context) error {
+	m.pushMutex.Lock()
+	loop := m.push
+	m.push = nil
+	m.pushMutex.Unlock()
+	if loop == nil {
+		return nil
+	}
+
+	close(loop.stop)
+	select {
+	case <-loop.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return m.pushOnce(ctx, loop)
+}
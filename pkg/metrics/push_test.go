@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fakeGateway counts the pushes it receives, answering each with status
+type fakeGateway struct {
+	*httptest.Server
+	pushes atomic.Int32
+	path   atomic.Value
+}
+
+func newFakeGateway(t *testing.T, status int) *fakeGateway {
+	t.Helper()
+	g := &fakeGateway{}
+	g.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.path.Store(r.Method + " " + r.URL.Path)
+		g.pushes.Add(1)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(g.Close)
+	return g
+}
+
+func TestPushesPeriodicallyAndOnStop(t *testing.T) {
+	gateway := newFakeGateway(t, http.StatusOK)
+	m := NewBlockchainMetricsWithRegistry(prometheus.NewRegistry())
+	err := m.StartPush(PushConfig{URL: gateway.URL, Job: "node", Instance: "a", Interval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.StartPush(PushConfig{URL: gateway.URL, Job: "node", Interval: time.Second}); !errors.Is(err, ErrPushRunning) {
+		t.Errorf("second start returned %v, want ErrPushRunning", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for gateway.pushes.Load() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d pushes before the deadline", gateway.pushes.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if path := gateway.path.Load(); path != "PUT /metrics/job/node/instance/a" {
+		t.Errorf("pushed with %v", path)
+	}
+
+	before := gateway.pushes.Load()
+	if err := m.StopPush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	after := gateway.pushes.Load()
+	if after == before {
+		t.Fatal("stopping didn't push a last time")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := gateway.pushes.Load(); n != after {
+		t.Errorf("%d pushes after stopping", n-after)
+	}
+	// Scraping keeps working alongside
+	if !strings.Contains(scrape(t, m), "\nmetrics_push_failures_total 0\n") {
+		t.Error("push failures counted against a healthy gateway")
+	}
+}
+
+func TestPushFailuresAreCounted(t *testing.T) {
+	gateway := newFakeGateway(t, http.StatusInternalServerError)
+	m := NewBlockchainMetricsWithRegistry(prometheus.NewRegistry())
+	if err := m.StartPush(PushConfig{URL: gateway.URL, Job: "node", Interval: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.StopPush(context.Background()); err == nil {
+		t.Fatal("final push to a failing gateway succeeded")
+	}
+	if !strings.Contains(scrape(t, m), "\nmetrics_push_failures_total 1\n") {
+		t.Error("failed push wasn't counted")
+	}
+}
+
+func TestStartPushRejectsIncompleteConfig(t *testing.T) {
+	m := NewBlockchainMetricsWithRegistry(prometheus.NewRegistry())
+	for name, config := range map[string]PushConfig{
+		"no URL":      {Job: "node", Interval: time.Second},
+		"no job":      {URL: "http://gateway", Interval: time.Second},
+		"no interval": {URL: "http://gateway", Job: "node"},
+	} {
+		if err := m.StartPush(config); !errors.Is(err, ErrInvalidPushConfig) {
+			t.Errorf("%s: got %v, want ErrInvalidPushConfig", name, err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Limits of the metrics server, matching the API server's. The write
+// timeout leaves room for CPU profiles and traces, which pprof refuses to
+// take for longer than it.
+const (
+	serverReadHeaderTimeout = 5 * time.Second
+	serverReadTimeout       = 15 * time.Second
+	serverWriteTimeout      = 2 * time.Minute
+	serverIdleTimeout       = 60 * time.Second
+	serverMaxHeaderBytes    = 1 << 20
+)
+
+// MetricsServer is a running metrics HTTP server
+type MetricsServer struct {
+	server   *http.Server
+	listener net.Listener
+	errs     chan error
+}
+
+// serveMux returns the metrics server's routes: /metrics, the /healthz
+// liveness check, and /debug/pprof/ when profiling is enabled
+func (m *BlockchainMetrics) serveMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	mux.Handle("/healthz", m.HealthHandler())
+	m.registerProfiling(mux)
+	return mux
+}
+
+// StartServer serves the metrics on port from their own mux. The port is
+// bound before it returns, so a port in use fails here; "0" picks a free one.
+func (m *BlockchainMetrics) StartServer(port string) (*MetricsServer, error) {
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for metrics: %w", err)
+	}
+
+	s := &MetricsServer{
+		server:   newHTTPServer(m.serveMux()),
+		listener: listener,
+		errs:     make(chan error, 1),
+	}
+	m.server = s
+
+	go func() {
+		defer close(s.errs)
+		log.Printf("Metrics server listening on %s/metrics\n", listener.Addr())
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.errs <- err
+		}
+	}()
+	return s, nil
+}
+
+// newHTTPServer creates an http.Server for handler with the limits applied
+func newHTTPServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: serverReadHeaderTimeout,
+		ReadTimeout:       serverReadTimeout,
+		WriteTimeout:      serverWriteTimeout,
+		IdleTimeout:       serverIdleTimeout,
+		MaxHeaderBytes:    serverMaxHeaderBytes,
+	}
+}
+
+// MountOn serves /metrics and /healthz from router, such as the API
+// server's, instead of a port of their own. The pprof endpoints are never
+// mounted: they are only served from the metrics server's private port.
+func (m *BlockchainMetrics) MountOn(router *mux.Router) {
+	routes := m.serveMux()
+	router.Handle("/metrics", routes)
+	router.Handle("/healthz", routes)
+}
+
+// Addr returns the address the server listens on
+func (s *MetricsServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Err returns a channel receiving the error that stopped the server
+// unexpectedly. It is closed once the server stops.
+func (s *MetricsServer) Err() <-chan error {
+	return s.errs
+}
+
+// Stop shuts the server down gracefully, releasing its port
+func (s *MetricsServer) Stop(ctx context.Context) error {
+	err := s.server.Shutdown(ctx)
+	// Shutdown only closes the listener once Serve has picked it up
+	s.listener.Close()
+	return err
+}
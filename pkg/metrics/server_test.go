@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// startTestServer starts a metrics server on a free port, stopped when the
+// test ends
+func startTestServer(t *testing.T) *MetricsServer {
+	t.Helper()
+	m := NewBlockchainMetricsWithRegistry(prometheus.NewRegistry())
+	server, err := m.StartServer("0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Stop(context.Background()) })
+	return server
+}
+
+// fetch gets url, failing the test unless it answers 200, and returns the body
+func fetch(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("%s answered %d", url, resp.StatusCode)
+	}
+	return string(body)
+}
+
+func TestMetricsServerReleasesPortOnStop(t *testing.T) {
+	m := NewBlockchainMetricsWithRegistry(prometheus.NewRegistry())
+	m.BlockAdded(time.Millisecond, 100)
+	server, err := m.StartServer("0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := strconv.Itoa(server.Addr().(*net.TCPAddr).Port)
+	if body := fetch(t, "http://127.0.0.1:"+port+"/metrics"); !strings.Contains(body, "blockchain_blocks_total 1") {
+		t.Fatalf("scrape returned:\n%s", body)
+	}
+
+	if err := server.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err, ok := <-server.Err(); ok {
+		t.Fatalf("stopped server reported %v", err)
+	}
+
+	// The port is free again for the next server
+	again, err := m.StartServer(port)
+	if err != nil {
+		t.Fatalf("rebinding port %s: %v", port, err)
+	}
+	defer again.Stop(context.Background())
+	fetch(t, "http://127.0.0.1:"+port+"/healthz")
+}
+
+func TestStartServerFailsOnPortInUse(t *testing.T) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	m := NewBlockchainMetricsWithRegistry(prometheus.NewRegistry())
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+	if server, err := m.StartServer(port); err == nil {
+		server.Stop(context.Background())
+		t.Fatalf("started on port %s, which is in use", port)
+	}
+}
+
+func TestMountOnServesMetricsBesideExistingRoutes(t *testing.T) {
+	m := NewBlockchainMetricsWithRegistry(prometheus.NewRegistry())
+	m.BlockAdded(time.Millisecond, 100)
+	router := mux.NewRouter()
+	router.HandleFunc("/api/stats", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "stats")
+	})
+	m.MountOn(router)
+
+	for path, want := range map[string]string{
+		"/metrics":   "blockchain_blocks_total 1",
+		"/healthz":   "",
+		"/api/stats": "stats",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), want) {
+			t.Errorf("%s answered %d:\n%s", path, w.Code, w.Body)
+		}
+	}
+}
+
+func TestMetricsServerIsHardened(t *testing.T) {
+	server := startTestServer(t).server
+	if server.ReadHeaderTimeout <= 0 || server.ReadTimeout <= 0 || server.WriteTimeout <= 0 || server.IdleTimeout <= 0 {
+		t.Errorf("server timeouts unset: header %v, read %v, write %v, idle %v",
+			server.ReadHeaderTimeout, server.ReadTimeout, server.WriteTimeout, server.IdleTimeout)
+	}
+	if server.MaxHeaderBytes != serverMaxHeaderBytes {
+		t.Errorf("MaxHeaderBytes = %d, want %d", server.MaxHeaderBytes, serverMaxHeaderBytes)
+	}
+}
+
+// scrape returns the text exposition of m's metrics
+func scrape(t *testing.T, m *BlockchainMetrics) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("scrape answered %d", w.Code)
+	}
+	return w.Body.String()
+}
+
+func TestTwoMetricsInstancesInOneProcess(t *testing.T) {
+	a, b := NewBlockchainMetrics(), NewBlockchainMetrics()
+	a.BlockAdded(time.Millisecond, 100)
+	a.BlockAdded(time.Millisecond, 100)
+	b.BlockAdded(time.Millisecond, 100)
+
+	if got := scrape(t, a); !strings.Contains(got, "blockchain_blocks_total 2") || !strings.Contains(got, "go_goroutines") {
+		t.Errorf("first instance exposes:\n%s", got)
+	}
+	if got := scrape(t, b); !strings.Contains(got, "blockchain_blocks_total 1") {
+		t.Errorf("second instance exposes:\n%s", got)
+	}
+
+	// Both servers run side by side on their own muxes
+	for _, m := range []*BlockchainMetrics{a, b} {
+		server, err := m.StartServer("0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer server.Stop(context.Background())
+		resp, err := http.Get("http://" + server.Addr().String() + "/metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("scraping %s answered %d", server.Addr(), resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package metrics
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// storageCollectors track the block store's latency and size
+type storageCollectors struct {
+	blocks   prometheus.Gauge
+	reads    *prometheus.HistogramVec
+	writes   *prometheus.HistogramVec
+	reporter storage.StatsReporter // Read at scrape time, nil until observed
+	mutex    sync.RWMutex
+}
+
+// registerStorageMetrics registers the storage collectors
+func (m *BlockchainMetrics) registerStorageMetrics(factory promauto.Factory) {
+	m.storage.blocks = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "storage_blocks_total",
+		Help: "Blocks held by the block store",
+	})
+	m.storage.reads = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "storage_read_duration_seconds",
+		Help:    "Time taken by block store reads, by operation",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 4, 8),
+	}, []string{"operation"})
+	m.storage.writes = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "storage_write_duration_seconds",
+		Help:    "Time taken by block store writes, by operation",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 4, 8),
+	}, []string{"operation"})
+	m.registry.MustRegister(storageStatsCollector{m})
+}
+
+// ObserveStorage reports a store's size, and its LevelDB internals when it
+// has them, read at every scrape
+func (m *BlockchainMetrics) ObserveStorage(reporter storage.StatsReporter) {
+	m.storage.mutex.Lock()
+	defer m.storage.mutex.Unlock()
+	m.storage.reporter = reporter
+}
+
+// Descriptions of the metrics read from a store's stats
+var (
+	storageSizeDesc = prometheus.NewDesc("storage_size_bytes",
+		"Size of the stored data on disk", nil, nil)
+	levelSizeDesc = prometheus.NewDesc("storage_leveldb_level_size_bytes",
+		"Bytes in the tables of each LevelDB level", []string{"level"}, nil)
+	levelTablesDesc = prometheus.NewDesc("storage_leveldb_level_tables",
+		"Tables in each LevelDB level", []string{"level"}, nil)
+	blockCacheDesc = prometheus.NewDesc("storage_leveldb_block_cache_bytes",
+		"Size of the LevelDB block cache", nil, nil)
+	openTablesDesc = prometheus.NewDesc("storage_leveldb_open_tables",
+		"LevelDB tables currently open", nil, nil)
+	ioReadDesc = prometheus.NewDesc("storage_leveldb_io_read_bytes_total",
+		"Bytes LevelDB has read from disk", nil, nil)
+	ioWriteDesc = prometheus.NewDesc("storage_leveldb_io_write_bytes_total",
+		"Bytes LevelDB has written to disk", nil, nil)
+	writeDelaysDesc = prometheus.NewDesc("storage_leveldb_write_delays_total",
+		"Writes LevelDB delayed while compacting", nil, nil)
+	writeDelayDesc = prometheus.NewDesc("storage_leveldb_write_delay_seconds_total",
+		"Time writes spent delayed while LevelDB compacted", nil, nil)
+	writePausedDesc = prometheus.NewDesc("storage_leveldb_write_paused",
+		"Whether LevelDB has paused writes (1) or not (0)", nil, nil)
+)
+
+// storageStatsCollector reads the observed store's stats at scrape time
+type storageStatsCollector struct {
+	m *BlockchainMetrics
+}
+
+// Describe sends the descriptions of the stats metrics
+func (c storageStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{storageSizeDesc, levelSizeDesc, levelTablesDesc, blockCacheDesc,
+		openTablesDesc, ioReadDesc, ioWriteDesc, writeDelaysDesc, writeDelayDesc, writePausedDesc} {
+		ch <- desc
+	}
+}
+
+// Collect sends the observed store's stats, or nothing if it has none
+func (c storageStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.m.storage.mutex.RLock()
+	reporter := c.m.storage.reporter
+	c.m.storage.mutex.RUnlock()
+	if reporter == nil {
+		return
+	}
+	stats, err := reporter.Stats()
+	if err != nil {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(storageSizeDesc, prometheus.GaugeValue, float64(stats.SizeBytes))
+	db := stats.LevelDB
+	if db == nil {
+		return
+	}
+	for level, size := range db.LevelSizes {
+		ch <- prometheus.MustNewConstMetric(levelSizeDesc, prometheus.GaugeValue, float64(size), strconv.Itoa(level))
+	}
+	for level, tables := range db.LevelTables {
+		ch <- prometheus.MustNewConstMetric(levelTablesDesc, prometheus.GaugeValue, float64(tables), strconv.Itoa(level))
+	}
+	paused := 0.0
+	if db.WritePaused {
+		paused = 1
+	}
+	ch <- prometheus.MustNewConstMetric(blockCacheDesc, prometheus.GaugeValue, float64(db.BlockCacheBytes))
+	ch <- prometheus.MustNewConstMetric(openTablesDesc, prometheus.GaugeValue, float64(db.OpenTables))
+	ch <- prometheus.MustNewConstMetric(ioReadDesc, prometheus.CounterValue, float64(db.IOReadBytes))
+	ch <- prometheus.MustNewConstMetric(ioWriteDesc, prometheus.CounterValue, float64(db.IOWriteBytes))
+	ch <- prometheus.MustNewConstMetric(writeDelaysDesc, prometheus.CounterValue, float64(db.WriteDelays))
+	ch <- prometheus.MustNewConstMetric(writeDelayDesc, prometheus.CounterValue, db.WriteDelay.Seconds())
+	ch <- prometheus.MustNewConstMetric(writePausedDesc, prometheus.GaugeValue, paused)
+}
+
+// InstrumentedStore implements the block store interface
+var _ storage.BlockchainStore = (*InstrumentedStore)(nil)
+
+// InstrumentedStore is a BlockchainStore that records the latency of each
+// operation and the number of blocks held in metrics
+type InstrumentedStore struct {
+	store   storage.BlockchainStore
+	metrics *BlockchainMetrics
+	blocks  atomic.Int64
+}
+
+// InstrumentStore wraps a block store to record its operations, and
+// observes its stats when it reports them
+func (m *BlockchainMetrics) InstrumentStore(store storage.BlockchainStore) *InstrumentedStore {
+	if reporter, ok := store.(storage.StatsReporter); ok {
+		m.ObserveStorage(reporter)
+	}
+	return &InstrumentedStore{store: store, metrics: m}
+}
+
+// read times a read operation
+func (s *InstrumentedStore) read(operation string, start time.Time) {
+	s.metrics.storage.reads.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// stored raises the block count to cover a block at index
+func (s *InstrumentedStore) stored(index int) {
+	count := int64(index) + 1
+	for {
+		current := s.blocks.Load()
+		if count <= current {
+			return
+		}
+		if s.blocks.CompareAndSwap(current, count) {
+			s.metrics.storage.blocks.Set(float64(count))
+			return
+		}
+	}
+}
+
+// Initialize prepares the store and counts the blocks it already holds
+func (s *InstrumentedStore) Initialize() error {
+	if err := s.store.Initialize(); err != nil {
+		return err
+	}
+	if latest, err := s.store.GetLatestBlock(); err == nil {
+		s.stored(latest.Index)
+	}
+	return nil
+}
+
+// SaveBlock persists a block, timing the write
+func (s *InstrumentedStore) SaveBlock(block blockchain.Block) error {
+	start := time.Now()
+	err := s.store.SaveBlock(block)
+	s.metrics.storage.writes.WithLabelValues("save_block").Observe(time.Since(start).Seconds())
+	if err == nil {
+		s.stored(block.Index)
+	}
+	return err
+}
+
+// GetBlock retrieves a block by its hash, timing the read
+func (s *InstrumentedStore) GetBlock(hash string) (blockchain.Block, error) {
+	defer s.read("get_block", time.Now())
+	return s.store.GetBlock(hash)
+}
+
+// GetBlockByIndex retrieves a block by its index, timing the read
+func (s *InstrumentedStore) GetBlockByIndex(index int) (blockchain.Block, error) {
+	defer s.read("get_block_by_index", time.Now())
+	return s.store.GetBlockByIndex(index)
+}
+
+// GetAllBlocks retrieves all blocks, timing the read
+func (s *InstrumentedStore) GetAllBlocks() ([]blockchain.Block, error) {
+	defer s.read("get_all_blocks", time.Now())
+	return s.store.GetAllBlocks()
+}
+
+// GetLatestBlock retrieves the most recent block, timing the read
+func (s *InstrumentedStore) GetLatestBlock() (blockchain.Block, error) {
+	defer s.read("get_latest_block", time.Now())
+	return s.store.GetLatestBlock()
+}
+
+// Truncate deletes the blocks above height, timing the write
+func (s *InstrumentedStore) Truncate(height int) error {
+	start := time.Now()
+	err := s.store.Truncate(height)
+	s.metrics.storage.writes.WithLabelValues("truncate").Observe(time.Since(start).Seconds())
+	if err == nil {
+		s.truncated(height)
+	}
+	return err
+}
+
+// truncated lowers the block count to the blocks up to height
+func (s *InstrumentedStore) truncated(height int) {
+	count := int64(height) + 1
+	for {
+		current := s.blocks.Load()
+		if count >= current {
+			return
+		}
+		if s.blocks.CompareAndSwap(current, count) {
+			s.metrics.storage.blocks.Set(float64(count))
+			return
+		}
+	}
+}
+
+// Close closes the wrapped store
+func (s *InstrumentedStore) Close() error {
+	return s.store.Close()
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/storage"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// saveBlocks saves blocks with indexes 0 to n-1
+func saveBlocks(t *testing.T, store storage.BlockchainStore, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := store.SaveBlock(blockchain.Block{BlockHeader: blockchain.BlockHeader{Index: i, Hash: fmt.Sprintf("hash-%d", i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestInstrumentedStoreRecordsOperations(t *testing.T) {
+	m := NewBlockchainMetricsWithRegistry(prometheus.NewRegistry())
+	store := m.InstrumentStore(storage.NewMemoryStore())
+	if err := store.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	saveBlocks(t, store, 3)
+	if _, err := store.GetBlock("hash-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetLatestBlock(); err != nil {
+		t.Fatal(err)
+	}
+	scraped := scrape(t, m)
+	for _, series := range []string{
+		"\nstorage_blocks_total 3\n",
+		`storage_write_duration_seconds_count{operation="save_block"} 3`,
+		`storage_read_duration_seconds_count{operation="get_block"} 1`,
+		`storage_read_duration_seconds_count{operation="get_latest_block"} 1`,
+	} {
+		if !strings.Contains(scraped, series) {
+			t.Errorf("missing %q", series)
+		}
+	}
+
+	if err := store.Truncate(0); err != nil {
+		t.Fatal(err)
+	}
+	if scraped := scrape(t, m); !strings.Contains(scraped, "\nstorage_blocks_total 1\n") {
+		t.Error("block count didn't follow the truncation")
+	}
+}
+
+func TestInstrumentedLevelDBReportsStats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain")
+	m := NewBlockchainMetricsWithRegistry(prometheus.NewRegistry())
+	store := m.InstrumentStore(storage.NewLevelDBStore(path))
+	if err := store.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	saveBlocks(t, store, 2)
+
+	// Reopening counts the blocks already stored
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	m = NewBlockchainMetricsWithRegistry(prometheus.NewRegistry())
+	store = m.InstrumentStore(storage.NewLevelDBStore(path))
+	if err := store.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	scraped := scrape(t, m)
+	for _, name := range []string{"\nstorage_blocks_total 2\n", "\nstorage_size_bytes ", "\nstorage_leveldb_open_tables "} {
+		if !strings.Contains(scraped, name) {
+			t.Errorf("missing %q", name)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// websocketCollectors track the API server's WebSocket clients and deliveries
+type websocketCollectors struct {
+	clients          prometheus.Gauge
+	messagesSent     *prometheus.CounterVec
+	sendErrors       *prometheus.CounterVec
+	broadcastDropped prometheus.Counter
+}
+
+// registerWebSocketMetrics registers the WebSocket collectors
+func (m *BlockchainMetrics) registerWebSocketMetrics(factory promauto.Factory) {
+	m.websocket = websocketCollectors{
+		clients: factory.NewGauge(prometheus.GaugeOpts{
+			Name: "api_websocket_clients",
+			Help: "WebSocket clients currently connected",
+		}),
+		messagesSent: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "api_websocket_messages_sent_total",
+			Help: "Messages written to WebSocket clients, by message type",
+		}, []string{"type"}),
+		sendErrors: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "api_websocket_send_errors_total",
+			Help: "Messages that couldn't be written to WebSocket clients, by message type",
+		}, []string{"type"}),
+		broadcastDropped: factory.NewCounter(prometheus.CounterOpts{
+			Name: "api_broadcast_dropped_total",
+			Help: "Broadcasts discarded because the broadcast queue was full",
+		}),
+	}
+}
+
+// WebSocketClients reports how many WebSocket clients are connected
+func (m *BlockchainMetrics) WebSocketClients(count int) {
+	m.websocket.clients.Set(float64(count))
+}
+
+// WebSocketMessageSent records a message written to a WebSocket client
+func (m *BlockchainMetrics) WebSocketMessageSent(messageType string) {
+	m.websocket.messagesSent.WithLabelValues(messageType).Inc()
+}
+
+// WebSocketSendError records a message that couldn't be written to a
+// WebSocket client
+func (m *BlockchainMetrics) WebSocketSendError(messageType string) {
+	m.websocket.sendErrors.WithLabelValues(messageType).Inc()
+}
+
+// BroadcastDropped records a broadcast discarded because the queue was full
+func (m *BlockchainMetrics) BroadcastDropped() {
+	m.websocket.broadcastDropped.Inc()
+}
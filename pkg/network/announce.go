@@ -0,0 +1,241 @@
+package network
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// capabilityAnnounce is advertised in the handshake by nodes that accept
+// block announcements and fetch bodies on demand
+const capabilityAnnounce = "announce"
+
+// gossipBlockAnnounce announces a block by hash without its body
+const gossipBlockAnnounce = "block-announce"
+
+// blockAnnouncement is the compact payload of a block announcement
+type blockAnnouncement struct {
+	Hash     string `json:"hash"`
+	Index    int    `json:"index"`
+	PrevHash string `json:"prevHash"`
+}
+
+// blockFetches tracks announced blocks being downloaded so each body is
+// fetched once, remembering other announcers to fall back on
+type blockFetches struct {
+	sources map[string][]string // Untried announcers by block hash
+	mutex   sync.Mutex
+}
+
+// newBlockFetches creates an empty fetch tracker
+func newBlockFetches() *blockFetches {
+	return &blockFetches{sources: make(map[string][]string)}
+}
+
+// offer records an announcer of a block and reports whether a fetch should
+// start, which is the case when none is in progress for the hash
+func (f *blockFetches) offer(hash, source string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	sources, fetching := f.sources[hash]
+	f.sources[hash] = append(sources, source)
+	return !fetching
+}
+
+// next returns the next announcer to fetch a block from. When none are left
+// the fetch ends, so a later announcement can start a new one.
+func (f *blockFetches) next(hash string) (string, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	sources := f.sources[hash]
+	if len(sources) == 0 {
+		delete(f.sources, hash)
+		return "", false
+	}
+	f.sources[hash] = sources[1:]
+	return sources[0], true
+}
+
+// done ends the fetch of a block
+func (f *blockFetches) done(hash string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.sources, hash)
+}
+
+// peerAcceptsAnnouncements reports whether a peer advertised the announce capability
+func (p *P2PServer) peerAcceptsAnnouncements(address string) bool {
+	p.peersMutex.Lock()
+	defer p.peersMutex.Unlock()
+	return p.peers[address].Announce
+}
+
+// newGossip creates a message originated and signed by this node
+func (p *P2PServer) newGossip(msgType string, payload interface{}) (GossipMessage, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return GossipMessage{}, err
+	}
+
+	msg := GossipMessage{
+		ID:        newMessageID(),
+		Type:      msgType,
+		OriginID:  p.nodeID,
+		Origin:    p.address,
+		Hops:      defaultGossipHops,
+		Payload:   data,
+		Timestamp: time.Now().Unix(),
+	}
+	msg.Signature = hex.EncodeToString(p.identity.Sign(msg.signingBytes()))
+	p.seenMsgs.Add(msg.ID)
+	return msg, nil
+}
+
+// propagateBlock passes a block on to every peer except exclude. Peers that
+// accept announcements get one from us and fetch the body if they need it;
+// other peers get the full block, relaying full when it is set or as a
+// message of our own otherwise.
+func (p *P2PServer) propagateBlock(block blockchain.Block, full *GossipMessage, exclude string) {
+	var announcing, legacy []string
+	for _, peer := range p.peerAddresses() {
+		if peer == exclude || (full != nil && peer == full.Origin) {
+			continue
+		}
+		if p.peerAcceptsAnnouncements(peer) {
+			announcing = append(announcing, peer)
+		} else {
+			legacy = append(legacy, peer)
+		}
+	}
+
+	if len(announcing) > 0 {
+		announcement := blockAnnouncement{Hash: block.Hash, Index: block.Index, PrevHash: block.PrevHash}
+		msg, err := p.newGossip(gossipBlockAnnounce, announcement)
+		if err != nil {
+			log.Printf("Failed to encode announcement of %s: %v\n", block.Hash, err)
+		} else {
+			// Receivers re-announce once they hold the block themselves
+			msg.Hops = 1
+			p.sendGossipTo(msg, announcing)
+		}
+	}
+
+	if len(legacy) == 0 {
+		return
+	}
+	if full != nil {
+		msg := *full
+		msg.Hops--
+		if msg.Hops > 0 {
+			p.sendGossipTo(msg, legacy)
+		}
+		return
+	}
+	msg, err := p.newGossip(gossipBlock, block)
+	if err != nil {
+		log.Printf("Failed to encode block %s: %v\n", block.Hash, err)
+		return
+	}
+	p.sendGossipTo(msg, legacy)
+}
+
+// processBlockAnnouncement fetches an announced block we don't have yet.
+// Blocks that extend our tip, or a longer fork, are downloaded in the
+// background from the announcer, falling back to other announcers of the same
+// block.
+func (p *P2PServer) processBlockAnnouncement(msg GossipMessage, sender string) (int, error) {
+	var announcement blockAnnouncement
+	if err := json.Unmarshal(msg.Payload, &announcement); err != nil || announcement.Hash == "" {
+		p.reportInvalid()
+		if err == nil {
+			err = errors.New("announcement without a block hash")
+		}
+		return http.StatusBadRequest, err
+	}
+
+	if _, onChain := p.chain.GetBlockByHash(announcement.Hash); onChain || p.knownBlocks.Contains(announcement.Hash) {
+		return http.StatusOK, nil
+	}
+	tip := p.chain.GetLatestBlock()
+	if announcement.PrevHash != tip.Hash && announcement.Index <= tip.Index {
+		// A fork no longer than our chain isn't worth downloading
+		return http.StatusOK, nil
+	}
+
+	source := sender
+	if source == "" {
+		source = msg.Origin
+	}
+	if p.isLight() {
+		// Light nodes only need the header, which header sync fetches
+		if _, known := p.headers.GetHeader(announcement.Hash); !known {
+			p.spawn(func() { p.syncHeadersFrom(source) })
+		}
+		return http.StatusAccepted, nil
+	}
+	if p.fetches.offer(announcement.Hash, source) {
+		p.spawn(func() { p.fetchAnnouncedBlock(announcement.Hash) })
+	}
+	return http.StatusAccepted, nil
+}
+
+// fetchAnnouncedBlock downloads an announced block from its announcers in
+// turn and adds it like a pushed block
+func (p *P2PServer) fetchAnnouncedBlock(hash string) {
+	for {
+		source, ok := p.fetches.next(hash)
+		if !ok {
+			log.Printf("Failed to fetch announced block %s from any announcer\n", hash)
+			return
+		}
+
+		block, err := p.RequestBlock(source, hash)
+		if err != nil {
+			if !quietPeerError(err) {
+				log.Printf("Failed to fetch announced block %s from %s: %v\n", hash, source, err)
+			}
+			continue
+		}
+
+		p.fetches.done(hash)
+		p.acceptFetchedBlock(block, source)
+		return
+	}
+}
+
+// acceptFetchedBlock validates a downloaded block and adds it to the chain,
+// resolving a fork if it doesn't extend our tip, and propagates it
+func (p *P2PServer) acceptFetchedBlock(block blockchain.Block, source string) {
+	tip := p.chain.GetLatestBlock()
+	var parent *blockchain.Block
+	if block.PrevHash == tip.Hash {
+		parent = &tip
+	}
+	if err := p.validateBlock(block, parent); err != nil {
+		p.penalize(source, err)
+		return
+	}
+	if !p.knownBlocks.Add(block.Hash) {
+		return
+	}
+
+	if parent == nil && block.Index > 0 {
+		p.handleOrphan(block, source, func() { p.propagateBlock(block, nil, source) })
+		return
+	}
+	if err := p.chain.AddExistingBlock(block); err != nil {
+		p.reportInvalid()
+		log.Printf("Rejected announced block %s from %s: %v\n", block.Hash, source, err)
+		return
+	}
+	log.Printf("Added announced block from peer: %s\n", block.Hash)
+	p.propagateBlock(block, nil, source)
+}
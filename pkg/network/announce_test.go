@@ -0,0 +1,62 @@
+package network
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// listenCountingBodies is listen, also counting the block bodies p serves
+func listenCountingBodies(t *testing.T, p *P2PServer) *atomic.Int32 {
+	t.Helper()
+	mux := http.NewServeMux()
+	p.RegisterRoutes(mux)
+	var bodies atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/block/") {
+			bodies.Add(1)
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	p.SetAdvertisedAddress(strings.TrimPrefix(server.URL, "http://"))
+	return &bodies
+}
+
+func TestAnnouncedBlockIsFetchedOncePerNode(t *testing.T) {
+	nodes := []*P2PServer{newTestServer(t), newTestServer(t), newTestServer(t)}
+	var served []*atomic.Int32
+	for _, p := range nodes {
+		served = append(served, listenCountingBodies(t, p))
+	}
+	for i, p := range nodes {
+		for _, peer := range nodes[:i] {
+			if err := p.ConnectPeer(peer.address); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	origin := nodes[0]
+	block := mine(t, origin.chain.GetLatestBlock(), 0)
+	if err := origin.chain.AddExistingBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	origin.BroadcastBlock(block)
+	for _, p := range nodes[1:] {
+		waitFor(t, "the announced block", func() bool { return p.chain.GetLatestBlock().Hash == block.Hash })
+	}
+
+	// Let re-announcements settle before counting
+	time.Sleep(200 * time.Millisecond)
+	var total int32
+	for _, n := range served {
+		total += n.Load()
+	}
+	if total != 2 {
+		t.Fatalf("served the block body %d times, want once to each other node", total)
+	}
+}
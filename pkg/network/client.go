@@ -0,0 +1,95 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// fullChainTimeout bounds a full-chain download, which can be much larger
+// than other peer responses
+const fullChainTimeout = 2 * time.Minute
+
+// errPeerTimeout is returned when a peer doesn't answer in time. Timeouts
+// count against the peer's liveness instead of being logged.
+var errPeerTimeout = errors.New("peer timed out")
+
+// ClientConfig holds the limits of the HTTP client used to reach peers
+type ClientConfig struct {
+	// ConnectTimeout bounds dialing and the TLS handshake
+	ConnectTimeout time.Duration
+	// RequestTimeout bounds a whole request, including reading the body
+	RequestTimeout time.Duration
+	// MaxIdleConnsPerHost caps the idle keep-alive connections kept per peer
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout closes keep-alive connections unused for this long
+	IdleConnTimeout time.Duration
+	// DialContext, when set, opens the connections to peers in place of a
+	// net.Dialer bounded by ConnectTimeout, such as to simulate network
+	// faults in tests
+	DialContext func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// DefaultClientConfig returns the default peer client limits
+func DefaultClientConfig() ClientConfig {
+	return ClientConfig{
+		ConnectTimeout:      5 * time.Second,
+		RequestTimeout:      30 * time.Second,
+		MaxIdleConnsPerHost: 4,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+// ConfigureClient sets the timeouts and connection limits for peer requests
+func (p *P2PServer) ConfigureClient(cfg ClientConfig) {
+	p.clientConfig = cfg
+	p.buildClient()
+}
+
+// buildClient rebuilds the shared peer client from the client and TLS settings
+func (p *P2PServer) buildClient() {
+	cfg := p.clientConfig
+	dial := (&net.Dialer{Timeout: cfg.ConnectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	if cfg.DialContext != nil {
+		dial = cfg.DialContext
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dial,
+		TLSClientConfig:       p.clientTLS,
+		TLSHandshakeTimeout:   cfg.ConnectTimeout,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if p.httpClient != nil {
+		if old, ok := p.httpClient.Transport.(*http.Transport); ok {
+			old.CloseIdleConnections()
+		}
+	}
+	p.httpClient = &http.Client{Transport: transport, Timeout: cfg.RequestTimeout}
+}
+
+// clientWithTimeout returns the shared peer client with a different request timeout
+func (p *P2PServer) clientWithTimeout(timeout time.Duration) *http.Client {
+	client := *p.httpClient
+	client.Timeout = timeout
+	return &client
+}
+
+// isTimeout reports whether a request failed because the peer was too slow
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// quietPeerError reports whether a peer error is already accounted for and
+// needn't be logged: a timeout counted against the peer, or a request
+// cancelled by Stop
+func quietPeerError(err error) bool {
+	return errors.Is(err, errPeerTimeout) || errors.Is(err, context.Canceled)
+}
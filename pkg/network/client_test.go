@@ -0,0 +1,84 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/network/networktest"
+)
+
+func TestHangingPeerTimesOut(t *testing.T) {
+	p := newTestServer(t)
+	cfg := DefaultClientConfig()
+	cfg.RequestTimeout = 100 * time.Millisecond
+	p.ConfigureClient(cfg)
+	address := networktest.HangingPeer(t)
+	p.AddPeer(address)
+
+	start := time.Now()
+	_, err := p.fetchHeight(address)
+	if !errors.Is(err, errPeerTimeout) {
+		t.Fatalf("got %v, want errPeerTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("gave up after %s", elapsed)
+	}
+	p.peersMutex.Lock()
+	failures := p.peers[address].ConsecutiveFailures
+	p.peersMutex.Unlock()
+	if failures != 1 {
+		t.Fatalf("counted %d failures against the peer, want 1", failures)
+	}
+}
+
+func TestStopCancelsInFlightRequests(t *testing.T) {
+	p := newTestServer(t)
+	address := networktest.HangingPeer(t)
+	p.AddPeer(address)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.fetchHeight(address)
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	p.Stop()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("got %v, want the request cancelled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request to a hanging peer outlived Stop")
+	}
+}
+
+func TestTimedOutRequestsLeakNoGoroutines(t *testing.T) {
+	p := newTestServer(t)
+	cfg := DefaultClientConfig()
+	cfg.RequestTimeout = 50 * time.Millisecond
+	p.ConfigureClient(cfg)
+	address := networktest.HangingPeer(t)
+	p.AddPeer(address)
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		if _, err := p.fetchHeight(address); !errors.Is(err, errPeerTimeout) {
+			t.Fatalf("got %v, want errPeerTimeout", err)
+		}
+	}
+
+	// Connection goroutines wind down shortly after the requests give up
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			t.Fatalf("%d goroutines before the requests, %d after:\n%s", before, runtime.NumGoroutine(), buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,201 @@
+package network
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// capabilityGzip is advertised in the handshake by nodes that accept
+// gzip-encoded request bodies
+const capabilityGzip = "gzip"
+
+// encodingGzip is the Content-Encoding value for gzip payloads
+const encodingGzip = "gzip"
+
+// CompressionStats reports how many payload bytes were moved gzip-encoded,
+// before (Raw) and after (Compressed) compression
+type CompressionStats struct {
+	RawBytes        int64 `json:"rawBytes"`
+	CompressedBytes int64 `json:"compressedBytes"`
+}
+
+// transferCounters accumulates compression statistics
+type transferCounters struct {
+	raw        atomic.Int64
+	compressed atomic.Int64
+}
+
+// CompressionStats returns the bytes sent and received with gzip encoding
+func (p *P2PServer) CompressionStats() CompressionStats {
+	return CompressionStats{
+		RawBytes:        p.transfers.raw.Load(),
+		CompressedBytes: p.transfers.compressed.Load(),
+	}
+}
+
+// recordTransfer adds one compressed payload to the counters
+func (p *P2PServer) recordTransfer(raw, compressed int64) {
+	p.transfers.raw.Add(raw)
+	p.transfers.compressed.Add(compressed)
+	if p.metrics != nil {
+		p.metrics.RecordTransferBytes(raw, compressed)
+	}
+}
+
+// acceptsGzip reports whether a request advertised gzip in Accept-Encoding
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(coding, encodingGzip) {
+			return true
+		}
+	}
+	return false
+}
+
+// countingWriter counts the bytes passing through to an underlying writer
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// gzipResponseWriter compresses everything the handler writes
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	raw int64
+	gz  *gzip.Writer
+}
+
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	n, err := g.gz.Write(b)
+	g.raw += int64(n)
+	return n, err
+}
+
+// compressed gzips a handler's response when the requester accepts it
+func (p *P2PServer) compressed(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r) {
+			handler(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Encoding", encodingGzip)
+		wire := &countingWriter{w: w}
+		gw := &gzipResponseWriter{ResponseWriter: w, gz: gzip.NewWriter(wire)}
+		handler(gw, r)
+		gw.gz.Close()
+		p.recordTransfer(gw.raw, wire.n)
+	}
+}
+
+// decompressBody transparently decodes a gzip request body. The decoded
+// size is capped at maxBytes so a small compressed body cannot expand
+// without bound. It reports false after answering 400 for a corrupt body.
+func (p *P2PServer) decompressBody(w http.ResponseWriter, r *http.Request, maxBytes int64) bool {
+	if !strings.EqualFold(r.Header.Get("Content-Encoding"), encodingGzip) {
+		return true
+	}
+
+	wire := &countingReader{r: r.Body}
+	gz, err := gzip.NewReader(wire)
+	if err != nil {
+		http.Error(w, "Invalid gzip body", http.StatusBadRequest)
+		return false
+	}
+	r.Body = http.MaxBytesReader(w, &gzipBody{Reader: gz, wire: wire, body: r.Body, p: p}, maxBytes)
+	r.Header.Del("Content-Encoding")
+	return true
+}
+
+// countingReader counts the bytes read from an underlying reader
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// gzipBody decompresses a body and records the transfer once it is closed
+type gzipBody struct {
+	*gzip.Reader
+	wire *countingReader
+	body io.Closer
+	raw  int64
+	p    *P2PServer
+}
+
+func (g *gzipBody) Read(b []byte) (int, error) {
+	n, err := g.Reader.Read(b)
+	g.raw += int64(n)
+	return n, err
+}
+
+func (g *gzipBody) Close() error {
+	g.Reader.Close()
+	g.p.recordTransfer(g.raw, g.wire.n)
+	return g.body.Close()
+}
+
+// getPeer issues a GET to a peer asking for a gzip response and returns the
+// response with its body already decompressed
+func (p *P2PServer) getPeer(client *http.Client, url string) (*http.Response, error) {
+	req, err := p.newPeerRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Setting the header ourselves disables the transport's transparent
+	// decompression, which lets us count the bytes on the wire
+	req.Header.Set("Accept-Encoding", encodingGzip)
+
+	resp, err := p.doPeer(client, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &meteredBody{ReadCloser: resp.Body, report: p.reportBytesReceived}
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), encodingGzip) {
+		return resp, nil
+	}
+
+	wire := &countingReader{r: resp.Body}
+	gz, err := gzip.NewReader(wire)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	resp.Body = &gzipBody{Reader: gz, wire: wire, body: resp.Body, p: p}
+	resp.Header.Del("Content-Encoding")
+	return resp, nil
+}
+
+// gzipPayload compresses a request body for a peer that accepts gzip
+func (p *P2PServer) gzipPayload(data []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(data)
+	gz.Close()
+	p.recordTransfer(int64(len(data)), int64(buf.Len()))
+	return buf.Bytes()
+}
+
+// peerAcceptsGzip reports whether a peer advertised gzip in its handshake
+func (p *P2PServer) peerAcceptsGzip(address string) bool {
+	p.peersMutex.Lock()
+	defer p.peersMutex.Unlock()
+	return p.peers[address].Gzip
+}
@@ -0,0 +1,104 @@
+package network
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+func TestRangeResponsesAreGzippedOnRequest(t *testing.T) {
+	p := newTestServer(t)
+	growChain(t, p.chain, 20)
+	mux := http.NewServeMux()
+	p.RegisterRoutes(mux)
+
+	for _, gzipped := range []bool{false, true} {
+		req := httptest.NewRequest(http.MethodGet, "/sync?from_index=0&count=20", nil)
+		req.Header.Set(networkIDHeader, p.networkID)
+		if gzipped {
+			req.Header.Set("Accept-Encoding", "gzip")
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		var body io.Reader = w.Body
+		if got := w.Header().Get("Content-Encoding") == encodingGzip; got != gzipped {
+			t.Fatalf("accepting gzip %t, response gzipped %t", gzipped, got)
+		}
+		if gzipped {
+			gz, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = gz
+		}
+		var blocks []blockchain.Block
+		if err := json.NewDecoder(body).Decode(&blocks); err != nil {
+			t.Fatal(err)
+		}
+		if len(blocks) != 20 {
+			t.Fatalf("got %d blocks, want 20", len(blocks))
+		}
+	}
+
+	stats := p.CompressionStats()
+	if stats.RawBytes == 0 || stats.CompressedBytes >= stats.RawBytes {
+		t.Fatalf("compression stats %+v, want fewer bytes on the wire", stats)
+	}
+}
+
+func TestGzippedGossipIsDecompressed(t *testing.T) {
+	p := newTestServer(t)
+	mux := http.NewServeMux()
+	p.RegisterRoutes(mux)
+
+	block := mine(t, p.chain.GetLatestBlock(), 0)
+	msg, err := newTestServer(t).newGossip(gossipBlock, block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(msg)
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(data)
+	gz.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/broadcast-block", &buf)
+	req.Header.Set(networkIDHeader, p.networkID)
+	req.Header.Set("Content-Encoding", encodingGzip)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || p.chain.GetLatestBlock().Hash != block.Hash {
+		t.Fatalf("gzipped gossip answered %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestSyncFromPeerWithoutCompression(t *testing.T) {
+	ahead, behind := newTestServer(t), newTestServer(t)
+	growChain(t, ahead.chain, 20)
+
+	// An old peer ignores Accept-Encoding
+	mux := http.NewServeMux()
+	ahead.RegisterRoutes(mux)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del("Accept-Encoding")
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	behind.AddPeer(strings.TrimPrefix(server.URL, "http://"))
+
+	behind.syncWithPeers()
+	if got := behind.chain.GetLatestBlock(); got.Hash != ahead.chain.GetLatestBlock().Hash {
+		t.Fatalf("synced to height %d, want 20", got.Index)
+	}
+	if stats := behind.CompressionStats(); stats.RawBytes != 0 {
+		t.Fatalf("counted %+v compressed bytes from a peer without compression", stats)
+	}
+}
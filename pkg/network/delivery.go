@@ -0,0 +1,273 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Retry policy for broadcasts that fail to reach a peer
+const (
+	deliveryRetryInitial = 500 * time.Millisecond
+	deliveryRetryMax     = 30 * time.Second
+	maxDeliveryAttempts  = 6
+)
+
+// outboxCapacity bounds how many deliveries may wait for one peer
+const outboxCapacity = 256
+
+// peerFailureLimit is how many consecutive failed attempts make a non-seed
+// peer be treated as dead and forgotten
+const peerFailureLimit = 10
+
+// delivery is a gossip message waiting to be posted to one peer
+type delivery struct {
+	msgType    string
+	path       string
+	data       []byte
+	blockIndex int // Index of a gossiped block, -1 for other messages
+}
+
+// outbox queues deliveries for one peer, drained by a single worker so
+// retries don't reorder a peer's messages
+type outbox struct {
+	queue   []delivery
+	running bool
+	mutex   sync.Mutex
+}
+
+// PeerInfo describes a known peer for status listings
+type PeerInfo struct {
+	Address       string    `json:"address"`
+	NodeID        string    `json:"nodeId,omitempty"`
+	LastSeen      time.Time `json:"lastSeen"`
+	Seed          bool      `json:"seed"`
+	Role          string    `json:"role,omitempty"`
+	Version       string    `json:"version,omitempty"`
+	Protocol      int       `json:"protocolVersion,omitempty"`
+	Height        int       `json:"height"`
+	Delivered     int       `json:"delivered"`
+	Dropped       int       `json:"dropped"`
+	DeliveryRatio float64   `json:"deliveryRatio"`
+	LatencyMs     float64   `json:"latencyMs"`
+}
+
+// deliveryRatio is the share of finished deliveries that reached the peer.
+// A peer with no finished deliveries counts as fully reliable.
+func (peer Peer) deliveryRatio() float64 {
+	total := peer.Delivered + peer.Dropped
+	if total == 0 {
+		return 1
+	}
+	return float64(peer.Delivered) / float64(total)
+}
+
+// Peers returns a snapshot of the known peers and their delivery statistics
+func (p *P2PServer) Peers() []PeerInfo {
+	p.peersMutex.Lock()
+	defer p.peersMutex.Unlock()
+
+	now := time.Now()
+	infos := make([]PeerInfo, 0, len(p.peers))
+	for _, peer := range p.peers {
+		infos = append(infos, PeerInfo{
+			Address:       peer.Address,
+			NodeID:        peer.NodeID,
+			LastSeen:      peer.LastSeen,
+			Seed:          peer.Seed,
+			Role:          peer.Role,
+			Version:       peer.Version,
+			Protocol:      peer.Protocol,
+			Height:        peer.Height,
+			Delivered:     peer.Delivered,
+			Dropped:       peer.Dropped,
+			DeliveryRatio: peer.deliveryRatio(),
+			LatencyMs:     float64(peer.effectiveLatency(now)) / float64(time.Millisecond),
+		})
+	}
+	return infos
+}
+
+// newDelivery prepares an encoded gossip message for the outbound queues
+func newDelivery(msg GossipMessage, data []byte) delivery {
+	d := delivery{msgType: msg.Type, path: gossipPath(msg.Type), data: data, blockIndex: -1}
+	if msg.Type == gossipBlock || msg.Type == gossipBlockAnnounce {
+		var header struct {
+			Index int `json:"index"`
+		}
+		if json.Unmarshal(msg.Payload, &header) == nil {
+			d.blockIndex = header.Index
+		}
+	}
+	return d
+}
+
+// enqueueDelivery adds a delivery to a peer's outbox, starting its worker
+// if it isn't running
+func (p *P2PServer) enqueueDelivery(address string, d delivery) {
+	p.outboxMutex.Lock()
+	box, ok := p.outboxes[address]
+	if !ok {
+		box = &outbox{}
+		p.outboxes[address] = box
+	}
+	p.outboxMutex.Unlock()
+
+	box.mutex.Lock()
+	defer box.mutex.Unlock()
+	if len(box.queue) >= outboxCapacity {
+		log.Printf("Outbox for %s is full, dropping %s\n", address, d.msgType)
+		p.recordDelivery(address, false)
+		return
+	}
+	box.queue = append(box.queue, d)
+	if !box.running {
+		box.running = p.spawn(func() { p.drainOutbox(address, box) })
+	}
+}
+
+// drainOutbox delivers queued messages in order until the outbox is empty
+func (p *P2PServer) drainOutbox(address string, box *outbox) {
+	for {
+		box.mutex.Lock()
+		if len(box.queue) == 0 || p.ctx.Err() != nil {
+			box.running = false
+			box.mutex.Unlock()
+			return
+		}
+		d := box.queue[0]
+		box.queue = box.queue[1:]
+		box.mutex.Unlock()
+
+		p.deliver(address, d)
+	}
+}
+
+// deliveryBackoff returns the wait before the given retry (1-based)
+func deliveryBackoff(retry int) time.Duration {
+	delay := deliveryRetryInitial
+	for i := 1; i < retry; i++ {
+		delay *= 2
+		if delay >= deliveryRetryMax {
+			return deliveryRetryMax
+		}
+	}
+	return delay
+}
+
+// backOff waits out a delivery backoff on the retry clock, reporting false
+// if the server stopped first
+func (p *P2PServer) backOff(d time.Duration) bool {
+	select {
+	case <-p.ctx.Done():
+		return false
+	case <-p.retryAfter(d):
+		return true
+	}
+}
+
+// deliver posts a message to a peer, retrying with exponential backoff.
+// Retries stop once the peer reports a height at or past a gossiped block,
+// after maxDeliveryAttempts, or when the peer is forgotten.
+func (p *P2PServer) deliver(address string, d delivery) {
+	for attempt := 1; attempt <= maxDeliveryAttempts; attempt++ {
+		if attempt > 1 {
+			if !p.backOff(deliveryBackoff(attempt - 1)) {
+				return
+			}
+			if !p.wantsDelivery(address, d) {
+				return
+			}
+		}
+
+		err := p.postGossip(address, d.path, d.data)
+		if err == nil {
+			p.recordDelivery(address, true)
+			p.reportSent(d.msgType)
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if errors.Is(err, errPeerTimeout) {
+			// Already counted against the peer by doPeer
+			continue
+		}
+		log.Printf("Failed to send %s to %s (attempt %d/%d): %v\n", d.msgType, address, attempt, maxDeliveryAttempts, err)
+		if !p.recordAttemptFailure(address) {
+			return
+		}
+	}
+	p.recordDelivery(address, false)
+}
+
+// wantsDelivery reports whether a retry is still useful: the peer must still
+// be known and, for blocks, must not already have reached the block's height
+func (p *P2PServer) wantsDelivery(address string, d delivery) bool {
+	p.peersMutex.Lock()
+	defer p.peersMutex.Unlock()
+
+	peer, ok := p.peers[address]
+	if !ok {
+		return false
+	}
+	return d.blockIndex < 0 || peer.Height < d.blockIndex
+}
+
+// recordDelivery counts a finished delivery and publishes the peer's ratio
+func (p *P2PServer) recordDelivery(address string, delivered bool) {
+	p.peersMutex.Lock()
+	peer, ok := p.peers[address]
+	if !ok {
+		p.peersMutex.Unlock()
+		return
+	}
+	if delivered {
+		peer.Delivered++
+		peer.ConsecutiveFailures = 0
+		peer.LastSeen = time.Now()
+	} else {
+		peer.Dropped++
+	}
+	p.peers[address] = peer
+	p.peersMutex.Unlock()
+
+	if p.metrics != nil {
+		p.metrics.PeerDeliveryRatio(address, peer.deliveryRatio())
+	}
+}
+
+// recordAttemptFailure counts a failed attempt against a peer, forgetting
+// non-seed peers that keep failing. It reports whether the peer is still known.
+func (p *P2PServer) recordAttemptFailure(address string) bool {
+	p.peersMutex.Lock()
+	peer, ok := p.peers[address]
+	if !ok {
+		p.peersMutex.Unlock()
+		return false
+	}
+	peer.ConsecutiveFailures++
+	p.peers[address] = peer
+	dead := !peer.Seed && peer.ConsecutiveFailures >= peerFailureLimit
+	p.peersMutex.Unlock()
+
+	if dead {
+		log.Printf("Forgetting peer %s after %d consecutive failures\n", address, peer.ConsecutiveFailures)
+		p.removePeer(address)
+		return false
+	}
+	return true
+}
+
+// setPeerHeight records the latest block index a peer reported
+func (p *P2PServer) setPeerHeight(address string, height int) {
+	p.peersMutex.Lock()
+	defer p.peersMutex.Unlock()
+	if peer, ok := p.peers[address]; ok {
+		peer.Height = height
+		p.peers[address] = peer
+	}
+}
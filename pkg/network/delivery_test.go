@@ -0,0 +1,164 @@
+package network
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// flakyPeer is a stub peer that fails a number of gossip posts before
+// accepting them, counting the attempts
+type flakyPeer struct {
+	address  string
+	mutex    sync.Mutex
+	attempts int
+}
+
+func newFlakyPeer(t *testing.T, failures int) *flakyPeer {
+	t.Helper()
+	peer := &flakyPeer{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		peer.mutex.Lock()
+		peer.attempts++
+		n := peer.attempts
+		peer.mutex.Unlock()
+		if n <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(server.Close)
+	peer.address = strings.TrimPrefix(server.URL, "http://")
+	return peer
+}
+
+func (f *flakyPeer) count() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.attempts
+}
+
+// retryClock stands in for the delivery retry timer, recording each backoff
+// and holding it until advanced
+type retryClock struct {
+	mutex   sync.Mutex
+	waits   []time.Duration
+	pending []chan time.Time
+}
+
+// useRetryClock makes p wait for backoffs on a fresh retryClock
+func useRetryClock(p *P2PServer) *retryClock {
+	c := &retryClock{}
+	p.retryAfter = c.after
+	return c
+}
+
+func (c *retryClock) after(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.waits = append(c.waits, d)
+	c.pending = append(c.pending, ch)
+	return ch
+}
+
+// advance ends every backoff waiting so far
+func (c *retryClock) advance() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, ch := range c.pending {
+		ch <- time.Now()
+	}
+	c.pending = nil
+}
+
+func (c *retryClock) backoffs() []time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]time.Duration{}, c.waits...)
+}
+
+func TestBroadcastIsRetriedWithBackoff(t *testing.T) {
+	p := newTestServer(t)
+	clock := useRetryClock(p)
+	peer := newFlakyPeer(t, 2)
+	p.AddPeer(peer.address)
+
+	p.BroadcastTransaction(&blockchain.Transaction{ID: "a", To: "bob"})
+	for retry := 1; retry <= 2; retry++ {
+		waitFor(t, fmt.Sprintf("backoff %d", retry), func() bool { return len(clock.backoffs()) == retry })
+		if n := peer.count(); n != retry {
+			t.Fatalf("peer saw %d attempts before backoff %d ended, want %d", n, retry, retry)
+		}
+		clock.advance()
+	}
+	waitFor(t, "the delivery", func() bool {
+		infos := p.Peers()
+		return len(infos) == 1 && infos[0].Delivered == 1
+	})
+
+	if n := peer.count(); n != 3 {
+		t.Fatalf("peer saw %d attempts, want 3", n)
+	}
+	backoffs := clock.backoffs()
+	if len(backoffs) != 2 {
+		t.Fatalf("waited %d times, want 2", len(backoffs))
+	}
+	for i, got := range backoffs {
+		if want := deliveryBackoff(i + 1); got != want {
+			t.Errorf("retry %d came after %s, want %s", i+1, got, want)
+		}
+	}
+	if info := p.Peers()[0]; info.Dropped != 0 || info.DeliveryRatio != 1 {
+		t.Errorf("peer listed as %+v, want every delivery made", info)
+	}
+}
+
+func TestBlockRetriesStopOncePeerHasTheBlock(t *testing.T) {
+	p := newTestServer(t)
+	clock := useRetryClock(p)
+	peer := newFlakyPeer(t, maxDeliveryAttempts)
+	p.AddPeer(peer.address)
+
+	block := mine(t, p.chain.GetLatestBlock(), 0)
+	msg, err := p.newGossip(gossipBlock, block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.sendGossipTo(msg, []string{peer.address})
+	waitFor(t, "the first backoff", func() bool { return len(clock.backoffs()) == 1 })
+	p.setPeerHeight(peer.address, block.Index)
+
+	clock.advance()
+	waitFor(t, "the delivery to finish", func() bool {
+		p.outboxMutex.Lock()
+		box := p.outboxes[peer.address]
+		p.outboxMutex.Unlock()
+		box.mutex.Lock()
+		defer box.mutex.Unlock()
+		return !box.running
+	})
+	if n := peer.count(); n != 1 {
+		t.Fatalf("retried %d times after the peer reached the block", n-1)
+	}
+	if n := len(clock.backoffs()); n != 1 {
+		t.Fatalf("backed off %d times, want the retries abandoned after 1", n)
+	}
+}
+
+func TestDeliveryBackoffIsCapped(t *testing.T) {
+	if got := deliveryBackoff(1); got != deliveryRetryInitial {
+		t.Fatalf("first retry after %s, want %s", got, deliveryRetryInitial)
+	}
+	if got := deliveryBackoff(2); got != 2*deliveryRetryInitial {
+		t.Fatalf("second retry after %s, want %s", got, 2*deliveryRetryInitial)
+	}
+	if got := deliveryBackoff(20); got != deliveryRetryMax {
+		t.Fatalf("late retry after %s, want the %s cap", got, deliveryRetryMax)
+	}
+}
@@ -0,0 +1,152 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// errBlockNotFound is returned when a peer doesn't have a requested block
+var errBlockNotFound = errors.New("block not found on peer")
+
+// errBlockMismatch is returned when a peer answers a block request with a
+// block that doesn't hash to the requested hash
+var errBlockMismatch = errors.New("peer returned a block that does not match the requested hash")
+
+// blockRequestTimeout bounds a single block-by-hash request
+const blockRequestTimeout = 10 * time.Second
+
+// orphanSet holds blocks whose ancestors are being fetched
+type orphanSet struct {
+	blocks map[string]blockchain.Block
+	mutex  sync.Mutex
+}
+
+// newOrphanSet creates an empty orphan set
+func newOrphanSet() *orphanSet {
+	return &orphanSet{blocks: make(map[string]blockchain.Block)}
+}
+
+// Add stores an orphan and reports whether it wasn't already held
+func (o *orphanSet) Add(block blockchain.Block) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if _, exists := o.blocks[block.Hash]; exists {
+		return false
+	}
+	o.blocks[block.Hash] = block
+	return true
+}
+
+// Remove releases an orphan
+func (o *orphanSet) Remove(hash string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	delete(o.blocks, hash)
+}
+
+// Len returns the number of orphans held
+func (o *orphanSet) Len() int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return len(o.blocks)
+}
+
+// RequestBlock fetches the block with the given hash from a peer. The block
+// is only returned if its contents actually hash to the requested hash.
+func (p *P2PServer) RequestBlock(address, hash string) (blockchain.Block, error) {
+	resp, err := p.getPeer(p.clientWithTimeout(blockRequestTimeout), p.peerURL(address, "/block/"+hash))
+	if err != nil {
+		return blockchain.Block{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return blockchain.Block{}, errBlockNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return blockchain.Block{}, fmt.Errorf("unexpected status from %s: %s", address, resp.Status)
+	}
+
+	var block blockchain.Block
+	if err := json.NewDecoder(resp.Body).Decode(&block); err != nil {
+		return blockchain.Block{}, fmt.Errorf("failed to decode block: %w", err)
+	}
+	if block.Hash != hash || !blockchain.HashMatches(block) {
+		return blockchain.Block{}, fmt.Errorf("%w: %s from %s", errBlockMismatch, hash, address)
+	}
+	return block, nil
+}
+
+// resolveFork walks back from a block that doesn't extend our tip, fetching
+// its ancestors from the peer until one is on our chain, and then asks the
+// chain to reorganize onto the resulting branch
+func (p *P2PServer) resolveFork(block blockchain.Block, address string) error {
+	branch := []blockchain.Block{block}
+	current := block
+
+	for {
+		if _, onChain := p.chain.GetBlockByHash(current.PrevHash); onChain {
+			break
+		}
+		if len(branch) > blockchain.MaxReorgDepth {
+			return fmt.Errorf("no common ancestor within %d blocks", blockchain.MaxReorgDepth)
+		}
+
+		parent, err := p.RequestBlock(address, current.PrevHash)
+		if err != nil {
+			return fmt.Errorf("failed to fetch ancestor %s: %w", current.PrevHash, err)
+		}
+		branch = append([]blockchain.Block{parent}, branch...)
+		current = parent
+	}
+
+	ancestor, _ := p.chain.GetBlockByHash(branch[0].PrevHash)
+	if err := p.validateBlocks(branch, &ancestor); err != nil {
+		p.penalize(address, err)
+		return err
+	}
+
+	if err := p.chain.Reorganize(branch); err != nil {
+		return err
+	}
+	log.Printf("Reorganized onto branch of %d blocks from %s\n", len(branch), address)
+	return nil
+}
+
+// handleOrphan holds a non-connecting block while its ancestors are fetched
+// from source, calling resolved once the fork is resolved. A block whose
+// parent is unknown also goes to the chain's orphan pool, so it connects
+// if the parent arrives from another peer first.
+func (p *P2PServer) handleOrphan(block blockchain.Block, source string, resolved func()) {
+	if !p.orphans.Add(block) {
+		return
+	}
+	defer p.orphans.Remove(block.Hash)
+	p.chain.AddOrphan(block)
+
+	if err := p.resolveFork(block, source); err != nil {
+		if _, onChain := p.chain.GetBlockByHash(block.Hash); onChain {
+			resolved()
+			return
+		}
+		log.Printf("Failed to resolve fork at block %s: %v\n", block.Hash, err)
+		return
+	}
+	resolved()
+}
+
+func (p *P2PServer) handleGetBlock(w http.ResponseWriter, r *http.Request) {
+	block, ok := p.chain.GetBlockByHash(r.PathValue("hash"))
+	if !ok || block.Pruned {
+		http.Error(w, "Block not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(block)
+}
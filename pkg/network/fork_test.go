@@ -0,0 +1,86 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+func TestGossipedForkTipFetchesAncestors(t *testing.T) {
+	heavy, light := newTestServer(t), newTestServer(t)
+	listen(t, heavy)
+	listen(t, light)
+
+	// light mines two blocks of its own, heavy three on another branch
+	pool := blockchain.NewTransactionPool(10)
+	if err := pool.AddTransaction(&blockchain.Transaction{ID: "light", To: "bob", Timestamp: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := light.chain.AddBlock(pool, 0); err != nil {
+		t.Fatal(err)
+	}
+	growChain(t, light.chain, 1)
+	growChain(t, heavy.chain, 3)
+	tip := heavy.chain.GetLatestBlock()
+
+	msg, err := heavy.newGossip(gossipBlock, tip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := postGossipMessage(light, msg, heavy.address); code != http.StatusAccepted {
+		t.Fatalf("non-connecting block answered %d, want 202", code)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for light.chain.GetLatestBlock().Hash != tip.Hash {
+		if time.Now().After(deadline) {
+			t.Fatalf("light node at height %d, want the heavier branch's tip", light.chain.Height())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := light.orphans.Len(); n != 0 {
+		t.Errorf("%d orphans still held after the fork resolved", n)
+	}
+	if reorgs := light.chain.Forks().Reorgs(); len(reorgs) != 1 || reorgs[0].AbandonedBlocks != 2 {
+		t.Errorf("recorded reorgs %+v, want one abandoning two blocks", reorgs)
+	}
+}
+
+func TestRequestBlockRejectsUnknownHash(t *testing.T) {
+	p, peer := newTestServer(t), newTestServer(t)
+	listen(t, peer)
+	growChain(t, peer.chain, 1)
+
+	block, err := p.RequestBlock(peer.address, peer.chain.GetLatestBlock().Hash)
+	if err != nil || block.Hash != peer.chain.GetLatestBlock().Hash {
+		t.Fatalf("got block %s, %v", block.Hash, err)
+	}
+	if _, err := p.RequestBlock(peer.address, "missing"); !errors.Is(err, errBlockNotFound) {
+		t.Fatalf("got %v, want errBlockNotFound", err)
+	}
+}
+
+func TestRequestBlockRejectsMismatchedBlock(t *testing.T) {
+	p := newTestServer(t)
+	requested := mine(t, p.chain.GetLatestBlock(), 0)
+	other := mine(t, requested, 0)
+	forged := requested
+	forged.Data = "forged"
+
+	for name, answer := range map[string]blockchain.Block{"another block": other, "altered contents": forged} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(answer)
+		}))
+		_, err := p.RequestBlock(strings.TrimPrefix(server.URL, "http://"), requested.Hash)
+		server.Close()
+		if !errors.Is(err, errBlockMismatch) {
+			t.Errorf("%s: got %v, want errBlockMismatch", name, err)
+		}
+	}
+}
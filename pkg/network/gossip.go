@@ -0,0 +1,324 @@
+package network
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// Gossip message types
+const (
+	gossipBlock       = "block"
+	gossipTransaction = "transaction"
+)
+
+// defaultGossipHops is how many times a gossip message may be relayed
+const defaultGossipHops = 8
+
+// defaultSeenMessagesSize bounds how many relayed message IDs are remembered
+const defaultSeenMessagesSize = 10000
+
+// maxGossipAge is how far a signed message's timestamp may drift from our clock
+const maxGossipAge = 5 * time.Minute
+
+// Errors returned when authenticating gossip messages
+var (
+	errUnsignedMessage = errors.New("message is not signed")
+	errStaleMessage    = errors.New("message timestamp outside the accepted window")
+	errUnknownOrigin   = errors.New("unknown origin public key")
+	errBadSignature    = errors.New("invalid message signature")
+)
+
+// nodeAddressHeader carries the sending node's advertised P2P address
+const nodeAddressHeader = "X-Node-Address"
+
+// GossipMessage wraps a broadcast payload with the metadata needed to relay
+// it through the network without storms
+type GossipMessage struct {
+	ID       string          `json:"id"`
+	Type     string          `json:"type"`
+	OriginID string          `json:"originId"`
+	Origin   string          `json:"origin"` // Advertised address of the originating node
+	Hops     int             `json:"hops"`
+	Payload  json.RawMessage `json:"payload"`
+
+	// Timestamp and Signature authenticate the message. Hops is excluded from
+	// the signature because relays decrement it.
+	Timestamp int64  `json:"timestamp"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// signingBytes returns the canonical bytes covered by the message signature
+func (m GossipMessage) signingBytes() []byte {
+	payloadHash := sha256.Sum256(m.Payload)
+	var buf bytes.Buffer
+	buf.WriteString(m.ID)
+	buf.WriteByte('|')
+	buf.WriteString(m.Type)
+	buf.WriteByte('|')
+	buf.Write(payloadHash[:])
+	buf.WriteByte('|')
+	buf.WriteString(m.OriginID)
+	buf.WriteByte('|')
+	buf.WriteString(strconv.FormatInt(m.Timestamp, 10))
+	return buf.Bytes()
+}
+
+// newMessageID returns a random gossip message ID
+func newMessageID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// gossipPath returns the endpoint that receives a gossip message type
+func gossipPath(msgType string) string {
+	if msgType == gossipTransaction {
+		return "/broadcast-tx"
+	}
+	return "/broadcast-block"
+}
+
+// gossip originates a new message and sends it to every peer
+func (p *P2PServer) gossip(msgType string, payload interface{}) {
+	msg, err := p.newGossip(msgType, payload)
+	if err != nil {
+		log.Printf("Failed to encode %s gossip: %v\n", msgType, err)
+		return
+	}
+	p.sendGossip(msg, "")
+}
+
+// acceptGossip reports whether a received message should be processed: it
+// must not be our own and must not have been seen before
+func (p *P2PServer) acceptGossip(msg GossipMessage) bool {
+	if msg.OriginID == p.nodeID {
+		return false
+	}
+	return p.seenMsgs.Add(msg.ID)
+}
+
+// verifyGossip checks a message's signature against the origin's public key
+// learned during the handshake. Verification is skipped unless authenticated
+// mode is enabled.
+func (p *P2PServer) verifyGossip(msg GossipMessage) error {
+	if !p.authenticated {
+		return nil
+	}
+	if msg.Signature == "" {
+		return errUnsignedMessage
+	}
+
+	age := time.Since(time.Unix(msg.Timestamp, 0))
+	if age > maxGossipAge || age < -maxGossipAge {
+		return errStaleMessage
+	}
+
+	publicKey, ok := p.peerKey(msg.OriginID)
+	if !ok {
+		return errUnknownOrigin
+	}
+
+	signature, err := hex.DecodeString(msg.Signature)
+	if err != nil || !ed25519.Verify(publicKey, msg.signingBytes(), signature) {
+		return errBadSignature
+	}
+	return nil
+}
+
+// processGossip verifies and applies a received gossip message, relaying it
+// onward when accepted. The returned status code describes the outcome for
+// HTTP callers.
+func (p *P2PServer) processGossip(msg GossipMessage, sender string) (int, error) {
+	if err := p.verifyGossip(msg); err != nil {
+		p.reportInvalid()
+		return http.StatusUnauthorized, err
+	}
+	p.reportReceived(msg.Type)
+
+	// Drop our own messages and ones already relayed
+	if !p.acceptGossip(msg) {
+		return http.StatusOK, nil
+	}
+
+	switch msg.Type {
+	case gossipBlock:
+		return p.processBlockGossip(msg, sender)
+	case gossipBlockAnnounce:
+		return p.processBlockAnnouncement(msg, sender)
+	case gossipTransaction:
+		return p.processTransactionGossip(msg, sender)
+	default:
+		p.reportInvalid()
+		return http.StatusBadRequest, fmt.Errorf("unknown gossip type: %s", msg.Type)
+	}
+}
+
+// processBlockGossip adds a gossiped block to the chain
+func (p *P2PServer) processBlockGossip(msg GossipMessage, sender string) (int, error) {
+	var block blockchain.Block
+	if err := json.Unmarshal(msg.Payload, &block); err != nil {
+		p.reportInvalid()
+		return http.StatusBadRequest, err
+	}
+
+	source := sender
+	if source == "" {
+		source = msg.Origin
+	}
+	if p.isLight() {
+		return p.processLightBlock(block, source)
+	}
+
+	// Skip blocks already on our chain or seen recently
+	if _, onChain := p.chain.GetBlockByHash(block.Hash); onChain || p.knownBlocks.Contains(block.Hash) {
+		return http.StatusOK, nil
+	}
+
+	// Rejected blocks aren't remembered, so a valid block with the same
+	// hash is still accepted later
+	tip := p.chain.GetLatestBlock()
+	var parent *blockchain.Block
+	if block.PrevHash == tip.Hash {
+		parent = &tip
+	}
+	if err := p.validateBlock(block, parent); err != nil {
+		p.penalize(source, err)
+		return http.StatusConflict, err
+	}
+	if !p.knownBlocks.Add(block.Hash) {
+		return http.StatusOK, nil
+	}
+
+	// A block that doesn't extend our tip may belong to a heavier fork;
+	// fetch its ancestors in the background
+	if parent == nil && block.Index > 0 {
+		p.spawn(func() {
+			p.handleOrphan(block, source, func() { p.propagateBlock(block, &msg, sender) })
+		})
+		return http.StatusAccepted, nil
+	}
+
+	// Validate and add the block to our chain
+	if err := p.chain.AddExistingBlock(block); err != nil {
+		p.reportInvalid()
+		return http.StatusConflict, err
+	}
+	log.Printf("Added new block from peer: %s\n", block.Hash)
+
+	p.propagateBlock(block, &msg, sender)
+	return http.StatusOK, nil
+}
+
+// processTransactionGossip adds a gossiped transaction to the pool
+func (p *P2PServer) processTransactionGossip(msg GossipMessage, sender string) (int, error) {
+	// Light nodes keep no mempool
+	if p.isLight() {
+		return http.StatusOK, nil
+	}
+
+	var tx blockchain.Transaction
+	if err := json.Unmarshal(msg.Payload, &tx); err != nil {
+		p.reportInvalid()
+		return http.StatusBadRequest, err
+	}
+
+	if p.txPool != nil {
+		if err := p.txPool.AddTransaction(&tx); err != nil {
+			// Already pooled or the pool is full; don't relay further
+			return http.StatusOK, nil
+		}
+	}
+
+	p.relay(msg, sender)
+	return http.StatusOK, nil
+}
+
+// relay forwards a received message with one fewer hop, never sending it back
+// to its origin or to the peer it came from
+func (p *P2PServer) relay(msg GossipMessage, sender string) {
+	msg.Hops--
+	if msg.Hops <= 0 {
+		return
+	}
+	p.sendGossip(msg, sender)
+}
+
+// sendGossip delivers a message to all peers except the origin and the excluded address
+func (p *P2PServer) sendGossip(msg GossipMessage, exclude string) {
+	var targets []string
+	for _, peer := range p.peerAddresses() {
+		if peer == exclude || peer == msg.Origin {
+			continue
+		}
+		// Light peers keep no mempool
+		if msg.Type != gossipTransaction || !p.peerIsLight(peer) {
+			targets = append(targets, peer)
+		}
+	}
+	p.sendGossipTo(msg, targets)
+}
+
+// sendGossipTo delivers a message to the given peers, over their WebSocket
+// session when one is open and through their outbox otherwise
+func (p *P2PServer) sendGossipTo(msg GossipMessage, peers []string) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Failed to encode gossip message %s: %v\n", msg.ID, err)
+		return
+	}
+
+	d := newDelivery(msg, data)
+	for _, peer := range peers {
+		if session := p.session(peer); session != nil {
+			gossip := msg
+			if err := session.send(peerFrame{Type: frameGossip, Gossip: &gossip}); err == nil {
+				p.reportSent(msg.Type)
+				continue
+			}
+		}
+		p.enqueueDelivery(peer, d)
+	}
+}
+
+// postGossip sends an encoded gossip message to a peer, identifying this node as the sender
+func (p *P2PServer) postGossip(address, path string, data []byte) error {
+	url := p.peerURL(address, path)
+	gzipped := p.peerAcceptsGzip(address)
+	if gzipped {
+		data = p.gzipPayload(data)
+	}
+	req, err := p.newPeerRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if gzipped {
+		req.Header.Set("Content-Encoding", encodingGzip)
+	}
+
+	resp, err := p.doPeer(p.httpClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	p.reportBytesSent(int64(len(data)))
+
+	// The peer received the message unless it was overloaded or failed;
+	// rejections of the message itself are not worth retrying
+	if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+		return fmt.Errorf("peer %s answered %s", address, resp.Status)
+	}
+	return nil
+}
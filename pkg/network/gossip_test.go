@@ -0,0 +1,368 @@
+package network
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// gossipSink is a stub peer recording the gossip messages posted to it
+type gossipSink struct {
+	address  string
+	received chan GossipMessage
+}
+
+func newGossipSink(t *testing.T) *gossipSink {
+	t.Helper()
+	sink := &gossipSink{received: make(chan GossipMessage, 16)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/broadcast-") {
+			return
+		}
+		var msg GossipMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err == nil {
+			sink.received <- msg
+		}
+	}))
+	t.Cleanup(server.Close)
+	sink.address = strings.TrimPrefix(server.URL, "http://")
+	return sink
+}
+
+// postGossipMessage sends msg to p's block gossip handler as sender would
+func postGossipMessage(p *P2PServer, msg GossipMessage, sender string) int {
+	body, _ := json.Marshal(msg)
+	req := httptest.NewRequest(http.MethodPost, "/broadcast-block", bytes.NewReader(body))
+	req.Header.Set(networkIDHeader, p.networkID)
+	if sender != "" {
+		req.Header.Set(nodeAddressHeader, sender)
+	}
+	w := httptest.NewRecorder()
+	p.handleGossip(w, req)
+	return w.Code
+}
+
+func TestConcurrentBlockGossip(t *testing.T) {
+	p := newTestServer(t)
+	origin := newTestServer(t)
+	genesis := p.chain.GetLatestBlock()
+
+	// Several blocks competing for the same height, each sent many times
+	var messages []GossipMessage
+	for i := 0; i < 4; i++ {
+		block, _, err := blockchain.GenerateBlock(genesis, []blockchain.Transaction{{ID: string(rune('a' + i)), To: "bob"}}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := origin.newGossip(gossipBlock, block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, msg)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(msg GossipMessage) {
+			defer wg.Done()
+			// Each copy has its own ID, as if relayed by a different peer
+			msg.ID = newMessageID()
+			postGossipMessage(p, msg, "")
+		}(messages[i%len(messages)])
+	}
+	wg.Wait()
+
+	if height := p.chain.Height(); height != 1 {
+		t.Fatalf("chain height %d, want exactly one of the competing blocks", height)
+	}
+}
+
+func TestBlockGossipIsNotForwardedToOriginOrSender(t *testing.T) {
+	p := newTestServer(t)
+	origin, sender, other := newGossipSink(t), newGossipSink(t), newGossipSink(t)
+	for _, peer := range []*gossipSink{origin, sender, other} {
+		p.AddPeer(peer.address)
+	}
+
+	block := mine(t, p.chain.GetLatestBlock(), 0)
+	msg, err := newTestServer(t).newGossip(gossipBlock, block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.Origin = origin.address
+	if code := postGossipMessage(p, msg, sender.address); code != http.StatusOK {
+		t.Fatalf("gossip answered %d", code)
+	}
+	if p.chain.GetLatestBlock().Hash != block.Hash {
+		t.Fatal("gossiped block wasn't added")
+	}
+
+	select {
+	case forwarded := <-other.received:
+		if forwarded.Origin != origin.address {
+			t.Errorf("forwarded with origin %q, want %q", forwarded.Origin, origin.address)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("block wasn't forwarded to the other peer")
+	}
+	select {
+	case <-origin.received:
+		t.Error("block was forwarded back to its origin")
+	case <-sender.received:
+		t.Error("block was forwarded back to the peer that sent it")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestAuthenticatedGossip(t *testing.T) {
+	origin, stranger := newTestServer(t), newTestServer(t)
+	block := mine(t, blockchain.CreateGenesisBlock(), 0)
+	other := mine(t, block, 0)
+
+	// resign signs msg again as origin after a test altered it
+	resign := func(msg GossipMessage) GossipMessage {
+		msg.Signature = hex.EncodeToString(origin.identity.Sign(msg.signingBytes()))
+		return msg
+	}
+	signed, err := origin.newGossip(gossipBlock, block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromStranger, err := stranger.newGossip(gossipBlock, block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := signed
+	tampered.Payload, _ = json.Marshal(other)
+	unsigned := signed
+	unsigned.Signature = ""
+	replayed := signed
+	replayed.Timestamp = time.Now().Add(-2 * maxGossipAge).Unix()
+	replayed = resign(replayed)
+	future := signed
+	future.Timestamp = time.Now().Add(2 * maxGossipAge).Unix()
+	future = resign(future)
+
+	tests := []struct {
+		name string
+		msg  GossipMessage
+		want error
+	}{
+		{"valid", signed, nil},
+		{"tampered payload", tampered, errBadSignature},
+		{"unsigned", unsigned, errUnsignedMessage},
+		{"replayed old message", replayed, errStaleMessage},
+		{"timestamp from the future", future, errStaleMessage},
+		{"unknown origin", fromStranger, errUnknownOrigin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestServer(t)
+			p.SetAuthenticated(true)
+			if err := p.learnIdentity(origin.localHandshake()); err != nil {
+				t.Fatal(err)
+			}
+
+			status, err := p.processGossip(tt.msg, "")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			added := p.chain.GetLatestBlock().Hash == block.Hash
+			if tt.want == nil && (status != http.StatusOK || !added) {
+				t.Fatalf("valid message answered %d, block added %v", status, added)
+			}
+			if tt.want != nil && (status != http.StatusUnauthorized || p.chain.Height() != 0) {
+				t.Fatalf("rejected message answered %d and left height %d", status, p.chain.Height())
+			}
+		})
+	}
+}
+
+func TestUnauthenticatedModeAcceptsUnsignedGossip(t *testing.T) {
+	p := newTestServer(t)
+	block := mine(t, p.chain.GetLatestBlock(), 0)
+	payload, _ := json.Marshal(block)
+
+	msg := GossipMessage{ID: newMessageID(), Type: gossipBlock, OriginID: "anyone", Hops: 1, Payload: payload}
+	if status, err := p.processGossip(msg, ""); err != nil || status != http.StatusOK {
+		t.Fatalf("got %d %v", status, err)
+	}
+	if p.chain.Height() != 1 {
+		t.Fatal("unsigned block wasn't added")
+	}
+}
+
+func TestOutOfOrderBlockGossipConnects(t *testing.T) {
+	p := newTestServer(t)
+	origin := newTestServer(t)
+	blocks := make([]blockchain.Block, 3)
+	parent := p.chain.GetLatestBlock()
+	for i := range blocks {
+		blocks[i] = mine(t, parent, 0)
+		parent = blocks[i]
+	}
+
+	for _, i := range []int{2, 1, 0} {
+		msg, err := origin.newGossip(gossipBlock, blocks[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		postGossipMessage(p, msg, "")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for p.chain.GetLatestBlock().Hash != blocks[2].Hash {
+		if time.Now().After(deadline) {
+			t.Fatalf("chain at height %d, want all three blocks", p.chain.Height())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTransactionGossipRelaysOnceWithinHops(t *testing.T) {
+	p := newTestServer(t)
+	sink := newGossipSink(t)
+	p.AddPeer(sink.address)
+
+	msg, err := newTestServer(t).newGossip(gossipTransaction, blockchain.Transaction{ID: "a", To: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if status, err := p.processGossip(msg, ""); err != nil || status != http.StatusOK {
+			t.Fatalf("got %d %v", status, err)
+		}
+	}
+	select {
+	case relayed := <-sink.received:
+		if relayed.ID != msg.ID || relayed.Hops != msg.Hops-1 {
+			t.Fatalf("relayed %s with %d hops, want %s with %d", relayed.ID, relayed.Hops, msg.ID, msg.Hops-1)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("transaction wasn't relayed")
+	}
+
+	// Out of hops: accepted but not relayed
+	last, err := newTestServer(t).newGossip(gossipTransaction, blockchain.Transaction{ID: "b", To: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last.Hops = 1
+	if status, err := p.processGossip(last, ""); err != nil || status != http.StatusOK {
+		t.Fatalf("got %d %v", status, err)
+	}
+	select {
+	case relayed := <-sink.received:
+		t.Fatalf("relayed %s again or past its last hop", relayed.ID)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+// listenCounting is listen, also counting the requests p serves by path
+func listenCounting(t *testing.T, p *P2PServer) *requestCounter {
+	t.Helper()
+	mux := http.NewServeMux()
+	p.RegisterRoutes(mux)
+	counter := &requestCounter{counts: make(map[string]int)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if strings.HasPrefix(path, "/block/") {
+			path = "/block/"
+		}
+		counter.mutex.Lock()
+		counter.counts[path]++
+		counter.mutex.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	p.SetAdvertisedAddress(strings.TrimPrefix(server.URL, "http://"))
+	return counter
+}
+
+// totalRequests sums the requests for path served across counters
+func totalRequests(counters []*requestCounter, path string) int {
+	var total int
+	for _, c := range counters {
+		total += c.count(path)
+	}
+	return total
+}
+
+func TestBlockBroadcastDeliveriesInMesh(t *testing.T) {
+	for name, c := range map[string]struct {
+		connect func(p, peer *P2PServer) error
+		// requests served across the mesh for one broadcast
+		want map[string]int
+	}{
+		// Peers added without a handshake get the full block. The origin
+		// sends it to 3 peers and each relays it once to the 2 that are
+		// neither the origin nor its sender.
+		"full blocks": {
+			connect: func(p, peer *P2PServer) error { p.AddPeer(peer.address); return nil },
+			want:    map[string]int{"/broadcast-block": 3 + 3*2, "/block/": 0},
+		},
+		// Handshaken peers get announcements the same way, and fetch the
+		// body once each
+		"announcements": {
+			connect: func(p, peer *P2PServer) error { return p.ConnectPeer(peer.address) },
+			want:    map[string]int{"/broadcast-block": 3 + 3*2, "/block/": 3},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			nodes := []*P2PServer{newTestServer(t), newTestServer(t), newTestServer(t), newTestServer(t)}
+			var counters []*requestCounter
+			for _, p := range nodes {
+				counters = append(counters, listenCounting(t, p))
+			}
+			for _, p := range nodes {
+				for _, peer := range nodes {
+					if peer == p {
+						continue
+					}
+					if err := c.connect(p, peer); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			before := make(map[string]int)
+			for path := range c.want {
+				before[path] = totalRequests(counters, path)
+			}
+
+			origin := nodes[0]
+			block := mine(t, origin.chain.GetLatestBlock(), 0)
+			if err := origin.chain.AddExistingBlock(block); err != nil {
+				t.Fatal(err)
+			}
+			origin.BroadcastBlock(block)
+			for _, p := range nodes[1:] {
+				waitFor(t, "the broadcast block", func() bool { return p.chain.GetLatestBlock().Hash == block.Hash })
+			}
+
+			// Let relays settle before counting
+			for path, want := range c.want {
+				waitFor(t, path+" deliveries", func() bool { return totalRequests(counters, path)-before[path] >= want })
+			}
+			time.Sleep(200 * time.Millisecond)
+			for path, want := range c.want {
+				if got := totalRequests(counters, path) - before[path]; got != want {
+					t.Errorf("served %d %s requests, want %d", got, path, want)
+				}
+			}
+			// None of it goes back to the origin
+			if n := counters[0].count("/broadcast-block"); n != 0 {
+				t.Errorf("origin received its own block %d times", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package network
+
+import (
+	"container/list"
+	"sync"
+)
+
+// defaultKnownBlocksSize bounds how many recently seen block hashes are remembered
+const defaultKnownBlocksSize = 10000
+
+// hashCache is a bounded, concurrency-safe LRU set of hashes
+type hashCache struct {
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+	mutex    sync.Mutex
+}
+
+// newHashCache creates a cache that holds at most capacity hashes
+func newHashCache(capacity int) *hashCache {
+	if capacity <= 0 {
+		capacity = defaultKnownBlocksSize
+	}
+	return &hashCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Add records a hash and reports whether it was new. The least recently
+// seen hash is evicted once the cache is full.
+func (c *hashCache) Add(hash string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elem, exists := c.entries[hash]; exists {
+		c.order.MoveToFront(elem)
+		return false
+	}
+
+	c.entries[hash] = c.order.PushFront(hash)
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(string))
+	}
+	return true
+}
+
+// Contains reports whether a hash is in the cache
+func (c *hashCache) Contains(hash string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, exists := c.entries[hash]
+	return exists
+}
+
+// Len returns the number of hashes in the cache
+func (c *hashCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,37 @@
+package network
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHashCacheStaysBounded(t *testing.T) {
+	c := newHashCache(100)
+	for i := 0; i < 1000; i++ {
+		if !c.Add(fmt.Sprintf("hash-%d", i)) {
+			t.Fatalf("hash-%d reported as seen", i)
+		}
+	}
+	if n := c.Len(); n != 100 {
+		t.Fatalf("cache holds %d hashes, want 100", n)
+	}
+	if c.Contains("hash-0") {
+		t.Error("oldest hash wasn't evicted")
+	}
+	if c.Add("hash-999") {
+		t.Error("recent duplicate wasn't deduplicated")
+	}
+}
+
+func TestHashCacheEvictsLeastRecentlySeen(t *testing.T) {
+	c := newHashCache(2)
+	c.Add("a")
+	c.Add("b")
+	// Seeing a again makes b the oldest
+	c.Add("a")
+	c.Add("c")
+
+	if !c.Contains("a") || c.Contains("b") || !c.Contains("c") {
+		t.Fatalf("cache kept a=%t b=%t c=%t, want a and c", c.Contains("a"), c.Contains("b"), c.Contains("c"))
+	}
+}
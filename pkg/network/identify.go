@@ -0,0 +1,187 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// identifyNonceSize is the size of the nonce a node signs to prove its identity
+const identifyNonceSize = 32
+
+// identifyTimeout bounds the dial-back that verifies a registering peer
+const identifyTimeout = 5 * time.Second
+
+// Errors returned when a peer's claimed identity can't be bound to its address
+var (
+	errIdentityConflict   = errors.New("address is bound to another node")
+	errIdentityUnverified = errors.New("peer identity not verified")
+)
+
+// identifyRequest is the challenge sent to a peer's /identify endpoint
+type identifyRequest struct {
+	Nonce string `json:"nonce"`
+}
+
+// identifyResponse proves the answering node holds the key of its node ID
+type identifyResponse struct {
+	NodeID    string `json:"nodeId"`
+	PublicKey string `json:"publicKey"`
+	Signature string `json:"signature"`
+}
+
+// identifyMessage returns the bytes a node signs to answer a challenge. The
+// nonce is hex-encoded last, so no gossip message signs the same bytes.
+func identifyMessage(nodeID string, nonce []byte) []byte {
+	return []byte("simple-blockchain/identify|" + nodeID + "|" + hex.EncodeToString(nonce))
+}
+
+// handleIdentify signs a challenge nonce with the node's key, letting a peer
+// we register with check that we answer at the address we advertise
+func (p *P2PServer) handleIdentify(w http.ResponseWriter, r *http.Request) {
+	var req identifyRequest
+	if !p.decodeBody(w, r, &req) {
+		return
+	}
+	nonce, err := hex.DecodeString(req.Nonce)
+	if err != nil || len(nonce) != identifyNonceSize {
+		http.Error(w, "Invalid nonce", http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(identifyResponse{
+		NodeID:    p.nodeID,
+		PublicKey: hex.EncodeToString(p.identity.PublicKey),
+		Signature: hex.EncodeToString(ed25519.Sign(p.identity.PrivateKey, identifyMessage(p.nodeID, nonce))),
+	})
+}
+
+// verifyIdentity dials back the address a peer registered from and checks
+// that the node answering there signs a fresh nonce with the key the peer
+// claimed. Handshakes without a key claim no identity and pass.
+func (p *P2PServer) verifyIdentity(ctx context.Context, h handshake) error {
+	if h.PublicKey == "" {
+		return nil
+	}
+
+	nonce := make([]byte, identifyNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	body, _ := json.Marshal(identifyRequest{Nonce: hex.EncodeToString(nonce)})
+
+	scheme := schemeHTTP
+	if h.hasCapability(capabilityTLS) {
+		scheme = schemeHTTPS
+	}
+	ctx, cancel := context.WithTimeout(ctx, identifyTimeout)
+	defer cancel()
+	req, err := p.newPeerRequest(http.MethodPost, fmt.Sprintf("%s://%s/identify", scheme, h.Address), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: dialing back %s: %v", errIdentityUnverified, h.Address, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s answered %s", errIdentityUnverified, h.Address, resp.Status)
+	}
+
+	var answer identifyResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxHandshakeBytes)).Decode(&answer); err != nil {
+		return fmt.Errorf("%w: %s sent an invalid answer", errIdentityUnverified, h.Address)
+	}
+	if answer.NodeID != h.NodeID || answer.PublicKey != h.PublicKey {
+		return fmt.Errorf("%w: %s is node %s", errIdentityUnverified, h.Address, answer.NodeID)
+	}
+	key, _ := hex.DecodeString(h.PublicKey)
+	signature, err := hex.DecodeString(answer.Signature)
+	if err != nil || len(key) != ed25519.PublicKeySize || !ed25519.Verify(key, identifyMessage(h.NodeID, nonce), signature) {
+		return fmt.Errorf("%w: %s didn't sign the challenge", errIdentityUnverified, h.Address)
+	}
+	return nil
+}
+
+// admitPeer stores a peer that registered with us or opened a session,
+// binding its identity only once a dial-back to its address verifies it.
+// An address already bound to another node keeps its binding.
+func (p *P2PServer) admitPeer(ctx context.Context, h handshake) (string, error) {
+	address, err := normalizeAddress(h.Address)
+	if err != nil {
+		return "", err
+	}
+	h.Address = address
+	if _, err := parseIdentity(h); err != nil {
+		return "", err
+	}
+	if err := p.checkBinding(h); err != nil {
+		return "", err
+	}
+	if err := p.verifyIdentity(ctx, h); err != nil {
+		return "", err
+	}
+
+	if _, err := p.addPeer(address); err != nil {
+		return "", err
+	}
+	if err := p.learnIdentity(h); err != nil {
+		return "", err
+	}
+	return address, nil
+}
+
+// checkBinding rejects a handshake claiming an address that is bound to a
+// different node ID
+func (p *P2PServer) checkBinding(h handshake) error {
+	if h.PublicKey == "" {
+		return nil
+	}
+	p.peersMutex.Lock()
+	defer p.peersMutex.Unlock()
+	if bound := p.peers[h.Address].NodeID; bound != "" && bound != h.NodeID {
+		return fmt.Errorf("%w: %s is node %s", errIdentityConflict, h.Address, bound)
+	}
+	return nil
+}
+
+// parseIdentity returns the public key a handshake claims, checking that it
+// matches the claimed node ID. Handshakes without a key return nil.
+func parseIdentity(h handshake) (ed25519.PublicKey, error) {
+	if h.PublicKey == "" {
+		return nil, nil
+	}
+	key, err := hex.DecodeString(h.PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid public key")
+	}
+	if NodeIDFromPublicKey(key) != h.NodeID {
+		return nil, errors.New("node ID does not match public key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// admitStatus returns the HTTP status answering a registration admitPeer refused
+func admitStatus(err error) int {
+	switch {
+	case errors.Is(err, errPeerLimit):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, errIdentityConflict):
+		return http.StatusConflict
+	case errors.Is(err, errIdentityUnverified):
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
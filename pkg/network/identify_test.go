@@ -0,0 +1,116 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// listen serves a test server's P2P routes and advertises their address
+func listen(t *testing.T, p *P2PServer) {
+	t.Helper()
+	mux := http.NewServeMux()
+	p.RegisterRoutes(mux)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	p.SetAdvertisedAddress(strings.TrimPrefix(server.URL, "http://"))
+}
+
+// register posts a handshake to p's registration endpoint, returning the status
+func register(t *testing.T, p *P2PServer, h handshake) int {
+	t.Helper()
+	body, _ := json.Marshal(h)
+	req := httptest.NewRequest(http.MethodPost, "/register-peer", bytes.NewReader(body))
+	req.Header.Set(networkIDHeader, p.networkID)
+	w := httptest.NewRecorder()
+	p.handleRegisterPeer(w, req)
+	return w.Code
+}
+
+// boundNodeID returns the node ID p has bound to a peer address
+func boundNodeID(p *P2PServer, address string) string {
+	p.peersMutex.Lock()
+	defer p.peersMutex.Unlock()
+	return p.peers[address].NodeID
+}
+
+func TestRegisterPeerBindsVerifiedIdentity(t *testing.T) {
+	a, b := newTestServer(t), newTestServer(t)
+	listen(t, a)
+	listen(t, b)
+
+	if err := b.ConnectPeer(a.address); err != nil {
+		t.Fatal(err)
+	}
+	if got := boundNodeID(a, b.address); got != b.nodeID {
+		t.Errorf("a bound %s to %q, want %s", b.address, got, b.nodeID)
+	}
+	if got := boundNodeID(b, a.address); got != a.nodeID {
+		t.Errorf("b bound %s to %q, want %s", a.address, got, a.nodeID)
+	}
+}
+
+func TestRegisterPeerRejectsAddressItDoesNotAnswerAt(t *testing.T) {
+	a, victim, attacker := newTestServer(t), newTestServer(t), newTestServer(t)
+	listen(t, a)
+	listen(t, victim)
+
+	claim := attacker.localHandshake()
+	claim.Address = victim.address
+	if code := register(t, a, claim); code != http.StatusForbidden {
+		t.Fatalf("got status %d, want 403", code)
+	}
+	if _, ok := a.peerKey(attacker.nodeID); ok {
+		t.Error("attacker's key was learned")
+	}
+	a.peersMutex.Lock()
+	_, stored := a.peers[victim.address]
+	a.peersMutex.Unlock()
+	if stored {
+		t.Error("unverified peer was stored")
+	}
+}
+
+func TestRegisterPeerKeepsBoundIdentity(t *testing.T) {
+	a, victim, attacker := newTestServer(t), newTestServer(t), newTestServer(t)
+	listen(t, a)
+	listen(t, victim)
+	if err := victim.ConnectPeer(a.address); err != nil {
+		t.Fatal(err)
+	}
+
+	claim := attacker.localHandshake()
+	claim.Address = victim.address
+	if code := register(t, a, claim); code != http.StatusConflict {
+		t.Fatalf("got status %d, want 409", code)
+	}
+	if got := boundNodeID(a, victim.address); got != victim.nodeID {
+		t.Fatalf("%s rebound to %q", victim.address, got)
+	}
+}
+
+func TestLearnIdentityRefusesRebinding(t *testing.T) {
+	p, first, second := newTestServer(t), newTestServer(t), newTestServer(t)
+	address := "127.0.0.1:9"
+	if _, err := p.addPeer(address); err != nil {
+		t.Fatal(err)
+	}
+
+	h := first.localHandshake()
+	h.Address = address
+	if err := p.learnIdentity(h); err != nil {
+		t.Fatal(err)
+	}
+	h = second.localHandshake()
+	h.Address = address
+	if err := p.learnIdentity(h); !errors.Is(err, errIdentityConflict) {
+		t.Fatalf("got %v, want errIdentityConflict", err)
+	}
+	if got := boundNodeID(p, address); got != first.nodeID {
+		t.Fatalf("address rebound to %q", got)
+	}
+}
@@ -0,0 +1,43 @@
+package network
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/anekazek/simple-blockchain/pkg/wallet"
+)
+
+// Identity is the key pair that identifies a node on the network
+type Identity struct {
+	PublicKey  ed25519.PublicKey
+	PrivateKey ed25519.PrivateKey
+}
+
+// GenerateIdentity creates a new random node identity
+func GenerateIdentity() (*Identity, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate identity key: %w", err)
+	}
+	return &Identity{
+		PublicKey:  publicKey,
+		PrivateKey: privateKey,
+	}, nil
+}
+
+// NodeID derives the node ID from the public key
+func (id *Identity) NodeID() string {
+	return NodeIDFromPublicKey(id.PublicKey)
+}
+
+// Sign signs a message with the identity's private key
+func (id *Identity) Sign(message []byte) []byte {
+	return ed25519.Sign(id.PrivateKey, message)
+}
+
+// NodeIDFromPublicKey derives the node ID for a public key, which is the
+// key's wallet address
+func NodeIDFromPublicKey(publicKey ed25519.PublicKey) string {
+	return wallet.AddressFromPublicKey(publicKey)
+}
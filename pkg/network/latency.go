@@ -0,0 +1,101 @@
+package network
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Latency estimation and sync peer preference
+const (
+	// latencySmoothing is the weight of a new sample in the rolling estimate
+	latencySmoothing = 0.3
+	// latencyHalfLife is how quickly an estimate fades toward
+	// unmeasuredLatency when a peer isn't sampled, so a peer that recovers
+	// isn't avoided forever
+	latencyHalfLife = 2 * time.Minute
+	// unmeasuredLatency is assumed for peers without recent samples
+	unmeasuredLatency = 250 * time.Millisecond
+	// syncSlowFactor is how many times costlier than the best peer a peer may
+	// be and still be given sync work
+	syncSlowFactor = 3
+	// syncExploreRate is the chance a slower peer is given work anyway, which
+	// keeps its estimate fresh
+	syncExploreRate = 0.1
+)
+
+// recordLatency folds a response time into a peer's rolling estimate
+func (p *P2PServer) recordLatency(address string, sample time.Duration) {
+	p.peersMutex.Lock()
+	defer p.peersMutex.Unlock()
+
+	peer, ok := p.peers[address]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	if peer.LatencyUpdated.IsZero() {
+		peer.Latency = sample
+	} else {
+		current := peer.effectiveLatency(now)
+		peer.Latency = time.Duration(latencySmoothing*float64(sample) + (1-latencySmoothing)*float64(current))
+	}
+	peer.LatencyUpdated = now
+	p.peers[address] = peer
+}
+
+// effectiveLatency returns the peer's latency estimate, decayed toward
+// unmeasuredLatency by the time since it was last sampled
+func (peer Peer) effectiveLatency(now time.Time) time.Duration {
+	if peer.LatencyUpdated.IsZero() {
+		return unmeasuredLatency
+	}
+	weight := math.Pow(0.5, float64(now.Sub(peer.LatencyUpdated))/float64(latencyHalfLife))
+	return time.Duration(weight*float64(peer.Latency) + (1-weight)*float64(unmeasuredLatency))
+}
+
+// syncCost ranks a peer for sync work; lower is better. Unreliable peers
+// cost more than their latency alone suggests.
+func (peer Peer) syncCost(now time.Time) float64 {
+	reliability := math.Max(peer.deliveryRatio(), 0.1)
+	return float64(peer.effectiveLatency(now)) / reliability * (1 + 0.5*float64(peer.ConsecutiveFailures))
+}
+
+// rankSyncPeers orders peers from cheapest to costliest for sync work,
+// returning the cost of each
+func (p *P2PServer) rankSyncPeers(addresses []string) ([]string, map[string]float64) {
+	p.peersMutex.Lock()
+	now := time.Now()
+	costs := make(map[string]float64, len(addresses))
+	for _, address := range addresses {
+		costs[address] = p.peers[address].syncCost(now)
+	}
+	p.peersMutex.Unlock()
+
+	ranked := append([]string(nil), addresses...)
+	sort.Slice(ranked, func(i, j int) bool {
+		if costs[ranked[i]] == costs[ranked[j]] {
+			return ranked[i] < ranked[j]
+		}
+		return costs[ranked[i]] < costs[ranked[j]]
+	})
+	return ranked, costs
+}
+
+// preferredSyncPeers orders peers from cheapest to costliest and drops those
+// much slower than the best, except for an occasional exploratory pick
+func (p *P2PServer) preferredSyncPeers(addresses []string) []string {
+	if len(addresses) == 0 {
+		return nil
+	}
+	ranked, costs := p.rankSyncPeers(addresses)
+	best := costs[ranked[0]]
+	preferred := ranked[:1]
+	for _, address := range ranked[1:] {
+		if costs[address] <= best*syncSlowFactor || rand.Float64() < syncExploreRate {
+			preferred = append(preferred, address)
+		}
+	}
+	return preferred
+}
@@ -0,0 +1,74 @@
+package network
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// delayedPeer serves p's P2P routes after a delay, counting range requests
+func delayedPeer(t *testing.T, p *P2PServer, delay time.Duration) (string, *atomic.Int32) {
+	t.Helper()
+	mux := http.NewServeMux()
+	p.RegisterRoutes(mux)
+	var ranges atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		if r.URL.Query().Has("from_index") {
+			ranges.Add(1)
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://"), &ranges
+}
+
+func TestFastPeerGetsMostSyncWork(t *testing.T) {
+	ahead, behind := newTestServer(t), newTestServer(t)
+	growChain(t, ahead.chain, 16*syncChunkSize)
+	fast, fastRanges := delayedPeer(t, ahead, 5*time.Millisecond)
+	slow, slowRanges := delayedPeer(t, ahead, 150*time.Millisecond)
+	behind.AddPeer(fast)
+	behind.AddPeer(slow)
+	behind.recordLatency(fast, 5*time.Millisecond)
+	behind.recordLatency(slow, 150*time.Millisecond)
+
+	target := ahead.chain.Height()
+	if err := behind.parallelSync(map[string]int{fast: target, slow: target}, target); err != nil {
+		t.Fatal(err)
+	}
+	if got := behind.chain.GetLatestBlock().Hash; got != ahead.chain.GetLatestBlock().Hash {
+		t.Fatal("didn't sync to the peers' tip")
+	}
+	if f, s := fastRanges.Load(), slowRanges.Load(); f <= s*2 {
+		t.Fatalf("fast peer served %d chunks and slow peer %d, want most from the fast one", f, s)
+	}
+
+	var listed bool
+	for _, info := range behind.Peers() {
+		if info.Address == slow {
+			listed = info.LatencyMs >= 100
+		}
+	}
+	if !listed {
+		t.Error("peer listing doesn't show the slow peer's latency")
+	}
+}
+
+func TestLatencyDecaysTowardUnmeasured(t *testing.T) {
+	now := time.Now()
+	peer := Peer{Latency: 2 * time.Second, LatencyUpdated: now}
+	if got := peer.effectiveLatency(now); got != 2*time.Second {
+		t.Fatalf("fresh estimate %s, want 2s", got)
+	}
+	if got := peer.effectiveLatency(now.Add(latencyHalfLife)); got >= 2*time.Second || got <= unmeasuredLatency {
+		t.Fatalf("estimate after a half-life %s, want between %s and 2s", got, unmeasuredLatency)
+	}
+	later := peer.effectiveLatency(now.Add(20 * latencyHalfLife))
+	if diff := later - unmeasuredLatency; diff < 0 || diff > time.Millisecond {
+		t.Fatalf("estimate long after the last sample %s, want about %s", later, unmeasuredLatency)
+	}
+}
@@ -0,0 +1,310 @@
+//go:build libp2p
+
+package network
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/libp2p/go-libp2p"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// libp2p topic and protocol names
+const (
+	libp2pBlockTopic       = "simple-blockchain/blocks/1"
+	libp2pTransactionTopic = "simple-blockchain/transactions/1"
+	libp2pSyncProtocol     = protocol.ID("/simple-blockchain/sync/1.0.0")
+)
+
+// libp2pSyncRequest asks a peer for the blocks after an index
+type libp2pSyncRequest struct {
+	FromIndex int `json:"fromIndex"`
+}
+
+// Libp2pTransport is a Transport built on libp2p, using gossipsub for block
+// and transaction propagation and a request/response stream for range sync
+type Libp2pTransport struct {
+	chain        *blockchain.Chain
+	txPool       *blockchain.TransactionPool
+	identity     *Identity
+	host         host.Host
+	blocks       *pubsub.Topic
+	transactions *pubsub.Topic
+	bootstrap    []string
+	ctx          context.Context
+	cancel       context.CancelFunc
+}
+
+// NewLibp2pTransport creates a libp2p host listening on the given TCP port
+// whose peer identity is derived from the node identity key. Bootstrap
+// peers are given as multiaddrs including the /p2p/ peer ID.
+func NewLibp2pTransport(chain *blockchain.Chain, txPool *blockchain.TransactionPool, port string, identity *Identity, bootstrap []string) (Transport, error) {
+	privateKey, err := crypto.UnmarshalEd25519PrivateKey(identity.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert identity key: %w", err)
+	}
+
+	h, err := libp2p.New(
+		libp2p.Identity(privateKey),
+		libp2p.ListenAddrStrings("/ip4/0.0.0.0/tcp/"+port),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create libp2p host: %w", err)
+	}
+
+	t, err := newLibp2pTransport(chain, txPool, identity, h, bootstrap)
+	if err != nil {
+		h.Close()
+		return nil, err
+	}
+	for _, addr := range h.Addrs() {
+		log.Printf("libp2p listening on %s/p2p/%s\n", addr, h.ID())
+	}
+	return t, nil
+}
+
+// newLibp2pTransport runs gossipsub and the sync protocol on an existing host
+func newLibp2pTransport(chain *blockchain.Chain, txPool *blockchain.TransactionPool, identity *Identity, h host.Host, bootstrap []string) (*Libp2pTransport, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	// Flood our own blocks and transactions to every subscriber rather than
+	// only the mesh, which is empty until the first heartbeat
+	ps, err := pubsub.NewGossipSub(ctx, h, pubsub.WithFloodPublish(true))
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to start gossipsub: %w", err)
+	}
+
+	blocks, err := ps.Join(libp2pBlockTopic)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to join block topic: %w", err)
+	}
+	transactions, err := ps.Join(libp2pTransactionTopic)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to join transaction topic: %w", err)
+	}
+
+	t := &Libp2pTransport{
+		chain:        chain,
+		txPool:       txPool,
+		identity:     identity,
+		host:         h,
+		blocks:       blocks,
+		transactions: transactions,
+		bootstrap:    bootstrap,
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+	h.SetStreamHandler(libp2pSyncProtocol, t.handleSyncStream)
+	return t, nil
+}
+
+// Start subscribes to the gossip topics and begins periodic range sync
+func (t *Libp2pTransport) Start() {
+	go t.readBlocks()
+	go t.readTransactions()
+	go t.syncLoop()
+}
+
+// ListenAndServe connects to the bootstrap peers and runs until the transport is closed
+func (t *Libp2pTransport) ListenAndServe() error {
+	for _, addr := range t.bootstrap {
+		info, err := peer.AddrInfoFromString(addr)
+		if err != nil {
+			log.Printf("Invalid libp2p bootstrap address %s: %v\n", addr, err)
+			continue
+		}
+		if err := t.host.Connect(t.ctx, *info); err != nil {
+			log.Printf("Failed to connect to libp2p peer %s: %v\n", addr, err)
+		}
+	}
+
+	<-t.ctx.Done()
+	return t.host.Close()
+}
+
+// Close stops the transport
+func (t *Libp2pTransport) Close() {
+	t.cancel()
+}
+
+// BroadcastBlock publishes a block on the block topic
+func (t *Libp2pTransport) BroadcastBlock(block blockchain.Block) {
+	t.publish(t.blocks, block)
+}
+
+// BroadcastTransaction publishes a transaction on the transaction topic
+func (t *Libp2pTransport) BroadcastTransaction(tx *blockchain.Transaction) {
+	t.publish(t.transactions, tx)
+}
+
+// PeerCount returns the number of connected libp2p peers
+func (t *Libp2pTransport) PeerCount() int {
+	return len(t.host.Network().Peers())
+}
+
+// NodeID returns this node's identifier on the network
+func (t *Libp2pTransport) NodeID() string {
+	return t.identity.NodeID()
+}
+
+// publish encodes and publishes a payload on a topic
+func (t *Libp2pTransport) publish(topic *pubsub.Topic, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Failed to encode libp2p message: %v\n", err)
+		return
+	}
+	if err := topic.Publish(t.ctx, data); err != nil {
+		log.Printf("Failed to publish on %s: %v\n", topic.String(), err)
+	}
+}
+
+// readBlocks adds blocks received on the block topic to the chain
+func (t *Libp2pTransport) readBlocks() {
+	sub, err := t.blocks.Subscribe()
+	if err != nil {
+		log.Printf("Failed to subscribe to blocks: %v\n", err)
+		return
+	}
+
+	for {
+		msg, err := sub.Next(t.ctx)
+		if err != nil {
+			return
+		}
+		if msg.ReceivedFrom == t.host.ID() {
+			continue
+		}
+
+		var block blockchain.Block
+		if err := json.Unmarshal(msg.Data, &block); err != nil {
+			continue
+		}
+		if err := t.chain.AddExistingBlock(block); err == nil {
+			log.Printf("Added new block from libp2p peer: %s\n", block.Hash)
+		}
+	}
+}
+
+// readTransactions adds transactions received on the transaction topic to the pool
+func (t *Libp2pTransport) readTransactions() {
+	sub, err := t.transactions.Subscribe()
+	if err != nil {
+		log.Printf("Failed to subscribe to transactions: %v\n", err)
+		return
+	}
+
+	for {
+		msg, err := sub.Next(t.ctx)
+		if err != nil {
+			return
+		}
+		if msg.ReceivedFrom == t.host.ID() || t.txPool == nil {
+			continue
+		}
+
+		var tx blockchain.Transaction
+		if err := json.Unmarshal(msg.Data, &tx); err != nil {
+			continue
+		}
+		t.txPool.AddTransaction(&tx)
+	}
+}
+
+// syncLoop periodically pulls missing blocks from connected peers
+func (t *Libp2pTransport) syncLoop() {
+	ticker := time.NewTicker(60 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-ticker.C:
+			for _, id := range t.host.Network().Peers() {
+				if err := t.syncFrom(id); err != nil {
+					log.Printf("libp2p sync with %s failed: %v\n", id, err)
+				}
+			}
+		}
+	}
+}
+
+// syncFrom requests ranges from a peer until it has nothing newer
+func (t *Libp2pTransport) syncFrom(id peer.ID) error {
+	for {
+		blocks, err := t.requestRange(id, t.chain.GetLatestBlock().Index)
+		if err != nil {
+			return err
+		}
+		if len(blocks) == 0 {
+			return nil
+		}
+		for _, block := range blocks {
+			if err := t.chain.AddExistingBlock(block); err != nil {
+				return errChainDiverged
+			}
+		}
+	}
+}
+
+// requestRange opens a sync stream and reads back the blocks after an index
+func (t *Libp2pTransport) requestRange(id peer.ID, from int) ([]blockchain.Block, error) {
+	ctx, cancel := context.WithTimeout(t.ctx, 30*time.Second)
+	defer cancel()
+
+	stream, err := t.host.NewStream(ctx, id, libp2pSyncProtocol)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	if err := json.NewEncoder(stream).Encode(libp2pSyncRequest{FromIndex: from}); err != nil {
+		return nil, err
+	}
+	stream.CloseWrite()
+
+	var blocks []blockchain.Block
+	if err := json.NewDecoder(bufio.NewReader(stream)).Decode(&blocks); err != nil {
+		return nil, fmt.Errorf("failed to decode blocks: %w", err)
+	}
+	return blocks, nil
+}
+
+// handleSyncStream answers a range sync request
+func (t *Libp2pTransport) handleSyncStream(stream network.Stream) {
+	defer stream.Close()
+
+	var req libp2pSyncRequest
+	if err := json.NewDecoder(bufio.NewReader(stream)).Decode(&req); err != nil {
+		stream.Reset()
+		return
+	}
+
+	blocks := t.chain.GetBlocks()
+	start := req.FromIndex + 1
+	if start < 0 {
+		start = 0
+	}
+	if start > len(blocks) {
+		start = len(blocks)
+	}
+	end := start + maxSyncBatch
+	if end > len(blocks) {
+		end = len(blocks)
+	}
+	json.NewEncoder(stream).Encode(blocks[start:end])
+}
@@ -0,0 +1,14 @@
+//go:build !libp2p
+
+package network
+
+import (
+	"errors"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// NewLibp2pTransport is unavailable unless the binary is built with -tags libp2p
+func NewLibp2pTransport(chain *blockchain.Chain, txPool *blockchain.TransactionPool, port string, identity *Identity, bootstrap []string) (Transport, error) {
+	return nil, errors.New("libp2p transport not compiled in; rebuild with -tags libp2p")
+}
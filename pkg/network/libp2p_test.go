@@ -0,0 +1,129 @@
+//go:build libp2p
+
+package network
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// newLibp2pMesh starts n libp2p transports on fresh chains, fully connected
+// over an in-memory network
+func newLibp2pMesh(t *testing.T, n int) []*Libp2pTransport {
+	t.Helper()
+	mn := mocknet.New()
+	t.Cleanup(func() { mn.Close() })
+
+	transports := make([]*Libp2pTransport, n)
+	for i := range transports {
+		identity, err := GenerateIdentity()
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := crypto.UnmarshalEd25519PrivateKey(identity.PrivateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, err := mn.AddPeer(key, ma.StringCast(fmt.Sprintf("/ip4/10.0.0.%d/tcp/4001", i+1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr, err := newLibp2pTransport(blockchain.NewBlockchain(), blockchain.NewTransactionPool(10), identity, h, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(tr.Close)
+		transports[i] = tr
+	}
+
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+	// One connection per pair: a second one makes gossipsub replace its
+	// stream and drop whatever was queued on the old one
+	for i, a := range transports {
+		for _, b := range transports[i+1:] {
+			if _, err := mn.ConnectPeers(a.host.ID(), b.host.ID()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return transports
+}
+
+func TestLibp2pPeerIdentityComesFromNodeKey(t *testing.T) {
+	tr := newLibp2pMesh(t, 1)[0]
+
+	key, err := tr.host.ID().ExtractPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := key.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := NodeIDFromPublicKey(raw); got != tr.NodeID() {
+		t.Fatalf("peer ID belongs to node %s, want %s", got, tr.NodeID())
+	}
+}
+
+func TestLibp2pBlocksPropagateOverGossipsub(t *testing.T) {
+	mesh := newLibp2pMesh(t, 3)
+	for _, tr := range mesh {
+		tr.Start()
+	}
+	for _, tr := range mesh {
+		waitFor(t, "block topic subscribers", func() bool {
+			return len(tr.blocks.ListPeers()) == len(mesh)-1
+		})
+	}
+
+	block, err := mesh[0].chain.AddBlock(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mesh[0].BroadcastBlock(block)
+
+	for i, tr := range mesh[1:] {
+		waitFor(t, fmt.Sprintf("block on node %d", i+1), func() bool {
+			return tr.chain.GetLatestBlock().Hash == block.Hash
+		})
+	}
+}
+
+func TestLibp2pSyncCatchesUpInRanges(t *testing.T) {
+	mesh := newLibp2pMesh(t, 2)
+	ahead, behind := mesh[0], mesh[1]
+	growChain(t, ahead.chain, 2*maxSyncBatch+10)
+
+	if err := behind.syncFrom(ahead.host.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := behind.chain.GetLatestBlock(), ahead.chain.GetLatestBlock(); got.Hash != want.Hash {
+		t.Fatalf("synced to height %d, want %d", got.Index, want.Index)
+	}
+}
+
+func TestLibp2pSyncRejectsDivergedPeer(t *testing.T) {
+	mesh := newLibp2pMesh(t, 2)
+	growChain(t, mesh[0].chain, 3)
+
+	// mesh[1] mines a block of its own at height 1
+	pool := blockchain.NewTransactionPool(10)
+	if err := pool.AddTransaction(&blockchain.Transaction{ID: "fork", To: "bob", Timestamp: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mesh[1].chain.AddBlock(pool, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mesh[1].syncFrom(mesh[0].host.ID()); err != errChainDiverged {
+		t.Fatalf("got %v, want errChainDiverged", err)
+	}
+}
@@ -0,0 +1,100 @@
+package network
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// intervalJitter is the fraction by which each periodic tick is randomized
+// so restarted fleets don't hit their peers in lockstep
+const intervalJitter = 0.2
+
+// stopTimeout bounds how long Stop waits for the HTTP server to drain
+const stopTimeout = 5 * time.Second
+
+// IntervalConfig holds the periods of the P2P background loops
+type IntervalConfig struct {
+	// Discovery is how often peers are asked for their peer lists
+	Discovery time.Duration
+	// Sync is how often the chain is compared against peers
+	Sync time.Duration
+}
+
+// DefaultIntervalConfig returns the default background loop periods
+func DefaultIntervalConfig() IntervalConfig {
+	return IntervalConfig{
+		Discovery: 30 * time.Second,
+		Sync:      60 * time.Second,
+	}
+}
+
+// jittered returns d randomized by up to ±intervalJitter
+func jittered(d time.Duration) time.Duration {
+	spread := float64(d) * intervalJitter
+	return d + time.Duration((rand.Float64()*2-1)*spread)
+}
+
+// sleep waits for d, reporting false if the server stopped first
+func (p *P2PServer) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-p.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// every runs fn once per jittered interval until the server stops
+func (p *P2PServer) every(interval time.Duration, fn func()) {
+	for p.sleep(jittered(interval)) {
+		fn()
+	}
+}
+
+// spawn runs fn in a goroutine that Stop waits for. It reports false
+// without running fn once the server has stopped.
+func (p *P2PServer) spawn(fn func()) bool {
+	p.lifecycleMutex.Lock()
+	defer p.lifecycleMutex.Unlock()
+
+	if p.ctx.Err() != nil {
+		return false
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		fn()
+	}()
+	return true
+}
+
+// Stop cancels the background loops, waits for them and any in-flight
+// broadcasts to finish, and closes peer sessions and the HTTP server
+func (p *P2PServer) Stop() {
+	p.lifecycleMutex.Lock()
+	p.cancel()
+	server := p.httpServer
+	p.lifecycleMutex.Unlock()
+
+	p.sessionsMutex.Lock()
+	sessions := make([]*peerSession, 0, len(p.sessions))
+	for _, session := range p.sessions {
+		sessions = append(sessions, session)
+	}
+	p.sessionsMutex.Unlock()
+	for _, session := range sessions {
+		session.close()
+	}
+
+	if server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+		defer cancel()
+		server.Shutdown(ctx)
+	}
+
+	p.wg.Wait()
+}
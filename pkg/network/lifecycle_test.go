@@ -0,0 +1,52 @@
+package network
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+func TestStopEndsBackgroundLoops(t *testing.T) {
+	p := NewP2PServer(blockchain.NewBlockchain(), "0", IntervalConfig{Discovery: 10 * time.Millisecond, Sync: 10 * time.Millisecond})
+	var ticks atomic.Int32
+	p.Start()
+	p.spawn(func() { p.every(10*time.Millisecond, func() { ticks.Add(1) }) })
+	waitFor(t, "the loop to tick", func() bool { return ticks.Load() > 2 })
+
+	stopped := make(chan struct{})
+	go func() {
+		p.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop didn't return promptly")
+	}
+
+	after := ticks.Load()
+	time.Sleep(50 * time.Millisecond)
+	if ticks.Load() != after {
+		t.Error("loop kept ticking after Stop")
+	}
+	if p.spawn(func() {}) {
+		t.Error("spawned work after Stop")
+	}
+}
+
+func TestIntervalsAreJittered(t *testing.T) {
+	const interval = time.Second
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 20; i++ {
+		d := jittered(interval)
+		if d < interval*8/10 || d > interval*12/10 {
+			t.Fatalf("jittered %s to %s, beyond 20%%", interval, d)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 2 {
+		t.Fatal("every tick got the same interval")
+	}
+}
@@ -0,0 +1,337 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// Node roles exchanged in the handshake
+const (
+	// RoleFull nodes store and serve whole blocks
+	RoleFull = "full"
+	// RoleLight nodes track headers only and verify transactions with
+	// merkle proofs fetched from full peers
+	RoleLight = "light"
+)
+
+// maxHeaderBatch bounds how many headers are returned for one request
+const maxHeaderBatch = 2000
+
+// Errors returned by light-node operations
+var (
+	errLightNode      = errors.New("light nodes do not serve block bodies")
+	errUnknownHeader  = errors.New("proof refers to a block outside the header chain")
+	errInvalidProof   = errors.New("merkle proof does not match the block header")
+	errNoProofSource  = errors.New("no full peer could prove the transaction")
+	errTxNotInChain   = errors.New("transaction not found on chain")
+	errHeaderDiverged = errors.New("peer headers do not connect to the local header chain")
+)
+
+// TransactionProof shows that a transaction was mined into a block
+type TransactionProof struct {
+	BlockHash   string                  `json:"blockHash"`
+	BlockIndex  int                     `json:"blockIndex"`
+	Transaction blockchain.Transaction  `json:"transaction"`
+	Proof       []blockchain.MerkleStep `json:"proof"`
+}
+
+// SetRole sets whether this node is a full or a light node. It must be
+// called before the server starts. Light nodes keep a header chain instead of
+// relying on block bodies and ignore pending transactions.
+func (p *P2PServer) SetRole(role string) {
+	p.role = role
+	if role == RoleLight && p.headers == nil {
+		p.headers = blockchain.NewHeaderChain()
+	}
+}
+
+// Role returns the node role advertised to peers
+func (p *P2PServer) Role() string {
+	return p.role
+}
+
+// isLight reports whether this node runs in light mode
+func (p *P2PServer) isLight() bool {
+	return p.role == RoleLight
+}
+
+// peerIsLight reports whether a peer advertised the light role
+func (p *P2PServer) peerIsLight(address string) bool {
+	p.peersMutex.Lock()
+	defer p.peersMutex.Unlock()
+	return p.peers[address].Role == RoleLight
+}
+
+// fullPeerAddresses returns the known peers that serve block bodies
+func (p *P2PServer) fullPeerAddresses() []string {
+	var peers []string
+	for _, address := range p.peerAddresses() {
+		if !p.peerIsLight(address) {
+			peers = append(peers, address)
+		}
+	}
+	return peers
+}
+
+// fullOnly answers 403 on light nodes, which don't serve block bodies,
+// ranges, or pending transactions
+func (p *P2PServer) fullOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if p.isLight() {
+			http.Error(w, errLightNode.Error(), http.StatusForbidden)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// localTip returns the index and hash of our latest block or header. An
+// empty header chain reports index -1.
+func (p *P2PServer) localTip() (int, string) {
+	if p.isLight() {
+		header, ok := p.headers.Latest()
+		if !ok {
+			return -1, ""
+		}
+		return header.Index, header.Hash
+	}
+	latest := p.chain.GetLatestBlock()
+	return latest.Index, latest.Hash
+}
+
+// handleHeaders returns up to count headers after from_index
+func (p *P2PServer) handleHeaders(w http.ResponseWriter, r *http.Request) {
+	from, err := strconv.Atoi(r.URL.Query().Get("from_index"))
+	if err != nil || from < -1 {
+		http.Error(w, "Invalid from_index", http.StatusBadRequest)
+		return
+	}
+	count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+	if count < 1 || count > maxHeaderBatch {
+		count = maxHeaderBatch
+	}
+
+	if p.isLight() {
+		json.NewEncoder(w).Encode(p.headers.HeadersAfter(from, count))
+		return
+	}
+
+	headers := p.chain.GetHeaders(from+1, count)
+	if headers == nil {
+		headers = []blockchain.BlockHeader{}
+	}
+	json.NewEncoder(w).Encode(headers)
+}
+
+// handleProof returns a merkle proof for a mined transaction
+func (p *P2PServer) handleProof(w http.ResponseWriter, r *http.Request) {
+	proof, err := p.proveTransaction(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(proof)
+}
+
+// proveTransaction builds a merkle proof for a transaction on our chain,
+// searching from the tip
+func (p *P2PServer) proveTransaction(txID string) (TransactionProof, error) {
+	blocks := p.chain.GetBlocks()
+	for i := len(blocks) - 1; i >= 0; i-- {
+		txs := blockchain.BlockTransactions(blocks[i])
+		for j, tx := range txs {
+			if tx.ID != txID {
+				continue
+			}
+			steps, err := blockchain.MerkleProof(txs, j)
+			if err != nil {
+				return TransactionProof{}, err
+			}
+			return TransactionProof{
+				BlockHash:   blocks[i].Hash,
+				BlockIndex:  blocks[i].Index,
+				Transaction: tx,
+				Proof:       steps,
+			}, nil
+		}
+	}
+	return TransactionProof{}, errTxNotInChain
+}
+
+// VerifyTransaction proves that a transaction was mined. Light nodes fetch
+// a merkle proof from full peers and check it against their header chain;
+// full nodes search their own chain.
+func (p *P2PServer) VerifyTransaction(txID string) (TransactionProof, error) {
+	if !p.isLight() {
+		return p.proveTransaction(txID)
+	}
+
+	lastErr := errNoProofSource
+	for _, address := range p.fullPeerAddresses() {
+		proof, err := p.fetchProof(address, txID)
+		if err == nil {
+			err = p.checkProof(txID, proof)
+		}
+		if err == nil {
+			return proof, nil
+		}
+		if errors.Is(err, errInvalidProof) {
+			p.reportInvalid()
+		}
+		lastErr = err
+	}
+	return TransactionProof{}, lastErr
+}
+
+// fetchProof asks a full peer for a transaction's merkle proof
+func (p *P2PServer) fetchProof(address, txID string) (TransactionProof, error) {
+	resp, err := p.getPeer(p.httpClient, p.peerURL(address, "/proof/"+txID))
+	if err != nil {
+		return TransactionProof{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return TransactionProof{}, errTxNotInChain
+	}
+	if resp.StatusCode != http.StatusOK {
+		return TransactionProof{}, fmt.Errorf("unexpected status from %s: %s", address, resp.Status)
+	}
+	var proof TransactionProof
+	if err := json.NewDecoder(resp.Body).Decode(&proof); err != nil {
+		return TransactionProof{}, fmt.Errorf("failed to decode proof: %w", err)
+	}
+	return proof, nil
+}
+
+// checkProof verifies a proof against the header chain
+func (p *P2PServer) checkProof(txID string, proof TransactionProof) error {
+	header, ok := p.headers.GetHeader(proof.BlockHash)
+	if !ok {
+		return errUnknownHeader
+	}
+	if proof.Transaction.ID != txID || !blockchain.VerifyMerkleProof(proof.Transaction, proof.Proof, header.MerkleRoot) {
+		return errInvalidProof
+	}
+	return nil
+}
+
+// syncHeaders catches the header chain up with the tallest full peer
+func (p *P2PServer) syncHeaders() {
+	local, _ := p.localTip()
+	best := ""
+	bestHeight := local
+	for _, address := range p.fullPeerAddresses() {
+		height, err := p.fetchHeight(address)
+		if err != nil {
+			if !quietPeerError(err) {
+				log.Printf("Failed to get height from %s: %v\n", address, err)
+			}
+			continue
+		}
+		p.setPeerHeight(address, height.Height)
+		if height.Height > bestHeight {
+			best = address
+			bestHeight = height.Height
+		}
+	}
+	if best == "" {
+		return
+	}
+	p.syncHeadersFrom(best)
+}
+
+// syncHeadersFrom downloads, validates, and appends headers from a peer
+// until it has no more. Only one header sync runs at a time.
+func (p *P2PServer) syncHeadersFrom(address string) {
+	if !p.headerSyncMutex.TryLock() {
+		return
+	}
+	defer p.headerSyncMutex.Unlock()
+
+	for {
+		from, _ := p.localTip()
+		headers, err := p.fetchHeaders(address, from)
+		if err != nil {
+			if !quietPeerError(err) {
+				log.Printf("Header sync with %s failed: %v\n", address, err)
+			}
+			return
+		}
+		if len(headers) == 0 {
+			return
+		}
+
+		for _, header := range headers {
+			var parent *blockchain.BlockHeader
+			if tip, ok := p.headers.Latest(); ok {
+				if header.PrevHash != tip.Hash {
+					log.Printf("Header sync with %s stopped: %v\n", address, errHeaderDiverged)
+					return
+				}
+				parent = &tip
+			}
+			if err := p.validateHeader(header, parent); err != nil {
+				p.penalize(address, err)
+				return
+			}
+			if err := p.headers.AddHeader(header); err != nil {
+				log.Printf("Header sync with %s stopped: %v\n", address, err)
+				return
+			}
+		}
+		log.Printf("Synced %d headers from %s\n", len(headers), address)
+	}
+}
+
+// fetchHeaders requests the headers after an index from a peer
+func (p *P2PServer) fetchHeaders(address string, from int) ([]blockchain.BlockHeader, error) {
+	url := p.peerURL(address, fmt.Sprintf("/headers?from_index=%d&count=%d", from, maxHeaderBatch))
+	resp, err := p.getPeer(p.httpClient, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status from %s: %s", address, resp.Status)
+	}
+	var headers []blockchain.BlockHeader
+	if err := json.NewDecoder(resp.Body).Decode(&headers); err != nil {
+		return nil, fmt.Errorf("failed to decode headers: %w", err)
+	}
+	return headers, nil
+}
+
+// processLightBlock takes the header of a block pushed to a light node,
+// catching up from the sender when it doesn't extend our header tip
+func (p *P2PServer) processLightBlock(block blockchain.Block, source string) (int, error) {
+	if _, known := p.headers.GetHeader(block.Hash); known {
+		return http.StatusOK, nil
+	}
+	tip, ok := p.headers.Latest()
+	if !ok || block.PrevHash != tip.Hash {
+		p.spawn(func() { p.syncHeadersFrom(source) })
+		return http.StatusAccepted, nil
+	}
+	if !blockchain.HashMatches(block) {
+		err := fmt.Errorf("%w: block %d has a bad hash", errInvalidBlock, block.Index)
+		p.penalize(source, err)
+		return http.StatusConflict, err
+	}
+	header := block.Header()
+	if err := p.validateHeader(header, &tip); err != nil {
+		p.penalize(source, err)
+		return http.StatusConflict, err
+	}
+	if err := p.headers.AddHeader(header); err != nil {
+		return http.StatusConflict, err
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,67 @@
+package network
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+func TestLightNodeTracksHeadersAndVerifiesProofs(t *testing.T) {
+	full, light := newTestServer(t), newTestServer(t)
+	light.SetRole(RoleLight)
+	listen(t, full)
+	listen(t, light)
+
+	pool := blockchain.NewTransactionPool(10)
+	for _, id := range []string{"a", "b", "c"} {
+		if err := pool.AddTransaction(&blockchain.Transaction{ID: id, To: "bob"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := full.chain.AddBlock(pool, 0); err != nil {
+		t.Fatal(err)
+	}
+	growChain(t, full.chain, 2)
+
+	if err := light.ConnectPeer(full.address); err != nil {
+		t.Fatal(err)
+	}
+	light.syncWithPeers()
+
+	header, ok := light.headers.Latest()
+	if tip := full.chain.GetLatestBlock(); !ok || header.Hash != tip.Hash {
+		t.Fatalf("light node's header tip is %+v, want block %d", header, tip.Index)
+	}
+	if light.chain.Height() != 0 {
+		t.Fatalf("light node stored %d block bodies", light.chain.Height())
+	}
+
+	proof, err := light.VerifyTransaction("b")
+	if err != nil || proof.BlockIndex != 1 {
+		t.Fatalf("got proof %+v, %v", proof, err)
+	}
+	if _, err := light.VerifyTransaction("missing"); !errors.Is(err, errTxNotInChain) {
+		t.Fatalf("verifying an unmined transaction: got %v, want errTxNotInChain", err)
+	}
+
+	// A light node serves headers but no bodies
+	mux := http.NewServeMux()
+	light.RegisterRoutes(mux)
+	for path, want := range map[string]int{
+		"/headers?from_index=0":      http.StatusOK,
+		"/sync?from_index=0&count=1": http.StatusForbidden,
+		"/block/" + header.Hash:      http.StatusForbidden,
+		"/mempool":                   http.StatusForbidden,
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(networkIDHeader, light.networkID)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("light node answered %s with %d, want %d", path, w.Code, want)
+		}
+	}
+}
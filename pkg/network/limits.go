@@ -0,0 +1,215 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Size limits for inbound P2P request bodies
+const (
+	maxBlockMessageBytes       = 2 << 20
+	maxTransactionMessageBytes = 64 << 10
+	maxHandshakeBytes          = 4 << 10
+)
+
+// Per-peer rate limiting and banning policy
+const (
+	peerRequestsPerSecond = 20
+	peerRequestBurst      = 50
+	peerBanThreshold      = 20
+	peerBanDuration       = 5 * time.Minute
+	peerLimiterIdleTTL    = 10 * time.Minute
+)
+
+// tokenBucket tracks the request allowance of one peer
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// violationCount tracks the offences of one peer since it was last banned
+type violationCount struct {
+	count int
+	last  time.Time
+}
+
+// peerLimiter rate limits requests per peer and bans repeat offenders
+type peerLimiter struct {
+	rate        float64
+	burst       float64
+	buckets     map[string]*tokenBucket
+	violations  map[string]*violationCount
+	bannedUntil map[string]time.Time
+	mutex       sync.Mutex
+}
+
+// newPeerLimiter creates a limiter allowing rate requests per second with the given burst
+func newPeerLimiter(rate, burst float64) *peerLimiter {
+	return &peerLimiter{
+		rate:        rate,
+		burst:       burst,
+		buckets:     make(map[string]*tokenBucket),
+		violations:  make(map[string]*violationCount),
+		bannedUntil: make(map[string]time.Time),
+	}
+}
+
+// Allow consumes a token for the peer, reporting false when it has none left
+func (l *peerLimiter) Allow(key string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		l.pruneLocked(now)
+		bucket = &tokenBucket{tokens: l.burst, lastSeen: now}
+		l.buckets[key] = bucket
+	}
+
+	bucket.tokens += now.Sub(bucket.lastSeen).Seconds() * l.rate
+	if bucket.tokens > l.burst {
+		bucket.tokens = l.burst
+	}
+	bucket.lastSeen = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// RecordViolation counts a rejected request and bans the peer once it
+// crosses the threshold. It reports whether the peer is now banned.
+func (l *peerLimiter) RecordViolation(key string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	violations, ok := l.violations[key]
+	if !ok {
+		l.pruneLocked(now)
+		violations = &violationCount{}
+		l.violations[key] = violations
+	}
+
+	violations.count++
+	violations.last = now
+	if violations.count >= peerBanThreshold {
+		l.bannedUntil[key] = now.Add(peerBanDuration)
+		delete(l.violations, key)
+		return true
+	}
+	return false
+}
+
+// IsBanned reports whether the peer is currently banned
+func (l *peerLimiter) IsBanned(key string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	until, ok := l.bannedUntil[key]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(l.bannedUntil, key)
+		return false
+	}
+	return true
+}
+
+// Violations returns the current violation count for each peer
+func (l *peerLimiter) Violations() map[string]int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	counts := make(map[string]int, len(l.violations))
+	for key, violations := range l.violations {
+		counts[key] = violations.count
+	}
+	return counts
+}
+
+// pruneLocked drops buckets and violation counts of peers idle longer
+// than the TTL, and bans that have expired
+func (l *peerLimiter) pruneLocked(now time.Time) {
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.lastSeen) > peerLimiterIdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+	for key, violations := range l.violations {
+		if now.Sub(violations.last) > peerLimiterIdleTTL {
+			delete(l.violations, key)
+		}
+	}
+	for key, until := range l.bannedUntil {
+		if now.After(until) {
+			delete(l.bannedUntil, key)
+		}
+	}
+}
+
+// requesterKey identifies the peer behind a request by its IP address. The
+// X-Node-ID header isn't authenticated, so keying on it would let a peer
+// dodge its ban or spend another node's allowance.
+func (p *P2PServer) requesterKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limited wraps a P2P handler with per-peer rate limiting, banning, and a body size limit
+func (p *P2PServer) limited(maxBytes int64, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := p.requesterKey(r)
+		if p.limiter.IsBanned(key) {
+			http.Error(w, "Peer temporarily banned", http.StatusForbidden)
+			return
+		}
+		if !p.limiter.Allow(key) {
+			p.limiter.RecordViolation(key)
+			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		if !p.decompressBody(w, r, maxBytes) {
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// decodeBody decodes a JSON request body, answering 413 for oversized bodies
+// and 400 for malformed ones. It reports whether decoding succeeded.
+func (p *P2PServer) decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil {
+		return true
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	p.reportInvalid()
+	if errors.As(err, &maxBytesErr) {
+		p.limiter.RecordViolation(p.requesterKey(r))
+		http.Error(w, "Message too large", http.StatusRequestEntityTooLarge)
+		return false
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+	return false
+}
+
+// PeerViolations returns the number of rate and size limit violations per
+// peer, for use in peer scoring
+func (p *P2PServer) PeerViolations() map[string]int {
+	return p.limiter.Violations()
+}
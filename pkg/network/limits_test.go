@@ -0,0 +1,116 @@
+package network
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// postFrom posts body to path on p's routes from the given IP address
+func postFrom(p *P2PServer, mux *http.ServeMux, ip, path string, body []byte) int {
+	return postAs(p, mux, ip, "", path, body)
+}
+
+// postAs posts like postFrom, claiming to be nodeID when it isn't empty
+func postAs(p *P2PServer, mux *http.ServeMux, ip, nodeID, path string, body []byte) int {
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	req.RemoteAddr = ip + ":4000"
+	req.Header.Set(networkIDHeader, p.networkID)
+	if nodeID != "" {
+		req.Header.Set(nodeIDHeader, nodeID)
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestFloodingPeerIsThrottledAndBanned(t *testing.T) {
+	p := newTestServer(t)
+	mux := http.NewServeMux()
+	p.RegisterRoutes(mux)
+
+	codes := make(map[int]int)
+	for i := 0; i < peerRequestBurst+peerBanThreshold+10; i++ {
+		codes[postFrom(p, mux, "10.0.0.1", "/broadcast-tx", []byte("{}"))]++
+	}
+	if codes[http.StatusTooManyRequests] == 0 {
+		t.Fatalf("flood answered %v, want some 429s", codes)
+	}
+	if codes[http.StatusForbidden] == 0 {
+		t.Fatalf("flood answered %v, want the peer banned", codes)
+	}
+	if !p.limiter.IsBanned("10.0.0.1") {
+		t.Fatal("flooding peer isn't banned")
+	}
+
+	// Another peer is unaffected
+	if code := postFrom(p, mux, "10.0.0.2", "/broadcast-tx", []byte("{}")); code == http.StatusTooManyRequests || code == http.StatusForbidden {
+		t.Fatalf("well-behaved peer answered %d", code)
+	}
+}
+
+func TestOversizedMessageIsRejected(t *testing.T) {
+	p := newTestServer(t)
+	mux := http.NewServeMux()
+	p.RegisterRoutes(mux)
+
+	body := append([]byte(`{"payload":"`), bytes.Repeat([]byte("a"), maxTransactionMessageBytes)...)
+	body = append(body, `"}`...)
+	if code := postFrom(p, mux, "10.0.0.1", "/broadcast-tx", body); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized transaction answered %d, want 413", code)
+	}
+	if n := p.PeerViolations()["10.0.0.1"]; n != 1 {
+		t.Fatalf("recorded %d violations, want 1", n)
+	}
+}
+
+func TestClaimedNodeIDDoesNotEscapeBan(t *testing.T) {
+	p := newTestServer(t)
+	mux := http.NewServeMux()
+	p.RegisterRoutes(mux)
+
+	// A peer that completed a handshake, whose node ID anyone can claim
+	known, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.peersMutex.Lock()
+	p.peerKeys[known.NodeID()] = known.PublicKey
+	p.peersMutex.Unlock()
+
+	for !p.limiter.IsBanned("10.0.0.1") {
+		postFrom(p, mux, "10.0.0.1", "/broadcast-tx", []byte("{}"))
+	}
+	if code := postAs(p, mux, "10.0.0.1", known.NodeID(), "/broadcast-tx", []byte("{}")); code != http.StatusForbidden {
+		t.Fatalf("banned peer claiming a known node ID answered %d, want 403", code)
+	}
+
+	// Nor can a flood under someone else's node ID get that node banned
+	for i := 0; i < peerRequestBurst+peerBanThreshold; i++ {
+		postAs(p, mux, "10.0.0.2", known.NodeID(), "/broadcast-tx", []byte("{}"))
+	}
+	if p.limiter.IsBanned(known.NodeID()) {
+		t.Fatal("node banned for requests made in its name")
+	}
+}
+
+func TestLimiterPrunesStaleViolationsAndBans(t *testing.T) {
+	l := newPeerLimiter(peerRequestsPerSecond, peerRequestBurst)
+	for i := 0; i < peerBanThreshold; i++ {
+		l.RecordViolation("banned")
+	}
+	l.RecordViolation("offender")
+
+	l.mutex.Lock()
+	l.pruneLocked(time.Now())
+	if len(l.violations) != 1 || len(l.bannedUntil) != 1 {
+		t.Errorf("pruned fresh entries: %d violation counts, %d bans", len(l.violations), len(l.bannedUntil))
+	}
+	l.pruneLocked(time.Now().Add(peerBanDuration + peerLimiterIdleTTL))
+	if len(l.violations) != 0 || len(l.bannedUntil) != 0 {
+		t.Errorf("kept %d violation counts and %d bans past their expiry", len(l.violations), len(l.bannedUntil))
+	}
+	l.mutex.Unlock()
+}
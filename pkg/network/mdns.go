@@ -0,0 +1,158 @@
+package network
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mdnsServiceType is the DNS-SD service type nodes advertise on the LAN
+const mdnsServiceType = "_simple-blockchain._tcp"
+
+// Timing for local peer discovery
+const (
+	mdnsQueryInterval = 10 * time.Second
+	mdnsQueryTimeout  = 2 * time.Second
+)
+
+// TXT record keys describing a node
+const (
+	mdnsKeyNodeID    = "node_id"
+	mdnsKeyAddress   = "address"
+	mdnsKeyNetworkID = "network_id"
+)
+
+// MDNSRecord is the service record a node advertises for local discovery
+type MDNSRecord struct {
+	NodeID    string
+	Address   string // Advertised P2P host:port
+	NetworkID string
+}
+
+// txt encodes the record as DNS-SD TXT fields
+func (r MDNSRecord) txt() []string {
+	return []string{
+		mdnsKeyNodeID + "=" + r.NodeID,
+		mdnsKeyAddress + "=" + r.Address,
+		mdnsKeyNetworkID + "=" + r.NetworkID,
+	}
+}
+
+// parseMDNSRecord decodes TXT fields, reporting false when a field is missing
+func parseMDNSRecord(fields []string) (MDNSRecord, bool) {
+	var r MDNSRecord
+	for _, field := range fields {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case mdnsKeyNodeID:
+			r.NodeID = value
+		case mdnsKeyAddress:
+			r.Address = value
+		case mdnsKeyNetworkID:
+			r.NetworkID = value
+		}
+	}
+	return r, r.NodeID != "" && r.Address != ""
+}
+
+// MDNSService is the multicast DNS layer used by discovery. The default
+// implementation requires building with -tags mdns.
+type MDNSService interface {
+	// Advertise publishes the record until Close is called
+	Advertise(record MDNSRecord) error
+
+	// Query browses for records for up to timeout
+	Query(timeout time.Duration) ([]MDNSRecord, error)
+
+	// Close withdraws the advertisement
+	Close() error
+}
+
+// MDNSDiscovery finds peers on the local network and registers with them
+type MDNSDiscovery struct {
+	server  *P2PServer
+	service MDNSService
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewMDNSDiscovery creates discovery for a P2P server over the given mDNS layer
+func NewMDNSDiscovery(server *P2PServer, service MDNSService) *MDNSDiscovery {
+	return &MDNSDiscovery{server: server, service: service}
+}
+
+// Start advertises this node and begins browsing for peers
+func (d *MDNSDiscovery) Start() error {
+	record := MDNSRecord{
+		NodeID:    d.server.NodeID(),
+		Address:   d.server.AdvertisedAddress(),
+		NetworkID: d.server.NetworkID(),
+	}
+	if err := d.service.Advertise(record); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	d.wg.Add(1)
+	go d.browse(ctx)
+	return nil
+}
+
+// Stop ends browsing, withdraws the advertisement, and waits for the browse loop to exit
+func (d *MDNSDiscovery) Stop() error {
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.wg.Wait()
+	return d.service.Close()
+}
+
+// browse queries for peers until the context is cancelled
+func (d *MDNSDiscovery) browse(ctx context.Context) {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(mdnsQueryInterval)
+	defer ticker.Stop()
+
+	for {
+		records, err := d.service.Query(mdnsQueryTimeout)
+		if err != nil {
+			log.Printf("mDNS query failed: %v\n", err)
+		}
+		for _, record := range records {
+			d.handleRecord(record)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handleRecord adds a discovered node as a peer, ignoring this node, nodes
+// on other networks, and peers we already know
+func (d *MDNSDiscovery) handleRecord(record MDNSRecord) bool {
+	p := d.server
+	if record.NodeID == p.NodeID() || record.Address == p.AdvertisedAddress() {
+		return false
+	}
+	if record.NetworkID != p.NetworkID() {
+		return false
+	}
+
+	p.peersMutex.Lock()
+	_, known := p.peers[record.Address]
+	p.peersMutex.Unlock()
+	if known {
+		return false
+	}
+
+	log.Printf("Discovered local peer %s via mDNS\n", record.Address)
+	p.spawn(func() { p.registerWithPeer(record.Address) })
+	return true
+}
@@ -0,0 +1,85 @@
+//go:build mdns
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/mdns"
+)
+
+// hashicorpMDNS implements MDNSService with github.com/hashicorp/mdns
+type hashicorpMDNS struct {
+	server *mdns.Server
+	mutex  sync.Mutex
+}
+
+// NewMDNSService returns the multicast DNS layer used for local discovery
+func NewMDNSService() (MDNSService, error) {
+	return &hashicorpMDNS{}, nil
+}
+
+// Advertise publishes the record under the node ID as instance name
+func (m *hashicorpMDNS) Advertise(record MDNSRecord) error {
+	_, portStr, err := net.SplitHostPort(record.Address)
+	if err != nil {
+		return fmt.Errorf("invalid advertised address: %w", err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return fmt.Errorf("invalid advertised port: %w", err)
+	}
+
+	service, err := mdns.NewMDNSService(record.NodeID, mdnsServiceType, "", "", port, nil, record.txt())
+	if err != nil {
+		return err
+	}
+	server, err := mdns.NewServer(&mdns.Config{Zone: service})
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	m.server = server
+	m.mutex.Unlock()
+	return nil
+}
+
+// Query collects the records that answer within the timeout
+func (m *hashicorpMDNS) Query(timeout time.Duration) ([]MDNSRecord, error) {
+	entries := make(chan *mdns.ServiceEntry, 16)
+	done := make(chan []MDNSRecord)
+	go func() {
+		var records []MDNSRecord
+		for entry := range entries {
+			if record, ok := parseMDNSRecord(entry.InfoFields); ok {
+				records = append(records, record)
+			}
+		}
+		done <- records
+	}()
+
+	params := mdns.DefaultParams(mdnsServiceType)
+	params.Entries = entries
+	params.Timeout = timeout
+	params.DisableIPv6 = true
+	err := mdns.Query(params)
+	close(entries)
+	return <-done, err
+}
+
+// Close shuts down the advertising responder
+func (m *hashicorpMDNS) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.server == nil {
+		return nil
+	}
+	err := m.server.Shutdown()
+	m.server = nil
+	return err
+}
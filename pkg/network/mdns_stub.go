@@ -0,0 +1,10 @@
+//go:build !mdns
+
+package network
+
+import "errors"
+
+// NewMDNSService is unavailable unless the binary is built with -tags mdns
+func NewMDNSService() (MDNSService, error) {
+	return nil, errors.New("mDNS discovery not compiled in; rebuild with -tags mdns")
+}
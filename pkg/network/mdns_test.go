@@ -0,0 +1,77 @@
+package network
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMDNS is an in-memory mDNS layer answering queries with fixed records
+type fakeMDNS struct {
+	mutex      sync.Mutex
+	records    []MDNSRecord
+	advertised []MDNSRecord
+	closed     bool
+}
+
+func (f *fakeMDNS) Advertise(record MDNSRecord) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.advertised = append(f.advertised, record)
+	return nil
+}
+
+func (f *fakeMDNS) Query(timeout time.Duration) ([]MDNSRecord, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]MDNSRecord{}, f.records...), nil
+}
+
+func (f *fakeMDNS) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.closed = true
+	return nil
+}
+
+func TestMDNSDiscoveryFiltersRecords(t *testing.T) {
+	p, local := newTestServer(t), newTestServer(t)
+	listen(t, p)
+	listen(t, local)
+
+	service := &fakeMDNS{records: []MDNSRecord{
+		{NodeID: p.NodeID(), Address: p.AdvertisedAddress(), NetworkID: p.NetworkID()},
+		{NodeID: "elsewhere", Address: "10.0.0.9:3000", NetworkID: "other"},
+		{NodeID: local.NodeID(), Address: local.AdvertisedAddress(), NetworkID: local.NetworkID()},
+	}}
+	discovery := NewMDNSDiscovery(p, service)
+	if err := discovery.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "the local peer", func() bool { return boundNodeID(p, local.address) == local.nodeID })
+	if err := discovery.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := p.PeerCount(); n != 1 {
+		t.Errorf("added %d peers, want only the one on our network", n)
+	}
+	if len(service.advertised) != 1 || service.advertised[0].NodeID != p.NodeID() || service.advertised[0].Address != p.AdvertisedAddress() {
+		t.Errorf("advertised %+v", service.advertised)
+	}
+	if !service.closed {
+		t.Error("Stop didn't withdraw the advertisement")
+	}
+}
+
+func TestMDNSRecordRoundTrip(t *testing.T) {
+	record := MDNSRecord{NodeID: "n", Address: "10.0.0.1:3000", NetworkID: "main"}
+	got, ok := parseMDNSRecord(record.txt())
+	if !ok || got != record {
+		t.Fatalf("parsed %+v, want %+v", got, record)
+	}
+	if _, ok := parseMDNSRecord([]string{"network_id=main"}); ok {
+		t.Fatal("parsed a record without a node ID or address")
+	}
+}
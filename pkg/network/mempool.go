@@ -0,0 +1,203 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// maxMempoolBatch bounds how many transactions are listed or sent in one
+// mempool exchange
+const maxMempoolBatch = 500
+
+// mempoolSummary lists the IDs of a peer's pending transactions
+type mempoolSummary struct {
+	IDs   []string `json:"ids"`
+	Total int      `json:"total"` // Size of the whole pool, which may exceed len(IDs)
+}
+
+// mempoolRequest asks a peer for the bodies of pending transactions
+type mempoolRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// pendingTransactions returns the pool's transactions oldest first, so
+// bounded batches are stable between requests
+func (p *P2PServer) pendingTransactions() []*blockchain.Transaction {
+	if p.txPool == nil {
+		return nil
+	}
+	txs := p.txPool.GetAllTransactions()
+	sort.Slice(txs, func(i, j int) bool {
+		if txs[i].Timestamp.Equal(txs[j].Timestamp) {
+			return txs[i].ID < txs[j].ID
+		}
+		return txs[i].Timestamp.Before(txs[j].Timestamp)
+	})
+	return txs
+}
+
+// handleMempool lists the IDs of up to limit pending transactions
+func (p *P2PServer) handleMempool(w http.ResponseWriter, r *http.Request) {
+	limit := maxMempoolBatch
+	if param := r.URL.Query().Get("limit"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if n < limit {
+			limit = n
+		}
+	}
+
+	txs := p.pendingTransactions()
+	summary := mempoolSummary{IDs: make([]string, 0, limit), Total: len(txs)}
+	for _, tx := range txs {
+		if len(summary.IDs) >= limit {
+			break
+		}
+		summary.IDs = append(summary.IDs, tx.ID)
+	}
+	json.NewEncoder(w).Encode(summary)
+}
+
+// handleMempoolTransactions returns the bodies of the requested pending
+// transactions, skipping IDs that are no longer pooled
+func (p *P2PServer) handleMempoolTransactions(w http.ResponseWriter, r *http.Request) {
+	var req mempoolRequest
+	if !p.decodeBody(w, r, &req) {
+		return
+	}
+	if len(req.IDs) > maxMempoolBatch {
+		http.Error(w, "Too many transaction IDs", http.StatusBadRequest)
+		return
+	}
+
+	txs := make([]*blockchain.Transaction, 0, len(req.IDs))
+	if p.txPool != nil {
+		for _, id := range req.IDs {
+			if tx, err := p.txPool.GetTransaction(id); err == nil {
+				txs = append(txs, tx)
+			}
+		}
+	}
+	json.NewEncoder(w).Encode(txs)
+}
+
+// syncMempool pulls the pending transactions of a peer that we don't have
+// yet, up to the pool's remaining capacity. Only unknown IDs are fetched.
+func (p *P2PServer) syncMempool(address string) error {
+	if p.txPool == nil {
+		return nil
+	}
+	capacity := p.txPool.Capacity()
+	if capacity <= 0 {
+		return nil
+	}
+	if capacity > maxMempoolBatch {
+		capacity = maxMempoolBatch
+	}
+
+	summary, err := p.fetchMempoolSummary(address, capacity)
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[string]bool)
+	missing := make([]string, 0, len(summary.IDs))
+	for _, id := range summary.IDs {
+		if id == "" || wanted[id] {
+			continue
+		}
+		if _, err := p.txPool.GetTransaction(id); err == nil {
+			continue
+		}
+		wanted[id] = true
+		missing = append(missing, id)
+		if len(missing) >= capacity {
+			break
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	txs, err := p.fetchMempoolTransactions(address, missing)
+	if err != nil {
+		return err
+	}
+
+	added := 0
+	for _, tx := range txs {
+		// Ignore anything we didn't ask for
+		if tx == nil || !wanted[tx.ID] {
+			p.reportInvalid()
+			continue
+		}
+		delete(wanted, tx.ID)
+		if err := p.txPool.AddTransaction(tx); err != nil {
+			if p.txPool.Capacity() <= 0 {
+				break
+			}
+			continue
+		}
+		added++
+	}
+	if added > 0 {
+		log.Printf("Pulled %d pending transactions from %s\n", added, address)
+	}
+	return nil
+}
+
+// fetchMempoolSummary asks a peer for the IDs of up to limit pending transactions
+func (p *P2PServer) fetchMempoolSummary(address string, limit int) (mempoolSummary, error) {
+	resp, err := p.getPeer(p.httpClient, p.peerURL(address, fmt.Sprintf("/mempool?limit=%d", limit)))
+	if err != nil {
+		return mempoolSummary{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return mempoolSummary{}, fmt.Errorf("peer %s returned %s", address, resp.Status)
+	}
+	var summary mempoolSummary
+	if err := json.NewDecoder(resp.Body).Decode(&summary); err != nil {
+		return mempoolSummary{}, err
+	}
+	if len(summary.IDs) > limit {
+		summary.IDs = summary.IDs[:limit]
+	}
+	return summary, nil
+}
+
+// fetchMempoolTransactions asks a peer for the bodies of the given transactions
+func (p *P2PServer) fetchMempoolTransactions(address string, ids []string) ([]*blockchain.Transaction, error) {
+	body, _ := json.Marshal(mempoolRequest{IDs: ids})
+	req, err := p.newPeerRequest(http.MethodPost, p.peerURL(address, "/mempool/transactions"), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.doPeer(p.httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("peer %s returned %s", address, resp.Status)
+	}
+	var txs []*blockchain.Transaction
+	if err := json.NewDecoder(resp.Body).Decode(&txs); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
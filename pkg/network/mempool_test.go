@@ -0,0 +1,106 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// mempoolPeer serves p's P2P routes, recording the transaction IDs asked for
+func mempoolPeer(t *testing.T, p *P2PServer) (string, func() []string) {
+	t.Helper()
+	mux := http.NewServeMux()
+	p.RegisterRoutes(mux)
+	var mutex sync.Mutex
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/mempool/transactions" {
+			body, _ := io.ReadAll(r.Body)
+			var req mempoolRequest
+			json.Unmarshal(body, &req)
+			mutex.Lock()
+			requested = append(requested, req.IDs...)
+			mutex.Unlock()
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://"), func() []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]string{}, requested...)
+	}
+}
+
+// pooledServer is a test server with a pool holding the given transactions
+func pooledServer(t *testing.T, capacity int, txs ...blockchain.Transaction) *P2PServer {
+	t.Helper()
+	p := newTestServer(t)
+	pool := blockchain.NewTransactionPool(capacity)
+	for i := range txs {
+		if err := pool.AddTransaction(&txs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.SetTransactionPool(pool)
+	return p
+}
+
+func TestMempoolSyncFetchesOnlyUnknownTransactions(t *testing.T) {
+	var txs []blockchain.Transaction
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		txs = append(txs, blockchain.Transaction{ID: fmt.Sprintf("tx-%d", i), To: "bob", Timestamp: start.Add(time.Duration(i) * time.Second)})
+	}
+	a := pooledServer(t, 10, txs...)
+	b := pooledServer(t, 10, txs[0], txs[1])
+	address, requested := mempoolPeer(t, a)
+
+	if err := b.syncMempool(address); err != nil {
+		t.Fatal(err)
+	}
+	if n := b.txPool.Count(); n != 5 {
+		t.Fatalf("pool holds %d transactions, want 5", n)
+	}
+	if got := requested(); len(got) != 3 || got[0] != "tx-2" {
+		t.Fatalf("asked for %v, want only the three unknown transactions", got)
+	}
+
+	// A second exchange transfers nothing
+	if err := b.syncMempool(address); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(requested()); n != 3 {
+		t.Fatalf("asked for %d transactions in total, want no more after catching up", n)
+	}
+}
+
+func TestMempoolSyncRespectsCapacity(t *testing.T) {
+	var txs []blockchain.Transaction
+	for i := 0; i < 5; i++ {
+		txs = append(txs, blockchain.Transaction{ID: fmt.Sprintf("tx-%d", i), To: "bob", Timestamp: time.Now()})
+	}
+	a := pooledServer(t, 10, txs...)
+	b := pooledServer(t, 2)
+	address, requested := mempoolPeer(t, a)
+
+	if err := b.syncMempool(address); err != nil {
+		t.Fatal(err)
+	}
+	if n := b.txPool.Count(); n != 2 {
+		t.Fatalf("pool holds %d transactions, want its capacity of 2", n)
+	}
+	if n := len(requested()); n != 2 {
+		t.Fatalf("asked for %d transactions, want no more than fit", n)
+	}
+}
@@ -0,0 +1,122 @@
+package network
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// MetricsSink receives P2P events for monitoring. *metrics.BlockchainMetrics
+// implements it.
+type MetricsSink interface {
+	UpdatePeerCount(count int)
+	PeerMessageSent(msgType string)
+	PeerMessageReceived(msgType string)
+	InvalidPeerMessage()
+	PeerBytesSent(n int64)
+	PeerBytesReceived(n int64)
+	RecordSync(duration time.Duration)
+	RecordTransferBytes(raw, compressed int64)
+	PeerDeliveryRatio(peer string, ratio float64)
+	PeerRemoved(peer string)
+}
+
+// SetMetrics attaches the sink that receives peer, gossip, and sync metrics
+func (p *P2PServer) SetMetrics(m MetricsSink) {
+	p.metrics = m
+}
+
+// reportPeerCountLocked publishes the peer count; peersMutex must be held
+func (p *P2PServer) reportPeerCountLocked() {
+	if p.metrics != nil {
+		p.metrics.UpdatePeerCount(len(p.peers))
+	}
+}
+
+// reportSent records a gossip message delivered to a peer
+func (p *P2PServer) reportSent(msgType string) {
+	if p.metrics != nil {
+		p.metrics.PeerMessageSent(msgType)
+	}
+}
+
+// reportReceived records a gossip message received from a peer
+func (p *P2PServer) reportReceived(msgType string) {
+	if p.metrics != nil {
+		p.metrics.PeerMessageReceived(msgType)
+	}
+}
+
+// reportInvalid records a rejected peer message
+func (p *P2PServer) reportInvalid() {
+	if p.metrics != nil {
+		p.metrics.InvalidPeerMessage()
+	}
+}
+
+// reportBytesSent records bytes written to a peer
+func (p *P2PServer) reportBytesSent(n int64) {
+	if p.metrics != nil && n > 0 {
+		p.metrics.PeerBytesSent(n)
+	}
+}
+
+// reportBytesReceived records bytes read from a peer
+func (p *P2PServer) reportBytesReceived(n int64) {
+	if p.metrics != nil && n > 0 {
+		p.metrics.PeerBytesReceived(n)
+	}
+}
+
+// reportSync records how long a sync took
+func (p *P2PServer) reportSync(duration time.Duration) {
+	if p.metrics != nil {
+		p.metrics.RecordSync(duration)
+	}
+}
+
+// meteredBody counts the bytes read from a body and reports them on Close
+type meteredBody struct {
+	io.ReadCloser
+	n      int64
+	report func(int64)
+}
+
+func (m *meteredBody) Read(b []byte) (int, error) {
+	n, err := m.ReadCloser.Read(b)
+	m.n += int64(n)
+	return n, err
+}
+
+func (m *meteredBody) Close() error {
+	m.report(m.n)
+	m.n = 0
+	return m.ReadCloser.Close()
+}
+
+// meteredResponseWriter counts the bytes written to a response
+type meteredResponseWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (m *meteredResponseWriter) Write(b []byte) (int, error) {
+	n, err := m.ResponseWriter.Write(b)
+	m.n += int64(n)
+	return n, err
+}
+
+// metered counts a handler's request and response body bytes. It must not
+// wrap handlers that hijack the connection.
+func (p *P2PServer) metered(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body := &meteredBody{ReadCloser: r.Body, report: p.reportBytesReceived}
+		r.Body = body
+		mw := &meteredResponseWriter{ResponseWriter: w}
+
+		handler(mw, r)
+
+		body.Close()
+		p.reportBytesSent(mw.n)
+	}
+}
@@ -0,0 +1,90 @@
+package network
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMetrics records the events a P2P server reports
+type fakeMetrics struct {
+	mutex  sync.Mutex
+	events map[string]int
+	peers  int
+}
+
+func newFakeMetrics() *fakeMetrics {
+	return &fakeMetrics{events: make(map[string]int)}
+}
+
+func (m *fakeMetrics) record(event string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.events[event]++
+}
+
+func (m *fakeMetrics) count(event string) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.events[event]
+}
+
+func (m *fakeMetrics) UpdatePeerCount(count int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.peers = count
+}
+
+func (m *fakeMetrics) PeerMessageSent(msgType string)               { m.record("sent " + msgType) }
+func (m *fakeMetrics) PeerMessageReceived(msgType string)           { m.record("received " + msgType) }
+func (m *fakeMetrics) InvalidPeerMessage()                          { m.record("invalid") }
+func (m *fakeMetrics) PeerBytesSent(n int64)                        { m.record("bytes out") }
+func (m *fakeMetrics) PeerBytesReceived(n int64)                    { m.record("bytes in") }
+func (m *fakeMetrics) RecordSync(duration time.Duration)            { m.record("sync") }
+func (m *fakeMetrics) RecordTransferBytes(raw, compressed int64)    { m.record("transfer") }
+func (m *fakeMetrics) PeerDeliveryRatio(peer string, ratio float64) {}
+func (m *fakeMetrics) PeerRemoved(peer string)                      { m.record("removed") }
+
+func TestPeerExchangeReportsMetrics(t *testing.T) {
+	a, b := newTestServer(t), newTestServer(t)
+	am, bm := newFakeMetrics(), newFakeMetrics()
+	a.SetMetrics(am)
+	b.SetMetrics(bm)
+	listen(t, a)
+	listen(t, b)
+
+	if err := b.ConnectPeer(a.address); err != nil {
+		t.Fatal(err)
+	}
+	bm.mutex.Lock()
+	peers := bm.peers
+	bm.mutex.Unlock()
+	if peers != 1 {
+		t.Fatalf("reported %d peers, want 1", peers)
+	}
+
+	// b catches up with a, then receives a's next block by gossip
+	growChain(t, a.chain, 3)
+	b.syncWithPeers()
+	if bm.count("sync") != 1 || bm.count("bytes in") == 0 {
+		t.Fatalf("sync reported %v", bm.events)
+	}
+
+	block := mine(t, a.chain.GetLatestBlock(), 0)
+	if err := a.chain.AddExistingBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	a.BroadcastBlock(block)
+	waitFor(t, "the gossiped block", func() bool { return b.chain.GetLatestBlock().Hash == block.Hash })
+	waitFor(t, "gossip metrics", func() bool {
+		return am.count("bytes out") > 0 && bm.count("received "+gossipBlockAnnounce)+bm.count("received "+gossipBlock) > 0
+	})
+
+	if code := postGossipMessage(b, GossipMessage{ID: newMessageID(), Type: "bogus", Hops: 1}, ""); code != http.StatusBadRequest {
+		t.Fatalf("bogus gossip answered %d", code)
+	}
+	if bm.count("invalid") != 1 {
+		t.Fatalf("reported %d invalid messages, want 1", bm.count("invalid"))
+	}
+}
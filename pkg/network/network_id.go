@@ -0,0 +1,86 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// networkIDHeader carries the sender's network ID on every P2P request
+const networkIDHeader = "X-Network-ID"
+
+// errNetworkMismatch is returned when a peer belongs to a different network
+var errNetworkMismatch = errors.New("peer is on a different network")
+
+// networkMismatchResponse is the 409 body sent to peers from other networks
+type networkMismatchResponse struct {
+	Error     string `json:"error"`
+	NetworkID string `json:"networkId"`
+}
+
+// sameNetwork rejects requests whose network ID differs from ours with 409
+// before the handler does any work
+func (p *P2PServer) sameNetwork(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(networkIDHeader) == p.networkID {
+			handler(w, r)
+			return
+		}
+
+		p.reportInvalid()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(networkIDHeader, p.networkID)
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(networkMismatchResponse{
+			Error:     errNetworkMismatch.Error(),
+			NetworkID: p.networkID,
+		})
+	}
+}
+
+// peerHeaders identifies this node and its network on an outbound request
+func (p *P2PServer) peerHeaders() http.Header {
+	h := make(http.Header)
+	h.Set(nodeIDHeader, p.nodeID)
+	h.Set(nodeAddressHeader, p.address)
+	h.Set(networkIDHeader, p.networkID)
+	return h
+}
+
+// newPeerRequest creates a request to a peer carrying our identifying
+// headers. It is cancelled when the server stops.
+func (p *P2PServer) newPeerRequest(method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(p.ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range p.peerHeaders() {
+		req.Header[key] = values
+	}
+	return req, nil
+}
+
+// doPeer sends a request to a peer. A peer that rejects our network ID is
+// dropped from the peer list and errNetworkMismatch is returned; a peer that
+// times out has the failure counted against it and errPeerTimeout is returned.
+func (p *P2PServer) doPeer(client *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		if isTimeout(err) && p.ctx.Err() == nil {
+			p.recordAttemptFailure(req.URL.Host)
+			return nil, fmt.Errorf("%w: %s", errPeerTimeout, req.URL.Host)
+		}
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusConflict {
+		if theirs := resp.Header.Get(networkIDHeader); theirs != "" && theirs != p.networkID {
+			resp.Body.Close()
+			p.removePeer(req.URL.Host)
+			return nil, fmt.Errorf("%w: %s is on %q", errNetworkMismatch, req.URL.Host, theirs)
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,73 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOtherNetworkIsRejectedByEveryHandler(t *testing.T) {
+	p := newTestServer(t)
+	mux := http.NewServeMux()
+	p.RegisterRoutes(mux)
+
+	block := mine(t, p.chain.GetLatestBlock(), 0)
+	gossip, err := newTestServer(t).newGossip(gossipBlock, block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gossipBody, _ := json.Marshal(gossip)
+	handshakeBody, _ := json.Marshal(newTestServer(t).localHandshake())
+
+	requests := []struct {
+		method, path string
+		body         []byte
+	}{
+		{http.MethodGet, "/peers", nil},
+		{http.MethodPost, "/register-peer", handshakeBody},
+		{http.MethodPost, "/identify", handshakeBody},
+		{http.MethodGet, "/sync", nil},
+		{http.MethodGet, "/height", nil},
+		{http.MethodGet, "/block/" + block.Hash, nil},
+		{http.MethodGet, "/headers", nil},
+		{http.MethodGet, "/snapshot", nil},
+		{http.MethodGet, "/mempool", nil},
+		{http.MethodPost, "/mempool/transactions", []byte("[]")},
+		{http.MethodGet, "/p2p", nil},
+		{http.MethodPost, "/broadcast-block", gossipBody},
+		{http.MethodPost, "/broadcast-tx", gossipBody},
+	}
+	for _, r := range requests {
+		req := httptest.NewRequest(r.method, r.path, bytes.NewReader(r.body))
+		req.Header.Set(networkIDHeader, "other")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		var resp networkMismatchResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		if w.Code != http.StatusConflict || resp.NetworkID != p.networkID || w.Header().Get(networkIDHeader) != p.networkID {
+			t.Errorf("%s %s answered %d %+v, want 409 naming our network", r.method, r.path, w.Code, resp)
+		}
+	}
+
+	if p.chain.Height() != 0 || p.PeerCount() != 0 {
+		t.Fatalf("rejected requests left height %d and %d peers", p.chain.Height(), p.PeerCount())
+	}
+}
+
+func TestPeerOnOtherNetworkIsDropped(t *testing.T) {
+	p, other := newTestServer(t), newTestServer(t)
+	other.SetNetworkID("other")
+	listen(t, other)
+	p.AddPeer(other.address)
+
+	if err := p.learnPeersFrom(other.address); !errors.Is(err, errNetworkMismatch) {
+		t.Fatalf("got %v, want errNetworkMismatch", err)
+	}
+	if n := p.PeerCount(); n != 0 {
+		t.Fatalf("kept %d peers, want the other network's peer dropped", n)
+	}
+}
@@ -0,0 +1,44 @@
+// Package networktest provides stub peers for tests of the P2P network and
+// of the nodes built on it
+package networktest
+
+import (
+	"net"
+	"sync"
+	"testing"
+)
+
+// HangingPeer listens on a free local port, accepting connections and never
+// answering them, and returns its address. The listener and the connections
+// it accepted are closed when the test ends.
+func HangingPeer(t testing.TB) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mutex sync.Mutex
+	var conns []net.Conn
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mutex.Lock()
+			conns = append(conns, conn)
+			mutex.Unlock()
+		}
+	}()
+	t.Cleanup(func() {
+		listener.Close()
+		<-done
+		for _, conn := range conns {
+			conn.Close()
+		}
+	})
+	return listener.Addr().String()
+}
@@ -2,21 +2,110 @@ package network
 
 import (
 	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
+	"math/big"
+	"net"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/consensus"
+	"github.com/anekazek/simple-blockchain/pkg/version"
+	"github.com/gorilla/websocket"
 )
 
 // Peer represents a node in the P2P network
 type Peer struct {
 	Address  string
 	LastSeen time.Time
+	Seed     bool   // Seeds are configured bootstrap peers and are never evicted
+	NodeID   string // Learned during the handshake
+	Gzip     bool   // Peer accepts gzip-encoded request bodies
+	Announce bool   // Peer accepts block announcements instead of full blocks
+	Height   int    // Latest block index the peer reported
+	Scheme   string // "http" or "https", recorded at the handshake
+	Role     string // RoleFull or RoleLight, recorded at the handshake
+	Version  string // Build version, recorded at the handshake
+	Protocol int    // P2P protocol version, recorded at the handshake
+
+	// Rolling response time estimate, see recordLatency
+	Latency        time.Duration
+	LatencyUpdated time.Time
+
+	// Broadcast delivery outcomes, used for liveness
+	Delivered           int
+	Dropped             int
+	ConsecutiveFailures int
+}
+
+// errSelfPeer is returned when a peer address turns out to be this node
+var errSelfPeer = errors.New("peer is this node")
+
+// defaultAdvertisedAddress combines the outbound interface IP with the listen port
+func defaultAdvertisedAddress(port string) string {
+	host := "127.0.0.1"
+	// No packets are sent; dialing UDP just selects the outbound interface
+	if conn, err := net.Dial("udp", "8.8.8.8:80"); err == nil {
+		host = conn.LocalAddr().(*net.UDPAddr).IP.String()
+		conn.Close()
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// Retry bounds for contacting seed peers
+const (
+	seedRetryInitial = 1 * time.Second
+	seedRetryMax     = 5 * time.Minute
+)
+
+// DefaultNetworkID names the network a node joins unless configured otherwise
+const DefaultNetworkID = blockchain.DefaultNetworkID
+
+// nodeIDHeader identifies the sending node on P2P requests
+const nodeIDHeader = "X-Node-ID"
+
+// maxSyncBatch caps the number of blocks returned by a single range sync request
+const maxSyncBatch = 128
+
+// errChainDiverged is returned by range sync when the peer's blocks don't extend our chain
+var errChainDiverged = errors.New("peer chain does not connect to local chain")
+
+// heightResponse is the payload of the /height endpoint
+type heightResponse struct {
+	Height     int    `json:"height"`
+	LatestHash string `json:"latestHash"`
+	// Work is the decimal total work of a full node's chain. Older nodes
+	// and light nodes leave it out.
+	Work string `json:"work,omitempty"`
+}
+
+// work returns the total work the response reports, or nil if it has none
+func (h heightResponse) work() *big.Int {
+	work, ok := new(big.Int).SetString(h.Work, 10)
+	if !ok {
+		return nil
+	}
+	return work
+}
+
+// beats reports whether the chain described by h is better than other's:
+// it has more total work, or is taller when either doesn't report its work
+func (h heightResponse) beats(other heightResponse) bool {
+	work, otherWork := h.work(), other.work()
+	if work == nil || otherWork == nil {
+		return h.Height > other.Height
+	}
+	return work.Cmp(otherWork) > 0
 }
 
 // P2PServer manages peer-to-peer communication between blockchain nodes
@@ -25,168 +114,742 @@ type P2PServer struct {
 	peers       map[string]Peer
 	peersMutex  *sync.Mutex
 	port        string
-	knownBlocks map[string]bool // Track blocks we've already seen by hash
+	knownBlocks *hashCache // Recently seen block hashes
+	metrics     MetricsSink
+	seeds       []string
+	identity    *Identity
+	nodeID      string // Derived from the identity key
+	address     string // Advertised host:port that peers dial to reach us
+	networkID   string // Nodes only peer with others on the same network
+	seenMsgs    *hashCache
+	txPool      *blockchain.TransactionPool
+	orphans     *orphanSet
+	consensus   consensus.Algorithm // Rules blocks from peers must satisfy
+
+	role            string                  // RoleFull or RoleLight
+	headers         *blockchain.HeaderChain // Tracked instead of blocks in light mode
+	headerSyncMutex sync.Mutex
+	fetches         *blockFetches // Announced blocks being downloaded
+
+	snapshots  *snapshotCache // Served to fast syncing peers when set
+	syncPaused atomic.Bool
+
+	authenticated bool                         // Require signed gossip from known origins
+	peerKeys      map[string]ed25519.PublicKey // Public keys learned during handshakes, by node ID
+
+	wsEnabled     bool                    // Use persistent WebSocket sessions with capable peers
+	sessions      map[string]*peerSession // Live sessions by peer address
+	dialing       map[string]bool         // Peers with an outbound session loop running
+	sessionsMutex sync.Mutex
+	upgrader      websocket.Upgrader
+
+	httpClient   *http.Client // Shared by outbound peer requests
+	clientConfig ClientConfig
+	clientTLS    *tls.Config // Verifies peer certificates
+	serverTLS    *tls.Config // Serves P2P routes over HTTPS when set
+
+	syncTracker *syncTracker
+	limiter     *peerLimiter
+	transfers   transferCounters
+
+	maxPeers int // Cap on stored peers; seeds are always kept
+
+	outboxes    map[string]*outbox // Pending broadcast deliveries by peer address
+	outboxMutex sync.Mutex
+	retryAfter  func(time.Duration) <-chan time.Time // Times delivery backoffs, replaced in tests
+
+	intervals      IntervalConfig
+	ctx            context.Context // Cancelled by Stop
+	cancel         context.CancelFunc
+	wg             sync.WaitGroup // Background loops and in-flight broadcasts
+	lifecycleMutex sync.Mutex
+	httpServer     *http.Server
+}
+
+// handshake is exchanged on peer registration so each side learns the
+// other's address and identity key
+type handshake struct {
+	Address   string `json:"address"`
+	NodeID    string `json:"nodeId,omitempty"`
+	PublicKey string `json:"publicKey,omitempty"`
+	Role      string `json:"role,omitempty"`
+
+	Version         string `json:"version,omitempty"`
+	ProtocolVersion int    `json:"protocolVersion,omitempty"`
+
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// hasCapability reports whether the handshake advertises a capability
+func (h handshake) hasCapability(capability string) bool {
+	for _, c := range h.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
 }
 
-// NewP2PServer creates a new P2P server for the given blockchain
-func NewP2PServer(chain *blockchain.Chain, port string) *P2PServer {
-	return &P2PServer{
-		chain:       chain,
-		peers:       make(map[string]Peer),
-		peersMutex:  &sync.Mutex{},
-		port:        port,
-		knownBlocks: make(map[string]bool),
+// NewP2PServer creates a new P2P server for the given blockchain whose
+// background loops run at the given intervals
+func NewP2PServer(chain *blockchain.Chain, port string, intervals IntervalConfig) *P2PServer {
+	identity, err := GenerateIdentity()
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &P2PServer{
+		chain:        chain,
+		peers:        make(map[string]Peer),
+		peersMutex:   &sync.Mutex{},
+		port:         port,
+		knownBlocks:  newHashCache(defaultKnownBlocksSize),
+		identity:     identity,
+		nodeID:       identity.NodeID(),
+		address:      defaultAdvertisedAddress(port),
+		networkID:    DefaultNetworkID,
+		seenMsgs:     newHashCache(defaultSeenMessagesSize),
+		orphans:      newOrphanSet(),
+		fetches:      newBlockFetches(),
+		peerKeys:     make(map[string]ed25519.PublicKey),
+		sessions:     make(map[string]*peerSession),
+		dialing:      make(map[string]bool),
+		syncTracker:  newSyncTracker(),
+		limiter:      newPeerLimiter(peerRequestsPerSecond, peerRequestBurst),
+		upgrader:     websocket.Upgrader{EnableCompression: true},
+		outboxes:     make(map[string]*outbox),
+		retryAfter:   time.After,
+		maxPeers:     DefaultMaxPeers,
+		clientConfig: DefaultClientConfig(),
+		intervals:    intervals,
+		ctx:          ctx,
+		cancel:       cancel,
+		role:         RoleFull,
 	}
+	p.buildClient()
+	return p
 }
 
 // RegisterRoutes adds P2P endpoints to the HTTP server
 func (p *P2PServer) RegisterRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("/peers", p.handlePeers)
-	mux.HandleFunc("/register-peer", p.handleRegisterPeer)
-	mux.HandleFunc("/sync", p.handleSync)
-	mux.HandleFunc("/broadcast-block", p.handleBroadcastBlock)
+	mux.HandleFunc("/peers", p.metered(p.sameNetwork(p.handlePeers)))
+	mux.HandleFunc("/register-peer", p.metered(p.sameNetwork(p.limited(maxHandshakeBytes, p.handleRegisterPeer))))
+	mux.HandleFunc("POST /identify", p.metered(p.sameNetwork(p.limited(maxHandshakeBytes, p.handleIdentify))))
+	mux.HandleFunc("/sync", p.metered(p.sameNetwork(p.fullOnly(p.compressed(p.handleSync)))))
+	mux.HandleFunc("/height", p.metered(p.sameNetwork(p.handleHeight)))
+	mux.HandleFunc("GET /block/{hash}", p.metered(p.sameNetwork(p.fullOnly(p.compressed(p.handleGetBlock)))))
+	mux.HandleFunc("GET /headers", p.metered(p.sameNetwork(p.compressed(p.handleHeaders))))
+	mux.HandleFunc("GET /snapshot", p.metered(p.sameNetwork(p.fullOnly(p.handleSnapshot))))
+	mux.HandleFunc("GET /snapshot/chunks/{hash}", p.metered(p.sameNetwork(p.fullOnly(p.compressed(p.handleSnapshotChunk)))))
+	mux.HandleFunc("GET /proof/{id}", p.metered(p.sameNetwork(p.fullOnly(p.handleProof))))
+	mux.HandleFunc("GET /mempool", p.metered(p.sameNetwork(p.fullOnly(p.compressed(p.handleMempool)))))
+	mux.HandleFunc("POST /mempool/transactions", p.metered(p.sameNetwork(p.fullOnly(p.limited(maxTransactionMessageBytes, p.compressed(p.handleMempoolTransactions))))))
+	mux.HandleFunc("/p2p", p.sameNetwork(p.handlePeerSocket))
+	mux.HandleFunc("/broadcast-block", p.metered(p.sameNetwork(p.limited(maxBlockMessageBytes, p.handleGossip))))
+	mux.HandleFunc("/broadcast-tx", p.metered(p.sameNetwork(p.limited(maxTransactionMessageBytes, p.handleGossip))))
+}
+
+// SetTransactionPool attaches the pool that receives gossiped transactions
+func (p *P2PServer) SetTransactionPool(txPool *blockchain.TransactionPool) {
+	p.txPool = txPool
+}
+
+// SetAdvertisedAddress overrides the host:port announced to peers, e.g. a
+// public address when running behind NAT
+func (p *P2PServer) SetAdvertisedAddress(address string) {
+	if normalized, err := normalizeAddress(address); err == nil {
+		address = normalized
+	}
+	p.address = address
+}
+
+// AdvertisedAddress returns the host:port announced to peers
+func (p *P2PServer) AdvertisedAddress() string {
+	return p.address
+}
+
+// SetNetworkID sets the network this node belongs to
+func (p *P2PServer) SetNetworkID(networkID string) {
+	p.networkID = networkID
+}
+
+// NetworkID returns the network this node belongs to
+func (p *P2PServer) NetworkID() string {
+	return p.networkID
+}
+
+// SetIdentity replaces the generated identity with the node's identity key
+func (p *P2PServer) SetIdentity(identity *Identity) {
+	p.identity = identity
+	p.nodeID = identity.NodeID()
+}
+
+// SetAuthenticated enables or disables authenticated mode, in which gossip
+// must be signed by an origin whose key was learned during a handshake.
+// Unauthenticated mode is intended for local development.
+func (p *P2PServer) SetAuthenticated(enabled bool) {
+	p.authenticated = enabled
+}
+
+// NodeID returns this node's identifier on the network
+func (p *P2PServer) NodeID() string {
+	return p.nodeID
+}
+
+// ListenAndServe serves the P2P endpoints on the server's port
+func (p *P2PServer) ListenAndServe() error {
+	mux := http.NewServeMux()
+	p.RegisterRoutes(mux)
+
+	server := &http.Server{
+		Addr:              ":" + p.port,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+
+	p.lifecycleMutex.Lock()
+	if p.ctx.Err() != nil {
+		p.lifecycleMutex.Unlock()
+		return http.ErrServerClosed
+	}
+	p.httpServer = server
+	p.lifecycleMutex.Unlock()
+
+	if p.serverTLS != nil {
+		server.TLSConfig = p.serverTLS
+		log.Printf("P2P server listening with TLS on port %s\n", p.port)
+		return server.ListenAndServeTLS("", "")
+	}
+	log.Printf("P2P server listening on port %s\n", p.port)
+	return server.ListenAndServe()
 }
 
 // Start begins the P2P server operations
 func (p *P2PServer) Start() {
 	// Start periodic peer discovery and chain synchronization
-	go p.discoverPeers()
-	go p.syncBlockchain()
+	p.spawn(p.discoverPeers)
+	p.spawn(p.syncBlockchain)
+
+	for _, seed := range p.seeds {
+		seed := seed
+		p.spawn(func() { p.bootstrapFromSeed(seed) })
+	}
+}
+
+// AddSeed registers a bootstrap peer. Seeds are contacted when the server
+// starts and retried with exponential backoff until they respond.
+func (p *P2PServer) AddSeed(address string) {
+	if normalized, err := normalizeAddress(address); err == nil {
+		address = normalized
+	}
+
+	p.peersMutex.Lock()
+	p.seeds = append(p.seeds, address)
+	p.peers[address] = Peer{
+		Address:  address,
+		LastSeen: time.Now(),
+		Seed:     true,
+	}
+	p.reportPeerCountLocked()
+	p.peersMutex.Unlock()
+
+	log.Printf("Added seed peer: %s\n", address)
+}
+
+// bootstrapFromSeed registers with a seed and learns its peers, retrying with
+// exponential backoff while the seed is unreachable or until the server stops
+func (p *P2PServer) bootstrapFromSeed(seed string) {
+	delay := seedRetryInitial
+	for {
+		err := p.registerWithPeer(seed)
+		if err == nil {
+			err = p.learnPeersFrom(seed)
+		}
+		if err == nil {
+			log.Printf("Bootstrapped from seed %s\n", seed)
+			return
+		}
+
+		log.Printf("Seed %s unreachable, retrying in %s: %v\n", seed, delay, err)
+		if !p.sleep(delay) {
+			return
+		}
+		delay *= 2
+		if delay > seedRetryMax {
+			delay = seedRetryMax
+		}
+	}
+}
+
+// ConnectPeer handshakes with a peer and stores it, reporting why it
+// couldn't
+func (p *P2PServer) ConnectPeer(address string) error {
+	return p.registerWithPeer(address)
 }
 
 // AddPeer adds a new peer to the network
 func (p *P2PServer) AddPeer(address string) {
+	if _, err := p.addPeer(address); err != nil {
+		log.Printf("Not adding peer %s: %v\n", address, err)
+	}
+}
+
+// KnownBlocksCount returns the number of block hashes in the deduplication cache
+func (p *P2PServer) KnownBlocksCount() int {
+	return p.knownBlocks.Len()
+}
+
+// peerKey returns the public key learned for a node ID
+func (p *P2PServer) peerKey(nodeID string) (ed25519.PublicKey, bool) {
 	p.peersMutex.Lock()
 	defer p.peersMutex.Unlock()
+	key, ok := p.peerKeys[nodeID]
+	return key, ok
+}
 
-	p.peers[address] = Peer{
-		Address:  address,
-		LastSeen: time.Now(),
+// localHandshake describes this node to a peer
+func (p *P2PServer) localHandshake() handshake {
+	h := handshake{
+		Address:   p.address,
+		NodeID:    p.nodeID,
+		PublicKey: hex.EncodeToString(p.identity.PublicKey),
+		Role:      p.role,
+
+		Version:         version.Version,
+		ProtocolVersion: version.ProtocolVersion,
+
+		Capabilities: []string{capabilityGzip, capabilityAnnounce},
+	}
+	if p.wsEnabled {
+		h.Capabilities = append(h.Capabilities, capabilityWebSocket)
+	}
+	if p.serverTLS != nil {
+		h.Capabilities = append(h.Capabilities, capabilityTLS)
+	}
+	return h
+}
+
+// learnIdentity records a peer's capabilities, and its public key if it
+// matches the claimed node ID. An address already bound to another node ID
+// isn't rebound.
+func (p *P2PServer) learnIdentity(h handshake) error {
+	if address, err := normalizeAddress(h.Address); err == nil {
+		h.Address = address
+	}
+	key, err := parseIdentity(h)
+	if err != nil {
+		return err
 	}
-	log.Printf("Added peer: %s\n", address)
+
+	p.peersMutex.Lock()
+	defer p.peersMutex.Unlock()
+	peer, ok := p.peers[h.Address]
+	if key != nil && ok && peer.NodeID != "" && peer.NodeID != h.NodeID {
+		return fmt.Errorf("%w: %s is node %s", errIdentityConflict, h.Address, peer.NodeID)
+	}
+	if ok {
+		peer.Gzip = h.hasCapability(capabilityGzip)
+		peer.Announce = h.hasCapability(capabilityAnnounce)
+		peer.Role = RoleFull
+		if h.Role == RoleLight {
+			peer.Role = RoleLight
+		}
+		peer.Version, peer.Protocol = h.Version, h.ProtocolVersion
+		peer.Scheme = schemeHTTP
+		if h.hasCapability(capabilityTLS) {
+			peer.Scheme = schemeHTTPS
+		}
+	}
+	if key == nil {
+		if ok {
+			p.peers[h.Address] = peer
+		}
+		return nil
+	}
+
+	p.peerKeys[h.NodeID] = key
+	if ok {
+		peer.NodeID = h.NodeID
+		p.peers[h.Address] = peer
+	}
+	return nil
+}
+
+// removePeer forgets a non-seed peer
+func (p *P2PServer) removePeer(address string) {
+	p.peersMutex.Lock()
+	defer p.peersMutex.Unlock()
+
+	if p.peers[address].Seed {
+		return
+	}
+	delete(p.peers, address)
+	p.reportPeerCountLocked()
+	if p.metrics != nil {
+		p.metrics.PeerRemoved(address)
+	}
+
+	p.outboxMutex.Lock()
+	delete(p.outboxes, address)
+	p.outboxMutex.Unlock()
+}
+
+// PeerCount returns the number of known peers
+func (p *P2PServer) PeerCount() int {
+	p.peersMutex.Lock()
+	defer p.peersMutex.Unlock()
+	return len(p.peers)
 }
 
-// BroadcastBlock sends a new block to all peers
+// BroadcastBlock sends a new block to all peers, as an announcement to
+// peers that fetch bodies on demand
 func (p *P2PServer) BroadcastBlock(block blockchain.Block) {
+	p.propagateBlock(block, nil, "")
+}
+
+// BroadcastTransaction gossips a new transaction to all peers
+func (p *P2PServer) BroadcastTransaction(tx *blockchain.Transaction) {
+	p.gossip(gossipTransaction, tx)
+}
+
+// discoverPeers periodically asks each peer for its peers until the server stops
+func (p *P2PServer) discoverPeers() {
+	p.every(p.intervals.Discovery, func() {
+		for _, peer := range p.peerAddresses() {
+			address := peer
+			p.spawn(func() {
+				if err := p.learnPeersFrom(address); err != nil && !quietPeerError(err) {
+					log.Printf("Failed to get peers from %s: %v\n", address, err)
+				}
+			})
+		}
+	})
+}
+
+// learnPeersFrom fetches a peer's peer list, adds any new peers, and registers with them
+func (p *P2PServer) learnPeersFrom(address string) error {
+	resp, err := p.getPeer(p.httpClient, p.peerURL(address, "/peers"))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var peerList []string
+	if err := json.NewDecoder(resp.Body).Decode(&peerList); err != nil {
+		return fmt.Errorf("failed to decode peers: %w", err)
+	}
+
+	p.learnPeers(address, peerList)
+	return nil
+}
+
+// syncBlockchain periodically syncs the blockchain with peers until the server stops
+func (p *P2PServer) syncBlockchain() {
+	p.every(p.intervals.Sync, p.syncWithPeers)
+}
+
+// peerAddresses returns a snapshot of the known peer addresses
+func (p *P2PServer) peerAddresses() []string {
 	p.peersMutex.Lock()
+	defer p.peersMutex.Unlock()
+
 	peers := make([]string, 0, len(p.peers))
 	for addr := range p.peers {
 		peers = append(peers, addr)
 	}
-	p.peersMutex.Unlock()
+	return peers
+}
+
+// syncWithPeers catches up with the peer whose chain has the most work,
+// downloading only the missing range of blocks. If the peer's blocks don't
+// connect to our chain, it reorganizes onto the peer's branch, falling back
+// to fetching and validating the peer's full chain.
+func (p *P2PServer) syncWithPeers() {
+	if p.syncPaused.Load() {
+		return
+	}
+	if p.isLight() {
+		p.syncHeaders()
+		return
+	}
 
-	for _, peer := range peers {
-		go func(address string) {
-			url := fmt.Sprintf("http://%s/broadcast-block", address)
-			blockData, _ := json.Marshal(block)
-			resp, err := http.Post(url, "application/json", bytes.NewBuffer(blockData))
-			if err != nil {
-				log.Printf("Failed to broadcast block to %s: %v\n", address, err)
-				return
+	bestPeer := ""
+	tip := p.chain.GetLatestBlock()
+	local := tip.Index
+	best := heightResponse{Height: local, LatestHash: tip.Hash, Work: p.chain.TotalWork().String()}
+	ahead := make(map[string]int)
+
+	// Light peers don't serve bodies. Peers are asked cheapest first, so the
+	// fastest of several equally tall peers is synced from.
+	ranked, _ := p.rankSyncPeers(p.fullPeerAddresses())
+	for _, address := range ranked {
+		height, err := p.fetchHeight(address)
+		if err != nil {
+			if quietPeerError(err) {
+				continue
 			}
-			defer resp.Body.Close()
-		}(peer)
+			log.Printf("Failed to get height from %s: %v\n", address, err)
+			continue
+		}
+		p.setPeerHeight(address, height.Height)
+		if height.Height > local {
+			ahead[address] = height.Height
+		}
+		if height.beats(best) {
+			bestPeer = address
+			best = height
+		}
 	}
-}
 
-// discoverPeers periodically looks for new peers
-func (p *P2PServer) discoverPeers() {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
+	if bestPeer == "" {
+		return
+	}
 
-	for {
-		<-ticker.C
-		p.peersMutex.Lock()
-		peers := make([]string, 0, len(p.peers))
-		for addr := range p.peers {
-			peers = append(peers, addr)
+	p.syncTracker.begin(local, max(local, best.Height))
+	defer p.syncTracker.finish()
+	defer func(start time.Time) { p.reportSync(time.Since(start)) }(time.Now())
+
+	// Split large gaps across every peer that is ahead of us
+	if len(ahead) > 1 && best.Height-local > maxSyncBatch {
+		if err := p.parallelSync(ahead, best.Height); err != nil {
+			log.Printf("Parallel sync failed: %v\n", err)
 		}
-		p.peersMutex.Unlock()
-
-		// Ask each peer for their peers
-		for _, peer := range peers {
-			go func(address string) {
-				url := fmt.Sprintf("http://%s/peers", address)
-				resp, err := http.Get(url)
-				if err != nil {
-					log.Printf("Failed to get peers from %s: %v\n", address, err)
-					return
-				}
-				defer resp.Body.Close()
+	}
 
-				var peerList []string
-				if err := json.NewDecoder(resp.Body).Decode(&peerList); err != nil {
-					log.Printf("Failed to decode peers from %s: %v\n", address, err)
-					return
-				}
+	// A peer with more work but no more blocks must be on another fork
+	err := errChainDiverged
+	if best.Height > local {
+		err = p.syncRange(bestPeer, best.Height)
+		if err == nil {
+			return
+		}
+		log.Printf("Range sync with %s failed: %v\n", bestPeer, err)
+	}
 
-				// Register new peers
-				for _, newPeer := range peerList {
-					if newPeer != p.port && newPeer != address {
-						p.AddPeer(newPeer)
-						// Register ourselves with the new peer
-						p.registerWithPeer(newPeer)
-					}
-				}
-			}(peer)
+	// The peer is on a different fork; try to reorganize onto its tip
+	if errors.Is(err, errChainDiverged) {
+		tip, fetchErr := p.RequestBlock(bestPeer, best.LatestHash)
+		if fetchErr == nil && p.resolveFork(tip, bestPeer) == nil {
+			return
 		}
 	}
+	p.syncFullChain(bestPeer)
 }
 
-// syncBlockchain periodically syncs the blockchain with peers
-func (p *P2PServer) syncBlockchain() {
-	ticker := time.NewTicker(60 * time.Second)
-	defer ticker.Stop()
+// blocksAfter returns up to count blocks following the given index. A count
+// outside 1..maxSyncBatch is treated as maxSyncBatch.
+func (p *P2PServer) blocksAfter(from, count int) []blockchain.Block {
+	if count <= 0 || count > maxSyncBatch {
+		count = maxSyncBatch
+	}
+
+	blocks := p.chain.BlocksFrom(from+1, count)
+	// Pruned blocks can't be validated by the requester
+	if len(blocks) > 0 && blocks[0].Pruned {
+		return nil
+	}
+	return blocks
+}
+
+// fetchHeight asks a peer for the index and hash of its latest block
+func (p *P2PServer) fetchHeight(address string) (heightResponse, error) {
+	start := time.Now()
+	resp, err := p.getPeer(p.httpClient, p.peerURL(address, "/height"))
+	if err != nil {
+		if errors.Is(err, errPeerTimeout) {
+			p.recordLatency(address, time.Since(start))
+		}
+		return heightResponse{}, err
+	}
+	defer resp.Body.Close()
+	p.recordLatency(address, time.Since(start))
 
+	var height heightResponse
+	if err := json.NewDecoder(resp.Body).Decode(&height); err != nil {
+		return heightResponse{}, err
+	}
+	return height, nil
+}
+
+// syncRange downloads blocks after our tip in batches until we reach the target height
+func (p *P2PServer) syncRange(address string, targetHeight int) error {
 	for {
-		<-ticker.C
-		p.peersMutex.Lock()
-		peers := make([]string, 0, len(p.peers))
-		for addr := range p.peers {
-			peers = append(peers, addr)
+		from := p.chain.GetLatestBlock().Index
+		if from >= targetHeight {
+			return nil
 		}
-		p.peersMutex.Unlock()
-
-		// Sync with each peer
-		for _, peer := range peers {
-			go func(address string) {
-				url := fmt.Sprintf("http://%s/", address)
-				resp, err := http.Get(url)
-				if err != nil {
-					log.Printf("Failed to sync with %s: %v\n", address, err)
-					return
-				}
-				defer resp.Body.Close()
 
-				var blocks []blockchain.Block
-				if err := json.NewDecoder(resp.Body).Decode(&blocks); err != nil {
-					log.Printf("Failed to decode blockchain from %s: %v\n", address, err)
-					return
-				}
+		blocks, err := p.fetchRange(address, from, maxSyncBatch)
+		if err != nil {
+			return err
+		}
+		if len(blocks) == 0 {
+			return nil
+		}
 
-				// Replace our chain if the peer has a longer valid chain
-				if len(blocks) > len(p.chain.GetBlocks()) {
-					if p.chain.ReplaceChain(blocks) {
-						log.Printf("Blockchain replaced with longer chain from %s\n", address)
-					}
-				}
-			}(peer)
+		tip := p.chain.GetLatestBlock()
+		var parent *blockchain.Block
+		if blocks[0].PrevHash == tip.Hash {
+			parent = &tip
 		}
+		if err := p.validateBlocks(blocks, parent); err != nil {
+			p.penalize(address, err)
+			return err
+		}
+		for _, block := range blocks {
+			if err := p.chain.AddExistingBlock(block); err != nil {
+				return errChainDiverged
+			}
+		}
+		p.syncTracker.advance(p.chain.GetLatestBlock().Index)
+		log.Printf("Synced %d blocks from %s\n", len(blocks), address)
 	}
 }
 
-// registerWithPeer registers this node with another peer
-func (p *P2PServer) registerWithPeer(peerAddr string) {
-	url := fmt.Sprintf("http://%s/register-peer", peerAddr)
-	data := map[string]string{"address": p.port}
-	jsonData, _ := json.Marshal(data)
+// fetchRange requests up to count blocks after an index from a peer, over
+// its session when one is open and over HTTP otherwise
+func (p *P2PServer) fetchRange(address string, from, count int) ([]blockchain.Block, error) {
+	start := time.Now()
+	if session := p.session(address); session != nil {
+		response, err := session.request(peerFrame{Type: frameSyncRequest, FromIndex: from, Count: count})
+		if err == nil {
+			p.recordLatency(address, time.Since(start))
+			return response.Blocks, nil
+		}
+		log.Printf("Session range request to %s failed, falling back to HTTP: %v\n", address, err)
+		start = time.Now()
+	}
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	url := p.peerURL(address, fmt.Sprintf("/sync?from_index=%d&count=%d", from, count))
+	resp, err := p.getPeer(p.clientWithTimeout(syncChunkTimeout), url)
 	if err != nil {
-		log.Printf("Failed to register with peer %s: %v\n", peerAddr, err)
+		if errors.Is(err, errPeerTimeout) {
+			p.recordLatency(address, time.Since(start))
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var blocks []blockchain.Block
+	if err := json.NewDecoder(resp.Body).Decode(&blocks); err != nil {
+		return nil, fmt.Errorf("failed to decode blocks: %w", err)
+	}
+	p.recordLatency(address, time.Since(start))
+	return blocks, nil
+}
+
+// syncFullChain downloads a peer's entire chain and replaces ours if it's valid and has more work.
+// It is the last-resort path when range sync cannot connect the peer's blocks.
+func (p *P2PServer) syncFullChain(address string) {
+	resp, err := p.getPeer(p.clientWithTimeout(fullChainTimeout), p.peerURL(address, "/sync"))
+	if err != nil {
+		if !quietPeerError(err) {
+			log.Printf("Failed to sync with %s: %v\n", address, err)
+		}
 		return
 	}
 	defer resp.Body.Close()
+
+	var blocks []blockchain.Block
+	if err := json.NewDecoder(resp.Body).Decode(&blocks); err != nil {
+		log.Printf("Failed to decode blockchain from %s: %v\n", address, err)
+		return
+	}
+
+	if len(blocks) == 0 || blocks[0].Hash != p.chain.Genesis().Hash {
+		log.Printf("Ignoring chain from %s: %v\n", address, blockchain.ErrGenesisMismatch)
+		return
+	}
+	if err := p.validateBlocks(blocks, nil); err != nil {
+		p.penalize(address, err)
+		return
+	}
+
+	// Replace our chain if the peer's valid chain has more work
+	if p.chain.ReplaceChain(blocks) {
+		log.Printf("Blockchain replaced with chain of more work from %s\n", address)
+	}
+}
+
+// registerWithPeer registers this node with another peer and stores the
+// peer once it has answered the handshake
+func (p *P2PServer) registerWithPeer(peerAddr string) error {
+	peerAddr, err := normalizeAddress(peerAddr)
+	if err != nil {
+		return err
+	}
+
+	// Peers we haven't handshaken with may speak either scheme
+	scheme := p.peerScheme(peerAddr)
+	resp, err := p.postHandshake(peerAddr, scheme)
+	if isSchemeMismatch(resp, err) {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		scheme = otherScheme(scheme)
+		resp, err = p.postHandshake(peerAddr, scheme)
+	}
+	if err != nil {
+		if !quietPeerError(err) {
+			log.Printf("Failed to register with peer %s: %v\n", peerAddr, err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("peer %s rejected registration: %s", peerAddr, resp.Status)
+	}
+
+	// Learn the peer's identity from its reply
+	var remote handshake
+	if err := json.NewDecoder(resp.Body).Decode(&remote); err != nil {
+		if _, err := p.addPeer(peerAddr); err != nil {
+			return err
+		}
+		p.setPeerScheme(peerAddr, scheme)
+		return nil
+	}
+	if remote.NodeID == p.nodeID {
+		p.removePeer(peerAddr)
+		return errSelfPeer
+	}
+	if _, err := p.addPeer(peerAddr); err != nil {
+		return err
+	}
+	if err := p.learnIdentity(remote); err != nil {
+		return fmt.Errorf("peer %s sent an invalid identity: %w", peerAddr, err)
+	}
+	p.setPeerScheme(peerAddr, scheme)
+	if p.wsEnabled && remote.hasCapability(capabilityWebSocket) && p.session(peerAddr) == nil {
+		p.spawn(func() { p.maintainSession(peerAddr) })
+	}
+	// Catch up on the peer's pending transactions so our miner has work
+	if !p.isLight() && remote.Role != RoleLight {
+		p.spawn(func() {
+			if err := p.syncMempool(peerAddr); err != nil && !quietPeerError(err) {
+				log.Printf("Failed to sync mempool with %s: %v\n", peerAddr, err)
+			}
+		})
+	}
+	return nil
+}
+
+// postHandshake sends our handshake to a peer's registration endpoint
+func (p *P2PServer) postHandshake(peerAddr, scheme string) (*http.Response, error) {
+	url := fmt.Sprintf("%s://%s/register-peer", scheme, peerAddr)
+	jsonData, _ := json.Marshal(p.localHandshake())
+
+	req, err := p.newPeerRequest(http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return p.doPeer(p.httpClient, req)
 }
 
 // HTTP Handlers
@@ -203,77 +866,71 @@ func (p *P2PServer) handlePeers(w http.ResponseWriter, r *http.Request) {
 }
 
 func (p *P2PServer) handleRegisterPeer(w http.ResponseWriter, r *http.Request) {
-	var data map[string]string
-	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	var data handshake
+	if !p.decodeBody(w, r, &data) {
 		return
 	}
 
-	address, ok := data["address"]
-	if !ok {
+	if data.Address == "" {
 		http.Error(w, "Missing peer address", http.StatusBadRequest)
 		return
 	}
+	if data.NodeID == p.nodeID {
+		http.Error(w, errSelfPeer.Error(), http.StatusConflict)
+		return
+	}
+	if _, err := p.admitPeer(r.Context(), data); err != nil {
+		http.Error(w, err.Error(), admitStatus(err))
+		return
+	}
 
-	p.AddPeer(address)
-	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(p.localHandshake())
 }
 
 func (p *P2PServer) handleSync(w http.ResponseWriter, r *http.Request) {
-	blocks := p.chain.GetBlocks()
-	json.NewEncoder(w).Encode(blocks)
-}
-
-func (p *P2PServer) handleBroadcastBlock(w http.ResponseWriter, r *http.Request) {
-	var block blockchain.Block
-	if err := json.NewDecoder(r.Body).Decode(&block); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	// Without from_index the full chain is returned
+	fromParam := r.URL.Query().Get("from_index")
+	if fromParam == "" {
+		if _, pruned := p.chain.SnapshotBase(); pruned {
+			http.Error(w, "Chain history is pruned", http.StatusGone)
+			return
+		}
+		json.NewEncoder(w).Encode(p.chain.GetBlocks())
 		return
 	}
 
-	// Check if we've already seen this block
-	if p.knownBlocks[block.Hash] {
-		w.WriteHeader(http.StatusOK)
+	from, err := strconv.Atoi(fromParam)
+	if err != nil || from < -1 {
+		http.Error(w, "Invalid from_index", http.StatusBadRequest)
 		return
 	}
 
-	// Mark this block as seen
-	p.knownBlocks[block.Hash] = true
+	count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+	json.NewEncoder(w).Encode(p.blocksAfter(from, count))
+}
 
-	// Validate and add the block to our chain if valid
-	if blockchain.IsBlockValid(block, p.chain.GetLatestBlock()) {
-		p.chain.ReplaceChain(append(p.chain.GetBlocks(), block))
-		log.Printf("Added new block from peer: %s\n", block.Hash)
+func (p *P2PServer) handleHeight(w http.ResponseWriter, r *http.Request) {
+	height, hash := p.localTip()
+	response := heightResponse{Height: height, LatestHash: hash}
+	if !p.isLight() {
+		response.Work = p.chain.TotalWork().String()
+	}
+	json.NewEncoder(w).Encode(response)
+}
 
-		// Forward the block to other peers (except the one who sent it)
-		io.Copy(io.Discard, r.Body) // Drain the body
-		peerAddr := r.Header.Get("X-Forwarded-For")
-		if peerAddr == "" {
-			peerAddr = r.RemoteAddr
-		}
+func (p *P2PServer) handleGossip(w http.ResponseWriter, r *http.Request) {
+	var msg GossipMessage
+	if !p.decodeBody(w, r, &msg) {
+		return
+	}
 
-		p.peersMutex.Lock()
-		peers := make([]string, 0, len(p.peers))
-		for addr := range p.peers {
-			if addr != peerAddr {
-				peers = append(peers, addr)
-			}
-		}
-		p.peersMutex.Unlock()
-
-		for _, peer := range peers {
-			go func(address string) {
-				url := fmt.Sprintf("http://%s/broadcast-block", address)
-				blockData, _ := json.Marshal(block)
-				resp, err := http.Post(url, "application/json", bytes.NewBuffer(blockData))
-				if err != nil {
-					log.Printf("Failed to forward block to %s: %v\n", address, err)
-					return
-				}
-				defer resp.Body.Close()
-			}(peer)
+	status, err := p.processGossip(msg, r.Header.Get(nodeAddressHeader))
+	if err != nil {
+		if status == http.StatusUnauthorized || status == http.StatusBadRequest {
+			p.limiter.RecordViolation(p.requesterKey(r))
 		}
+		http.Error(w, err.Error(), status)
+		return
 	}
-
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(status)
 }
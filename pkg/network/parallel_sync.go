@@ -0,0 +1,204 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// Parallel catch-up tuning
+const (
+	// syncChunkSize is the number of blocks requested from a peer at once
+	syncChunkSize = maxSyncBatch
+	// syncWindowChunks bounds how far ahead of the tip chunks may be fetched,
+	// which caps the blocks buffered while waiting for an earlier chunk
+	syncWindowChunks = 8
+	// syncChunkTimeout bounds a single chunk request
+	syncChunkTimeout = 15 * time.Second
+)
+
+// errBadChunk is returned when a peer answers a chunk request with blocks
+// that do not form the requested range
+var errBadChunk = errors.New("peer returned an invalid chunk")
+
+// chunkResult is the outcome of one chunk request
+type chunkResult struct {
+	start  int
+	peer   string
+	blocks []blockchain.Block
+	err    error
+}
+
+// chunkCount returns the number of blocks in the chunk starting after start
+func chunkCount(start, target int) int {
+	if target-start < syncChunkSize {
+		return target - start
+	}
+	return syncChunkSize
+}
+
+// validateChunk checks that blocks are exactly the requested range and that
+// they link to each other. The link to the preceding chunk is checked when
+// the chunk is appended.
+func validateChunk(blocks []blockchain.Block, start, count int) error {
+	if len(blocks) != count {
+		return fmt.Errorf("%w: expected %d blocks, got %d", errBadChunk, count, len(blocks))
+	}
+	for i, block := range blocks {
+		if block.Index != start+1+i {
+			return fmt.Errorf("%w: unexpected index %d", errBadChunk, block.Index)
+		}
+		if i == 0 {
+			if !blockchain.HashMatches(block) {
+				return fmt.Errorf("%w: bad hash at index %d", errBadChunk, block.Index)
+			}
+			continue
+		}
+		if !blockchain.IsBlockValid(block, blocks[i-1]) {
+			return fmt.Errorf("%w: broken link at index %d", errBadChunk, block.Index)
+		}
+	}
+	return nil
+}
+
+// parallelSync downloads the blocks between our tip and target in chunks
+// spread across the given peers, keyed by address with their reported
+// height. Chunks may arrive in any order but are appended strictly in
+// sequence. A chunk that times out or fails validation is requested again
+// from a different peer, and so is a chunk that does not connect to the one
+// before it. It fails once some chunk has no peer left to ask, leaving the
+// caller to fall back to single-peer sync.
+func (p *P2PServer) parallelSync(peers map[string]int, target int) error {
+	base := p.chain.GetLatestBlock().Index
+
+	var pending []int
+	for start := base; start < target; start += syncChunkSize {
+		pending = append(pending, start)
+	}
+
+	idle := make([]string, 0, len(peers))
+	for address := range peers {
+		idle = append(idle, address)
+	}
+
+	tried := make(map[int]map[string]bool)
+	ready := make(map[int]chunkResult)
+	// Buffered so requests still in flight on an early return can finish
+	results := make(chan chunkResult, len(peers))
+	inFlight := 0
+	next := base
+
+	// dispatch hands each candidate the earliest chunk inside the window that
+	// it can serve and has not already failed
+	dispatch := func(candidates []string) {
+		limit := next + syncWindowChunks*syncChunkSize
+		assignedPeers := make(map[string]bool)
+		for _, address := range candidates {
+			assigned := -1
+			for i, start := range pending {
+				if start >= limit {
+					break
+				}
+				count := chunkCount(start, target)
+				if tried[start][address] || peers[address] < start+count {
+					continue
+				}
+				assigned = i
+				break
+			}
+			if assigned < 0 {
+				continue
+			}
+			assignedPeers[address] = true
+
+			start := pending[assigned]
+			pending = append(pending[:assigned], pending[assigned+1:]...)
+			inFlight++
+			go func(address string, start, count int) {
+				blocks, err := p.fetchRange(address, start, count)
+				if err == nil {
+					err = validateChunk(blocks, start, count)
+				}
+				if err == nil {
+					if err = p.validateBlocks(blocks, nil); err != nil {
+						p.penalize(address, err)
+					}
+				}
+				results <- chunkResult{start: start, peer: address, blocks: blocks, err: err}
+			}(address, start, chunkCount(start, target))
+		}
+
+		remaining := idle[:0]
+		for _, address := range idle {
+			if !assignedPeers[address] {
+				remaining = append(remaining, address)
+			}
+		}
+		idle = remaining
+	}
+
+	// requeue returns a chunk to the queue, keeping it ordered
+	requeue := func(start int, peer string) {
+		if tried[start] == nil {
+			tried[start] = make(map[string]bool)
+		}
+		tried[start][peer] = true
+		i := sort.SearchInts(pending, start)
+		pending = append(pending, 0)
+		copy(pending[i+1:], pending[i:])
+		pending[i] = start
+	}
+
+	for next < target {
+		// Fast peers get the work; slower ones only when exploring or when
+		// nothing else is running
+		dispatch(p.preferredSyncPeers(idle))
+		if inFlight == 0 {
+			dispatch(idle)
+		}
+		if inFlight == 0 {
+			return fmt.Errorf("no peer can serve blocks after index %d", next)
+		}
+
+		result := <-results
+		inFlight--
+		idle = append(idle, result.peer)
+
+		if result.err != nil {
+			log.Printf("Chunk after %d from %s failed: %v\n", result.start, result.peer, result.err)
+			requeue(result.start, result.peer)
+			continue
+		}
+		ready[result.start] = result
+
+		// Append every chunk that now continues our tip
+		for {
+			chunk, ok := ready[next]
+			if !ok {
+				break
+			}
+			delete(ready, next)
+			blocks := chunk.blocks
+
+			if err := p.chain.AddExistingBlock(blocks[0]); err != nil {
+				// Internally consistent but on another branch; ask someone else
+				requeue(next, chunk.peer)
+				break
+			}
+			for _, block := range blocks[1:] {
+				if err := p.chain.AddExistingBlock(block); err != nil {
+					return fmt.Errorf("failed to append block %d: %w", block.Index, err)
+				}
+			}
+			next += len(blocks)
+			p.syncTracker.advance(next)
+		}
+	}
+
+	log.Printf("Synced %d blocks from %d peers\n", next-base, len(peers))
+	return nil
+}
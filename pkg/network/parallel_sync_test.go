@@ -0,0 +1,143 @@
+package network
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// syncPeer serves p's P2P routes after a delay, recording where each range
+// request it answered started
+type syncPeer struct {
+	address string
+	mutex   sync.Mutex
+	starts  []int
+}
+
+// slowPeer starts a syncPeer. If tamper is set it is applied to every range
+// before it is served.
+func slowPeer(t *testing.T, p *P2PServer, delay time.Duration, tamper func([]blockchain.Block)) *syncPeer {
+	t.Helper()
+	peer := &syncPeer{}
+	mux := http.NewServeMux()
+	p.RegisterRoutes(mux)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		from, err := strconv.Atoi(r.URL.Query().Get("from_index"))
+		if err != nil {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		peer.mutex.Lock()
+		peer.starts = append(peer.starts, from)
+		peer.mutex.Unlock()
+		if tamper == nil {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		// Fetched uncompressed so it can be decoded and altered
+		r.Header.Del("Accept-Encoding")
+		served := httptest.NewRecorder()
+		mux.ServeHTTP(served, r)
+		var blocks []blockchain.Block
+		if err := json.Unmarshal(served.Body.Bytes(), &blocks); err != nil {
+			t.Error(err)
+			return
+		}
+		tamper(blocks)
+		json.NewEncoder(w).Encode(blocks)
+	}))
+	t.Cleanup(server.Close)
+	peer.address = strings.TrimPrefix(server.URL, "http://")
+	return peer
+}
+
+// served returns the starts of the ranges the peer has answered
+func (s *syncPeer) served() []int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]int{}, s.starts...)
+}
+
+// forgeHash gives a block in the middle of the range a hash of its own
+func forgeHash(blocks []blockchain.Block) {
+	blocks[len(blocks)/2].Hash = strings.Repeat("0", 64)
+}
+
+// breakLink points a block in the middle of the range at the wrong parent
+func breakLink(blocks []blockchain.Block) {
+	block := &blocks[len(blocks)/2]
+	block.PrevHash = strings.Repeat("f", 64)
+	block.Hash = blockchain.CalculateHash(*block)
+}
+
+func TestParallelSyncAssemblesRangeFromSeveralPeers(t *testing.T) {
+	const chunks = 8
+	const latency = 100 * time.Millisecond
+	ahead, behind := newTestServer(t), newTestServer(t)
+	growChain(t, ahead.chain, chunks*syncChunkSize)
+	target := ahead.chain.Height()
+
+	good := []*syncPeer{slowPeer(t, ahead, latency, nil), slowPeer(t, ahead, 2*latency, nil)}
+	bad := []*syncPeer{slowPeer(t, ahead, latency/4, forgeHash), slowPeer(t, ahead, latency/4, breakLink)}
+	peers := make(map[string]int)
+	for _, peer := range append(good, bad...) {
+		peers[peer.address] = target
+	}
+
+	start := time.Now()
+	if err := behind.parallelSync(peers, target); err != nil {
+		t.Fatal(err)
+	}
+	elapsed := time.Since(start)
+
+	if got, want := behind.chain.GetLatestBlock(), ahead.chain.GetLatestBlock(); got.Hash != want.Hash {
+		t.Fatalf("synced to block %d %s, want %d %s", got.Index, got.Hash, want.Index, want.Hash)
+	}
+	if serial := chunks * 2 * latency; elapsed >= serial {
+		t.Errorf("took %s, no faster than %s fetching serially from the slower peer", elapsed, serial)
+	}
+
+	// Every range a bad peer served was asked for again from a good one
+	fromGood := make(map[int]bool)
+	for _, peer := range good {
+		for _, start := range peer.served() {
+			fromGood[start] = true
+		}
+	}
+	for i, peer := range bad {
+		starts := peer.served()
+		if len(starts) == 0 {
+			t.Errorf("bad peer %d was never asked for a range", i)
+		}
+		for _, start := range starts {
+			if !fromGood[start] {
+				t.Errorf("range after %d from bad peer %d was never requested again", start, i)
+			}
+		}
+	}
+}
+
+func TestParallelSyncFailsWithOnlyBadPeers(t *testing.T) {
+	ahead, behind := newTestServer(t), newTestServer(t)
+	growChain(t, ahead.chain, syncChunkSize)
+
+	peers := map[string]int{
+		slowPeer(t, ahead, 0, forgeHash).address: ahead.chain.Height(),
+		slowPeer(t, ahead, 0, breakLink).address: ahead.chain.Height(),
+	}
+	if err := behind.parallelSync(peers, ahead.chain.Height()); err == nil {
+		t.Fatal("synced from peers returning only tampered chunks")
+	}
+	if h := behind.chain.Height(); h != 0 {
+		t.Fatalf("appended up to height %d from tampered chunks", h)
+	}
+}
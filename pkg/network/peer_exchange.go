@@ -0,0 +1,192 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxPeers caps the peer map unless configured otherwise
+const DefaultMaxPeers = 50
+
+// defaultPeerPort is assumed for learned addresses that omit a port
+const defaultPeerPort = "3000"
+
+// maxLearnedPeers bounds how many addresses are taken from one peer list
+const maxLearnedPeers = 100
+
+// peerStaleAfter is how long a peer may go unseen before a newly learned
+// peer may replace it when the peer map is full
+const peerStaleAfter = 10 * time.Minute
+
+// errPeerLimit is returned when the peer map is full of healthy peers
+var errPeerLimit = errors.New("peer limit reached")
+
+// normalizeAddress canonicalizes a peer address to host:port, lowercasing
+// host names, mapping localhost to 127.0.0.1, and assuming defaultPeerPort
+// when the port is missing. Malformed addresses are rejected.
+func normalizeAddress(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if address == "" || strings.ContainsAny(address, "/ \t@?#") {
+		return "", fmt.Errorf("invalid peer address %q", address)
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		// Retry as a bare host without a port
+		host, port, err = net.SplitHostPort(net.JoinHostPort(strings.Trim(address, "[]"), defaultPeerPort))
+		if err != nil {
+			return "", fmt.Errorf("invalid peer address %q: %w", address, err)
+		}
+	}
+
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("invalid port in peer address %q", address)
+	}
+
+	host = strings.ToLower(host)
+	if host == "" {
+		return "", fmt.Errorf("missing host in peer address %q", address)
+	}
+	if host == "localhost" {
+		host = "127.0.0.1"
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.IsUnspecified() || ip.IsMulticast() {
+			return "", fmt.Errorf("unroutable peer address %q", address)
+		}
+		host = ip.String()
+	} else if !validHostname(host) {
+		return "", fmt.Errorf("invalid host in peer address %q", address)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(n)), nil
+}
+
+// validHostname reports whether host looks like a DNS name
+func validHostname(host string) bool {
+	if len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// SetMaxPeers caps how many peers are kept. Seeds are always kept.
+func (p *P2PServer) SetMaxPeers(max int) {
+	p.peersMutex.Lock()
+	defer p.peersMutex.Unlock()
+	p.maxPeers = max
+}
+
+// peerRank orders peers for eviction; lower ranks are evicted first
+func peerRank(peer Peer, now time.Time) float64 {
+	rank := peer.deliveryRatio() - 0.1*float64(peer.ConsecutiveFailures)
+	if now.Sub(peer.LastSeen) > peerStaleAfter {
+		rank--
+	}
+	return rank
+}
+
+// makeRoomLocked frees a slot for a new peer when the map is full by
+// evicting the lowest-ranked non-seed peer, but only if it is unhealthy or
+// stale. It reports whether a new peer fits. peersMutex must be held.
+func (p *P2PServer) makeRoomLocked() bool {
+	if p.maxPeers <= 0 || len(p.peers) < p.maxPeers {
+		return true
+	}
+
+	now := time.Now()
+	worst := ""
+	worstRank := 0.0
+	for address, peer := range p.peers {
+		if peer.Seed {
+			continue
+		}
+		rank := peerRank(peer, now)
+		if worst == "" || rank < worstRank || (rank == worstRank && peer.LastSeen.Before(p.peers[worst].LastSeen)) {
+			worst = address
+			worstRank = rank
+		}
+	}
+
+	// A healthy, recently seen peer is worth more than an untried one
+	if worst == "" || worstRank >= 1 {
+		return false
+	}
+	log.Printf("Evicting peer %s to make room\n", worst)
+	delete(p.peers, worst)
+	return true
+}
+
+// addPeer normalizes and stores a peer, refreshing it if already known. It
+// never stores this node's address and respects the peer limit.
+func (p *P2PServer) addPeer(address string) (string, error) {
+	address, err := normalizeAddress(address)
+	if err != nil {
+		return "", err
+	}
+
+	p.peersMutex.Lock()
+	defer p.peersMutex.Unlock()
+
+	if address == p.address {
+		return "", errSelfPeer
+	}
+
+	// Re-adding a known peer refreshes it without losing what we learned
+	peer, known := p.peers[address]
+	if !known && !p.makeRoomLocked() {
+		return "", errPeerLimit
+	}
+	peer.Address = address
+	peer.LastSeen = time.Now()
+	p.peers[address] = peer
+	p.reportPeerCountLocked()
+	if !known {
+		log.Printf("Added peer: %s\n", address)
+	}
+	return address, nil
+}
+
+// learnPeers registers with the addresses from a peer list, skipping
+// malformed entries, duplicates, ourselves, and peers we already know.
+// Only peers that complete a handshake are stored.
+func (p *P2PServer) learnPeers(from string, addresses []string) {
+	if len(addresses) > maxLearnedPeers {
+		addresses = addresses[:maxLearnedPeers]
+	}
+
+	seen := make(map[string]bool)
+	for _, raw := range addresses {
+		address, err := normalizeAddress(raw)
+		if err != nil || seen[address] || address == p.address || address == from {
+			continue
+		}
+		seen[address] = true
+
+		p.peersMutex.Lock()
+		_, known := p.peers[address]
+		p.peersMutex.Unlock()
+		if known {
+			continue
+		}
+
+		if err := p.registerWithPeer(address); err != nil && !quietPeerError(err) {
+			log.Printf("Skipping learned peer %s: %v\n", address, err)
+		}
+	}
+}
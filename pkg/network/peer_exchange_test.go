@@ -0,0 +1,79 @@
+package network
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"10.0.0.1:3000", "10.0.0.1:3000"},
+		{" 10.0.0.1:3000 ", "10.0.0.1:3000"},
+		{"10.0.0.1", "10.0.0.1:3000"},
+		{"localhost:4000", "127.0.0.1:4000"},
+		{"Node-1.Example.com:4000", "node-1.example.com:4000"},
+		{"[::1]:4000", "[::1]:4000"},
+		{"::1", "[::1]:3000"},
+		{"", ""},
+		{"http://10.0.0.1:3000", ""},
+		{"10.0.0.1:0", ""},
+		{"10.0.0.1:70000", ""},
+		{"0.0.0.0:3000", ""},
+		{"224.0.0.1:3000", ""},
+		{"-bad-.example:3000", ""},
+		{"user@10.0.0.1:3000", ""},
+	}
+	for _, tt := range tests {
+		got, err := normalizeAddress(tt.in)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("normalized %q to %q, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("normalized %q to %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestLearnPeersFiltersAndCaps(t *testing.T) {
+	p := newTestServer(t)
+	listen(t, p)
+	p.SetMaxPeers(2)
+	var live []*P2PServer
+	for i := 0; i < 3; i++ {
+		peer := newTestServer(t)
+		listen(t, peer)
+		live = append(live, peer)
+	}
+
+	_, port, _ := strings.Cut(live[0].address, ":")
+	p.learnPeers("10.0.0.9:3000", []string{
+		"not an address",
+		p.address,
+		"127.0.0.1:1",
+		live[0].address,
+		"localhost:" + port,
+		live[1].address,
+		live[2].address,
+	})
+
+	if n := p.PeerCount(); n != 2 {
+		t.Fatalf("stored %d peers, want the cap of 2", n)
+	}
+	for _, peer := range live[:2] {
+		if got := boundNodeID(p, peer.address); got != peer.nodeID {
+			t.Errorf("peer %s bound to %q, want %s", peer.address, got, peer.nodeID)
+		}
+	}
+	p.peersMutex.Lock()
+	defer p.peersMutex.Unlock()
+	for _, address := range []string{p.address, "127.0.0.1:1", "10.0.0.9:3000", live[2].address} {
+		if _, ok := p.peers[address]; ok {
+			t.Errorf("stored %s", address)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package network
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSeedsAreNeverRemoved(t *testing.T) {
+	p := newTestServer(t)
+	p.AddSeed("10.0.0.1:3000")
+	p.AddPeer("10.0.0.2:3000")
+
+	p.removePeer("10.0.0.1:3000")
+	p.removePeer("10.0.0.2:3000")
+
+	p.peersMutex.Lock()
+	seed, ok := p.peers["10.0.0.1:3000"]
+	p.peersMutex.Unlock()
+	if !ok || !seed.Seed {
+		t.Fatalf("seed was removed, stored as %+v", seed)
+	}
+	if n := p.PeerCount(); n != 1 {
+		t.Fatalf("%d peers left, want only the seed", n)
+	}
+}
+
+func TestBootstrapFromSeedLearnsItsPeers(t *testing.T) {
+	seed, other, fresh := newTestServer(t), newTestServer(t), newTestServer(t)
+	listen(t, seed)
+	listen(t, other)
+	listen(t, fresh)
+	if err := other.ConnectPeer(seed.address); err != nil {
+		t.Fatal(err)
+	}
+
+	fresh.AddSeed(seed.address)
+	fresh.bootstrapFromSeed(seed.address)
+
+	if got := boundNodeID(fresh, seed.address); got != seed.nodeID {
+		t.Errorf("fresh bound the seed to %q, want %s", got, seed.nodeID)
+	}
+	if got := boundNodeID(fresh, other.address); got != other.nodeID {
+		t.Errorf("fresh didn't learn the seed's peer, bound %q", got)
+	}
+	if got := boundNodeID(seed, fresh.address); got != fresh.nodeID {
+		t.Errorf("seed bound fresh to %q, want %s", got, fresh.nodeID)
+	}
+}
+
+func TestBootstrapFromUnreachableSeedStopsWithServer(t *testing.T) {
+	p := newTestServer(t)
+	// Nothing listens on port 1
+	p.AddSeed("127.0.0.1:1")
+
+	done := make(chan struct{})
+	go func() {
+		p.bootstrapFromSeed("127.0.0.1:1")
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("bootstrap gave up on an unreachable seed")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	p.cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("bootstrap kept retrying after the server stopped")
+	}
+}
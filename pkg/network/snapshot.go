@@ -0,0 +1,327 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/snapshot"
+)
+
+// maxSnapshotChunkBytes bounds a snapshot chunk downloaded from a peer
+const maxSnapshotChunkBytes = 64 << 20
+
+// servedSnapshots is how many snapshots are kept so that chunks stay
+// available to a node still downloading the previous one
+const servedSnapshots = 2
+
+// errNoSnapshot is returned by peers that don't serve snapshots
+var errNoSnapshot = errors.New("peer serves no snapshot")
+
+// SnapshotSource builds a snapshot of the chain at a height
+type SnapshotSource func(height int) (*snapshot.Snapshot, error)
+
+// snapshotCache keeps the snapshots served to peers, newest last, so one
+// is only rebuilt when asked for another block
+type snapshotCache struct {
+	source SnapshotSource
+	recent []*snapshot.Snapshot
+	mutex  sync.Mutex
+}
+
+// get returns the snapshot of the block at height with the given hash,
+// building it if needed
+func (c *snapshotCache) get(height int, hash string) (*snapshot.Snapshot, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, s := range c.recent {
+		if s.Manifest.TipHash == hash {
+			return s, nil
+		}
+	}
+	s, err := c.source(height)
+	if err != nil {
+		return nil, err
+	}
+	c.recent = append(c.recent, s)
+	if len(c.recent) > servedSnapshots {
+		c.recent = c.recent[len(c.recent)-servedSnapshots:]
+	}
+	return s, nil
+}
+
+// chunk returns a chunk of any snapshot still served
+func (c *snapshotCache) chunk(hash string) ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, s := range c.recent {
+		if data, err := s.Chunk(hash); err == nil {
+			return data, nil
+		}
+	}
+	return nil, snapshot.ErrUnknownChunk
+}
+
+// SetSnapshotSource serves snapshots built by source to peers fast
+// syncing. Without one, /snapshot responds 404.
+func (p *P2PServer) SetSnapshotSource(source SnapshotSource) {
+	p.snapshots = &snapshotCache{source: source}
+}
+
+// handleSnapshot serves the manifest of the snapshot of the block at the
+// height asked for, the tip by default
+func (p *P2PServer) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if p.snapshots == nil {
+		http.Error(w, errNoSnapshot.Error(), http.StatusNotFound)
+		return
+	}
+	blocks := p.chain.GetBlocks()
+	height := len(blocks) - 1
+	if param := r.URL.Query().Get("height"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid height", http.StatusBadRequest)
+			return
+		}
+		if n > height || blocks[n].Pruned {
+			http.Error(w, errNoSnapshot.Error(), http.StatusNotFound)
+			return
+		}
+		height = n
+	}
+
+	s, err := p.snapshots.get(height, blocks[height].Hash)
+	if err != nil {
+		log.Printf("Failed to build snapshot: %v\n", err)
+		http.Error(w, "Failed to build snapshot", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(s.Manifest)
+}
+
+// handleSnapshotChunk serves a snapshot chunk by hash
+func (p *P2PServer) handleSnapshotChunk(w http.ResponseWriter, r *http.Request) {
+	if p.snapshots == nil {
+		http.Error(w, errNoSnapshot.Error(), http.StatusNotFound)
+		return
+	}
+	data, err := p.snapshots.chunk(r.PathValue("hash"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// PauseSync stops the chain from being synced with peers until ResumeSync,
+// such as while a snapshot is installed
+func (p *P2PServer) PauseSync() {
+	p.syncPaused.Store(true)
+}
+
+// ResumeSync undoes PauseSync
+func (p *P2PServer) ResumeSync() {
+	p.syncPaused.Store(false)
+}
+
+// FetchedSnapshot is a snapshot downloaded and verified by FetchSnapshot
+type FetchedSnapshot struct {
+	Manifest snapshot.Manifest
+	State    snapshot.State
+	// Blocks is the chain up to the snapshot tip: pruned blocks made from
+	// the downloaded headers, followed by the snapshot's whole blocks
+	Blocks []blockchain.Block
+	Peer   string
+}
+
+// FetchSnapshot downloads a trusted snapshot from the first full peer that
+// serves one: of the peer's tip when signers are trusted, and otherwise of
+// the checkpoint. The manifest must be trusted, the headers from genesis to the
+// snapshot tip, and to the checkpoint if there is one, must link and meet
+// the consensus rules, and every chunk must match the manifest.
+func (p *P2PServer) FetchSnapshot(trust snapshot.Trust) (*FetchedSnapshot, error) {
+	peers, _ := p.rankSyncPeers(p.fullPeerAddresses())
+	if len(peers) == 0 {
+		return nil, errors.New("no peers to fetch a snapshot from")
+	}
+
+	var errs []error
+	for _, address := range peers {
+		fetched, err := p.fetchSnapshotFrom(address, trust)
+		if err == nil {
+			return fetched, nil
+		}
+		if !quietPeerError(err) && !errors.Is(err, errNoSnapshot) {
+			log.Printf("Snapshot from %s rejected: %v\n", address, err)
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", address, err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// fetchSnapshotFrom downloads and verifies the snapshot served by address
+func (p *P2PServer) fetchSnapshotFrom(address string, trust snapshot.Trust) (*FetchedSnapshot, error) {
+	height := -1
+	if len(trust.Signers) == 0 && trust.Checkpoint != nil {
+		height = trust.Checkpoint.Height
+	}
+	manifest, err := p.fetchManifest(address, height)
+	if err != nil {
+		return nil, err
+	}
+	if err := trust.Check(manifest); err != nil {
+		return nil, err
+	}
+	if manifest.GenesisHash != p.chain.Genesis().Hash {
+		return nil, blockchain.ErrGenesisMismatch
+	}
+
+	target := manifest.Height
+	if trust.Checkpoint != nil && trust.Checkpoint.Height > target {
+		target = trust.Checkpoint.Height
+	}
+	headers, err := p.fetchHeaderChain(address, target)
+	if err != nil {
+		p.penalize(address, err)
+		return nil, err
+	}
+	if headers[0].Hash != manifest.GenesisHash || headers[manifest.Height].Hash != manifest.TipHash {
+		return nil, errors.New("snapshot is not on the peer's header chain")
+	}
+	if trust.Checkpoint != nil && headers[trust.Checkpoint.Height].Hash != trust.Checkpoint.Hash {
+		return nil, fmt.Errorf("peer's chain doesn't pass checkpoint %s", trust.Checkpoint)
+	}
+
+	chunks := make(map[string][]byte, len(manifest.Chunks))
+	for _, info := range manifest.Chunks {
+		data, err := p.fetchSnapshotChunk(address, info)
+		if err != nil {
+			return nil, err
+		}
+		chunks[info.Hash] = data
+	}
+	state, err := snapshot.Decode(manifest, chunks)
+	if err != nil {
+		p.penalize(address, fmt.Errorf("%w: %v", errInvalidBlock, err))
+		return nil, err
+	}
+
+	blocks, err := p.assembleSnapshot(headers[:manifest.Height+1], state.Blocks)
+	if err != nil {
+		p.penalize(address, err)
+		return nil, err
+	}
+	return &FetchedSnapshot{Manifest: manifest, State: state, Blocks: blocks, Peer: address}, nil
+}
+
+// assembleSnapshot combines the headers of the chain with the whole blocks
+// of a snapshot, which must hash to the headers at their heights
+func (p *P2PServer) assembleSnapshot(headers []blockchain.BlockHeader, whole []blockchain.Block) ([]blockchain.Block, error) {
+	blocks := make([]blockchain.Block, len(headers))
+	for i, header := range headers {
+		blocks[i] = blockchain.PrunedBlock(header)
+	}
+	for i, block := range whole {
+		if block.Index >= len(headers) || headers[block.Index].Hash != block.Hash || !blockchain.HashMatches(block) {
+			return nil, fmt.Errorf("%w: snapshot block %d doesn't match its header", errInvalidBlock, block.Index)
+		}
+		if i > 0 {
+			parent := whole[i-1]
+			if parent.Index+1 == block.Index {
+				if err := p.validateBlock(block, &parent); err != nil {
+					return nil, err
+				}
+			}
+		}
+		blocks[block.Index] = block
+	}
+	return blocks, nil
+}
+
+// fetchManifest fetches the manifest of the snapshot a peer serves at
+// height, or at its tip when height is negative
+func (p *P2PServer) fetchManifest(address string, height int) (snapshot.Manifest, error) {
+	path := "/snapshot"
+	if height >= 0 {
+		path += "?height=" + strconv.Itoa(height)
+	}
+	resp, err := p.getPeer(p.httpClient, p.peerURL(address, path))
+	if err != nil {
+		return snapshot.Manifest{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return snapshot.Manifest{}, errNoSnapshot
+	}
+	if resp.StatusCode != http.StatusOK {
+		return snapshot.Manifest{}, fmt.Errorf("unexpected status from %s: %s", address, resp.Status)
+	}
+	var manifest snapshot.Manifest
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return snapshot.Manifest{}, fmt.Errorf("failed to decode snapshot manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// fetchSnapshotChunk downloads a chunk and checks it against the manifest
+func (p *P2PServer) fetchSnapshotChunk(address string, info snapshot.ChunkInfo) ([]byte, error) {
+	resp, err := p.getPeer(p.clientWithTimeout(fullChainTimeout), p.peerURL(address, "/snapshot/chunks/"+info.Hash))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status from %s: %s", address, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSnapshotChunkBytes))
+	if err != nil {
+		return nil, err
+	}
+	if err := snapshot.VerifyChunk(info, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// fetchHeaderChain downloads the headers of a peer's chain from genesis to
+// height, checking that they link and meet the consensus rules
+func (p *P2PServer) fetchHeaderChain(address string, height int) ([]blockchain.BlockHeader, error) {
+	headers := make([]blockchain.BlockHeader, 0, height+1)
+	for len(headers) <= height {
+		batch, err := p.fetchHeaders(address, len(headers)-1)
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			return nil, fmt.Errorf("peer's chain ends at %d, below %d", len(headers)-1, height)
+		}
+		for _, header := range batch {
+			var parent *blockchain.BlockHeader
+			if n := len(headers); n > 0 {
+				parent = &headers[n-1]
+				if header.PrevHash != parent.Hash {
+					return nil, fmt.Errorf("%w: header %d doesn't link to its parent", errInvalidBlock, header.Index)
+				}
+			}
+			if header.Index != len(headers) {
+				return nil, fmt.Errorf("%w: header %d out of order", errInvalidBlock, header.Index)
+			}
+			if err := p.validateHeader(header, parent); err != nil {
+				return nil, err
+			}
+			headers = append(headers, header)
+		}
+	}
+	return headers[:height+1], nil
+}
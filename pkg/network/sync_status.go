@@ -0,0 +1,149 @@
+package network
+
+import (
+	"sync"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/node/lifecycle"
+)
+
+// SyncState describes whether the node is catching up with peers
+type SyncState string
+
+const (
+	// SyncIdle means no sync is in progress
+	SyncIdle SyncState = "idle"
+	// SyncSyncing means blocks are being downloaded from a peer
+	SyncSyncing SyncState = "syncing"
+)
+
+// syncProgressInterval throttles how often progress listeners are notified
+const syncProgressInterval = 500 * time.Millisecond
+
+// SyncStatus reports the progress of block synchronization
+type SyncStatus struct {
+	State                     SyncState `json:"state"`
+	StartHeight               int       `json:"startHeight"`
+	CurrentHeight             int       `json:"currentHeight"`
+	TargetHeight              int       `json:"targetHeight"`
+	BlocksPerSecond           float64   `json:"blocksPerSecond"`
+	EstimatedRemainingSeconds float64   `json:"estimatedRemainingSeconds"`
+}
+
+// syncTracker maintains the sync status and notifies listeners of progress
+type syncTracker struct {
+	status    SyncStatus
+	startedAt time.Time
+	lastEmit  time.Time
+	listeners []func(SyncStatus)
+	progress  lifecycle.ProgressFunc // Set while a bootstrap sync is reporting progress
+	mutex     sync.Mutex
+}
+
+// newSyncTracker creates an idle tracker
+func newSyncTracker() *syncTracker {
+	return &syncTracker{status: SyncStatus{State: SyncIdle}}
+}
+
+// Status returns the current sync status
+func (t *syncTracker) Status() SyncStatus {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.status
+}
+
+// Subscribe registers a listener for throttled progress updates
+func (t *syncTracker) Subscribe(fn func(SyncStatus)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.listeners = append(t.listeners, fn)
+}
+
+// setProgressFunc installs or clears the bootstrap progress callback
+func (t *syncTracker) setProgressFunc(fn lifecycle.ProgressFunc) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.progress = fn
+}
+
+// begin starts tracking a sync from the current height toward a target
+func (t *syncTracker) begin(start, target int) {
+	t.mutex.Lock()
+	t.startedAt = time.Now()
+	t.status = SyncStatus{
+		State:         SyncSyncing,
+		StartHeight:   start,
+		CurrentHeight: start,
+		TargetHeight:  target,
+	}
+	t.notify(true)
+}
+
+// advance records the height reached so far
+func (t *syncTracker) advance(current int) {
+	t.mutex.Lock()
+	t.status.CurrentHeight = current
+
+	elapsed := time.Since(t.startedAt).Seconds()
+	synced := current - t.status.StartHeight
+	if elapsed > 0 && synced > 0 {
+		t.status.BlocksPerSecond = float64(synced) / elapsed
+		remaining := t.status.TargetHeight - current
+		if remaining < 0 {
+			remaining = 0
+		}
+		t.status.EstimatedRemainingSeconds = float64(remaining) / t.status.BlocksPerSecond
+	}
+	t.notify(false)
+}
+
+// finish marks the sync as complete and returns to idle
+func (t *syncTracker) finish() {
+	t.mutex.Lock()
+	t.status.State = SyncIdle
+	t.status.EstimatedRemainingSeconds = 0
+	t.notify(true)
+}
+
+// notify releases the lock and calls listeners, skipping updates that
+// arrive within the throttle interval unless forced
+func (t *syncTracker) notify(force bool) {
+	status := t.status
+	progress := t.progress
+	emit := force || time.Since(t.lastEmit) >= syncProgressInterval
+	if emit {
+		t.lastEmit = time.Now()
+	}
+	listeners := append([]func(SyncStatus){}, t.listeners...)
+	t.mutex.Unlock()
+
+	if progress != nil {
+		progress(status.CurrentHeight, status.TargetHeight)
+	}
+	if !emit {
+		return
+	}
+	for _, fn := range listeners {
+		fn(status)
+	}
+}
+
+// SyncStatus returns the current block sync progress
+func (p *P2PServer) SyncStatus() SyncStatus {
+	return p.syncTracker.Status()
+}
+
+// OnSyncProgress registers a listener for throttled sync progress updates
+func (p *P2PServer) OnSyncProgress(fn func(SyncStatus)) {
+	p.syncTracker.Subscribe(fn)
+}
+
+// Sync performs a single catch-up round with peers, reporting progress. It
+// lets the P2P server act as the node's bootstrap syncer.
+func (p *P2PServer) Sync(progress lifecycle.ProgressFunc) error {
+	p.syncTracker.setProgressFunc(progress)
+	defer p.syncTracker.setProgressFunc(nil)
+
+	p.syncWithPeers()
+	return nil
+}
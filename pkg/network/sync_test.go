@@ -0,0 +1,152 @@
+package network
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// requestCounter counts the requests a stub peer serves by kind
+type requestCounter struct {
+	mutex  sync.Mutex
+	counts map[string]int
+}
+
+func (c *requestCounter) count(kind string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.counts[kind]
+}
+
+// stubPeer serves p's P2P routes, counting /height, ranged /sync and
+// full-chain /sync requests, and returns its address
+func stubPeer(t *testing.T, p *P2PServer) (string, *requestCounter) {
+	t.Helper()
+	mux := http.NewServeMux()
+	p.RegisterRoutes(mux)
+	counter := &requestCounter{counts: make(map[string]int)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		kind := r.URL.Path
+		if kind == "/sync" && r.URL.Query().Has("from_index") {
+			kind = "/sync?from_index"
+		}
+		counter.mutex.Lock()
+		counter.counts[kind]++
+		counter.mutex.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://"), counter
+}
+
+// growChain appends n empty blocks to a chain
+func growChain(t *testing.T, chain *blockchain.Chain, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if _, err := chain.AddBlock(nil, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSyncCatchesUpWithRangeRequests(t *testing.T) {
+	ahead, behind := newTestServer(t), newTestServer(t)
+	growChain(t, ahead.chain, 300)
+	address, counter := stubPeer(t, ahead)
+	behind.AddPeer(address)
+
+	behind.syncWithPeers()
+
+	if got := behind.chain.GetLatestBlock(); got.Hash != ahead.chain.GetLatestBlock().Hash {
+		t.Fatalf("synced to height %d, want %d", got.Index, ahead.chain.Height())
+	}
+	if n := counter.count("/height"); n != 1 {
+		t.Errorf("asked for the height %d times, want 1", n)
+	}
+	// 300 blocks in batches of maxSyncBatch
+	if n, want := counter.count("/sync?from_index"), (300+maxSyncBatch-1)/maxSyncBatch; n != want {
+		t.Errorf("made %d range requests, want %d", n, want)
+	}
+	if n := counter.count("/sync"); n != 0 {
+		t.Errorf("downloaded the full chain %d times", n)
+	}
+
+	// Once caught up, a sync only asks for the height
+	behind.syncWithPeers()
+	if n := counter.count("/height"); n != 2 {
+		t.Errorf("asked for the height %d times, want 2", n)
+	}
+	if n := counter.count("/sync?from_index") + counter.count("/sync"); n != (300+maxSyncBatch-1)/maxSyncBatch {
+		t.Errorf("synced again without falling behind")
+	}
+}
+
+func TestSyncReorganizesOntoDivergedPeer(t *testing.T) {
+	ahead, behind := newTestServer(t), newTestServer(t)
+	growChain(t, ahead.chain, 10)
+
+	pool := blockchain.NewTransactionPool(10)
+	if err := pool.AddTransaction(&blockchain.Transaction{ID: "local", To: "bob", Timestamp: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := behind.chain.AddBlock(pool, 0); err != nil {
+		t.Fatal(err)
+	}
+	growChain(t, behind.chain, 1)
+
+	address, _ := stubPeer(t, ahead)
+	behind.AddPeer(address)
+	behind.syncWithPeers()
+
+	if got, want := behind.chain.GetLatestBlock(), ahead.chain.GetLatestBlock(); got.Hash != want.Hash {
+		t.Fatalf("tip is block %d %s, want the peer's %d %s", got.Index, got.Hash, want.Index, want.Hash)
+	}
+}
+
+func TestSyncReportsProgress(t *testing.T) {
+	ahead, behind := newTestServer(t), newTestServer(t)
+	growChain(t, ahead.chain, 300)
+	address, _ := stubPeer(t, ahead)
+	behind.AddPeer(address)
+
+	var statuses []SyncStatus
+	behind.OnSyncProgress(func(s SyncStatus) { statuses = append(statuses, s) })
+	var heights []int
+	err := behind.Sync(func(current, target int) {
+		if target != 300 {
+			t.Errorf("progress toward height %d, want 300", target)
+		}
+		heights = append(heights, current)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(heights) < 2 {
+		t.Fatalf("reported progress %d times, want several", len(heights))
+	}
+	for i := 1; i < len(heights); i++ {
+		if heights[i] < heights[i-1] {
+			t.Fatalf("progress went back from %d to %d", heights[i-1], heights[i])
+		}
+	}
+	if last := heights[len(heights)-1]; last != 300 {
+		t.Errorf("progress ended at %d, want 300", last)
+	}
+
+	if len(statuses) < 2 || statuses[0].State != SyncSyncing || statuses[0].StartHeight != 0 {
+		t.Fatalf("listener saw %+v, want a start from height 0", statuses)
+	}
+	status := behind.SyncStatus()
+	if status.State != SyncIdle || status.CurrentHeight != 300 || status.TargetHeight != 300 || status.EstimatedRemainingSeconds != 0 {
+		t.Errorf("final status %+v, want idle at 300", status)
+	}
+	if statuses[len(statuses)-1] != status {
+		t.Errorf("listener last saw %+v, want the final status", statuses[len(statuses)-1])
+	}
+}
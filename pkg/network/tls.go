@@ -0,0 +1,149 @@
+package network
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// capabilityTLS is advertised in the handshake by nodes serving P2P over HTTPS
+const capabilityTLS = "tls"
+
+// Peer URL schemes
+const (
+	schemeHTTP  = "http"
+	schemeHTTPS = "https"
+)
+
+// errCertificateNotPinned is returned when a peer presents a certificate
+// whose fingerprint isn't pinned
+var errCertificateNotPinned = errors.New("peer certificate fingerprint is not pinned")
+
+// PeerTLSConfig configures TLS for peer-to-peer traffic
+type PeerTLSConfig struct {
+	// CertFile and KeyFile enable serving P2P routes over HTTPS
+	CertFile string
+	KeyFile  string
+	// CAFile is a PEM bundle of roots trusted for peer certificates; the
+	// system roots are used when empty
+	CAFile string
+	// PinnedFingerprints are hex SHA-256 digests of accepted peer leaf
+	// certificates. When set, only these certificates are accepted, which
+	// suits private networks with self-signed certificates.
+	PinnedFingerprints []string
+}
+
+// CertificateFingerprint returns the hex SHA-256 digest used for pinning
+func CertificateFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// ConfigureTLS sets up HTTPS for outbound peer requests and, when a
+// certificate is given, for the P2P listener
+func (p *P2PServer) ConfigureTLS(cfg PeerTLSConfig) error {
+	client := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+		client.RootCAs = pool
+	}
+
+	if len(cfg.PinnedFingerprints) > 0 {
+		pins := make(map[string]bool, len(cfg.PinnedFingerprints))
+		for _, pin := range cfg.PinnedFingerprints {
+			pins[strings.ToLower(strings.ReplaceAll(pin, ":", ""))] = true
+		}
+		// Chain verification is replaced by the fingerprint check, since
+		// pinned certificates are typically self-signed
+		client.InsecureSkipVerify = true
+		client.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 || !pins[CertificateFingerprint(rawCerts[0])] {
+				return errCertificateNotPinned
+			}
+			return nil
+		}
+	}
+
+	var server *tls.Config
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load P2P certificate: %w", err)
+		}
+		server = &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{cert},
+		}
+	}
+
+	p.clientTLS = client
+	p.serverTLS = server
+	p.buildClient()
+	return nil
+}
+
+// peerScheme returns the scheme for reaching a peer: the one recorded at
+// its handshake, or our own scheme for peers we haven't handshaken with
+func (p *P2PServer) peerScheme(address string) string {
+	p.peersMutex.Lock()
+	scheme := p.peers[address].Scheme
+	p.peersMutex.Unlock()
+
+	if scheme != "" {
+		return scheme
+	}
+	if p.serverTLS != nil {
+		return schemeHTTPS
+	}
+	return schemeHTTP
+}
+
+// setPeerScheme records how a peer must be reached
+func (p *P2PServer) setPeerScheme(address, scheme string) {
+	p.peersMutex.Lock()
+	defer p.peersMutex.Unlock()
+	if peer, ok := p.peers[address]; ok {
+		peer.Scheme = scheme
+		p.peers[address] = peer
+	}
+}
+
+// peerURL builds the URL of a P2P endpoint on a peer
+func (p *P2PServer) peerURL(address, path string) string {
+	return fmt.Sprintf("%s://%s%s", p.peerScheme(address), address, path)
+}
+
+// otherScheme returns the alternative scheme to try when a peer can't be
+// reached with the first
+func otherScheme(scheme string) string {
+	if scheme == schemeHTTPS {
+		return schemeHTTP
+	}
+	return schemeHTTPS
+}
+
+// isSchemeMismatch reports whether a request failed because the peer speaks
+// the other scheme: plain HTTP answering a TLS client, or a TLS server
+// rejecting a plain request
+func isSchemeMismatch(resp *http.Response, err error) bool {
+	if err != nil {
+		var recordErr tls.RecordHeaderError
+		return errors.As(err, &recordErr) || strings.Contains(err.Error(), "server gave HTTP response to HTTPS client")
+	}
+	// A TLS listener answers plain requests with a bare HTTP/1.0 400
+	return resp.StatusCode == http.StatusBadRequest && resp.Proto == "HTTP/1.0" && resp.Header.Get("Content-Type") == ""
+}
@@ -0,0 +1,108 @@
+package network
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// selfSignedCert writes a self-signed certificate for 127.0.0.1 and its key,
+// returning their paths and the certificate's fingerprint
+func selfSignedCert(t *testing.T) (string, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "peer"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile, CertificateFingerprint(der)
+}
+
+// newTLSServer is a test server serving its P2P routes over HTTPS with a
+// self-signed certificate, trusting only the pinned peer certificates
+func newTLSServer(t *testing.T, certFile, keyFile string, pins ...string) *P2PServer {
+	t.Helper()
+	p := newTestServer(t)
+	if err := p.ConfigureTLS(PeerTLSConfig{CertFile: certFile, KeyFile: keyFile, PinnedFingerprints: pins}); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	p.RegisterRoutes(mux)
+	server := httptest.NewUnstartedServer(mux)
+	server.TLS = p.serverTLS
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	p.SetAdvertisedAddress(strings.TrimPrefix(server.URL, "https://"))
+	return p
+}
+
+func TestSyncOverPinnedTLS(t *testing.T) {
+	aheadCert, aheadKey, aheadPin := selfSignedCert(t)
+	behindCert, behindKey, behindPin := selfSignedCert(t)
+	ahead := newTLSServer(t, aheadCert, aheadKey, behindPin)
+	behind := newTLSServer(t, behindCert, behindKey, aheadPin)
+	growChain(t, ahead.chain, 5)
+
+	if err := behind.ConnectPeer(ahead.address); err != nil {
+		t.Fatal(err)
+	}
+	if scheme := behind.peerScheme(ahead.address); scheme != schemeHTTPS {
+		t.Fatalf("recorded scheme %q for a TLS peer", scheme)
+	}
+	behind.syncWithPeers()
+	if got := behind.chain.GetLatestBlock(); got.Hash != ahead.chain.GetLatestBlock().Hash {
+		t.Fatalf("synced to height %d over TLS, want 5", got.Index)
+	}
+}
+
+func TestPinnedTLSRejectsOtherCertificate(t *testing.T) {
+	peerCert, peerKey, _ := selfSignedCert(t)
+	ownCert, ownKey, _ := selfSignedCert(t)
+	_, _, otherPin := selfSignedCert(t)
+	peer := newTLSServer(t, peerCert, peerKey)
+	p := newTLSServer(t, ownCert, ownKey, otherPin)
+
+	err := p.ConnectPeer(peer.address)
+	if !errors.Is(err, errCertificateNotPinned) {
+		t.Fatalf("got %v, want errCertificateNotPinned", err)
+	}
+	if boundNodeID(p, peer.address) != "" {
+		t.Fatal("bound the identity of a peer with an unpinned certificate")
+	}
+}
@@ -0,0 +1,31 @@
+package network
+
+import (
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// Transport is a network backend. The HTTP P2PServer and the optional
+// libp2p backend both implement it so the rest of the node doesn't depend
+// on which one is active.
+type Transport interface {
+	// Start begins background peer discovery and synchronization
+	Start()
+
+	// ListenAndServe accepts inbound peer connections until the transport stops
+	ListenAndServe() error
+
+	// BroadcastBlock sends a locally produced block to the network
+	BroadcastBlock(block blockchain.Block)
+
+	// BroadcastTransaction sends a locally submitted transaction to the network
+	BroadcastTransaction(tx *blockchain.Transaction)
+
+	// PeerCount returns the number of connected or known peers
+	PeerCount() int
+
+	// NodeID returns this node's identifier on the network
+	NodeID() string
+}
+
+// Ensure P2PServer satisfies Transport
+var _ Transport = (*P2PServer)(nil)
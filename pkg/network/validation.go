@@ -0,0 +1,102 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/consensus"
+)
+
+// errInvalidBlock is returned when a peer's block breaks the consensus rules
+var errInvalidBlock = errors.New("block violates consensus rules")
+
+// SetConsensus sets the algorithm that blocks from peers must satisfy
+func (p *P2PServer) SetConsensus(algorithm consensus.Algorithm) {
+	p.consensus = algorithm
+}
+
+// validateBlock checks a block received from a peer before it reaches the
+// chain: its contents must hash to its hash, its merkle root must match its
+// transactions, and it must follow the header rules of validateHeader.
+func (p *P2PServer) validateBlock(block blockchain.Block, parent *blockchain.Block) error {
+	if !blockchain.HashMatches(block) {
+		return fmt.Errorf("%w: block %d has a bad hash", errInvalidBlock, block.Index)
+	}
+	var parentTime *int64
+	if parent != nil {
+		parentTime = &parent.Timestamp
+	}
+	return p.checkBlockRules(block, parentTime)
+}
+
+// validateHeader checks a header received from a peer: its hash must meet
+// the difficulty it claims and the consensus algorithm, and its timestamp
+// must pass blockchain.CheckTimestamp against the parent's when it is
+// known. The hash of a header from version 5 must be recomputed from the
+// header; older headers don't carry what their hash covers.
+func (p *P2PServer) validateHeader(header blockchain.BlockHeader, parent *blockchain.BlockHeader) error {
+	if header.Version >= 5 {
+		if hash, ok := blockchain.HeaderHash(header); !ok || hash != header.Hash {
+			return fmt.Errorf("%w: header %d has a bad hash", errInvalidBlock, header.Index)
+		}
+	}
+	var parentTime *int64
+	if parent != nil {
+		parentTime = &parent.Timestamp
+	}
+	return p.checkBlockRules(blockchain.Block{BlockHeader: header}, parentTime)
+}
+
+// checkBlockRules applies the difficulty, consensus, and timestamp rules.
+// Genesis blocks are exempt. The difficulty comes from the peer, so it is
+// bounded by the hash length before the hash is checked against it.
+func (p *P2PServer) checkBlockRules(block blockchain.Block, parentTime *int64) error {
+	if block.Index == 0 {
+		return nil
+	}
+	if block.Difficulty < 0 || block.Difficulty > len(block.Hash) {
+		return fmt.Errorf("%w: block %d has difficulty %d out of range", errInvalidBlock, block.Index, block.Difficulty)
+	}
+	if !blockchain.IsHashValid(block.Hash, block.Difficulty) {
+		return fmt.Errorf("%w: block %d doesn't meet its difficulty %d", errInvalidBlock, block.Index, block.Difficulty)
+	}
+	if p.consensus != nil && !p.consensus.ValidateBlock(block) {
+		return fmt.Errorf("%w: block %d rejected by consensus", errInvalidBlock, block.Index)
+	}
+
+	if err := blockchain.CheckTimestamp(block.Timestamp, parentTime, time.Now()); err != nil {
+		return fmt.Errorf("%w: block %d: %v", errInvalidBlock, block.Index, err)
+	}
+	return nil
+}
+
+// validateBlocks checks a run of consecutive blocks, the first following
+// parent when it is known
+func (p *P2PServer) validateBlocks(blocks []blockchain.Block, parent *blockchain.Block) error {
+	for i := range blocks {
+		if err := p.validateBlock(blocks[i], parent); err != nil {
+			return err
+		}
+		parent = &blocks[i]
+	}
+	return nil
+}
+
+// penalize counts an invalid block against the peer that sent it, which
+// leads to a ban from the rate-limited routes after repeated offences
+func (p *P2PServer) penalize(address string, err error) {
+	p.reportInvalid()
+	log.Printf("Rejected block from %s: %v\n", address, err)
+
+	key := address
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		key = host
+	}
+	if p.limiter.RecordViolation(key) {
+		log.Printf("Banned peer %s for sending invalid blocks\n", address)
+	}
+}
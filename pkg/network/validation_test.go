@@ -0,0 +1,128 @@
+package network
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/consensus"
+)
+
+// newTestServer returns a P2P server on a fresh chain that isn't listening
+func newTestServer(t *testing.T) *P2PServer {
+	t.Helper()
+	p := NewP2PServer(blockchain.NewBlockchain(), "0", IntervalConfig{})
+	t.Cleanup(p.cancel)
+	return p
+}
+
+// mine mines a block on parent or fails the test
+func mine(t *testing.T, parent blockchain.Block, difficulty int, opts ...blockchain.MineOption) blockchain.Block {
+	t.Helper()
+	block, _, err := blockchain.GenerateBlock(parent, nil, difficulty, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+func TestValidateBlockAcceptsValidBlock(t *testing.T) {
+	p := newTestServer(t)
+	p.SetConsensus(consensus.NewProofOfWork(1))
+	genesis := p.chain.GetLatestBlock()
+
+	block := mine(t, genesis, 1)
+	if err := p.validateBlock(block, &genesis); err != nil {
+		t.Fatalf("valid block rejected: %v", err)
+	}
+}
+
+func TestValidateBlockRejectsLowDifficulty(t *testing.T) {
+	p := newTestServer(t)
+	p.SetConsensus(consensus.NewProofOfWork(3))
+	genesis := p.chain.GetLatestBlock()
+
+	block := mine(t, genesis, 0)
+	if block.Hash[0] == '0' && block.Hash[1] == '0' && block.Hash[2] == '0' {
+		t.Skip("difficulty-0 block happened to meet difficulty 3")
+	}
+	if err := p.validateBlock(block, &genesis); !errors.Is(err, errInvalidBlock) {
+		t.Fatalf("got %v, want errInvalidBlock", err)
+	}
+}
+
+func TestValidateBlockRejectsDifficultyOutOfRange(t *testing.T) {
+	p := newTestServer(t)
+	genesis := p.chain.GetLatestBlock()
+	block := mine(t, genesis, 0)
+
+	for _, difficulty := range []int{-1, len(block.Hash) + 1, 1 << 40} {
+		forged := block
+		forged.Difficulty = difficulty
+		forged.Hash = blockchain.CalculateHash(forged)
+		if err := p.validateBlock(forged, &genesis); !errors.Is(err, errInvalidBlock) {
+			t.Errorf("difficulty %d: got %v, want errInvalidBlock", difficulty, err)
+		}
+		if err := p.validateHeader(forged.BlockHeader, &genesis.BlockHeader); !errors.Is(err, errInvalidBlock) {
+			t.Errorf("header difficulty %d: got %v, want errInvalidBlock", difficulty, err)
+		}
+	}
+}
+
+func TestValidateBlockRejectsUnsignedProofOfStakeBlock(t *testing.T) {
+	p := newTestServer(t)
+	p.SetConsensus(consensus.NewProofOfStake(0))
+	genesis := p.chain.GetLatestBlock()
+
+	block := mine(t, genesis, 0)
+	if err := p.validateBlock(block, &genesis); !errors.Is(err, errInvalidBlock) {
+		t.Fatalf("got %v, want errInvalidBlock", err)
+	}
+
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := mine(t, genesis, 0, blockchain.WithSigner(key))
+	if err := p.validateBlock(signed, &genesis); err != nil {
+		t.Fatalf("signed block rejected: %v", err)
+	}
+}
+
+func TestRejectedBlockDoesNotBlockLaterBlock(t *testing.T) {
+	p := newTestServer(t)
+	p.SetConsensus(consensus.NewProofOfStake(0))
+	genesis := p.chain.GetLatestBlock()
+
+	gossip := func(block blockchain.Block) int {
+		payload, err := json.Marshal(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		status, _ := p.processBlockGossip(GossipMessage{Type: gossipBlock, Hops: 1, Payload: payload}, "127.0.0.1:1")
+		return status
+	}
+
+	unsigned := mine(t, genesis, 0)
+	if status := gossip(unsigned); status != http.StatusConflict {
+		t.Fatalf("unsigned block: status %d, want %d", status, http.StatusConflict)
+	}
+	if p.knownBlocks.Contains(unsigned.Hash) {
+		t.Fatal("rejected block remembered as known")
+	}
+
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := mine(t, genesis, 0, blockchain.WithSigner(key))
+	if status := gossip(signed); status != http.StatusOK {
+		t.Fatalf("signed block: status %d, want %d", status, http.StatusOK)
+	}
+	if p.chain.Height() != 1 {
+		t.Fatalf("height %d after a valid block, want 1", p.chain.Height())
+	}
+}
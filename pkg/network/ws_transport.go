@@ -0,0 +1,414 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/gorilla/websocket"
+)
+
+// capabilityWebSocket is advertised in the handshake by nodes that accept
+// persistent WebSocket peer sessions
+const capabilityWebSocket = "ws"
+
+// Timing for persistent peer sessions
+const (
+	sessionPingInterval   = 15 * time.Second
+	sessionReadTimeout    = 45 * time.Second
+	sessionRequestTimeout = 10 * time.Second
+	sessionRetryInitial   = 1 * time.Second
+	sessionRetryMax       = 1 * time.Minute
+)
+
+// Peer session frame types
+const (
+	frameHello        = "hello"
+	frameGossip       = "gossip"
+	frameSyncRequest  = "sync_request"
+	frameSyncResponse = "sync_response"
+	framePing         = "ping"
+	framePong         = "pong"
+)
+
+// sessionDialer opens outbound peer sessions with per-message compression
+var sessionDialer = &websocket.Dialer{
+	Proxy:             http.ProxyFromEnvironment,
+	HandshakeTimeout:  sessionRequestTimeout,
+	EnableCompression: true,
+}
+
+// errSessionClosed is returned when sending on a closed session
+var errSessionClosed = errors.New("peer session closed")
+
+// peerFrame is a typed message exchanged over a peer session
+type peerFrame struct {
+	Type      string             `json:"type"`
+	RequestID string             `json:"requestId,omitempty"`
+	Hello     *handshake         `json:"hello,omitempty"`
+	Gossip    *GossipMessage     `json:"gossip,omitempty"`
+	FromIndex int                `json:"fromIndex"`
+	Count     int                `json:"count,omitempty"`
+	Blocks    []blockchain.Block `json:"blocks,omitempty"`
+}
+
+// peerSession is a persistent WebSocket connection to a peer
+type peerSession struct {
+	address      string
+	conn         *websocket.Conn
+	writeMutex   sync.Mutex
+	pending      map[string]chan peerFrame
+	pendingMutex sync.Mutex
+	closeOnce    sync.Once
+	closed       chan struct{}
+	onSend       func(int64) // Reports the size of each written frame
+}
+
+// newPeerSession wraps an established connection
+func newPeerSession(address string, conn *websocket.Conn, onSend func(int64)) *peerSession {
+	conn.SetReadLimit(maxBlockMessageBytes)
+	return &peerSession{
+		address: address,
+		conn:    conn,
+		pending: make(map[string]chan peerFrame),
+		closed:  make(chan struct{}),
+		onSend:  onSend,
+	}
+}
+
+// send writes a frame; writes are serialized since a connection allows one writer
+func (s *peerSession) send(frame peerFrame) error {
+	select {
+	case <-s.closed:
+		return errSessionClosed
+	default:
+	}
+
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(sessionRequestTimeout))
+	if err := s.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		return err
+	}
+	s.onSend(int64(len(data)))
+	return nil
+}
+
+// request sends a frame and waits for the response with the same request ID
+func (s *peerSession) request(frame peerFrame) (peerFrame, error) {
+	frame.RequestID = newMessageID()
+	reply := make(chan peerFrame, 1)
+
+	s.pendingMutex.Lock()
+	s.pending[frame.RequestID] = reply
+	s.pendingMutex.Unlock()
+	defer func() {
+		s.pendingMutex.Lock()
+		delete(s.pending, frame.RequestID)
+		s.pendingMutex.Unlock()
+	}()
+
+	if err := s.send(frame); err != nil {
+		return peerFrame{}, err
+	}
+
+	select {
+	case response := <-reply:
+		return response, nil
+	case <-s.closed:
+		return peerFrame{}, errSessionClosed
+	case <-time.After(sessionRequestTimeout):
+		return peerFrame{}, fmt.Errorf("request to %s timed out", s.address)
+	}
+}
+
+// deliver hands a response frame to the waiting request, if any
+func (s *peerSession) deliver(frame peerFrame) {
+	s.pendingMutex.Lock()
+	reply, ok := s.pending[frame.RequestID]
+	s.pendingMutex.Unlock()
+	if ok {
+		reply <- frame
+	}
+}
+
+// close shuts the connection down
+func (s *peerSession) close() {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		s.conn.Close()
+	})
+}
+
+// EnableWebSocketTransport makes the server accept and establish persistent
+// WebSocket sessions with peers that advertise support. Peers without it keep
+// using the HTTP endpoints.
+func (p *P2PServer) EnableWebSocketTransport() {
+	p.wsEnabled = true
+}
+
+// session returns the live session for a peer, if any
+func (p *P2PServer) session(address string) *peerSession {
+	p.sessionsMutex.Lock()
+	defer p.sessionsMutex.Unlock()
+	return p.sessions[address]
+}
+
+// addSession registers a session, replacing any previous one for the peer
+func (p *P2PServer) addSession(session *peerSession) {
+	p.sessionsMutex.Lock()
+	if p.ctx.Err() != nil {
+		p.sessionsMutex.Unlock()
+		session.close()
+		return
+	}
+	old := p.sessions[session.address]
+	p.sessions[session.address] = session
+	p.sessionsMutex.Unlock()
+
+	if old != nil {
+		old.close()
+	}
+}
+
+// removeSession forgets a session if it is still the current one for its peer
+func (p *P2PServer) removeSession(session *peerSession) {
+	p.sessionsMutex.Lock()
+	if p.sessions[session.address] == session {
+		delete(p.sessions, session.address)
+	}
+	p.sessionsMutex.Unlock()
+	session.close()
+}
+
+// handlePeerSocket accepts an inbound peer session
+func (p *P2PServer) handlePeerSocket(w http.ResponseWriter, r *http.Request) {
+	if !p.wsEnabled {
+		http.Error(w, "WebSocket transport disabled", http.StatusNotFound)
+		return
+	}
+
+	conn, err := p.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Peer WebSocket upgrade error: %v\n", err)
+		return
+	}
+
+	// The first frame must introduce the peer
+	conn.SetReadDeadline(time.Now().Add(sessionRequestTimeout))
+	var hello peerFrame
+	if err := conn.ReadJSON(&hello); err != nil || hello.Type != frameHello || hello.Hello == nil || hello.Hello.Address == "" {
+		conn.Close()
+		return
+	}
+	if hello.Hello.NodeID == p.nodeID {
+		conn.Close()
+		return
+	}
+	address, err := p.admitPeer(r.Context(), *hello.Hello)
+	if err != nil {
+		log.Printf("Rejected peer session from %s: %v\n", hello.Hello.Address, err)
+		conn.Close()
+		return
+	}
+	hello.Hello.Address = address
+
+	session := newPeerSession(hello.Hello.Address, conn, p.reportBytesSent)
+	local := p.localHandshake()
+	if err := session.send(peerFrame{Type: frameHello, Hello: &local}); err != nil {
+		conn.Close()
+		return
+	}
+
+	p.addSession(session)
+	log.Printf("Accepted peer session from %s\n", session.address)
+
+	p.runSession(session)
+}
+
+// maintainSession keeps a session with a peer open, reconnecting with
+// backoff whenever it drops
+func (p *P2PServer) maintainSession(address string) {
+	p.sessionsMutex.Lock()
+	if p.dialing[address] {
+		p.sessionsMutex.Unlock()
+		return
+	}
+	p.dialing[address] = true
+	p.sessionsMutex.Unlock()
+
+	defer func() {
+		p.sessionsMutex.Lock()
+		delete(p.dialing, address)
+		p.sessionsMutex.Unlock()
+	}()
+
+	delay := sessionRetryInitial
+	for {
+		if p.session(address) == nil {
+			session, err := p.dialSession(address)
+			if errors.Is(err, errNetworkMismatch) {
+				log.Printf("Peer session to %s abandoned: %v\n", address, err)
+				return
+			}
+			if err != nil {
+				log.Printf("Peer session to %s failed, retrying in %s: %v\n", address, delay, err)
+				if !p.sleep(delay) {
+					return
+				}
+				delay *= 2
+				if delay > sessionRetryMax {
+					delay = sessionRetryMax
+				}
+				continue
+			}
+			delay = sessionRetryInitial
+			p.addSession(session)
+			log.Printf("Opened peer session to %s\n", address)
+			p.runSession(session)
+		} else if !p.sleep(sessionPingInterval) {
+			return
+		}
+		if p.ctx.Err() != nil {
+			return
+		}
+
+		// Stop reconnecting once the peer has been forgotten
+		p.peersMutex.Lock()
+		_, known := p.peers[address]
+		p.peersMutex.Unlock()
+		if !known {
+			return
+		}
+	}
+}
+
+// dialSession opens an outbound session and exchanges hellos
+func (p *P2PServer) dialSession(address string) (*peerSession, error) {
+	scheme := "ws"
+	if p.peerScheme(address) == schemeHTTPS {
+		scheme = "wss"
+	}
+	dialer := *sessionDialer
+	dialer.TLSClientConfig = p.clientTLS
+	conn, resp, err := dialer.DialContext(p.ctx, fmt.Sprintf("%s://%s/p2p", scheme, address), p.peerHeaders())
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusConflict {
+			if theirs := resp.Header.Get(networkIDHeader); theirs != "" && theirs != p.networkID {
+				p.removePeer(address)
+				return nil, fmt.Errorf("%w: %s is on %q", errNetworkMismatch, address, theirs)
+			}
+		}
+		return nil, err
+	}
+
+	session := newPeerSession(address, conn, p.reportBytesSent)
+	local := p.localHandshake()
+	if err := session.send(peerFrame{Type: frameHello, Hello: &local}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(sessionRequestTimeout))
+	var hello peerFrame
+	if err := conn.ReadJSON(&hello); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if hello.Type != frameHello || hello.Hello == nil {
+		conn.Close()
+		return nil, errors.New("peer did not send hello")
+	}
+	if err := p.learnIdentity(*hello.Hello); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return session, nil
+}
+
+// runSession reads frames until the connection drops
+func (p *P2PServer) runSession(session *peerSession) {
+	defer p.removeSession(session)
+
+	go p.pingSession(session)
+
+	for {
+		session.conn.SetReadDeadline(time.Now().Add(sessionReadTimeout))
+		_, data, err := session.conn.ReadMessage()
+		if err != nil {
+			log.Printf("Peer session with %s closed: %v\n", session.address, err)
+			return
+		}
+		p.reportBytesReceived(int64(len(data)))
+		p.touchPeer(session.address)
+
+		var frame peerFrame
+		if err := json.Unmarshal(data, &frame); err != nil {
+			p.reportInvalid()
+			log.Printf("Peer session with %s sent a malformed frame: %v\n", session.address, err)
+			return
+		}
+
+		switch frame.Type {
+		case frameGossip:
+			if frame.Gossip != nil {
+				if _, err := p.processGossip(*frame.Gossip, session.address); err != nil {
+					log.Printf("Rejected gossip from %s: %v\n", session.address, err)
+				}
+			}
+		case frameSyncRequest:
+			// Light nodes don't serve ranges and answer with no blocks
+			var blocks []blockchain.Block
+			if !p.isLight() {
+				blocks = p.blocksAfter(frame.FromIndex, frame.Count)
+			}
+			session.send(peerFrame{
+				Type:      frameSyncResponse,
+				RequestID: frame.RequestID,
+				Blocks:    blocks,
+			})
+		case framePing:
+			session.send(peerFrame{Type: framePong})
+		case frameSyncResponse:
+			session.deliver(frame)
+		}
+	}
+}
+
+// pingSession keeps the session alive until it closes
+func (p *P2PServer) pingSession(session *peerSession) {
+	ticker := time.NewTicker(sessionPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-session.closed:
+			return
+		case <-ticker.C:
+			if err := session.send(peerFrame{Type: framePing}); err != nil {
+				session.close()
+				return
+			}
+		}
+	}
+}
+
+// touchPeer updates a peer's last-seen time
+func (p *P2PServer) touchPeer(address string) {
+	p.peersMutex.Lock()
+	defer p.peersMutex.Unlock()
+	if peer, ok := p.peers[address]; ok {
+		peer.LastSeen = time.Now()
+		p.peers[address] = peer
+	}
+}
@@ -0,0 +1,75 @@
+package network
+
+import (
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or fails the test after a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// newWebSocketServer is a listening test server on the WebSocket transport
+func newWebSocketServer(t *testing.T) *P2PServer {
+	t.Helper()
+	p := newTestServer(t)
+	p.EnableWebSocketTransport()
+	listen(t, p)
+	return p
+}
+
+func TestWebSocketSessionCarriesGossipAndReconnects(t *testing.T) {
+	a, b := newWebSocketServer(t), newWebSocketServer(t)
+	if err := b.ConnectPeer(a.address); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "sessions on both sides", func() bool {
+		return a.session(b.address) != nil && b.session(a.address) != nil
+	})
+
+	block := mine(t, a.chain.GetLatestBlock(), 0)
+	if err := a.chain.AddExistingBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	a.gossip(gossipBlock, block)
+	waitFor(t, "the block over the session", func() bool {
+		return b.chain.GetLatestBlock().Hash == block.Hash
+	})
+
+	// Dropping the connection makes b dial a again
+	dropped := b.session(a.address)
+	dropped.close()
+	waitFor(t, "a new session", func() bool {
+		s := b.session(a.address)
+		return s != nil && s != dropped
+	})
+}
+
+func TestWebSocketPeerFallsBackToHTTP(t *testing.T) {
+	ws := newWebSocketServer(t)
+	plain := newTestServer(t)
+	listen(t, plain)
+	if err := plain.ConnectPeer(ws.address); err != nil {
+		t.Fatal(err)
+	}
+	if ws.session(plain.address) != nil || plain.session(ws.address) != nil {
+		t.Fatal("opened a session with an HTTP-only peer")
+	}
+
+	block := mine(t, ws.chain.GetLatestBlock(), 0)
+	if err := ws.chain.AddExistingBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	ws.gossip(gossipBlock, block)
+	waitFor(t, "the block over HTTP", func() bool {
+		return plain.chain.GetLatestBlock().Hash == block.Hash
+	})
+}
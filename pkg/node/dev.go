@@ -0,0 +1,44 @@
+package node
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/config"
+	"github.com/anekazek/simple-blockchain/pkg/storage"
+	"github.com/anekazek/simple-blockchain/pkg/wallet"
+)
+
+// devAccounts derives the accounts funded by the dev genesis from the seed
+func devAccounts(cfg config.DevConfig) []*wallet.Wallet {
+	accounts := make([]*wallet.Wallet, cfg.Accounts)
+	for i := range accounts {
+		accounts[i] = wallet.FromSeed(cfg.Seed, i)
+	}
+	return accounts
+}
+
+// devGenesis creates the dev genesis funding each account, refusing to
+// when the store already holds a chain with another genesis
+func devGenesis(cfg config.DevConfig, accounts []*wallet.Wallet, store storage.BlockchainStore) (blockchain.Block, error) {
+	allocations := make(map[string]float64, len(accounts))
+	for _, account := range accounts {
+		allocations[account.Address()] = cfg.Balance
+	}
+	genesis := blockchain.CreateDevGenesisBlock(allocations)
+
+	if stored, err := store.GetBlockByIndex(0); err == nil && stored.Hash != genesis.Hash {
+		return blockchain.Block{}, fmt.Errorf("dev mode refused: storage holds a chain with genesis %s, not the dev genesis %s of these dev settings", stored.Hash, genesis.Hash)
+	}
+	return genesis, nil
+}
+
+// logDevAccounts prints the funded dev accounts with their private keys
+func logDevAccounts(cfg config.DevConfig, accounts []*wallet.Wallet) {
+	log.Printf("DEV MODE: faucet enabled, %d accounts funded with %g each; their keys are public, never use them elsewhere\n", len(accounts), cfg.Balance)
+	for i, account := range accounts {
+		log.Printf("  (%d) %s  private key %s\n", i, account.Address(), hex.EncodeToString(account.PrivateKey))
+	}
+}
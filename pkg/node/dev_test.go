@@ -0,0 +1,50 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/config"
+	"github.com/anekazek/simple-blockchain/pkg/storage"
+)
+
+func TestDevAccountsAreDeterministic(t *testing.T) {
+	cfg := config.DevConfig{Accounts: 3, Seed: "dev"}
+	first, second := devAccounts(cfg), devAccounts(cfg)
+	for i := range first {
+		if first[i].Address() != second[i].Address() {
+			t.Fatalf("account %d differs between derivations", i)
+		}
+	}
+	if first[0].Address() == first[1].Address() {
+		t.Fatal("accounts share an address")
+	}
+	if devAccounts(config.DevConfig{Accounts: 1, Seed: "other"})[0].Address() == first[0].Address() {
+		t.Fatal("another seed derived the same account")
+	}
+}
+
+func TestDevGenesisRefusesOtherChain(t *testing.T) {
+	cfg := config.DevConfig{Accounts: 2, Balance: 1000, Seed: "dev"}
+	accounts := devAccounts(cfg)
+
+	store := storage.NewMemoryStore()
+	genesis, err := devGenesis(cfg, accounts, store)
+	if err != nil {
+		t.Fatalf("fresh store: %v", err)
+	}
+	if err := store.SaveBlock(genesis); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := devGenesis(cfg, accounts, store); err != nil {
+		t.Fatalf("store holding the same dev chain: %v", err)
+	}
+
+	store = storage.NewMemoryStore()
+	if err := store.SaveBlock(blockchain.CreateGenesisBlock()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := devGenesis(cfg, accounts, store); err == nil {
+		t.Fatal("dev mode enabled on a store holding a non-dev chain")
+	}
+}
@@ -0,0 +1,48 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/anekazek/simple-blockchain/pkg/config"
+	"github.com/anekazek/simple-blockchain/pkg/wallet"
+)
+
+// IdentityFile is the name of the node's key file in its data directory
+const IdentityFile = "identity.json"
+
+// loadIdentity loads the node's key pair from its data directory, creating
+// it on first start. A key file that exists but can't be read is an error
+// rather than a reason to start over as a different node.
+func loadIdentity(cfg config.NodeConfig) (*wallet.Wallet, error) {
+	path := filepath.Join(cfg.DataDir, IdentityFile)
+	identity, err := wallet.Open(path, cfg.IdentityPassphrase)
+	if err == nil {
+		return identity, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load node identity (move the file aside only to start as a new node): %w", err)
+	}
+
+	if identity, err = wallet.Generate(); err != nil {
+		return nil, fmt.Errorf("failed to create node identity: %w", err)
+	}
+	if err := os.MkdirAll(cfg.DataDir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if cfg.IdentityPassphrase != "" {
+		err = identity.SaveEncrypted(path, cfg.IdentityPassphrase)
+	} else {
+		log.Printf("Warning: storing the node identity unencrypted in %s; set IDENTITY_PASSPHRASE to encrypt it\n", path)
+		err = identity.Save(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save node identity: %w", err)
+	}
+	log.Printf("Created node identity %s in %s\n", identity.Address(), path)
+	return identity, nil
+}
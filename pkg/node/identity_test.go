@@ -0,0 +1,56 @@
+package node
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/config"
+)
+
+func TestIdentityIsCreatedThenReused(t *testing.T) {
+	for name, passphrase := range map[string]string{"plaintext": "", "encrypted": "secret"} {
+		cfg := config.NodeConfig{DataDir: filepath.Join(t.TempDir(), "data"), IdentityPassphrase: passphrase}
+		first, err := loadIdentity(cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(cfg.DataDir, IdentityFile)); err != nil {
+			t.Fatalf("%s: identity file not written: %v", name, err)
+		}
+
+		second, err := loadIdentity(cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if second.Address() != first.Address() {
+			t.Errorf("%s: second start is %s, first was %s", name, second.Address(), first.Address())
+		}
+	}
+}
+
+func TestCorruptedIdentityIsAnError(t *testing.T) {
+	cfg := config.NodeConfig{DataDir: t.TempDir()}
+	path := filepath.Join(cfg.DataDir, IdentityFile)
+	if err := os.WriteFile(path, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadIdentity(cfg); err == nil {
+		t.Fatal("loaded a corrupted identity")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "{not json" {
+		t.Error("corrupted identity file was replaced")
+	}
+}
+
+func TestEncryptedIdentityNeedsItsPassphrase(t *testing.T) {
+	cfg := config.NodeConfig{DataDir: t.TempDir(), IdentityPassphrase: "secret"}
+	if _, err := loadIdentity(cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.IdentityPassphrase = "wrong"
+	if _, err := loadIdentity(cfg); err == nil {
+		t.Fatal("opened the identity with the wrong passphrase")
+	}
+}
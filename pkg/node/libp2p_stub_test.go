@@ -0,0 +1,31 @@
+//go:build !libp2p
+
+package node
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/config"
+)
+
+func TestLibp2pTransportNeedsBuildTag(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.P2P.Transport = config.TransportLibp2p
+	n, err := New(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		n.Stop(ctx)
+	})
+
+	err = n.Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "-tags libp2p") {
+		t.Fatalf("got %v, want the libp2p transport to be unavailable", err)
+	}
+}
@@ -0,0 +1,161 @@
+// Package lifecycle tracks a node's startup states, from loading its chain
+// through syncing with peers to serving traffic
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// State describes where a node is in its startup lifecycle
+type State string
+
+const (
+	// StateStarting is the initial state before any data is loaded
+	StateStarting State = "starting"
+	// StateLoading means the chain is being read from local storage
+	StateLoading State = "loading"
+	// StateSyncing means the node is catching up with its peers
+	StateSyncing State = "syncing"
+	// StateReady means the node is fully loaded and serving traffic
+	StateReady State = "ready"
+	// StateStopped means the node was stopped before it became ready
+	StateStopped State = "stopped"
+)
+
+// order gives the position of each state in the lifecycle
+var order = map[State]int{
+	StateStarting: 0,
+	StateLoading:  1,
+	StateSyncing:  2,
+	StateReady:    3,
+	StateStopped:  4,
+}
+
+// Status is a snapshot of the node state and its progress
+type Status struct {
+	State        State `json:"state"`
+	BlocksLoaded int   `json:"blocksLoaded"`
+	TargetHeight int   `json:"targetHeight"`
+}
+
+// ProgressFunc reports how many blocks have been processed out of the target height
+type ProgressFunc func(loaded, target int)
+
+// Loader reads the chain from local storage during startup
+type Loader interface {
+	Load(progress ProgressFunc) error
+}
+
+// Syncer brings the chain up to date with the network during startup
+type Syncer interface {
+	Sync(progress ProgressFunc) error
+}
+
+// StateMachine tracks node readiness and notifies listeners of transitions
+type StateMachine struct {
+	status    Status
+	listeners []func(Status)
+	mutex     sync.RWMutex
+}
+
+// NewStateMachine creates a state machine in the starting state
+func NewStateMachine() *StateMachine {
+	return &StateMachine{
+		status: Status{State: StateStarting},
+	}
+}
+
+// Status returns the current state and progress
+func (m *StateMachine) Status() Status {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.status
+}
+
+// IsReady reports whether the node has finished loading and syncing
+func (m *StateMachine) IsReady() bool {
+	return m.Status().State == StateReady
+}
+
+// Subscribe registers a callback invoked after every state change
+func (m *StateMachine) Subscribe(fn func(Status)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.listeners = append(m.listeners, fn)
+}
+
+// Transition moves the node to the given state; states may only move forward
+func (m *StateMachine) Transition(to State) error {
+	m.mutex.Lock()
+	if _, ok := order[to]; !ok {
+		m.mutex.Unlock()
+		return fmt.Errorf("unknown node state: %s", to)
+	}
+	if order[to] <= order[m.status.State] {
+		from := m.status.State
+		m.mutex.Unlock()
+		return fmt.Errorf("invalid state transition: %s -> %s", from, to)
+	}
+	m.status.State = to
+	m.status.BlocksLoaded = 0
+	m.status.TargetHeight = 0
+	status := m.status
+	listeners := append([]func(Status){}, m.listeners...)
+	m.mutex.Unlock()
+
+	for _, fn := range listeners {
+		fn(status)
+	}
+	return nil
+}
+
+// SetProgress records the blocks processed within the current state
+func (m *StateMachine) SetProgress(loaded, target int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.status.BlocksLoaded = loaded
+	m.status.TargetHeight = target
+}
+
+// Bootstrap drives the node through loading and syncing until it is ready.
+// A nil loader or syncer skips that phase. If ctx is done before the node
+// is ready, such as when a sync is cut short by shutdown, the node is left
+// stopped and Bootstrap returns ctx's error.
+func (m *StateMachine) Bootstrap(ctx context.Context, loader Loader, syncer Syncer) error {
+	if err := m.Transition(StateLoading); err != nil {
+		return err
+	}
+	if loader != nil {
+		if err := loader.Load(m.SetProgress); err != nil {
+			return fmt.Errorf("failed to load chain: %w", err)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return m.stop(err)
+	}
+
+	if err := m.Transition(StateSyncing); err != nil {
+		return err
+	}
+	if syncer != nil {
+		if err := syncer.Sync(m.SetProgress); err != nil {
+			return fmt.Errorf("failed to sync chain: %w", err)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return m.stop(err)
+	}
+
+	return m.Transition(StateReady)
+}
+
+// stop moves an interrupted bootstrap to the stopped state and returns the
+// reason it was interrupted
+func (m *StateMachine) stop(reason error) error {
+	if err := m.Transition(StateStopped); err != nil {
+		return err
+	}
+	return reason
+}
@@ -0,0 +1,35 @@
+package node
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/api"
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+)
+
+// runMiner mines a block whenever transactions are pending, checking every
+// interval until ctx is done
+func runMiner(ctx context.Context, server *api.EnhancedBlockchainServer, txPool *blockchain.TransactionPool, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if txPool.Count() == 0 {
+			continue
+		}
+		block, err := server.MineBlock(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Mining failed: %v\n", err)
+			}
+			continue
+		}
+		log.Printf("Mined block %d\n", block.Index)
+	}
+}
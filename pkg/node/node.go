@@ -0,0 +1,381 @@
+// Package node assembles a blockchain node from its configuration: storage,
+// the chain and transaction pool, consensus, contracts, the API, P2P, the
+// miner and metrics. The binary and tests build nodes the same way, through
+// New.
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/anekazek/simple-blockchain/pkg/api"
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/config"
+	"github.com/anekazek/simple-blockchain/pkg/consensus"
+	"github.com/anekazek/simple-blockchain/pkg/metrics"
+	"github.com/anekazek/simple-blockchain/pkg/network"
+	"github.com/anekazek/simple-blockchain/pkg/node/lifecycle"
+	"github.com/anekazek/simple-blockchain/pkg/tracing"
+	"github.com/anekazek/simple-blockchain/pkg/version"
+	"github.com/anekazek/simple-blockchain/pkg/wallet"
+)
+
+// Node is a blockchain node and everything it runs
+type Node struct {
+	cfg       *config.Config
+	chain     *blockchain.Chain
+	pool      *blockchain.TransactionPool
+	consensus consensus.Algorithm
+	metrics   *metrics.BlockchainMetrics
+	store     Store
+	writer    *chainWriter
+	loader    *snapshotLoader
+	server    *api.EnhancedBlockchainServer
+	state     *lifecycle.StateMachine
+	identity  *wallet.Wallet
+	miner     string // Address credited with mined blocks
+	devKeys   []*wallet.Wallet
+	dialPeer  func(ctx context.Context, network, address string) (net.Conn, error)
+
+	// Set by Start
+	transport       network.Transport
+	mdns            *network.MDNSDiscovery
+	metricsServer   *metrics.MetricsServer
+	shutdownTracing func(context.Context) error
+	cancel          context.CancelFunc
+	wg              sync.WaitGroup
+	errs            chan error
+}
+
+// New builds a node from cfg: it loads or creates the node identity, opens
+// the configured store, reloads the deployed contracts from it, and wires the chain, pool, consensus, API
+// server and metrics together. Nothing listens until Start.
+func New(cfg *config.Config) (*Node, error) {
+	algorithm, err := consensus.New(cfg.Consensus.Type, cfg.Consensus.Difficulty)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := loadIdentity(cfg.Node)
+	if err != nil {
+		return nil, err
+	}
+	miner := cfg.Miner.Address
+	if miner == "" {
+		miner = identity.Address()
+	}
+
+	blockchainMetrics := metrics.NewBlockchainMetrics()
+	blockchainMetrics.SetProfiling(cfg.Metrics.ProfilingConfig())
+	blockchainMetrics.SetNodeInfo(version.Version, version.Commit, cfg.Consensus.Type)
+
+	store, err := openStore(cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	blocks := blockchainMetrics.InstrumentStore(store)
+
+	// Dev mode starts from a genesis funding the dev accounts and accepts
+	// faucet transactions
+	var chain *blockchain.Chain
+	var accounts []*wallet.Wallet
+	limits := blockchain.BlockLimits{
+		MaxBytes:        cfg.Consensus.MaxBlockBytes,
+		MaxTransactions: cfg.Consensus.MaxBlockTransactions,
+	}
+	if cfg.Dev.Enabled {
+		accounts = devAccounts(cfg.Dev)
+		genesis, err := devGenesis(cfg.Dev, accounts, store)
+		if err != nil {
+			store.Close()
+			return nil, err
+		}
+		chain = blockchain.NewBlockchainWithGenesis(genesis, limits)
+		chain.SetDevMode(true)
+	} else {
+		chain = blockchain.NewBlockchainWithGenesis(blockchain.CreateNetworkGenesisBlock(cfg.P2P.NetworkID), limits)
+	}
+	chain.SetMiner(blockchain.NewMiner(cfg.Miner.Workers))
+	hasher, err := blockchain.HasherFor(cfg.Consensus.HashAlgo)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	chain.SetHasher(hasher)
+	// Proof-of-stake peers only accept blocks signed by their validator
+	if cfg.Consensus.Type == config.ConsensusPoS {
+		chain.SetSigner(identity.PrivateKey)
+	}
+	chain.SetOrphanLimits(blockchain.OrphanLimits{MaxBlocks: cfg.P2P.MaxOrphans, TTL: cfg.P2P.OrphanTTL})
+	txPool := blockchain.NewTransactionPool(cfg.Pool.Size)
+	txPool.AllowFaucet(cfg.Dev.Enabled)
+	txPool.SetBalances(chain)
+	// Transactions of abandoned blocks are pending again
+	chain.OnReorg(func(reorg blockchain.Reorg) {
+		requeued := txPool.Requeue(reorg.Transactions)
+		log.Printf("Reorganized onto block %d from %d, abandoning %d blocks; requeued %d of %d transactions\n",
+			reorg.NewTipHeight, reorg.AncestorHeight, reorg.AbandonedBlocks, requeued, len(reorg.Transactions))
+	})
+
+	server := api.NewEnhancedBlockchainServer(chain, txPool, cfg.Consensus.Difficulty, blockchainMetrics)
+	if cfg.Metrics.OnAPI {
+		server.MountMetrics()
+	}
+	if err := server.LoadContracts(store); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to load contracts: %w", err)
+	}
+
+	server.SetNodeID(identity.Address())
+	if cfg.Dev.Enabled {
+		server.EnableFaucet(api.FaucetConfig{MaxAmount: cfg.Dev.FaucetMaxAmount, Interval: cfg.Dev.FaucetInterval})
+	}
+
+	// Let holders of the admin token manage every contract
+	server.SetAdminToken(cfg.API.AdminToken)
+	if cfg.API.TLS.Enabled() {
+		server.ConfigureTLS(cfg.API.TLS.CertFile, cfg.API.TLS.KeyFile)
+	}
+
+	// Gate API traffic until the node has loaded and synced
+	state := lifecycle.NewStateMachine()
+	server.SetNodeState(state)
+
+	trust, err := cfg.P2P.SnapshotTrust()
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	// Pin the configured checkpoints, and the block fast sync trusts
+	checkpoints, err := cfg.Consensus.LoadCheckpoints()
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	if trust.Checkpoint != nil {
+		checkpoints = append(checkpoints, *trust.Checkpoint)
+	}
+	for _, checkpoint := range checkpoints {
+		if err := chain.AddCheckpoint(checkpoint.Height, checkpoint.Hash); err != nil {
+			store.Close()
+			return nil, err
+		}
+	}
+
+	n := &Node{
+		cfg:       cfg,
+		chain:     chain,
+		pool:      txPool,
+		consensus: algorithm,
+		metrics:   blockchainMetrics,
+		store:     store,
+		writer:    newChainWriter(chain, blocks),
+		server:    server,
+		state:     state,
+		identity:  identity,
+		miner:     miner,
+		devKeys:   accounts,
+		errs:      make(chan error, 1),
+	}
+	n.loader = &snapshotLoader{node: n, trust: trust}
+	return n, nil
+}
+
+// Start starts tracing, the metrics, API and P2P servers and the miner,
+// then loads the chain from storage and syncs it with peers in the
+// background; State reports the progress. Ports are bound before Start
+// returns. If it fails, Stop releases whatever was started.
+func (n *Node) Start(ctx context.Context) error {
+	ctx, n.cancel = context.WithCancel(ctx)
+	cfg := n.cfg
+
+	// Export traces over OTLP when enabled
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing.Config())
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	n.shutdownTracing = shutdownTracing
+
+	// Serve metrics on their own port unless they share the API's
+	if !cfg.Metrics.OnAPI {
+		n.metricsServer, err = n.metrics.StartServer(strconv.Itoa(cfg.Metrics.Port))
+		if err != nil {
+			return err
+		}
+		n.forward(n.metricsServer.Err())
+	}
+
+	// Push metrics to a Pushgateway for nodes Prometheus can't scrape
+	if cfg.Metrics.Push.URL != "" {
+		if err := n.metrics.StartPush(cfg.Metrics.PushConfig()); err != nil {
+			return fmt.Errorf("failed to start metrics push: %w", err)
+		}
+	}
+	n.metrics.SetNodeHealth(true)
+
+	if err := n.server.Listen(strconv.Itoa(cfg.API.HTTPPort), strconv.Itoa(cfg.API.WSPort)); err != nil {
+		return err
+	}
+	n.forward(n.server.Err())
+
+	// Start the P2P transport when a P2P port, peers, or seeds are configured
+	var syncer lifecycle.Syncer
+	if cfg.P2P.Enabled() {
+		if syncer, err = n.startP2P(ctx); err != nil {
+			return err
+		}
+	}
+
+	// Stop waits for the bootstrap so the store isn't closed under it. It
+	// stops the P2P transport first, which cuts a sync in progress short.
+	n.spawn(func() {
+		err := n.state.Bootstrap(ctx, n.loader, syncer)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("Node bootstrap failed: %v\n", err)
+			n.metrics.SetNodeHealth(false)
+		}
+	})
+	n.spawn(func() { n.writer.run(ctx) })
+
+	// Mine pending transactions in the background
+	if cfg.Miner.Enabled {
+		n.spawn(func() { runMiner(ctx, n.server, n.pool, cfg.Miner.Interval) })
+	}
+
+	log.Printf("Node ID: %s\n", n.ID())
+	if cfg.Dev.Enabled {
+		logDevAccounts(cfg.Dev, n.devKeys)
+	}
+	if cfg.Miner.Enabled {
+		log.Printf("Mining for %s\n", n.miner)
+	}
+	log.Printf("Starting blockchain with %s consensus and difficulty: %d\n", cfg.Consensus.Type, cfg.Consensus.Difficulty)
+	log.Printf("Transaction pool initialized with capacity: %d\n", cfg.Pool.Size)
+	log.Printf("Storing blocks and contracts in %s storage\n", cfg.Storage.Backend)
+	return nil
+}
+
+// Stop stops the miner, P2P and the API, waits for the startup load and
+// sync to end, writes any blocks not yet stored, pushes metrics a last
+// time, flushes traces and closes the store
+func (n *Node) Stop(ctx context.Context) error {
+	if n.cancel != nil {
+		n.cancel()
+	}
+
+	var errs []error
+	if n.mdns != nil {
+		errs = append(errs, n.mdns.Stop())
+	}
+	switch transport := n.transport.(type) {
+	case *network.P2PServer:
+		transport.Stop()
+	case interface{ Close() }:
+		transport.Close()
+	}
+	errs = append(errs, n.server.Shutdown(ctx))
+	n.wg.Wait()
+
+	errs = append(errs, n.writer.flush(ctx), n.metrics.Shutdown(ctx))
+	if n.shutdownTracing != nil {
+		errs = append(errs, n.shutdownTracing(ctx))
+	}
+	errs = append(errs, n.store.Close())
+	return errors.Join(errs...)
+}
+
+// SetPeerDialer replaces the dialer of the connections to peers, such as to
+// simulate network faults in tests. It must be called before Start.
+func (n *Node) SetPeerDialer(dial func(ctx context.Context, network, address string) (net.Conn, error)) {
+	n.dialPeer = dial
+}
+
+// Err returns a channel receiving the first error that stopped one of the
+// node's servers unexpectedly
+func (n *Node) Err() <-chan error {
+	return n.errs
+}
+
+// spawn runs fn in the background until Stop
+func (n *Node) spawn(fn func()) {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		fn()
+	}()
+}
+
+// forward reports the errors received on errs through Err
+func (n *Node) forward(errs <-chan error) {
+	go func() {
+		for err := range errs {
+			n.fail(err)
+		}
+	}()
+}
+
+// fail reports err through Err unless an error is already pending
+func (n *Node) fail(err error) {
+	select {
+	case n.errs <- err:
+	default:
+	}
+}
+
+// ID returns the node ID, the address of its identity key
+func (n *Node) ID() string {
+	return n.identity.Address()
+}
+
+// Identity returns the node's key pair
+func (n *Node) Identity() *wallet.Wallet {
+	return n.identity
+}
+
+// MinerAddress returns the address credited with the blocks the node mines:
+// miner.address, or the node ID
+func (n *Node) MinerAddress() string {
+	return n.miner
+}
+
+// DevAccounts returns the accounts funded by the dev genesis, nil outside
+// dev mode
+func (n *Node) DevAccounts() []*wallet.Wallet {
+	return n.devKeys
+}
+
+// Chain returns the node's chain
+func (n *Node) Chain() *blockchain.Chain {
+	return n.chain
+}
+
+// Pool returns the node's transaction pool
+func (n *Node) Pool() *blockchain.TransactionPool {
+	return n.pool
+}
+
+// Server returns the node's API server
+func (n *Node) Server() *api.EnhancedBlockchainServer {
+	return n.server
+}
+
+// Metrics returns the node's metrics
+func (n *Node) Metrics() *metrics.BlockchainMetrics {
+	return n.metrics
+}
+
+// State returns the node's startup state machine
+func (n *Node) State() *lifecycle.StateMachine {
+	return n.state
+}
+
+// Addr returns the address the API listens on, nil before Start
+func (n *Node) Addr() net.Addr {
+	return n.server.Addr()
+}
@@ -0,0 +1,119 @@
+package node
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/client"
+	"github.com/anekazek/simple-blockchain/pkg/config"
+	"github.com/anekazek/simple-blockchain/pkg/network/networktest"
+	"github.com/anekazek/simple-blockchain/pkg/node/lifecycle"
+)
+
+// testConfig returns the configuration of a node keeping its data in
+// memory and listening on free ports
+func testConfig(t *testing.T) config.Config {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	cfg := config.Default()
+	cfg.Node.DataDir = t.TempDir()
+	cfg.API.HTTPPort, cfg.API.WSPort = 0, 0
+	cfg.Metrics.OnAPI = true
+	cfg.Storage.Backend = config.BackendMemory
+	cfg.Miner.Enabled = false
+	cfg.P2P.Port = port
+	cfg.P2P.AdvertiseAddr = "127.0.0.1:" + strconv.Itoa(port)
+	return cfg
+}
+
+func TestStopWaitsForBootstrap(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.P2P.Peers = []string{networktest.HangingPeer(t)}
+	cfg.P2P.RequestTimeout = time.Minute
+
+	n, err := New(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The sync blocks on the peer that never answers
+	deadline := time.Now().Add(5 * time.Second)
+	for n.State().Status().State != lifecycle.StateSyncing {
+		if time.Now().After(deadline) {
+			t.Fatalf("node never started syncing, state %s", n.State().Status().State)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := n.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Stop waited out the sync: %v", elapsed)
+	}
+	// The cut-short sync must not leave the node looking ready
+	if state := n.State().Status().State; state != lifecycle.StateStopped {
+		t.Fatalf("Stop returned with bootstrap %s, want %s", state, lifecycle.StateStopped)
+	}
+}
+
+func TestTransactionIsConfirmedOverHTTP(t *testing.T) {
+	n := startNode(t, testConfig(t))
+	deadline := time.Now().Add(5 * time.Second)
+	for !n.State().IsReady() {
+		if time.Now().After(deadline) {
+			t.Fatalf("node never became ready, state %s", n.State().Status().State)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx := context.Background()
+	api := client.New("http://"+n.Addr().String(), "")
+	id, err := api.SendTransaction(ctx, client.TransactionRequest{To: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, err := api.Transaction(ctx, id); err != nil || status.Status != "pending" {
+		t.Fatalf("got %+v, %v before mining, want it pending", status, err)
+	}
+
+	block, err := api.Mine(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := api.Transaction(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != "confirmed" || status.BlockHash != block.Hash || status.BlockIndex == nil || *status.BlockIndex != block.Index {
+		t.Fatalf("got %+v, want it confirmed in block %d", status, block.Index)
+	}
+
+	// The block reaches the node's store too
+	if err := n.writer.flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := n.store.GetBlock(block.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txs := blockchain.BlockTransactions(stored); len(txs) != 1 || txs[0].ID != id {
+		t.Fatalf("stored block holds %+v, want the transaction", txs)
+	}
+}
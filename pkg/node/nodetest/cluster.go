@@ -0,0 +1,266 @@
+// Package nodetest runs clusters of in-process nodes for integration tests
+// of sync, gossip and fork handling:
+//
+//	c := nodetest.StartCluster(t, 3, nodetest.Options{})
+//	c.MineOn(0)
+//	c.WaitForHeight(1, 5*time.Second)
+//
+// Nodes keep their data in memory, listen on free ports, peer with each
+// other, and are stopped when the test ends.
+package nodetest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/client"
+	"github.com/anekazek/simple-blockchain/pkg/config"
+	"github.com/anekazek/simple-blockchain/pkg/node"
+	"github.com/anekazek/simple-blockchain/pkg/wallet"
+)
+
+// Options configure a cluster. Zero values select the defaults.
+type Options struct {
+	// Difficulty is the mining difficulty, 1 by default
+	Difficulty int
+	// SyncInterval is how often nodes sync with and discover peers, 200ms
+	// by default
+	SyncInterval time.Duration
+	// Configure adjusts the configuration of node i before it is built
+	Configure func(i int, cfg *config.Config)
+}
+
+// pollInterval is how often the Wait helpers check their condition
+const pollInterval = 20 * time.Millisecond
+
+// leakTimeout is how long goroutines may take to exit after the cluster
+// stops
+const leakTimeout = 5 * time.Second
+
+// Cluster is a set of running, peered nodes
+type Cluster struct {
+	t       testing.TB
+	nodes   []*node.Node
+	clients []*client.Client
+	network *network
+}
+
+// StartCluster starts n nodes sharing their network's genesis block, each
+// configured with every other node as a peer. They are stopped, and the test
+// fails if they leak goroutines, when the test ends.
+func StartCluster(t testing.TB, n int, opts Options) *Cluster {
+	t.Helper()
+	if opts.Difficulty == 0 {
+		opts.Difficulty = 1
+	}
+	if opts.SyncInterval == 0 {
+		opts.SyncInterval = 200 * time.Millisecond
+	}
+
+	baseline := runtime.NumGoroutine()
+	c := &Cluster{t: t, network: newNetwork()}
+	t.Cleanup(func() { checkLeaks(t, baseline) })
+	t.Cleanup(c.stop)
+
+	addresses := make([]string, n)
+	for i := range addresses {
+		addresses[i] = "127.0.0.1:" + strconv.Itoa(freePort(t))
+		c.network.add(i, addresses[i])
+	}
+
+	for i := 0; i < n; i++ {
+		cfg := config.Default()
+		cfg.Node.DataDir = t.TempDir()
+		cfg.Consensus.Difficulty = opts.Difficulty
+		cfg.API.HTTPPort, cfg.API.WSPort = 0, 0
+		cfg.Metrics.OnAPI = true
+		cfg.Storage.Backend = config.BackendMemory
+		_, port, _ := net.SplitHostPort(addresses[i])
+		cfg.P2P.Port, _ = strconv.Atoi(port)
+		cfg.P2P.AdvertiseAddr = addresses[i]
+		cfg.P2P.SyncInterval, cfg.P2P.DiscoveryInterval = opts.SyncInterval, opts.SyncInterval
+		cfg.P2P.ConnectTimeout, cfg.P2P.RequestTimeout = time.Second, 2*time.Second
+		for j, address := range addresses {
+			if j != i {
+				cfg.P2P.Peers = append(cfg.P2P.Peers, address)
+			}
+		}
+		if opts.Configure != nil {
+			opts.Configure(i, &cfg)
+		}
+
+		nd, err := node.New(&cfg)
+		if err != nil {
+			t.Fatalf("node %d: %v", i, err)
+		}
+		nd.SetPeerDialer(c.network.dialer(i))
+		c.nodes = append(c.nodes, nd)
+
+		if err := nd.Start(context.Background()); err != nil {
+			t.Fatalf("node %d: %v", i, err)
+		}
+		c.clients = append(c.clients, client.New("http://"+nd.Addr().String(), ""))
+	}
+
+	c.waitFor(10*time.Second, "nodes to become ready", func() bool {
+		for _, nd := range c.nodes {
+			if !nd.State().IsReady() {
+				return false
+			}
+		}
+		return true
+	})
+	return c
+}
+
+// freePort returns a port that was free a moment ago
+func freePort(t testing.TB) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// stop stops every node
+func (c *Cluster) stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for i, nd := range c.nodes {
+		if err := nd.Stop(ctx); err != nil {
+			c.t.Errorf("stopping node %d: %v", i, err)
+		}
+	}
+	c.network.closeAll()
+}
+
+// checkLeaks fails the test if more goroutines are running than before the
+// cluster started, once they have had time to exit
+func checkLeaks(t testing.TB, baseline int) {
+	deadline := time.Now().Add(leakTimeout)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			var stacks bytes.Buffer
+			pprof.Lookup("goroutine").WriteTo(&stacks, 1)
+			t.Errorf("%d goroutines leaked by the cluster:\n%s", runtime.NumGoroutine()-baseline, stacks.String())
+			return
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// Len returns the number of nodes
+func (c *Cluster) Len() int {
+	return len(c.nodes)
+}
+
+// Node returns node i
+func (c *Cluster) Node(i int) *node.Node {
+	return c.nodes[i]
+}
+
+// Client returns an API client of node i
+func (c *Cluster) Client(i int) *client.Client {
+	return c.clients[i]
+}
+
+// MineOn mines a block of node i's pending transactions and announces it
+func (c *Cluster) MineOn(i int) blockchain.Block {
+	c.t.Helper()
+	block, err := c.nodes[i].Server().MineBlock(context.Background())
+	if err != nil {
+		c.t.Fatalf("mining on node %d: %v", i, err)
+	}
+	return block
+}
+
+// NewTransaction returns a transaction of value to an address, signed by a
+// new wallet. The wallet owns nothing, so nodes refuse any value but 0.
+func NewTransaction(t testing.TB, to string, value float64) client.TransactionRequest {
+	t.Helper()
+	w, err := wallet.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := client.TransactionRequest{To: to, Value: value}
+	tx.Sign(w, time.Now())
+	return tx
+}
+
+// SubmitTx submits a signed transaction to node i over its API and returns
+// its ID
+func (c *Cluster) SubmitTx(i int, tx client.TransactionRequest) string {
+	c.t.Helper()
+	id, err := c.clients[i].SendTransaction(context.Background(), tx)
+	if err != nil {
+		c.t.Fatalf("submitting to node %d: %v", i, err)
+	}
+	return id
+}
+
+// Partition splits the cluster so nodes only reach the nodes in their own
+// group; nodes in no group reach none
+func (c *Cluster) Partition(groups ...[]int) {
+	c.network.partition(groups)
+}
+
+// Heal ends a partition
+func (c *Cluster) Heal() {
+	c.network.heal()
+}
+
+// WaitForHeight waits until every node's chain reaches height h
+func (c *Cluster) WaitForHeight(h int, timeout time.Duration) {
+	c.t.Helper()
+	c.waitFor(timeout, fmt.Sprintf("every node to reach height %d", h), func() bool {
+		for _, nd := range c.nodes {
+			if nd.Chain().Height() < h {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// WaitForConsensus waits until every node has the same tip and returns it
+func (c *Cluster) WaitForConsensus(timeout time.Duration) blockchain.Block {
+	c.t.Helper()
+	var tip blockchain.Block
+	c.waitFor(timeout, "every node to agree on the tip", func() bool {
+		tip = c.nodes[0].Chain().GetLatestBlock()
+		for _, nd := range c.nodes[1:] {
+			if nd.Chain().GetLatestBlock().Hash != tip.Hash {
+				return false
+			}
+		}
+		return true
+	})
+	return tip
+}
+
+// WaitFor waits until cond holds, failing the test after timeout
+func (c *Cluster) WaitFor(timeout time.Duration, what string, cond func() bool) {
+	c.t.Helper()
+	c.waitFor(timeout, what, cond)
+}
+
+// waitFor polls cond until it holds or timeout passes
+func (c *Cluster) waitFor(timeout time.Duration, what string, cond func() bool) {
+	c.t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			c.t.Fatalf("timed out after %v waiting for %s", timeout, what)
+		}
+		time.Sleep(pollInterval)
+	}
+}
@@ -0,0 +1,63 @@
+package nodetest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/node/nodetest"
+)
+
+func TestBlockPropagatesToEveryNode(t *testing.T) {
+	c := nodetest.StartCluster(t, 3, nodetest.Options{})
+
+	block := c.MineOn(1)
+	c.WaitForHeight(block.Index, 10*time.Second)
+	if tip := c.WaitForConsensus(10 * time.Second); tip.Hash != block.Hash {
+		t.Fatalf("nodes agree on %s, want the mined block %s", tip.Hash, block.Hash)
+	}
+}
+
+func TestForkResolvesWhenPartitionHeals(t *testing.T) {
+	c := nodetest.StartCluster(t, 3, nodetest.Options{})
+
+	c.Partition([]int{0}, []int{1, 2})
+	// A transaction only node 0 holds makes its block differ from node 1's
+	c.SubmitTx(0, nodetest.NewTransaction(t, "bob", 0))
+	minority := c.MineOn(0)
+	c.MineOn(1)
+	majority := c.MineOn(1)
+	c.WaitFor(10*time.Second, "node 2 to follow node 1", func() bool {
+		return c.Node(2).Chain().GetLatestBlock().Hash == majority.Hash
+	})
+	if got := c.Node(0).Chain().GetLatestBlock().Hash; got != minority.Hash {
+		t.Fatalf("partitioned node 0 has tip %s, want its own block %s", got, minority.Hash)
+	}
+
+	c.Heal()
+	if tip := c.WaitForConsensus(15 * time.Second); tip.Hash != majority.Hash {
+		t.Fatalf("nodes agree on %s, want the heavier branch's tip %s", tip.Hash, majority.Hash)
+	}
+	if _, ok := c.Node(0).Chain().GetBlockByHash(minority.Hash); ok {
+		t.Fatal("node 0 kept its abandoned block on the chain")
+	}
+}
+
+func TestTransactionGossipReachesEveryPool(t *testing.T) {
+	c := nodetest.StartCluster(t, 3, nodetest.Options{})
+
+	id := c.SubmitTx(0, nodetest.NewTransaction(t, "bob", 0))
+	c.WaitFor(10*time.Second, "every pool to hold the transaction", func() bool {
+		for i := 0; i < c.Len(); i++ {
+			if _, err := c.Node(i).Pool().GetTransaction(id); err != nil {
+				return false
+			}
+		}
+		return true
+	})
+
+	block := c.MineOn(2)
+	if len(block.Transactions) == 0 || block.Transactions[0].ID != id {
+		t.Fatalf("node 2 mined %+v, want the gossiped transaction", block.Transactions)
+	}
+	c.WaitForConsensus(10 * time.Second)
+}
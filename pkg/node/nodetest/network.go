@@ -0,0 +1,129 @@
+package nodetest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+)
+
+// errPartitioned is returned by connections between partitioned nodes
+var errPartitioned = errors.New("nodetest: nodes are partitioned")
+
+// network routes the cluster's peer connections, dropping those between
+// nodes in different partitions
+type network struct {
+	nodes  map[string]int // Node index by P2P address
+	groups map[int]int    // Partition of each node, nil when healed
+	conns  map[*conn]struct{}
+	mutex  sync.Mutex
+}
+
+// newNetwork creates a network with every node reachable
+func newNetwork() *network {
+	return &network{nodes: make(map[string]int), conns: make(map[*conn]struct{})}
+}
+
+// add registers the P2P address of node i
+func (n *network) add(i int, address string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.nodes[address] = i
+}
+
+// partition splits the nodes into groups. Nodes in no group are isolated.
+func (n *network) partition(groups [][]int) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.groups = make(map[int]int)
+	for g, group := range groups {
+		for _, i := range group {
+			n.groups[i] = g
+		}
+	}
+}
+
+// heal makes every node reachable again
+func (n *network) heal() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.groups = nil
+}
+
+// blocked reports whether traffic between nodes from and to is dropped.
+// Addresses outside the cluster are never blocked.
+func (n *network) blocked(from int, address string) bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	to, ok := n.nodes[address]
+	if !ok || n.groups == nil {
+		return false
+	}
+	fromGroup, fromOK := n.groups[from]
+	toGroup, toOK := n.groups[to]
+	return !fromOK || !toOK || fromGroup != toGroup
+}
+
+// dialer returns the peer dialer of node i
+func (n *network) dialer(i int) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if n.blocked(i, address) {
+			return nil, errPartitioned
+		}
+		var dialer net.Dialer
+		c, err := dialer.DialContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		wrapped := &conn{Conn: c, network: n, from: i, address: address}
+		n.mutex.Lock()
+		n.conns[wrapped] = struct{}{}
+		n.mutex.Unlock()
+		return wrapped, nil
+	}
+}
+
+// closeAll closes every connection opened through the network
+func (n *network) closeAll() {
+	n.mutex.Lock()
+	conns := make([]*conn, 0, len(n.conns))
+	for c := range n.conns {
+		conns = append(conns, c)
+	}
+	n.mutex.Unlock()
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+// conn is a peer connection that fails while its ends are partitioned, so
+// kept-alive connections are cut too
+type conn struct {
+	net.Conn
+	network *network
+	from    int
+	address string
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	if c.network.blocked(c.from, c.address) {
+		c.Conn.Close()
+		return 0, errPartitioned
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *conn) Write(b []byte) (int, error) {
+	if c.network.blocked(c.from, c.address) {
+		c.Conn.Close()
+		return 0, errPartitioned
+	}
+	return c.Conn.Write(b)
+}
+
+func (c *conn) Close() error {
+	c.network.mutex.Lock()
+	delete(c.network.conns, c)
+	c.network.mutex.Unlock()
+	return c.Conn.Close()
+}
@@ -0,0 +1,102 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/anekazek/simple-blockchain/pkg/config"
+	"github.com/anekazek/simple-blockchain/pkg/network"
+	"github.com/anekazek/simple-blockchain/pkg/node/lifecycle"
+)
+
+// startP2P starts the configured P2P transport and connects it to the API
+// server, returning the syncer the node bootstraps from, if the transport
+// has one
+func (n *Node) startP2P(ctx context.Context) (lifecycle.Syncer, error) {
+	cfg := n.cfg
+	identity := &network.Identity{PublicKey: n.identity.PublicKey, PrivateKey: n.identity.PrivateKey}
+
+	var err error
+	var syncer lifecycle.Syncer
+	p2pPort := cfg.P2P.ListenPort()
+	switch cfg.P2P.Transport {
+	case config.TransportHTTP:
+		p2pServer := network.NewP2PServer(n.chain, p2pPort, cfg.P2P.Intervals())
+		p2pServer.SetIdentity(identity)
+		if cfg.P2P.AdvertiseAddr != "" {
+			p2pServer.SetAdvertisedAddress(cfg.P2P.AdvertiseAddr)
+		}
+		p2pServer.SetMaxPeers(cfg.P2P.MaxPeers)
+		p2pServer.SetNetworkID(cfg.P2P.NetworkID)
+		clientConfig := cfg.P2P.ClientConfig()
+		clientConfig.DialContext = n.dialPeer
+		p2pServer.ConfigureClient(clientConfig)
+		p2pServer.SetMetrics(n.metrics)
+		p2pServer.SetConsensus(n.consensus)
+		p2pServer.SetRole(cfg.P2P.Role)
+		p2pServer.SetTransactionPool(n.pool)
+		p2pServer.SetAuthenticated(cfg.P2P.Auth)
+		p2pServer.SetSnapshotSource(n.buildSnapshot)
+		if cfg.P2P.FastSync {
+			// Held until the loader has installed a snapshot
+			p2pServer.PauseSync()
+			n.loader.p2p = p2pServer
+		}
+		if cfg.P2P.TLS.Enabled {
+			if err := p2pServer.ConfigureTLS(cfg.PeerTLSConfig()); err != nil {
+				return nil, fmt.Errorf("failed to configure P2P TLS: %w", err)
+			}
+		}
+		if cfg.P2P.WebSocket {
+			p2pServer.EnableWebSocketTransport()
+		}
+		for _, peer := range cfg.P2P.Peers {
+			p2pServer.AddPeer(peer)
+		}
+		for _, seed := range cfg.P2P.Seeds {
+			p2pServer.AddSeed(seed)
+		}
+		n.transport = p2pServer
+		syncer = p2pServer
+		if cfg.P2P.MDNS {
+			n.startMDNSDiscovery(p2pServer)
+		}
+	case config.TransportLibp2p:
+		n.transport, err = network.NewLibp2pTransport(n.chain, n.pool, p2pPort, identity, append(cfg.P2P.Peers, cfg.P2P.Seeds...))
+		if err != nil {
+			return nil, fmt.Errorf("failed to start libp2p transport: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown P2P transport %q", cfg.P2P.Transport)
+	}
+	n.server.SetPeerNetwork(n.transport)
+
+	transport := n.transport
+	go func() {
+		err := transport.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) && ctx.Err() == nil {
+			n.fail(fmt.Errorf("P2P transport failed: %w", err))
+		}
+	}()
+	transport.Start()
+	return syncer, nil
+}
+
+// startMDNSDiscovery advertises the node on the local network and adds
+// discovered peers
+func (n *Node) startMDNSDiscovery(p2pServer *network.P2PServer) {
+	service, err := network.NewMDNSService()
+	if err != nil {
+		log.Printf("mDNS discovery disabled: %v\n", err)
+		return
+	}
+	discovery := network.NewMDNSDiscovery(p2pServer, service)
+	if err := discovery.Start(); err != nil {
+		log.Printf("Failed to start mDNS discovery: %v\n", err)
+		return
+	}
+	n.mdns = discovery
+}
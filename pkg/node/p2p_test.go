@@ -0,0 +1,66 @@
+package node_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/node/nodetest"
+)
+
+// broadcastOnly configures a cluster that syncs too rarely for a test to
+// see, so blocks only spread by being broadcast
+var broadcastOnly = nodetest.Options{SyncInterval: time.Hour}
+
+func TestMinedBlockReachesPeer(t *testing.T) {
+	c := nodetest.StartCluster(t, 2, broadcastOnly)
+
+	block := c.MineOn(0)
+	c.WaitFor(5*time.Second, "node 1 to receive the block", func() bool {
+		got, ok := c.Node(1).Chain().GetBlockByHash(block.Hash)
+		return ok && got.Index == block.Index
+	})
+}
+
+func TestBlockMinedThroughAPIIsBroadcast(t *testing.T) {
+	c := nodetest.StartCluster(t, 2, broadcastOnly)
+
+	block, err := c.Client(1).Mine(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.WaitFor(5*time.Second, "node 0 to receive the block", func() bool {
+		_, ok := c.Node(0).Chain().GetBlockByHash(block.Hash)
+		return ok
+	})
+	if tip := c.WaitForConsensus(5 * time.Second); tip.Hash != block.Hash {
+		t.Fatalf("nodes agree on %s, want the mined block %s", tip.Hash, block.Hash)
+	}
+}
+
+func TestPeerCountReachesStatsAndMetrics(t *testing.T) {
+	c := nodetest.StartCluster(t, 2, nodetest.Options{})
+
+	for i := 0; i < c.Len(); i++ {
+		c.WaitFor(5*time.Second, "the stats to count the peer", func() bool {
+			stats, err := c.Client(i).Stats(context.Background())
+			return err == nil && stats.PeerCount == 1
+		})
+
+		resp, err := http.Get("http://" + c.Node(i).Addr().String() + "/metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(body), "\nblockchain_peer_count 1\n") {
+			t.Errorf("node %d metrics don't count one peer", i)
+		}
+	}
+}
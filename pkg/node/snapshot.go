@@ -0,0 +1,170 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/network"
+	"github.com/anekazek/simple-blockchain/pkg/node/lifecycle"
+	"github.com/anekazek/simple-blockchain/pkg/snapshot"
+)
+
+// SnapshotFile is the name of the file in the data directory holding the
+// balances of a chain installed from a snapshot, whose earlier blocks are
+// pruned
+const SnapshotFile = "snapshot.json"
+
+// snapshotLoader loads the chain from storage like chainWriter, and when
+// fast sync is on and the store held no chain, installs a trusted snapshot
+// fetched from a peer before the regular sync. It implements
+// lifecycle.Loader.
+type snapshotLoader struct {
+	node  *Node
+	p2p   *network.P2PServer // Nil unless fast syncing over HTTP
+	trust snapshot.Trust
+}
+
+// Load restores the chain, or fast syncs an empty one
+func (l *snapshotLoader) Load(progress lifecycle.ProgressFunc) error {
+	n := l.node
+	if l.p2p != nil {
+		defer l.p2p.ResumeSync()
+	}
+	if err := n.writer.Load(progress); err != nil {
+		return err
+	}
+	if n.chain.GetLatestBlock().Index > 0 {
+		return l.restoreBase()
+	}
+	if l.p2p == nil {
+		return nil
+	}
+
+	fetched, err := l.p2p.FetchSnapshot(l.trust)
+	if err != nil {
+		log.Printf("No trusted snapshot to fast sync from, syncing every block: %v\n", err)
+		return nil
+	}
+	if err := l.install(fetched); err != nil {
+		return fmt.Errorf("failed to install snapshot: %w", err)
+	}
+	progress(fetched.Manifest.Height, fetched.Manifest.Height)
+	log.Printf("Installed snapshot at height %d from %s, with %d whole blocks\n", fetched.Manifest.Height, fetched.Peer, len(fetched.State.Blocks))
+	return nil
+}
+
+// install installs a fetched snapshot: the chain, the deployed contracts
+// and their storage, and the balances, then stores the blocks
+func (l *snapshotLoader) install(fetched *network.FetchedSnapshot) error {
+	n := l.node
+	manifest, state := fetched.Manifest, fetched.State
+	base := blockchain.SnapshotBase{Height: manifest.Height, Hash: manifest.TipHash, Balances: state.Balances}
+	if err := n.chain.InstallSnapshot(fetched.Blocks, base); err != nil {
+		return err
+	}
+
+	for _, contract := range state.Contracts {
+		if err := n.store.SaveContract(contract.StoredContract); err != nil {
+			return fmt.Errorf("failed to store contract %s: %w", contract.ID, err)
+		}
+	}
+	if err := n.server.LoadContracts(n.store); err != nil {
+		return fmt.Errorf("failed to load contracts: %w", err)
+	}
+	for _, contract := range state.Contracts {
+		n.server.RestoreContractState(contract.ID, contract.State)
+	}
+
+	if err := l.saveBase(base); err != nil {
+		return err
+	}
+	return n.writer.flush(context.Background())
+}
+
+// restoreBase reapplies the balances of a stored chain installed from a
+// snapshot. A stale file, left from a chain since replaced, is ignored.
+func (l *snapshotLoader) restoreBase() error {
+	data, err := os.ReadFile(l.basePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot balances: %w", err)
+	}
+	var base blockchain.SnapshotBase
+	if err := json.Unmarshal(data, &base); err != nil {
+		return fmt.Errorf("invalid snapshot balances in %s: %w", l.basePath(), err)
+	}
+	if err := l.node.chain.SetSnapshotBase(base); err != nil {
+		log.Printf("Ignoring snapshot balances in %s: %v\n", l.basePath(), err)
+	}
+	return nil
+}
+
+// saveBase writes the balances of an installed snapshot to the data
+// directory
+func (l *snapshotLoader) saveBase(base blockchain.SnapshotBase) error {
+	data, err := json.Marshal(base)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(l.node.cfg.Node.DataDir, 0o700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := os.WriteFile(l.basePath(), data, 0o600); err != nil {
+		return fmt.Errorf("failed to save snapshot balances: %w", err)
+	}
+	return nil
+}
+
+// basePath returns the path of SnapshotFile
+func (l *snapshotLoader) basePath() string {
+	return filepath.Join(l.node.cfg.Node.DataDir, SnapshotFile)
+}
+
+// buildSnapshot packages the chain up to height for peers to fast sync
+// from: the genesis block, the most recent whole blocks, the balances as of
+// height and the deployed contracts with their current storage, signed with
+// the node identity
+func (n *Node) buildSnapshot(height int) (*snapshot.Snapshot, error) {
+	blocks, balances, err := n.chain.SnapshotState(height)
+	if err != nil {
+		return nil, err
+	}
+	if blocks[len(blocks)-1].Pruned {
+		return nil, errors.New("no whole blocks to snapshot")
+	}
+
+	recent := []blockchain.Block{}
+	if !blocks[0].Pruned {
+		recent = append(recent, blocks[0])
+	}
+	for _, block := range blocks[max(1, len(blocks)-n.cfg.P2P.SnapshotBlocks):] {
+		if !block.Pruned {
+			recent = append(recent, block)
+		}
+	}
+
+	stored, err := n.store.ListContracts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list contracts: %w", err)
+	}
+	deployed := make([]snapshot.Contract, len(stored))
+	for i, contract := range stored {
+		deployed[i] = snapshot.Contract{StoredContract: contract, State: n.server.ContractState(contract.ID)}
+	}
+
+	s, err := snapshot.Build(snapshot.State{Blocks: recent, Balances: balances, Contracts: deployed})
+	if err != nil {
+		return nil, err
+	}
+	s.Sign(n.identity)
+	return s, nil
+}
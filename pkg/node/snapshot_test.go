@@ -0,0 +1,141 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/client"
+	"github.com/anekazek/simple-blockchain/pkg/config"
+	"github.com/anekazek/simple-blockchain/pkg/wallet"
+)
+
+// startNode builds and starts a node, stopping it when the test ends
+func startNode(t *testing.T, cfg config.Config) *Node {
+	t.Helper()
+	n, err := New(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		n.Stop(ctx)
+	})
+	return n
+}
+
+// mineBlocks mines count blocks on n's chain at its difficulty, so its peers
+// accept them
+func mineBlocks(t *testing.T, n *Node, count int) {
+	t.Helper()
+	for i := 0; i < count; i++ {
+		if _, err := n.Chain().AddBlock(n.Pool(), n.cfg.Consensus.Difficulty); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// devConfig is testConfig on a dev chain, whose faucet funds transactions
+func devConfig(t *testing.T) config.Config {
+	cfg := testConfig(t)
+	cfg.Dev.Enabled = true
+	return cfg
+}
+
+// deployCounter deploys a Lua contract through n's API and stores a value
+// in it, returning its ID
+func deployCounter(t *testing.T, n *Node) string {
+	t.Helper()
+	ctx := context.Background()
+	api := client.New("http://"+n.Addr().String(), "")
+	owner, err := wallet.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deploy := client.DeployRequest{Type: "lua", Name: "counter", Code: `function set(v) storage_set("value", v) end`}
+	deploy.Sign(owner, time.Now())
+	deployment, err := api.DeployContract(ctx, deploy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := client.ExecuteRequest{Function: "set", Params: json.RawMessage(`["42"]`)}
+	call.Sign(owner, deployment.ID, time.Now())
+	if _, err := api.ExecuteContract(ctx, deployment.ID, call); err != nil {
+		t.Fatal(err)
+	}
+	return deployment.ID
+}
+
+func TestFastSyncInstallsSnapshot(t *testing.T) {
+	full := startNode(t, devConfig(t))
+	contractID := deployCounter(t, full)
+	if err := full.Pool().AddTransaction(blockchain.NewFaucetTransaction("bob", 7, time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	mineBlocks(t, full, 300)
+	tip := full.Chain().GetLatestBlock()
+
+	cfg := devConfig(t)
+	cfg.P2P.FastSync = true
+	cfg.P2P.Peers = []string{full.cfg.P2P.AdvertiseAddr}
+	cfg.P2P.SnapshotSigners = []string{full.Identity().Address()}
+	fresh := startNode(t, cfg)
+
+	deadline := time.Now().Add(20 * time.Second)
+	for !fresh.State().IsReady() || fresh.Chain().GetLatestBlock().Hash != tip.Hash {
+		if time.Now().After(deadline) {
+			t.Fatalf("fresh node at height %d, want the full node's tip at %d", fresh.Chain().Height(), tip.Index)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if got, want := fresh.Chain().Balance("bob"), full.Chain().Balance("bob"); got != want || got != 7 {
+		t.Errorf("bob has %g on the fresh node, %g on the full node", got, want)
+	}
+	if got := string(fresh.Server().ContractState(contractID)["value"]); got != "42" {
+		t.Errorf("contract storage on the fresh node holds %q, want 42", got)
+	}
+
+	var whole int
+	for _, block := range fresh.Chain().GetBlocks() {
+		if !block.Pruned {
+			whole++
+		}
+	}
+	if limit := cfg.P2P.SnapshotBlocks + 1; whole > limit {
+		t.Errorf("fresh node downloaded %d whole blocks, want at most %d of %d", whole, limit, tip.Index+1)
+	}
+}
+
+func TestFastSyncRefusesUntrustedSnapshot(t *testing.T) {
+	full := startNode(t, devConfig(t))
+	mineBlocks(t, full, 100)
+	tip := full.Chain().GetLatestBlock()
+
+	cfg := devConfig(t)
+	cfg.P2P.FastSync = true
+	cfg.P2P.Peers = []string{full.cfg.P2P.AdvertiseAddr}
+	cfg.P2P.SyncInterval = 100 * time.Millisecond
+	fresh := startNode(t, cfg)
+
+	// With no trusted signer the node syncs every block instead
+	deadline := time.Now().Add(20 * time.Second)
+	for fresh.Chain().GetLatestBlock().Hash != tip.Hash {
+		if time.Now().After(deadline) {
+			t.Fatalf("fresh node at height %d, want %d", fresh.Chain().Height(), tip.Index)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	for _, block := range fresh.Chain().GetBlocks() {
+		if block.Pruned {
+			t.Fatalf("block %d was installed from an untrusted snapshot", block.Index)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/config"
+	"github.com/anekazek/simple-blockchain/pkg/contracts"
+	"github.com/anekazek/simple-blockchain/pkg/node/lifecycle"
+	"github.com/anekazek/simple-blockchain/pkg/storage"
+)
+
+// writeInterval is how often blocks added to the chain are written to the
+// store
+const writeInterval = time.Second
+
+// Store keeps a node's blocks and deployed contracts
+type Store interface {
+	storage.BlockchainStore
+	contracts.ContractStore
+}
+
+// openStore opens the configured storage backend
+func openStore(cfg config.StorageConfig) (Store, error) {
+	var store Store
+	switch cfg.Backend {
+	case config.BackendMemory:
+		store = storage.NewMemoryStore()
+	case config.BackendLevelDB:
+		store = storage.NewLevelDBStore(cfg.Path)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+	if err := store.Initialize(); err != nil {
+		return nil, fmt.Errorf("failed to open %s storage: %w", cfg.Backend, err)
+	}
+	return store, nil
+}
+
+// chainWriter loads the chain from a store at startup and then writes the
+// blocks added to the chain back to it, rewriting those replaced by a
+// reorganization and deleting those removed by a rollback
+type chainWriter struct {
+	chain *blockchain.Chain
+	store storage.BlockchainStore
+	saved []string // Hash of each stored block by index, nil until loaded
+	mutex sync.Mutex
+}
+
+// newChainWriter creates a writer between chain and store
+func newChainWriter(chain *blockchain.Chain, store storage.BlockchainStore) *chainWriter {
+	return &chainWriter{chain: chain, store: store}
+}
+
+// Load restores the chain from the store, or stores the genesis block of
+// an empty one. It implements lifecycle.Loader.
+func (w *chainWriter) Load(progress lifecycle.ProgressFunc) error {
+	blocks, err := w.store.GetAllBlocks()
+	if err != nil {
+		return err
+	}
+	if len(blocks) > 0 {
+		if err := w.chain.Restore(blocks); err != nil {
+			return fmt.Errorf("stored chain is invalid: %w", err)
+		}
+		progress(len(blocks)-1, len(blocks)-1)
+	}
+
+	w.mutex.Lock()
+	w.saved = make([]string, len(blocks))
+	for i, block := range blocks {
+		w.saved[i] = block.Hash
+	}
+	w.mutex.Unlock()
+	return w.flush(context.Background())
+}
+
+// run writes new blocks every writeInterval until ctx is done
+func (w *chainWriter) run(ctx context.Context) {
+	ticker := time.NewTicker(writeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.flush(ctx); err != nil {
+				log.Printf("Failed to store blocks: %v\n", err)
+			}
+		}
+	}
+}
+
+// flush writes the blocks of the chain that aren't stored yet, or that
+// differ from the stored ones, and deletes stored blocks above the tip. It
+// does nothing until the chain is loaded. Only the blocks from the lowest
+// one that changed are read, so a flush costs the blocks it writes rather
+// than the length of the chain.
+func (w *chainWriter) flush(ctx context.Context) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.saved == nil {
+		return nil
+	}
+
+	height := w.chain.Height()
+	if height+1 < len(w.saved) {
+		if err := w.store.Truncate(height); err != nil {
+			return fmt.Errorf("failed to delete blocks above %d: %w", height, err)
+		}
+		w.saved = w.saved[:height+1]
+	}
+
+	// Walk down past the stored blocks a reorganization replaced
+	from := len(w.saved)
+	for from > 0 {
+		blocks := w.chain.BlocksFrom(from-1, 1)
+		if len(blocks) == 1 && blocks[0].Hash == w.saved[from-1] {
+			break
+		}
+		from--
+	}
+	if from > height {
+		return nil
+	}
+
+	var saveErr error
+	err := w.chain.Iterate(from, math.MaxInt, func(block blockchain.Block) bool {
+		if saveErr = storage.SaveBlockContext(ctx, w.store, block); saveErr != nil {
+			saveErr = fmt.Errorf("failed to store block %d: %w", block.Index, saveErr)
+			return false
+		}
+		if block.Index < len(w.saved) {
+			w.saved[block.Index] = block.Hash
+		} else {
+			w.saved = append(w.saved, block.Hash)
+		}
+		return true
+	})
+	if saveErr != nil {
+		return saveErr
+	}
+	// A chain replaced meanwhile is written by the next flush
+	if errors.Is(err, blockchain.ErrChainChanged) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,117 @@
+package node
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"unsafe"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/storage"
+)
+
+// newLoadedWriter returns a writer between a chain of height blocks above
+// genesis and an empty memory store, loaded and flushed
+func newLoadedWriter(t *testing.T, height int) (*chainWriter, *blockchain.Chain, storage.BlockchainStore) {
+	t.Helper()
+	chain := blockchain.NewBlockchain()
+	addBlocks(t, chain, height)
+	store := storage.NewMemoryStore()
+	if err := store.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	w := newChainWriter(chain, store)
+	if err := w.Load(func(int, int) {}); err != nil {
+		t.Fatal(err)
+	}
+	return w, chain, store
+}
+
+// addBlocks mines n empty blocks onto chain
+func addBlocks(t *testing.T, chain *blockchain.Chain, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if _, err := chain.AddBlock(nil, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkStored fails the test unless store holds exactly chain's blocks
+func checkStored(t *testing.T, chain *blockchain.Chain, store storage.BlockchainStore) {
+	t.Helper()
+	stored, err := store.GetAllBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks := chain.GetBlocks()
+	if len(stored) != len(blocks) {
+		t.Fatalf("store holds %d blocks, chain has %d", len(stored), len(blocks))
+	}
+	for i := range blocks {
+		if stored[i].Hash != blocks[i].Hash {
+			t.Fatalf("stored block %d is %s, chain has %s", i, stored[i].Hash, blocks[i].Hash)
+		}
+	}
+}
+
+func TestFlushWritesNewBlocks(t *testing.T) {
+	w, chain, store := newLoadedWriter(t, 3)
+	addBlocks(t, chain, 2)
+	if err := w.flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	checkStored(t, chain, store)
+}
+
+func TestFlushRewritesReorganizedBlocks(t *testing.T) {
+	w, chain, store := newLoadedWriter(t, 5)
+
+	// A heavier branch from block 3 replaces blocks 4 and 5
+	fork := chain.GetBlocks()[:4]
+	for i := 0; i < 3; i++ {
+		block, _, err := blockchain.GenerateBlock(fork[len(fork)-1], nil, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fork = append(fork, block)
+	}
+	if !chain.ReplaceChain(fork) {
+		t.Fatal("heavier branch not adopted")
+	}
+
+	if err := w.flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	checkStored(t, chain, store)
+}
+
+func TestFlushDeletesRolledBackBlocks(t *testing.T) {
+	w, chain, store := newLoadedWriter(t, 5)
+	if _, err := chain.RollbackToHeight(2, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	checkStored(t, chain, store)
+}
+
+func TestFlushDoesNotCopyTheChain(t *testing.T) {
+	w, chain, _ := newLoadedWriter(t, 2000)
+	addBlocks(t, chain, 1)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if err := w.flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+
+	// Copying the chain would allocate its 2002 blocks; reading one batch
+	// allocates IterateBatchSize
+	limit := uint64(2*blockchain.IterateBatchSize) * uint64(unsafe.Sizeof(blockchain.Block{}))
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > limit {
+		t.Fatalf("flushing one block allocated %d bytes, want under %d", allocated, limit)
+	}
+}
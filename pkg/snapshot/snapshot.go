@@ -0,0 +1,200 @@
+// Package snapshot packages a node's state for fast sync: the most recent
+// blocks, the balances as of the tip, and the deployed contracts with their
+// storage. A snapshot is served as a manifest listing content-addressed
+// chunks, so a joining node can fetch the chunks from any peer and verify
+// each against the manifest.
+package snapshot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/contracts"
+)
+
+// Version is the snapshot format version
+const Version = 1
+
+// blocksPerChunk is how many blocks are packed into one chunk
+const blocksPerChunk = 64
+
+// Chunk kinds
+const (
+	// KindBlocks holds a run of whole blocks
+	KindBlocks = "blocks"
+	// KindBalances holds the balance of every address as of the tip
+	KindBalances = "balances"
+	// KindContracts holds the deployed contracts and their storage
+	KindContracts = "contracts"
+)
+
+// ErrUnknownChunk is returned for a chunk the snapshot doesn't hold
+var ErrUnknownChunk = errors.New("unknown snapshot chunk")
+
+// ChunkInfo describes one chunk of a snapshot
+type ChunkInfo struct {
+	Kind string `json:"kind"`
+	Hash string `json:"hash"` // Hex SHA-256 of the chunk
+	Size int    `json:"size"`
+}
+
+// Manifest describes a snapshot and the chunks it is made of
+type Manifest struct {
+	Version     int         `json:"version"`
+	Height      int         `json:"height"`
+	TipHash     string      `json:"tipHash"`
+	GenesisHash string      `json:"genesisHash"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	Chunks      []ChunkInfo `json:"chunks"`
+
+	// Set by Sign
+	Signer    string `json:"signer,omitempty"` // Address of the signing key
+	PublicKey string `json:"publicKey,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// Contract is a deployed contract with its storage
+type Contract struct {
+	contracts.StoredContract
+	State map[string][]byte `json:"state,omitempty"`
+}
+
+// State is what a snapshot holds
+type State struct {
+	// Blocks are the genesis block and the most recent blocks, ending at
+	// the tip. Pruned blocks are left out.
+	Blocks    []blockchain.Block
+	Balances  map[string]float64
+	Contracts []Contract
+}
+
+// Snapshot is a manifest and the chunks it lists
+type Snapshot struct {
+	Manifest Manifest
+	chunks   map[string][]byte
+}
+
+// Build packages state into a snapshot of its last block
+func Build(state State) (*Snapshot, error) {
+	if len(state.Blocks) == 0 {
+		return nil, errors.New("snapshot has no blocks")
+	}
+	genesis, tip := state.Blocks[0], state.Blocks[len(state.Blocks)-1]
+	s := &Snapshot{
+		Manifest: Manifest{
+			Version:     Version,
+			Height:      tip.Index,
+			TipHash:     tip.Hash,
+			GenesisHash: genesis.Hash,
+			CreatedAt:   time.Now().UTC(),
+		},
+		chunks: make(map[string][]byte),
+	}
+
+	for start := 0; start < len(state.Blocks); start += blocksPerChunk {
+		end := min(start+blocksPerChunk, len(state.Blocks))
+		if err := s.add(KindBlocks, state.Blocks[start:end]); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.add(KindBalances, state.Balances); err != nil {
+		return nil, err
+	}
+	if err := s.add(KindContracts, state.Contracts); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// add encodes v as a chunk of the given kind
+func (s *Snapshot) add(kind string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s chunk: %w", kind, err)
+	}
+	hash := chunkHash(data)
+	s.chunks[hash] = data
+	s.Manifest.Chunks = append(s.Manifest.Chunks, ChunkInfo{Kind: kind, Hash: hash, Size: len(data)})
+	return nil
+}
+
+// Chunk returns the chunk with the given hash
+func (s *Snapshot) Chunk(hash string) ([]byte, error) {
+	data, ok := s.chunks[hash]
+	if !ok {
+		return nil, ErrUnknownChunk
+	}
+	return data, nil
+}
+
+// chunkHash returns the hex SHA-256 of a chunk
+func chunkHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyChunk checks that data is the chunk info describes
+func VerifyChunk(info ChunkInfo, data []byte) error {
+	if len(data) != info.Size || chunkHash(data) != info.Hash {
+		return fmt.Errorf("%s chunk %s doesn't match the manifest", info.Kind, info.Hash)
+	}
+	return nil
+}
+
+// Decode reassembles the state from the chunks of a manifest, by hash,
+// after verifying each. The blocks must run from genesis to the tip the
+// manifest names.
+func Decode(manifest Manifest, chunks map[string][]byte) (State, error) {
+	if manifest.Version != Version {
+		return State{}, fmt.Errorf("unsupported snapshot version %d", manifest.Version)
+	}
+
+	var state State
+	for _, info := range manifest.Chunks {
+		data, ok := chunks[info.Hash]
+		if !ok {
+			return State{}, fmt.Errorf("missing %s chunk %s", info.Kind, info.Hash)
+		}
+		if err := VerifyChunk(info, data); err != nil {
+			return State{}, err
+		}
+
+		var err error
+		switch info.Kind {
+		case KindBlocks:
+			var blocks []blockchain.Block
+			if err = json.Unmarshal(data, &blocks); err == nil {
+				state.Blocks = append(state.Blocks, blocks...)
+			}
+		case KindBalances:
+			err = json.Unmarshal(data, &state.Balances)
+		case KindContracts:
+			err = json.Unmarshal(data, &state.Contracts)
+		default:
+			err = errors.New("unknown chunk kind")
+		}
+		if err != nil {
+			return State{}, fmt.Errorf("invalid %s chunk %s: %w", info.Kind, info.Hash, err)
+		}
+	}
+
+	if len(state.Blocks) == 0 {
+		return State{}, errors.New("snapshot has no blocks")
+	}
+	genesis, tip := state.Blocks[0], state.Blocks[len(state.Blocks)-1]
+	if genesis.Index != 0 || genesis.Hash != manifest.GenesisHash {
+		return State{}, errors.New("snapshot genesis doesn't match the manifest")
+	}
+	if tip.Index != manifest.Height || tip.Hash != manifest.TipHash {
+		return State{}, errors.New("snapshot tip doesn't match the manifest")
+	}
+	if state.Balances == nil {
+		state.Balances = make(map[string]float64)
+	}
+	return state, nil
+}
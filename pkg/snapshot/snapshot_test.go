@@ -0,0 +1,135 @@
+package snapshot
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/contracts"
+	"github.com/anekazek/simple-blockchain/pkg/wallet"
+)
+
+// testState returns the state of a chain of height blocks with a balance
+// and a contract
+func testState(t *testing.T, height int) State {
+	t.Helper()
+	chain := blockchain.NewBlockchain()
+	for i := 0; i < height; i++ {
+		if _, err := chain.AddBlock(nil, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return State{
+		Blocks:   chain.GetBlocks(),
+		Balances: map[string]float64{"bob": 7},
+		Contracts: []Contract{{
+			StoredContract: contracts.StoredContract{ID: "c", Type: contracts.TypeLua, Code: []byte("function f() end")},
+			State:          map[string][]byte{"value": []byte("42")},
+		}},
+	}
+}
+
+// chunksOf returns every chunk of s by hash
+func chunksOf(t *testing.T, s *Snapshot) map[string][]byte {
+	t.Helper()
+	chunks := make(map[string][]byte)
+	for _, info := range s.Manifest.Chunks {
+		data, err := s.Chunk(info.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks[info.Hash] = data
+	}
+	return chunks
+}
+
+func TestBuildAndDecode(t *testing.T) {
+	state := testState(t, 2*blocksPerChunk+1)
+	s, err := Build(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Manifest.Height != len(state.Blocks)-1 || s.Manifest.TipHash != state.Blocks[len(state.Blocks)-1].Hash {
+		t.Fatalf("manifest %+v doesn't name the tip", s.Manifest)
+	}
+	// Three block chunks, the balances and the contracts
+	if n := len(s.Manifest.Chunks); n != 5 {
+		t.Fatalf("%d chunks, want 5", n)
+	}
+
+	got, err := Decode(s.Manifest, chunksOf(t, s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Blocks) != len(state.Blocks) || got.Blocks[len(got.Blocks)-1].Hash != s.Manifest.TipHash {
+		t.Fatalf("decoded %d blocks, want %d", len(got.Blocks), len(state.Blocks))
+	}
+	if got.Balances["bob"] != 7 {
+		t.Fatalf("decoded balances %v", got.Balances)
+	}
+	if len(got.Contracts) != 1 || string(got.Contracts[0].State["value"]) != "42" {
+		t.Fatalf("decoded contracts %+v", got.Contracts)
+	}
+}
+
+func TestDecodeRejectsTamperedChunks(t *testing.T) {
+	s, err := Build(testState(t, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := chunksOf(t, s)
+	balances := s.Manifest.Chunks[1]
+	chunks[balances.Hash] = []byte(`{"bob":7000}`)
+	if _, err := Decode(s.Manifest, chunks); err == nil {
+		t.Fatal("decoded a tampered chunk")
+	}
+
+	chunks = chunksOf(t, s)
+	delete(chunks, balances.Hash)
+	if _, err := Decode(s.Manifest, chunks); err == nil {
+		t.Fatal("decoded with a chunk missing")
+	}
+
+	manifest := s.Manifest
+	manifest.TipHash = "other"
+	if _, err := Decode(manifest, chunksOf(t, s)); err == nil {
+		t.Fatal("decoded blocks ending at another tip than the manifest's")
+	}
+}
+
+func TestTrust(t *testing.T) {
+	signer, err := wallet.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Build(testState(t, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := s.Manifest
+	s.Sign(signer)
+
+	if err := (Trust{Signers: []string{signer.Address()}}).Check(s.Manifest); err != nil {
+		t.Fatalf("snapshot signed by a trusted key: %v", err)
+	}
+	if err := (Trust{Signers: []string{"someone else"}}).Check(s.Manifest); !errors.Is(err, ErrUntrusted) {
+		t.Fatalf("snapshot signed by another key: got %v, want ErrUntrusted", err)
+	}
+	if err := (Trust{Signers: []string{signer.Address()}}).Check(unsigned); !errors.Is(err, ErrUntrusted) {
+		t.Fatalf("unsigned snapshot: got %v, want ErrUntrusted", err)
+	}
+
+	altered := s.Manifest
+	altered.Height++
+	if err := (Trust{Signers: []string{signer.Address()}}).Check(altered); err == nil {
+		t.Fatal("trusted a manifest altered after signing")
+	}
+
+	if err := (Trust{Checkpoint: &Checkpoint{Height: 3, Hash: "aa"}}).Check(unsigned); err != nil {
+		t.Fatalf("unsigned snapshot at the checkpoint: %v", err)
+	}
+	if err := (Trust{Checkpoint: &Checkpoint{Height: 2, Hash: "aa"}}).Check(unsigned); !errors.Is(err, ErrUntrusted) {
+		t.Fatalf("unsigned snapshot above the checkpoint: got %v, want ErrUntrusted", err)
+	}
+}
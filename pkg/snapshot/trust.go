@@ -0,0 +1,80 @@
+package snapshot
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/wallet"
+)
+
+// ErrUntrusted is returned for a snapshot neither signed by a trusted key
+// nor covered by the checkpoint
+var ErrUntrusted = errors.New("snapshot is not trusted")
+
+// Checkpoint is a block known to be on the canonical chain
+type Checkpoint = blockchain.Checkpoint
+
+// ParseCheckpoint parses a checkpoint written as height:hash
+func ParseCheckpoint(s string) (Checkpoint, error) {
+	return blockchain.ParseCheckpoint(s)
+}
+
+// Trust decides which snapshots a node installs. A snapshot is trusted when
+// it is signed by one of Signers, or when its tip is at or below the
+// Checkpoint, whose hash the header download must then confirm.
+type Trust struct {
+	Signers    []string    // Addresses of the keys trusted to sign snapshots
+	Checkpoint *Checkpoint // Optional
+}
+
+// Check reports whether the manifest is trusted
+func (t Trust) Check(manifest Manifest) error {
+	if t.Checkpoint != nil && manifest.Height <= t.Checkpoint.Height {
+		return nil
+	}
+	if manifest.Signature == "" {
+		return ErrUntrusted
+	}
+	for _, signer := range t.Signers {
+		if signer == manifest.Signer {
+			return manifest.Verify()
+		}
+	}
+	return fmt.Errorf("%w: signed by %s", ErrUntrusted, manifest.Signer)
+}
+
+// signingBytes returns the bytes signed for the manifest: its JSON without
+// the signature fields
+func (m Manifest) signingBytes() []byte {
+	m.Signer, m.PublicKey, m.Signature = "", "", ""
+	data, _ := json.Marshal(m)
+	return data
+}
+
+// Sign signs the manifest with w
+func (s *Snapshot) Sign(w *wallet.Wallet) {
+	signature := ed25519.Sign(w.PrivateKey, s.Manifest.signingBytes())
+	s.Manifest.Signer = w.Address()
+	s.Manifest.PublicKey = hex.EncodeToString(w.PublicKey)
+	s.Manifest.Signature = hex.EncodeToString(signature)
+}
+
+// Verify checks the manifest's signature and that its key is the signer's
+func (m Manifest) Verify() error {
+	publicKey, err := hex.DecodeString(m.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return errors.New("snapshot has an invalid public key")
+	}
+	if wallet.AddressFromPublicKey(publicKey) != m.Signer {
+		return errors.New("snapshot key doesn't match its signer")
+	}
+	signature, err := hex.DecodeString(m.Signature)
+	if err != nil || !ed25519.Verify(publicKey, m.signingBytes(), signature) {
+		return errors.New("snapshot has an invalid signature")
+	}
+	return nil
+}
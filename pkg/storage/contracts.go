@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/anekazek/simple-blockchain/pkg/contracts"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// contractPrefix prefixes the keys of stored contracts
+const contractPrefix = "contract"
+
+// SaveContract persists a deployed contract, replacing any with the same ID
+func (s *LevelDBStore) SaveContract(contract contracts.StoredContract) error {
+	if s.db == nil {
+		return errors.New("database not initialized")
+	}
+
+	data, err := json.Marshal(contract)
+	if err != nil {
+		return fmt.Errorf("failed to marshal contract: %w", err)
+	}
+	if err := s.db.Put([]byte(contractPrefix+contract.ID), data, nil); err != nil {
+		return fmt.Errorf("failed to store contract: %w", err)
+	}
+	return nil
+}
+
+// DeleteContract removes a stored contract
+func (s *LevelDBStore) DeleteContract(id string) error {
+	if s.db == nil {
+		return errors.New("database not initialized")
+	}
+
+	if err := s.db.Delete([]byte(contractPrefix+id), nil); err != nil {
+		return fmt.Errorf("failed to delete contract: %w", err)
+	}
+	return nil
+}
+
+// ListContracts returns every stored contract, oldest first
+func (s *LevelDBStore) ListContracts() ([]contracts.StoredContract, error) {
+	if s.db == nil {
+		return nil, errors.New("database not initialized")
+	}
+
+	iter := s.db.NewIterator(util.BytesPrefix([]byte(contractPrefix)), nil)
+	defer iter.Release()
+
+	var stored []contracts.StoredContract
+	for iter.Next() {
+		var contract contracts.StoredContract
+		if err := json.Unmarshal(iter.Value(), &contract); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal contract %s: %w", iter.Key()[len(contractPrefix):], err)
+		}
+		stored = append(stored, contract)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("failed to read contracts: %w", err)
+	}
+
+	sort.Slice(stored, func(i, j int) bool {
+		return stored[i].CreatedAt.Before(stored[j].CreatedAt)
+	})
+	return stored, nil
+}
@@ -160,6 +160,40 @@ func (s *LevelDBStore) GetLatestBlock() (blockchain.Block, error) {
 	return s.GetBlock(string(hashBytes))
 }
 
+// Truncate deletes the blocks above height in one batch
+func (s *LevelDBStore) Truncate(height int) error {
+	if s.db == nil {
+		return errors.New("database not initialized")
+	}
+	if height >= s.lastIndex {
+		return nil
+	}
+
+	batch := new(leveldb.Batch)
+	for index := height + 1; index <= s.lastIndex; index++ {
+		block, err := s.GetBlockByIndex(index)
+		if err != nil {
+			return err
+		}
+		batch.Delete([]byte("hash" + block.Hash))
+		batch.Delete([]byte("index" + strconv.Itoa(index)))
+	}
+	if height < 0 {
+		batch.Delete([]byte("latest"))
+	} else {
+		latest, err := s.GetBlockByIndex(height)
+		if err != nil {
+			return err
+		}
+		batch.Put([]byte("latest"), []byte(latest.Hash))
+	}
+	if err := s.db.Write(batch, nil); err != nil {
+		return fmt.Errorf("failed to truncate blocks: %w", err)
+	}
+	s.lastIndex = height
+	return nil
+}
+
 // Close closes the database connection
 func (s *LevelDBStore) Close() error {
 	if s.db != nil {
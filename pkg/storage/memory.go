@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"github.com/anekazek/simple-blockchain/pkg/contracts"
+)
+
+// MemoryStore implements BlockchainStore and contracts.ContractStore in
+// memory, for nodes that don't keep data across restarts and for tests
+type MemoryStore struct {
+	byHash    map[string]blockchain.Block
+	byIndex   map[int]blockchain.Block
+	lastIndex int
+	contracts map[string]contracts.StoredContract
+	mutex     sync.RWMutex
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		byHash:    make(map[string]blockchain.Block),
+		byIndex:   make(map[int]blockchain.Block),
+		lastIndex: -1,
+		contracts: make(map[string]contracts.StoredContract),
+	}
+}
+
+// Initialize does nothing; a memory store is ready once created
+func (s *MemoryStore) Initialize() error {
+	return nil
+}
+
+// SaveBlock stores a block, replacing any at the same index
+func (s *MemoryStore) SaveBlock(block blockchain.Block) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.byHash[block.Hash] = block
+	s.byIndex[block.Index] = block
+	if block.Index > s.lastIndex {
+		s.lastIndex = block.Index
+	}
+	return nil
+}
+
+// GetBlock retrieves a block by its hash
+func (s *MemoryStore) GetBlock(hash string) (blockchain.Block, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	block, ok := s.byHash[hash]
+	if !ok {
+		return blockchain.Block{}, fmt.Errorf("block not found: %s", hash)
+	}
+	return block, nil
+}
+
+// GetBlockByIndex retrieves a block by its index
+func (s *MemoryStore) GetBlockByIndex(index int) (blockchain.Block, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	block, ok := s.byIndex[index]
+	if !ok {
+		return blockchain.Block{}, fmt.Errorf("block not found at index %d", index)
+	}
+	return block, nil
+}
+
+// GetAllBlocks retrieves all blocks in index order
+func (s *MemoryStore) GetAllBlocks() ([]blockchain.Block, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	blocks := make([]blockchain.Block, s.lastIndex+1)
+	for i := range blocks {
+		block, ok := s.byIndex[i]
+		if !ok {
+			return nil, fmt.Errorf("failed to get block at index %d: block not found", i)
+		}
+		blocks[i] = block
+	}
+	return blocks, nil
+}
+
+// GetLatestBlock retrieves the block with the highest index
+func (s *MemoryStore) GetLatestBlock() (blockchain.Block, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.lastIndex < 0 {
+		return blockchain.Block{}, errors.New("latest block not found: store is empty")
+	}
+	return s.byIndex[s.lastIndex], nil
+}
+
+// Truncate deletes the blocks above height
+func (s *MemoryStore) Truncate(height int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for index := height + 1; index <= s.lastIndex; index++ {
+		if block, ok := s.byIndex[index]; ok {
+			delete(s.byHash, block.Hash)
+			delete(s.byIndex, index)
+		}
+	}
+	s.lastIndex = min(s.lastIndex, height)
+	return nil
+}
+
+// Close does nothing; the data is released with the store
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// SaveContract stores a deployed contract, replacing any with the same ID
+func (s *MemoryStore) SaveContract(contract contracts.StoredContract) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.contracts[contract.ID] = contract
+	return nil
+}
+
+// DeleteContract removes a stored contract
+func (s *MemoryStore) DeleteContract(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.contracts, id)
+	return nil
+}
+
+// ListContracts returns every stored contract, oldest first
+func (s *MemoryStore) ListContracts() ([]contracts.StoredContract, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	stored := make([]contracts.StoredContract, 0, len(s.contracts))
+	for _, contract := range s.contracts {
+		stored = append(stored, contract)
+	}
+	sort.Slice(stored, func(i, j int) bool {
+		return stored[i].CreatedAt.Before(stored[j].CreatedAt)
+	})
+	return stored, nil
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// Stats describes a store's size and, for LevelDB, its engine internals
+type Stats struct {
+	SizeBytes int64         // Size of the stored data on disk
+	LevelDB   *LevelDBStats // Nil for other backends
+}
+
+// LevelDBStats are the LevelDB internals reported by a LevelDBStore
+type LevelDBStats struct {
+	LevelSizes      []int64 // Bytes in each level's tables
+	LevelTables     []int   // Tables in each level
+	BlockCacheBytes int
+	OpenTables      int
+	IOReadBytes     uint64
+	IOWriteBytes    uint64
+	WriteDelays     int32 // Writes delayed by compaction
+	WriteDelay      time.Duration
+	WritePaused     bool
+}
+
+// StatsReporter is implemented by stores that can describe their size
+type StatsReporter interface {
+	Stats() (Stats, error)
+}
+
+// Stats reports the database's size and LevelDB internals
+func (s *LevelDBStore) Stats() (Stats, error) {
+	if s.db == nil {
+		return Stats{}, errors.New("database not initialized")
+	}
+
+	var dbStats leveldb.DBStats
+	if err := s.db.Stats(&dbStats); err != nil {
+		return Stats{}, err
+	}
+
+	stats := Stats{LevelDB: &LevelDBStats{
+		LevelSizes:      dbStats.LevelSizes,
+		LevelTables:     dbStats.LevelTablesCounts,
+		BlockCacheBytes: dbStats.BlockCacheSize,
+		OpenTables:      dbStats.OpenedTablesCount,
+		IOReadBytes:     dbStats.IORead,
+		IOWriteBytes:    dbStats.IOWrite,
+		WriteDelays:     dbStats.WriteDelayCount,
+		WriteDelay:      dbStats.WriteDelayDuration,
+		WritePaused:     dbStats.WritePaused,
+	}}
+	for _, size := range dbStats.LevelSizes {
+		stats.SizeBytes += size
+	}
+	return stats, nil
+}
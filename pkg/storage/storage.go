@@ -24,6 +24,9 @@ type BlockchainStore interface {
 	// GetLatestBlock retrieves the most recent block
 	GetLatestBlock() (blockchain.Block, error)
 
+	// Truncate deletes the blocks above height, such as after a rollback
+	Truncate(height int) error
+
 	// Close closes the storage connection
 	Close() error
 }
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/anekazek/simple-blockchain/pkg/blockchain"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer returns the tracer of storage write spans from the current global
+// provider
+func tracer() trace.Tracer {
+	return otel.Tracer("github.com/anekazek/simple-blockchain/pkg/storage")
+}
+
+// SaveBlockContext persists a block to store in a span nested under the
+// one in ctx
+func SaveBlockContext(ctx context.Context, store BlockchainStore, block blockchain.Block) error {
+	_, span := tracer().Start(ctx, "storage.save_block", trace.WithAttributes(attribute.Int("block.index", block.Index)))
+	defer span.End()
+
+	if err := store.SaveBlock(block); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// Package tracing configures OpenTelemetry tracing for the node. Packages
+// create spans through the global tracer provider, which is a no-op until
+// Setup installs an exporting one.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Config controls tracing
+type Config struct {
+	// Enabled exports spans over OTLP/HTTP. The exporter reads its endpoint,
+	// headers and TLS settings from the standard OTEL_EXPORTER_OTLP_*
+	// environment variables.
+	Enabled bool
+	// ServiceName identifies the node in traces
+	ServiceName string
+	// SampleRatio is the share of new traces recorded, from 0 to 1. Requests
+	// carrying a sampled parent trace are always recorded.
+	SampleRatio float64
+}
+
+// DefaultConfig returns the default tracing settings: disabled
+func DefaultConfig() Config {
+	return Config{
+		ServiceName: "simple-blockchain",
+		SampleRatio: 1,
+	}
+}
+
+// Setup installs a tracer provider exporting over OTLP when tracing is
+// enabled, and returns a function flushing and stopping it. When tracing
+// is disabled spans cost nothing and the returned function does nothing.
+func Setup(ctx context.Context, config Config) (func(context.Context) error, error) {
+	if !config.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", config.ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	Install(provider)
+	return provider.Shutdown, nil
+}
+
+// Install makes provider the source of every package's spans and accepts
+// W3C trace context from incoming requests
+func Install(provider *sdktrace.TracerProvider) {
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
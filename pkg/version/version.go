@@ -0,0 +1,47 @@
+// Package version identifies the running build. Version, Commit and
+// BuildDate are set at build time with
+//
+//	go build -ldflags "-X github.com/anekazek/simple-blockchain/pkg/version.Version=v1.2.3 \
+//		-X github.com/anekazek/simple-blockchain/pkg/version.Commit=$(git rev-parse HEAD)"
+package version
+
+import "runtime"
+
+// Build details, overridden with -ldflags -X
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// Versions of the interfaces this build speaks
+const (
+	// APIVersion is the version of the REST and WebSocket API
+	APIVersion = "1"
+	// ProtocolVersion is the version of the P2P protocol, exchanged in the
+	// peer handshake
+	ProtocolVersion = 1
+)
+
+// Info describes the running build
+type Info struct {
+	Version         string `json:"version"`
+	Commit          string `json:"commit"`
+	BuildDate       string `json:"buildDate"`
+	GoVersion       string `json:"goVersion"`
+	APIVersion      string `json:"apiVersion"`
+	ProtocolVersion int    `json:"protocolVersion"`
+	NodeID          string `json:"nodeId,omitempty"` // Set by the node serving it
+}
+
+// Get returns the running build's details
+func Get() Info {
+	return Info{
+		Version:         Version,
+		Commit:          Commit,
+		BuildDate:       BuildDate,
+		GoVersion:       runtime.Version(),
+		APIVersion:      APIVersion,
+		ProtocolVersion: ProtocolVersion,
+	}
+}
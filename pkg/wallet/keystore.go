@@ -0,0 +1,131 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Errors opening an encrypted key file
+var (
+	ErrPassphraseRequired = errors.New("key file is encrypted and needs a passphrase")
+	ErrWrongPassphrase    = errors.New("wrong passphrase or corrupted key file")
+)
+
+// Parameters of the key derivation of new encrypted key files
+const (
+	kdfName       = "pbkdf2-sha256"
+	kdfIterations = 600000
+	kdfSaltSize   = 16
+)
+
+// sealedKey is a private key encrypted with AES-256-GCM under a key derived
+// from a passphrase
+type sealedKey struct {
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       string `json:"salt"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// SaveEncrypted writes the wallet to a new key file like Save, with the
+// private key encrypted under the passphrase
+func (w *Wallet) SaveEncrypted(path, passphrase string) error {
+	if passphrase == "" {
+		return errors.New("empty passphrase")
+	}
+	sealed, err := seal(w.PrivateKey, passphrase)
+	if err != nil {
+		return err
+	}
+	return writeKeyFile(path, keyFile{
+		Address:   w.Address(),
+		PublicKey: hex.EncodeToString(w.PublicKey),
+		Sealed:    sealed,
+	})
+}
+
+// seal encrypts a private key under a passphrase
+func seal(privateKey []byte, passphrase string) (*sealedKey, error) {
+	salt := make([]byte, kdfSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, salt, kdfIterations)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return &sealedKey{
+		KDF:        kdfName,
+		Iterations: kdfIterations,
+		Salt:       hex.EncodeToString(salt),
+		Nonce:      hex.EncodeToString(nonce),
+		Ciphertext: hex.EncodeToString(aead.Seal(nil, nonce, privateKey, nil)),
+	}, nil
+}
+
+// open decrypts the private key
+func (s *sealedKey) open(passphrase string) ([]byte, error) {
+	if s.KDF != kdfName {
+		return nil, fmt.Errorf("%w: unsupported kdf %q", ErrInvalidKeyFile, s.KDF)
+	}
+	salt, saltErr := hex.DecodeString(s.Salt)
+	nonce, nonceErr := hex.DecodeString(s.Nonce)
+	ciphertext, ciphertextErr := hex.DecodeString(s.Ciphertext)
+	if saltErr != nil || nonceErr != nil || ciphertextErr != nil || s.Iterations <= 0 {
+		return nil, fmt.Errorf("%w: malformed encryption parameters", ErrInvalidKeyFile)
+	}
+
+	aead, err := newAEAD(passphrase, salt, s.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("%w: malformed nonce", ErrInvalidKeyFile)
+	}
+	privateKey, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return privateKey, nil
+}
+
+// newAEAD creates the AES-256-GCM cipher keyed by the passphrase
+func newAEAD(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2SHA256([]byte(passphrase), salt, iterations))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2SHA256 derives a 32-byte key with PBKDF2-HMAC-SHA256 (RFC 8018).
+// One block of output is all AES-256 needs.
+func pbkdf2SHA256(password, salt []byte, iterations int) []byte {
+	prf := hmac.New(sha256.New, password)
+	prf.Write(salt)
+	prf.Write(binary.BigEndian.AppendUint32(nil, 1))
+	u := prf.Sum(nil)
+
+	key := append([]byte{}, u...)
+	for i := 1; i < iterations; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
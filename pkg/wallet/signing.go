@@ -0,0 +1,68 @@
+package wallet
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature authenticates an API request. It is sent in the request's JSON
+// body alongside its other fields.
+type Signature struct {
+	PublicKey string `json:"publicKey,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"` // Unix seconds
+}
+
+// SigningBytes returns the bytes signed for a request:
+//
+//	action|contractID|subject|timestamp
+func SigningBytes(action, contractID, subject string, timestamp int64) []byte {
+	return []byte(strings.Join([]string{action, contractID, subject, strconv.FormatInt(timestamp, 10)}, "|"))
+}
+
+// Sign signs a request made at now
+func (w *Wallet) Sign(action, contractID, subject string, now time.Time) Signature {
+	timestamp := now.Unix()
+	signature := ed25519.Sign(w.PrivateKey, SigningBytes(action, contractID, subject, timestamp))
+	return Signature{
+		PublicKey: hex.EncodeToString(w.PublicKey),
+		Signature: hex.EncodeToString(signature),
+		Timestamp: timestamp,
+	}
+}
+
+// CodeHash returns the subject signed for a deploy, the hex SHA-256 of the
+// code as sent
+func CodeHash(code string) string {
+	return hashHex([]byte(code))
+}
+
+// CallHash returns the subject signed for an execution: the hex SHA-256 of
+// the function name and the params JSON exactly as sent, joined by "|"
+func CallHash(function string, params []byte) string {
+	return hashHex(append([]byte(function+"|"), params...))
+}
+
+// TransactionHash returns the subject signed for a transaction: the hex
+// SHA-256 of its sender, recipient, value, fee and data joined by "|",
+// with numbers in their shortest decimal form
+func TransactionHash(from, to string, value, fee float64, data string) string {
+	return hashHex([]byte(strings.Join([]string{
+		from, to, formatAmount(value), formatAmount(fee), data,
+	}, "|")))
+}
+
+// formatAmount writes an amount in its shortest decimal form
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', -1, 64)
+}
+
+// hashHex returns the hex SHA-256 of data
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
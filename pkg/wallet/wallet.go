@@ -0,0 +1,132 @@
+// Package wallet holds ed25519 keys, saves them to key files, and signs
+// API requests with them.
+package wallet
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrInvalidKeyFile is returned when a key file can't be decoded or its
+// keys don't match
+var ErrInvalidKeyFile = errors.New("invalid key file")
+
+// Wallet is an ed25519 key pair
+type Wallet struct {
+	PublicKey  ed25519.PublicKey
+	PrivateKey ed25519.PrivateKey
+}
+
+// keyFile is the JSON encoding of a wallet on disk. The private key is
+// either in the clear or sealed with a passphrase.
+type keyFile struct {
+	Address    string     `json:"address"`
+	PublicKey  string     `json:"publicKey"`
+	PrivateKey string     `json:"privateKey,omitempty"`
+	Sealed     *sealedKey `json:"crypto,omitempty"`
+}
+
+// Generate creates a wallet with a random key pair
+func Generate() (*Wallet, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return &Wallet{PublicKey: publicKey, PrivateKey: privateKey}, nil
+}
+
+// FromSeed derives a wallet deterministically from a seed phrase and an
+// index, so the same seed always yields the same keys. Only for development
+// accounts: anyone knowing the seed holds the keys.
+func FromSeed(seed string, index int) *Wallet {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d", seed, index)))
+	privateKey := ed25519.NewKeyFromSeed(sum[:])
+	return &Wallet{PublicKey: privateKey.Public().(ed25519.PublicKey), PrivateKey: privateKey}
+}
+
+// AddressFromPublicKey derives an address from a public key: the hex of
+// the first 20 bytes of its SHA-256
+func AddressFromPublicKey(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:20])
+}
+
+// Address returns the wallet's address
+func (w *Wallet) Address() string {
+	return AddressFromPublicKey(w.PublicKey)
+}
+
+// Save writes the wallet to a new key file readable only by its owner. It
+// never overwrites an existing file.
+func (w *Wallet) Save(path string) error {
+	return writeKeyFile(path, keyFile{
+		Address:    w.Address(),
+		PublicKey:  hex.EncodeToString(w.PublicKey),
+		PrivateKey: hex.EncodeToString(w.PrivateKey),
+	})
+}
+
+// writeKeyFile writes a new key file readable only by its owner
+func writeKeyFile(path string, contents keyFile) error {
+	data, err := json.MarshalIndent(contents, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Load reads a key file written by Save
+func Load(path string) (*Wallet, error) {
+	return Open(path, "")
+}
+
+// Open reads a key file written by Save or SaveEncrypted. The passphrase
+// is only needed for encrypted files.
+func Open(path, passphrase string) (*Wallet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidKeyFile, path, err)
+	}
+
+	var privateKey []byte
+	if file.Sealed != nil {
+		if passphrase == "" {
+			return nil, fmt.Errorf("%w: %s", ErrPassphraseRequired, path)
+		}
+		if privateKey, err = file.Sealed.open(passphrase); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	} else if privateKey, err = hex.DecodeString(file.PrivateKey); err != nil {
+		privateKey = nil
+	}
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("%w: %s: malformed private key", ErrInvalidKeyFile, path)
+	}
+	w := &Wallet{PrivateKey: privateKey, PublicKey: ed25519.PrivateKey(privateKey).Public().(ed25519.PublicKey)}
+	if file.PublicKey != "" && file.PublicKey != hex.EncodeToString(w.PublicKey) {
+		return nil, fmt.Errorf("%w: %s: public key doesn't match the private key", ErrInvalidKeyFile, path)
+	}
+	if file.Address != "" && file.Address != w.Address() {
+		return nil, fmt.Errorf("%w: %s: address doesn't match the key", ErrInvalidKeyFile, path)
+	}
+	return w, nil
+}